@@ -0,0 +1,219 @@
+// alert notifies when the values extracted by Colibri change beyond configured thresholds.
+package alert
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Direction specifies which changes of a numeric value trigger an alert.
+type Direction int
+
+const (
+	// Any triggers the alert when the value increases or decreases.
+	Any Direction = iota
+
+	// Increase triggers the alert only when the value increases.
+	Increase
+
+	// Decrease triggers the alert only when the value decreases.
+	Decrease
+)
+
+// ErrNotifierIsNil is returned when Notifier is nil.
+var ErrNotifierIsNil = errors.New("Notifier is nil")
+
+// Rule specifies a field to monitor.
+type Rule struct {
+	// Field path of the value in the output, the keys of nested values are separated by "/".
+	Field string
+
+	// Threshold minimum relative change (0.05 = 5%) of a numeric value that triggers the alert.
+	// Non-numeric values trigger the alert whenever they change.
+	Threshold float64
+
+	// Direction specifies which changes of a numeric value trigger the alert.
+	Direction Direction
+}
+
+// Change represents a change of a monitored field.
+type Change struct {
+	// Key identifies the monitored output, e.g. the URL.
+	Key string `json:"key"`
+
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+
+	// Delta relative change of a numeric value, zero for non-numeric values
+	// and for the values that change from zero, whose relative change is not finite.
+	Delta float64 `json:"delta"`
+}
+
+// Notifier sends the alerts.
+type Notifier interface {
+	Notify(changes []Change) error
+}
+
+// Alerter compares the extracted outputs with the previous ones
+// and notifies the changes that exceed the thresholds of the rules.
+type Alerter struct {
+	Rules    []Rule
+	Notifier Notifier
+
+	mu   sync.Mutex
+	last map[string]map[string]any
+}
+
+// New returns a new Alerter structure.
+func New(notifier Notifier, rules ...Rule) *Alerter {
+	return &Alerter{Rules: rules, Notifier: notifier}
+}
+
+// Check compares the output with the previous output stored with the same key,
+// notifies the changes that exceed the thresholds and stores the new values.
+// The first output of a key is only stored. If the notification fails, the new values
+// are not stored, so the changes are notified again by the next Check.
+func (alerter *Alerter) Check(key string, output map[string]any) ([]Change, error) {
+	if alerter.Notifier == nil {
+		return nil, ErrNotifierIsNil
+	}
+
+	alerter.mu.Lock()
+	if alerter.last == nil {
+		alerter.last = make(map[string]map[string]any)
+	}
+
+	prev, seen := alerter.last[key]
+	current := make(map[string]any, len(alerter.Rules))
+
+	var changes []Change
+	for _, rule := range alerter.Rules {
+		value, ok := Lookup(output, rule.Field)
+		if !ok {
+			continue
+		}
+		current[rule.Field] = value
+
+		old, ok := prev[rule.Field]
+		if !seen || !ok {
+			continue
+		}
+
+		if change, ok := rule.compare(old, value); ok {
+			change.Key = key
+			changes = append(changes, change)
+		}
+	}
+	if len(changes) == 0 {
+		alerter.last[key] = current
+		alerter.mu.Unlock()
+		return nil, nil
+	}
+	alerter.mu.Unlock()
+
+	if err := alerter.Notifier.Notify(changes); err != nil {
+		return changes, err
+	}
+
+	alerter.mu.Lock()
+	alerter.last[key] = current
+	alerter.mu.Unlock()
+	return changes, nil
+}
+
+// Clear removes the stored outputs.
+func (alerter *Alerter) Clear() {
+	alerter.mu.Lock()
+	clear(alerter.last)
+	alerter.mu.Unlock()
+}
+
+func (rule Rule) compare(old, value any) (Change, bool) {
+	change := Change{Field: rule.Field, Old: old, New: value}
+
+	oldNum, okOld := ToFloat(old)
+	newNum, okNew := ToFloat(value)
+	if !okOld || !okNew {
+		return change, !reflect.DeepEqual(old, value)
+	}
+
+	if oldNum == newNum {
+		return change, false
+	}
+
+	// the change from zero exceeds any threshold
+	delta := math.Inf(1)
+	if newNum < 0 {
+		delta = math.Inf(-1)
+	}
+
+	if oldNum != 0 {
+		delta = (newNum - oldNum) / math.Abs(oldNum)
+		change.Delta = delta
+	}
+
+	switch rule.Direction {
+	case Increase:
+		if delta < 0 {
+			return change, false
+		}
+	case Decrease:
+		if delta > 0 {
+			return change, false
+		}
+	}
+	return change, math.Abs(delta) >= rule.Threshold
+}
+
+// Lookup returns the value of the field in the output.
+// The keys of nested values are separated by "/".
+func Lookup(output map[string]any, field string) (any, bool) {
+	var value any = output
+	for _, key := range strings.Split(field, "/") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+var numberRegexp = regexp.MustCompile(`-?\d+(\.\d+)?`)
+
+// ToFloat converts a numeric value or the first number found in a string
+// (e.g. "$1,299.99") to a float64.
+func ToFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case string:
+		s := numberRegexp.FindString(strings.ReplaceAll(v, ",", ""))
+		if s == "" {
+			return 0, false
+		}
+
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	}
+
+	switch rValue := reflect.ValueOf(value); rValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rValue.Int()), true
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rValue.Uint()), true
+
+	case reflect.Float32, reflect.Float64:
+		return rValue.Float(), true
+	}
+	return 0, false
+}
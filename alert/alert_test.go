@@ -0,0 +1,197 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testNotifier struct {
+	Changes []Change
+}
+
+func (n *testNotifier) Notify(changes []Change) error {
+	n.Changes = append(n.Changes, changes...)
+	return nil
+}
+
+type testErrNotifier struct {
+	err error
+}
+
+func (n *testErrNotifier) Notify(changes []Change) error {
+	return n.err
+}
+
+func TestAlerterCheck(t *testing.T) {
+	notifier := &testNotifier{}
+	alerter := New(notifier,
+		Rule{Field: "price", Threshold: 0.05, Direction: Decrease},
+		Rule{Field: "stock/status"},
+	)
+
+	tests := []struct {
+		Name        string
+		Output      map[string]any
+		WantChanges []Change
+	}{
+		{
+			"First",
+			map[string]any{"price": "$100.00", "stock": map[string]any{"status": "in stock"}},
+			nil,
+		},
+		{
+			"SmallDrop",
+			map[string]any{"price": "$97.00", "stock": map[string]any{"status": "in stock"}},
+			nil,
+		},
+		{
+			"Increase",
+			map[string]any{"price": "$120.00", "stock": map[string]any{"status": "in stock"}},
+			nil,
+		},
+		{
+			"Drop",
+			map[string]any{"price": "$90.00", "stock": map[string]any{"status": "sold out"}},
+			[]Change{
+				{Key: "item", Field: "price", Old: "$120.00", New: "$90.00", Delta: -0.25},
+				{Key: "item", Field: "stock/status", Old: "in stock", New: "sold out"},
+			},
+		},
+		{
+			"MissingField",
+			map[string]any{"stock": map[string]any{"status": "sold out"}},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			changes, err := alerter.Check("item", tt.Output)
+			if err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(changes, tt.WantChanges) {
+				t.Fatalf("got %v, want %v", changes, tt.WantChanges)
+			}
+		})
+	}
+
+	if len(notifier.Changes) != 2 {
+		t.Fatalf("got %v, want %v", len(notifier.Changes), 2)
+	}
+
+	t.Run("NotifierIsNil", func(t *testing.T) {
+		_, err := New(nil).Check("item", nil)
+		if !errors.Is(err, ErrNotifierIsNil) {
+			t.Fatalf("got %v, want %v", err, ErrNotifierIsNil)
+		}
+	})
+}
+
+func TestAlerterFromZero(t *testing.T) {
+	var got []Change
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	alerter := New(&Webhook{URL: ts.URL}, Rule{Field: "stock", Threshold: 0.5, Direction: Increase})
+	if _, err := alerter.Check("item", map[string]any{"stock": 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := alerter.Check("item", map[string]any{"stock": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Change{{Key: "item", Field: "stock", Old: 0, New: 3}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("got %v, want %v", changes, want)
+	} else if (len(got) != 1) || (got[0].Delta != 0) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAlerterNotifyErr(t *testing.T) {
+	var (
+		notifier = &testErrNotifier{err: errors.New("unavailable")}
+		alerter  = New(notifier, Rule{Field: "status"})
+	)
+
+	if _, err := alerter.Check("item", map[string]any{"status": "in stock"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alerter.Check("item", map[string]any{"status": "sold out"}); !errors.Is(err, notifier.err) {
+		t.Fatalf("got %v, want %v", err, notifier.err)
+	}
+
+	// the change is notified again
+	notifier.err = nil
+	changes, err := alerter.Check("item", map[string]any{"status": "sold out"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Change{{Key: "item", Field: "status", Old: "in stock", New: "sold out"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("got %v, want %v", changes, want)
+	}
+
+	if changes, _ := alerter.Check("item", map[string]any{"status": "sold out"}); changes != nil {
+		t.Fatalf("got %v, want %v", changes, nil)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	const secret = "s3cr3t"
+
+	var verified bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verified = Verify(body, secret, r.Header.Get(SignatureHeader))
+	}))
+	defer ts.Close()
+
+	webhook := &Webhook{URL: ts.URL, Secret: secret}
+	if err := webhook.Notify([]Change{{Key: "item", Field: "price", Old: 1, New: 2, Delta: 1}}); err != nil {
+		t.Fatal(err)
+	} else if !verified {
+		t.Fatal("invalid signature")
+	}
+
+	webhook.URL = ts.URL + "/%"
+	if err := webhook.Notify(nil); err == nil {
+		t.Fatal("nil error")
+	}
+}
+
+func TestEmail(t *testing.T) {
+	var msg string
+	email := &Email{
+		Addr: "localhost:25",
+		From: "colibri@example.com",
+		To:   []string{"user@example.com"},
+		sendMail: func(_ string, _ smtp.Auth, _ string, _ []string, b []byte) error {
+			msg = string(b)
+			return nil
+		},
+	}
+
+	if err := email.Notify([]Change{{Key: "item", Field: "price", Old: "$10", New: "$5", Delta: -0.5}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(msg, "item price: $10 -> $5 (-50.00%)") {
+		t.Fatal(msg)
+	}
+}
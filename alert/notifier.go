@@ -0,0 +1,113 @@
+package alert
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+)
+
+// SignatureHeader is the header that contains the HMAC-SHA256 signature of the webhook body.
+const SignatureHeader = "X-Colibri-Signature"
+
+// Webhook sends the changes in JSON format with a POST request to the URL.
+// If Secret is not empty, the body is signed with HMAC-SHA256 and
+// the signature is sent in the SignatureHeader header.
+type Webhook struct {
+	URL    string
+	Secret string
+
+	// Client used to send the request, if nil http.DefaultClient is used.
+	Client *http.Client
+}
+
+func (webhook *Webhook) Notify(changes []Change) error {
+	body, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if webhook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(body, webhook.Secret))
+	}
+
+	client := webhook.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if (resp.StatusCode < 200) || (resp.StatusCode > 299) {
+		return fmt.Errorf("webhook: unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of the body with the format "sha256=" + hex(HMAC-SHA256(body, secret)).
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature is valid for the body and the secret.
+func Verify(body []byte, secret, signature string) bool {
+	return hmac.Equal([]byte(Sign(body, secret)), []byte(signature))
+}
+
+// Email sends the changes by email using SMTP.
+type Email struct {
+	// Addr SMTP server address (host:port).
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+
+	// Subject of the email, if empty "Colibri alert" is used.
+	Subject string
+
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (email *Email) Notify(changes []Change) error {
+	subject := email.Subject
+	if subject == "" {
+		subject = "Colibri alert"
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", email.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(email.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	for _, change := range changes {
+		fmt.Fprintf(&msg, "%s %s: %v -> %v", change.Key, change.Field, change.Old, change.New)
+		if change.Delta != 0 {
+			fmt.Fprintf(&msg, " (%+.2f%%)", change.Delta*100)
+		}
+		msg.WriteString("\r\n")
+	}
+
+	sendMail := email.sendMail
+	if sendMail == nil {
+		sendMail = smtp.SendMail
+	}
+	return sendMail(email.Addr, email.Auth, email.From, email.To, []byte(msg.String()))
+}
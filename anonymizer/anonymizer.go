@@ -0,0 +1,150 @@
+// anonymizer scrubs personal data and secrets (emails, tokens, cookies, ...) from recorded
+// HTTP fixtures (cassettes, WARC files, dumps) so they can be committed to test suites.
+//
+// The scrubbed values are masked character by character, so the length of the
+// content does not change and the Content-Length of the recorded messages remains valid.
+// Digests of the content, such as WARC-Block-Digest, are not recalculated.
+package anonymizer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+)
+
+// DefaultMask is the character used to mask the scrubbed values.
+const DefaultMask = 'x'
+
+// DefaultHeaders are the headers whose values are scrubbed by default.
+var DefaultHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+	"X-CSRF-Token",
+}
+
+// DefaultRules are the rules used by default.
+var DefaultRules = []Rule{
+	// Email
+	{regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`), 0},
+
+	// JSON Web Token
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`), 0},
+
+	// Bearer token
+	{regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`), 1},
+
+	// token=..., "password": "...", api_key: ...
+	{regexp.MustCompile(`(?i)(?:access_|refresh_|api_|auth_|csrf_?|client_)?(?:token|key|secret|password|passwd|session_?id)["']?\s*[:=]\s*["']?([^"'&\s,;<}]+)`), 1},
+}
+
+// Rule specifies a regular expression whose matches are scrubbed.
+type Rule struct {
+	Regexp *regexp.Regexp
+
+	// Group number of the submatch that is scrubbed, 0 scrubs the whole match.
+	Group int
+}
+
+// Anonymizer scrubs personal data and secrets.
+type Anonymizer struct {
+	// Headers whose values are scrubbed (case insensitive).
+	Headers []string
+
+	// Rules applied to the whole content.
+	Rules []Rule
+
+	// Mask character used to mask the scrubbed values.
+	Mask byte
+}
+
+// New returns a new Anonymizer with the DefaultHeaders and DefaultRules.
+func New() *Anonymizer {
+	return &Anonymizer{
+		Headers: append([]string(nil), DefaultHeaders...),
+		Rules:   append([]Rule(nil), DefaultRules...),
+		Mask:    DefaultMask,
+	}
+}
+
+// Bytes returns a copy of the data with the personal data and secrets masked.
+func (anon *Anonymizer) Bytes(data []byte) []byte {
+	result := make([]byte, len(data))
+	copy(result, data)
+
+	if re := anon.headersRegexp(); re != nil {
+		for _, loc := range re.FindAllSubmatchIndex(result, -1) {
+			anon.mask(result[loc[2]:loc[3]])
+		}
+	}
+
+	for _, rule := range anon.Rules {
+		for _, loc := range rule.Regexp.FindAllSubmatchIndex(result, -1) {
+			i := 2 * rule.Group
+			if (i+1 >= len(loc)) || (loc[i] < 0) {
+				continue
+			}
+			anon.mask(result[loc[i]:loc[i+1]])
+		}
+	}
+	return result
+}
+
+// Copy reads all the content of src and writes it scrubbed in dst.
+func (anon *Anonymizer) Copy(dst io.Writer, src io.Reader) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	_, err = dst.Write(anon.Bytes(data))
+	return err
+}
+
+// File scrubs the content of the file in place.
+func (anon *Anonymizer) File(name string) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, anon.Bytes(data), info.Mode())
+}
+
+func (anon *Anonymizer) headersRegexp() *regexp.Regexp {
+	if len(anon.Headers) == 0 {
+		return nil
+	}
+
+	var names bytes.Buffer
+	for i, header := range anon.Headers {
+		if i > 0 {
+			names.WriteByte('|')
+		}
+		names.WriteString(regexp.QuoteMeta(header))
+	}
+	return regexp.MustCompile(`(?im)^(?:` + names.String() + `):[ \t]*([^\r\n]*)`)
+}
+
+// mask replaces the characters of the value with the mask,
+// except the separators "@" and "." to keep emails and domains readable.
+func (anon *Anonymizer) mask(value []byte) {
+	mask := anon.Mask
+	if mask == 0 {
+		mask = DefaultMask
+	}
+
+	for i, c := range value {
+		if (c != '@') && (c != '.') {
+			value[i] = mask
+		}
+	}
+}
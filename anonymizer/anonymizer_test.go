@@ -0,0 +1,71 @@
+package anonymizer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fixture = "GET /account?token=abc123&page=2 HTTP/1.1\r\n" +
+	"Host: example.com\r\n" +
+	"Authorization: Bearer s3cr3t\r\n" +
+	"cookie: session=0123456789\r\n" +
+	"\r\n" +
+	"HTTP/1.1 200 OK\r\n" +
+	"Set-Cookie: id=42; Path=/\r\n" +
+	"Content-Type: application/json\r\n" +
+	"Content-Length: 64\r\n" +
+	"\r\n" +
+	`{"email": "gopher@go.dev", "password": "hunter2", "name": "Go"}`
+
+func TestAnonymizerBytes(t *testing.T) {
+	var (
+		anon   = New()
+		output = string(anon.Bytes([]byte(fixture)))
+	)
+
+	if len(output) != len(fixture) {
+		t.Fatalf("got %v, want %v", len(output), len(fixture))
+	}
+
+	for _, secret := range []string{"abc123", "s3cr3t", "0123456789", "id=42", "gopher@go.dev", "hunter2"} {
+		if strings.Contains(output, secret) {
+			t.Fatalf("%q not scrubbed", secret)
+		}
+	}
+
+	for _, keep := range []string{"page=2", "Host: example.com", `"name": "Go"`, "xxxxxx@xx.xxx", "Content-Length: 64"} {
+		if !strings.Contains(output, keep) {
+			t.Fatalf("%q not found", keep)
+		}
+	}
+
+	t.Run("File", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "fixture.warc")
+		if err := os.WriteFile(name, []byte(fixture), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := anon.File(name); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		} else if string(data) != output {
+			t.Fatal("not equal")
+		}
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := anon.Copy(&buf, strings.NewReader(fixture)); err != nil {
+			t.Fatal(err)
+		} else if buf.String() != output {
+			t.Fatal("not equal")
+		}
+	})
+}
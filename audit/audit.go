@@ -0,0 +1,219 @@
+// audit records an append-only log of the outbound requests made by Colibri.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/parsers"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+const (
+	// DecisionAllowed the request was made and a response was received.
+	DecisionAllowed = "allowed"
+
+	// DecisionRobotsDeny the request was not made because robots.txt denies access to the URL.
+	DecisionRobotsDeny = "robots-deny"
+
+	// DecisionError the request failed.
+	DecisionError = "error"
+)
+
+// Entry is a record of the audit log.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	URL    string    `json:"url"`
+
+	// HeaderHash SHA-256 hash of the request header, see HashHeader.
+	HeaderHash string `json:"headerHash"`
+
+	// Selector name of the Follow selector that made the request, empty for the seed requests.
+	Selector string `json:"selector,omitempty"`
+
+	// Decision of the request: DecisionAllowed, DecisionRobotsDeny or DecisionError.
+	Decision   string `json:"decision"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	// Rules snapshot of the rules of the request, only recorded if Logger.RecordRules is true.
+	// See Replay.
+	Rules *colibri.Rules `json:"rules,omitempty"`
+}
+
+// Logger writes the entries of the audit log to the sinks.
+type Logger struct {
+	// RecordRules specifies whether a snapshot of the rules is stored in the entries so the
+	// requests can be replayed. The snapshot includes the values of the header and the proxy,
+	// which may contain credentials.
+	RecordRules bool
+
+	mu    sync.Mutex
+	sinks []Sink
+}
+
+// New returns a new Logger that writes the entries to the sinks.
+func New(sinks ...Sink) *Logger {
+	return &Logger{sinks: sinks}
+}
+
+// Wrap replaces the Client and RobotsTxt of Colibri with wrappers that record the requests.
+func (logger *Logger) Wrap(c *colibri.Colibri) {
+	if c.Client != nil {
+		c.Client = &Client{HTTPClient: c.Client, Logger: logger}
+	}
+
+	if c.RobotsTxt != nil {
+		c.RobotsTxt = &RobotsTxt{RobotsTxt: c.RobotsTxt, Logger: logger}
+	}
+}
+
+// Log writes the entry to all the sinks in order. If the Time is zero, the current time is used.
+// The entries are written one at a time, so the sinks receive them in the same order.
+func (logger *Logger) Log(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	var errs error
+	for _, sink := range logger.sinks {
+		errs = errors.Join(errs, sink.Write(&entry))
+	}
+	return errs
+}
+
+// LogRules writes an entry with the request of the rules and the decision.
+// An empty method is logged as GET.
+func (logger *Logger) LogRules(rules *colibri.Rules, decision string, statusCode int, err error) error {
+	entry := Entry{
+		Method:     rules.Method,
+		HeaderHash: HashHeader(rules.Header),
+		Decision:   decision,
+		StatusCode: statusCode,
+	}
+
+	if entry.Method == "" {
+		entry.Method = http.MethodGet
+	}
+
+	if rules.URL != nil {
+		entry.URL = rules.URL.String()
+	}
+
+	if selector, ok := rules.Fields[parsers.KeyFollowSelector].(string); ok {
+		entry.Selector = selector
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if logger.RecordRules {
+		entry.Rules = rules.Clone()
+	}
+	return logger.Log(entry)
+}
+
+// HashHeader returns the hex encoded SHA-256 hash of the header, the keys are sorted
+// so equal headers have the same hash. The values of the header are not stored in the log.
+func HashHeader(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		h.Write([]byte(http.CanonicalHeaderKey(key) + ": " + strings.Join(header[key], ", ") + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Client records the HTTP requests.
+// If the entry cannot be written, the body of the response is closed and the error is returned,
+// so no response is used without being audited.
+// See the colibri.HTTPClient interface.
+type Client struct {
+	colibri.HTTPClient
+	Logger *Logger
+}
+
+func (client *Client) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	return client.DoContext(context.Background(), c, rules)
+}
+
+// DoContext calls DoContext of the wrapped HTTPClient if it implements
+// colibri.HTTPClientContext, otherwise Do is called. See colibri.ClientDo.
+func (client *Client) DoContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	resp, err := colibri.ClientDo(ctx, client.HTTPClient, c, rules)
+
+	if err != nil {
+		return nil, errors.Join(err, client.Logger.LogRules(rules, DecisionError, 0, err))
+	}
+
+	if err := client.Logger.LogRules(rules, DecisionAllowed, resp.StatusCode(), nil); err != nil {
+		resp.Body().Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ReportCapabilities adds the "audit" feature and the capabilities of the wrapped HTTPClient to the report.
+// See the colibri.CapabilityReporter interface.
+func (client *Client) ReportCapabilities(caps *colibri.Capabilities) {
+	colibri.ReportCapabilitiesOf(client.HTTPClient, caps)
+	caps.Features = append(caps.Features, "audit")
+}
+
+// RobotsTxt records the requests denied by robots.txt.
+// See the colibri.RobotsTxt interface.
+type RobotsTxt struct {
+	colibri.RobotsTxt
+	Logger *Logger
+}
+
+func (robots *RobotsTxt) IsAllowed(c *colibri.Colibri, rules *colibri.Rules) error {
+	return robots.IsAllowedContext(context.Background(), c, rules)
+}
+
+// IsAllowedContext calls IsAllowedContext of the wrapped RobotsTxt if it implements
+// colibri.RobotsTxtContext, otherwise IsAllowed is called. See colibri.RobotsIsAllowed.
+func (robots *RobotsTxt) IsAllowedContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) error {
+	err := colibri.RobotsIsAllowed(ctx, robots.RobotsTxt, c, rules)
+	if errors.Is(err, webextractor.ErrorRobotstxtRestriction) {
+		return errors.Join(err, robots.Logger.LogRules(rules, DecisionRobotsDeny, 0, err))
+	}
+	return err
+}
+
+// ReportCapabilities adds the capabilities of the wrapped RobotsTxt to the report.
+// See the colibri.CapabilityReporter interface.
+func (robots *RobotsTxt) ReportCapabilities(caps *colibri.Capabilities) {
+	colibri.ReportCapabilitiesOf(robots.RobotsTxt, caps)
+}
+
+// CrawlDelay returns the crawl delay of the wrapped RobotsTxt if it implements
+// colibri.CrawlDelayer, otherwise zero. See the colibri.CrawlDelayer interface.
+func (robots *RobotsTxt) CrawlDelay(u *url.URL, userAgent string) time.Duration {
+	return colibri.RobotsCrawlDelay(robots.RobotsTxt, u, userAgent)
+}
+
+// Sitemaps returns the URLs of the Sitemap directives of the wrapped RobotsTxt if it implements
+// colibri.Sitemapper, otherwise nil. See the colibri.Sitemapper interface.
+func (robots *RobotsTxt) Sitemaps(host string) []string {
+	return colibri.RobotsSitemaps(robots.RobotsTxt, host)
+}
@@ -0,0 +1,268 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+func TestLogger(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	we, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	var entries []Entry
+	logger := New(SinkFunc(func(entry *Entry) error {
+		entries = append(entries, *entry)
+		return nil
+	}))
+	logger.Wrap(we)
+
+	rules := &colibri.Rules{
+		Method: "GET",
+		URL:    mustNewURL(ts.URL + "/html"),
+		Header: http.Header{"Accept": {"text/html"}},
+		Selectors: []*colibri.Selector{{
+			Name:      "links",
+			Expr:      "//a/@href",
+			All:       true,
+			Follow:    true,
+			Selectors: []*colibri.Selector{{Name: "title", Expr: "//title"}},
+		}},
+	}
+
+	var robotsWarnings []*colibri.Warning
+	we.OnWarning = func(_ context.Context, w *colibri.Warning) {
+		if w.Kind == colibri.WarningRobotsTxt {
+			robotsWarnings = append(robotsWarnings, w)
+		}
+	}
+
+	// the disallowed link is reported as a warning instead of an error
+	if _, _, err := we.Extract(rules); err != nil {
+		t.Fatal(err)
+	} else if len(robotsWarnings) != 1 {
+		t.Fatalf("got %v, want the warning of the disallowed link", robotsWarnings)
+	}
+	we.OnWarning = nil
+
+	type result struct {
+		Path, Selector, Decision string
+		StatusCode               int
+	}
+
+	want := []result{
+		{"/robots.txt", "", DecisionAllowed, http.StatusOK},
+		{"/html", "", DecisionAllowed, http.StatusOK},
+		{"/page", "links", DecisionAllowed, http.StatusOK},
+		{"/disallow", "links", DecisionRobotsDeny, 0},
+	}
+
+	var got []result
+	for _, entry := range entries {
+		got = append(got, result{mustNewURL(entry.URL).Path, entry.Selector, entry.Decision, entry.StatusCode})
+
+		if entry.Time.IsZero() || (entry.Method != "GET") || (len(entry.HeaderHash) != 64) {
+			t.Fatalf("incomplete entry %+v", entry)
+		}
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	t.Run("SinkErr", func(t *testing.T) {
+		testErr := errors.New("Test Error")
+
+		logger := New(SinkFunc(func(*Entry) error { return testErr }))
+		client := &Client{HTTPClient: &webextractor.Client{}, Logger: logger}
+
+		rules := &colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + "/html")}
+		if _, err := client.Do(colibri.New(), rules); !errors.Is(err, testErr) {
+			t.Fatalf("got %v, want %v", err, testErr)
+		}
+	})
+}
+
+func TestRobotsTxtCrawlDelay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nCrawl-delay: 2\nSitemap: https://example.com/sitemap.xml")
+	}))
+	defer ts.Close()
+
+	we, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	New(SinkFunc(func(*Entry) error { return nil })).Wrap(we)
+
+	u := mustNewURL(ts.URL + "/a")
+	if err := we.RobotsTxt.IsAllowed(we, &colibri.Rules{Method: "GET", URL: u}); err != nil {
+		t.Fatal(err)
+	}
+
+	crawlDelayer, ok := we.RobotsTxt.(colibri.CrawlDelayer)
+	if !ok {
+		t.Fatal("CrawlDelayer not implemented")
+	} else if got := crawlDelayer.CrawlDelay(u, "colibri"); got != 2*time.Second {
+		t.Fatalf("got %v, want %v", got, 2*time.Second)
+	}
+
+	sitemaps := colibri.RobotsSitemaps(we.RobotsTxt, u.Host)
+	if want := []string{"https://example.com/sitemap.xml"}; !reflect.DeepEqual(sitemaps, want) {
+		t.Fatalf("got %v, want %v", sitemaps, want)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	we, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	var buf bytes.Buffer
+	logger := New(NewWriterSink(&buf))
+	logger.RecordRules = true
+	logger.Wrap(we)
+
+	rules := &colibri.Rules{
+		Method:    "GET",
+		URL:       mustNewURL(ts.URL + "/header"),
+		Header:    http.Header{"X-Test": {"replayed"}},
+		Selectors: []*colibri.Selector{{Name: "title", Expr: "//title"}},
+	}
+
+	if _, _, err := we.Extract(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadEntries(&buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 2 {
+		t.Fatalf("got %v, want %v", len(entries), 2)
+	}
+
+	replayer, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer.Delay = nil // Deactivate Delay
+
+	_, output, err := Replay(replayer, entries[1])
+	if err != nil {
+		t.Fatal(err)
+	} else if output["title"] != "replayed" {
+		t.Fatalf("got %v, want %v", output["title"], "replayed")
+	}
+
+	if _, _, err := Replay(replayer, &Entry{}); !errors.Is(err, ErrNoRules) {
+		t.Fatalf("got %v, want %v", err, ErrNoRules)
+	}
+}
+
+func TestHashHeader(t *testing.T) {
+	var (
+		h1 = http.Header{"Accept": {"text/html"}, "User-Agent": {"colibri"}}
+		h2 = http.Header{"User-Agent": {"colibri"}, "Accept": {"text/html"}}
+		h3 = http.Header{"Accept": {"text/html"}}
+	)
+
+	if HashHeader(h1) != HashHeader(h2) {
+		t.Fatal("not equal")
+	}
+
+	if HashHeader(h1) == HashHeader(h3) {
+		t.Fatal("equal")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+
+	for i := 0; i < 2; i++ {
+		sink, err := OpenFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entry := &Entry{URL: fmt.Sprintf("https://example.com/%d", i), Decision: DecisionAllowed}
+		if err := sink.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+		sink.Close()
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var (
+		n       int
+		scanner = bufio.NewScanner(file)
+	)
+	for ; scanner.Scan(); n++ {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		} else if want := fmt.Sprintf("https://example.com/%d", n); entry.URL != want {
+			t.Fatalf("got %v, want %v", entry.URL, want)
+		}
+	}
+
+	// the entries are appended
+	if n != 2 {
+		t.Fatalf("got %v, want %v", n, 2)
+	}
+}
+
+func mustNewURL(rawURL string) *url.URL {
+	u, _ := url.Parse(rawURL)
+	return u
+}
+
+func testServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintln(w, "User-agent: *\nDisallow: /disallow")
+
+		case "/header":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, "<html><head><title>%s</title></head></html>", r.Header.Get("X-Test"))
+
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, `<html><body><a href="/page">Page</a><a href="/disallow">Disallow</a></body></html>`)
+
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, "<html><head><title>Audit</title></head></html>")
+		}
+	}))
+}
@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// ErrNoRules is returned when the entry does not have a snapshot of the rules.
+var ErrNoRules = errors.New("entry without rules, see Logger.RecordRules")
+
+// Replay makes the request of the entry again with the snapshot of the rules
+// (method, URL, header, proxy and selectors) and extracts the data,
+// useful to reproduce the failures of a crawl. The entry is not modified.
+func Replay(c *colibri.Colibri, entry *Entry) (colibri.Response, map[string]any, error) {
+	return ReplayContext(context.Background(), c, entry)
+}
+
+// ReplayContext is like Replay, the context is propagated to ExtractContext.
+func ReplayContext(ctx context.Context, c *colibri.Colibri, entry *Entry) (colibri.Response, map[string]any, error) {
+	if (entry == nil) || (entry.Rules == nil) {
+		return nil, nil, ErrNoRules
+	}
+	return c.ExtractContext(ctx, entry.Rules.Clone())
+}
+
+// ReadEntries reads the entries written in JSON Lines format by WriterSink or FileSink.
+func ReadEntries(r io.Reader) ([]*Entry, error) {
+	var (
+		entries []*Entry
+		scanner = bufio.NewScanner(r)
+	)
+	scanner.Buffer(nil, 16<<20)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Sink stores the entries of the audit log, the entries must only be appended.
+type Sink interface {
+	// Write appends the entry.
+	Write(entry *Entry) error
+}
+
+// SinkFunc is an adapter to use a function as a Sink.
+type SinkFunc func(entry *Entry) error
+
+func (fn SinkFunc) Write(entry *Entry) error {
+	return fn(entry)
+}
+
+// WriterSink writes the entries to W in JSON Lines format.
+type WriterSink struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+// NewWriterSink returns a new WriterSink that writes the entries to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{W: w}
+}
+
+func (sink *WriterSink) Write(entry *Entry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	_, err = sink.W.Write(append(b, '\n'))
+	return err
+}
+
+// FileSink writes the entries to a file in JSON Lines format.
+// The file is opened in append-only mode and synced after each entry.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFile returns a FileSink that appends the entries to the file, the file is created if it does not exist.
+func OpenFile(name string) (*FileSink, error) {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (sink *FileSink) Write(entry *Entry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if _, err := sink.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return sink.file.Sync()
+}
+
+// Close closes the file.
+func (sink *FileSink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.file.Close()
+}
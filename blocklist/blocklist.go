@@ -0,0 +1,321 @@
+// blocklist blocks the requests made by Colibri to the domains and URLs of lists loaded
+// from files or URLs and reloaded periodically, e.g. to honor opt-out lists and killswitches.
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+// DefaultInterval is the interval between the reloads of the sources used by default.
+const DefaultInterval = 5 * time.Minute
+
+// MaxSourceSize is the maximum number of bytes read from a source.
+const MaxSourceSize = 10 << 20
+
+var (
+	// ErrBlocked is returned when the URL matches a pattern of the blocklist.
+	ErrBlocked = errors.New("URL is blocked")
+
+	// ErrNotAllowed is returned when the allowlist has patterns and the URL does not match any.
+	ErrNotAllowed = errors.New("URL is not allowed")
+
+	// ErrSourceStatus is returned when the response of a source with a URL is not 200 OK.
+	ErrSourceStatus = errors.New("unexpected status code of the source")
+)
+
+// List blocks the URLs that match the patterns of the blocklist sources and, if the allowlist
+// sources have patterns, the URLs that do not match any of them. The blocklist has priority.
+//
+// The sources are files or http and https URLs with a pattern per line, the empty lines
+// and the lines starting with # are ignored. The patterns are domains, which match the domain
+// and its subdomains (example.com or *.example.com), or URLs, which match the URLs that start
+// with them and in which * matches any sequence of characters (https://example.com/*/private).
+type List struct {
+	// Block sources of the blocklist.
+	Block []string
+
+	// Allow sources of the allowlist.
+	Allow []string
+
+	// Interval between the reloads of the sources, see Watch. If zero, DefaultInterval is used.
+	Interval time.Duration
+
+	// HTTPClient gets the sources with URLs, if nil http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// OnError is called with the errors of the reloads of Watch.
+	OnError func(source string, err error)
+
+	rw       sync.RWMutex
+	patterns map[string]*patterns
+}
+
+// patterns are the patterns of a source.
+type patterns struct {
+	domains []string
+	urls    []*regexp.Regexp
+}
+
+// New returns a new List with the sources of the blocklist and the allowlist.
+func New(block, allow []string) *List {
+	return &List{Block: block, Allow: allow}
+}
+
+// Load loads the sources, the sources that fail keep the patterns loaded previously.
+// Returns the errors of the sources by source.
+func (list *List) Load(ctx context.Context) error {
+	var errs error
+	for _, source := range append(append([]string(nil), list.Block...), list.Allow...) {
+		if err := list.loadSource(ctx, source); err != nil {
+			errs = colibri.AddError(errs, source, err)
+		}
+	}
+	return errs
+}
+
+// Watch reloads the sources every Interval until the context is cancelled,
+// the errors are passed to OnError.
+func (list *List) Watch(ctx context.Context) {
+	interval := list.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, source := range append(append([]string(nil), list.Block...), list.Allow...) {
+			if err := list.loadSource(ctx, source); (err != nil) && (list.OnError != nil) {
+				list.OnError(source, err)
+			}
+		}
+	}
+}
+
+// Check returns ErrBlocked if the URL matches the blocklist or ErrNotAllowed
+// if the allowlist has patterns and the URL does not match any of them.
+func (list *List) Check(u *url.URL) error {
+	if u == nil {
+		return nil
+	}
+
+	var (
+		host    = strings.TrimSuffix(colibri.NormalizeHost(u.Hostname()), ".")
+		rawURL  = normalizeURL(u)
+		allowed = true
+	)
+
+	list.rw.RLock()
+	defer list.rw.RUnlock()
+
+	for _, source := range list.Block {
+		if list.patterns[source].match(host, rawURL) {
+			return ErrBlocked
+		}
+	}
+
+	for _, source := range list.Allow {
+		p := list.patterns[source]
+		if p.empty() {
+			continue
+		}
+
+		if p.match(host, rawURL) {
+			return nil
+		}
+		allowed = false
+	}
+
+	if !allowed {
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+// Wrap replaces the Client of Colibri with a Client that checks the URLs before each request.
+// If the Client is a *webextractor.Client, its CheckRedirect is set to check the redirects.
+func (list *List) Wrap(c *colibri.Colibri) {
+	if client, ok := c.Client.(*webextractor.Client); ok {
+		next := client.CheckRedirect
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if err := list.Check(req.URL); err != nil {
+				return err
+			}
+
+			if next != nil {
+				return next(req, via)
+			}
+			return nil
+		}
+	}
+
+	if c.Client != nil {
+		c.Client = &Client{HTTPClient: c.Client, List: list}
+	}
+}
+
+// loadSource loads the patterns of the source.
+func (list *List) loadSource(ctx context.Context, source string) error {
+	r, err := list.open(ctx, source)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	p, err := parsePatterns(io.LimitReader(r, MaxSourceSize))
+	if err != nil {
+		return err
+	}
+
+	list.rw.Lock()
+	if list.patterns == nil {
+		list.patterns = make(map[string]*patterns)
+	}
+	list.patterns[source] = p
+	list.rw.Unlock()
+	return nil
+}
+
+// open opens the file or gets the URL of the source.
+func (list *List) open(ctx context.Context, source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := list.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d", ErrSourceStatus, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// parsePatterns parses the patterns of a source.
+func parsePatterns(r io.Reader) (*patterns, error) {
+	var (
+		p       = &patterns{}
+		scanner = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if (line == "") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !strings.Contains(line, "://") {
+			domain := strings.TrimPrefix(strings.ToLower(line), "*.")
+			p.domains = append(p.domains, strings.TrimSuffix(colibri.NormalizeHost(domain), "."))
+			continue
+		}
+
+		// the scheme and the host are compared in lowercase
+		scheme, rest, _ := strings.Cut(line, "://")
+		host, path := rest, ""
+		if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+			host, path = rest[:i], rest[i:]
+		}
+		path, _, _ = strings.Cut(path, "#")
+
+		parts := strings.Split(strings.ToLower(scheme)+"://"+strings.ToLower(host)+path, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		p.urls = append(p.urls, regexp.MustCompile("^"+strings.Join(parts, ".*")))
+	}
+	return p, scanner.Err()
+}
+
+// match returns true if the host or the URL match any of the patterns.
+func (p *patterns) match(host, rawURL string) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, domain := range p.domains {
+		if (host == domain) || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	for _, re := range p.urls {
+		if re.MatchString(rawURL) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *patterns) empty() bool {
+	return (p == nil) || ((len(p.domains) == 0) && (len(p.urls) == 0))
+}
+
+// normalizeURL returns the URL without the fragment, with the scheme and the host in lowercase.
+func normalizeURL(u *url.URL) string {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = colibri.NormalizeHost(n.Host)
+	n.Fragment, n.RawFragment = "", ""
+	return n.String()
+}
+
+// Client checks the URLs with the List before each request.
+// See the colibri.HTTPClient interface.
+type Client struct {
+	colibri.HTTPClient
+	List *List
+}
+
+func (client *Client) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	return client.DoContext(context.Background(), c, rules)
+}
+
+// DoContext calls DoContext of the wrapped HTTPClient if it implements
+// colibri.HTTPClientContext, otherwise Do is called. See colibri.ClientDo.
+func (client *Client) DoContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	if err := client.List.Check(rules.URL); err != nil {
+		return nil, err
+	}
+	return colibri.ClientDo(ctx, client.HTTPClient, c, rules)
+}
+
+// ReportCapabilities adds the "blocklist" feature and the capabilities of the wrapped HTTPClient to the report.
+// See the colibri.CapabilityReporter interface.
+func (client *Client) ReportCapabilities(caps *colibri.Capabilities) {
+	colibri.ReportCapabilitiesOf(client.HTTPClient, caps)
+	caps.Features = append(caps.Features, "blocklist")
+}
@@ -0,0 +1,175 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+func TestList(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		optOut = "# opt-out\nOptOut.example\n\nhttps://shop.example/*/private\n"
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/opt-out.txt":
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprint(w, optOut)
+
+		case "/missing.txt":
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	allowFile := filepath.Join(t.TempDir(), "allow.txt")
+	if err := os.WriteFile(allowFile, []byte("*.example\nhttps://other.test/public\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	list := New([]string{ts.URL + "/opt-out.txt"}, []string{allowFile})
+	if err := list.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		URL string
+		Err error
+	}{
+		{"https://shop.example/items", nil},
+		{"https://SHOP.example/a/b/private/1", ErrBlocked},
+		{"https://www.optout.example/", ErrBlocked},
+		{"https://optout.example.org/", ErrNotAllowed},
+		{"https://other.test/public/page", nil},
+		{"https://other.test/secret", ErrNotAllowed},
+	}
+
+	for _, tt := range tests {
+		if err := list.Check(mustNewURL(tt.URL)); err != tt.Err {
+			t.Fatalf("%s: got %v, want %v", tt.URL, err, tt.Err)
+		}
+	}
+
+	// the sources that fail keep the patterns
+	list.Block = append(list.Block, ts.URL+"/missing.txt")
+	if err := list.Load(context.Background()); !errors.Is(mustGet(t, err, ts.URL+"/missing.txt"), ErrSourceStatus) {
+		t.Fatalf("got %v, want %v", err, ErrSourceStatus)
+	} else if err := list.Check(mustNewURL("https://optout.example/")); err != ErrBlocked {
+		t.Fatalf("got %v, want %v", err, ErrBlocked)
+	}
+
+	// hot-reload
+	mu.Lock()
+	optOut = "shop.example\n"
+	mu.Unlock()
+
+	var errs []string
+	list.Interval = 10 * time.Millisecond
+	list.OnError = func(source string, err error) {
+		mu.Lock()
+		errs = append(errs, source)
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		list.Watch(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for list.Check(mustNewURL("https://shop.example/items")) != ErrBlocked {
+		if time.Now().After(deadline) {
+			t.Fatal("list not reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if err := list.Check(mustNewURL("https://optout.example/")); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if (len(errs) == 0) || (errs[0] != ts.URL+"/missing.txt") {
+		t.Fatalf("got %v, want %v", errs, ts.URL+"/missing.txt")
+	}
+}
+
+func TestListWrap(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/blocked", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	}))
+	defer ts.Close()
+
+	blockFile := filepath.Join(t.TempDir(), "block.txt")
+	if err := os.WriteFile(blockFile, []byte(ts.URL+"/blocked\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	we, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	list := New([]string{blockFile}, nil)
+	if err := list.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	list.Wrap(we)
+
+	tests := []struct {
+		Path string
+		Err  error
+	}{
+		{"/page", nil},
+		{"/blocked", ErrBlocked},
+		{"/redirect", ErrBlocked},
+	}
+
+	for _, tt := range tests {
+		_, err := we.Do(&colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + tt.Path), IgnoreRobotsTxt: true})
+		if !errors.Is(err, tt.Err) {
+			t.Fatalf("%s: got %v, want %v", tt.Path, err, tt.Err)
+		}
+	}
+}
+
+func mustGet(t *testing.T, err error, key string) error {
+	errs, ok := err.(*colibri.Errs)
+	if !ok {
+		t.Fatalf("got %v, want *colibri.Errs", err)
+	}
+
+	err, _ = errs.Get(key)
+	return err
+}
+
+func mustNewURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
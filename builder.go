@@ -0,0 +1,603 @@
+package colibri
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+var (
+	// ErrInvalidMethod is returned when the HTTP method is invalid.
+	ErrInvalidMethod = errors.New("invalid method")
+
+	// ErrInvalidURL is returned when the URL is not an absolute HTTP or HTTPS URL.
+	ErrInvalidURL = errors.New("must be an absolute http or https URL")
+
+	// ErrNegativeDuration is returned when the duration is negative.
+	ErrNegativeDuration = errors.New("duration must not be negative")
+
+	// ErrNegativeRetries is returned when the number of retries is negative.
+	ErrNegativeRetries = errors.New("retries must not be negative")
+
+	// ErrNegativeSize is returned when the size is negative.
+	ErrNegativeSize = errors.New("size must not be negative")
+
+	// ErrNegativePages is returned when the number of pages is negative.
+	ErrNegativePages = errors.New("pages must not be negative")
+
+	// ErrNegativeLimit is returned when a limit is negative.
+	ErrNegativeLimit = errors.New("limit must not be negative")
+
+	// ErrDuplicateSelector is returned when there is already a selector with the same name.
+	ErrDuplicateSelector = errors.New("duplicate selector")
+
+	// ErrDuplicateVariant is returned when a variant name is repeated.
+	ErrDuplicateVariant = errors.New("duplicate variant")
+
+	// ErrURLRequired is returned when the URL is not specified.
+	ErrURLRequired = errors.New("URL is required")
+)
+
+// SelectorBuilder builds a Selector using a fluent API.
+//
+//	selector := colibri.NewSelector("links").
+//		XPath("//a/@href").
+//		All().
+//		Follow().
+//		Child(colibri.NewSelector("title").XPath("//title")).
+//		Build()
+type SelectorBuilder struct {
+	selector *Selector
+}
+
+// NewSelector returns a new SelectorBuilder with the selector name.
+func NewSelector(name string) *SelectorBuilder {
+	return &SelectorBuilder{selector: &Selector{Name: name, Fields: make(map[string]any)}}
+}
+
+// Expr sets the expression and the type of the expression.
+func (builder *SelectorBuilder) Expr(expr, exprType string) *SelectorBuilder {
+	builder.selector.Expr = expr
+	builder.selector.Type = exprType
+	return builder
+}
+
+// XPath sets an XPath expression.
+func (builder *SelectorBuilder) XPath(expr string) *SelectorBuilder {
+	return builder.Expr(expr, "xpath")
+}
+
+// CSS sets a CSS selector.
+func (builder *SelectorBuilder) CSS(expr string) *SelectorBuilder {
+	return builder.Expr(expr, "css")
+}
+
+// Regular sets a regular expression.
+func (builder *SelectorBuilder) Regular(expr string) *SelectorBuilder {
+	return builder.Expr(expr, "regular")
+}
+
+// JSONPath sets a JSONPath expression.
+func (builder *SelectorBuilder) JSONPath(expr string) *SelectorBuilder {
+	return builder.Expr(expr, "jsonpath")
+}
+
+// Var specifies the variable in which the value found is stored, see KeyVar.
+func (builder *SelectorBuilder) Var(name string) *SelectorBuilder {
+	builder.selector.Fields[KeyVar] = name
+	return builder
+}
+
+// Next specifies that the selector finds the URL of the next page, see KeyNext.
+func (builder *SelectorBuilder) Next() *SelectorBuilder {
+	builder.selector.Fields[KeyNext] = true
+	return builder
+}
+
+// Required specifies that the selector must find an element.
+func (builder *SelectorBuilder) Required() *SelectorBuilder {
+	builder.selector.Required = true
+	return builder
+}
+
+// SkipIf skips the selector if the selector of the same level with the name finds the value, see KeySkipIf.
+func (builder *SelectorBuilder) SkipIf(name string, value any) *SelectorBuilder {
+	conditions, ok := builder.selector.Fields[KeySkipIf].(map[string]any)
+	if !ok {
+		conditions = make(map[string]any)
+		builder.selector.Fields[KeySkipIf] = conditions
+	}
+
+	conditions[name] = value
+	return builder
+}
+
+// Allow adds the regular expressions of the URLs that the Follow selector can follow.
+func (builder *SelectorBuilder) Allow(res ...*regexp.Regexp) *SelectorBuilder {
+	builder.selector.Allow = append(builder.selector.Allow, res...)
+	return builder
+}
+
+// Deny adds the regular expressions of the URLs that the Follow selector does not follow.
+func (builder *SelectorBuilder) Deny(res ...*regexp.Regexp) *SelectorBuilder {
+	builder.selector.Deny = append(builder.selector.Deny, res...)
+	return builder
+}
+
+// All specifies that all elements are to be found.
+func (builder *SelectorBuilder) All() *SelectorBuilder {
+	builder.selector.All = true
+	return builder
+}
+
+// Follow specifies that the URLs found by the selector should be followed.
+func (builder *SelectorBuilder) Follow() *SelectorBuilder {
+	builder.selector.Follow = true
+	return builder
+}
+
+// Child adds the nested selectors.
+func (builder *SelectorBuilder) Child(children ...*SelectorBuilder) *SelectorBuilder {
+	for _, child := range children {
+		if child != nil {
+			builder.selector.Selectors = append(builder.selector.Selectors, child.Build())
+		}
+	}
+	return builder
+}
+
+// Pipe adds an operation applied to the values found by the selector, see Selector.Pipes.
+func (builder *SelectorBuilder) Pipe(name string, args ...string) *SelectorBuilder {
+	builder.selector.Pipes = append(builder.selector.Pipes, Pipe{Name: name, Args: args})
+	return builder
+}
+
+// Field adds an additional field to the selector, see Selector.Rules.
+func (builder *SelectorBuilder) Field(key string, value any) *SelectorBuilder {
+	builder.selector.Fields[key] = value
+	return builder
+}
+
+// Build returns a copy of the built Selector.
+func (builder *SelectorBuilder) Build() *Selector {
+	return builder.selector.Clone()
+}
+
+// RulesBuilder builds Rules validating each value as it is added.
+// The errors are accumulated and returned by Build, see AddError.
+//
+//	rules, err := colibri.NewRulesBuilder().
+//		WithURL("https://example.com").
+//		WithHeader("User-Agent", "Colibri").
+//		WithTimeout(10 * time.Second).
+//		WithSelector(colibri.NewSelector("title").XPath("//title")).
+//		Build()
+type RulesBuilder struct {
+	rules *Rules
+	errs  error
+}
+
+// NewRulesBuilder returns a new RulesBuilder, the default method is GET.
+func NewRulesBuilder() *RulesBuilder {
+	return &RulesBuilder{rules: &Rules{Method: "GET", Header: http.Header{}, Fields: make(map[string]any)}}
+}
+
+// WithMethod sets the HTTP method.
+func (builder *RulesBuilder) WithMethod(method string) *RulesBuilder {
+	if (method == "") || (len(method) != len(strings.Map(methodRune, method))) {
+		builder.errs = AddError(builder.errs, KeyMethod, ErrInvalidMethod)
+		return builder
+	}
+
+	builder.rules.Method = strings.ToUpper(method)
+	return builder
+}
+
+// WithURL sets the URL, it must be an absolute HTTP or HTTPS URL.
+func (builder *RulesBuilder) WithURL(rawURL string) *RulesBuilder {
+	u, err := ToURL(rawURL)
+	if (err == nil) && (((u.Scheme != "http") && (u.Scheme != "https")) || (u.Host == "")) {
+		err = ErrInvalidURL
+	}
+
+	if err != nil {
+		builder.errs = AddError(builder.errs, KeyURL, err)
+		return builder
+	}
+
+	builder.rules.URL = u
+	return builder
+}
+
+// WithProxy sets the proxy URL.
+func (builder *RulesBuilder) WithProxy(rawURL string) *RulesBuilder {
+	u, err := ToURL(rawURL)
+	if (err == nil) && ((u.Scheme == "") || ((u.Host == "") && (u.Path == ""))) {
+		err = ErrInvalidURL
+	}
+
+	if err != nil {
+		builder.errs = AddError(builder.errs, KeyProxy, err)
+		return builder
+	}
+
+	builder.rules.Proxy = u
+	return builder
+}
+
+// WithHeader adds the values to the header key.
+func (builder *RulesBuilder) WithHeader(key string, values ...string) *RulesBuilder {
+	if !httpguts.ValidHeaderFieldName(key) {
+		builder.errs = AddError(builder.errs, KeyHeader, ErrInvalidHeader)
+		return builder
+	}
+
+	for _, value := range values {
+		if !httpguts.ValidHeaderFieldValue(value) {
+			builder.errs = AddError(builder.errs, KeyHeader, ErrInvalidHeader)
+			return builder
+		}
+	}
+
+	for _, value := range values {
+		builder.rules.Header.Add(key, value)
+	}
+	return builder
+}
+
+// WithTLS sets the TLS configuration of the requests, the TLS is copied.
+func (builder *RulesBuilder) WithTLS(t *TLS) *RulesBuilder {
+	builder.rules.TLS = t.Clone()
+	return builder
+}
+
+// WithTimeout sets the time limit for the HTTP request.
+func (builder *RulesBuilder) WithTimeout(timeout time.Duration) *RulesBuilder {
+	if timeout < 0 {
+		builder.errs = AddError(builder.errs, KeyTimeout, ErrNegativeDuration)
+		return builder
+	}
+
+	builder.rules.Timeout = timeout
+	return builder
+}
+
+// WithParseTimeout sets the time limit for parsing the response.
+func (builder *RulesBuilder) WithParseTimeout(timeout time.Duration) *RulesBuilder {
+	if timeout < 0 {
+		builder.errs = AddError(builder.errs, KeyParseTimeout, ErrNegativeDuration)
+		return builder
+	}
+
+	builder.rules.ParseTimeout = timeout
+	return builder
+}
+
+// WithDelay sets the delay time between requests.
+func (builder *RulesBuilder) WithDelay(delay time.Duration) *RulesBuilder {
+	if delay < 0 {
+		builder.errs = AddError(builder.errs, KeyDelay, ErrNegativeDuration)
+		return builder
+	}
+
+	builder.rules.Delay = delay
+	return builder
+}
+
+// WithRetries sets the maximum number of retries, the base delay between retries
+// and the status codes of the responses that are retried.
+func (builder *RulesBuilder) WithRetries(retries int, backoff time.Duration, statusCodes ...int) *RulesBuilder {
+	if retries < 0 {
+		builder.errs = AddError(builder.errs, KeyRetries, ErrNegativeRetries)
+		return builder
+	}
+
+	if backoff < 0 {
+		builder.errs = AddError(builder.errs, KeyRetryBackoff, ErrNegativeDuration)
+		return builder
+	}
+
+	codes, err := toStatusCodes(statusCodes)
+	if err != nil {
+		builder.errs = AddError(builder.errs, KeyRetryOn, err)
+		return builder
+	}
+
+	builder.rules.Retries = retries
+	builder.rules.RetryBackoff = backoff
+	builder.rules.RetryOn = codes
+	return builder
+}
+
+// WithMaxWait sets the maximum total time waited for the Retry-After header of the responses.
+func (builder *RulesBuilder) WithMaxWait(maxWait time.Duration) *RulesBuilder {
+	if maxWait < 0 {
+		builder.errs = AddError(builder.errs, KeyMaxWait, ErrNegativeDuration)
+		return builder
+	}
+
+	builder.rules.MaxWait = maxWait
+	return builder
+}
+
+// WithDisableCompression specifies whether the compressed response bodies should not be requested and decoded.
+func (builder *RulesBuilder) WithDisableCompression(disable bool) *RulesBuilder {
+	builder.rules.DisableCompression = disable
+	return builder
+}
+
+// WithMaxBodySize sets the maximum number of bytes of the response body.
+func (builder *RulesBuilder) WithMaxBodySize(size int64) *RulesBuilder {
+	if size < 0 {
+		builder.errs = AddError(builder.errs, KeyMaxBodySize, ErrNegativeSize)
+		return builder
+	}
+
+	builder.rules.MaxBodySize = size
+	return builder
+}
+
+// WithMaxPages sets the maximum number of pages extracted following the links to the next page.
+func (builder *RulesBuilder) WithMaxPages(pages int) *RulesBuilder {
+	if pages < 0 {
+		builder.errs = AddError(builder.errs, KeyMaxPages, ErrNegativePages)
+		return builder
+	}
+
+	builder.rules.MaxPages = pages
+	return builder
+}
+
+// WithMaxDepth sets the maximum number of nested follows from the requested page.
+func (builder *RulesBuilder) WithMaxDepth(depth int) *RulesBuilder {
+	if depth < 0 {
+		builder.errs = AddError(builder.errs, KeyMaxDepth, ErrNegativeLimit)
+		return builder
+	}
+
+	builder.rules.MaxDepth = depth
+	return builder
+}
+
+// WithMaxRequests sets the maximum number of requests of an extraction.
+func (builder *RulesBuilder) WithMaxRequests(requests int) *RulesBuilder {
+	if requests < 0 {
+		builder.errs = AddError(builder.errs, KeyMaxRequests, ErrNegativeLimit)
+		return builder
+	}
+
+	builder.rules.MaxRequests = requests
+	return builder
+}
+
+// WithFollowConcurrency sets the maximum number of URLs of a Follow selector requested concurrently.
+func (builder *RulesBuilder) WithFollowConcurrency(concurrency int) *RulesBuilder {
+	if concurrency < 0 {
+		builder.errs = AddError(builder.errs, KeyFollowConcurrency, ErrNegativeLimit)
+		return builder
+	}
+
+	builder.rules.FollowConcurrency = concurrency
+	return builder
+}
+
+// WithRender specifies that the page should be rendered executing its JavaScript
+// and the time to wait after the page is loaded.
+func (builder *RulesBuilder) WithRender(wait time.Duration) *RulesBuilder {
+	if wait < 0 {
+		builder.errs = AddError(builder.errs, KeyRenderWait, ErrNegativeDuration)
+		return builder
+	}
+
+	builder.rules.Render = true
+	builder.rules.RenderWait = wait
+	return builder
+}
+
+// WithVars declares the variables of the crawl with their initial values, see Vars.
+func (builder *RulesBuilder) WithVars(values map[string]string) *RulesBuilder {
+	builder.rules.Vars = NewVars(values)
+	return builder
+}
+
+// WithUseCookies specifies whether the client should send and store Cookies.
+func (builder *RulesBuilder) WithUseCookies(useCookies bool) *RulesBuilder {
+	builder.rules.UseCookies = useCookies
+	return builder
+}
+
+// WithIgnoreRobotsTxt specifies whether robots.txt should be ignored.
+func (builder *RulesBuilder) WithIgnoreRobotsTxt(ignore bool) *RulesBuilder {
+	builder.rules.IgnoreRobotsTxt = ignore
+	return builder
+}
+
+// WithAllow adds the regular expressions of the URLs that the Follow selectors can follow.
+func (builder *RulesBuilder) WithAllow(patterns ...string) *RulesBuilder {
+	res, err := toRegexps(patterns)
+	if err != nil {
+		builder.errs = AddError(builder.errs, KeyAllow, err)
+		return builder
+	}
+
+	builder.rules.Allow = append(builder.rules.Allow, res...)
+	return builder
+}
+
+// WithDeny adds the regular expressions of the URLs that the Follow selectors do not follow.
+func (builder *RulesBuilder) WithDeny(patterns ...string) *RulesBuilder {
+	res, err := toRegexps(patterns)
+	if err != nil {
+		builder.errs = AddError(builder.errs, KeyDeny, err)
+		return builder
+	}
+
+	builder.rules.Deny = append(builder.rules.Deny, res...)
+	return builder
+}
+
+// WithSession sets the session of the request.
+func (builder *RulesBuilder) WithSession(session string) *RulesBuilder {
+	builder.rules.Session = session
+	return builder
+}
+
+// WithSelector adds the selectors.
+// The selectors must have a name and an expression, and the names must be unique.
+func (builder *RulesBuilder) WithSelector(selectors ...*SelectorBuilder) *RulesBuilder {
+	for _, sb := range selectors {
+		if sb == nil {
+			continue
+		}
+
+		selector := sb.Build()
+		if err := validateSelector(selector); err != nil {
+			builder.errs = AddError(builder.errs, selectorKey(selector), err)
+			continue
+		}
+
+		if hasSelector(builder.rules.Selectors, selector.Name) {
+			builder.errs = AddError(builder.errs, selectorKey(selector), ErrDuplicateSelector)
+			continue
+		}
+		builder.rules.Selectors = append(builder.rules.Selectors, selector)
+	}
+	return builder
+}
+
+// WithVariant adds a variant with the Detect selector and the selectors,
+// the variants are tried in the order they are added, see Variant.
+// The name of the Detect selector is the name of the variant.
+func (builder *RulesBuilder) WithVariant(name string, detect *SelectorBuilder, selectors ...*SelectorBuilder) *RulesBuilder {
+	key := name
+	if key == "" {
+		key = KeyVariants
+	}
+
+	if (name == "") || (detect == nil) {
+		builder.errs = AddError(builder.errs, key, ErrInvalidVariant)
+		return builder
+	}
+
+	for _, variant := range builder.rules.Variants {
+		if variant.Name == name {
+			builder.errs = AddError(builder.errs, key, ErrDuplicateVariant)
+			return builder
+		}
+	}
+
+	variant := &Variant{Name: name, Detect: detect.Build()}
+	variant.Detect.Name = name
+
+	var errs error
+	if err := validateSelector(variant.Detect); err != nil {
+		errs = AddError(errs, KeyDetect, err)
+	}
+
+	for _, sb := range selectors {
+		if sb == nil {
+			continue
+		}
+
+		selector := sb.Build()
+		if err := validateSelector(selector); err != nil {
+			errs = AddError(errs, selectorKey(selector), err)
+			continue
+		}
+
+		if hasSelector(variant.Selectors, selector.Name) {
+			errs = AddError(errs, selectorKey(selector), ErrDuplicateSelector)
+			continue
+		}
+		variant.Selectors = append(variant.Selectors, selector)
+	}
+
+	if errs != nil {
+		builder.errs = AddError(builder.errs, key, errs)
+		return builder
+	}
+
+	builder.rules.Variants = append(builder.rules.Variants, variant)
+	return builder
+}
+
+// WithField adds an additional field.
+func (builder *RulesBuilder) WithField(key string, value any) *RulesBuilder {
+	builder.rules.Fields[key] = value
+	return builder
+}
+
+// Build returns a copy of the built Rules.
+// Returns the accumulated errors or ErrURLRequired if the URL was not specified.
+func (builder *RulesBuilder) Build() (*Rules, error) {
+	errs := builder.errs
+	if (builder.rules.URL == nil) && !hasError(errs, KeyURL) {
+		errs = AddError(errs, KeyURL, ErrURLRequired)
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+	return builder.rules.Clone(), nil
+}
+
+func validateSelector(selector *Selector) error {
+	if (selector.Name == "") || (selector.Expr == "") {
+		return ErrInvalidSelector
+	}
+
+	var errs error
+	for _, child := range selector.Selectors {
+		if err := validateSelector(child); err != nil {
+			errs = AddError(errs, selectorKey(child), err)
+		}
+	}
+
+	for i, child := range selector.Selectors {
+		if hasSelector(selector.Selectors[:i], child.Name) {
+			errs = AddError(errs, selectorKey(child), ErrDuplicateSelector)
+		}
+	}
+	return errs
+}
+
+// selectorKey returns the key used to store the errors of the selector.
+func selectorKey(selector *Selector) string {
+	if selector.Name == "" {
+		return KeySelectors
+	}
+	return selector.Name
+}
+
+func hasSelector(selectors []*Selector, name string) bool {
+	for _, selector := range selectors {
+		if selector.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasError(errs error, key string) bool {
+	e, ok := errs.(*Errs)
+	if !ok {
+		return false
+	}
+
+	e.rw.RLock()
+	_, ok = e.data[key]
+	e.rw.RUnlock()
+	return ok
+}
+
+// methodRune returns -1 if the rune is not valid in an HTTP method (token).
+func methodRune(r rune) rune {
+	if (r < 127) && httpguts.IsTokenRune(r) {
+		return r
+	}
+	return -1
+}
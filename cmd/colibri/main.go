@@ -0,0 +1,260 @@
+// colibri runs the rules files with WebExtractor and writes the data extracted to the standard output.
+//
+//	colibri [flags] rules.json...
+//
+// Each file contains the raw rules in JSON or a list of them, see the "Raw Rules ~ JSON"
+// section of the README. The files with the extension .yaml or .yml are read as YAML
+// with the same keys. The outputs are written in the order of the rules.
+//
+//	-format      format of the outputs: json (default), jsonl or csv
+//	-proxy       URL of the proxy of the requests
+//	-user-agent  User-Agent of the requests
+//	-timeout     timeout of each request, e.g. 30s
+//
+// The exit status is 1 if an extraction failed and 2 if the flags or the rules files are invalid.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/export"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Exit status codes.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// Output formats.
+const (
+	formatJSON      = "json"
+	formatJSONLines = "jsonl"
+	formatCSV       = "csv"
+)
+
+var (
+	errNoRulesFiles = errors.New("no rules files")
+	errFormat       = errors.New("unknown output format")
+)
+
+// options are the flags of the command.
+type options struct {
+	format    string
+	proxy     *url.URL
+	userAgent string
+	timeout   time.Duration
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command with the arguments and returns the exit status.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	opts, files, err := parseFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	} else if err != nil {
+		fmt.Fprintln(stderr, "colibri:", err)
+		return exitUsage
+	}
+
+	var rawRules []colibri.RawRules
+	for _, name := range files {
+		rr, err := readRulesFile(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "colibri: %s: %v\n", name, err)
+			return exitUsage
+		}
+		rawRules = append(rawRules, rr...)
+	}
+
+	c, err := webextractor.New()
+	if err != nil {
+		fmt.Fprintln(stderr, "colibri:", err)
+		return exitFailure
+	}
+
+	enc := newEncoder(opts.format, stdout)
+	status := exitOK
+	for _, rr := range rawRules {
+		output, err := extract(ctx, c, rr, opts)
+		if err != nil {
+			fmt.Fprintln(stderr, "colibri:", err)
+			status = exitFailure
+		}
+
+		if output == nil {
+			continue
+		}
+
+		if err := enc.Encode(output); err != nil {
+			fmt.Fprintln(stderr, "colibri:", err)
+			return exitFailure
+		}
+	}
+	return status
+}
+
+// parseFlags returns the options and the names of the rules files.
+func parseFlags(args []string, stderr io.Writer) (*options, []string, error) {
+	var (
+		opts  = &options{}
+		proxy string
+		flags = flag.NewFlagSet("colibri", flag.ContinueOnError)
+	)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: colibri [flags] rules.json...")
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&opts.format, "format", formatJSON, "format of the outputs: json, jsonl or csv")
+	flags.StringVar(&proxy, "proxy", "", "URL of the proxy of the requests")
+	flags.StringVar(&opts.userAgent, "user-agent", "", "User-Agent of the requests")
+	flags.DurationVar(&opts.timeout, "timeout", 0, "timeout of each request, e.g. 30s")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	switch opts.format {
+	case formatJSON, formatJSONLines, formatCSV:
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", errFormat, opts.format)
+	}
+
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.proxy = u
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return nil, nil, errNoRulesFiles
+	}
+	return opts, flags.Args(), nil
+}
+
+// readRulesFile returns the raw rules of the file, an object or a list of objects.
+func readRulesFile(name string) ([]colibri.RawRules, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if ext := strings.ToLower(filepath.Ext(name)); (ext == ".yaml") || (ext == ".yml") {
+		if b, err = yamlToJSON(b); err != nil {
+			return nil, err
+		}
+	}
+
+	b = bytes.TrimSpace(b)
+	if bytes.HasPrefix(b, []byte("[")) {
+		var list []colibri.RawRules
+		err := json.Unmarshal(b, &list)
+		return list, err
+	}
+
+	var rawRules colibri.RawRules
+	if err := json.Unmarshal(b, &rawRules); err != nil {
+		return nil, err
+	}
+	return []colibri.RawRules{rawRules}, nil
+}
+
+// yamlToJSON converts the YAML document to JSON,
+// so the values have the same types as the values of the JSON files.
+func yamlToJSON(b []byte) ([]byte, error) {
+	var v any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// extract extracts the data of the raw rules with the options applied.
+func extract(ctx context.Context, c *colibri.Colibri, rawRules colibri.RawRules, opts *options) (map[string]any, error) {
+	_, output, err := c.ExtractRawContext(ctx, applyOptions(rawRules, opts))
+	return output, err
+}
+
+// applyOptions returns a copy of the raw rules with the proxy, the User-Agent and the timeout of the options.
+func applyOptions(rawRules colibri.RawRules, opts *options) colibri.RawRules {
+	result := make(colibri.RawRules, len(rawRules)+3)
+	for key, value := range rawRules {
+		result[key] = value
+	}
+
+	if opts.proxy != nil {
+		result[colibri.KeyProxy] = opts.proxy.String()
+	}
+
+	if opts.timeout > 0 {
+		result[colibri.KeyTimeout] = opts.timeout.String()
+	}
+
+	if opts.userAgent != "" {
+		header := make(map[string]any)
+		if raw, ok := rawRules[colibri.KeyHeader].(map[string]any); ok {
+			for key, value := range raw {
+				if !strings.EqualFold(key, "User-Agent") {
+					header[key] = value
+				}
+			}
+		}
+		header["User-Agent"] = opts.userAgent
+		result[colibri.KeyHeader] = header
+	}
+	return result
+}
+
+// newEncoder returns the encoder of the outputs in the format.
+func newEncoder(format string, w io.Writer) export.Encoder {
+	switch format {
+	case formatJSONLines:
+		return export.NewJSONLines(w)
+	case formatCSV:
+		return export.NewCSV(w)
+	}
+	return &jsonEncoder{enc: newIndentEncoder(w)}
+}
+
+// jsonEncoder writes each output as an indented JSON object.
+type jsonEncoder struct {
+	enc *json.Encoder
+}
+
+func newIndentEncoder(w io.Writer) *json.Encoder {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc
+}
+
+func (enc *jsonEncoder) Encode(output map[string]any) error {
+	return enc.enc.Encode(output)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>%s %s</title></head></html>", r.Host, r.Header.Get("User-Agent"))
+	}))
+	defer ts.Close()
+
+	var (
+		dir   = t.TempDir()
+		rules = fmt.Sprintf(`{"URL": %q, "IgnoreRobotsTxt": true, "Header": {"user-agent": "test"}, "Selectors": {"title": "//title"}}`, ts.URL)
+		host  = ts.Listener.Addr().String()
+	)
+
+	files := map[string]string{
+		"rules.json":   rules,
+		"list.json":    "[" + rules + "," + rules + "]",
+		"proxy.json":   `{"URL": "http://example.test", "IgnoreRobotsTxt": true, "Selectors": {"title": "//title"}}`,
+		"invalid.json": `{"URL": 1}`,
+		"broken.json":  `{"URL":`,
+		"rules.yaml":   fmt.Sprintf("URL: %s\nIgnoreRobotsTxt: true\nHeader:\n  user-agent: test\nSelectors:\n  title: //title\n", ts.URL),
+		"list.yml":     fmt.Sprintf("- URL: %s\n  IgnoreRobotsTxt: true\n  Selectors:\n    title: //title\n", ts.URL),
+		"broken.yaml":  "URL: [",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Args       []string
+		WantStatus int
+		WantOutput string
+	}{
+		{[]string{"rules.json"}, exitOK, "{\n  \"title\": \"" + host + " test\"\n}\n"},
+		{[]string{"-format", "jsonl", "list.json"}, exitOK, `{"title":"` + host + " test\"}\n{\"title\":\"" + host + " test\"}\n"},
+		{[]string{"-format", "csv", "-user-agent", "cli", "rules.json"}, exitOK, "title\n" + host + " cli\n"},
+		{[]string{"-format", "jsonl", "-proxy", ts.URL, "-timeout", "5s", "proxy.json"}, exitOK, `{"title":"example.test colibri/0.1"}` + "\n"},
+		{[]string{"invalid.json", "rules.json"}, exitFailure, "{\n  \"title\": \"" + host + " test\"\n}\n"},
+		{[]string{"-format", "jsonl", "rules.yaml", "list.yml"}, exitOK, `{"title":"` + host + " test\"}\n{\"title\":\"" + host + " colibri/0.1\"}\n"},
+		{[]string{"broken.json"}, exitUsage, ""},
+		{[]string{"broken.yaml"}, exitUsage, ""},
+		{[]string{"missing.json"}, exitUsage, ""},
+		{[]string{"-format", "xml", "rules.json"}, exitUsage, ""},
+		{nil, exitUsage, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.Args), func(t *testing.T) {
+			var args []string
+			for _, arg := range tt.Args {
+				if ext := filepath.Ext(arg); (ext == ".json") || (ext == ".yaml") || (ext == ".yml") {
+					arg = filepath.Join(dir, arg)
+				}
+				args = append(args, arg)
+			}
+
+			var stdout, stderr bytes.Buffer
+			if got := run(context.Background(), args, &stdout, &stderr); got != tt.WantStatus {
+				t.Fatalf("got %v, want %v: %s", got, tt.WantStatus, stderr.String())
+			} else if stdout.String() != tt.WantOutput {
+				t.Fatalf("got %q, want %q", stdout.String(), tt.WantOutput)
+			}
+		})
+	}
+}
@@ -3,12 +3,15 @@
 package colibri
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,6 +19,9 @@ import (
 const DefaultUserAgent = "colibri/0.1"
 
 var (
+	// ErrColibriIsNil returned when Colibri is nil.
+	ErrColibriIsNil = errors.New("Colibri is nil")
+
 	// ErrClientIsNil returned when Client is nil.
 	ErrClientIsNil = errors.New("Client is nil")
 
@@ -24,6 +30,9 @@ var (
 
 	// ErrRulesIsNil returned when rules are nil.
 	ErrRulesIsNil = errors.New("Rules is nil")
+
+	// ErrParseTimeout returned when the parsing of the response exceeds the ParseTimeout.
+	ErrParseTimeout = errors.New("parse timeout exceeded")
 )
 
 type (
@@ -76,6 +85,34 @@ type (
 		Clear()
 	}
 
+	// RateLimiter limits the concurrent HTTP requests.
+	RateLimiter interface {
+		// Acquire waits until a request to the URL can be made.
+		// Returns the error of the context if it is cancelled before,
+		// in which case Release must not be called.
+		Acquire(ctx context.Context, u *url.URL) error
+
+		// Release warns that the request to the URL has finished.
+		Release(u *url.URL)
+
+		// Clear cleans the fields of the structure.
+		Clear()
+	}
+
+	// Cache stores the HTTP responses to avoid repeating the requests.
+	Cache interface {
+		// Get returns the fresh response stored for the rules, false if there is none.
+		// The context and the Colibri are used by the requests made with the response.
+		Get(ctx context.Context, c *Colibri, rules *Rules) (Response, bool)
+
+		// Set stores the response obtained with the rules, if it can be stored.
+		// The body of the response may be consumed, the returned response must be used instead.
+		Set(rules *Rules, resp Response) (Response, error)
+
+		// Clear cleans the fields of the structure.
+		Clear()
+	}
+
 	// RobotsTxt represents a robots.txt parser.
 	RobotsTxt interface {
 		// IsAllowed verifies that the User-Agent can access the URL.
@@ -85,7 +122,90 @@ type (
 		Clear()
 	}
 
+	// HTTPClientContext is implemented by the HTTP clients that support contexts.
+	// If the Client implements it, DoContext is used instead of Do.
+	HTTPClientContext interface {
+		// DoContext makes HTTP requests with the context.
+		DoContext(ctx context.Context, c *Colibri, rules *Rules) (Response, error)
+	}
+
+	// DelayContext is implemented by the delays whose wait can be cancelled.
+	// If the Delay implements it, WaitContext is used instead of Wait. Otherwise,
+	// the wait is abandoned when the context is cancelled, but Wait keeps running
+	// in the background until it returns and then Done is called.
+	DelayContext interface {
+		// WaitContext is like Wait, but it returns the error of the context
+		// if the context is cancelled before the wait ends.
+		// If an error is returned, Done must not be called.
+		WaitContext(ctx context.Context, u *url.URL, duration time.Duration) error
+	}
+
+	// RobotsTxtContext is implemented by the robots.txt parsers that support contexts.
+	// If the RobotsTxt implements it, IsAllowedContext is used instead of IsAllowed.
+	RobotsTxtContext interface {
+		// IsAllowedContext verifies that the User-Agent can access the URL,
+		// the context is used to get the robots.txt.
+		IsAllowedContext(ctx context.Context, c *Colibri, rules *Rules) error
+	}
+
+	// CrawlDelayer is implemented by the robots.txt parsers that get the crawl delay of the hosts.
+	// If the RobotsTxt implements it, the requests that do not ignore the robots.txt
+	// are delayed at least the crawl delay of their host, see Rules.Delay.
+	CrawlDelayer interface {
+		// CrawlDelay returns the crawl delay of the host of the URL for the User-Agent, zero if none.
+		CrawlDelay(u *url.URL, userAgent string) time.Duration
+	}
+
+	// DelayObserver is implemented by the Delays that adapt the delay of each host to its responses.
+	// If the Delay implements it, it is used with all the requests, also with those whose
+	// delay is zero, and ObserveResponse is called after each request.
+	DelayObserver interface {
+		// ObserveResponse records the response to the request to the URL, nil if the request
+		// failed, and the time taken to receive it.
+		ObserveResponse(u *url.URL, resp Response, latency time.Duration, err error)
+	}
+
+	// CacheValidator is implemented by the caches that revalidate the stale
+	// responses with conditional requests (If-None-Match, If-Modified-Since).
+	// If the Cache implements it, the header returned by Validators is added
+	// to the header of the request, the rules are not modified.
+	CacheValidator interface {
+		// Validators returns the header used to revalidate the stale response stored for the rules.
+		Validators(rules *Rules) http.Header
+	}
+
+	// Metrics collects the metrics of the requests and the parses, e.g. to monitor a crawl.
+	// The methods must be safe for concurrent use.
+	Metrics interface {
+		// ObserveRequest records a request to the URL with the status code of the response,
+		// zero if the request failed, and the time taken.
+		ObserveRequest(u *url.URL, statusCode int, duration time.Duration, err error)
+
+		// ObserveBytes records bytes of the body of the response downloaded from the URL.
+		ObserveBytes(u *url.URL, n int)
+
+		// ObserveParse records the parse of the response of the URL and the time taken.
+		ObserveParse(u *url.URL, duration time.Duration, err error)
+
+		// ObserveRobotsDenial records that the robots.txt did not allow the request to the URL.
+		ObserveRobotsDenial(u *url.URL)
+	}
+
+	// MetricsContext is implemented by the Metrics that use the context of the requests and
+	// the parses, e.g. to record the trace ID as an exemplar. If the Metrics implement it,
+	// these methods are called instead of ObserveRequest and ObserveParse.
+	MetricsContext interface {
+		// ObserveRequestContext is like ObserveRequest, the context has the trace ID of the request.
+		ObserveRequestContext(ctx context.Context, u *url.URL, statusCode int, duration time.Duration, err error)
+
+		// ObserveParseContext is like ObserveParse, the context has the trace ID of the request.
+		ObserveParseContext(ctx context.Context, u *url.URL, duration time.Duration, err error)
+	}
+
 	// Parser represents a parser of the response content.
+	// The parse runs in its own goroutine when the rules have a ParseTimeout or the
+	// context can be cancelled, and an abandoned parse continues in the background,
+	// so the Parser must be safe for concurrent use.
 	Parser interface {
 		// Match returns true if the Content-Type is compatible with the Parser.
 		Match(contentType string) bool
@@ -96,15 +216,68 @@ type (
 		// Clear cleans the fields of the structure.
 		Clear()
 	}
+
+	// StreamParser is implemented by the parsers that emit the results of the
+	// selectors as they are found. If the Parser does not implement it,
+	// ExtractStream emits the output of Parse.
+	StreamParser interface {
+		// ParseStream parses the response based on the rules and calls emit with
+		// the name of the selector and the value found.
+		ParseStream(rules *Rules, resp Response, emit func(name string, value any) error) error
+	}
+
+	// ExprCompiler is implemented by the parsers that precompile the expressions of the selectors.
+	// If the Parser implements it, Colibri.Compile precompiles the expressions of the rules.
+	ExprCompiler interface {
+		// Compile validates and precompiles the expressions of the selectors of the rules.
+		Compile(rules *Rules) error
+	}
 )
 
 // Colibri performs HTTP requests and parses
 // the content of the response based on rules.
 type Colibri struct {
-	Client    HTTPClient
-	Delay     Delay
-	RobotsTxt RobotsTxt
-	Parser    Parser
+	Client      HTTPClient
+	Delay       Delay
+	RateLimiter RateLimiter
+	RobotsTxt   RobotsTxt
+	Cache       Cache
+	Parser      Parser
+
+	// FetchFilters are called in order with the responses of Extract before they are parsed,
+	// the responses rejected by a filter are not parsed and Extract returns the SkipError,
+	// e.g. SkipMIME("image/*").
+	FetchFilters []FetchFilter
+
+	// ParserSets stores named Parsers that can be swapped at runtime, if it has
+	// an active set, it is used instead of Parser. If nil, Parser is used.
+	ParserSets *ParserSets
+
+	// OnWarning is called with the Warnings of the extractions, the expected conditions that
+	// are not added to the Errs, e.g. the URLs of the Follow selectors blocked by the robots.txt
+	// or the selectors that did not find any element. It is called by Warn, it may be called
+	// concurrently. If nil, the Warnings are discarded.
+	OnWarning func(ctx context.Context, warning *Warning)
+
+	// Logger logs the events of the requests and the parses, e.g. the requests blocked
+	// by the robots.txt or the time waited for the Delay, see LogRequest.
+	// If nil, nothing is logged.
+	Logger *slog.Logger
+
+	// Metrics collects the metrics of the requests and the parses, the cached responses
+	// are not recorded as requests. The responses are wrapped to count the bytes of the body.
+	// If nil, the metrics are not collected.
+	Metrics Metrics
+
+	// TraceField is the name of the value added to the outputs of Extract with the trace ID
+	// of the request, it is emitted first by ExtractStream. If empty, it is not added.
+	TraceField string
+
+	// DomainRules stores the default rules by host glob, they are merged into the rules
+	// before each request, see DomainRules.Merge. If nil, no defaults are merged.
+	DomainRules *DomainRules
+
+	hosts hostControl
 }
 
 // New returns a new empty Colibri structure.
@@ -114,6 +287,21 @@ func New() *Colibri {
 
 // Do performs an HTTP request according to the rules.
 func (c *Colibri) Do(rules *Rules) (resp Response, err error) {
+	return c.DoContext(context.Background(), rules)
+}
+
+// DoContext performs an HTTP request according to the rules.
+// The context is propagated to the Client, the Delay and the RobotsTxt,
+// see HTTPClientContext, DelayContext and RobotsTxtContext.
+// The defaults of the DomainRules that match the host of the URL are merged into the rules.
+// The delay is at least the crawl delay of the host if the RobotsTxt implements CrawlDelayer.
+// The references to the Vars of the URL and the header are replaced by their values.
+// If the Cache has a fresh response for the rules, it is returned without making the request.
+// The requests to the paused hosts wait until they are resumed and the requests to the
+// blocked hosts return ErrHostBlocked, see PauseHost and BlockHost.
+// The events of the request are logged with the Logger and recorded in the Metrics
+// with the trace ID of the context, a new one if it does not have one, see WithTraceID.
+func (c *Colibri) DoContext(ctx context.Context, rules *Rules) (resp Response, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("%v", r)
@@ -128,31 +316,152 @@ func (c *Colibri) Do(rules *Rules) (resp Response, err error) {
 		return nil, ErrRulesIsNil
 	}
 
+	if rules.released {
+		return nil, ErrRulesReleased
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx = c.withWarnings(withTraceID(ctx))
+
 	if rules.Header == nil {
 		rules.Header = http.Header{}
 	}
 
+	if c.DomainRules != nil {
+		rules = c.DomainRules.Merge(rules)
+	}
+
 	if strings.TrimSpace(rules.Header.Get("User-Agent")) == "" {
 		rules.Header.Set("User-Agent", DefaultUserAgent)
 	}
 
-	if (c.RobotsTxt != nil) && !rules.IgnoreRobotsTxt {
-		err := c.RobotsTxt.IsAllowed(c, rules)
+	if rules.Vars != nil {
+		expanded, err := rules.Vars.expandRules(rules)
 		if err != nil {
 			return nil, err
 		}
+		rules = expanded
+	}
+
+	if err := c.waitHost(ctx, rules.URL); err != nil {
+		return nil, err
 	}
 
-	if (c.Delay != nil) && (rules.Delay > 0) {
-		c.Delay.Wait(rules.URL, rules.Delay)
+	if (c.RobotsTxt != nil) && !rules.IgnoreRobotsTxt {
+		var (
+			start = time.Now()
+			err   error
+		)
+		if err = RobotsIsAllowed(ctx, c.RobotsTxt, c, rules); err != nil {
+			c.log(ctx, slog.LevelInfo, LogRobotsTxtBlocked, urlAttr(rules.URL), durationAttr(start), errorAttr(err))
+			if c.Metrics != nil {
+				c.Metrics.ObserveRobotsDenial(rules.URL)
+			}
+			err = &RobotsError{URL: rules.URL, Err: err}
+			return nil, &Warning{Kind: WarningRobotsTxt, URL: rules.URL, Err: err, TraceID: TraceID(ctx)}
+		}
+		c.log(ctx, slog.LevelDebug, LogRobotsTxtAllowed, urlAttr(rules.URL), durationAttr(start))
+	}
+
+	if c.Cache != nil {
+		if resp, ok := c.Cache.Get(ctx, c, rules); ok {
+			c.log(ctx, slog.LevelDebug, LogCacheHit, urlAttr(rules.URL))
+			return resp, nil
+		}
+	}
+
+	delay := rules.Delay
+	if (c.RobotsTxt != nil) && !rules.IgnoreRobotsTxt {
+		delay = max(delay, RobotsCrawlDelay(c.RobotsTxt, rules.URL, rules.Header.Get("User-Agent")))
+	}
+
+	observer, observe := c.Delay.(DelayObserver)
+	if (c.Delay != nil) && ((delay > 0) || observe) {
+		start := time.Now()
+		if err := DelayWait(ctx, c.Delay, rules.URL, delay); err != nil {
+			return nil, err
+		}
 		defer c.Delay.Done(rules.URL)
+
+		c.log(ctx, slog.LevelDebug, LogDelay, urlAttr(rules.URL), slog.Duration("delay", delay), durationAttr(start))
+
+		// the host may have been paused during the delay
+		if err := c.waitHost(ctx, rules.URL); err != nil {
+			return nil, err
+		}
 	}
 
-	resp, err = c.Client.Do(c, rules)
+	if c.RateLimiter != nil {
+		start := time.Now()
+		if err := c.RateLimiter.Acquire(ctx, rules.URL); err != nil {
+			return nil, err
+		}
+		defer c.RateLimiter.Release(rules.URL)
+
+		c.log(ctx, slog.LevelDebug, LogRateLimit, urlAttr(rules.URL), durationAttr(start))
+	}
+
+	reqRules := rules
+	if validator, ok := c.Cache.(CacheValidator); ok {
+		if header := validator.Validators(rules); len(header) > 0 {
+			conditional := *rules
+			conditional.Header = rules.Header.Clone()
+			for key, values := range header {
+				conditional.Header[key] = values
+			}
+			reqRules = &conditional
+		}
+	}
+
+	start := time.Now()
+	c.log(ctx, slog.LevelDebug, LogRequest, slog.String("method", rules.Method), urlAttr(rules.URL))
+
+	resp, err = ClientDo(ctx, c.Client, c, reqRules)
+
+	if (err != nil) && !typedError(err) {
+		err = &NetworkError{URL: rules.URL, Err: err}
+	}
+
+	if err != nil {
+		c.log(ctx, slog.LevelInfo, LogRequestFailed, urlAttr(rules.URL), durationAttr(start), errorAttr(err))
+	} else if resp != nil {
+		c.log(ctx, slog.LevelDebug, LogResponse, urlAttr(resp.URL()), slog.Int("status", resp.StatusCode()), durationAttr(start))
+	}
+
+	if c.Metrics != nil {
+		statusCode := 0
+		if (err == nil) && (resp != nil) {
+			statusCode = resp.StatusCode()
+		}
+
+		if metrics, ok := c.Metrics.(MetricsContext); ok {
+			metrics.ObserveRequestContext(ctx, rules.URL, statusCode, time.Since(start), err)
+		} else {
+			c.Metrics.ObserveRequest(rules.URL, statusCode, time.Since(start), err)
+		}
+	}
 
 	if (c.Delay != nil) && (resp != nil) {
 		c.Delay.Stamp(resp.URL())
 	}
+
+	if observe {
+		observer.ObserveResponse(rules.URL, resp, time.Since(start), err)
+	}
+
+	if (c.Cache != nil) && (err == nil) {
+		resp, err = c.Cache.Set(rules, resp)
+	}
+
+	if (c.Metrics != nil) && (resp != nil) && (err == nil) && (resp.Body() != nil) {
+		resp = newMeteredResponse(resp, c.Metrics)
+	}
 	return resp, err
 }
 
@@ -160,27 +469,237 @@ func (c *Colibri) Do(rules *Rules) (resp Response, err error) {
 // It returns the response of the request, the data extracted with the selectors
 // and an error (if any).
 func (c *Colibri) Extract(rules *Rules) (resp Response, output map[string]any, err error) {
+	return c.ExtractContext(context.Background(), rules)
+}
+
+// ExtractContext is like Extract, the context is propagated to DoContext.
+// The responses of the Client should propagate the context to the
+// requests of the Follow selectors, so they have the same trace ID.
+// The Errs of the parse have the trace ID of the request, see TraceIDOf.
+func (c *Colibri) ExtractContext(ctx context.Context, rules *Rules) (resp Response, output map[string]any, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("%v", r)
 		}
 	}()
 
-	if c.Parser == nil {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, parser := c.selectParser(withTraceID(ctx))
+	if parser == nil {
 		return nil, nil, ErrParserIsNil
 	}
 
-	resp, err = c.Do(rules)
+	ctx = withRequestBudget(ctx, rules)
+	if err := spendRequest(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	resp, err = c.DoContext(ctx, rules)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if len(rules.Selectors) > 0 {
-		output, err = c.Parser.Parse(rules, resp)
+	if !rules.hasSelectors() {
+		return resp, nil, nil
+	}
+
+	if err := c.filterFetch(rules, resp); err != nil {
+		c.log(ctx, slog.LevelInfo, LogSkipped, urlAttr(resp.URL()), errorAttr(err))
+		return resp, nil, err
+	}
+
+	output, err = c.parse(ctx, parser, rules, resp)
+	if (c.TraceField != "") && (output != nil) {
+		output[c.TraceField] = TraceID(ctx)
 	}
 	return resp, output, err
 }
 
+// ExtractStream is like Extract, but the results of the selectors are passed to emit
+// as they are found instead of being returned in a map. If the Parser implements
+// StreamParser the items of the All selectors are emitted one by one,
+// otherwise each selector of the output of Parse is emitted in order.
+// If emit returns an error, the parse ends and the error is returned.
+// emit is not called after ExtractStream returns.
+func (c *Colibri) ExtractStream(rules *Rules, emit func(name string, value any) error) (Response, error) {
+	return c.ExtractStreamContext(context.Background(), rules, emit)
+}
+
+// ExtractStreamContext is like ExtractStream, the context is propagated to DoContext.
+func (c *Colibri) ExtractStreamContext(ctx context.Context, rules *Rules, emit func(name string, value any) error) (resp Response, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, parser := c.selectParser(withTraceID(ctx))
+	if parser == nil {
+		return nil, ErrParserIsNil
+	}
+
+	ctx = withRequestBudget(ctx, rules)
+	if err := spendRequest(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err = c.DoContext(ctx, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rules.hasSelectors() {
+		return resp, nil
+	}
+
+	if err := c.filterFetch(rules, resp); err != nil {
+		c.log(ctx, slog.LevelInfo, LogSkipped, urlAttr(resp.URL()), errorAttr(err))
+		return resp, err
+	}
+
+	if c.TraceField != "" {
+		if err := emit(c.TraceField, TraceID(ctx)); err != nil {
+			return resp, err
+		}
+	}
+
+	err = c.parseStream(ctx, parser, rules, resp, emit)
+	return resp, err
+}
+
+// parseStream parses the response until the ParseTimeout is exceeded or the context is cancelled,
+// emit is not called after parseStream returns.
+func (c *Colibri) parseStream(ctx context.Context, parser Parser, rules *Rules, resp Response, emit func(name string, value any) error) error {
+	var (
+		mu      sync.Mutex
+		stopped bool
+	)
+	guarded := func(name string, value any) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if stopped {
+			return ErrParseTimeout
+		}
+		return emit(name, value)
+	}
+
+	start := time.Now()
+	_, err := runParse(ctx, rules, func() error {
+		if streamParser, ok := parser.(StreamParser); ok {
+			return streamParser.ParseStream(rules, resp, guarded)
+		}
+
+		output, errs := parser.Parse(rules, resp)
+		for _, name := range rules.selectorNames() {
+			value, ok := output[name]
+			if !ok {
+				continue
+			}
+
+			if err := guarded(name, value); err != nil {
+				return err
+			}
+		}
+		return errs
+	})
+
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+
+	setTraceID(ctx, err)
+	wrapParseErrors(resp, err)
+	c.observeParse(ctx, resp, start, err)
+	return err
+}
+
+// parse parses the response until the ParseTimeout is exceeded or the context is cancelled.
+func (c *Colibri) parse(ctx context.Context, parser Parser, rules *Rules, resp Response) (map[string]any, error) {
+	var (
+		output map[string]any
+		start  = time.Now()
+	)
+	finished, err := runParse(ctx, rules, func() error {
+		var err error
+		output, err = parser.Parse(rules, resp)
+		return err
+	})
+	setTraceID(ctx, err)
+	wrapParseErrors(resp, err)
+	c.observeParse(ctx, resp, start, err)
+
+	if !finished {
+		return nil, err
+	}
+	return output, err
+}
+
+// observeParse logs the parse of the response and records it in the Metrics,
+// the parses that failed are logged with info level.
+func (c *Colibri) observeParse(ctx context.Context, resp Response, start time.Time, err error) {
+	if metrics, ok := c.Metrics.(MetricsContext); ok {
+		metrics.ObserveParseContext(ctx, resp.URL(), time.Since(start), err)
+	} else if c.Metrics != nil {
+		c.Metrics.ObserveParse(resp.URL(), time.Since(start), err)
+	}
+
+	attrs := []slog.Attr{urlAttr(resp.URL()), slog.String("content_type", resp.Header().Get("Content-Type")), durationAttr(start)}
+	if err != nil {
+		c.log(ctx, slog.LevelInfo, LogParse, append(attrs, errorAttr(err))...)
+		return
+	}
+	c.log(ctx, slog.LevelDebug, LogParse, attrs...)
+}
+
+// runParse calls fn until the ParseTimeout is exceeded or the context is cancelled.
+// The parser can not be interrupted, when the parse is abandoned it continues
+// in the background, finished is false and its result must be discarded.
+// The abandoned parse still reads the rules, so they must not be released or modified.
+func runParse(ctx context.Context, rules *Rules, fn func() error) (finished bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	if (rules.ParseTimeout <= 0) && (ctx.Done() == nil) {
+		return true, fn()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("%v", r)
+			}
+		}()
+
+		done <- fn()
+	}()
+
+	var timeout <-chan time.Time
+	if rules.ParseTimeout > 0 {
+		timer := time.NewTimer(rules.ParseTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-done:
+		return true, err
+	case <-timeout:
+		return false, ErrParseTimeout
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
 // Clear cleans the fields of the structure.
 func (c *Colibri) Clear() {
 	if c.Client != nil {
@@ -191,11 +710,23 @@ func (c *Colibri) Clear() {
 		c.Delay.Clear()
 	}
 
+	if c.RateLimiter != nil {
+		c.RateLimiter.Clear()
+	}
+
 	if c.RobotsTxt != nil {
 		c.RobotsTxt.Clear()
 	}
 
+	if c.Cache != nil {
+		c.Cache.Clear()
+	}
+
 	if c.Parser != nil {
 		c.Parser.Clear()
 	}
+
+	if c.ParserSets != nil {
+		c.ParserSets.Clear()
+	}
 }
@@ -1,12 +1,21 @@
 package colibri
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -153,6 +162,63 @@ func TestColibriDo(t *testing.T) {
 	})
 }
 
+func TestColibriDoContext(t *testing.T) {
+	var (
+		c      = New()
+		client = &testClient{}
+	)
+	c.Client = client
+	c.Parser = &testParser{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := c.DoContext(ctx, &Rules{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	} else if _, _, err := c.ExtractContext(ctx, &Rules{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestColibriParseTimeout(t *testing.T) {
+	newRules := func(timeout, sleep time.Duration) *Rules {
+		return &Rules{
+			ParseTimeout: timeout,
+			Selectors:    []*Selector{testSelector},
+			Fields:       map[string]any{"parserSleep": sleep},
+		}
+	}
+
+	c := New()
+	c.Client = &testClient{}
+
+	parser := &testParser{done: make(chan struct{})}
+	c.Parser = parser
+
+	start := time.Now()
+	if _, _, err := c.Extract(newRules(10*time.Millisecond, 500*time.Millisecond)); !errors.Is(err, ErrParseTimeout) {
+		t.Fatalf("got %v, want %v", err, ErrParseTimeout)
+	} else if time.Since(start) > 250*time.Millisecond {
+		t.Fatal("parse not abandoned")
+	}
+	<-parser.done
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	parser = &testParser{done: make(chan struct{})}
+	c.Parser = parser
+	if _, _, err := c.ExtractContext(ctx, newRules(0, 200*time.Millisecond)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	<-parser.done
+
+	c.Parser = &testParser{}
+	if _, _, err := c.Extract(newRules(time.Second, time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestColibriExtract(t *testing.T) {
 	var (
 		c      = New()
@@ -238,6 +304,253 @@ func TestColibriExtract(t *testing.T) {
 	}
 }
 
+func TestColibriExtractStream(t *testing.T) {
+	c := New()
+	c.Client = &testClient{}
+	c.Parser = &testParser{}
+
+	var (
+		testErr = errors.New("Test Error")
+		rules   = &Rules{
+			Selectors: []*Selector{{Name: "b"}, {Name: "a"}, {Name: "c"}},
+			Fields: map[string]any{
+				"output": map[string]any{"a": 1, "b": 2},
+			},
+		}
+	)
+
+	var names []string
+	_, err := c.ExtractStream(rules, func(name string, value any) error {
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if got := strings.Join(names, ","); got != "b,a" {
+		t.Fatalf("got %v, want %v", got, "b,a")
+	}
+
+	names = nil
+	_, err = c.ExtractStream(rules, func(name string, value any) error {
+		names = append(names, name)
+		return testErr
+	})
+	if !errors.Is(err, testErr) {
+		t.Fatalf("got %v, want %v", err, testErr)
+	} else if len(names) != 1 {
+		t.Fatalf("got %v, want %v", len(names), 1)
+	}
+
+	parser := &testParser{done: make(chan struct{})}
+	c.Parser = parser
+
+	rules = &Rules{
+		ParseTimeout: 10 * time.Millisecond,
+		Selectors:    []*Selector{{Name: "a"}},
+		Fields:       map[string]any{"parserSleep": 200 * time.Millisecond},
+	}
+	if _, err := c.ExtractStream(rules, func(string, any) error { return nil }); !errors.Is(err, ErrParseTimeout) {
+		t.Fatalf("got %v, want %v", err, ErrParseTimeout)
+	}
+	<-parser.done
+
+	c.Parser = nil
+	if _, err := c.ExtractStream(rules, func(string, any) error { return nil }); !errors.Is(err, ErrParserIsNil) {
+		t.Fatalf("got %v, want %v", err, ErrParserIsNil)
+	}
+}
+
+func TestExtractAs(t *testing.T) {
+	type Link struct {
+		Text string `colibri:"text"`
+		Href string `colibri:"href"`
+	}
+
+	type Base struct {
+		ID uint `colibri:"id"`
+	}
+
+	type Page struct {
+		Base
+		Title   string            `colibri:"title"`
+		Price   float64           `colibri:"price"`
+		Stock   *int              `colibri:"stock"`
+		Active  bool              `colibri:"active"`
+		TTL     time.Duration     `colibri:"ttl"`
+		Tags    []string          `colibri:"tags"`
+		Links   []Link            `colibri:"links"`
+		Follow  map[string]Link   `colibri:"follow"`
+		Extra   map[string]any    `colibri:"extra"`
+		Ignored string            `colibri:"-"`
+		Raw     any               `colibri:"raw"`
+		Header  map[string]string `colibri:"header"`
+	}
+
+	var (
+		c     = New()
+		stock = 7
+	)
+	c.Client = &testClient{}
+	c.Parser = &testParser{}
+
+	output := map[string]any{
+		"id":     "42",
+		"title":  "Title",
+		"price":  " 19.99 ",
+		"stock":  float64(7),
+		"active": "true",
+		"ttl":    "1m",
+		"tags":   []any{"a", "b"},
+		"links": []any{
+			map[string]any{"text": "Go", "href": "https://go.dev"},
+		},
+		"follow": map[string]any{
+			"https://go.dev": map[string]any{"text": "Go"},
+		},
+		"extra":   map[string]any{"k": "v"},
+		"Ignored": "ignored",
+		"-":       "ignored",
+		"raw":     1,
+		"header":  map[string]any{"Accept": "text/html"},
+	}
+
+	rules := &Rules{
+		Selectors: []*Selector{testSelector},
+		Fields:    map[string]any{"output": output},
+	}
+
+	got, err := ExtractAs[Page](c, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Page{
+		Base:   Base{ID: 42},
+		Title:  "Title",
+		Price:  19.99,
+		Stock:  &stock,
+		Active: true,
+		TTL:    time.Minute,
+		Tags:   []string{"a", "b"},
+		Links:  []Link{{Text: "Go", Href: "https://go.dev"}},
+		Follow: map[string]Link{"https://go.dev": {Text: "Go"}},
+		Extra:  map[string]any{"k": "v"},
+		Raw:    1,
+		Header: map[string]string{"Accept": "text/html"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		output["price"] = "free"
+		output["links"] = "https://go.dev"
+
+		_, err := ExtractAs[Page](c, rules)
+
+		var errs *Errs
+		if !errors.As(err, &errs) {
+			t.Fatalf("got %v, want *Errs", err)
+		}
+
+		if _, ok := errs.Get("price"); !ok {
+			t.Fatal("price error not found")
+		} else if _, ok := errs.Get("links"); !ok {
+			t.Fatal("links error not found")
+		}
+	})
+
+	t.Run("InvalidTarget", func(t *testing.T) {
+		if err := Decode(output, Page{}); !errors.Is(err, ErrInvalidTarget) {
+			t.Fatalf("got %v, want %v", err, ErrInvalidTarget)
+		}
+	})
+}
+
+func TestUnmarshal(t *testing.T) {
+	type Product struct {
+		Name  string  `colibri:"name"`
+		Price float64 `colibri:"price"`
+	}
+
+	type Catalog struct {
+		Title    string    `colibri:"title"`
+		Products []Product `colibri:"products"`
+		Pages    []Product `colibri:"pages"`
+		Featured []Product `colibri:"featured"`
+	}
+
+	output := map[string]any{
+		"title": "Catalog",
+		"products": []any{
+			map[string]any{"name": "A", "price": "1.5"},
+			map[string]any{"name": "B", "price": "2"},
+		},
+		"pages": map[string]any{
+			"https://example.com/p/2": map[string]any{"name": "D", "price": "4"},
+			"https://example.com/p/1": map[string]any{"name": "C", "price": "3"},
+		},
+		"featured": map[string]any{"name": "E", "price": "5"},
+	}
+
+	var got Catalog
+	if err := Unmarshal(output, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Catalog{
+		Title:    "Catalog",
+		Products: []Product{{"A", 1.5}, {"B", 2}},
+		Pages:    []Product{{"C", 3}, {"D", 4}},
+		Featured: []Product{{"E", 5}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeUnmarshalers(t *testing.T) {
+	type Target struct {
+		IP        net.IP      `colibri:"ip"`
+		Published time.Time   `colibri:"published"`
+		Updated   time.Time   `colibri:"updated" layout:"02/01/2006"`
+		Unix      time.Time   `colibri:"unix"`
+		Money     testMoney   `colibri:"money"`
+		Prices    []testMoney `colibri:"prices"`
+	}
+
+	output := map[string]any{
+		"ip":        "192.168.0.1",
+		"published": "2023-08-05T10:30:00Z",
+		"updated":   " 05/08/2023 ",
+		"unix":      float64(1691231400),
+		"money":     "19.99 USD",
+		"prices":    []any{float64(5)},
+	}
+
+	var got Target
+	if err := Decode(output, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Target{
+		IP:        net.ParseIP("192.168.0.1"),
+		Published: time.Date(2023, 8, 5, 10, 30, 0, 0, time.UTC),
+		Updated:   time.Date(2023, 8, 5, 0, 0, 0, 0, time.UTC),
+		Unix:      time.Date(2023, 8, 5, 10, 30, 0, 0, time.UTC),
+		Money:     testMoney{Amount: "19.99", Currency: "USD"},
+		Prices:    []testMoney{{Amount: "5"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	output["updated"] = "2023-08-05"
+	if err := Decode(output, &got); err == nil {
+		t.Fatal("expected ErrTimeLayout")
+	}
+}
+
 func TestNewRules(t *testing.T) {
 	tests := []struct {
 		Name      string
@@ -292,7 +605,7 @@ func TestNewRules(t *testing.T) {
 
 			if (err != nil) && (tt.ErrMap != nil) {
 				wantErr, _ := json.Marshal(tt.ErrMap)
-				jsonErrs, _ := json.Marshal(err)
+				jsonErrs := []byte(err.Error()) // the messages without the codes
 
 				if !reflect.DeepEqual(wantErr, jsonErrs) {
 					t.Fatal(err)
@@ -345,6 +658,142 @@ func TestNewRules(t *testing.T) {
 	})
 }
 
+func TestMigrate(t *testing.T) {
+	migrator := &Migrator{
+		Version: 3,
+		Migrations: map[int]MigrationFunc{
+			1: RenameKey("Wait", KeyDelay),
+			2: SetDefault(KeyTimeout, "10s"),
+		},
+	}
+
+	raw := RawRules{
+		"URL":  "https://example.com",
+		"Wait": "1s",
+		"Selectors": map[string]any{
+			"title": map[string]any{"Expr": "//title", "Wait": "2s"},
+		},
+	}
+
+	got, err := migrator.Migrate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := RawRules{
+		"URL":     "https://example.com",
+		"Delay":   "1s",
+		"Timeout": "10s",
+		"Version": 3,
+		"Selectors": map[string]any{
+			"title": map[string]any{"Expr": "//title", "Delay": "2s"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// the original raw rules are not modified
+	if _, ok := raw["Wait"]; !ok {
+		t.Fatal("raw rules modified")
+	}
+
+	tests := []struct {
+		Version any
+		Want    error
+	}{
+		{2, nil},
+		{"3", nil},
+		{4, ErrUnsupportedVersion},
+		{0, ErrUnsupportedVersion},
+		{"v1", ErrUnsupportedVersion},
+	}
+
+	for _, tt := range tests {
+		if _, err := migrator.Migrate(RawRules{KeyVersion: tt.Version}); !errors.Is(err, tt.Want) {
+			t.Fatalf("got %v, want %v", err, tt.Want)
+		}
+	}
+
+	delete(migrator.Migrations, 2)
+	if _, err := migrator.Migrate(raw); !errors.Is(err, ErrMigrationNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrMigrationNotFound)
+	}
+
+	t.Run("NewRules", func(t *testing.T) {
+		rules, err := NewRules(RawRules{KeyVersion: RulesVersion, "URL": "https://example.com"})
+		if err != nil {
+			t.Fatal(err)
+		} else if _, ok := rules.Fields[KeyVersion]; ok {
+			t.Fatal("Version stored in Fields")
+		}
+
+		_, err = NewRules(RawRules{KeyVersion: RulesVersion + 1})
+		if err, ok := err.(*Errs).Get(KeyVersion); !ok || !errors.Is(err, ErrUnsupportedVersion) {
+			t.Fatalf("got %v, want %v", err, ErrUnsupportedVersion)
+		}
+	})
+}
+
+func TestRegisterField(t *testing.T) {
+	errNegative := errors.New("must not be negative")
+
+	err := RegisterField("retries", FieldSchema{
+		Kind: reflect.Int,
+		Validate: func(value any) error {
+			if value.(int) < 0 {
+				return errNegative
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterField("retries")
+
+	if err := RegisterField("required", FieldSchema{Kind: reflect.Bool}); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterField("required")
+
+	if err := RegisterField(KeyURL, FieldSchema{}); !errors.Is(err, ErrReservedField) {
+		t.Fatalf("got %v, want %v", err, ErrReservedField)
+	} else if err := RegisterField("x", FieldSchema{Kind: reflect.Chan}); !errors.Is(err, ErrUnsupportedKind) {
+		t.Fatalf("got %v, want %v", err, ErrUnsupportedKind)
+	}
+
+	rules, err := NewRules(RawRules{
+		"retries": "3",
+		"other":   "raw",
+		"Selectors": map[string]any{
+			"title": map[string]any{"Expr": "//title", "required": "true"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rules.Fields["retries"] != 3 {
+		t.Fatalf("got %v, want %v", rules.Fields["retries"], 3)
+	} else if rules.Fields["other"] != "raw" {
+		t.Fatalf("got %v, want %v", rules.Fields["other"], "raw")
+	} else if rules.Selectors[0].Fields["required"] != true {
+		t.Fatalf("got %v, want %v", rules.Selectors[0].Fields["required"], true)
+	}
+
+	_, err = NewRules(RawRules{"retries": -1})
+	if err == nil {
+		t.Fatal("expected error")
+	} else if got, _ := err.(*Errs).Get("retries"); got != errNegative {
+		t.Fatalf("got %v, want %v", got, errNegative)
+	}
+
+	if _, err := NewRules(RawRules{"retries": "three"}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
 func TestRulesUnmarshalJSON(t *testing.T) {
 	tests := []struct {
 		Name      string
@@ -353,6 +802,7 @@ func TestRulesUnmarshalJSON(t *testing.T) {
 		AnErr     bool
 	}{
 		{"OK", testRawRules, testRules, false},
+		{"MarshalJSON", testRules, testRules, false},
 
 		{
 			"Fail",
@@ -385,84 +835,1327 @@ func TestRulesUnmarshalJSON(t *testing.T) {
 			if (err != nil && !tt.AnErr) || (err == nil && tt.AnErr) {
 				t.Fatal(err)
 
-			} else if (err == nil) && !tt.AnErr {
-				if !reflect.DeepEqual(&newRules, tt.WantRules) {
-					t.Fatalf("not equal")
-				}
-			}
-		})
+			} else if (err == nil) && !tt.AnErr {
+				if !reflect.DeepEqual(&newRules, tt.WantRules) {
+					t.Fatalf("not equal")
+				}
+			}
+		})
+	}
+}
+
+func TestSelectorRules(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		selector := testSelector.Clone()
+		selector.Fields = nil
+
+		wantRules := testRules.Clone()
+		wantRules.Method = ""
+		wantRules.URL = nil
+		wantRules.MaxPages = 0 // not inherited
+		wantRules.Fields = make(map[string]any)
+		wantRules.Selectors = selector.Selectors
+
+		rules := selector.Rules(testRules)
+		if !reflect.DeepEqual(rules, wantRules) {
+			t.Fatal("not equal")
+		}
+	})
+
+	t.Run("", func(t *testing.T) {
+		selector := testSelector.Clone()
+		selector.Fields["Method"] = "POST"
+		selector.Fields["Proxy"] = mustNewURL("")
+		selector.Fields["Header"] = http.Header{"Accept": {"application/xml"}}
+		selector.Fields["Timeout"] = 10 * time.Second
+		selector.Fields["UseCookies"] = true
+		selector.Fields["IgnoreRobotsTxt"] = false
+		selector.Fields["Delay"] = 5 * time.Second
+		selector.Fields["MaxBodySize"] = 2048
+		selector.Fields["MaxPages"] = "2"
+
+		wantRules := &Rules{
+			Method:            "POST",
+			Proxy:             mustNewURL(""),
+			Header:            http.Header{"Accept": {"application/xml"}},
+			Timeout:           10 * time.Second,
+			UseCookies:        true,
+			Delay:             5 * time.Second,
+			MaxBodySize:       2048,
+			MaxPages:          2,
+			MaxDepth:          testRules.MaxDepth,
+			MaxRequests:       testRules.MaxRequests,
+			FollowConcurrency: testRules.FollowConcurrency,
+			Render:            testRules.Render,
+			RenderWait:        testRules.RenderWait,
+			Allow:             testRules.Allow,
+			TLS:               testRules.TLS,
+			Selectors:         CloneSelectors(selector.Selectors),
+			Vars:              testRules.Vars,
+			Fields:            make(map[string]any),
+		}
+
+		rules := selector.Rules(testRules)
+		if !reflect.DeepEqual(rules, wantRules) {
+			t.Fatal("not equal")
+		}
+	})
+
+	t.Run("", func(t *testing.T) {
+		selector := testSelector.Clone()
+		selector.Fields["Method"] = "POST"
+		selector.Fields["UseCookies"] = true
+		selector.Fields["Delay"] = 5 * time.Second
+
+		wantRules := &Rules{
+			Method:            "POST",
+			Proxy:             testRules.Proxy,
+			Header:            testRules.Header,
+			Timeout:           testRules.Timeout,
+			UseCookies:        true,
+			IgnoreRobotsTxt:   testRules.IgnoreRobotsTxt,
+			Delay:             5 * time.Second,
+			MaxBodySize:       testRules.MaxBodySize,
+			MaxDepth:          testRules.MaxDepth,
+			MaxRequests:       testRules.MaxRequests,
+			FollowConcurrency: testRules.FollowConcurrency,
+			Render:            testRules.Render,
+			RenderWait:        testRules.RenderWait,
+			Allow:             testRules.Allow,
+			TLS:               testRules.TLS,
+			Selectors:         CloneSelectors(selector.Selectors),
+			Vars:              testRules.Vars,
+			Fields:            make(map[string]any),
+		}
+
+		rules := selector.Rules(testRules)
+		if !reflect.DeepEqual(rules, wantRules) {
+			t.Fatal("not equal")
+		}
+	})
+
+	t.Run("Session", func(t *testing.T) {
+		src := testRules.Clone()
+		src.Session = "seed"
+
+		selector := testSelector.Clone()
+		selector.Fields["Proxy"] = mustNewURL("http://other-proxy.com:8080")
+		selector.Fields["UseCookies"] = false
+
+		rules := selector.Rules(src)
+		if rules.Session != src.Session {
+			t.Fatal("session not propagated")
+		} else if rules.Proxy.String() != src.Proxy.String() {
+			t.Fatal("session proxy not used")
+		} else if !rules.UseCookies {
+			t.Fatal("session cookies not used")
+		}
+	})
+
+	t.Run("ConvFunc", func(t *testing.T) {
+		selector := testSelector.Clone()
+		selector.Fields["Proxy"] = "http://proxy.example.com:8080"
+		selector.Fields["Header"] = map[string]any{"Accept": "application/xml"}
+		selector.Fields["Timeout"] = "5s"
+		selector.Fields["UseCookies"] = "true"
+		selector.Fields["Delay"] = 1500
+		selector.Fields["TLS"] = map[string]any{"MinVersion": "TLS1.3"}
+
+		rules, err := selector.RulesWithConvFunc(testRules, DefaultConvFunc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rules.Proxy.String() != "http://proxy.example.com:8080" {
+			t.Fatalf("got %v, want %v", rules.Proxy, "http://proxy.example.com:8080")
+		} else if rules.Header.Get("Accept") != "application/xml" {
+			t.Fatalf("got %v, want %v", rules.Header.Get("Accept"), "application/xml")
+		} else if rules.Timeout != 5*time.Second {
+			t.Fatalf("got %v, want %v", rules.Timeout, 5*time.Second)
+		} else if !rules.UseCookies {
+			t.Fatal("UseCookies not converted")
+		} else if rules.Delay != 1500*time.Millisecond {
+			t.Fatalf("got %v, want %v", rules.Delay, 1500*time.Millisecond)
+		} else if (rules.TLS == nil) || (rules.TLS.MinVersion != tls.VersionTLS13) {
+			t.Fatalf("got %v, want MinVersion %v", rules.TLS, tls.VersionTLS13)
+		}
+
+		selector.Fields["Timeout"] = "five seconds"
+		selector.Fields["Method"] = 21
+
+		rules, err = selector.RulesWithConvFunc(testRules, DefaultConvFunc)
+		if err == nil {
+			t.Fatal("nil error")
+		} else if rules.Timeout != testRules.Timeout {
+			t.Fatalf("got %v, want %v", rules.Timeout, testRules.Timeout)
+		}
+
+		for _, key := range []string{KeyTimeout, KeyMethod} {
+			if _, ok := err.(*Errs).Get(key); !ok {
+				t.Fatalf("%v error not found", key)
+			}
+		}
+	})
+}
+
+func TestSelectorBuilder(t *testing.T) {
+	got := NewSelector("links").
+		XPath("//a/@href").
+		All().
+		Follow().
+		Field(KeyMethod, "GET").
+		SkipIf("private", true).
+		Allow(regexp.MustCompile(`^https://example\.com/`)).
+		Deny(regexp.MustCompile(`\.pdf$`)).
+		Child(
+			NewSelector("title").CSS("title").Required(),
+			NewSelector("id").Regular(`id=(\d+)`),
+			NewSelector("author").JSONPath("$.author").Var("author"),
+		).
+		Pipe("trim").
+		Pipe("replace", "http:", "https:").
+		Build()
+
+	want := &Selector{
+		Name:   "links",
+		Expr:   "//a/@href",
+		Type:   "xpath",
+		All:    true,
+		Follow: true,
+		Allow:  []*regexp.Regexp{regexp.MustCompile(`^https://example\.com/`)},
+		Deny:   []*regexp.Regexp{regexp.MustCompile(`\.pdf$`)},
+		Selectors: []*Selector{
+			{Name: "title", Expr: "title", Type: "css", Required: true, Fields: map[string]any{}},
+			{Name: "id", Expr: `id=(\d+)`, Type: "regular", Fields: map[string]any{}},
+			{Name: "author", Expr: "$.author", Type: "jsonpath", Fields: map[string]any{KeyVar: "author"}},
+		},
+		Pipes:  []Pipe{{Name: "trim"}, {Name: "replace", Args: []string{"http:", "https:"}}},
+		Fields: map[string]any{KeyMethod: "GET", KeySkipIf: map[string]any{"private": true}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestRulesBuilder(t *testing.T) {
+	rules, err := NewRulesBuilder().
+		WithMethod("post").
+		WithURL("https://example.com").
+		WithProxy("http://proxy.example.com:8080").
+		WithHeader("User-Agent", "Colibri").
+		WithTimeout(5*time.Second).
+		WithDelay(time.Second).
+		WithRetries(3, time.Second, 429, 503).
+		WithMaxWait(time.Minute).
+		WithDisableCompression(true).
+		WithMaxBodySize(1024).
+		WithMaxPages(5).
+		WithMaxDepth(3).
+		WithMaxRequests(100).
+		WithFollowConcurrency(8).
+		WithVars(map[string]string{"token": ""}).
+		WithRender(2*time.Second).
+		WithUseCookies(true).
+		WithSession("example").
+		WithAllow(`^https://example\.com/`).
+		WithDeny(`/logout`).
+		WithSelector(NewSelector("title").XPath("//title")).
+		WithSelector(NewSelector("next").CSS("a.next").Next()).
+		WithField("required", true).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Rules{
+		Method:             "POST",
+		URL:                mustNewURL("https://example.com"),
+		Proxy:              mustNewURL("http://proxy.example.com:8080"),
+		Header:             http.Header{"User-Agent": {"Colibri"}},
+		Timeout:            5 * time.Second,
+		Delay:              time.Second,
+		Retries:            3,
+		RetryBackoff:       time.Second,
+		RetryOn:            []int{429, 503},
+		MaxWait:            time.Minute,
+		DisableCompression: true,
+		MaxBodySize:        1024,
+		MaxPages:           5,
+		MaxDepth:           3,
+		MaxRequests:        100,
+		FollowConcurrency:  8,
+		Vars:               NewVars(map[string]string{"token": ""}),
+		Render:             true,
+		RenderWait:         2 * time.Second,
+		UseCookies:         true,
+		Session:            "example",
+		Allow:              []*regexp.Regexp{regexp.MustCompile(`^https://example\.com/`)},
+		Deny:               []*regexp.Regexp{regexp.MustCompile(`/logout`)},
+		Selectors: []*Selector{
+			{Name: "title", Expr: "//title", Type: "xpath", Fields: map[string]any{}},
+			{Name: "next", Expr: "a.next", Type: "css", Fields: map[string]any{KeyNext: true}},
+		},
+		Fields: map[string]any{"required": true},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("got %v, want %v", rules, want)
+	}
+
+	_, err = NewRulesBuilder().
+		WithMethod("GE T").
+		WithURL("ftp://example.com").
+		WithHeader("Bad Header", "value").
+		WithTimeout(-1).
+		WithRetries(-1, 0).
+		WithMaxBodySize(-1).
+		WithMaxPages(-1).
+		WithMaxDepth(-1).
+		WithMaxRequests(-1).
+		WithFollowConcurrency(-1).
+		WithRender(-1).
+		WithAllow("(").
+		WithSelector(
+			NewSelector("title").XPath("//title"),
+			NewSelector("title").XPath("//h1"),
+			NewSelector("empty"),
+		).
+		Build()
+
+	var errs *Errs
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v, want *Errs", err)
+	}
+
+	for key, want := range map[string]error{
+		KeyMethod:            ErrInvalidMethod,
+		KeyURL:               ErrInvalidURL,
+		KeyHeader:            ErrInvalidHeader,
+		KeyTimeout:           ErrNegativeDuration,
+		KeyRetries:           ErrNegativeRetries,
+		KeyMaxBodySize:       ErrNegativeSize,
+		KeyMaxPages:          ErrNegativePages,
+		KeyMaxDepth:          ErrNegativeLimit,
+		KeyMaxRequests:       ErrNegativeLimit,
+		KeyFollowConcurrency: ErrNegativeLimit,
+		KeyRenderWait:        ErrNegativeDuration,
+		"title":              ErrDuplicateSelector,
+		"empty":              ErrInvalidSelector,
+	} {
+		if got, _ := errs.Get(key); got != want {
+			t.Fatalf("%v: got %v, want %v", key, got, want)
+		}
+	}
+
+	if got, _ := errs.Get(KeyAllow); got == nil {
+		t.Fatalf("%v: got %v, want error", KeyAllow, got)
+	}
+
+	if _, err := NewRulesBuilder().Build(); err == nil {
+		t.Fatal("expected ErrURLRequired")
+	}
+}
+
+func TestVariants(t *testing.T) {
+	raw := `{
+		"Method": "GET",
+		"URL": "https://example.com",
+		"Selectors": {"price": "//span[@id='price']"},
+		"Variants": [
+			{"Name": "mobile", "Detect": "//div[@class='m-layout']", "Selectors": {"price": "//div[@class='m-price']"}},
+			{"Name": "beta", "Detect": {"Expr": "#beta", "Type": "css"}, "Selectors": {"price": {"Expr": "#beta .price", "Type": "css"}}}
+		]
+	}`
+
+	want, err := NewRulesBuilder().
+		WithURL("https://example.com").
+		WithSelector(NewSelector("price").XPath("//span[@id='price']")).
+		WithVariant("mobile",
+			NewSelector("").XPath("//div[@class='m-layout']"),
+			NewSelector("price").XPath("//div[@class='m-price']"),
+		).
+		WithVariant("beta",
+			NewSelector("").CSS("#beta"),
+			NewSelector("price").CSS("#beta .price"),
+		).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the builder sets the type of the XPath expressions and an empty header
+	want.Header = nil
+	want.Selectors[0].Type = ""
+	want.Variants[0].Detect.Type = ""
+	want.Variants[0].Selectors[0].Type = ""
+
+	var rules Rules
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(&rules, want) {
+		t.Fatalf("got %v, want %v", &rules, want)
+	}
+
+	data, err := json.Marshal(&rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var newRules Rules
+	if err := json.Unmarshal(data, &newRules); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(&newRules, want) {
+		t.Fatalf("got %v, want %v", &newRules, want)
+	}
+
+	if clone := want.Clone(); !reflect.DeepEqual(clone, want) || (clone.Variants[0] == want.Variants[0]) {
+		t.Fatal("invalid clone")
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := NewRules(RawRules{
+			"Variants": []any{
+				map[string]any{"Detect": "//div"},
+				map[string]any{"Name": "mobile", "Detect": true},
+				"beta",
+			},
+		})
+
+		wantErr := map[string]any{
+			"Variants": map[string]any{
+				"0":      ErrInvalidVariant.Error(),
+				"mobile": map[string]any{"Detect": ErrInvalidSelector.Error()},
+				"2":      ErrInvalidVariant.Error(),
+			},
+		}
+
+		got := err.Error()
+		wantJSON, _ := json.Marshal(wantErr)
+		if got != string(wantJSON) {
+			t.Fatalf("got %s, want %s", got, wantJSON)
+		}
+
+		if _, err := NewRules(RawRules{"Variants": "mobile"}); err == nil {
+			t.Fatal("expected ErrInvalidVariants")
+		}
+	})
+
+	t.Run("Builder", func(t *testing.T) {
+		_, err := NewRulesBuilder().
+			WithURL("https://example.com").
+			WithVariant("", NewSelector("").XPath("//div")).
+			WithVariant("mobile", NewSelector("").XPath("//div")).
+			WithVariant("mobile", NewSelector("").XPath("//div")).
+			WithVariant("beta", NewSelector(""), NewSelector("price")).
+			Build()
+
+		var errs *Errs
+		if !errors.As(err, &errs) {
+			t.Fatalf("got %v, want *Errs", err)
+		}
+
+		if got, _ := errs.Get(KeyVariants); got != ErrInvalidVariant {
+			t.Fatalf("got %v, want %v", got, ErrInvalidVariant)
+		}
+
+		if got, _ := errs.Get("mobile"); got != ErrDuplicateVariant {
+			t.Fatalf("got %v, want %v", got, ErrDuplicateVariant)
+		}
+
+		beta, _ := errs.Get("beta")
+		if keys := beta.(*Errs).Keys(); !reflect.DeepEqual(keys, []string{KeyDetect, "price"}) {
+			t.Fatalf("got %v, want %v", keys, []string{KeyDetect, "price"})
+		}
+	})
+}
+
+func TestVars(t *testing.T) {
+	vars := NewVars(map[string]string{"token": "", "page": "1"})
+
+	if err := vars.Set("token", "a b&c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vars.Set("undeclared", "x"); !errors.Is(err, ErrUndeclaredVar) {
+		t.Fatalf("got %v, want %v", err, ErrUndeclaredVar)
+	}
+
+	if got, want := vars.Expand("{{token}}/{{ page }}/{{other}}"), "a b&c/1/{{other}}"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	var (
+		c      = New()
+		client = &testRulesClient{}
+		rules  = &Rules{
+			URL:    mustNewURL("https://example.com/{{page}}?token={{token}}"),
+			Header: http.Header{"Authorization": {"Bearer {{token}}"}},
+			Vars:   vars,
+		}
+	)
+	c.Client = client
+
+	if _, err := c.Do(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := client.rules.URL.String(), "https://example.com/1?token=a%20b%26c"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if got, want := client.rules.Header.Get("Authorization"), "Bearer a b&c"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// the templates of the rules are not modified
+	if got, want := rules.Header.Get("Authorization"), "Bearer {{token}}"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// the clones and the rules of the selectors share the variables
+	vars.Set("page", 2)
+	selectorRules := NewSelector("next").Build().Rules(rules.Clone())
+	if got, _ := selectorRules.Vars.Get("page"); got != "2" {
+		t.Fatalf("got %v, want %v", got, "2")
+	}
+
+	newRules, err := NewRules(RawRules{"Vars": []any{"token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := newRules.Vars.Names(), []string{"token"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if _, err := NewRules(RawRules{"Vars": "token"}); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var (
+		buf     bytes.Buffer
+		c       = New()
+		testErr = errors.New("Test Error")
+	)
+	c.Client = &testClient{}
+	c.Delay = &testDelay{}
+	c.RobotsTxt = &testRobots{}
+	c.Parser = &testParser{}
+	c.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	tests := []struct {
+		Name  string
+		Rules *Rules
+		Want  []string
+	}{
+		{
+			"OK",
+			&Rules{URL: mustNewURL("https://example.com"), Delay: time.Second, Selectors: []*Selector{{Name: "title", Expr: "//title"}}},
+			[]string{LogRobotsTxtAllowed, LogDelay, LogRequest, LogResponse, LogParse},
+		},
+		{
+			"RobotsTxtBlocked",
+			&Rules{URL: mustNewURL("https://example.com"), Fields: map[string]any{"robotsErr": testErr}},
+			[]string{LogRobotsTxtBlocked},
+		},
+		{
+			"RequestFailed",
+			&Rules{URL: mustNewURL("https://example.com"), IgnoreRobotsTxt: true, Fields: map[string]any{"doErr": testErr}},
+			[]string{LogRequest, LogRequestFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			buf.Reset()
+			c.Extract(tt.Rules)
+
+			var got []string
+			for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+				var event struct {
+					Msg string
+					URL string
+				}
+				if err := json.Unmarshal(line, &event); err != nil {
+					t.Fatal(err)
+				}
+
+				// the URL of the test response is nil
+				if (event.URL != "https://example.com") && (event.Msg != LogResponse) && (event.Msg != LogParse) {
+					t.Fatalf("%s: got %v, want %v", event.Msg, event.URL, "https://example.com")
+				}
+				got = append(got, event.Msg)
+			}
+
+			if !reflect.DeepEqual(got, tt.Want) {
+				t.Fatalf("got %v, want %v", got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestHostControl(t *testing.T) {
+	c := New()
+	c.Client = &testClient{}
+
+	rules := &Rules{URL: mustNewURL("https://Example.com:8080/page")}
+	do := func(ctx context.Context) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := c.DoContext(ctx, rules)
+			done <- err
+		}()
+		return done
+	}
+
+	c.PauseHost("example.com")
+	if !c.HostPaused("EXAMPLE.com:443") {
+		t.Fatal("host not paused")
+	}
+
+	changes := c.HostChanges()
+	done := do(context.Background())
+	select {
+	case err := <-done:
+		t.Fatalf("got %v, want paused request", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.ResumeHost("example.com")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+	default:
+		t.Fatal("changes not notified")
+	}
+
+	// the paused requests return the error of the context
+	c.PauseHost("example.com")
+	ctx, cancel := context.WithCancel(context.Background())
+	done = do(ctx)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+
+	// blocking a paused host ends the paused requests
+	done = do(context.Background())
+	c.BlockHost("example.com")
+	if err := <-done; err != ErrHostBlocked {
+		t.Fatalf("got %v, want %v", err, ErrHostBlocked)
+	} else if c.HostPaused("example.com") || !c.HostBlocked("example.com") {
+		t.Fatal("host not blocked")
+	}
+
+	c.ResumeHost("example.com")
+	if _, err := c.Do(rules); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDomainRules(t *testing.T) {
+	client := &testRulesClient{}
+	c := New()
+	c.Client = client
+	c.DomainRules = NewDomainRules()
+
+	if err := c.DomainRules.Set("*.Example.com", DomainDefaults{
+		Header: http.Header{"User-Agent": {"domain-bot"}, "Accept-Language": {"es"}},
+		Delay:  time.Second,
+		Proxy:  mustNewURL("http://proxy.example:8080"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.DomainRules.Set("shop.example.com", DomainDefaults{
+		Header:     http.Header{"Accept-Language": {"en"}},
+		Delay:      2 * time.Second,
+		UseCookies: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.DomainRules.Set("[", DomainDefaults{}); err == nil {
+		t.Fatal("got nil, want error")
+	}
+
+	tests := []struct {
+		Rules      *Rules
+		UserAgent  string
+		Language   string
+		Delay      time.Duration
+		Proxy      string
+		UseCookies bool
+	}{
+		{
+			Rules:     &Rules{URL: mustNewURL("https://www.example.com/")},
+			UserAgent: "domain-bot", Language: "es", Delay: time.Second, Proxy: "http://proxy.example:8080",
+		},
+		{
+			Rules:     &Rules{URL: mustNewURL("https://SHOP.example.com:8443/"), Header: http.Header{"User-Agent": {"rules-bot"}}},
+			UserAgent: "rules-bot", Language: "en", Delay: 2 * time.Second, Proxy: "http://proxy.example:8080", UseCookies: true,
+		},
+		{
+			Rules:     &Rules{URL: mustNewURL("https://www.example.com/"), Delay: time.Millisecond, Proxy: mustNewURL("http://other.proxy")},
+			UserAgent: "domain-bot", Language: "es", Delay: time.Millisecond, Proxy: "http://other.proxy",
+		},
+		{
+			Rules:     &Rules{URL: mustNewURL("https://example.com/")},
+			UserAgent: DefaultUserAgent,
+		},
+	}
+
+	for _, tt := range tests {
+		if _, err := c.Do(tt.Rules); err != nil {
+			t.Fatal(err)
+		}
+
+		rules := client.rules
+		proxy := ""
+		if rules.Proxy != nil {
+			proxy = rules.Proxy.String()
+		}
+
+		if got := rules.Header.Get("User-Agent"); got != tt.UserAgent {
+			t.Fatalf("got %v, want %v", got, tt.UserAgent)
+		} else if got := rules.Header.Get("Accept-Language"); got != tt.Language {
+			t.Fatalf("got %v, want %v", got, tt.Language)
+		} else if rules.Delay != tt.Delay {
+			t.Fatalf("got %v, want %v", rules.Delay, tt.Delay)
+		} else if proxy != tt.Proxy {
+			t.Fatalf("got %v, want %v", proxy, tt.Proxy)
+		} else if rules.UseCookies != tt.UseCookies {
+			t.Fatalf("got %v, want %v", rules.UseCookies, tt.UseCookies)
+		}
+	}
+
+	// the rules of the caller are not modified
+	if got := tests[0].Rules.Header.Get("Accept-Language"); got != "" {
+		t.Fatalf("got %v, want %v", got, "")
+	}
+
+	c.DomainRules.Delete("*.example.com")
+	if _, ok := c.DomainRules.Get("*.example.com"); ok {
+		t.Fatal("defaults not deleted")
+	} else if _, ok := c.DomainRules.Get("shop.example.com"); !ok {
+		t.Fatal("defaults not found")
+	}
+}
+
+func TestCompiledRules(t *testing.T) {
+	compiled, err := Compile(RawRules{
+		"Method": "post",
+		"URL":    "https://example.com/search",
+		"Header": map[string]any{"accept-language": "es"},
+		"Allow":  []any{`^https://example\.com/`},
+		"Selectors": map[string]any{
+			"title": "//h1",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := compiled.Rules()
+	if rules.Method != "POST" {
+		t.Fatalf("got %v, want %v", rules.Method, "POST")
+	} else if got := rules.Header.Get("Accept-Language"); got != "es" {
+		t.Fatalf("got %v, want %v", got, "es")
+	}
+
+	// the copies are independent
+	rules.URL.Path = "/other"
+	rules.Selectors[0].Expr = "//h2"
+	if other := compiled.Rules(); (other.URL.Path != "/search") || (other.Selectors[0].Expr != "//h1") {
+		t.Fatalf("got %v %v, want %v %v", other.URL.Path, other.Selectors[0].Expr, "/search", "//h1")
+	}
+
+	b, err := json.Marshal(compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded CompiledRules
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded.Raw(), compiled.Raw()) {
+		t.Fatalf("got %v, want %v", decoded.Raw(), compiled.Raw())
+	}
+
+	batch, err := CompileAll([]RawRules{
+		{"URL": "https://example.com/a"},
+		{"URL": "https://example.com/b", "Timeout": "soon"},
+		{"URL": "https://example.com/c"},
+	})
+
+	errs, ok := err.(*Errs)
+	if !ok {
+		t.Fatalf("got %v, want *Errs", err)
+	} else if got := errs.Keys(); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Fatalf("got %v, want %v", got, []string{"1"})
+	}
+
+	if (batch[0] == nil) || (batch[1] != nil) || (batch[2] == nil) {
+		t.Fatalf("got %v, want %v", batch, "[rules nil rules]")
+	}
+}
+
+func TestCrawlDelay(t *testing.T) {
+	tests := []struct {
+		Delay, CrawlDelay time.Duration
+		IgnoreRobotsTxt   bool
+		Want              time.Duration
+	}{
+		{0, 0, false, 0},
+		{time.Second, 0, false, time.Second},
+		{time.Second, 2 * time.Second, false, 2 * time.Second},
+		{3 * time.Second, 2 * time.Second, false, 3 * time.Second},
+		{time.Second, 2 * time.Second, true, time.Second},
+	}
+
+	for _, tt := range tests {
+		delay := &testWaitDelay{}
+
+		c := New()
+		c.Client = &testClient{}
+		c.Delay = delay
+		c.RobotsTxt = &testCrawlDelayRobots{crawlDelay: tt.CrawlDelay}
+
+		rules := &Rules{Method: "GET", URL: mustNewURL("https://example.com"), Delay: tt.Delay, IgnoreRobotsTxt: tt.IgnoreRobotsTxt}
+		if _, err := c.Do(rules); err != nil {
+			t.Fatal(err)
+		}
+
+		if delay.waited != tt.Want {
+			t.Fatalf("got %v, want %v", delay.waited, tt.Want)
+		}
+	}
+}
+
+func TestDelayObserver(t *testing.T) {
+	delay := &testObserverDelay{}
+
+	c := New()
+	c.Client = &testClient{}
+	c.Delay = delay
+
+	// the Delay is used without the delay of the rules
+	if _, err := c.Do(&Rules{Method: "GET", URL: mustNewURL("https://example.com")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !delay.WaitUsed || !delay.DoneUsed {
+		t.Fatalf("got %v, want %v", delay.WaitUsed && delay.DoneUsed, true)
+	} else if delay.statusCode != 500 {
+		t.Fatalf("got %v, want %v", delay.statusCode, 500)
+	}
+}
+
+func TestDelayContext(t *testing.T) {
+	delay := &testBlockingDelay{release: make(chan struct{}), done: make(chan struct{})}
+
+	c := New()
+	c.Client = &testClient{}
+	c.Delay = delay
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// the wait of a Delay without WaitContext is abandoned
+	_, err := c.DoContext(ctx, &Rules{URL: mustNewURL("https://example.com"), Delay: time.Hour})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// Done is called when the abandoned wait returns
+	close(delay.release)
+	select {
+	case <-delay.done:
+	case <-time.After(time.Second):
+		t.Fatal("Done not called")
+	}
+}
+
+type testBlockingDelay struct {
+	testDelay
+	release chan struct{}
+	done    chan struct{}
+}
+
+func (d *testBlockingDelay) Wait(_ *url.URL, _ time.Duration) { <-d.release }
+func (d *testBlockingDelay) Done(_ *url.URL)                  { close(d.done) }
+
+type testObserverDelay struct {
+	testDelay
+	statusCode int
+}
+
+func (d *testObserverDelay) ObserveResponse(_ *url.URL, resp Response, _ time.Duration, _ error) {
+	d.statusCode = resp.StatusCode()
+}
+
+type testWaitDelay struct {
+	testDelay
+	waited time.Duration
+}
+
+func (d *testWaitDelay) Wait(_ *url.URL, duration time.Duration) { d.waited = duration }
+
+type testCrawlDelayRobots struct {
+	testRobots
+	crawlDelay time.Duration
+}
+
+func (r *testCrawlDelayRobots) CrawlDelay(_ *url.URL, _ string) time.Duration { return r.crawlDelay }
+
+type testSitemapRobots struct {
+	testRobots
+	sitemaps []string
+}
+
+func (r *testSitemapRobots) Sitemaps(_ string) []string { return r.sitemaps }
+
+func TestFetchFilters(t *testing.T) {
+	filters := []FetchFilter{
+		SkipStatus(404),
+		SkipMIME("image/*"),
+		SkipLarger(5<<20, "text/html"),
+	}
+
+	tests := []struct {
+		StatusCode  int
+		ContentType string
+		Size        string
+		Reason      string
+	}{
+		{200, "text/html; charset=utf-8", "1024", ""},
+		{200, "text/html; charset=utf-8", "", ""},
+		{404, "text/html", "", SkipReasonStatus},
+		{200, "image/png", "", SkipReasonMIME},
+		{200, "Image/JPEG", "", SkipReasonMIME},
+		{200, "text/html", "10485760", SkipReasonSize},
+		{200, "application/json", "10485760", ""},
+	}
+
+	for _, tt := range tests {
+		header := http.Header{"Content-Type": {tt.ContentType}}
+		if tt.Size != "" {
+			header.Set("Content-Length", tt.Size)
+		}
+
+		c := New()
+		c.Client = &testHeaderClient{statusCode: tt.StatusCode, header: header}
+		c.Parser = &testSetParser{name: "parsed"}
+		c.FetchFilters = filters
+
+		rules := &Rules{Method: "GET", URL: mustNewURL("https://example.com"), Selectors: []*Selector{{Name: "set", Expr: "//set"}}}
+		resp, output, err := c.Extract(rules)
+		if resp == nil {
+			t.Fatal("got nil response")
+		}
+
+		if tt.Reason == "" {
+			if err != nil {
+				t.Fatal(err)
+			} else if output["set"] != "parsed" {
+				t.Fatalf("got %v, want %v", output["set"], "parsed")
+			}
+			continue
+		}
+
+		var skipErr *SkipError
+		if !errors.As(err, &skipErr) || !errors.Is(err, ErrSkipped) {
+			t.Fatalf("got %v, want %v", err, ErrSkipped)
+		} else if skipErr.Reason != tt.Reason {
+			t.Fatalf("got %v, want %v", skipErr.Reason, tt.Reason)
+		} else if output != nil {
+			t.Fatalf("got %v, want %v", output, nil)
+		}
+
+		_, err = c.ExtractStream(rules, func(_ string, _ any) error { return nil })
+		if !errors.Is(err, ErrSkipped) {
+			t.Fatalf("got %v, want %v", err, ErrSkipped)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	var (
+		testErr = errors.New("Test Error")
+		u       = mustNewURL("https://example.com")
+	)
+
+	tests := []struct {
+		Err  error
+		Kind string
+	}{
+		{nil, ""},
+		{testErr, ""},
+		{&Warning{Kind: WarningTruncated, URL: u, Err: testErr}, WarningTruncated},
+		{fmt.Errorf("wrapped: %w", &Warning{Kind: WarningEmpty}), WarningEmpty},
+		{&SkipError{Reason: SkipReasonMIME, URL: u}, WarningSkipped},
+		{(&Errs{}).Add("key", &Warning{Kind: WarningEmpty}), ""},
+	}
+
+	for _, tt := range tests {
+		w, ok := AsWarning(tt.Err)
+		if ok != (tt.Kind != "") {
+			t.Fatalf("got %v, want %v", ok, tt.Kind != "")
+		} else if ok && (w.Kind != tt.Kind) {
+			t.Fatalf("got %v, want %v", w.Kind, tt.Kind)
+		}
+	}
+
+	c := New()
+	c.Client = &testClient{}
+	c.RobotsTxt = &testRobots{}
+
+	var warnings []*Warning
+	c.OnWarning = func(ctx context.Context, w *Warning) { warnings = append(warnings, w) }
+
+	// the warnings returned by Do are not reported
+	_, err := c.Do(&Rules{Method: "GET", URL: u, Fields: map[string]any{"robotsErr": testErr}})
+	if w, ok := AsWarning(err); !ok || (w.Kind != WarningRobotsTxt) {
+		t.Fatalf("got %v, want %v", err, WarningRobotsTxt)
+	} else if !errors.Is(err, testErr) || (err.Error() != testErr.Error()) {
+		t.Fatalf("got %v, want %v", err, testErr)
+	}
+
+	Warn(c.withWarnings(context.Background()), &Warning{Kind: WarningEmpty, Key: "title"})
+	Warn(context.Background(), &Warning{Kind: WarningEmpty, Key: "discarded"})
+	if (len(warnings) != 1) || (warnings[0].Key != "title") {
+		t.Fatalf("got %v, want %v", warnings, "title")
+	}
+}
+
+func TestTraceID(t *testing.T) {
+	var buf bytes.Buffer
+	metrics := &testTraceMetrics{}
+
+	c := New()
+	c.Client = &testClient{}
+	c.Parser = &testParser{}
+	c.Metrics = metrics
+	c.TraceField = "trace_id"
+	c.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	rules := &Rules{
+		URL:       mustNewURL("https://example.com"),
+		Selectors: []*Selector{{Name: "title", Expr: "//title"}},
+	}
+
+	// a new trace ID for each extraction
+	_, output, err := c.Extract(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, _ := output["trace_id"].(string)
+	if len(id) != 32 {
+		t.Fatalf("got %v, want %v", id, "32 hexadecimal characters")
+	}
+
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var event map[string]any
+		if err := json.Unmarshal(line, &event); err != nil {
+			t.Fatal(err)
+		}
+
+		if event[LogTraceID] != id {
+			t.Fatalf("%s: got %v, want %v", event["msg"], event[LogTraceID], id)
+		}
+	}
+
+	if !reflect.DeepEqual(metrics.traceIDs, []string{id, id}) {
+		t.Fatalf("got %v, want %v", metrics.traceIDs, []string{id, id})
+	}
+
+	if _, output, _ := c.Extract(rules); output["trace_id"] == id {
+		t.Fatalf("got %v, want a new trace ID", output["trace_id"])
+	}
+
+	// the trace ID of the context is used
+	ctx := WithTraceID(context.Background(), "trace-1")
+
+	var names []string
+	_, err = c.ExtractStreamContext(ctx, rules, func(name string, value any) error {
+		names = append(names, name)
+		if (name == "trace_id") && (value != "trace-1") {
+			t.Fatalf("got %v, want %v", value, "trace-1")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if (len(names) == 0) || (names[0] != "trace_id") {
+		t.Fatalf("got %v, want %v", names, "trace_id")
+	}
+
+	rules.Fields = map[string]any{"parserErr": (&Errs{}).Add("title", ErrParseTimeout)}
+	_, _, err = c.ExtractContext(ctx, rules)
+	if got := TraceIDOf(err); got != "trace-1" {
+		t.Fatalf("got %v, want %v", got, "trace-1")
+	} else if got := DetailOf(err).TraceID; got != "trace-1" {
+		t.Fatalf("got %v, want %v", got, "trace-1")
+	}
+
+	c.RobotsTxt = &testRobots{}
+	rules.Fields = map[string]any{"robotsErr": errors.New("Test Error")}
+	if _, err := c.DoContext(ctx, rules); TraceIDOf(err) != "trace-1" {
+		t.Fatalf("got %v, want %v", TraceIDOf(err), "trace-1")
+	}
+
+	if got := TraceIDOf(errors.New("Test Error")); got != "" {
+		t.Fatalf("got %v, want %v", got, "")
+	}
+}
+
+type testTraceMetrics struct {
+	traceIDs []string
+}
+
+func (m *testTraceMetrics) ObserveRequest(_ *url.URL, _ int, _ time.Duration, _ error) {}
+func (m *testTraceMetrics) ObserveBytes(_ *url.URL, _ int)                             {}
+func (m *testTraceMetrics) ObserveParse(_ *url.URL, _ time.Duration, _ error)          {}
+func (m *testTraceMetrics) ObserveRobotsDenial(_ *url.URL)                             {}
+
+func (m *testTraceMetrics) ObserveRequestContext(ctx context.Context, _ *url.URL, _ int, _ time.Duration, _ error) {
+	m.traceIDs = append(m.traceIDs, TraceID(ctx))
+}
+
+func (m *testTraceMetrics) ObserveParseContext(ctx context.Context, _ *url.URL, _ time.Duration, _ error) {
+	m.traceIDs = append(m.traceIDs, TraceID(ctx))
+}
+
+func TestExtractRaw(t *testing.T) {
+	c := New()
+	c.Client = &testClient{}
+	c.Parser = &testParser{}
+
+	want := map[string]any{"title": "Test"}
+	rawRules := RawRules{
+		KeyURL:       "https://example.com",
+		KeySelectors: map[string]any{"title": "//title"},
+		"output":     want,
+	}
+
+	if _, output, err := c.ExtractRaw(rawRules); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(output, want) {
+		t.Fatalf("got %v, want %v", output, want)
+	}
+
+	b, err := json.Marshal(rawRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, output, err := c.ExtractJSON(b); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(output, want) {
+		t.Fatalf("got %v, want %v", output, want)
+	}
+
+	if _, err := c.DoJSON(b); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name string
+		JSON string
+	}{
+		{"InvalidJSON", `{"URL":`},
+		{"InvalidRules", `{"URL": 1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			if _, _, err := c.ExtractJSON([]byte(tt.JSON)); err == nil {
+				t.Fatal("error expected")
+			} else if _, err := c.DoJSON([]byte(tt.JSON)); err == nil {
+				t.Fatal("error expected")
+			}
+		})
+	}
+}
+
+func TestNewResponse(t *testing.T) {
+	u := mustNewURL("https://example.com")
+
+	resp := NewResponse(u, http.StatusOK, http.Header{"Content-Type": {"text/html"}}, strings.NewReader("<title>Test</title>"), nil)
+	if resp.URL() != u {
+		t.Fatalf("got %v, want %v", resp.URL(), u)
+	} else if resp.StatusCode() != http.StatusOK {
+		t.Fatalf("got %v, want %v", resp.StatusCode(), http.StatusOK)
+	} else if got := resp.Header().Get("Content-Type"); got != "text/html" {
+		t.Fatalf("got %v, want %v", got, "text/html")
+	}
+
+	b, err := io.ReadAll(resp.Body())
+	if err != nil {
+		t.Fatal(err)
+	} else if string(b) != "<title>Test</title>" {
+		t.Fatalf("got %v, want %v", string(b), "<title>Test</title>")
+	} else if err := resp.Body().Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := resp.Do(&Rules{URL: u}); err != ErrColibriIsNil {
+		t.Fatalf("got %v, want %v", err, ErrColibriIsNil)
+	} else if _, _, err := resp.Extract(&Rules{URL: u}); err != ErrColibriIsNil {
+		t.Fatalf("got %v, want %v", err, ErrColibriIsNil)
+	}
+
+	// the header and the body are empty
+	resp = NewResponse(u, http.StatusNoContent, nil, nil, nil)
+	if resp.Header() == nil {
+		t.Fatal("header expected")
+	} else if b, _ := io.ReadAll(resp.Body()); len(b) != 0 {
+		t.Fatalf("got %v, want %v", string(b), "")
+	}
+
+	// the requests use the Colibri and the context of the response
+	c := New()
+	c.Client = &testClient{}
+	c.Parser = &testParser{}
+
+	ctx := WithTraceID(context.Background(), "trace-1")
+	resp = NewResponseContext(ctx, u, http.StatusOK, nil, nil, c)
+	if got := resp.(interface{ Context() context.Context }).Context(); TraceID(got) != "trace-1" {
+		t.Fatalf("got %v, want %v", TraceID(got), "trace-1")
+	}
+
+	if _, err := resp.Do(&Rules{URL: u}); err != nil {
+		t.Fatal(err)
+	} else if _, _, err := resp.Extract(&Rules{URL: u}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testHeaderResp struct {
+	testResp
+	statusCode int
+	header     http.Header
+}
+
+func (resp *testHeaderResp) URL() *url.URL       { return mustNewURL("https://example.com") }
+func (resp *testHeaderResp) StatusCode() int     { return resp.statusCode }
+func (resp *testHeaderResp) Header() http.Header { return resp.header }
+func (resp *testHeaderResp) Body() io.ReadCloser { return io.NopCloser(strings.NewReader("")) }
+
+type testHeaderClient struct {
+	statusCode int
+	header     http.Header
+}
+
+func (c *testHeaderClient) Do(_ *Colibri, _ *Rules) (Response, error) {
+	return &testHeaderResp{statusCode: c.statusCode, header: c.header}, nil
+}
+func (c *testHeaderClient) Clear() {}
+
+func TestParserSets(t *testing.T) {
+	var (
+		sets    = NewParserSets()
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+
+	if err := sets.Set("v1", &testSetParser{name: "v1", started: started, release: release}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sets.Set("nil", nil); !errors.Is(err, ErrParserIsNil) {
+		t.Fatalf("got %v, want %v", err, ErrParserIsNil)
 	}
-}
 
-func TestSelectorRules(t *testing.T) {
-	t.Run("", func(t *testing.T) {
-		selector := testSelector.Clone()
-		selector.Fields = nil
+	c := New()
+	c.Client = &testClient{}
+	c.ParserSets = sets
 
-		wantRules := testRules.Clone()
-		wantRules.Method = ""
-		wantRules.URL = nil
-		wantRules.Fields = make(map[string]any)
-		wantRules.Selectors = selector.Selectors
+	newRules := func() *Rules {
+		return &Rules{Method: "GET", URL: mustNewURL("https://example.com"), Selectors: []*Selector{{Name: "set", Expr: "//set"}}}
+	}
 
-		rules := selector.Rules(testRules)
-		if !reflect.DeepEqual(rules, wantRules) {
-			t.Fatal("not equal")
+	type result struct {
+		output map[string]any
+		ctx    context.Context
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		ctx, _ := c.selectParser(context.Background())
+		_, output, err := c.ExtractContext(ctx, newRules())
+		if err != nil {
+			t.Error(err)
 		}
-	})
+		inFlight <- result{output, ctx}
+	}()
+	<-started
 
-	t.Run("", func(t *testing.T) {
-		selector := testSelector.Clone()
-		selector.Fields["Method"] = "POST"
-		selector.Fields["Proxy"] = mustNewURL("")
-		selector.Fields["Header"] = http.Header{"Accept": {"application/xml"}}
-		selector.Fields["Timeout"] = 10 * time.Second
-		selector.Fields["UseCookies"] = true
-		selector.Fields["IgnoreRobotsTxt"] = false
-		selector.Fields["Delay"] = 5 * time.Second
+	// the new extractions use the new active set, the in-flight extraction keeps the old one
+	sets.Set("v2", &testSetParser{name: "v2"})
+	if err := sets.Activate("v2"); err != nil {
+		t.Fatal(err)
+	}
 
-		wantRules := &Rules{
-			Method:     "POST",
-			Proxy:      mustNewURL(""),
-			Header:     http.Header{"Accept": {"application/xml"}},
-			Timeout:    10 * time.Second,
-			UseCookies: true,
-			Delay:      5 * time.Second,
-			Selectors:  CloneSelectors(selector.Selectors),
-			Fields:     make(map[string]any),
-		}
+	_, output, err := c.Extract(newRules())
+	if err != nil {
+		t.Fatal(err)
+	} else if output["set"] != "v2" {
+		t.Fatalf("got %v, want %v", output["set"], "v2")
+	}
 
-		rules := selector.Rules(testRules)
-		if !reflect.DeepEqual(rules, wantRules) {
-			t.Fatal("not equal")
-		}
-	})
+	close(release)
+	old := <-inFlight
+	if old.output["set"] != "v1" {
+		t.Fatalf("got %v, want %v", old.output["set"], "v1")
+	}
 
-	t.Run("", func(t *testing.T) {
-		selector := testSelector.Clone()
-		selector.Fields["Method"] = "POST"
-		selector.Fields["UseCookies"] = true
-		selector.Fields["Delay"] = 5 * time.Second
+	// the requests made with the context of the old extraction, e.g. the Follow requests
+	if _, parser := c.selectParser(old.ctx); parser.(*testSetParser).name != "v1" {
+		t.Fatalf("got %v, want %v", parser.(*testSetParser).name, "v1")
+	}
 
-		wantRules := &Rules{
-			Method:          "POST",
-			Proxy:           testRules.Proxy,
-			Header:          testRules.Header,
-			Timeout:         testRules.Timeout,
-			UseCookies:      true,
-			IgnoreRobotsTxt: testRules.IgnoreRobotsTxt,
-			Delay:           5 * time.Second,
-			Selectors:       CloneSelectors(selector.Selectors),
-			Fields:          make(map[string]any),
-		}
+	if err := sets.Activate("v3"); !errors.Is(err, ErrParserSetNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrParserSetNotFound)
+	}
 
-		rules := selector.Rules(testRules)
-		if !reflect.DeepEqual(rules, wantRules) {
-			t.Fatal("not equal")
-		}
-	})
+	if err := sets.Delete("v2"); !errors.Is(err, ErrParserSetActive) {
+		t.Fatalf("got %v, want %v", err, ErrParserSetActive)
+	}
+
+	if err := sets.Delete("v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := sets.Names(); !reflect.DeepEqual(names, []string{"v2"}) {
+		t.Fatalf("got %v, want %v", names, []string{"v2"})
+	}
+}
+
+type testSetParser struct {
+	name             string
+	started, release chan struct{}
 }
 
+func (p *testSetParser) Match(_ string) bool { return true }
+func (p *testSetParser) Parse(_ *Rules, _ Response) (map[string]any, error) {
+	if p.started != nil {
+		close(p.started)
+		<-p.release
+	}
+	return map[string]any{"set": p.name}, nil
+}
+func (p *testSetParser) Clear() {}
+
 func TestClear(t *testing.T) {
 	t.Run("Colibri", func(t *testing.T) {
 		var (
@@ -530,6 +2223,103 @@ func TestClear(t *testing.T) {
 	})
 }
 
+func TestPoolDebug(t *testing.T) {
+	c := New()
+	c.Client = &testClient{}
+
+	// the second release is ignored
+	rules, _ := NewRules(RawRules{KeyURL: "https://example.com"})
+	ReleaseRules(rules)
+	ReleaseRules(rules)
+	if _, err := c.Do(rules); err != ErrRulesReleased {
+		t.Fatalf("got %v, want %v", err, ErrRulesReleased)
+	}
+
+	SetPoolDebug(true)
+	defer SetPoolDebug(false)
+
+	tests := []struct {
+		Name    string
+		Release func()
+	}{
+		{"Rules", func() {
+			rules, _ := NewRules(RawRules{KeyURL: "https://example.com"})
+			ReleaseRules(rules)
+			ReleaseRules(rules)
+		}},
+		{"Selector", func() {
+			selector := &Selector{Name: "title"}
+			ReleaseSelector(selector)
+			ReleaseSelector(selector)
+		}},
+		{"Nested", func() {
+			rules, _ := NewRules(RawRules{KeyURL: "https://example.com", KeySelectors: map[string]any{"title": "//title"}})
+			selector := rules.Selectors[0]
+			ReleaseRules(rules)
+			ReleaseSelector(selector)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != ErrDoubleRelease {
+					t.Fatalf("got %v, want %v", r, ErrDoubleRelease)
+				}
+			}()
+			tt.Release()
+		})
+	}
+}
+
+func TestPoolLiteral(t *testing.T) {
+	ReleaseRules(&Rules{})
+	ReleaseSelector(&Selector{})
+
+	for i := 0; i < 10; i++ {
+		rules, selector := getRules(), getSelector()
+		if rules.Fields == nil {
+			t.Fatal("rules without Fields")
+		} else if selector.Fields == nil {
+			t.Fatal("selector without Fields")
+		}
+	}
+}
+
+func TestScope(t *testing.T) {
+	var (
+		scope = NewScope()
+		sel   = &Selector{Name: "links", Expr: "//a/@href", Follow: true}
+	)
+
+	src, err := scope.NewRules(RawRules{KeyURL: "https://example.com", KeySelectors: map[string]any{"title": "//title"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := scope.Clone(src)
+	derived, err := scope.SelectorRules(scope.Selector(sel), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scope.Release()
+	for _, rules := range []*Rules{src, clone, derived} {
+		if !rules.released || (rules.URL != nil) {
+			t.Fatalf("got %v, want %v", rules.released, true)
+		}
+	}
+
+	if !sel.released {
+		t.Fatalf("got %v, want %v", sel.released, true)
+	}
+
+	// the Scope is empty
+	SetPoolDebug(true)
+	defer SetPoolDebug(false)
+	scope.Release()
+}
+
 func TestErrs(t *testing.T) {
 	var (
 		err1 = errors.New("err 1")
@@ -608,6 +2398,168 @@ func TestErrs(t *testing.T) {
 	if !reflect.DeepEqual(result, want) {
 		t.Fatal("not equal", result)
 	}
+
+	t.Run("Path", func(t *testing.T) {
+		errs := AddError(nil, "https://go.dev", AddError(nil, "title", AddError(nil, "a.b", err1)))
+		errs = AddError(errs, "err2", err2)
+
+		e := errs.(*Errs)
+		if err, _ := e.Get("https://go.dev.title.a.b"); !errors.Is(err, err1) {
+			t.Fatalf(`got %v, want %v`, err, err1)
+		} else if _, ok := e.Get("https://go.dev.none"); ok {
+			t.Fatal("unexpected error")
+		}
+
+		if e.Len() != 2 {
+			t.Fatalf("got %v, want %v", e.Len(), 2)
+		}
+
+		wantFlat := map[string]string{"https://go.dev.title.a.b": "err 1", "err2": "err 2"}
+		if flat := e.Flatten(); !reflect.DeepEqual(flat, wantFlat) {
+			t.Fatalf("got %v, want %v", flat, wantFlat)
+		}
+
+		if keys := e.Keys(); !reflect.DeepEqual(keys, []string{"err2", "https://go.dev"}) {
+			t.Fatalf("got %v", keys)
+		}
+	})
+
+	t.Run("MessageFunc", func(t *testing.T) {
+		SetMessageFunc(func(path string, err error) string {
+			if errors.Is(err, ErrInvalidSelector) {
+				return path + ": el selector no es válido"
+			}
+			return err.Error()
+		})
+		defer SetMessageFunc(nil)
+
+		errs := AddError(nil, KeySelectors, AddError(nil, "title", ErrInvalidSelector))
+		errs = AddError(errs, "err1", err1)
+
+		want := `{"Selectors":{"title":"Selectors.title: el selector no es válido"},"err1":"err 1"}`
+		if got := errs.Error(); got != want {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+
+		SetMessageFunc(nil)
+		want = `{"Selectors":{"title":"invalid selector"},"err1":"err 1"}`
+		if got := errs.Error(); got != want {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestErrorCode(t *testing.T) {
+	customErr := errors.New("custom")
+	RegisterErrorCode(customErr, "CUSTOM", CategoryParse)
+
+	tests := []struct {
+		Err            error
+		Code, Category string
+	}{
+		{nil, "", ""},
+		{errors.New("unknown"), CodeUnknown, CategoryInternal},
+		{ErrClientIsNil, CodeNotConfigured, CategoryInternal},
+		{fmt.Errorf("field: %w", ErrMustBeConvInt), CodeInvalidRules, CategoryRules},
+		{ErrHostBlocked, CodeHostBlocked, CategoryPolicy},
+		{ErrBudgetExceeded, CodeBudgetExceeded, CategoryPolicy},
+		{context.DeadlineExceeded, CodeTimeout, CategoryNetwork},
+		{&net.OpError{Op: "dial", Err: errors.New("refused")}, CodeNetwork, CategoryNetwork},
+		{&Warning{Kind: WarningRobotsTxt, Err: customErr}, CodeRobotsDenied, CategoryWarning},
+		{&SkipError{Reason: SkipReasonMIME}, CodeResponseSkipped, CategoryWarning},
+		{customErr, "CUSTOM", CategoryParse},
+	}
+
+	for _, tt := range tests {
+		code, category := ErrorCode(tt.Err)
+		if (code != tt.Code) || (category != tt.Category) {
+			t.Fatalf("%v: got %v %v, want %v %v", tt.Err, code, category, tt.Code, tt.Category)
+		}
+	}
+
+	errs := AddError(nil, "title", &Warning{Kind: WarningEmpty, Key: "title"})
+	errs = AddError(errs, "nested", AddError(nil, "field", ErrMustBeString))
+
+	want := `{"nested":{"field":{"code":"INVALID_RULES","category":"rules","msg":"must be a string"}},` +
+		`"title":{"code":"SELECTOR_EMPTY","category":"warning","msg":"warning empty (title)"}}`
+
+	for _, v := range []any{errs.(*Errs).Details(), errs} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		} else if string(b) != want {
+			t.Fatalf("got %v, want %v", string(b), want)
+		}
+	}
+
+	wantMsgs := `{"nested":{"field":"must be a string"},"title":"warning empty (title)"}`
+	if got := errs.Error(); got != wantMsgs {
+		t.Fatalf("got %v, want %v", got, wantMsgs)
+	}
+
+	if got := DetailOf(ErrRulesIsNil); got != (ErrorDetail{Code: CodeInvalidRules, Category: CategoryRules, Msg: ErrRulesIsNil.Error()}) {
+		t.Fatalf("got %v, want %v", got, CodeInvalidRules)
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	var (
+		testErr = errors.New("Test Error")
+		u       = mustNewURL("https://example.com")
+		c       = New()
+	)
+	c.Client = &testClient{}
+	c.RobotsTxt = &testRobots{}
+	c.Parser = &testParser{}
+
+	_, err := c.Do(&Rules{URL: u, IgnoreRobotsTxt: true, Fields: map[string]any{"doErr": testErr}})
+
+	var networkErr *NetworkError
+	if !errors.As(err, &networkErr) || (networkErr.URL != u) {
+		t.Fatalf("got %v, want %v", err, "*NetworkError")
+	} else if !errors.Is(err, testErr) || (err.Error() != testErr.Error()) {
+		t.Fatalf("got %v, want %v", err, testErr)
+	} else if code, _ := ErrorCode(err); code != CodeNetwork {
+		t.Fatalf("got %v, want %v", code, CodeNetwork)
+	}
+
+	_, err = c.Do(&Rules{URL: u, Fields: map[string]any{"robotsErr": testErr}})
+
+	var robotsErr *RobotsError
+	if !errors.As(err, &robotsErr) || !errors.Is(err, testErr) {
+		t.Fatalf("got %v, want %v", err, "*RobotsError")
+	} else if code, _ := ErrorCode(err); code != CodeRobotsDenied {
+		t.Fatalf("got %v, want %v", code, CodeRobotsDenied)
+	}
+
+	// the errors of the Follow requests keep their type
+	follow := (&Errs{}).Add("https://example.com/a", &NetworkError{URL: u, Err: context.DeadlineExceeded})
+	parseErrs := (&Errs{}).Add("title", testErr).Add("links", follow)
+
+	_, _, err = c.Extract(&Rules{
+		URL:       u,
+		Selectors: []*Selector{{Name: "title", Expr: "//title"}},
+		Fields:    map[string]any{"parserErr": parseErrs},
+	})
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || (parseErr.Key != "title") {
+		t.Fatalf("got %v, want %v", err, "title")
+	} else if !errors.Is(err, testErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, []error{testErr, context.DeadlineExceeded})
+	} else if !errors.As(err, &networkErr) || (networkErr.Err != context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", networkErr, context.DeadlineExceeded)
+	}
+
+	if title, _ := parseErrs.Get("title"); title.Error() != testErr.Error() {
+		t.Fatalf("got %v, want %v", title, testErr)
+	} else if code, category := ErrorCode(title); (code != CodeParse) || (category != CategoryParse) {
+		t.Fatalf("got %v, want %v", code, CodeParse)
+	}
+
+	if got := len(parseErrs.Unwrap()); got != 2 {
+		t.Fatalf("got %v, want %v", got, 2)
+	}
 }
 
 func TestDefaultConvFunc(t *testing.T) {
@@ -622,6 +2574,8 @@ func TestDefaultConvFunc(t *testing.T) {
 		{KeyURL, "/", mustNewURL("/"), false},
 		{KeyProxy, "", mustNewURL(""), false},
 
+		{KeyURL, "https://Bücher.example:8080/ñ", mustNewURL("https://xn--bcher-kva.example:8080/ñ"), false},
+
 		{KeyURL, nil, nil, true},
 		{KeyProxy, true, nil, true},
 
@@ -646,6 +2600,21 @@ func TestDefaultConvFunc(t *testing.T) {
 		{KeyDelay, "error", time.Duration(0), true},
 		{KeyTimeout, []byte{}, time.Duration(0), true},
 
+		// Int
+		{KeyRetries, "3", 3, false},
+		{KeyRetries, 2.0, 2, false},
+		{KeyRetries, uint(1), 1, false},
+
+		{KeyRetries, 1.5, 0, true},
+		{KeyRetries, "error", 0, true},
+
+		// Status codes
+		{KeyRetryOn, []any{429, "503"}, []int{429, 503}, false},
+		{KeyRetryOn, 500.0, []int{500}, false},
+
+		{KeyRetryOn, []any{42}, nil, true},
+		{KeyRetryOn, "error", nil, true},
+
 		// Header
 		{KeyHeader, nil, http.Header{}, false},
 		{
@@ -660,8 +2629,20 @@ func TestDefaultConvFunc(t *testing.T) {
 			},
 			false,
 		},
+		{
+			KeyHeader,
+			map[string]any{"Accept": []any{"application/json", "application/xml"}},
+			http.Header{"Accept": {"application/json", "application/xml"}},
+			false,
+		},
 
 		{KeyHeader, 123, http.Header{}, true},
+		{
+			KeyHeader,
+			map[string]any{"Accept": []any{123}},
+			nil,
+			true,
+		},
 		{
 			KeyHeader,
 			map[any]any{123: "test/0.1"},
@@ -675,6 +2656,33 @@ func TestDefaultConvFunc(t *testing.T) {
 			true,
 		},
 
+		// TLS
+		{KeyTLS, nil, (*TLS)(nil), false},
+		{
+			KeyTLS,
+			map[string]any{"InsecureSkipVerify": true, "RootCAs": "ca.pem", "MinVersion": "1.3"},
+			&TLS{InsecureSkipVerify: true, RootCAs: []string{"ca.pem"}, MinVersion: tls.VersionTLS13},
+			false,
+		},
+
+		{KeyTLS, "insecure", (*TLS)(nil), true},
+		{KeyTLS, map[string]any{"MinVersion": "2.0"}, (*TLS)(nil), true},
+		{KeyTLS, map[string]any{"RootCAs": []any{123}}, (*TLS)(nil), true},
+		{KeyTLS, map[string]any{"Verify": false}, (*TLS)(nil), true},
+
+		// Pipes
+		{KeyPipes, nil, []Pipe(nil), false},
+		{KeyPipes, "trim", []Pipe{{Name: "trim"}}, false},
+		{
+			KeyPipes,
+			[]any{"trim", []any{"replace", ",", ""}, "toInt"},
+			[]Pipe{{Name: "trim"}, {Name: "replace", Args: []string{",", ""}}, {Name: "toInt"}},
+			false,
+		},
+		{KeyPipes, "", nil, true},
+		{KeyPipes, []any{123}, nil, true},
+		{KeyPipes, []any{[]any{}}, nil, true},
+
 		// Selectors
 		{
 			KeySelectors,
@@ -712,6 +2720,28 @@ func TestDefaultConvFunc(t *testing.T) {
 	}
 }
 
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		Host, Want string
+	}{
+		{"", ""},
+		{"pkg.go.dev", "pkg.go.dev"},
+		{"PKG.Go.Dev", "pkg.go.dev"},
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example"},
+		{"BÜCHER.example:8080", "xn--bcher-kva.example:8080"},
+		{"127.0.0.1:8080", "127.0.0.1:8080"},
+		{"[::1]:8080", "[::1]:8080"},
+		{"My_Host", "my_host"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeHost(tt.Host); got != tt.Want {
+			t.Fatalf("got %v, want %v", got, tt.Want)
+		}
+	}
+}
+
 func BenchmarkNewRules(b *testing.B) {
 	b.ReportAllocs()
 	for n := 0; n < b.N; n++ {
@@ -735,8 +2765,11 @@ var (
 			"":      "//script/@src", // ignore
 			"media": "",              // ignore
 		},
+		"Pipes": []any{"trim", []any{"replace", "a", "b"}},
+		"Deny":  `\.pdf$`,
 
-		"required": true,
+		"Required": "true",
+		"priority": "high",
 	}
 
 	testRawRules = map[string]any{
@@ -748,9 +2781,18 @@ var (
 		},
 		"Timeout": "2s",
 
-		"UseCookies":      "true",
-		"IgnoreRobotsTxt": true,
-		"Delay":           1,
+		"UseCookies":        "true",
+		"IgnoreRobotsTxt":   true,
+		"Delay":             1,
+		"MaxBodySize":       "1048576",
+		"MaxPages":          "3",
+		"MaxDepth":          2,
+		"MaxRequests":       "50",
+		"FollowConcurrency": 4,
+		"Render":            "true",
+		"RenderWait":        "2s",
+		"Allow":             []any{`^https://pkg\.go\.dev/`},
+		"TLS":               map[string]any{"InsecureSkipVerify": "true", "MinVersion": "1.2"},
 
 		"Selectors": map[string]any{
 			"head": testRawSelector,
@@ -758,6 +2800,8 @@ var (
 			"body": nil, // ignore
 		},
 
+		"Vars": map[string]any{"session": "S1"},
+
 		"id":    float64(123), // UnmarshalJSON
 		"token": "T456",
 	}
@@ -771,23 +2815,37 @@ var (
 		Selectors: []*Selector{
 			{Name: "title", Expr: "//title", Fields: make(map[string]any)},
 		},
+		Required: true,
+		Deny:     []*regexp.Regexp{regexp.MustCompile(`\.pdf$`)},
+		Pipes:    []Pipe{{Name: "trim"}, {Name: "replace", Args: []string{"a", "b"}}},
 		Fields: map[string]any{
-			"required": true,
+			"priority": "high",
 		},
 	}
 
 	testRules = &Rules{
-		Method:          "GET",
-		URL:             mustNewURL("https://pkg.go.dev"),
-		Proxy:           mustNewURL("http://proxy-url.com:8080"),
-		Header:          http.Header{"User-Agent": {"test/0.0.1"}},
-		Timeout:         2 * time.Second,
-		UseCookies:      true,
-		IgnoreRobotsTxt: true,
-		Delay:           1 * time.Millisecond,
+		Method:            "GET",
+		URL:               mustNewURL("https://pkg.go.dev"),
+		Proxy:             mustNewURL("http://proxy-url.com:8080"),
+		Header:            http.Header{"User-Agent": {"test/0.0.1"}},
+		Timeout:           2 * time.Second,
+		UseCookies:        true,
+		IgnoreRobotsTxt:   true,
+		Delay:             1 * time.Millisecond,
+		MaxBodySize:       1 << 20,
+		MaxPages:          3,
+		MaxDepth:          2,
+		MaxRequests:       50,
+		FollowConcurrency: 4,
+		Render:            true,
+		RenderWait:        2 * time.Second,
+		Allow:             []*regexp.Regexp{regexp.MustCompile(`^https://pkg\.go\.dev/`)},
+		TLS:               &TLS{InsecureSkipVerify: true, MinVersion: tls.VersionTLS12},
 
 		Selectors: []*Selector{testSelector},
 
+		Vars: NewVars(map[string]string{"session": "S1"}),
+
 		Fields: map[string]any{
 			"id":    float64(123), // UnmarshalJSON
 			"token": "T456",
@@ -795,6 +2853,94 @@ var (
 	}
 )
 
+type testReporterClient struct {
+	testClient
+}
+
+func (c *testReporterClient) ReportCapabilities(caps *Capabilities) {
+	caps.Features = append(caps.Features, "test")
+}
+
+func TestForward(t *testing.T) {
+	u := mustNewURL("https://example.com/a")
+
+	t.Run("ClientDo", func(t *testing.T) {
+		client := &testClient{}
+		if _, err := ClientDo(context.Background(), client, New(), &Rules{URL: u}); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := ClientDo(ctx, client, New(), &Rules{URL: u}); !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("Robots", func(t *testing.T) {
+		tests := []struct {
+			Robots     RobotsTxt
+			CrawlDelay time.Duration
+			Sitemaps   []string
+		}{
+			{&testRobots{}, 0, nil},
+			{&testCrawlDelayRobots{crawlDelay: time.Second}, time.Second, nil},
+			{&testSitemapRobots{sitemaps: []string{"https://example.com/sitemap.xml"}}, 0, []string{"https://example.com/sitemap.xml"}},
+		}
+
+		for _, tt := range tests {
+			if got := RobotsCrawlDelay(tt.Robots, u, "test"); got != tt.CrawlDelay {
+				t.Fatalf("got %v, want %v", got, tt.CrawlDelay)
+			}
+
+			if got := RobotsSitemaps(tt.Robots, "example.com"); !reflect.DeepEqual(got, tt.Sitemaps) {
+				t.Fatalf("got %v, want %v", got, tt.Sitemaps)
+			}
+
+			if err := RobotsIsAllowed(context.Background(), tt.Robots, New(), &Rules{URL: u}); err != nil {
+				t.Fatalf("got %v, want %v", err, nil)
+			}
+		}
+	})
+
+	t.Run("Delay", func(t *testing.T) {
+		delay := &testDelay{}
+		if err := DelayWait(context.Background(), delay, u, time.Second); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		} else if !delay.WaitUsed {
+			t.Fatal("Wait not used")
+		}
+
+		blocking := &testBlockingDelay{release: make(chan struct{}), done: make(chan struct{})}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := DelayWait(ctx, blocking, u, time.Second); !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, want %v", err, context.Canceled)
+		}
+
+		// Done is called when the abandoned wait returns
+		close(blocking.release)
+		<-blocking.done
+
+		observer := &testObserverDelay{}
+		DelayObserve(observer, u, &testHeaderResp{statusCode: 304}, 0, nil)
+		DelayObserve(&testDelay{}, u, nil, 0, nil)
+		if observer.statusCode != 304 {
+			t.Fatalf("got %v, want %v", observer.statusCode, 304)
+		}
+	})
+
+	t.Run("ReportCapabilitiesOf", func(t *testing.T) {
+		var caps Capabilities
+		ReportCapabilitiesOf(&testReporterClient{}, &caps)
+		ReportCapabilitiesOf(&testClient{}, &caps)
+		if !reflect.DeepEqual(caps.Features, []string{"test"}) {
+			t.Fatalf("got %v, want %v", caps.Features, []string{"test"})
+		}
+	})
+}
+
 type testResp struct{}
 
 func (resp *testResp) URL() *url.URL       { return nil }
@@ -822,6 +2968,16 @@ func (c *testClient) Do(_ *Colibri, rules *Rules) (Response, error) {
 }
 func (c *testClient) Clear() { c.ClearUsed = true }
 
+type testRulesClient struct {
+	rules *Rules
+}
+
+func (c *testRulesClient) Do(_ *Colibri, rules *Rules) (Response, error) {
+	c.rules = rules
+	return &testResp{}, nil
+}
+func (c *testRulesClient) Clear() {}
+
 type testDelay struct {
 	WaitUsed, DoneUsed, StampUsed, ClearUsed bool
 }
@@ -855,16 +3011,26 @@ func (r *testRobots) Clear() {
 
 type testParser struct {
 	ParseUsed, ClearUsed bool
+
+	// done is closed when Parse returns, if it is not nil.
+	done chan struct{}
 }
 
 func (p *testParser) Match(_ string) bool { return false }
 func (p *testParser) Parse(rules *Rules, _ Response) (map[string]any, error) {
 	p.ParseUsed = true
+	if p.done != nil {
+		defer close(p.done)
+	}
 
 	if err := rules.Fields["parserErr"]; err != nil {
 		return nil, err.(error)
 	} else if v := rules.Fields["parserPanic"]; v != nil {
 		panic(v)
+	} else if d, ok := rules.Fields["parserSleep"].(time.Duration); ok {
+		time.Sleep(d)
+	} else if output, ok := rules.Fields["output"].(map[string]any); ok {
+		return output, nil
 	}
 	return make(map[string]any), nil
 }
@@ -872,6 +3038,25 @@ func (p *testParser) Clear() {
 	p.ClearUsed = true
 }
 
+type testMoney struct {
+	Amount, Currency string
+}
+
+func (m *testMoney) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case string:
+		m.Amount, m.Currency, _ = strings.Cut(v, " ")
+	case float64:
+		m.Amount = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return nil
+}
+
 func mustNewURL(rawURL string) *url.URL {
 	u, _ := url.Parse(rawURL)
 	return u
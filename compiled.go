@@ -0,0 +1,99 @@
+package colibri
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// CompiledRules are rules validated and processed once, so they can be executed many times
+// without processing the raw rules again, e.g. by the workers of a crawl.
+// The URLs are parsed, the regular expressions are compiled, the keys of the header
+// are canonicalized and the method is upper case.
+// CompiledRules are serialized with the format of the raw rules, see Rules.Raw.
+// They are safe for concurrent use, each request must use its own copy, see Rules.
+type CompiledRules struct {
+	rules *Rules
+}
+
+// Compile returns the raw rules compiled using DefaultConvFunc.
+func Compile(rawRules RawRules) (*CompiledRules, error) {
+	return CompileWithConvFunc(rawRules, DefaultConvFunc)
+}
+
+// CompileWithConvFunc returns the compiled raw rules, see NewRulesWithConvFunc.
+func CompileWithConvFunc(rawRules RawRules, convFunc ConvFunc) (*CompiledRules, error) {
+	rules, err := NewRulesWithConvFunc(rawRules, convFunc)
+	if err != nil {
+		ReleaseRules(rules)
+		return nil, err
+	}
+
+	rules.Method = strings.ToUpper(rules.Method)
+	return &CompiledRules{rules: rules}, nil
+}
+
+// CompileAll compiles a batch of raw rules using DefaultConvFunc.
+// The errors are returned with the indexes of the raw rules as keys,
+// the compiled rules of the raw rules with errors are nil.
+func CompileAll(rawRules []RawRules) ([]*CompiledRules, error) {
+	var (
+		compiled = make([]*CompiledRules, len(rawRules))
+		errs     error
+	)
+	for i, raw := range rawRules {
+		var err error
+		if compiled[i], err = Compile(raw); err != nil {
+			errs = AddError(errs, strconv.Itoa(i), err)
+		}
+	}
+	return compiled, errs
+}
+
+// Compile compiles the raw rules like Compile and, if the active Parser implements ExprCompiler,
+// precompiles the expressions of the selectors, so the invalid expressions are
+// reported before the requests.
+func (c *Colibri) Compile(rawRules RawRules) (*CompiledRules, error) {
+	compiled, err := Compile(rawRules)
+	if err != nil {
+		return nil, err
+	}
+
+	if exprCompiler, ok := c.activeParser().(ExprCompiler); ok {
+		if err := exprCompiler.Compile(compiled.rules); err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
+// Rules returns a copy of the compiled rules, the copy shares the Vars.
+func (compiled *CompiledRules) Rules() *Rules {
+	return compiled.rules.Clone()
+}
+
+// Raw returns the raw rules of the compiled rules, see Rules.Raw.
+func (compiled *CompiledRules) Raw() RawRules {
+	return compiled.rules.Raw()
+}
+
+// MarshalJSON encodes the compiled rules with the format of the raw rules.
+func (compiled *CompiledRules) MarshalJSON() ([]byte, error) {
+	return json.Marshal(compiled.Raw())
+}
+
+// UnmarshalJSON decodes and compiles the raw rules.
+func (compiled *CompiledRules) UnmarshalJSON(b []byte) error {
+	rawRules := make(RawRules)
+	if err := json.Unmarshal(b, &rawRules); err != nil {
+		return err
+	}
+
+	newCompiled, err := Compile(rawRules)
+	if err != nil {
+		return err
+	}
+
+	*compiled = *newCompiled
+	return nil
+}
@@ -0,0 +1,199 @@
+// compress compresses the state persisted by Colibri (visited URLs, cached responses and snapshots)
+// with pluggable codecs. The codec of the compressed data is detected by its magic number,
+// so the state written with any registered codec, or without compression, can be read.
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrUnknownCodec is returned when the codec with the name is not registered.
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// Writer compresses the data written to it.
+type Writer interface {
+	io.WriteCloser
+
+	// Flush writes the pending data, so it can be read even if the Writer is not closed.
+	Flush() error
+}
+
+// Codec compresses and decompresses data.
+// The codecs must support concatenated streams, so the data appended
+// to a file with different Writers is read with a single Reader.
+type Codec interface {
+	// Name returns the name of the codec, e.g. "gzip".
+	Name() string
+
+	// Magic returns the first bytes of the compressed data, used to detect the codec.
+	Magic() []byte
+
+	// NewWriter returns a Writer that writes the compressed data to w.
+	NewWriter(w io.Writer) (Writer, error)
+
+	// NewReader returns a reader of the data decompressed from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	// Gzip is the gzip codec of the standard library.
+	Gzip Codec = gzipCodec{}
+
+	// Zstd is the zstd codec, faster than Gzip with a similar compression ratio.
+	Zstd Codec = zstdCodec{}
+)
+
+// Default is the codec used by default by the stores that compress their state.
+var Default = Zstd
+
+var (
+	rw     sync.RWMutex
+	codecs = map[string]Codec{Gzip.Name(): Gzip, Zstd.Name(): Zstd}
+)
+
+// Register registers the codec, so the data compressed with it is detected by NewReader.
+// Replaces the codec registered with the same name.
+func Register(codec Codec) {
+	rw.Lock()
+	codecs[codec.Name()] = codec
+	rw.Unlock()
+}
+
+// Lookup returns the codec registered with the name.
+func Lookup(name string) (Codec, error) {
+	rw.RLock()
+	defer rw.RUnlock()
+
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, ErrUnknownCodec
+	}
+	return codec, nil
+}
+
+// Detect returns the registered codec whose magic number is the prefix of the data, nil if none.
+func Detect(data []byte) Codec {
+	rw.RLock()
+	defer rw.RUnlock()
+
+	for _, codec := range codecs {
+		if magic := codec.Magic(); (len(magic) > 0) && bytes.HasPrefix(data, magic) {
+			return codec
+		}
+	}
+	return nil
+}
+
+// NewReader returns a reader of the data of r decompressed with the detected codec,
+// the data that is not compressed with a registered codec is read as is.
+// Returns the codec detected, nil if the data is not compressed.
+func NewReader(r io.Reader) (io.ReadCloser, Codec, error) {
+	br := bufio.NewReader(r)
+	prefix, _ := br.Peek(maxMagic())
+
+	codec := Detect(prefix)
+	if codec == nil {
+		return io.NopCloser(br), nil, nil
+	}
+
+	cr, err := codec.NewReader(br)
+	return cr, codec, err
+}
+
+// Compress returns the data compressed with the codec, the data as is if the codec is nil.
+func Compress(codec Codec, data []byte) ([]byte, error) {
+	if codec == nil {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	w, err := codec.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress returns the data decompressed with the detected codec, see NewReader.
+func Decompress(data []byte) ([]byte, error) {
+	codec := Detect(data)
+	if codec == nil {
+		return data, nil
+	}
+
+	r, err := codec.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// maxMagic returns the length of the longest magic number.
+func maxMagic() int {
+	rw.RLock()
+	defer rw.RUnlock()
+
+	n := 0
+	for _, codec := range codecs {
+		n = max(n, len(codec.Magic()))
+	}
+	return n
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (gzipCodec) Magic() []byte {
+	return []byte{0x1f, 0x8b}
+}
+
+func (gzipCodec) NewWriter(w io.Writer) (Writer, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string {
+	return "zstd"
+}
+
+func (zstdCodec) Magic() []byte {
+	return []byte{0x28, 0xb5, 0x2f, 0xfd}
+}
+
+func (zstdCodec) NewWriter(w io.Writer) (Writer, error) {
+	return zstd.NewWriter(w)
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
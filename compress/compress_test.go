@@ -0,0 +1,134 @@
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	data := []byte(`{"Visited":"GET https://example.com/"}` + "\n")
+
+	if Default != Zstd {
+		t.Fatalf("got %v, want %v", Default, Zstd)
+	}
+
+	compressed := make(map[Codec][]byte)
+	for _, codec := range []Codec{Gzip, Zstd} {
+		b, err := Compress(codec, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := Detect(b); got != codec {
+			t.Fatalf("got %v, want %v", got, codec)
+		}
+		compressed[codec] = b
+	}
+
+	tests := []struct {
+		Data      []byte
+		Want      []byte
+		WantCodec Codec
+	}{
+		{compressed[Gzip], data, Gzip},
+		{compressed[Zstd], data, Zstd},
+		{data, data, nil},
+		{[]byte("a"), []byte("a"), nil},
+	}
+
+	for _, tt := range tests {
+		got, err := Decompress(tt.Data)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(got, tt.Want) {
+			t.Fatalf("got %q, want %q", got, tt.Want)
+		}
+
+		r, codec, err := NewReader(bytes.NewReader(tt.Data))
+		if err != nil {
+			t.Fatal(err)
+		} else if codec != tt.WantCodec {
+			t.Fatalf("got %v, want %v", codec, tt.WantCodec)
+		}
+
+		got, err = io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(got, tt.Want) {
+			t.Fatalf("got %q, want %q", got, tt.Want)
+		}
+	}
+}
+
+func TestConcatenatedStreams(t *testing.T) {
+	for _, codec := range []Codec{Gzip, Zstd} {
+		var buf bytes.Buffer
+		for _, line := range []string{"a\n", "b\n"} {
+			w, err := codec.NewWriter(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(w, line)
+			w.Close()
+		}
+
+		got, err := Decompress(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		} else if string(got) != "a\nb\n" {
+			t.Fatalf("%v: got %q, want %q", codec.Name(), got, "a\nb\n")
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if _, err := Lookup("test"); !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("got %v, want %v", err, ErrUnknownCodec)
+	}
+
+	Register(testCodec{})
+	codec, err := Lookup("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed, err := Compress(codec, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Decompress(compressed)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(got) != "data" {
+		t.Fatalf("got %q, want %q", got, "data")
+	}
+}
+
+// testCodec is a raw deflate stream prefixed with a magic number.
+type testCodec struct{}
+
+func (testCodec) Name() string {
+	return "test"
+}
+
+func (testCodec) Magic() []byte {
+	return []byte("TST")
+}
+
+func (codec testCodec) NewWriter(w io.Writer) (Writer, error) {
+	if _, err := w.Write(codec.Magic()); err != nil {
+		return nil, err
+	}
+	return flate.NewWriter(w, flate.DefaultCompression)
+}
+
+func (codec testCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	if _, err := io.ReadFull(r, make([]byte, len(codec.Magic()))); err != nil {
+		return nil, err
+	}
+	return flate.NewReader(r), nil
+}
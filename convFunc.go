@@ -2,11 +2,16 @@ package colibri
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
+
+	"golang.org/x/net/idna"
 )
 
 var (
@@ -16,11 +21,26 @@ var (
 	// ErrMustBeConvDuration is returned when the value is not convertible to time.Duration.
 	ErrMustBeConvDuration = errors.New("must be a string or number")
 
+	// ErrMustBeConvInt is returned when the value is not convertible to int.
+	ErrMustBeConvInt = errors.New("must be an integer string or number")
+
+	// ErrMustBeConvStatusCodes is returned when the value is not convertible to a list of status codes.
+	ErrMustBeConvStatusCodes = errors.New("must be a status code or a list of status codes")
+
 	// ErrMustBeString is returned when the value must be a string.
 	ErrMustBeString = errors.New("must be a string")
 
 	// ErrInvalidHeader is returned when the header is invalid.
 	ErrInvalidHeader = errors.New("invalid header")
+
+	// ErrMustBeConvPipes is returned when the value is not convertible to a list of pipes.
+	ErrMustBeConvPipes = errors.New("must be a pipe name or a list of pipes")
+
+	// ErrMustBeConvRegexps is returned when the value is not convertible to a list of regular expressions.
+	ErrMustBeConvRegexps = errors.New("must be a regular expression or a list of regular expressions")
+
+	// ErrMustBeConvVars is returned when the value is not convertible to Vars.
+	ErrMustBeConvVars = errors.New("must be a map of variables or a list of names")
 )
 
 // ConvFunc processes the value based on the key.
@@ -32,28 +52,85 @@ func DefaultConvFunc(key string, rawValue any) (any, error) {
 	case KeyURL, KeyProxy:
 		return ToURL(rawValue)
 
-	case KeyIgnoreRobotsTxt, KeyFollow, KeyUseCookies, KeyAll:
+	case KeyIgnoreRobotsTxt, KeyFollow, KeyUseCookies, KeyAll, KeyRequired, KeyRender, KeyDisableCompression, KeyNext:
 		return toBool(rawValue)
 
-	case KeyDelay, KeyTimeout:
+	case KeyDelay, KeyTimeout, KeyParseTimeout, KeyRetryBackoff, KeyMaxWait, KeyRenderWait:
 		return toDuration(rawValue)
 
+	case KeyRetries, KeyMaxPages, KeyMaxDepth, KeyMaxRequests, KeyFollowConcurrency:
+		return toInt(rawValue)
+
+	case KeyMaxBodySize:
+		size, err := toInt(rawValue)
+		return int64(size), err
+
+	case KeyRetryOn:
+		return toStatusCodes(rawValue)
+
 	case KeyHeader:
 		return toHeader(rawValue)
 
 	case KeySelectors:
 		return newSelectors(rawValue, DefaultConvFunc)
+
+	case KeyPipes:
+		return toPipes(rawValue)
+
+	case KeyVariants:
+		return newVariants(rawValue, DefaultConvFunc)
+
+	case KeyVars:
+		return toVars(rawValue)
+
+	case KeyAllow, KeyDeny:
+		return toRegexps(rawValue)
+
+	case KeyTLS:
+		return toTLS(rawValue)
 	}
 	return rawValue, nil
 }
 
 // ToURL converts a value to a *url.URL.
+// The host is normalized with NormalizeHost.
 func ToURL(value any) (*url.URL, error) {
 	rawURL, ok := value.(string)
-	if ok {
-		return url.Parse(rawURL)
+	if !ok {
+		return nil, ErrMustBeString
 	}
-	return nil, ErrMustBeString
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Host = NormalizeHost(u.Host)
+	return u, nil
+}
+
+// NormalizeHost returns the host in lowercase with the internationalized
+// domain names converted to ASCII (punycode), the port is preserved.
+// If the host is not a valid domain name, it is returned in lowercase.
+func NormalizeHost(host string) string {
+	if host == "" {
+		return host
+	}
+
+	hostname, port := host, ""
+	if i := strings.LastIndexByte(host, ':'); (i >= 0) && !strings.HasSuffix(host, "]") {
+		hostname, port = host[:i], host[i:]
+	}
+
+	if strings.HasPrefix(hostname, "[") {
+		return strings.ToLower(host)
+	}
+
+	ascii, err := idna.Lookup.ToASCII(hostname)
+	if err != nil {
+		return strings.ToLower(host)
+	}
+	return ascii + port
 }
 
 // toBool converts a value to a boolean.
@@ -106,6 +183,208 @@ func toDuration(value any) (time.Duration, error) {
 }
 
 // toHeader converts a value to a http.Header.
+// toInt converts a value to an int.
+func toInt(value any) (int, error) {
+	if value == nil {
+		return 0, nil
+	}
+
+	switch rValue := reflect.ValueOf(value); rValue.Kind() {
+	case reflect.String:
+		return strconv.Atoi(value.(string))
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rValue.Int()), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(rValue.Uint()), nil
+
+	case reflect.Float32, reflect.Float64:
+		f := rValue.Float()
+		if f == float64(int(f)) {
+			return int(f), nil
+		}
+	}
+
+	return 0, ErrMustBeConvInt
+}
+
+// toStatusCodes converts a value to a list of HTTP status codes.
+func toStatusCodes(value any) ([]int, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	values, ok := value.([]any)
+	if !ok {
+		if codes, ok := value.([]int); ok {
+			values = make([]any, 0, len(codes))
+			for _, code := range codes {
+				values = append(values, code)
+			}
+		} else {
+			values = []any{value}
+		}
+	}
+
+	codes := make([]int, 0, len(values))
+	for _, v := range values {
+		code, err := toInt(v)
+		if (err != nil) || (code < 100) || (code > 999) {
+			return nil, ErrMustBeConvStatusCodes
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// toRegexps converts a value to a list of regular expressions,
+// a regular expression or a list of regular expressions.
+func toRegexps(value any) ([]*regexp.Regexp, error) {
+	var patterns []any
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+
+	case []*regexp.Regexp:
+		return v, nil
+
+	case string:
+		patterns = []any{v}
+
+	case []string:
+		for _, e := range v {
+			patterns = append(patterns, e)
+		}
+
+	case []any:
+		patterns = v
+
+	default:
+		return nil, ErrMustBeConvRegexps
+	}
+
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, e := range patterns {
+		pattern, ok := e.(string)
+		if !ok {
+			return nil, ErrMustBeConvRegexps
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// toVars converts a value to Vars, a map with the initial values
+// of the variables or a list with the names of the variables.
+func toVars(value any) (*Vars, error) {
+	values := make(map[string]string)
+	switch v := value.(type) {
+	case *Vars:
+		return v, nil
+
+	case map[string]string:
+		return NewVars(v), nil
+
+	case map[string]any:
+		for name, rawValue := range v {
+			if rawValue == nil {
+				values[name] = ""
+				continue
+			}
+			values[name] = fmt.Sprint(rawValue)
+		}
+
+	case []string:
+		for _, name := range v {
+			values[name] = ""
+		}
+
+	case []any:
+		for _, rawName := range v {
+			name, ok := rawName.(string)
+			if !ok {
+				return nil, ErrMustBeConvVars
+			}
+			values[name] = ""
+		}
+
+	default:
+		return nil, ErrMustBeConvVars
+	}
+	return NewVars(values), nil
+}
+
+// toPipes converts a value to a list of pipes.
+// Each pipe is the name of the operation or a list with the name and the arguments.
+func toPipes(value any) ([]Pipe, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+
+	case []Pipe:
+		return v, nil
+
+	case string:
+		if v == "" {
+			return nil, ErrMustBeConvPipes
+		}
+		return []Pipe{{Name: v}}, nil
+
+	case []any:
+		pipes := make([]Pipe, 0, len(v))
+		for _, rawPipe := range v {
+			pipe, err := toPipe(rawPipe)
+			if err != nil {
+				return nil, err
+			}
+			pipes = append(pipes, pipe)
+		}
+		return pipes, nil
+	}
+	return nil, ErrMustBeConvPipes
+}
+
+func toPipe(value any) (Pipe, error) {
+	var values []any
+	switch v := value.(type) {
+	case string:
+		values = []any{v}
+
+	case []string:
+		for _, e := range v {
+			values = append(values, e)
+		}
+
+	case []any:
+		values = v
+	}
+
+	if len(values) == 0 {
+		return Pipe{}, ErrMustBeConvPipes
+	}
+
+	var pipe Pipe
+	for i, e := range values {
+		str, ok := e.(string)
+		if !ok || ((i == 0) && (str == "")) {
+			return Pipe{}, ErrMustBeConvPipes
+		}
+
+		if i == 0 {
+			pipe.Name = str
+			continue
+		}
+		pipe.Args = append(pipe.Args, str)
+	}
+	return pipe, nil
+}
+
 func toHeader(value any) (http.Header, error) {
 	if value == nil {
 		return http.Header{}, nil
@@ -138,6 +417,17 @@ func toHeader(value any) (http.Header, error) {
 				header.Add(key, e)
 			}
 			continue
+
+		case []any:
+			key := k.String()
+			for _, e := range value {
+				str, ok := e.(string)
+				if !ok {
+					return header, ErrInvalidHeader
+				}
+				header.Add(key, str)
+			}
+			continue
 		}
 
 		return header, ErrInvalidHeader
@@ -0,0 +1,274 @@
+// cost accounts the resources used by the scraping jobs (requests, bytes, proxy and render time)
+// and calculates their cost with pluggable cost models, to bill or budget the jobs.
+package cost
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+// ErrBudgetExceeded is returned when the cost of the job exceeds its budget.
+var ErrBudgetExceeded = errors.New("job budget exceeded")
+
+type jobKey struct{}
+
+// WithJob returns a copy of the context with the job, the requests made with the context
+// and with the responses obtained with it are accounted to the job.
+func WithJob(ctx context.Context, job string) context.Context {
+	return context.WithValue(ctx, jobKey{}, job)
+}
+
+// JobFromContext returns the job of the context, empty if it has none.
+func JobFromContext(ctx context.Context) string {
+	job, _ := ctx.Value(jobKey{}).(string)
+	return job
+}
+
+// Usage represents the resources used by a job.
+type Usage struct {
+	// Requests number of HTTP requests made.
+	Requests int64 `json:"requests"`
+
+	// Bytes number of bytes of the response bodies read.
+	Bytes int64 `json:"bytes"`
+
+	// ProxyTime time of the requests made through a proxy.
+	ProxyTime time.Duration `json:"proxyTime"`
+
+	// RenderTime time of the requests rendered in a browser, see colibri.Rules.Render.
+	RenderTime time.Duration `json:"renderTime"`
+}
+
+// Add returns the sum of the usages.
+func (usage Usage) Add(other Usage) Usage {
+	return Usage{
+		Requests:   usage.Requests + other.Requests,
+		Bytes:      usage.Bytes + other.Bytes,
+		ProxyTime:  usage.ProxyTime + other.ProxyTime,
+		RenderTime: usage.RenderTime + other.RenderTime,
+	}
+}
+
+// Model calculates the cost of the resources used by a job.
+type Model interface {
+	// Cost returns the cost of the usage of the job.
+	Cost(job string, usage Usage) float64
+}
+
+// ModelFunc is a function that implements Model.
+type ModelFunc func(job string, usage Usage) float64
+
+func (fn ModelFunc) Cost(job string, usage Usage) float64 {
+	return fn(job, usage)
+}
+
+// Rates is a Model with a price per unit of each resource.
+type Rates struct {
+	// PerRequest price of each request.
+	PerRequest float64
+
+	// PerMB price of each megabyte (1 << 20 bytes) downloaded.
+	PerMB float64
+
+	// PerProxyMinute price of each minute of requests made through a proxy.
+	PerProxyMinute float64
+
+	// PerRenderSecond price of each second of rendering in a browser.
+	PerRenderSecond float64
+}
+
+func (rates Rates) Cost(_ string, usage Usage) float64 {
+	return float64(usage.Requests)*rates.PerRequest +
+		float64(usage.Bytes)/(1<<20)*rates.PerMB +
+		usage.ProxyTime.Minutes()*rates.PerProxyMinute +
+		usage.RenderTime.Seconds()*rates.PerRenderSecond
+}
+
+// Accountant accounts the resources used by the jobs.
+type Accountant struct {
+	// Model calculates the cost of the jobs, if nil the cost is zero.
+	Model Model
+
+	// Budgets maximum cost of the jobs, by job. The requests of a job whose
+	// cost reached its budget return ErrBudgetExceeded without being made.
+	// The jobs without budget are not limited.
+	Budgets map[string]float64
+
+	rw    sync.RWMutex
+	usage map[string]*Usage
+}
+
+// New returns a new Accountant with the cost model.
+func New(model Model) *Accountant {
+	return &Accountant{Model: model, usage: make(map[string]*Usage)}
+}
+
+// Wrap replaces the Client of Colibri with a wrapper that accounts the requests.
+// The job of each request is taken from its context, see WithJob.
+func (accountant *Accountant) Wrap(c *colibri.Colibri) {
+	if c.Client != nil {
+		c.Client = &Client{HTTPClient: c.Client, Accountant: accountant}
+	}
+}
+
+// Add adds the usage to the job.
+func (accountant *Accountant) Add(job string, usage Usage) {
+	accountant.rw.Lock()
+	defer accountant.rw.Unlock()
+
+	if accountant.usage == nil {
+		accountant.usage = make(map[string]*Usage)
+	}
+
+	current, ok := accountant.usage[job]
+	if !ok {
+		current = &Usage{}
+		accountant.usage[job] = current
+	}
+	*current = current.Add(usage)
+}
+
+// Usage returns the resources used by the job.
+func (accountant *Accountant) Usage(job string) Usage {
+	accountant.rw.RLock()
+	defer accountant.rw.RUnlock()
+
+	if usage, ok := accountant.usage[job]; ok {
+		return *usage
+	}
+	return Usage{}
+}
+
+// Cost returns the cost of the job calculated with the Model.
+func (accountant *Accountant) Cost(job string) float64 {
+	if accountant.Model == nil {
+		return 0
+	}
+	return accountant.Model.Cost(job, accountant.Usage(job))
+}
+
+// Jobs returns the jobs with usage sorted in increasing order.
+func (accountant *Accountant) Jobs() []string {
+	accountant.rw.RLock()
+	jobs := make([]string, 0, len(accountant.usage))
+	for job := range accountant.usage {
+		jobs = append(jobs, job)
+	}
+	accountant.rw.RUnlock()
+
+	sort.Strings(jobs)
+	return jobs
+}
+
+// Reset removes the usage of the job, e.g. at the start of a new billing period.
+func (accountant *Accountant) Reset(job string) {
+	accountant.rw.Lock()
+	delete(accountant.usage, job)
+	accountant.rw.Unlock()
+}
+
+// Clear removes the usage of all the jobs.
+func (accountant *Accountant) Clear() {
+	accountant.rw.Lock()
+	clear(accountant.usage)
+	accountant.rw.Unlock()
+}
+
+// checkBudget returns ErrBudgetExceeded if the cost of the job reached its budget.
+func (accountant *Accountant) checkBudget(job string) error {
+	accountant.rw.RLock()
+	budget, ok := accountant.Budgets[job]
+	accountant.rw.RUnlock()
+
+	if ok && (accountant.Cost(job) >= budget) {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+// Client accounts the requests to the job of their context.
+// See the colibri.HTTPClient interface.
+type Client struct {
+	colibri.HTTPClient
+	Accountant *Accountant
+}
+
+func (client *Client) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	return client.DoContext(context.Background(), c, rules)
+}
+
+// DoContext calls DoContext of the wrapped HTTPClient if it implements
+// colibri.HTTPClientContext, otherwise Do is called. See colibri.ClientDo.
+func (client *Client) DoContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	job := JobFromContext(ctx)
+	if err := client.Accountant.checkBudget(job); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := colibri.ClientDo(ctx, client.HTTPClient, c, rules)
+
+	usage := Usage{Requests: 1}
+	if rules.Proxy != nil {
+		usage.ProxyTime = time.Since(start)
+	}
+
+	if rules.Render {
+		usage.RenderTime = time.Since(start)
+	}
+	client.Accountant.Add(job, usage)
+
+	if (err != nil) || (resp == nil) || (resp.Body() == nil) {
+		return resp, err
+	}
+	return client.countBody(job, resp), nil
+}
+
+// ReportCapabilities adds the "cost" feature and the capabilities of the wrapped HTTPClient to the report.
+// See the colibri.CapabilityReporter interface.
+func (client *Client) ReportCapabilities(caps *colibri.Capabilities) {
+	colibri.ReportCapabilitiesOf(client.HTTPClient, caps)
+	caps.Features = append(caps.Features, "cost")
+}
+
+// countBody replaces the body of the response with a body that accounts the bytes read.
+func (client *Client) countBody(job string, resp colibri.Response) colibri.Response {
+	body := &countedBody{ReadCloser: resp.Body(), job: job, accountant: client.Accountant}
+	if r, ok := resp.(*webextractor.Response); ok {
+		r.HTTP.Body = body
+		return r
+	}
+	return &countedResponse{Response: resp, body: body}
+}
+
+// countedResponse is a response whose body accounts the bytes read.
+type countedResponse struct {
+	colibri.Response
+	body io.ReadCloser
+}
+
+func (resp *countedResponse) Body() io.ReadCloser {
+	return resp.body
+}
+
+// countedBody accounts the bytes read to the job.
+type countedBody struct {
+	io.ReadCloser
+	job        string
+	accountant *Accountant
+}
+
+func (body *countedBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	if n > 0 {
+		body.accountant.Add(body.job, Usage{Bytes: int64(n)})
+	}
+	return n, err
+}
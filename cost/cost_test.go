@@ -0,0 +1,116 @@
+package cost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+const testBody = "<html><head><title>Cost</title></head></html>"
+
+func TestAccountant(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, testBody)
+	}))
+	defer ts.Close()
+
+	we, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	accountant := New(Rates{PerRequest: 1, PerMB: 1 << 20})
+	accountant.Budgets = map[string]float64{"limited": 1}
+	accountant.Wrap(we)
+
+	tests := []struct {
+		Job   string
+		Proxy bool
+		Err   error
+	}{
+		{"tenant-a", false, nil},
+		{"tenant-a", true, nil},
+		{"tenant-b", false, nil},
+		{"limited", false, nil},
+		{"limited", false, ErrBudgetExceeded},
+	}
+
+	for _, tt := range tests {
+		rules := &colibri.Rules{
+			Method:          "GET",
+			URL:             mustNewURL(ts.URL + "/html"),
+			IgnoreRobotsTxt: true,
+			Selectors:       []*colibri.Selector{{Name: "title", Expr: "//title"}},
+		}
+
+		if tt.Proxy {
+			rules.URL = mustNewURL("http://example.test/html")
+			rules.Proxy = mustNewURL(ts.URL)
+		}
+
+		_, output, err := we.ExtractContext(WithJob(context.Background(), tt.Job), rules)
+		if !errors.Is(err, tt.Err) {
+			t.Fatalf("got %v, want %v", err, tt.Err)
+		} else if (err == nil) && (output["title"] != "Cost") {
+			t.Fatalf("got %v, want %v", output["title"], "Cost")
+		}
+	}
+
+	if got, want := accountant.Jobs(), []string{"limited", "tenant-a", "tenant-b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	usage := accountant.Usage("tenant-a")
+	if (usage.Requests != 2) || (usage.Bytes != 2*int64(len(testBody))) {
+		t.Fatalf("got %+v, want %v requests and %v bytes", usage, 2, 2*len(testBody))
+	} else if (usage.ProxyTime <= 0) || (usage.RenderTime != 0) {
+		t.Fatalf("got %+v, want proxy time", usage)
+	}
+
+	if got, want := accountant.Cost("tenant-b"), float64(1+len(testBody)); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	accountant.Reset("limited")
+	if got := accountant.Usage("limited"); got != (Usage{}) {
+		t.Fatalf("got %v, want %v", got, Usage{})
+	}
+}
+
+func TestRates(t *testing.T) {
+	rates := Rates{PerRequest: 0.001, PerMB: 0.5, PerProxyMinute: 0.2, PerRenderSecond: 0.01}
+	usage := Usage{Requests: 1000, Bytes: 4 << 20, ProxyTime: 5 * time.Minute, RenderTime: 100 * time.Second}
+
+	if got, want := rates.Cost("job", usage), 1+2+1+1.0; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	model := ModelFunc(func(job string, usage Usage) float64 {
+		if job == "free" {
+			return 0
+		}
+		return rates.Cost(job, usage)
+	})
+	if got := model.Cost("free", usage); got != 0 {
+		t.Fatalf("got %v, want %v", got, 0)
+	}
+}
+
+func mustNewURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
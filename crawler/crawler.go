@@ -0,0 +1,454 @@
+// crawler crawls websites with Colibri using a frontier queue and a pool of workers.
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/storage"
+)
+
+// DefaultWorkers default number of workers.
+const DefaultWorkers = 4
+
+var (
+	// ErrColibriIsNil is returned when Colibri is nil.
+	ErrColibriIsNil = errors.New("Colibri is nil")
+
+	// ErrRunning is returned when Run is called while the crawler is running.
+	ErrRunning = errors.New("crawler is running")
+)
+
+// Page represents a page processed by the crawler.
+type Page struct {
+	// Rules used to request the page.
+	Rules *colibri.Rules
+
+	// Depth number of follows from the seed.
+	Depth int
+
+	// Parent URL of the page in which the URL of the page was found, nil for the seeds.
+	Parent *url.URL
+
+	// Selector name of the Follow selector that found the URL of the page, empty for the seeds.
+	Selector string
+
+	// Response of the request.
+	Response colibri.Response
+
+	// Output data extracted with the selectors.
+	// The values of the Follow selectors are the URLs found.
+	Output map[string]any
+
+	// Err error of the request or the extraction (if any).
+	Err error
+
+	// TraceID is the trace ID of the extraction of the page, see colibri.WithTraceID.
+	TraceID string
+}
+
+// Crawler crawls the seeds and the URLs found by their Follow selectors.
+// Unlike Colibri.Extract, the URLs of the Follow selectors are not requested
+// during the extraction, they are added to the frontier queue and processed
+// by the workers as new pages with the nested selectors.
+// The pages of each host are processed in order and the hosts in round-robin,
+// so a slow host does not stop the crawl of the other hosts.
+type Crawler struct {
+	// Colibri used to extract the pages.
+	Colibri *colibri.Colibri
+
+	// Workers number of pages processed concurrently.
+	// If zero, DefaultWorkers is used.
+	Workers int
+
+	// MaxDepth maximum number of follows from the seeds.
+	// Zero means no limit.
+	MaxDepth int
+
+	// OnPage is called for each page processed, it must be safe for concurrent use.
+	OnPage func(page *Page)
+
+	// Storage stores the URLs processed, they are not requested again by the crawls
+	// that use the same Storage, e.g. after a restart. See Pending.
+	// If nil, the URLs are only deduplicated during each crawl.
+	Storage storage.Storage
+
+	// Windows time windows in which the pages are requested, see Window.
+	// Outside the windows the crawl is paused and it is resumed when a window opens.
+	// If empty, the pages are requested at any time.
+	Windows []Window
+
+	// HostWindows time windows of the hosts, by host name, used instead of Windows
+	// for the pages of the host. The host names are normalized, see colibri.NormalizeHost.
+	HostWindows map[string][]Window
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   frontier
+	active  int
+	running bool
+	visited map[string]struct{}
+	pending []*colibri.Rules
+}
+
+// New returns a new Crawler.
+func New(c *colibri.Colibri, onPage func(page *Page)) *Crawler {
+	return &Crawler{Colibri: c, OnPage: onPage}
+}
+
+// Run crawls the seeds until the frontier queue is empty or the context is cancelled.
+// The URLs are requested only once per crawl, see Visited, and the URLs marked as
+// visited in the Storage are not requested.
+// The pages are only requested in their time windows, see Windows.
+// Returns the error of the context if it is cancelled.
+func (crawler *Crawler) Run(ctx context.Context, seeds ...*colibri.Rules) error {
+	if crawler.Colibri == nil {
+		return ErrColibriIsNil
+	}
+
+	crawler.mu.Lock()
+	if crawler.running {
+		crawler.mu.Unlock()
+		return ErrRunning
+	}
+	crawler.running = true
+	crawler.cond = sync.NewCond(&crawler.mu)
+	crawler.queue = frontier{}
+	crawler.active = 0
+	crawler.visited = make(map[string]struct{})
+	crawler.pending = nil
+	crawler.mu.Unlock()
+
+	for _, seed := range seeds {
+		if seed != nil {
+			crawler.push(&Page{Rules: seed.Clone()})
+		}
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		crawler.mu.Lock()
+		crawler.cond.Broadcast()
+		crawler.mu.Unlock()
+	})
+	defer stop()
+
+	workers := crawler.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			crawler.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	crawler.mu.Lock()
+	crawler.running = false
+	for _, page := range crawler.queue.drain() {
+		crawler.pending = append(crawler.pending, page.Rules)
+	}
+	crawler.mu.Unlock()
+
+	return ctx.Err()
+}
+
+// Len returns the number of pages in the frontier queue.
+func (crawler *Crawler) Len() int {
+	crawler.mu.Lock()
+	defer crawler.mu.Unlock()
+	return crawler.queue.len()
+}
+
+// Visited returns the number of URLs added to the frontier queue during the crawl.
+func (crawler *Crawler) Visited() int {
+	crawler.mu.Lock()
+	defer crawler.mu.Unlock()
+	return len(crawler.visited)
+}
+
+// Pending returns the rules of the pages that were not processed because the last crawl
+// was cancelled, used as the seeds to resume the crawl with the same Storage.
+func (crawler *Crawler) Pending() []*colibri.Rules {
+	crawler.mu.Lock()
+	defer crawler.mu.Unlock()
+
+	pending := make([]*colibri.Rules, 0, len(crawler.pending))
+	for _, rules := range crawler.pending {
+		pending = append(pending, rules.Clone())
+	}
+	return pending
+}
+
+func (crawler *Crawler) work(ctx context.Context) {
+	for {
+		page, ok := crawler.pop(ctx)
+		if !ok {
+			return
+		}
+
+		crawler.process(ctx, page)
+
+		crawler.mu.Lock()
+		crawler.active--
+		crawler.cond.Broadcast()
+		crawler.mu.Unlock()
+	}
+}
+
+// pop returns the next page of the queue whose time window is open and whose host is not paused,
+// waits while the queue is empty and other workers are processing pages or while all the windows
+// are closed or the hosts paused, see colibri.Colibri.PauseHost.
+// Returns false when the crawl ends or the context is cancelled.
+func (crawler *Crawler) pop(ctx context.Context) (*Page, bool) {
+	crawler.mu.Lock()
+	defer crawler.mu.Unlock()
+
+	for ctx.Err() == nil {
+		if crawler.queue.len() == 0 {
+			if crawler.active == 0 {
+				return nil, false
+			}
+
+			crawler.cond.Wait()
+			continue
+		}
+
+		changes := crawler.Colibri.HostChanges()
+		head, next := crawler.nextPage(time.Now())
+		if head != nil {
+			page := crawler.queue.pop(head)
+			crawler.active++
+			return page, true
+		}
+
+		// the crawl is paused until the next window opens or a host is resumed
+		var (
+			wake = func() {
+				crawler.mu.Lock()
+				crawler.cond.Broadcast()
+				crawler.mu.Unlock()
+			}
+			timer *time.Timer
+			stop  = make(chan struct{})
+		)
+		if !next.IsZero() {
+			timer = time.AfterFunc(time.Until(next), wake)
+		}
+
+		go func() {
+			select {
+			case <-changes:
+				wake()
+			case <-stop:
+			}
+		}()
+
+		crawler.cond.Wait()
+		close(stop)
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	return nil, false
+}
+
+// nextPage returns the first page of the next host, in round-robin order, whose window is open
+// at now and which is not paused, or nil and the next time in which a window opens,
+// zero if all the hosts are paused.
+func (crawler *Crawler) nextPage(now time.Time) (*Page, time.Time) {
+	var (
+		found *Page
+		next  time.Time
+	)
+	crawler.queue.heads(func(page *Page) bool {
+		if (page.Rules.URL != nil) && crawler.Colibri.HostPaused(page.Rules.URL.Hostname()) {
+			return true
+		}
+
+		open := nextOpen(crawler.windows(page.Rules.URL), now)
+		if !open.After(now) {
+			found = page
+			return false
+		}
+
+		if next.IsZero() || open.Before(next) {
+			next = open
+		}
+		return true
+	})
+
+	if found != nil {
+		return found, now
+	}
+	return nil, next
+}
+
+// windows returns the time windows of the URL.
+func (crawler *Crawler) windows(u *url.URL) []Window {
+	if (u != nil) && (crawler.HostWindows != nil) {
+		if windows, ok := crawler.HostWindows[colibri.NormalizeHost(u.Hostname())]; ok {
+			return windows
+		}
+	}
+	return crawler.Windows
+}
+
+// push adds the page to the queue if its URL was not visited.
+// If the Storage fails, the page is added.
+func (crawler *Crawler) push(page *Page) {
+	key := visitKey(page.Rules)
+	if crawler.Storage != nil {
+		if ok, _ := crawler.Storage.Visited(key); ok {
+			return
+		}
+	}
+
+	crawler.mu.Lock()
+	defer crawler.mu.Unlock()
+
+	if _, ok := crawler.visited[key]; ok {
+		return
+	}
+	crawler.visited[key] = struct{}{}
+
+	crawler.queue.push(page)
+	crawler.cond.Signal()
+}
+
+func (crawler *Crawler) process(ctx context.Context, page *Page) {
+	var (
+		rules     = page.Rules
+		selectors = rules.Selectors
+		follows   = make(map[*colibri.Selector]*colibri.Selector)
+	)
+	rules.Selectors = splitFollows(rules.Selectors, follows)
+
+	page.TraceID = colibri.NewTraceID()
+	page.Response, page.Output, page.Err = crawler.Colibri.ExtractContext(colibri.WithTraceID(ctx, page.TraceID), rules)
+	if ctx.Err() != nil {
+		// the page is requested again when the crawl is resumed
+		pending := rules.Clone()
+		pending.Selectors = colibri.CloneSelectors(selectors)
+
+		crawler.mu.Lock()
+		crawler.pending = append(crawler.pending, pending)
+		crawler.mu.Unlock()
+	} else if crawler.Storage != nil {
+		if err := crawler.Storage.MarkVisited(visitKey(rules)); err != nil {
+			page.Err = errors.Join(page.Err, err)
+		}
+	}
+
+	if (crawler.MaxDepth <= 0) || (page.Depth < crawler.MaxDepth) {
+		var parent *url.URL
+		if page.Response != nil {
+			parent = page.Response.URL()
+		}
+
+		crawler.discover(rules, page, parent, rules.Selectors, follows, page.Output)
+	}
+
+	if crawler.OnPage != nil {
+		crawler.OnPage(page)
+	}
+}
+
+// discover adds to the queue the URLs found by the Follow selectors
+// that are allowed by the rules and the selectors, see colibri.FollowAllowed.
+func (crawler *Crawler) discover(src *colibri.Rules, page *Page, parent *url.URL, selectors []*colibri.Selector, follows map[*colibri.Selector]*colibri.Selector, output map[string]any) {
+	for _, selector := range selectors {
+		value, ok := output[selector.Name]
+		if !ok {
+			continue
+		}
+
+		follow, ok := follows[selector]
+		if !ok {
+			switch v := value.(type) {
+			case map[string]any:
+				crawler.discover(src, page, parent, selector.Selectors, follows, v)
+			case []any:
+				for _, e := range v {
+					if m, ok := e.(map[string]any); ok {
+						crawler.discover(src, page, parent, selector.Selectors, follows, m)
+					}
+				}
+			}
+			continue
+		}
+
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+
+		for _, rawURL := range values {
+			u, err := colibri.ToURL(rawURL)
+			if (err != nil) || (u.String() == "") {
+				continue
+			}
+
+			if (parent != nil) && !u.IsAbs() {
+				u = parent.ResolveReference(u)
+			}
+
+			if !colibri.FollowAllowed(src, follow, u) {
+				continue
+			}
+
+			rules := follow.Rules(src)
+			rules.URL = u
+			crawler.push(&Page{
+				Rules:    rules,
+				Depth:    page.Depth + 1,
+				Parent:   parent,
+				Selector: follow.Name,
+			})
+		}
+	}
+}
+
+// splitFollows returns a copy of the selectors in which the Follow selectors
+// only find the URLs, the original Follow selectors are stored in follows.
+func splitFollows(selectors []*colibri.Selector, follows map[*colibri.Selector]*colibri.Selector) []*colibri.Selector {
+	result := make([]*colibri.Selector, 0, len(selectors))
+	for _, selector := range selectors {
+		sel := selector.Clone()
+		if sel.Follow {
+			follows[sel] = selector
+			sel.Follow = false
+			sel.Selectors = nil
+		} else {
+			sel.Selectors = splitFollows(selector.Selectors, follows)
+		}
+		result = append(result, sel)
+	}
+	return result
+}
+
+// visitKey returns the key used to deduplicate the requests,
+// the method and the URL without the fragment and with the host normalized.
+func visitKey(rules *colibri.Rules) string {
+	if rules.URL == nil {
+		return rules.Method
+	}
+
+	u := *rules.URL
+	u.Fragment, u.RawFragment = "", ""
+	u.Host = colibri.NormalizeHost(u.Host)
+
+	method := rules.Method
+	if method == "" {
+		method = "GET"
+	}
+	return method + " " + u.String()
+}
@@ -0,0 +1,357 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/storage"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+var testPages = map[string][]string{
+	"/a": {"/b", "/c"},
+	"/b": {"/a", "/c", "/d#top"},
+	"/c": {"/d"},
+	"/d": {"/e"},
+	"/e": nil,
+}
+
+func TestCrawler(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	c, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links := &colibri.Selector{Name: "links", Expr: "//a/@href", All: true, Follow: true}
+	links.Selectors = []*colibri.Selector{
+		{Name: "title", Expr: "//title"},
+		links.Clone(),
+	}
+
+	seed, err := colibri.NewRules(colibri.RawRules{
+		colibri.KeyURL:             ts.URL + "/a",
+		colibri.KeyIgnoreRobotsTxt: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed.Selectors = []*colibri.Selector{{Name: "title", Expr: "//title"}, links}
+
+	tests := []struct {
+		MaxDepth  int
+		WantPaths []string
+	}{
+		{0, []string{"/a", "/b", "/c", "/d"}}, // the nested selectors of /d do not follow
+		{1, []string{"/a", "/b", "/c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint("MaxDepth", tt.MaxDepth), func(t *testing.T) {
+			var (
+				mu    sync.Mutex
+				paths []string
+			)
+
+			crawler := New(c, func(page *Page) {
+				if page.Err != nil {
+					t.Error(page.Err)
+				}
+
+				if page.TraceID == "" {
+					t.Error("trace ID expected")
+				}
+
+				if (len(page.Rules.Selectors) > 0) && (page.Output["title"] != page.Rules.URL.Path) {
+					t.Errorf("got %v, want %v", page.Output["title"], page.Rules.URL.Path)
+				}
+
+				mu.Lock()
+				paths = append(paths, page.Rules.URL.Path)
+				mu.Unlock()
+			})
+			crawler.MaxDepth = tt.MaxDepth
+
+			if err := crawler.Run(context.Background(), seed); err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Strings(paths)
+			if fmt.Sprint(paths) != fmt.Sprint(tt.WantPaths) {
+				t.Fatalf("got %v, want %v", paths, tt.WantPaths)
+			} else if crawler.Len() != 0 {
+				t.Fatalf("got %v, want %v", crawler.Len(), 0)
+			}
+		})
+	}
+
+	t.Run("Storage", func(t *testing.T) {
+		var (
+			mu     sync.Mutex
+			paths  []string
+			cancel context.CancelFunc
+		)
+
+		crawler := New(c, func(page *Page) {
+			mu.Lock()
+			paths = append(paths, page.Rules.URL.Path)
+			mu.Unlock()
+
+			cancel() // interrupts the crawl after the first page
+		})
+		crawler.Workers = 1
+		crawler.Storage = storage.NewMemory()
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		if err := crawler.Run(ctx, seed); err != context.Canceled {
+			t.Fatalf("got %v, want %v", err, context.Canceled)
+		}
+
+		pending := crawler.Pending()
+		if len(pending) != 2 {
+			t.Fatalf("got %v, want %v", len(pending), 2)
+		}
+
+		// the seed is not requested again
+		cancel = func() {}
+		if err := crawler.Run(context.Background(), append(pending, seed)...); err != nil {
+			t.Fatal(err)
+		}
+
+		sort.Strings(paths)
+		want := []string{"/a", "/b", "/c", "/d"}
+		if fmt.Sprint(paths) != fmt.Sprint(want) {
+			t.Fatalf("got %v, want %v", paths, want)
+		} else if len(crawler.Pending()) != 0 {
+			t.Fatalf("got %v, want %v", len(crawler.Pending()), 0)
+		}
+	})
+
+	t.Run("Windows", func(t *testing.T) {
+		var (
+			now    = time.Now().UTC()
+			offset = now.Sub(now.Truncate(24 * time.Hour))
+			opens  = now.Add(200 * time.Millisecond)
+			closed = Window{Start: (offset + 200*time.Millisecond) % (24 * time.Hour), End: (offset + time.Hour) % (24 * time.Hour)}
+			first  time.Time
+		)
+
+		crawler := New(c, func(page *Page) {
+			if page.Rules.URL.Path == "/a" {
+				first = time.Now()
+			}
+		})
+		crawler.Workers = 1
+		crawler.Windows = []Window{closed}
+
+		if err := crawler.Run(context.Background(), seed); err != nil {
+			t.Fatal(err)
+		}
+
+		if first.Before(opens) {
+			t.Fatalf("got %v, want after %v", first, opens)
+		}
+
+		// the windows of the host are used instead
+		crawler.HostWindows = map[string][]Window{"127.0.0.1": {{}}}
+		start := time.Now()
+		if err := crawler.Run(context.Background(), seed); err != nil {
+			t.Fatal(err)
+		}
+
+		if first.Sub(start) > 100*time.Millisecond {
+			t.Fatalf("got %v, want less than %v", first.Sub(start), 100*time.Millisecond)
+		}
+	})
+
+	t.Run("PauseHost", func(t *testing.T) {
+		var (
+			first   time.Time
+			resumed time.Time
+		)
+
+		crawler := New(c, func(page *Page) {
+			if page.Rules.URL.Path == "/a" {
+				first = time.Now()
+			}
+		})
+
+		c.PauseHost("127.0.0.1")
+		time.AfterFunc(100*time.Millisecond, func() {
+			resumed = time.Now()
+			c.ResumeHost("127.0.0.1")
+		})
+
+		if err := crawler.Run(context.Background(), seed); err != nil {
+			t.Fatal(err)
+		}
+
+		if first.Before(resumed) {
+			t.Fatalf("got %v, want after %v", first, resumed)
+		}
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		var (
+			mu    sync.Mutex
+			paths []string
+		)
+
+		crawler := New(c, func(page *Page) {
+			mu.Lock()
+			paths = append(paths, page.Rules.URL.Path)
+			mu.Unlock()
+		})
+
+		denied := seed.Clone()
+		denied.Deny = []*regexp.Regexp{regexp.MustCompile(`/c$`)}
+		if err := crawler.Run(context.Background(), denied); err != nil {
+			t.Fatal(err)
+		}
+
+		sort.Strings(paths)
+		if want := []string{"/a", "/b", "/d"}; fmt.Sprint(paths) != fmt.Sprint(want) {
+			t.Fatalf("got %v, want %v", paths, want)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		crawler := New(c, nil)
+		if err := crawler.Run(ctx, seed); err != context.Canceled {
+			t.Fatalf("got %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestFrontier(t *testing.T) {
+	var f frontier
+	for _, rawURL := range []string{
+		"http://a.com/1", "http://a.com/2", "http://a.com/3",
+		"http://b.com/1", "http://A.com/4", "http://c.com/1", "http://b.com/2",
+	} {
+		f.push(&Page{Rules: &colibri.Rules{URL: mustParseURL(rawURL)}})
+	}
+
+	if f.len() != 7 {
+		t.Fatalf("got %v, want %v", f.len(), 7)
+	}
+
+	var got []string
+	for f.len() > 0 {
+		var head *Page
+		f.heads(func(page *Page) bool {
+			// the pages of b.com are skipped until the others end
+			if (page.Rules.URL.Host == "b.com") && (f.len() > 2) {
+				return true
+			}
+
+			head = page
+			return false
+		})
+		got = append(got, f.pop(head).Rules.URL.String())
+	}
+
+	want := []string{
+		"http://a.com/1", "http://c.com/1", "http://a.com/2",
+		"http://a.com/3", "http://A.com/4", "http://b.com/1", "http://b.com/2",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	for _, rawURL := range []string{"http://a.com/1", "http://a.com/2", "http://b.com/1", "http://c.com/1"} {
+		f.push(&Page{Rules: &colibri.Rules{URL: mustParseURL(rawURL)}})
+	}
+
+	got = got[:0]
+	for _, page := range f.drain() {
+		got = append(got, page.Rules.URL.String())
+	}
+
+	want = []string{"http://a.com/1", "http://b.com/1", "http://c.com/1", "http://a.com/2"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	} else if f.len() != 0 {
+		t.Fatalf("got %v, want %v", f.len(), 0)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	window, err := ParseWindow("02:00-05:00", tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	night, err := ParseWindow("22:30 - 01:00", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Window Window
+		Time   time.Time
+		Want   time.Time
+	}{
+		{window, time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)},  // 03:00 JST
+		{window, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 17, 0, 0, 0, time.UTC)},  // 21:00 JST
+		{window, time.Date(2024, 5, 1, 20, 30, 0, 0, time.UTC), time.Date(2024, 5, 2, 17, 0, 0, 0, time.UTC)}, // 05:30 JST
+		{night, time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)},
+		{night, time.Date(2024, 5, 2, 0, 30, 0, 0, time.UTC), time.Date(2024, 5, 2, 0, 30, 0, 0, time.UTC)},
+		{night, time.Date(2024, 5, 2, 1, 0, 0, 0, time.UTC), time.Date(2024, 5, 2, 22, 30, 0, 0, time.UTC)},
+		{Window{}, time.Date(2024, 5, 2, 1, 0, 0, 0, time.UTC), time.Date(2024, 5, 2, 1, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.Window.Next(tt.Time); !got.Equal(tt.Want) {
+			t.Fatalf("got %v, want %v", got, tt.Want)
+		}
+	}
+
+	for _, s := range []string{"02:00", "2am-5am", "02:00-25:00"} {
+		if _, err := ParseWindow(s, nil); err != ErrInvalidWindow {
+			t.Fatalf("got %v, want %v", err, ErrInvalidWindow)
+		}
+	}
+}
+
+func testServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		links, ok := testPages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head><body>", r.URL.Path)
+		for _, link := range links {
+			fmt.Fprintf(w, `<a href="%s">%s</a>`, link, link)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+}
+
+func mustParseURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
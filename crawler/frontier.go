@@ -0,0 +1,100 @@
+package crawler
+
+import "github.com/eduardogxnzalez/colibri"
+
+// frontier is the queue of the pages to process. The pages of each host are queued in order
+// and the hosts are served in round-robin, so a host with many pages or long delays does not
+// delay the pages of the other hosts.
+type frontier struct {
+	queues map[string][]*Page
+	hosts  []string // hosts with pages, in round-robin order
+	next   int      // index in hosts of the next host served
+	size   int
+}
+
+// push adds the page to the queue of its host.
+func (f *frontier) push(page *Page) {
+	if f.queues == nil {
+		f.queues = make(map[string][]*Page)
+	}
+
+	host := pageHost(page)
+	if _, ok := f.queues[host]; !ok {
+		// the new host is served after the hosts waiting
+		f.hosts = append(f.hosts, "")
+		copy(f.hosts[f.next+1:], f.hosts[f.next:])
+		f.hosts[f.next] = host
+		f.next = (f.next + 1) % len(f.hosts)
+	}
+
+	f.queues[host] = append(f.queues[host], page)
+	f.size++
+}
+
+// heads calls fn with the first page of each host in round-robin order,
+// starting from the next host served, until fn returns false.
+func (f *frontier) heads(fn func(page *Page) bool) {
+	for i := 0; i < len(f.hosts); i++ {
+		host := f.hosts[(f.next+i)%len(f.hosts)]
+		if !fn(f.queues[host][0]) {
+			return
+		}
+	}
+}
+
+// pop removes and returns the first page of the host of the page,
+// the next host served is the following one.
+func (f *frontier) pop(head *Page) *Page {
+	host := pageHost(head)
+	queue := f.queues[host]
+	page := queue[0]
+	queue[0] = nil
+	f.size--
+
+	i := 0
+	for f.hosts[i] != host {
+		i++
+	}
+
+	if len(queue) > 1 {
+		f.queues[host] = queue[1:]
+		f.next = (i + 1) % len(f.hosts)
+		return page
+	}
+
+	delete(f.queues, host)
+	f.hosts = append(f.hosts[:i], f.hosts[i+1:]...)
+	if len(f.hosts) == 0 {
+		f.next = 0
+	} else {
+		f.next = i % len(f.hosts)
+	}
+	return page
+}
+
+// drain removes and returns all the pages in round-robin order.
+func (f *frontier) drain() []*Page {
+	pages := make([]*Page, 0, f.size)
+	for f.size > 0 {
+		var head *Page
+		f.heads(func(page *Page) bool {
+			head = page
+			return false
+		})
+		pages = append(pages, f.pop(head))
+	}
+	return pages
+}
+
+// len returns the number of pages.
+func (f *frontier) len() int {
+	return f.size
+}
+
+// pageHost returns the normalized host of the URL of the page, empty if it is nil.
+func pageHost(page *Page) string {
+	if page.Rules.URL == nil {
+		return ""
+	}
+	return colibri.NormalizeHost(page.Rules.URL.Host)
+}
@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidWindow is returned when the window can not be parsed.
+var ErrInvalidWindow = errors.New("invalid window")
+
+// Window is a daily time window in which the pages can be requested,
+// e.g. from 02:00 to 05:00 in the time zone of the website.
+type Window struct {
+	// Start time of the day in which the window opens, as the duration since midnight.
+	Start time.Duration
+
+	// End time of the day in which the window closes, as the duration since midnight.
+	// If End is before Start, the window closes the next day, if they are equal it is always open.
+	End time.Duration
+
+	// Location time zone of the window, if nil UTC is used.
+	Location *time.Location
+}
+
+// ParseWindow parses a window with the format "15:04-15:04" in the time zone loc.
+func ParseWindow(s string, loc *time.Location) (Window, error) {
+	rawStart, rawEnd, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, ErrInvalidWindow
+	}
+
+	start, err := time.Parse("15:04", strings.TrimSpace(rawStart))
+	if err != nil {
+		return Window{}, ErrInvalidWindow
+	}
+
+	end, err := time.Parse("15:04", strings.TrimSpace(rawEnd))
+	if err != nil {
+		return Window{}, ErrInvalidWindow
+	}
+
+	return Window{
+		Start:    time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute,
+		End:      time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute,
+		Location: loc,
+	}, nil
+}
+
+// Next returns the next time from t in which the window is open, t if it is open.
+func (window Window) Next(t time.Time) time.Time {
+	loc := window.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var (
+		local    = t.In(loc)
+		midnight = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		offset   = local.Sub(midnight)
+	)
+
+	switch {
+	case window.Start == window.End:
+		return t
+	case (window.Start < window.End) && (offset >= window.Start) && (offset < window.End):
+		return t
+	case (window.Start > window.End) && ((offset >= window.Start) || (offset < window.End)):
+		return t
+	case offset < window.Start:
+		return midnight.Add(window.Start)
+	}
+	return midnight.AddDate(0, 0, 1).Add(window.Start)
+}
+
+// nextOpen returns the next time from t in which any of the windows is open,
+// t if there are no windows.
+func nextOpen(windows []Window, t time.Time) time.Time {
+	if len(windows) == 0 {
+		return t
+	}
+
+	next := windows[0].Next(t)
+	for _, window := range windows[1:] {
+		if n := window.Next(t); n.Before(next) {
+			next = n
+		}
+	}
+	return next
+}
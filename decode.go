@@ -0,0 +1,409 @@
+package colibri
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TagName is the struct tag used to map the names of the selectors to the fields of a structure.
+// If a field has no tag, the field name is used. The "-" tag omits the field.
+const TagName = "colibri"
+
+// LayoutTagName is the struct tag used to specify the layout of a time.Time field, see time.Parse.
+const LayoutTagName = "layout"
+
+// TimeLayouts are the layouts used to decode strings into time.Time values
+// when the field has no LayoutTagName tag. They are tried in order.
+var TimeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.RFC822Z,
+	time.RFC822,
+	time.ANSIC,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+var (
+	// ErrInvalidTarget is returned when the decoding target is not a non-nil pointer.
+	ErrInvalidTarget = errors.New("target must be a non-nil pointer")
+
+	// ErrNotDecodable is returned when the value cannot be decoded into the field.
+	ErrNotDecodable = errors.New("value is not decodable into field")
+
+	// ErrTimeLayout is returned when the string does not match any time layout.
+	ErrTimeLayout = errors.New("value does not match any time layout")
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// ExtractAs performs the HTTP request, parses the content of the response
+// following the rules and decodes the extracted data into a value of type T.
+// See Decode.
+func ExtractAs[T any](c *Colibri, rules *Rules) (T, error) {
+	var v T
+
+	_, output, err := c.Extract(rules)
+	if output == nil {
+		return v, err
+	}
+
+	if decodeErr := Decode(output, &v); err == nil {
+		err = decodeErr
+	}
+	return v, err
+}
+
+// Unmarshal decodes the output of Extract into the value pointed by v, see Decode.
+//
+//	type Page struct {
+//		Title string   `colibri:"title"`
+//		Links []string `colibri:"links"`
+//	}
+//
+//	var page Page
+//	err := colibri.Unmarshal(output, &page)
+func Unmarshal(output map[string]any, v any) error {
+	return Decode(output, v)
+}
+
+// Decode decodes the data extracted with the selectors into the value pointed by v.
+// Structure fields are mapped with the TagName tag, nested selectors are decoded
+// into structures or maps, the values found with All into slices and the data
+// of the URLs of the Follow selectors into maps or slices sorted by URL.
+// Strings are converted to numbers, booleans, time.Duration and time.Time values
+// (see TimeLayouts and LayoutTagName). Fields that implement encoding.TextUnmarshaler
+// are decoded from strings and fields that implement json.Unmarshaler from the
+// JSON encoding of the value.
+func Decode(output map[string]any, v any) error {
+	rv := reflect.ValueOf(v)
+	if (rv.Kind() != reflect.Pointer) || rv.IsNil() {
+		return ErrInvalidTarget
+	}
+	return decodeValue(output, rv.Elem(), nil)
+}
+
+func decodeValue(src any, dst reflect.Value, layouts []string) error {
+	if src == nil {
+		return nil
+	}
+
+	rSrc := reflect.ValueOf(src)
+	if (dst.Kind() != reflect.Interface) && rSrc.Type().AssignableTo(dst.Type()) {
+		dst.Set(rSrc)
+		return nil
+	}
+
+	if dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return decodeValue(src, dst.Elem(), layouts)
+	}
+
+	if ok, err := decodeUnmarshaler(src, dst, layouts); ok {
+		return err
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if !rSrc.Type().AssignableTo(dst.Type()) {
+			return ErrNotDecodable
+		}
+		dst.Set(rSrc)
+		return nil
+
+	case reflect.Struct:
+		m, ok := src.(map[string]any)
+		if !ok {
+			return ErrNotDecodable
+		}
+		return decodeStruct(m, dst)
+
+	case reflect.Map:
+		return decodeMap(src, dst, layouts)
+
+	case reflect.Slice:
+		return decodeSlice(src, dst, layouts)
+	}
+
+	return decodeScalar(src, dst)
+}
+
+func decodeStruct(src map[string]any, dst reflect.Value) error {
+	var (
+		dstType = dst.Type()
+		errs    error
+	)
+	for i := 0; i < dstType.NumField(); i++ {
+		field := dstType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, ok := field.Tag.Lookup(TagName)
+		if name == "-" {
+			continue
+		}
+
+		if !ok && field.Anonymous && (indirectType(field.Type).Kind() == reflect.Struct) {
+			if err := decodeValue(src, dst.Field(i), nil); err != nil {
+				errs = AddError(errs, field.Name, err)
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		value, ok := src[name]
+		if !ok {
+			continue
+		}
+
+		var layouts []string
+		if layout := field.Tag.Get(LayoutTagName); layout != "" {
+			layouts = []string{layout}
+		}
+
+		if err := decodeValue(value, dst.Field(i), layouts); err != nil {
+			errs = AddError(errs, name, err)
+		}
+	}
+	return errs
+}
+
+func decodeMap(src any, dst reflect.Value, layouts []string) error {
+	m, ok := src.(map[string]any)
+	if !ok || (dst.Type().Key().Kind() != reflect.String) {
+		return ErrNotDecodable
+	}
+
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m)))
+	}
+
+	var errs error
+	for key, value := range m {
+		elem := reflect.New(dst.Type().Elem()).Elem()
+		if err := decodeValue(value, elem, layouts); err != nil {
+			errs = AddError(errs, key, err)
+			continue
+		}
+		dst.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), elem)
+	}
+	return errs
+}
+
+func decodeSlice(src any, dst reflect.Value, layouts []string) error {
+	values, ok := src.([]any)
+	if !ok {
+		values = followValues(src)
+	}
+
+	var (
+		slice = reflect.MakeSlice(dst.Type(), 0, len(values))
+		errs  error
+	)
+	for i, value := range values {
+		elem := reflect.New(dst.Type().Elem()).Elem()
+		if err := decodeValue(value, elem, layouts); err != nil {
+			errs = AddError(errs, "#"+strconv.Itoa(i), err)
+			continue
+		}
+		slice = reflect.Append(slice, elem)
+	}
+
+	dst.Set(slice)
+	return errs
+}
+
+// followValues returns the values of the output of a Follow selector sorted by URL,
+// if src is not the output of a Follow selector it returns src as the only value.
+func followValues(src any) []any {
+	m, ok := src.(map[string]any)
+	if !ok || (len(m) == 0) {
+		return []any{src}
+	}
+
+	urls := make([]string, 0, len(m))
+	for key := range m {
+		if u, err := url.Parse(key); (err != nil) || !u.IsAbs() || (u.Host == "") {
+			return []any{src}
+		}
+		urls = append(urls, key)
+	}
+	sort.Strings(urls)
+
+	values := make([]any, 0, len(urls))
+	for _, u := range urls {
+		values = append(values, m[u])
+	}
+	return values
+}
+
+func decodeScalar(src any, dst reflect.Value) error {
+	str, isStr := src.(string)
+	if isStr {
+		str = strings.TrimSpace(str)
+	}
+
+	if dst.Type() == durationType {
+		if !isStr {
+			return convertNumber(src, dst)
+		}
+
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return err
+		}
+		dst.SetInt(int64(d))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(fmt.Sprint(src))
+		return nil
+
+	case reflect.Bool:
+		b, err := toBool(src)
+		if err != nil {
+			return err
+		}
+		dst.SetBool(b)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !isStr {
+			return convertNumber(src, dst)
+		}
+
+		n, err := strconv.ParseInt(str, 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !isStr {
+			return convertNumber(src, dst)
+		}
+
+		n, err := strconv.ParseUint(str, 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetUint(n)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		if !isStr {
+			return convertNumber(src, dst)
+		}
+
+		n, err := strconv.ParseFloat(str, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetFloat(n)
+		return nil
+	}
+
+	return ErrNotDecodable
+}
+
+// decodeUnmarshaler decodes time.Time values and values whose type implements
+// encoding.TextUnmarshaler or json.Unmarshaler.
+// Returns false if the type of dst is not supported.
+func decodeUnmarshaler(src any, dst reflect.Value, layouts []string) (bool, error) {
+	if dst.Type() == timeType {
+		t, err := toTime(src, layouts)
+		if err == nil {
+			dst.Set(reflect.ValueOf(t))
+		}
+		return true, err
+	}
+
+	if !dst.CanAddr() {
+		return false, nil
+	}
+
+	ptrType := reflect.PointerTo(dst.Type())
+	if str, ok := src.(string); ok && ptrType.Implements(textUnmarshalerType) {
+		return true, dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str))
+	}
+
+	if ptrType.Implements(jsonUnmarshalerType) {
+		b, err := json.Marshal(src)
+		if err != nil {
+			return true, err
+		}
+		return true, dst.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(b)
+	}
+	return false, nil
+}
+
+// toTime converts a string to a time.Time using the layouts, if layouts is nil TimeLayouts is used.
+// Numbers are interpreted as Unix timestamps in seconds.
+func toTime(src any, layouts []string) (time.Time, error) {
+	str, ok := src.(string)
+	if !ok {
+		var sec int64
+		if err := convertNumber(src, reflect.ValueOf(&sec).Elem()); err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	}
+
+	if layouts == nil {
+		layouts = TimeLayouts
+	}
+
+	str = strings.TrimSpace(str)
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, str); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrTimeLayout
+}
+
+// convertNumber converts a numeric value to the type of dst.
+func convertNumber(src any, dst reflect.Value) error {
+	rSrc := reflect.ValueOf(src)
+	switch rSrc.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		dst.Set(rSrc.Convert(dst.Type()))
+		return nil
+	}
+	return ErrNotDecodable
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
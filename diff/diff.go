@@ -0,0 +1,264 @@
+// diff runs differential crawls that only output the records that are new, changed or removed
+// relative to the previous run, comparing the fingerprints of the outputs stored in a Snapshot.
+package diff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/compress"
+	"github.com/eduardogxnzalez/colibri/crawler"
+)
+
+// Kind of change of a record.
+type Kind string
+
+const (
+	// KindNew the record was not in the previous run.
+	KindNew Kind = "new"
+
+	// KindChanged the output of the record is different from that of the previous run.
+	KindChanged Kind = "changed"
+
+	// KindRemoved the record of the previous run was not found in the current run.
+	KindRemoved Kind = "removed"
+)
+
+// Change represents a change of a record relative to the previous run.
+type Change struct {
+	// Kind of change.
+	Kind Kind `json:"kind"`
+
+	// Key of the record, see Differ.KeyFunc.
+	Key string `json:"key"`
+
+	// Output of the record, nil for the removed records.
+	Output map[string]any `json:"output,omitempty"`
+}
+
+// Snapshot stores the fingerprints of the outputs of a run by record key.
+type Snapshot map[string]string
+
+// LoadSnapshot loads the snapshot stored in the file of the path.
+// Returns an empty snapshot if the file does not exist, e.g. in the first run.
+func LoadSnapshot(path string) (Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if data, err = compress.Decompress(data); err != nil {
+		return nil, err
+	}
+
+	snapshot := Snapshot{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Save stores the snapshot in the file of the path. The file is replaced
+// atomically, so the previous snapshot is kept if the process ends while writing it.
+func (snapshot Snapshot) Save(path string) error {
+	return snapshot.SaveWithCodec(path, nil)
+}
+
+// SaveWithCodec stores the snapshot like Save, compressed with the codec.
+// If nil, the snapshot is not compressed. LoadSnapshot detects the codec.
+func (snapshot Snapshot) SaveWithCodec(path string, codec compress.Codec) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	if data, err = compress.Compress(codec, data); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := errors.Join(tmp.Sync(), tmp.Close()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Differ compares the records of the current run with the Snapshot of the previous run.
+// The crawls must process all the records, the Storage of the crawler must not skip the
+// URLs visited in previous runs, otherwise their records are reported as removed.
+type Differ struct {
+	// Previous snapshot of the previous run.
+	Previous Snapshot
+
+	// OnChange is called for each change, it must be safe for concurrent use.
+	OnChange func(change Change)
+
+	// KeyFunc returns the key of the record of the page, empty to ignore the page.
+	// If nil, the method and the URL of the page are used.
+	KeyFunc func(page *crawler.Page) string
+
+	// Ignore names of the fields of the outputs excluded from the fingerprints,
+	// e.g. timestamps or counters that change in each run.
+	Ignore []string
+
+	mu      sync.Mutex
+	current Snapshot
+}
+
+// New returns a new Differ with the snapshot of the previous run.
+func New(previous Snapshot, onChange func(change Change)) *Differ {
+	return &Differ{Previous: previous, OnChange: onChange}
+}
+
+// OnPage observes the output of the page, it can be used as crawler.Crawler.OnPage.
+// The pages with errors keep the fingerprint of the previous run, so they are not
+// reported as removed or changed.
+func (differ *Differ) OnPage(page *crawler.Page) {
+	keyFunc := differ.KeyFunc
+	if keyFunc == nil {
+		keyFunc = PageKey
+	}
+
+	key := keyFunc(page)
+	if key == "" {
+		return
+	}
+
+	if page.Err != nil {
+		differ.mu.Lock()
+		if fingerprint, ok := differ.Previous[key]; ok {
+			differ.setCurrent(key, fingerprint)
+		}
+		differ.mu.Unlock()
+		return
+	}
+	differ.Observe(key, page.Output)
+}
+
+// Observe observes the output of the record with the key.
+// Returns the change and true if the record is new or changed.
+func (differ *Differ) Observe(key string, output map[string]any) (Change, bool) {
+	fingerprint := differ.Fingerprint(output)
+
+	differ.mu.Lock()
+	prev, ok := differ.Previous[key]
+	differ.setCurrent(key, fingerprint)
+	differ.mu.Unlock()
+
+	var change Change
+	switch {
+	case !ok:
+		change = Change{Kind: KindNew, Key: key, Output: output}
+	case prev != fingerprint:
+		change = Change{Kind: KindChanged, Key: key, Output: output}
+	default:
+		return Change{}, false
+	}
+
+	if differ.OnChange != nil {
+		differ.OnChange(change)
+	}
+	return change, true
+}
+
+// Finish reports the records of the previous run that were not observed as removed,
+// they are returned sorted by key. Call it only after a complete run.
+func (differ *Differ) Finish() []Change {
+	differ.mu.Lock()
+	var removed []Change
+	for key := range differ.Previous {
+		if _, ok := differ.current[key]; !ok {
+			removed = append(removed, Change{Kind: KindRemoved, Key: key})
+		}
+	}
+	differ.mu.Unlock()
+
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Key < removed[j].Key })
+	if differ.OnChange != nil {
+		for _, change := range removed {
+			differ.OnChange(change)
+		}
+	}
+	return removed
+}
+
+// Snapshot returns the snapshot of the current run, to be saved as the previous of the next run.
+func (differ *Differ) Snapshot() Snapshot {
+	differ.mu.Lock()
+	defer differ.mu.Unlock()
+
+	snapshot := make(Snapshot, len(differ.current))
+	for key, fingerprint := range differ.current {
+		snapshot[key] = fingerprint
+	}
+	return snapshot
+}
+
+// Fingerprint returns the hex encoded SHA-256 hash of the output without the Ignore fields.
+// The keys of the maps are sorted, so the fingerprint does not depend on their order.
+func (differ *Differ) Fingerprint(output map[string]any) string {
+	if len(differ.Ignore) > 0 {
+		filtered := make(map[string]any, len(output))
+		for name, value := range output {
+			filtered[name] = value
+		}
+
+		for _, name := range differ.Ignore {
+			delete(filtered, name)
+		}
+		output = filtered
+	}
+
+	data, err := json.Marshal(output)
+	if err != nil {
+		data = []byte(fmt.Sprint(output))
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// setCurrent sets the fingerprint of the key in the current snapshot, the lock must be held.
+func (differ *Differ) setCurrent(key, fingerprint string) {
+	if differ.current == nil {
+		differ.current = make(Snapshot)
+	}
+	differ.current[key] = fingerprint
+}
+
+// PageKey returns the method and the URL of the page without the fragment and with the host normalized.
+func PageKey(page *crawler.Page) string {
+	if (page.Rules == nil) || (page.Rules.URL == nil) {
+		return ""
+	}
+
+	u := *page.Rules.URL
+	u.Fragment, u.RawFragment = "", ""
+	u.Host = colibri.NormalizeHost(u.Host)
+
+	method := page.Rules.Method
+	if method == "" {
+		method = "GET"
+	}
+	return method + " " + u.String()
+}
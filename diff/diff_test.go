@@ -0,0 +1,134 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/crawler"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+func TestDiffer(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		pages = map[string]string{
+			"/":  `<a href="/a"></a><a href="/b"></a>`,
+			"/a": "A",
+			"/b": "B",
+		}
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		body, ok := pages[r.URL.Path]
+		mu.Unlock()
+
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head><body>%s</body></html>", r.URL.Path, body)
+	}))
+	defer ts.Close()
+
+	c, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Delay = nil // Deactivate Delay
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	run := func() []string {
+		previous, err := LoadSnapshot(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var changes []string
+		differ := New(previous, func(change Change) {
+			mu.Lock()
+			changes = append(changes, string(change.Kind)+" "+change.Key[len("GET "+ts.URL):])
+			mu.Unlock()
+		})
+
+		seed := &colibri.Rules{
+			Method:          "GET",
+			URL:             mustNewURL(ts.URL + "/"),
+			IgnoreRobotsTxt: true,
+			Selectors: []*colibri.Selector{
+				{Name: "links", Expr: "//a/@href", All: true, Follow: true, Selectors: []*colibri.Selector{
+					{Name: "body", Expr: "//body"},
+				}},
+			},
+		}
+
+		if err := crawler.New(c, differ.OnPage).Run(context.Background(), seed); err != nil {
+			t.Fatal(err)
+		}
+		differ.Finish()
+
+		if err := differ.Snapshot().Save(path); err != nil {
+			t.Fatal(err)
+		}
+
+		sort.Strings(changes)
+		return changes
+	}
+
+	if got, want := run(), []string{"new /", "new /a", "new /b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if got := run(); len(got) > 0 {
+		t.Fatalf("got %v, want no changes", got)
+	}
+
+	mu.Lock()
+	pages["/"] = `<a href="/a"></a><a href="/c"></a>`
+	pages["/a"] = "A2"
+	pages["/c"] = "C"
+	mu.Unlock()
+
+	if got, want := run(), []string{"changed /", "changed /a", "new /c", "removed /b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	differ := New(nil, nil)
+	differ.Ignore = []string{"fetchedAt"}
+
+	a := differ.Fingerprint(map[string]any{"title": "A", "tags": []any{"x", "y"}, "fetchedAt": 1})
+	b := differ.Fingerprint(map[string]any{"tags": []any{"x", "y"}, "fetchedAt": 2, "title": "A"})
+	if a != b {
+		t.Fatalf("got %v, want %v", b, a)
+	}
+
+	if c := differ.Fingerprint(map[string]any{"title": "B", "tags": []any{"x", "y"}}); c == a {
+		t.Fatalf("got %v, want a different fingerprint", c)
+	}
+
+	// the pages with errors are not reported as removed
+	differ.Previous = Snapshot{"GET https://example.com/": a}
+	differ.OnPage(&crawler.Page{Rules: &colibri.Rules{URL: mustNewURL("https://EXAMPLE.com/#top")}, Err: fmt.Errorf("timeout")})
+	if removed := differ.Finish(); len(removed) > 0 {
+		t.Fatalf("got %v, want %v", removed, nil)
+	}
+}
+
+func mustNewURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,158 @@
+package colibri
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DomainDefaults are the default rules of the hosts that match a glob, see DomainRules.
+type DomainDefaults struct {
+	// Header values added to the header of the rules, the keys already set are kept.
+	Header http.Header
+
+	// Delay used if the Delay of the rules is zero.
+	Delay time.Duration
+
+	// Proxy used if the rules do not have a Proxy.
+	Proxy *url.URL
+
+	// UseCookies enables the cookies if true.
+	UseCookies bool
+}
+
+// DomainRules stores the default rules by host glob, e.g. *.example.com.
+// The defaults of the globs that match the host of the URL are merged into the rules before
+// each request, the defaults of the more specific globs have priority.
+// The glob syntax is that of path.Match, * does not match the domain without subdomain.
+type DomainRules struct {
+	rw      sync.RWMutex
+	entries []domainEntry // sorted by specificity
+}
+
+type domainEntry struct {
+	glob     string
+	defaults DomainDefaults
+}
+
+// NewDomainRules returns a new empty DomainRules.
+func NewDomainRules() *DomainRules {
+	return &DomainRules{}
+}
+
+// Set registers the defaults of the hosts that match the glob, replacing the previous ones.
+// Returns path.ErrBadPattern if the glob is malformed.
+func (dr *DomainRules) Set(glob string, defaults DomainDefaults) error {
+	glob = strings.ToLower(strings.TrimSpace(glob))
+	if _, err := path.Match(glob, ""); err != nil {
+		return err
+	}
+
+	dr.rw.Lock()
+	defer dr.rw.Unlock()
+
+	dr.remove(glob)
+	dr.entries = append(dr.entries, domainEntry{glob: glob, defaults: defaults})
+	sort.SliceStable(dr.entries, func(i, j int) bool {
+		return specificity(dr.entries[i].glob) > specificity(dr.entries[j].glob)
+	})
+	return nil
+}
+
+// Get returns the defaults registered with the glob.
+func (dr *DomainRules) Get(glob string) (DomainDefaults, bool) {
+	glob = strings.ToLower(strings.TrimSpace(glob))
+
+	dr.rw.RLock()
+	defer dr.rw.RUnlock()
+
+	for _, entry := range dr.entries {
+		if entry.glob == glob {
+			return entry.defaults, true
+		}
+	}
+	return DomainDefaults{}, false
+}
+
+// Delete removes the defaults registered with the glob.
+func (dr *DomainRules) Delete(glob string) {
+	dr.rw.Lock()
+	dr.remove(strings.ToLower(strings.TrimSpace(glob)))
+	dr.rw.Unlock()
+}
+
+// Clear removes all the defaults.
+func (dr *DomainRules) Clear() {
+	dr.rw.Lock()
+	dr.entries = nil
+	dr.rw.Unlock()
+}
+
+// Merge returns a copy of the rules with the defaults of the globs that match the host
+// of the URL. If no glob matches, the rules are returned without copying.
+func (dr *DomainRules) Merge(rules *Rules) *Rules {
+	if (rules == nil) || (rules.URL == nil) {
+		return rules
+	}
+
+	host := hostKey(rules.URL.Hostname())
+
+	dr.rw.RLock()
+	defer dr.rw.RUnlock()
+
+	var merged *Rules
+	for _, entry := range dr.entries {
+		if ok, _ := path.Match(entry.glob, host); !ok {
+			continue
+		}
+
+		if merged == nil {
+			copied := *rules
+			copied.Header = rules.Header.Clone()
+			if copied.Header == nil {
+				copied.Header = http.Header{}
+			}
+			merged = &copied
+		}
+
+		for key, values := range entry.defaults.Header {
+			key = http.CanonicalHeaderKey(key)
+			if _, ok := merged.Header[key]; !ok {
+				merged.Header[key] = append([]string(nil), values...)
+			}
+		}
+
+		if merged.Delay == 0 {
+			merged.Delay = entry.defaults.Delay
+		}
+
+		if merged.Proxy == nil {
+			merged.Proxy = entry.defaults.Proxy
+		}
+		merged.UseCookies = merged.UseCookies || entry.defaults.UseCookies
+	}
+
+	if merged == nil {
+		return rules
+	}
+	return merged
+}
+
+// remove removes the entry of the glob, the lock must be held.
+func (dr *DomainRules) remove(glob string) {
+	for i, entry := range dr.entries {
+		if entry.glob == glob {
+			dr.entries = append(dr.entries[:i], dr.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// specificity returns the number of characters of the glob that are not wildcards.
+func specificity(glob string) int {
+	return len(glob) - strings.Count(glob, "*") - strings.Count(glob, "?")
+}
@@ -0,0 +1,213 @@
+package colibri
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Categories of the error codes, see ErrorCode.
+const (
+	// CategoryWarning expected conditions, see Warning.
+	CategoryWarning = "warning"
+
+	// CategoryRules invalid rules or values of the rules.
+	CategoryRules = "rules"
+
+	// CategoryNetwork errors of the requests.
+	CategoryNetwork = "network"
+
+	// CategoryParse errors of the parses of the responses.
+	CategoryParse = "parse"
+
+	// CategoryPolicy requests refused by the configuration, e.g. blocked hosts.
+	CategoryPolicy = "policy"
+
+	// CategoryInternal errors of the configuration of Colibri and unknown errors.
+	CategoryInternal = "internal"
+)
+
+// Stable codes of the errors, see ErrorCode.
+const (
+	CodeUnknown         = "UNKNOWN"
+	CodeNotConfigured   = "NOT_CONFIGURED"
+	CodeCanceled        = "CANCELED"
+	CodeTimeout         = "TIMEOUT"
+	CodeNetwork         = "NETWORK"
+	CodeParse           = "PARSE"
+	CodeInvalidRules    = "INVALID_RULES"
+	CodeUndeclaredVar   = "UNDECLARED_VAR"
+	CodeHostBlocked     = "HOST_BLOCKED"
+	CodeParseTimeout    = "PARSE_TIMEOUT"
+	CodeRobotsDenied    = "ROBOTS_DENIED"
+	CodeURLFiltered     = "URL_FILTERED"
+	CodeResponseSkipped = "RESPONSE_SKIPPED"
+	CodeSelectorEmpty   = "SELECTOR_EMPTY"
+	CodeBodyTruncated   = "BODY_TRUNCATED"
+	CodeDepthExceeded   = "DEPTH_EXCEEDED"
+	CodeBudgetExceeded  = "REQUEST_BUDGET_EXCEEDED"
+)
+
+// warningCodes are the codes of the kinds of the Warnings.
+var warningCodes = map[string]string{
+	WarningRobotsTxt: CodeRobotsDenied,
+	WarningFiltered:  CodeURLFiltered,
+	WarningSkipped:   CodeResponseSkipped,
+	WarningEmpty:     CodeSelectorEmpty,
+	WarningTruncated: CodeBodyTruncated,
+}
+
+// errorCode is the code of the errors that match the target with errors.Is.
+type errorCode struct {
+	target         error
+	code, category string
+}
+
+var (
+	errorCodesMu sync.RWMutex
+	errorCodes   = []errorCode{
+		{ErrColibriIsNil, CodeNotConfigured, CategoryInternal},
+		{ErrClientIsNil, CodeNotConfigured, CategoryInternal},
+		{ErrParserIsNil, CodeNotConfigured, CategoryInternal},
+		{ErrRulesReleased, CodeInvalidRules, CategoryRules},
+		{ErrRulesIsNil, CodeInvalidRules, CategoryRules},
+		{ErrParseTimeout, CodeParseTimeout, CategoryParse},
+		{ErrHostBlocked, CodeHostBlocked, CategoryPolicy},
+		{ErrDepthExceeded, CodeDepthExceeded, CategoryPolicy},
+		{ErrBudgetExceeded, CodeBudgetExceeded, CategoryPolicy},
+		{ErrUndeclaredVar, CodeUndeclaredVar, CategoryRules},
+		{ErrNotAssignable, CodeInvalidRules, CategoryRules},
+		{ErrMustBeConvBool, CodeInvalidRules, CategoryRules},
+		{ErrMustBeConvDuration, CodeInvalidRules, CategoryRules},
+		{ErrMustBeConvInt, CodeInvalidRules, CategoryRules},
+		{ErrMustBeConvStatusCodes, CodeInvalidRules, CategoryRules},
+		{ErrMustBeString, CodeInvalidRules, CategoryRules},
+		{ErrInvalidHeader, CodeInvalidRules, CategoryRules},
+		{ErrMustBeConvPipes, CodeInvalidRules, CategoryRules},
+		{ErrMustBeConvRegexps, CodeInvalidRules, CategoryRules},
+		{ErrMustBeConvVars, CodeInvalidRules, CategoryRules},
+		{ErrMustBeConvTLS, CodeInvalidRules, CategoryRules},
+		{ErrTLSVersion, CodeInvalidRules, CategoryRules},
+		{ErrNoCertificates, CodeInvalidRules, CategoryRules},
+		{context.Canceled, CodeCanceled, CategoryInternal},
+		{context.DeadlineExceeded, CodeTimeout, CategoryNetwork},
+	}
+)
+
+// RegisterErrorCode registers the code and the category of the errors that match
+// the target with errors.Is, e.g. the errors of a package. The codes registered
+// later take precedence over the previous ones.
+func RegisterErrorCode(target error, code, category string) {
+	if (target == nil) || (code == "") {
+		return
+	}
+
+	errorCodesMu.Lock()
+	errorCodes = append(errorCodes, errorCode{target, code, category})
+	errorCodesMu.Unlock()
+}
+
+// ErrorCode returns the stable code and the category of the error, e.g. CodeRobotsDenied
+// and CategoryWarning. The Warnings have the code of their kind, the other errors
+// the code registered with RegisterErrorCode. The network errors and the NetworkErrors without
+// a registered code are CodeNetwork or CodeTimeout, the ParseErrors CodeParse and the unknown errors CodeUnknown.
+func ErrorCode(err error) (code, category string) {
+	if err == nil {
+		return "", ""
+	}
+
+	if w, ok := AsWarning(err); ok {
+		if code, ok := warningCodes[w.Kind]; ok {
+			return code, CategoryWarning
+		}
+		return CodeUnknown, CategoryWarning
+	}
+
+	errorCodesMu.RLock()
+	for i := len(errorCodes) - 1; i >= 0; i-- {
+		if errors.Is(err, errorCodes[i].target) {
+			errorCodesMu.RUnlock()
+			return errorCodes[i].code, errorCodes[i].category
+		}
+	}
+	errorCodesMu.RUnlock()
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return CodeTimeout, CategoryNetwork
+		}
+		return CodeNetwork, CategoryNetwork
+	}
+
+	var networkErr *NetworkError
+	if errors.As(err, &networkErr) {
+		return CodeNetwork, CategoryNetwork
+	}
+
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return CodeParse, CategoryParse
+	}
+	return CodeUnknown, CategoryInternal
+}
+
+// ErrorDetail is the machine-readable representation of an error.
+type ErrorDetail struct {
+	Code     string `json:"code"`
+	Category string `json:"category"`
+	Msg      string `json:"msg"`
+	TraceID  string `json:"trace_id,omitempty"`
+}
+
+// DetailOf returns the ErrorDetail of the error, the message can be customized with SetMessageFunc.
+// The trace ID is the one of the error, see TraceIDOf.
+func DetailOf(err error) ErrorDetail {
+	var fn MessageFunc
+	if p := messageFunc.Load(); p != nil {
+		fn = *p
+	}
+	return detailOf("", err, fn, TraceIDOf(err))
+}
+
+func detailOf(path string, err error, fn MessageFunc, traceID string) ErrorDetail {
+	code, category := ErrorCode(err)
+	detail := ErrorDetail{Code: code, Category: category, Msg: err.Error(), TraceID: traceID}
+	if fn != nil {
+		detail.Msg = fn(path, err)
+	}
+	return detail
+}
+
+// Details returns the errors as nested maps with the same keys as MarshalJSON
+// and an ErrorDetail instead of each message, so the errors can be filtered by code
+// and category, e.g. {"title": {"code": "SELECTOR_EMPTY", "category": "warning", "msg": "..."}}.
+// The messages can be customized with SetMessageFunc and the details have the trace ID of the Errs.
+func (errs *Errs) Details() map[string]any {
+	var fn MessageFunc
+	if p := messageFunc.Load(); p != nil {
+		fn = *p
+	}
+	return errs.details("", fn, errs.TraceID())
+}
+
+func (errs *Errs) details(prefix string, fn MessageFunc, traceID string) map[string]any {
+	errs.rw.RLock()
+	defer errs.rw.RUnlock()
+
+	result := make(map[string]any, len(errs.data))
+	for key, err := range errs.data {
+		path := key
+		if prefix != "" {
+			path = prefix + PathSeparator + key
+		}
+
+		if sub, ok := err.(*Errs); ok {
+			result[key] = sub.details(path, fn, traceID)
+			continue
+		}
+		result[key] = detailOf(path, err, fn, traceID)
+	}
+	return result
+}
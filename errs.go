@@ -2,10 +2,15 @@ package colibri
 
 import (
 	"encoding/json"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
+// PathSeparator separates the keys of the nested errors in the paths, see Errs.Get.
+const PathSeparator = "."
+
 // AddError adds an error to the existing error set.
 // If errs or err is null or the key is empty, no operation is performed.
 // If errs is not of type *Err, a new error of type *Err is returned
@@ -29,8 +34,24 @@ func AddError(errs error, key string, err error) error {
 
 // Errs is a structure that stores and manages errors.
 type Errs struct {
-	rw   sync.RWMutex
-	data map[string]error
+	rw      sync.RWMutex
+	data    map[string]error
+	traceID string
+}
+
+// TraceID returns the trace ID of the request in which the errors occurred, see SetTraceID.
+func (errs *Errs) TraceID() string {
+	errs.rw.RLock()
+	defer errs.rw.RUnlock()
+	return errs.traceID
+}
+
+// SetTraceID sets the trace ID of the request in which the errors occurred,
+// Extract sets it to the Errs of the parse.
+func (errs *Errs) SetTraceID(id string) {
+	errs.rw.Lock()
+	errs.traceID = id
+	errs.rw.Unlock()
 }
 
 // Add adds an error to the error set.
@@ -63,32 +84,173 @@ func (errs *Errs) Add(key string, err error) *Errs {
 
 // Get returns the error associated with a key and
 // a boolean indicating whether the key exists.
+// The key can be a path of nested errors separated by PathSeparator ("a.b.c"),
+// keys that contain the separator (like URLs) are matched first.
 // If the key does not exist, a null error and false are returned.
 func (errs *Errs) Get(key string) (err error, ok bool) {
 	errs.rw.RLock()
 	err, ok = errs.data[key]
 	errs.rw.RUnlock()
-	return err, ok
+	if ok {
+		return err, ok
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] != PathSeparator[0] {
+			continue
+		}
+
+		errs.rw.RLock()
+		sub, ok := errs.data[key[:i]].(*Errs)
+		errs.rw.RUnlock()
+		if !ok {
+			continue
+		}
+
+		if err, ok := sub.Get(key[i+1:]); ok {
+			return err, ok
+		}
+	}
+	return nil, false
+}
+
+// Flatten returns the messages of the errors by path,
+// the keys of the nested errors are joined with PathSeparator.
+func (errs *Errs) Flatten() map[string]string {
+	result := make(map[string]string)
+	errs.flatten("", result)
+	return result
+}
+
+func (errs *Errs) flatten(prefix string, result map[string]string) {
+	errs.rw.RLock()
+	defer errs.rw.RUnlock()
+
+	for key, err := range errs.data {
+		if prefix != "" {
+			key = prefix + PathSeparator + key
+		}
+
+		if sub, ok := err.(*Errs); ok {
+			sub.flatten(key, result)
+			continue
+		}
+		result[key] = err.Error()
+	}
+}
+
+// Unwrap returns the stored errors sorted by key, so errors.Is and errors.As
+// find the original errors, including those of the nested Errs, e.g. of the Follow selectors.
+func (errs *Errs) Unwrap() []error {
+	keys := errs.Keys()
+
+	errs.rw.RLock()
+	defer errs.rw.RUnlock()
+
+	result := make([]error, 0, len(keys))
+	for _, key := range keys {
+		if err, ok := errs.data[key]; ok {
+			result = append(result, err)
+		}
+	}
+	return result
+}
+
+// Len returns the number of errors, including the nested errors.
+func (errs *Errs) Len() int {
+	errs.rw.RLock()
+	defer errs.rw.RUnlock()
+
+	var n int
+	for _, err := range errs.data {
+		if sub, ok := err.(*Errs); ok {
+			n += sub.Len()
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// Keys returns the sorted keys of the stored errors.
+func (errs *Errs) Keys() []string {
+	errs.rw.RLock()
+	keys := make([]string, 0, len(errs.data))
+	for key := range errs.data {
+		keys = append(keys, key)
+	}
+	errs.rw.RUnlock()
+
+	sort.Strings(keys)
+	return keys
 }
 
-// Error returns a string representation of errors stored in JSON format.
+// Error returns a string representation of errors stored in JSON format,
+// the messages without their codes, e.g. {"title": "..."}. See MarshalJSON.
+// The messages can be customized with SetMessageFunc.
 func (errs *Errs) Error() string {
-	b, _ := errs.MarshalJSON()
+	var fn MessageFunc
+	if p := messageFunc.Load(); p != nil {
+		fn = *p
+	}
+
+	b, _ := json.Marshal(errs.messages("", fn))
 	return string(b)
 }
 
-// MarshalJSON returns the JSON representation of the stored errors.
+// MarshalJSON returns the JSON representation of the stored errors,
+// an ErrorDetail with the code, the category and the message of each error,
+// e.g. {"title": {"code": "SELECTOR_EMPTY", "category": "warning", "msg": "..."}}, see Details.
+// The keys are sorted, so the output is stable.
+// The messages can be customized with SetMessageFunc.
 func (errs *Errs) MarshalJSON() ([]byte, error) {
-	errs.rw.Lock()
-	defer errs.rw.Unlock()
+	return json.Marshal(errs.Details())
+}
+
+func (errs *Errs) messages(prefix string, fn MessageFunc) map[string]any {
+	errs.rw.RLock()
+	defer errs.rw.RUnlock()
 
 	errsMap := make(map[string]any, len(errs.data))
 	for key, err := range errs.data {
-		if e, ok := err.(json.Marshaler); ok {
-			errsMap[key] = e
+		path := key
+		if prefix != "" {
+			path = prefix + PathSeparator + key
+		}
+
+		switch e := err.(type) {
+		case *Errs:
+			errsMap[key] = e.messages(path, fn)
+			continue
+
+		case json.Marshaler:
+			if fn == nil {
+				errsMap[key] = e
+				continue
+			}
+		}
+
+		if fn != nil {
+			errsMap[key] = fn(path, err)
 			continue
 		}
 		errsMap[key] = err.Error()
 	}
-	return json.Marshal(errsMap)
+	return errsMap
+}
+
+// MessageFunc returns the message of the error stored in the path, see Errs.Get.
+type MessageFunc func(path string, err error) string
+
+var messageFunc atomic.Pointer[MessageFunc]
+
+// SetMessageFunc sets the function used to customize the messages of the errors
+// (translate them, add links to the documentation, ...) when Errs is serialized.
+// If fn is nil, the messages of the errors are used.
+func SetMessageFunc(fn MessageFunc) {
+	if fn == nil {
+		messageFunc.Store(nil)
+		return
+	}
+	messageFunc.Store(&fn)
 }
@@ -0,0 +1,108 @@
+package colibri
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// NetworkError is an error of the Client while the request was made, e.g. a DNS or a TLS error.
+// Do returns the errors of the Client wrapped in it, except the Warnings and the errors of the context.
+type NetworkError struct {
+	// URL of the request.
+	URL *url.URL
+
+	// Err is the error of the Client.
+	Err error
+}
+
+func (err *NetworkError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *NetworkError) Unwrap() error {
+	return err.Err
+}
+
+// ParseError is an error of a selector while the response was parsed.
+// Extract wraps the errors of the Errs of the parse in it, except the errors
+// of the Follow requests, which keep their own type.
+type ParseError struct {
+	// URL of the response.
+	URL *url.URL
+
+	// Key is the path of the error in the Errs, see Errs.Get.
+	Key string
+
+	// Err is the error of the Parser.
+	Err error
+}
+
+func (err *ParseError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// RobotsError is the cause of the Warning of kind WarningRobotsTxt,
+// the robots.txt does not allow the request.
+type RobotsError struct {
+	// URL of the request.
+	URL *url.URL
+
+	// Err is the error of the RobotsTxt.
+	Err error
+}
+
+func (err *RobotsError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *RobotsError) Unwrap() error {
+	return err.Err
+}
+
+// typedError returns true if the error already has a type: a NetworkError, a ParseError,
+// a RobotsError, a Warning or an error of the context, it is not wrapped again.
+func typedError(err error) bool {
+	var (
+		networkErr *NetworkError
+		parseErr   *ParseError
+		robotsErr  *RobotsError
+		w          *Warning
+	)
+	return errors.As(err, &networkErr) || errors.As(err, &parseErr) || errors.As(err, &robotsErr) ||
+		errors.As(err, &w) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// wrapParseErrors wraps the errors of the Errs of the parse of the response in ParseErrors.
+func wrapParseErrors(resp Response, err error) {
+	if errs, ok := err.(*Errs); ok {
+		errs.wrapParseErrors("", resp.URL())
+	}
+}
+
+// wrapParseErrors wraps the errors of the Errs that do not have a type in a ParseError
+// with the URL of the response, including the errors of the nested Errs.
+func (errs *Errs) wrapParseErrors(prefix string, u *url.URL) {
+	errs.rw.Lock()
+	defer errs.rw.Unlock()
+
+	for key, err := range errs.data {
+		path := key
+		if prefix != "" {
+			path = prefix + PathSeparator + key
+		}
+
+		if sub, ok := err.(*Errs); ok {
+			sub.wrapParseErrors(path, u)
+			continue
+		}
+
+		if !typedError(err) {
+			errs.data[key] = &ParseError{URL: u, Key: path, Err: err}
+		}
+	}
+}
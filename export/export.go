@@ -0,0 +1,191 @@
+// export encodes the outputs of Extract in JSON Lines and CSV formats,
+// so the results can be written to files or piped to other programs.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSeparator is the separator used by default to join the keys of the nested values.
+const DefaultSeparator = "."
+
+// ErrNoColumns is returned when the columns of the CSV can not be determined.
+var ErrNoColumns = errors.New("no columns")
+
+// Encoder encodes the outputs of Extract.
+type Encoder interface {
+	// Encode writes the output.
+	Encode(output map[string]any) error
+}
+
+// Flattener flattens the nested maps and lists of the outputs into a map with a single level.
+// The keys of the nested values are joined, the items of the lists use their index as key.
+// For example, {"a": {"b": 1}, "c": ["x", "y"]} is flattened to {"a.b": 1, "c.0": "x", "c.1": "y"}.
+type Flattener struct {
+	// Separator joins the keys of the nested values, DefaultSeparator if empty.
+	Separator string
+
+	// Join joins the keys of the nested values, if not nil Separator is not used.
+	Join func(keys []string) string
+}
+
+// Flatten returns the flattened output, the empty maps and lists are omitted.
+func (flattener *Flattener) Flatten(output map[string]any) map[string]any {
+	flat := make(map[string]any, len(output))
+	flattener.flatten(flat, nil, output)
+	return flat
+}
+
+func (flattener *Flattener) flatten(flat map[string]any, keys []string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			flattener.flatten(flat, append(keys[:len(keys):len(keys)], key), item)
+		}
+
+	case []any:
+		for i, item := range v {
+			flattener.flatten(flat, append(keys[:len(keys):len(keys)], strconv.Itoa(i)), item)
+		}
+
+	default:
+		flat[flattener.join(keys)] = v
+	}
+}
+
+func (flattener *Flattener) join(keys []string) string {
+	if flattener.Join != nil {
+		return flattener.Join(keys)
+	}
+
+	sep := flattener.Separator
+	if sep == "" {
+		sep = DefaultSeparator
+	}
+	return strings.Join(keys, sep)
+}
+
+// JSONLines encodes each output as a JSON object followed by a newline.
+type JSONLines struct {
+	// Flattener flattens the nested maps and lists of the outputs, if nil they are not flattened.
+	Flattener *Flattener
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NDJSON is newline delimited JSON, the same format as JSON Lines.
+type NDJSON = JSONLines
+
+// NewJSONLines returns a new JSONLines that writes the outputs to w.
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{w: w}
+}
+
+// NewNDJSON returns a new NDJSON that writes the outputs to w.
+func NewNDJSON(w io.Writer) *NDJSON {
+	return NewJSONLines(w)
+}
+
+func (enc *JSONLines) Encode(output map[string]any) error {
+	if enc.Flattener != nil {
+		output = enc.Flattener.Flatten(output)
+	}
+
+	b, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+
+	_, err = enc.w.Write(append(b, '\n'))
+	return err
+}
+
+// CSV encodes each output as a record of a CSV file, the nested maps and lists are flattened
+// with the Flattener. The first record is the header with the names of the columns.
+type CSV struct {
+	// Columns are the keys of the flattened outputs written as columns, in order.
+	// If nil, the columns are the keys of the first output in increasing order.
+	// The keys that are not columns are ignored.
+	Columns []string
+
+	// Flattener flattens the nested maps and lists of the outputs.
+	Flattener Flattener
+
+	mu     sync.Mutex
+	w      *csv.Writer
+	header bool
+}
+
+// NewCSV returns a new CSV that writes the outputs to w.
+func NewCSV(w io.Writer) *CSV {
+	return &CSV{w: csv.NewWriter(w)}
+}
+
+func (enc *CSV) Encode(output map[string]any) error {
+	flat := enc.Flattener.Flatten(output)
+
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+
+	if !enc.header {
+		if enc.Columns == nil {
+			for key := range flat {
+				enc.Columns = append(enc.Columns, key)
+			}
+			sort.Strings(enc.Columns)
+		}
+
+		if len(enc.Columns) == 0 {
+			return ErrNoColumns
+		}
+
+		if err := enc.w.Write(enc.Columns); err != nil {
+			return err
+		}
+		enc.header = true
+	}
+
+	record := make([]string, len(enc.Columns))
+	for i, column := range enc.Columns {
+		record[i] = formatValue(flat[column])
+	}
+
+	if err := enc.w.Write(record); err != nil {
+		return err
+	}
+
+	enc.w.Flush()
+	return enc.w.Error()
+}
+
+// formatValue formats the value of a column, nil is an empty string.
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
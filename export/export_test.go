@@ -0,0 +1,128 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testOutputs = []map[string]any{
+	{
+		"title": "Mug",
+		"price": 9.5,
+		"tags":  []any{"kitchen", "gift"},
+		"seller": map[string]any{
+			"name": "Acme, Inc.",
+			"id":   float64(42),
+		},
+	},
+	{
+		"title": "Lamp",
+		"price": float64(1200000),
+		"tags":  []any{},
+		"date":  time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+	},
+}
+
+func TestFlattener(t *testing.T) {
+	tests := []struct {
+		Flattener Flattener
+		Want      map[string]any
+	}{
+		{
+			Flattener{},
+			map[string]any{"title": "Mug", "price": 9.5, "tags.0": "kitchen", "tags.1": "gift", "seller.name": "Acme, Inc.", "seller.id": float64(42)},
+		},
+		{
+			Flattener{Separator: "_"},
+			map[string]any{"title": "Mug", "price": 9.5, "tags_0": "kitchen", "tags_1": "gift", "seller_name": "Acme, Inc.", "seller_id": float64(42)},
+		},
+		{
+			Flattener{Join: func(keys []string) string { return keys[0] + "[" + strings.Join(keys[1:], "][") + "]" }},
+			map[string]any{"title[]": "Mug", "price[]": 9.5, "tags[0]": "kitchen", "tags[1]": "gift", "seller[name]": "Acme, Inc.", "seller[id]": float64(42)},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.Flattener.Flatten(testOutputs[0]); !reflect.DeepEqual(got, tt.Want) {
+			t.Fatalf("got %v, want %v", got, tt.Want)
+		}
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	tests := []struct {
+		Flattener *Flattener
+		Want      string
+	}{
+		{
+			nil,
+			`{"price":9.5,"seller":{"id":42,"name":"Acme, Inc."},"tags":["kitchen","gift"],"title":"Mug"}` + "\n" +
+				`{"date":"2023-01-02T03:04:05Z","price":1200000,"tags":[],"title":"Lamp"}` + "\n",
+		},
+		{
+			&Flattener{},
+			`{"price":9.5,"seller.id":42,"seller.name":"Acme, Inc.","tags.0":"kitchen","tags.1":"gift","title":"Mug"}` + "\n" +
+				`{"date":"2023-01-02T03:04:05Z","price":1200000,"title":"Lamp"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		enc := NewNDJSON(&buf)
+		enc.Flattener = tt.Flattener
+
+		for _, output := range testOutputs {
+			if err := enc.Encode(output); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := buf.String(); got != tt.Want {
+			t.Fatalf("got %v, want %v", got, tt.Want)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	tests := []struct {
+		Columns []string
+		Want    string
+	}{
+		{
+			nil,
+			"price,seller.id,seller.name,tags.0,tags.1,title\n" +
+				"9.5,42,\"Acme, Inc.\",kitchen,gift,Mug\n" +
+				"1200000,,,,,Lamp\n",
+		},
+		{
+			[]string{"title", "date", "tags.0"},
+			"title,date,tags.0\n" +
+				"Mug,,kitchen\n" +
+				"Lamp,2023-01-02T03:04:05Z,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		enc := NewCSV(&buf)
+		enc.Columns = tt.Columns
+
+		for _, output := range testOutputs {
+			if err := enc.Encode(output); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := buf.String(); got != tt.Want {
+			t.Fatalf("got %v, want %v", got, tt.Want)
+		}
+	}
+
+	if err := NewCSV(&bytes.Buffer{}).Encode(nil); !errors.Is(err, ErrNoColumns) {
+		t.Fatalf("got %v, want %v", err, ErrNoColumns)
+	}
+}
@@ -0,0 +1,126 @@
+package colibri
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Reasons of the responses skipped by the FetchFilters, see SkipError.
+const (
+	// SkipReasonStatus the status code of the response is rejected.
+	SkipReasonStatus = "status"
+
+	// SkipReasonMIME the media type of the response is rejected.
+	SkipReasonMIME = "mime"
+
+	// SkipReasonSize the response body is too large.
+	SkipReasonSize = "size"
+)
+
+// ErrSkipped is the error matched by SkipError with errors.Is.
+var ErrSkipped = errors.New("response skipped")
+
+// SkipError is returned by Extract when a FetchFilter rejects the response,
+// the response is returned without being parsed.
+type SkipError struct {
+	// Reason why the response was skipped, e.g. SkipReasonMIME.
+	Reason string
+
+	// Detail describes the value rejected, e.g. the media type.
+	Detail string
+
+	// URL of the response.
+	URL *url.URL
+}
+
+func (err *SkipError) Error() string {
+	return fmt.Sprintf("%v (%s: %s): %v", ErrSkipped, err.Reason, err.Detail, err.URL)
+}
+
+// Is returns true if the target is ErrSkipped.
+func (err *SkipError) Is(target error) bool {
+	return target == ErrSkipped
+}
+
+// FetchFilter is called with the response before it is parsed,
+// the response is skipped if it returns a SkipError. See Colibri.FetchFilters.
+type FetchFilter func(rules *Rules, resp Response) *SkipError
+
+// SkipStatus returns a FetchFilter that skips the responses with the status codes.
+func SkipStatus(statusCodes ...int) FetchFilter {
+	return func(_ *Rules, resp Response) *SkipError {
+		if slices.Contains(statusCodes, resp.StatusCode()) {
+			return &SkipError{Reason: SkipReasonStatus, Detail: strconv.Itoa(resp.StatusCode()), URL: resp.URL()}
+		}
+		return nil
+	}
+}
+
+// SkipMIME returns a FetchFilter that skips the responses whose media type matches a pattern,
+// e.g. "image/*". The patterns use the syntax of path.Match, the parameters of the
+// Content-Type are ignored.
+func SkipMIME(patterns ...string) FetchFilter {
+	return func(_ *Rules, resp Response) *SkipError {
+		if mediaType := mediaType(resp); matchMIME(patterns, mediaType) {
+			return &SkipError{Reason: SkipReasonMIME, Detail: mediaType, URL: resp.URL()}
+		}
+		return nil
+	}
+}
+
+// SkipLarger returns a FetchFilter that skips the responses whose Content-Length exceeds maxBytes.
+// If patterns are specified, only the responses whose media type matches a pattern are
+// verified, see SkipMIME. The responses without Content-Length are not skipped,
+// use Rules.MaxBodySize to limit the bytes read.
+func SkipLarger(maxBytes int64, patterns ...string) FetchFilter {
+	return func(_ *Rules, resp Response) *SkipError {
+		if (len(patterns) > 0) && !matchMIME(patterns, mediaType(resp)) {
+			return nil
+		}
+
+		size, err := strconv.ParseInt(resp.Header().Get("Content-Length"), 10, 64)
+		if (err == nil) && (size > maxBytes) {
+			return &SkipError{Reason: SkipReasonSize, Detail: strconv.FormatInt(size, 10), URL: resp.URL()}
+		}
+		return nil
+	}
+}
+
+// filterFetch returns the first SkipError of the FetchFilters, nil if the response can be parsed.
+func (c *Colibri) filterFetch(rules *Rules, resp Response) error {
+	for _, filter := range c.FetchFilters {
+		if filter == nil {
+			continue
+		}
+
+		if skipErr := filter(rules, resp); skipErr != nil {
+			return skipErr
+		}
+	}
+	return nil
+}
+
+// mediaType returns the media type of the Content-Type of the response in lowercase.
+func mediaType(resp Response) string {
+	contentType := resp.Header().Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// matchMIME returns true if the media type matches a pattern.
+func matchMIME(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package colibri
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+var (
+	// ErrUnsupportedKind is returned when the kind of the field schema is not supported.
+	ErrUnsupportedKind = errors.New("unsupported kind")
+
+	// ErrReservedField is returned when the field name is a field of Rules or Selector.
+	ErrReservedField = errors.New("reserved field name")
+)
+
+// FieldSchema specifies the kind and the validator of a custom field.
+// The custom fields are the keys of the raw rules and selectors
+// that are stored in Fields.
+type FieldSchema struct {
+	// Kind the raw value is converted to, see Decode.
+	// Supports the boolean, numeric and string kinds, Slice ([]any) and Map (map[string]any).
+	// If it is reflect.Invalid, the value is not converted.
+	Kind reflect.Kind
+
+	// Validate validates the converted value, can be nil.
+	Validate func(value any) error
+}
+
+var fieldSchemas = struct {
+	rw   sync.RWMutex
+	data map[string]FieldSchema
+}{data: make(map[string]FieldSchema)}
+
+var kindTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.String:  reflect.TypeOf(""),
+	reflect.Slice:   reflect.TypeOf([]any{}),
+	reflect.Map:     reflect.TypeOf(map[string]any{}),
+}
+
+// RegisterField registers the schema of a custom field.
+// NewRules validates and converts the values of the registered fields
+// of the rules and the selectors, replaces the schema if it already exists.
+func RegisterField(name string, schema FieldSchema) error {
+	if _, ok := kindTypes[schema.Kind]; !ok && (schema.Kind != reflect.Invalid) {
+		return ErrUnsupportedKind
+	}
+
+	if isReservedField(name) {
+		return ErrReservedField
+	}
+
+	fieldSchemas.rw.Lock()
+	fieldSchemas.data[name] = schema
+	fieldSchemas.rw.Unlock()
+	return nil
+}
+
+// UnregisterField removes the schema of a custom field.
+func UnregisterField(name string) {
+	fieldSchemas.rw.Lock()
+	delete(fieldSchemas.data, name)
+	fieldSchemas.rw.Unlock()
+}
+
+// convertField converts and validates the value of the custom field.
+// If the field is not registered, the value is returned unchanged.
+func convertField(name string, value any) (any, error) {
+	fieldSchemas.rw.RLock()
+	schema, ok := fieldSchemas.data[name]
+	fieldSchemas.rw.RUnlock()
+	if !ok {
+		return value, nil
+	}
+
+	if t, ok := kindTypes[schema.Kind]; ok {
+		rv := reflect.New(t).Elem()
+		if err := decodeValue(value, rv, nil); err != nil {
+			return nil, err
+		}
+		value = rv.Interface()
+	}
+
+	if schema.Validate != nil {
+		if err := schema.Validate(value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+func isReservedField(name string) bool {
+	if (name == "") || (name == KeyVersion) {
+		return true
+	}
+
+	_, inRules := reflect.TypeOf(Rules{}).FieldByName(name)
+	_, inSelector := reflect.TypeOf(Selector{}).FieldByName(name)
+	return inRules || inSelector
+}
@@ -0,0 +1,50 @@
+package colibri
+
+import (
+	"net/url"
+	"regexp"
+)
+
+// AllowsURL returns true if the URL does not match any regular expression of Deny and,
+// if Allow is not empty, matches one of Allow. The URL is compared as a string.
+func (rules *Rules) AllowsURL(u *url.URL) bool {
+	return (rules == nil) || allowsURL(rules.Allow, rules.Deny, u)
+}
+
+// AllowsURL returns true if the URL does not match any regular expression of Deny and,
+// if Allow is not empty, matches one of Allow. The URL is compared as a string.
+func (selector *Selector) AllowsURL(u *url.URL) bool {
+	return (selector == nil) || allowsURL(selector.Allow, selector.Deny, u)
+}
+
+// FollowAllowed returns true if the Follow selector can follow the URL found with the rules,
+// the URL must be allowed by the rules and by the selector.
+func FollowAllowed(rules *Rules, selector *Selector, u *url.URL) bool {
+	return rules.AllowsURL(u) && selector.AllowsURL(u)
+}
+
+func allowsURL(allow, deny []*regexp.Regexp, u *url.URL) bool {
+	if (len(allow) == 0) && (len(deny) == 0) {
+		return true
+	} else if u == nil {
+		return false
+	}
+
+	rawURL := u.String()
+	for _, re := range deny {
+		if re.MatchString(rawURL) {
+			return false
+		}
+	}
+
+	if len(allow) == 0 {
+		return true
+	}
+
+	for _, re := range allow {
+		if re.MatchString(rawURL) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package colibri
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// The wrappers of the components of Colibri, e.g. the Client of the stats package,
+// forward the calls to the wrapped component with the following functions, so they support
+// the optional interfaces of the component in the same way that Colibri does.
+
+// Sitemapper is implemented by the robots.txt parsers that get the Sitemap directives of the hosts.
+type Sitemapper interface {
+	// Sitemaps returns the URLs of the Sitemap directives of the robots.txt of the host, nil if none.
+	Sitemaps(host string) []string
+}
+
+// ReportCapabilitiesOf adds the capabilities of the component to the report
+// if it implements CapabilityReporter.
+func ReportCapabilitiesOf(component any, caps *Capabilities) {
+	if reporter, ok := component.(CapabilityReporter); ok {
+		reporter.ReportCapabilities(caps)
+	}
+}
+
+// ClientDo calls DoContext of the client if it implements HTTPClientContext,
+// otherwise Do is called if the context is not cancelled.
+func ClientDo(ctx context.Context, client HTTPClient, c *Colibri, rules *Rules) (Response, error) {
+	if inner, ok := client.(HTTPClientContext); ok {
+		return inner.DoContext(ctx, c, rules)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return client.Do(c, rules)
+}
+
+// RobotsIsAllowed calls IsAllowedContext of the robots.txt parser if it implements
+// RobotsTxtContext, otherwise IsAllowed is called.
+func RobotsIsAllowed(ctx context.Context, robots RobotsTxt, c *Colibri, rules *Rules) error {
+	if inner, ok := robots.(RobotsTxtContext); ok {
+		return inner.IsAllowedContext(ctx, c, rules)
+	}
+	return robots.IsAllowed(c, rules)
+}
+
+// RobotsCrawlDelay returns the crawl delay of the robots.txt parser if it implements
+// CrawlDelayer, otherwise zero.
+func RobotsCrawlDelay(robots RobotsTxt, u *url.URL, userAgent string) time.Duration {
+	if inner, ok := robots.(CrawlDelayer); ok {
+		return inner.CrawlDelay(u, userAgent)
+	}
+	return 0
+}
+
+// RobotsSitemaps returns the URLs of the Sitemap directives of the robots.txt parser
+// if it implements Sitemapper, otherwise nil.
+func RobotsSitemaps(robots RobotsTxt, host string) []string {
+	if inner, ok := robots.(Sitemapper); ok {
+		return inner.Sitemaps(host)
+	}
+	return nil
+}
+
+// DelayWait calls WaitContext of the delay if it implements DelayContext.
+// Otherwise, Wait is called in a goroutine until the context is cancelled,
+// then the wait is abandoned and Done is called when Wait returns.
+// If an error is returned, Done must not be called.
+func DelayWait(ctx context.Context, delay Delay, u *url.URL, duration time.Duration) error {
+	if d, ok := delay.(DelayContext); ok {
+		return d.WaitContext(ctx, u, duration)
+	}
+
+	if ctx.Done() == nil {
+		delay.Wait(u, duration)
+		return nil
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		defer close(waited)
+		delay.Wait(u, duration)
+	}()
+
+	select {
+	case <-waited:
+		return nil
+	case <-ctx.Done():
+		go func() {
+			<-waited
+			delay.Done(u)
+		}()
+		return ctx.Err()
+	}
+}
+
+// DelayObserve calls ObserveResponse of the delay if it implements DelayObserver.
+// The wrappers of a Delay should implement DelayObserver only if the wrapped Delay does,
+// because Colibri uses the DelayObservers with all the requests.
+func DelayObserve(delay Delay, u *url.URL, resp Response, latency time.Duration, err error) {
+	if observer, ok := delay.(DelayObserver); ok {
+		observer.ObserveResponse(u, resp, latency, err)
+	}
+}
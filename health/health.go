@@ -0,0 +1,147 @@
+// health reports the health and readiness of the processes that run Colibri (crawlers, servers, job runners).
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WorkerStatus represents the status of a worker.
+type WorkerStatus string
+
+const (
+	// Idle the worker is waiting for work.
+	Idle WorkerStatus = "idle"
+
+	// Busy the worker is processing work.
+	Busy WorkerStatus = "busy"
+
+	// Stopped the worker is not running.
+	Stopped WorkerStatus = "stopped"
+)
+
+// Health stores the state used to generate the health reports.
+type Health struct {
+	rw         sync.RWMutex
+	start      time.Time
+	ready      bool
+	queueDepth func() int
+	workers    map[string]Worker
+	lastErr    *LastError
+}
+
+// Worker represents the state of a worker.
+type Worker struct {
+	Name      string       `json:"name"`
+	Status    WorkerStatus `json:"status"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+}
+
+// LastError represents the last error observed.
+type LastError struct {
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// Report represents the health and readiness of the process.
+type Report struct {
+	Ready      bool          `json:"ready"`
+	Uptime     time.Duration `json:"uptime"`
+	QueueDepth int           `json:"queueDepth"`
+	Workers    []Worker      `json:"workers"`
+	LastError  *LastError    `json:"lastError,omitempty"`
+}
+
+// New returns a new Health, not ready.
+func New() *Health {
+	return &Health{
+		start:   time.Now(),
+		workers: make(map[string]Worker),
+	}
+}
+
+// SetReady sets whether the process is ready to accept work.
+func (health *Health) SetReady(ready bool) {
+	health.rw.Lock()
+	health.ready = ready
+	health.rw.Unlock()
+}
+
+// SetQueueDepth sets the function used to get the number of pending items of the queue.
+func (health *Health) SetQueueDepth(fn func() int) {
+	health.rw.Lock()
+	health.queueDepth = fn
+	health.rw.Unlock()
+}
+
+// SetWorker sets the status of the worker.
+func (health *Health) SetWorker(name string, status WorkerStatus) {
+	health.rw.Lock()
+	health.workers[name] = Worker{Name: name, Status: status, UpdatedAt: time.Now()}
+	health.rw.Unlock()
+}
+
+// RemoveWorker removes the worker.
+func (health *Health) RemoveWorker(name string) {
+	health.rw.Lock()
+	delete(health.workers, name)
+	health.rw.Unlock()
+}
+
+// ObserveError records the error as the last error.
+func (health *Health) ObserveError(err error) {
+	if err == nil {
+		return
+	}
+
+	health.rw.Lock()
+	health.lastErr = &LastError{Error: err.Error(), Time: time.Now()}
+	health.rw.Unlock()
+}
+
+// Report returns the health report, the workers are sorted by name.
+func (health *Health) Report() *Report {
+	health.rw.RLock()
+	defer health.rw.RUnlock()
+
+	report := &Report{
+		Ready:   health.ready,
+		Uptime:  time.Since(health.start),
+		Workers: make([]Worker, 0, len(health.workers)),
+	}
+
+	if health.queueDepth != nil {
+		report.QueueDepth = health.queueDepth()
+	}
+
+	for _, worker := range health.workers {
+		report.Workers = append(report.Workers, worker)
+	}
+	sort.Slice(report.Workers, func(i, j int) bool {
+		return report.Workers[i].Name < report.Workers[j].Name
+	})
+
+	if health.lastErr != nil {
+		lastErr := *health.lastErr
+		report.LastError = &lastErr
+	}
+	return report
+}
+
+// Handler returns an http.Handler that writes the health report in JSON format.
+// Responds with the status code 503 (Service Unavailable) if the process is not ready.
+func (health *Health) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := health.Report()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !report.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
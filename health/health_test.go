@@ -0,0 +1,54 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthHandler(t *testing.T) {
+	health := New()
+	handler := health.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	health.SetReady(true)
+	health.SetQueueDepth(func() int { return 3 })
+	health.SetWorker("worker-2", Idle)
+	health.SetWorker("worker-1", Busy)
+	health.SetWorker("worker-3", Busy)
+	health.RemoveWorker("worker-3")
+	health.ObserveError(errors.New("timeout"))
+	health.ObserveError(nil) // ignore
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Ready {
+		t.Fatal("not ready")
+	} else if report.QueueDepth != 3 {
+		t.Fatalf("got %v, want %v", report.QueueDepth, 3)
+	} else if (report.LastError == nil) || (report.LastError.Error != "timeout") {
+		t.Fatalf("got %v, want %v", report.LastError, "timeout")
+	}
+
+	if len(report.Workers) != 2 {
+		t.Fatalf("got %v, want %v", len(report.Workers), 2)
+	} else if (report.Workers[0].Name != "worker-1") || (report.Workers[0].Status != Busy) {
+		t.Fatalf("got %v, want %v", report.Workers[0], "worker-1 busy")
+	}
+}
@@ -0,0 +1,147 @@
+package colibri
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+)
+
+// ErrHostBlocked is returned when the host of the URL is blocked, see Colibri.BlockHost.
+var ErrHostBlocked = errors.New("host is blocked")
+
+// hostControl stores the hosts paused and blocked at runtime.
+type hostControl struct {
+	mu      sync.Mutex
+	paused  map[string]chan struct{} // closed when the host is resumed
+	blocked map[string]struct{}
+	changed chan struct{} // closed when a host changes
+}
+
+// PauseHost pauses the requests to the host, they wait until the host is resumed
+// or their context is cancelled. The requests in progress are not interrupted.
+// The host is compared without the port, see NormalizeHost.
+func (c *Colibri) PauseHost(host string) {
+	host = hostKey(host)
+
+	c.hosts.mu.Lock()
+	defer c.hosts.mu.Unlock()
+
+	if c.hosts.paused == nil {
+		c.hosts.paused = make(map[string]chan struct{})
+	}
+
+	if _, ok := c.hosts.paused[host]; !ok {
+		c.hosts.paused[host] = make(chan struct{})
+		c.hosts.notify()
+	}
+}
+
+// BlockHost blocks the requests to the host, they return ErrHostBlocked until the host is resumed.
+// The requests waiting for the host to be resumed, see PauseHost, also return ErrHostBlocked.
+func (c *Colibri) BlockHost(host string) {
+	host = hostKey(host)
+
+	c.hosts.mu.Lock()
+	defer c.hosts.mu.Unlock()
+
+	if resumed, ok := c.hosts.paused[host]; ok {
+		close(resumed)
+		delete(c.hosts.paused, host)
+	}
+
+	if c.hosts.blocked == nil {
+		c.hosts.blocked = make(map[string]struct{})
+	}
+	c.hosts.blocked[host] = struct{}{}
+	c.hosts.notify()
+}
+
+// ResumeHost resumes the requests to the paused or blocked host.
+func (c *Colibri) ResumeHost(host string) {
+	host = hostKey(host)
+
+	c.hosts.mu.Lock()
+	defer c.hosts.mu.Unlock()
+
+	if resumed, ok := c.hosts.paused[host]; ok {
+		close(resumed)
+		delete(c.hosts.paused, host)
+	}
+	delete(c.hosts.blocked, host)
+	c.hosts.notify()
+}
+
+// HostPaused returns true if the host is paused.
+func (c *Colibri) HostPaused(host string) bool {
+	c.hosts.mu.Lock()
+	defer c.hosts.mu.Unlock()
+
+	_, ok := c.hosts.paused[hostKey(host)]
+	return ok
+}
+
+// HostBlocked returns true if the host is blocked.
+func (c *Colibri) HostBlocked(host string) bool {
+	c.hosts.mu.Lock()
+	defer c.hosts.mu.Unlock()
+
+	_, ok := c.hosts.blocked[hostKey(host)]
+	return ok
+}
+
+// HostChanges returns a channel that is closed when a host is paused, blocked or resumed,
+// used by the schedulers to wait for the paused hosts.
+func (c *Colibri) HostChanges() <-chan struct{} {
+	c.hosts.mu.Lock()
+	defer c.hosts.mu.Unlock()
+
+	if c.hosts.changed == nil {
+		c.hosts.changed = make(chan struct{})
+	}
+	return c.hosts.changed
+}
+
+// waitHost waits while the host of the URL is paused.
+// Returns ErrHostBlocked if it is blocked or the error of the context if it is cancelled.
+func (c *Colibri) waitHost(ctx context.Context, u *url.URL) error {
+	if u == nil {
+		return nil
+	}
+
+	host := hostKey(u.Hostname())
+	for {
+		c.hosts.mu.Lock()
+		_, blocked := c.hosts.blocked[host]
+		resumed, paused := c.hosts.paused[host]
+		c.hosts.mu.Unlock()
+
+		if blocked {
+			return ErrHostBlocked
+		} else if !paused {
+			return nil
+		}
+
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// notify closes the channel of HostChanges, the lock must be held.
+func (hosts *hostControl) notify() {
+	if hosts.changed != nil {
+		close(hosts.changed)
+		hosts.changed = nil
+	}
+}
+
+// hostKey returns the normalized host name without the port.
+func hostKey(host string) string {
+	if u, err := url.Parse("//" + host); (err == nil) && (u.Hostname() != "") {
+		host = u.Hostname()
+	}
+	return NormalizeHost(host)
+}
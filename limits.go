@@ -0,0 +1,49 @@
+package colibri
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+var (
+	// ErrDepthExceeded is returned when the URL found by a Follow selector
+	// exceeds the MaxDepth of the rules.
+	ErrDepthExceeded = errors.New("follow depth exceeded")
+
+	// ErrBudgetExceeded is returned when a request of an extraction
+	// exceeds the MaxRequests of the rules.
+	ErrBudgetExceeded = errors.New("request budget exceeded")
+)
+
+// budgetKey is the key of the context in which the request budget is stored.
+type budgetKey struct{}
+
+// requestBudget counts the requests of an extraction, including its Follow requests.
+type requestBudget struct {
+	max  int64
+	used atomic.Int64
+}
+
+// withRequestBudget returns the context with a request budget of the MaxRequests of the rules,
+// if the context does not have one, e.g. of the extraction of the page of a Follow selector.
+func withRequestBudget(ctx context.Context, rules *Rules) context.Context {
+	if (rules == nil) || (rules.MaxRequests <= 0) || (ctx.Value(budgetKey{}) != nil) {
+		return ctx
+	}
+	return context.WithValue(ctx, budgetKey{}, &requestBudget{max: int64(rules.MaxRequests)})
+}
+
+// spendRequest counts a request in the budget of the context,
+// returns ErrBudgetExceeded if the budget is exhausted.
+func spendRequest(ctx context.Context) error {
+	budget, ok := ctx.Value(budgetKey{}).(*requestBudget)
+	if !ok {
+		return nil
+	}
+
+	if budget.used.Add(1) > budget.max {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package colibri
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+// Messages of the events logged with the Logger of Colibri.
+const (
+	// LogRequest the request is going to be made, debug level.
+	LogRequest = "request"
+
+	// LogResponse the response was received, debug level.
+	LogResponse = "response"
+
+	// LogRequestFailed the request returned an error, info level.
+	LogRequestFailed = "request failed"
+
+	// LogRobotsTxtAllowed the robots.txt allows the request, debug level.
+	LogRobotsTxtAllowed = "robots.txt allowed"
+
+	// LogRobotsTxtBlocked the robots.txt does not allow the request, info level.
+	LogRobotsTxtBlocked = "robots.txt blocked"
+
+	// LogCacheHit the response was returned by the Cache, debug level.
+	LogCacheHit = "cache hit"
+
+	// LogDelay the request waited for the Delay, debug level.
+	LogDelay = "delay"
+
+	// LogRateLimit the request waited for the RateLimiter, debug level.
+	LogRateLimit = "rate limit"
+
+	// LogSkipped the response was rejected by a FetchFilter and not parsed, info level.
+	LogSkipped = "skipped"
+
+	// LogParse the response was parsed, debug level or info level if the parse failed.
+	LogParse = "parse"
+)
+
+// log logs the event with the Logger, if it is not nil.
+func (c *Colibri) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if (c.Logger == nil) || !c.Logger.Enabled(ctx, level) {
+		return
+	}
+	c.Logger.LogAttrs(ctx, level, msg, traceAttrs(ctx, attrs)...)
+}
+
+// urlAttr returns the attribute with the URL, empty if it is nil.
+func urlAttr(u *url.URL) slog.Attr {
+	if u == nil {
+		return slog.String("url", "")
+	}
+	return slog.String("url", u.String())
+}
+
+// durationAttr returns the attribute with the time elapsed since start.
+func durationAttr(start time.Time) slog.Attr {
+	return slog.Duration("duration", time.Since(start))
+}
+
+// errorAttr returns the attribute with the error.
+func errorAttr(err error) slog.Attr {
+	return slog.String("error", err.Error())
+}
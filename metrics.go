@@ -0,0 +1,46 @@
+package colibri
+
+import (
+	"context"
+	"io"
+)
+
+// meteredResponse is a response whose body records the bytes read in the Metrics.
+type meteredResponse struct {
+	Response
+	body io.ReadCloser
+}
+
+func newMeteredResponse(resp Response, metrics Metrics) *meteredResponse {
+	return &meteredResponse{
+		Response: resp,
+		body:     &meteredBody{ReadCloser: resp.Body(), resp: resp, metrics: metrics},
+	}
+}
+
+func (resp *meteredResponse) Body() io.ReadCloser {
+	return resp.body
+}
+
+// Context returns the context of the wrapped response, nil if it has none.
+func (resp *meteredResponse) Context() context.Context {
+	if r, ok := resp.Response.(interface{ Context() context.Context }); ok {
+		return r.Context()
+	}
+	return nil
+}
+
+// meteredBody records the bytes read in the Metrics.
+type meteredBody struct {
+	io.ReadCloser
+	resp    Response
+	metrics Metrics
+}
+
+func (body *meteredBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	if n > 0 {
+		body.metrics.ObserveBytes(body.resp.URL(), n)
+	}
+	return n, err
+}
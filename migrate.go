@@ -0,0 +1,138 @@
+package colibri
+
+import (
+	"errors"
+	"fmt"
+)
+
+// KeyVersion is the key of the raw rules that stores the version of the format.
+const KeyVersion = "Version"
+
+// RulesVersion is the current version of the raw rules format.
+// The raw rules without Version have the version 1.
+const RulesVersion = 1
+
+var (
+	// ErrUnsupportedVersion is returned when the version of the raw rules
+	// is newer than the version supported by the Migrator.
+	ErrUnsupportedVersion = errors.New("unsupported rules version")
+
+	// ErrMigrationNotFound is returned when there is no migration from a version to the next one.
+	ErrMigrationNotFound = errors.New("migration not found")
+)
+
+// MigrationFunc upgrades the raw rules from a version to the next one.
+type MigrationFunc func(raw RawRules) error
+
+// Migrator upgrades raw rules documents to the Version.
+type Migrator struct {
+	// Version of the format to which the raw rules are upgraded.
+	Version int
+
+	// Migrations stores the migrations with the version they upgrade from as key.
+	Migrations map[int]MigrationFunc
+}
+
+// DefaultMigrator upgrades the raw rules to RulesVersion, it is used by NewRules.
+var DefaultMigrator = &Migrator{Version: RulesVersion, Migrations: make(map[int]MigrationFunc)}
+
+// Migrate upgrades the raw rules to RulesVersion with DefaultMigrator.
+func Migrate(raw RawRules) (RawRules, error) {
+	return DefaultMigrator.Migrate(raw)
+}
+
+// Migrate returns the raw rules upgraded to the Version, the original raw rules
+// are not modified. The migrations are applied in order from the version of the raw rules.
+func (migrator *Migrator) Migrate(raw RawRules) (RawRules, error) {
+	version := 1
+	if rawVersion, ok := raw[KeyVersion]; ok {
+		v, err := toInt(rawVersion)
+		if (err != nil) || (v < 1) {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedVersion, rawVersion)
+		}
+		version = v
+	}
+
+	if version > migrator.Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	if version == migrator.Version {
+		return raw, nil
+	}
+
+	migrated := RawRules(copyRaw(raw).(map[string]any))
+	for ; version < migrator.Version; version++ {
+		migration, ok := migrator.Migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d to %d", ErrMigrationNotFound, version, version+1)
+		}
+
+		if err := migration(migrated); err != nil {
+			return nil, fmt.Errorf("migration %d to %d: %w", version, version+1, err)
+		}
+	}
+
+	migrated[KeyVersion] = migrator.Version
+	return migrated, nil
+}
+
+// RenameKey returns a MigrationFunc that renames the key of the raw rules
+// and of the raw selectors. The key is not renamed if the new key already exists.
+func RenameKey(oldKey, newKey string) MigrationFunc {
+	var rename func(raw map[string]any)
+	rename = func(raw map[string]any) {
+		if value, ok := raw[oldKey]; ok {
+			if _, exists := raw[newKey]; !exists {
+				raw[newKey] = value
+			}
+			delete(raw, oldKey)
+		}
+
+		selectors, _ := raw[KeySelectors].(map[string]any)
+		for _, selector := range selectors {
+			if s, ok := selector.(map[string]any); ok {
+				rename(s)
+			}
+		}
+	}
+
+	return func(raw RawRules) error {
+		rename(raw)
+		return nil
+	}
+}
+
+// SetDefault returns a MigrationFunc that sets the value of the key of the raw rules
+// if the key does not exist, used to keep the previous default when the default changes.
+func SetDefault(key string, value any) MigrationFunc {
+	return func(raw RawRules) error {
+		if _, ok := raw[key]; !ok {
+			raw[key] = value
+		}
+		return nil
+	}
+}
+
+// copyRaw returns a deep copy of the maps and slices of the raw value.
+func copyRaw(value any) any {
+	switch v := value.(type) {
+	case RawRules:
+		return copyRaw(map[string]any(v))
+
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, e := range v {
+			m[key] = copyRaw(e)
+		}
+		return m
+
+	case []any:
+		s := make([]any, len(v))
+		for i, e := range v {
+			s[i] = copyRaw(e)
+		}
+		return s
+	}
+	return value
+}
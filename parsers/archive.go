@@ -0,0 +1,327 @@
+package parsers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// ArchiveRegexp contains a regular expression that matches the MIME types of the zip and tar archives,
+// including the tar archives compressed with gzip.
+const ArchiveRegexp = `(?i)^application\/(zip|x-zip-compressed|x-tar|x-gtar|gzip|x-gzip|x-compressed-tar)`
+
+// PathExpr type of expression of the archives, a pattern of the paths of the files, see path.Match.
+const PathExpr = "path"
+
+// DefaultMaxArchiveSize default maximum number of bytes of the archives and of their decompressed files.
+const DefaultMaxArchiveSize = 100 << 20
+
+// MaxArchiveSize maximum number of bytes of the archives parsed by ParseArchive,
+// and maximum number of bytes of all their decompressed files.
+// Zero or negative means no limit.
+var MaxArchiveSize int64 = DefaultMaxArchiveSize
+
+// ErrArchiveTooLarge is returned when the archive or its decompressed files exceed MaxArchiveSize.
+var ErrArchiveTooLarge = errors.New("archive exceeds the maximum size")
+
+// ArchiveElement represents a zip or tar archive compatible with path patterns.
+// The files are found with patterns of their paths, e.g. "data/*.csv".
+type ArchiveElement struct {
+	parsers *Parsers
+	resp    colibri.Response
+	files   []*ArchiveFileElement
+}
+
+// ArchiveFileElement represents a file of an archive.
+// The file is parsed with the ParserFunc that matches its type, detected with the extension
+// of the path or the content, and the expressions are evaluated on its root element.
+// The value of the element is the path of the file.
+type ArchiveFileElement struct {
+	parsers *Parsers
+	resp    colibri.Response
+	name    string
+	data    []byte
+
+	root Element
+	err  error
+}
+
+// ParseArchive parses the zip or tar archive of the response and returns the root element.
+// The tar archives compressed with gzip are decompressed, a gzip file that is not a tar
+// archive is a file with the name of the URL without the ".gz" extension.
+// The files are parsed with the ParserFunc of the Parsers, so the archives can contain archives.
+// Returns ErrArchiveTooLarge if the archive or its decompressed files exceed MaxArchiveSize.
+func (parsers *Parsers) ParseArchive(resp colibri.Response) (*ArchiveElement, error) {
+	budget := &archiveBudget{n: MaxArchiveSize}
+
+	data, err := budget.read(resp.Body())
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*ArchiveFileElement
+	add := func(name string, data []byte) {
+		files = append(files, &ArchiveFileElement{parsers: parsers, resp: resp, name: name, data: data})
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")) || bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		err = readZip(data, budget, add)
+
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		data, err = budget.read(gz)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isTar(data) {
+			add(strings.TrimSuffix(path.Base(resp.URL().Path), ".gz"), data)
+			break
+		}
+		err = readTar(data, add)
+
+	default:
+		err = readTar(data, add)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveElement{parsers: parsers, resp: resp, files: files}, nil
+}
+
+func (archive *ArchiveElement) Find(expr, exprType string) (Element, error) {
+	files, err := archive.match(expr, exprType, 1)
+	if (err != nil) || (len(files) == 0) {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+func (archive *ArchiveElement) FindAll(expr, exprType string) ([]Element, error) {
+	files, err := archive.match(expr, exprType, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var elements []Element
+	for _, file := range files {
+		elements = append(elements, file)
+	}
+	return elements, nil
+}
+
+// Value returns the paths of the files of the archive.
+func (archive *ArchiveElement) Value() any {
+	names := make([]any, 0, len(archive.files))
+	for _, file := range archive.files {
+		names = append(names, file.name)
+	}
+	return names
+}
+
+// match returns the files whose paths match the pattern, at most limit files if limit is greater than zero.
+func (archive *ArchiveElement) match(expr, exprType string, limit int) ([]*ArchiveFileElement, error) {
+	if (exprType != "") && !strings.EqualFold(exprType, PathExpr) {
+		return nil, ErrExprType
+	}
+
+	if _, err := path.Match(expr, ""); err != nil {
+		return nil, err
+	}
+
+	var files []*ArchiveFileElement
+	for _, file := range archive.files {
+		if ok, _ := path.Match(expr, file.name); ok {
+			files = append(files, file)
+			if (limit > 0) && (len(files) >= limit) {
+				break
+			}
+		}
+	}
+	return files, nil
+}
+
+func (file *ArchiveFileElement) Find(expr, exprType string) (Element, error) {
+	root, err := file.parse()
+	if err != nil {
+		return nil, err
+	}
+	return root.Find(expr, exprType)
+}
+
+func (file *ArchiveFileElement) FindAll(expr, exprType string) ([]Element, error) {
+	root, err := file.parse()
+	if err != nil {
+		return nil, err
+	}
+	return root.FindAll(expr, exprType)
+}
+
+func (file *ArchiveFileElement) Value() any {
+	return file.name
+}
+
+// parse parses the file with the ParserFunc that matches its type, the root element is stored.
+func (file *ArchiveFileElement) parse() (Element, error) {
+	if (file.root != nil) || (file.err != nil) {
+		return file.root, file.err
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(file.name))
+	parserFunc := file.parsers.parserFunc(contentType)
+	if parserFunc == nil {
+		contentType = http.DetectContentType(file.data)
+		parserFunc = file.parsers.parserFunc(contentType)
+	}
+
+	if parserFunc == nil {
+		file.err = ErrNotMatch
+		return nil, file.err
+	}
+
+	file.root, file.err = parserFunc(&archiveFileResponse{file: file, contentType: contentType})
+	return file.root, file.err
+}
+
+// archiveFileResponse is the response of a file of an archive,
+// the URL is the URL of the archive with the path of the file as fragment.
+type archiveFileResponse struct {
+	file        *ArchiveFileElement
+	contentType string
+}
+
+func (resp *archiveFileResponse) URL() *url.URL {
+	u := *resp.file.resp.URL()
+	u.Fragment, u.RawFragment = resp.file.name, ""
+	return &u
+}
+
+func (resp *archiveFileResponse) StatusCode() int {
+	return resp.file.resp.StatusCode()
+}
+
+func (resp *archiveFileResponse) Header() http.Header {
+	return http.Header{"Content-Type": {resp.contentType}}
+}
+
+func (resp *archiveFileResponse) Body() io.ReadCloser {
+	return io.NopCloser(bytes.NewReader(resp.file.data))
+}
+
+// Context returns the context of the response of the archive, if it has one.
+func (resp *archiveFileResponse) Context() context.Context {
+	if r, ok := resp.file.resp.(interface{ Context() context.Context }); ok && (r.Context() != nil) {
+		return r.Context()
+	}
+	return context.Background()
+}
+
+func (resp *archiveFileResponse) Do(rules *colibri.Rules) (colibri.Response, error) {
+	return resp.file.resp.Do(rules)
+}
+
+func (resp *archiveFileResponse) Extract(rules *colibri.Rules) (colibri.Response, map[string]any, error) {
+	return resp.file.resp.Extract(rules)
+}
+
+// archiveBudget number of bytes that can still be read from the archive and its files.
+type archiveBudget struct {
+	n int64
+}
+
+// read reads all the bytes of r, returns ErrArchiveTooLarge if they exceed the budget.
+func (budget *archiveBudget) read(r io.Reader) ([]byte, error) {
+	if MaxArchiveSize <= 0 {
+		return io.ReadAll(r)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, budget.n+1))
+	if err != nil {
+		return nil, err
+	}
+
+	budget.n -= int64(len(data))
+	if budget.n < 0 {
+		return nil, ErrArchiveTooLarge
+	}
+	return data, nil
+}
+
+// readZip reads and decompresses the files of the zip archive.
+func readZip(data []byte, budget *archiveBudget, add func(name string, data []byte)) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+
+		fileData, err := budget.read(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		add(cleanPath(f.Name), fileData)
+	}
+	return nil
+}
+
+// readTar reads the files of the tar archive, the data was counted in the budget.
+func readTar(data []byte, add func(name string, data []byte)) error {
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		fileData, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		add(cleanPath(header.Name), fileData)
+	}
+}
+
+// isTar returns true if the data starts with a valid tar header.
+func isTar(data []byte) bool {
+	_, err := tar.NewReader(bytes.NewReader(data)).Next()
+	return err == nil
+}
+
+// cleanPath returns the path of the file without the leading "/" and "./".
+func cleanPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
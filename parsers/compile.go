@@ -0,0 +1,139 @@
+package parsers
+
+import (
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/eduardogxnzalez/colibri"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/xpath"
+)
+
+// compiledExprs stores the expressions precompiled by Compile, they are shared
+// by all the Parsers and reused by the elements instead of compiling them in each parse.
+var compiledExprs sync.Map // exprKey -> compiled expression
+
+// exprKey identifies a precompiled expression.
+type exprKey struct {
+	exprType string
+	expr     string
+}
+
+// Compile validates and precompiles the expressions of the selectors of the rules,
+// including the Detect selectors and the selectors of the Variants, and validates their pipes.
+// The parses of the responses with the rules reuse the precompiled expressions.
+// The expressions without type are precompiled as XPath expressions if they are valid,
+// the types not supported by the Parsers are not validated.
+// The errors are returned with the keys of the selectors, e.g. "Selectors.title".
+// See the colibri.ExprCompiler interface.
+func (parsers *Parsers) Compile(rules *colibri.Rules) error {
+	if rules == nil {
+		return nil
+	}
+
+	var errs error
+	if selectorErrs := compileSelectors(nil, rules.Selectors); selectorErrs != nil {
+		errs = colibri.AddError(errs, colibri.KeySelectors, selectorErrs)
+	}
+
+	var variantErrs error
+	for _, variant := range rules.Variants {
+		if variant == nil {
+			continue
+		}
+
+		selectors := variant.Selectors
+		if variant.Detect != nil {
+			selectors = append([]*colibri.Selector{variant.Detect}, selectors...)
+		}
+
+		if err := compileSelectors(nil, selectors); err != nil {
+			variantErrs = colibri.AddError(variantErrs, variant.Name, err)
+		}
+	}
+
+	if variantErrs != nil {
+		errs = colibri.AddError(errs, colibri.KeyVariants, variantErrs)
+	}
+	return errs
+}
+
+func compileSelectors(errs error, selectors []*colibri.Selector) error {
+	for _, selector := range selectors {
+		if selector == nil {
+			continue
+		}
+
+		if err := compileExpr(selector.Expr, selector.Type); err != nil {
+			errs = colibri.AddError(errs, selector.Name, err)
+			continue
+		}
+
+		if _, err := pipeline(selector.Pipes); err != nil {
+			errs = colibri.AddError(errs, selector.Name, err)
+			continue
+		}
+
+		if nestedErrs := compileSelectors(nil, selector.Selectors); nestedErrs != nil {
+			errs = colibri.AddError(errs, selector.Name, nestedErrs)
+		}
+	}
+	return errs
+}
+
+// compileExpr validates and stores the expression of the type in compiledExprs.
+func compileExpr(expr, exprType string) error {
+	exprType = strings.ToLower(exprType)
+	switch exprType {
+	case "":
+		// the expression may be a regular expression of a TextElement
+		if exp, err := xpath.Compile(expr); err == nil {
+			compiledExprs.Store(exprKey{XPathExpr, expr}, exp)
+		}
+		return nil
+
+	case XPathExpr:
+		return storeExpr(exprType, expr, xpath.Compile)
+
+	case CSSSelector:
+		return storeExpr(exprType, expr, cascadia.Compile)
+
+	case RegularExpr:
+		return storeExpr(exprType, expr, compileRegexp)
+
+	case JSONPathExpr:
+		return storeExpr(exprType, expr, compileJSONPath)
+
+	case PathExpr:
+		_, err := path.Match(expr, "")
+		return err
+	}
+	return nil
+}
+
+// storeExpr compiles the expression and stores it in compiledExprs.
+func storeExpr[T any](exprType, expr string, compile func(string) (T, error)) error {
+	compiled, err := compile(expr)
+	if err != nil {
+		return err
+	}
+	compiledExprs.Store(exprKey{exprType, expr}, compiled)
+	return nil
+}
+
+// precompiled returns the expression of the type precompiled by Compile,
+// or compiles it if it was not precompiled.
+func precompiled[T any](exprType, expr string, compile func(string) (T, error)) (T, error) {
+	if compiled, ok := compiledExprs.Load(exprKey{exprType, expr}); ok {
+		return compiled.(T), nil
+	}
+	return compile(expr)
+}
+
+// isPrecompiled returns true if the expression of the type was precompiled by Compile.
+func isPrecompiled(exprType, expr string) bool {
+	_, ok := compiledExprs.Load(exprKey{exprType, expr})
+	return ok
+}
@@ -1,9 +1,15 @@
 package parsers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"slices"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/eduardogxnzalez/colibri"
 )
@@ -19,7 +25,21 @@ type Element interface {
 	Value() any
 }
 
-func findSelectors(src *colibri.Rules, resp colibri.Response, selectors []*colibri.Selector, parent Element) (map[string]any, error) {
+// stopError is returned when the emit function of ParseStream fails,
+// the parse ends and the error is returned to the caller.
+type stopError struct {
+	err error
+}
+
+func (err *stopError) Error() string {
+	return err.err.Error()
+}
+
+func (err *stopError) Unwrap() error {
+	return err.err
+}
+
+func findSelectors(src *colibri.Rules, resp colibri.Response, selectors []*colibri.Selector, parent Element, state *parseState) (map[string]any, error) {
 	if (resp == nil) || (selectors == nil) || (parent == nil) {
 		return nil, nil
 	}
@@ -28,8 +48,13 @@ func findSelectors(src *colibri.Rules, resp colibri.Response, selectors []*colib
 		result = make(map[string]any)
 		errs   error
 	)
-	for _, selector := range selectors {
-		found, err := findSelector(src, resp, selector, parent)
+	for _, selector := range sortSelectors(selectors) {
+		if (selector != nil) && skipSelector(selector, result) {
+			result[selector.Name] = nil
+			continue
+		}
+
+		found, err := findSelector(src, resp, selector, parent, state, nil)
 		if err != nil {
 			errs = colibri.AddError(errs, selector.Name, err)
 			continue
@@ -39,7 +64,10 @@ func findSelectors(src *colibri.Rules, resp colibri.Response, selectors []*colib
 	return result, errs
 }
 
-func followSelector(src *colibri.Rules, resp colibri.Response, selector *colibri.Selector, rawURL ...any) (map[string]any, error) {
+// followSelector extracts the data of the URLs with the rules of the selector.
+// If emit is not nil, the data of each URL is emitted as a map with the URL as key
+// instead of being returned.
+func followSelector(src *colibri.Rules, resp colibri.Response, selector *colibri.Selector, state *parseState, emit func(any) error, rawURL ...any) (map[string]any, error) {
 	var (
 		result = make(map[string]any)
 		urls   = make([]*url.URL, 0, len(rawURL))
@@ -63,29 +91,210 @@ func followSelector(src *colibri.Rules, resp colibri.Response, selector *colibri
 		return nil, errs
 	}
 
-	rules := selector.Rules(src)
+	rules, err := selector.RulesWithConvFunc(src, colibri.DefaultConvFunc)
+	if err != nil {
+		colibri.ReleaseRules(rules)
+		return nil, err
+	}
+
+	var (
+		hash    = selectorHash(selector)
+		current = branchKey(resp.URL())
+		branch  = state.childBranch(current)
+		tasks   []*followTask
+		found   = make(map[string]struct{}, len(urls))
+	)
 	for _, u := range urls {
+		key := branchKey(u)
+		reason := state.skipFollow(current, key)
+		if !colibri.FollowAllowed(src, selector, u) {
+			reason = SkippedFiltered
+		} else if _, ok := found[key]; ok && (reason == "") {
+			reason = SkippedDuplicate
+		}
+		found[key] = struct{}{}
+
+		if reason == SkippedFiltered {
+			warn(resp, &colibri.Warning{Kind: colibri.WarningFiltered, URL: u, Key: selector.Name})
+		}
+
+		if reason != "" {
+			if (state != nil) && (state.followHook != nil) {
+				event := newFollowEvent(resp, selector, u, time.Now(), nil)
+				event.Skipped = reason
+				state.followHook(event)
+			}
+			continue
+		}
+
+		if (src.MaxDepth > 0) && (len(branch) > src.MaxDepth) {
+			errs = colibri.AddError(errs, u.String(), colibri.ErrDepthExceeded)
+			continue
+		}
+
 		cRules := rules.Clone()
 		cRules.URL = u
+		cRules.Fields[KeyFollowBranch] = branch
+		cRules.Fields[KeyFollowSelector] = selector.Name
+		tasks = append(tasks, &followTask{u: u, key: key, rules: cRules, done: make(chan struct{})})
+	}
 
-		_, found, err := resp.Extract(cRules)
-		if err != nil {
+	fetch := func(task *followTask) {
+		defer close(task.done)
+
+		task.found, task.err = state.follow(task.key+"#"+hash, func() (map[string]any, error) {
+			start := time.Now()
+			_, found, err := resp.Extract(task.rules)
+
+			if (state != nil) && (state.followHook != nil) {
+				state.followHook(newFollowEvent(resp, selector, task.u, start, err))
+			}
+			return found, err
+		})
+		releaseExtracted(resp, task.rules, task.err)
+	}
+
+	stop := startFollowTasks(tasks, rules.FollowConcurrency, fetch)
+	colibri.ReleaseRules(rules)
+
+	for _, task := range tasks {
+		if stop != nil {
+			<-task.done
+		} else {
+			fetch(task)
+		}
+
+		u, found, err := task.u, task.found, task.err
+		if w, ok := colibri.AsWarning(err); ok {
+			// expected conditions, e.g. the robots.txt does not allow the URL
+			if w.Key == "" {
+				w = &colibri.Warning{Kind: w.Kind, URL: u, Key: selector.Name, Err: err}
+			}
+			warn(resp, w)
+			continue
+		} else if err != nil {
 			errs = colibri.AddError(errs, u.String(), err)
 			continue
 		}
+
+		if emit != nil {
+			if err := emit(map[string]any{u.String(): found}); err != nil {
+				if stop != nil {
+					stop()
+				} else {
+					releaseFollowTasks(tasks)
+				}
+				return nil, &stopError{err}
+			}
+			continue
+		}
 		result[u.String()] = found
+	}
 
-		colibri.ReleaseRules(cRules)
+	if stop != nil {
+		stop()
 	}
 
-	colibri.ReleaseRules(rules)
+	if emit != nil {
+		return nil, errs
+	}
 	return result, errs
 }
 
-func findAllSelector(src *colibri.Rules, resp colibri.Response, selector *colibri.Selector, parent Element) (any, error) {
+// releaseExtracted releases the rules extracted with the response, unless the parse was
+// abandoned and continues in the background with the rules, see colibri.Rules.ParseTimeout.
+func releaseExtracted(resp colibri.Response, rules *colibri.Rules, err error) {
+	if errors.Is(err, colibri.ErrParseTimeout) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	if r, ok := resp.(interface{ Context() context.Context }); ok && (r.Context() != nil) && (r.Context().Err() != nil) {
+		return
+	}
+	colibri.ReleaseRules(rules)
+}
+
+// followTask is a URL of a Follow selector and the result of its extraction.
+type followTask struct {
+	u     *url.URL
+	key   string // see branchKey
+	rules *colibri.Rules
+	found map[string]any
+	err   error
+	done  chan struct{}
+}
+
+// startFollowTasks runs fetch for the tasks in up to concurrency goroutines, in order.
+// The returned function skips the tasks not started and waits for the running ones.
+// Returns nil if concurrency is not greater than one, the tasks are fetched by the caller.
+func startFollowTasks(tasks []*followTask, concurrency int, fetch func(task *followTask)) (stop func()) {
+	if (concurrency <= 1) || (len(tasks) <= 1) {
+		return nil
+	}
+
+	var (
+		queue   = make(chan *followTask)
+		stopped atomic.Bool
+		wg      sync.WaitGroup
+	)
+
+	go func() {
+		for _, task := range tasks {
+			queue <- task
+		}
+		close(queue)
+	}()
+
+	for i := 0; i < min(concurrency, len(tasks)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for task := range queue {
+				if stopped.Load() {
+					colibri.ReleaseRules(task.rules)
+					close(task.done)
+					continue
+				}
+				fetch(task)
+			}
+		}()
+	}
+
+	return func() {
+		stopped.Store(true)
+		wg.Wait()
+	}
+}
+
+// releaseFollowTasks releases the rules of the tasks not fetched one by one.
+func releaseFollowTasks(tasks []*followTask) {
+	for _, task := range tasks {
+		select {
+		case <-task.done:
+		default:
+			colibri.ReleaseRules(task.rules)
+		}
+	}
+}
+
+// warn reports the warning with the context of the response, see colibri.Warn.
+func warn(resp colibri.Response, warning *colibri.Warning) {
+	if r, ok := resp.(interface{ Context() context.Context }); ok {
+		colibri.Warn(r.Context(), warning)
+	}
+}
+
+// findAllSelector finds all the elements that match the selector.
+// If emit is not nil, each element found is emitted instead of being returned.
+func findAllSelector(src *colibri.Rules, resp colibri.Response, selector *colibri.Selector, parent Element, state *parseState, transform Transform, emit func(any) error) (any, error) {
 	children, err := parent.FindAll(selector.Expr, selector.Type)
 	if err != nil {
 		return nil, err
+	} else if (len(children) == 0) && selector.Required {
+		return nil, ErrRequired
+	} else if len(children) == 0 {
+		warn(resp, &colibri.Warning{Kind: colibri.WarningEmpty, URL: resp.URL(), Key: selector.Name})
 	}
 
 	var (
@@ -94,49 +303,192 @@ func findAllSelector(src *colibri.Rules, resp colibri.Response, selector *colibr
 	)
 	if !selector.Follow && (len(selector.Selectors) > 0) {
 		for i, child := range children {
-			found, err := findSelectors(src, resp, selector.Selectors, child)
+			found, err := findSelectors(src, resp, selector.Selectors, child, state)
 			if err != nil {
 				errs = colibri.AddError(errs, selector.Name+"#"+strconv.Itoa(i), err)
 				continue
 			}
+
+			if emit != nil {
+				if err := emit(found); err != nil {
+					return nil, &stopError{err}
+				}
+				continue
+			}
 			result = append(result, found)
 		}
 
 		return result, errs
 	}
 
-	for _, child := range children {
-		result = append(result, child.Value())
+	for i, child := range children {
+		value, err := elementValue(child, transform)
+		if err != nil {
+			errs = colibri.AddError(errs, selector.Name+"#"+strconv.Itoa(i), err)
+			continue
+		}
+
+		if selector.Follow {
+			if value != nil {
+				result = append(result, value)
+			}
+			continue
+		}
+
+		if emit != nil {
+			if err := emit(value); err != nil {
+				return nil, &stopError{err}
+			}
+			continue
+		}
+		result = append(result, value)
 	}
 
 	if selector.Follow {
-		return followSelector(src, resp, selector, result...)
+		if errs != nil {
+			return nil, errs
+		}
+		return followSelector(src, resp, selector, state, emit, result...)
 	}
 	return result, errs
 }
 
-func findSelector(src *colibri.Rules, resp colibri.Response, selector *colibri.Selector, parent Element) (any, error) {
+// findSelector finds the element that matches the selector.
+// If emit is not nil, the value found is emitted instead of being returned,
+// the items of the All selectors and the URLs of the Follow selectors are emitted one by one.
+func findSelector(src *colibri.Rules, resp colibri.Response, selector *colibri.Selector, parent Element, state *parseState, emit func(any) error) (found any, err error) {
 	if (selector == nil) || (parent == nil) {
 		return nil, nil
 	}
 
+	if (state != nil) && (state.hook != nil) {
+		start := time.Now()
+		defer func() {
+			state.hook(resp, selector, found, time.Since(start), err)
+		}()
+	}
+
+	transform, err := pipeline(selector.Pipes)
+	if err != nil {
+		return nil, err
+	}
+
 	if selector.All {
-		return findAllSelector(src, resp, selector, parent)
+		return findAllSelector(src, resp, selector, parent, state, transform, emit)
 	}
 
 	child, err := parent.Find(selector.Expr, selector.Type)
 	if err != nil {
 		return nil, err
+	} else if (child == nil) && selector.Required {
+		return nil, ErrRequired
 	} else if child == nil {
+		warn(resp, &colibri.Warning{Kind: colibri.WarningEmpty, URL: resp.URL(), Key: selector.Name})
 		return nil, nil
 	}
 
 	if selector.Follow {
-		return followSelector(src, resp, selector, child.Value())
+		value, err := elementValue(child, transform)
+		if (err != nil) || (value == nil) {
+			return nil, err
+		}
+		return followSelector(src, resp, selector, state, emit, value)
 	}
 
 	if len(selector.Selectors) > 0 {
-		return findSelectors(src, resp, selector.Selectors, child)
+		found, err = findSelectors(src, resp, selector.Selectors, child, state)
+	} else {
+		found, err = elementValue(child, transform)
+	}
+
+	if err == nil {
+		if err := setVar(src, selector, found); err != nil {
+			return nil, err
+		}
+	}
+
+	if (emit != nil) && (err == nil) {
+		if err := emit(found); err != nil {
+			return nil, &stopError{err}
+		}
+		return nil, nil
+	}
+	return found, err
+}
+
+// setVar stores the value found by the selector in its variable, see colibri.KeyVar.
+func setVar(rules *colibri.Rules, selector *colibri.Selector, value any) error {
+	name, _ := selector.Fields[colibri.KeyVar].(string)
+	if (name == "") || (value == nil) {
+		return nil
+	}
+
+	if (rules == nil) || (rules.Vars == nil) {
+		return colibri.ErrUndeclaredVar
+	}
+	return rules.Vars.Set(name, value)
+}
+
+// sortSelectors returns the selectors in the order in which they are evaluated, the selectors
+// that set variables first and the selectors with conditions last, see colibri.KeyVar and colibri.KeySkipIf.
+func sortSelectors(selectors []*colibri.Selector) []*colibri.Selector {
+	rank := func(selector *colibri.Selector) int {
+		if selector == nil {
+			return 1
+		}
+
+		if name, _ := selector.Fields[colibri.KeyVar].(string); name != "" {
+			return 0
+		}
+
+		if _, ok := selector.Fields[colibri.KeySkipIf]; ok {
+			return 2
+		}
+		return 1
+	}
+
+	if !slices.ContainsFunc(selectors, func(selector *colibri.Selector) bool { return rank(selector) != 1 }) {
+		return selectors
+	}
+
+	sorted := slices.Clone(selectors)
+	slices.SortStableFunc(sorted, func(a, b *colibri.Selector) int {
+		return rank(a) - rank(b)
+	})
+	return sorted
+}
+
+// skipSelector reports whether any condition of the selector matches the values found
+// by the selectors of the same level, see colibri.KeySkipIf.
+func skipSelector(selector *colibri.Selector, found map[string]any) bool {
+	conditions, _ := selector.Fields[colibri.KeySkipIf].(map[string]any)
+	for name, want := range conditions {
+		values, ok := want.([]any)
+		if !ok {
+			values = []any{want}
+		}
+
+		for _, value := range values {
+			if matchValue(found[name], value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchValue reports whether the value found is equal to the value of a condition.
+func matchValue(found, want any) bool {
+	if (found == nil) || (want == nil) {
+		return (found == nil) && (want == nil)
+	}
+	return fmt.Sprint(found) == fmt.Sprint(want)
+}
+
+// elementValue returns the value of the element transformed with the pipes of the selector.
+func elementValue(element Element, transform Transform) (any, error) {
+	if transform == nil {
+		return element.Value(), nil
 	}
-	return child.Value(), nil
+	return transform(element.Value())
 }
@@ -0,0 +1,224 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eduardogxnzalez/colibri"
+
+	"github.com/antchfx/xmlquery"
+	"golang.org/x/net/html/charset"
+)
+
+// EMLRegexp contains a regular expression that matches the MIME type of the email messages.
+const EMLRegexp = `(?i)^message\/rfc822`
+
+// DefaultMaxEMLSize default maximum number of bytes of the email messages parsed.
+const DefaultMaxEMLSize = 25 << 20
+
+// MaxEMLSize maximum number of bytes of the email messages parsed by ParseEML.
+// Zero or negative means no limit.
+var MaxEMLSize int64 = DefaultMaxEMLSize
+
+// ErrEMLTooLarge is returned when the email message exceeds MaxEMLSize.
+var ErrEMLTooLarge = errors.New("email message exceeds the maximum size")
+
+// wordDecoder decodes the encoded words (RFC 2047) of the headers.
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// ParseEML parses the email message of the response and returns the root element.
+// The message is represented as an XML document compatible with XPath expressions:
+//
+//	<message>
+//		<header>
+//			<subject>...</subject>
+//			<from>...</from>
+//		</header>
+//		<text>...</text>
+//		<html>...</html>
+//		<attachment filename="..." content-type="..." size="...">...</attachment>
+//	</message>
+//
+// The names of the headers are lower case and their encoded words are decoded.
+// The text and HTML parts are converted to UTF-8 and the content of the attachments
+// is encoded in base64. The attached messages are parsed as nested message elements.
+// Returns ErrEMLTooLarge if the content exceeds MaxEMLSize.
+func ParseEML(resp colibri.Response) (*XMLElement, error) {
+	var r io.Reader = resp.Body()
+	if MaxEMLSize > 0 {
+		r = io.LimitReader(r, MaxEMLSize+1)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if (MaxEMLSize > 0) && (int64(len(b)) > MaxEMLSize) {
+		return nil, ErrEMLTooLarge
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	root := &xmlquery.Node{Type: xmlquery.DocumentNode}
+	if err := addMessage(root, textproto.MIMEHeader(msg.Header), msg.Body); err != nil {
+		return nil, err
+	}
+	return &XMLElement{root}, nil
+}
+
+// addMessage adds the message element with its headers and parts to the parent.
+func addMessage(parent *xmlquery.Node, header textproto.MIMEHeader, body io.Reader) error {
+	message := addElement(parent, "message", "")
+
+	headerNode := addElement(message, "header", "")
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := headerName(key)
+		for _, value := range header[key] {
+			addElement(headerNode, name, decodeHeader(value))
+		}
+	}
+
+	return addPart(message, header, body)
+}
+
+// addPart adds the elements of the MIME part to the message element.
+func addPart(message *xmlquery.Node, header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if header.Get("Content-Type") == "" {
+		mediaType, params = "text/plain", map[string]string{}
+	} else if err != nil {
+		mediaType = "application/octet-stream"
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	filename = decodeHeader(filename)
+
+	body = decodeTransfer(header.Get("Content-Transfer-Encoding"), body)
+	attachment := (disposition == "attachment") || (filename != "")
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if err := addPart(message, part.Header, part); err != nil {
+				return err
+			}
+		}
+
+	case (mediaType == "message/rfc822") && (disposition != "attachment"):
+		msg, err := mail.ReadMessage(body)
+		if err != nil {
+			return err
+		}
+		return addMessage(message, textproto.MIMEHeader(msg.Header), msg.Body)
+
+	case ((mediaType == "text/plain") || (mediaType == "text/html")) && !attachment:
+		if label := params["charset"]; label != "" {
+			r, err := charset.NewReaderLabel(label, body)
+			if err == nil {
+				body = r
+			}
+		}
+
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+
+		name := "html"
+		if mediaType == "text/plain" {
+			name = "text"
+		}
+
+		addElement(message, name, string(b))
+		return nil
+	}
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	node := addElement(message, "attachment", base64.StdEncoding.EncodeToString(b))
+	xmlquery.AddAttr(node, "filename", filename)
+	xmlquery.AddAttr(node, "content-type", mediaType)
+	xmlquery.AddAttr(node, "size", strconv.Itoa(len(b)))
+	return nil
+}
+
+// addElement adds an element with the name and text to the parent, returns the element.
+func addElement(parent *xmlquery.Node, name, text string) *xmlquery.Node {
+	node := &xmlquery.Node{Type: xmlquery.ElementNode, Data: name}
+	if text != "" {
+		xmlquery.AddChild(node, &xmlquery.Node{Type: xmlquery.TextNode, Data: text})
+	}
+
+	xmlquery.AddChild(parent, node)
+	return node
+}
+
+// decodeTransfer decodes the content of the part with the Content-Transfer-Encoding.
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// decodeHeader decodes the encoded words of the header value, returns the value if it is invalid.
+func decodeHeader(value string) string {
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// headerName returns the lower case name of the header as a valid element name.
+func headerName(key string) string {
+	name := []byte(strings.ToLower(key))
+	for i, c := range name {
+		if !((c >= 'a') && (c <= 'z')) && !((c >= '0') && (c <= '9')) && (c != '-') && (c != '_') && (c != '.') {
+			name[i] = '_'
+		}
+	}
+
+	if (len(name) == 0) || !((name[0] >= 'a') && (name[0] <= 'z')) {
+		name = append([]byte("_"), name...)
+	}
+	return string(name)
+}
@@ -0,0 +1,41 @@
+package parsers
+
+import "github.com/eduardogxnzalez/colibri"
+
+// Codes of the errors of the parsers, see colibri.ErrorCode.
+const (
+	CodeContentTypeMismatch = "CONTENT_TYPE_MISMATCH"
+	CodeInvalidExpr         = "INVALID_EXPR"
+	CodeRequiredNotFound    = "REQUIRED_NOT_FOUND"
+	CodeBudgetExceeded      = "BUDGET_EXCEEDED"
+	CodeContentTooLarge     = "CONTENT_TOO_LARGE"
+	CodeInvalidContent      = "INVALID_CONTENT"
+)
+
+func init() {
+	for _, ec := range []struct {
+		err            error
+		code, category string
+	}{
+		{ErrNotMatch, CodeContentTypeMismatch, colibri.CategoryParse},
+		{ErrExprType, CodeInvalidExpr, colibri.CategoryRules},
+		{ErrExprNotFound, CodeRequiredNotFound, colibri.CategoryParse},
+		{ErrRequired, CodeRequiredNotFound, colibri.CategoryParse},
+		{ErrPipeNotFound, colibri.CodeInvalidRules, colibri.CategoryRules},
+		{ErrPipeArgs, colibri.CodeInvalidRules, colibri.CategoryRules},
+		{ErrUnknownCharset, colibri.CodeInvalidRules, colibri.CategoryRules},
+		{ErrInvalidJSONPath, CodeInvalidExpr, colibri.CategoryRules},
+		{ErrRegexpTooLong, CodeInvalidExpr, colibri.CategoryRules},
+		{ErrRegexpSyntax, CodeInvalidExpr, colibri.CategoryRules},
+		{ErrBudgetExceeded, CodeBudgetExceeded, colibri.CategoryParse},
+		{ErrRegexpTimeout, CodeBudgetExceeded, colibri.CategoryParse},
+		{ErrTextTooLarge, CodeContentTooLarge, colibri.CategoryParse},
+		{ErrArchiveTooLarge, CodeContentTooLarge, colibri.CategoryParse},
+		{ErrEMLTooLarge, CodeContentTooLarge, colibri.CategoryParse},
+		{ErrInvalidNDJSON, CodeInvalidContent, colibri.CategoryParse},
+		{ErrInvalidProto, CodeInvalidContent, colibri.CategoryParse},
+		{ErrInvalidProtoType, CodeInvalidContent, colibri.CategoryParse},
+	} {
+		colibri.RegisterErrorCode(ec.err, ec.code, ec.category)
+	}
+}
@@ -0,0 +1,167 @@
+package parsers
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+const (
+	// SkippedCycle the URL was not followed because it was already visited in the same branch.
+	SkippedCycle = "cycle"
+
+	// SkippedDepth the URL was not followed because the maximum follow depth was exceeded.
+	SkippedDepth = "depth"
+
+	// SkippedFiltered the URL was not followed because it is not allowed by the Allow
+	// and Deny of the rules or the selector, see colibri.FollowAllowed.
+	SkippedFiltered = "filtered"
+
+	// SkippedDuplicate the URL was not followed because the selector already found it in the page,
+	// e.g. repeated links. The result of the first URL is used.
+	SkippedDuplicate = "duplicate"
+)
+
+// FollowHook is called after each request made by a Follow selector
+// and for each URL skipped.
+type FollowHook func(event FollowEvent)
+
+// FollowEvent represents a request made by a Follow selector.
+type FollowEvent struct {
+	// URL requested.
+	URL string `json:"url"`
+
+	// Parent URL of the response in which the selector found the URL.
+	Parent string `json:"parent"`
+
+	// Selector name of the selector that found the URL.
+	Selector string `json:"selector"`
+
+	// Depth number of follows from the seed URL, calculated by FollowLog.
+	Depth int `json:"depth"`
+
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+
+	// Skipped reason why the URL was not followed (SkippedCycle, SkippedDepth, SkippedFiltered or SkippedDuplicate),
+	// empty if the request was made.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+func newFollowEvent(resp colibri.Response, selector *colibri.Selector, u *url.URL, start time.Time, err error) FollowEvent {
+	event := FollowEvent{
+		URL:      u.String(),
+		Selector: selector.Name,
+		Start:    start,
+		Duration: time.Since(start),
+	}
+
+	if parent := resp.URL(); parent != nil {
+		event.Parent = parent.String()
+	}
+
+	if err != nil {
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// FollowNode represents a URL of the follow tree and the URLs followed from it.
+type FollowNode struct {
+	FollowEvent
+	Children []*FollowNode `json:"children,omitempty"`
+}
+
+// FollowLog records the requests made by Follow selectors
+// to reconstruct how a crawl expanded from the seed URLs.
+type FollowLog struct {
+	mu     sync.Mutex
+	events []FollowEvent
+}
+
+// Hook returns the FollowHook that records the events, see Parsers.SetFollowHook.
+func (log *FollowLog) Hook() FollowHook {
+	return func(event FollowEvent) {
+		log.mu.Lock()
+		log.events = append(log.events, event)
+		log.mu.Unlock()
+	}
+}
+
+// Events returns the recorded events sorted by start time with the calculated depth.
+// The seed URLs have depth 0, the URLs followed from them depth 1 and so on.
+func (log *FollowLog) Events() []FollowEvent {
+	log.mu.Lock()
+	events := make([]FollowEvent, len(log.events))
+	copy(events, log.events)
+	log.mu.Unlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+
+	parents := make(map[string]string, len(events))
+	for _, event := range events {
+		if _, ok := parents[event.URL]; !ok {
+			parents[event.URL] = event.Parent
+		}
+	}
+
+	for i := range events {
+		var (
+			depth   = 1
+			visited = map[string]bool{events[i].URL: true}
+		)
+		for parent, ok := parents[events[i].Parent]; ok && !visited[parent]; parent, ok = parents[parent] {
+			visited[parent] = true
+			depth++
+		}
+		events[i].Depth = depth
+	}
+	return events
+}
+
+// Tree returns the follow tree, one root node for each seed URL.
+func (log *FollowLog) Tree() []*FollowNode {
+	var (
+		events = log.Events()
+		nodes  = make(map[string]*FollowNode, len(events))
+		roots  []*FollowNode
+	)
+	for _, event := range events {
+		node := &FollowNode{FollowEvent: event}
+
+		parent, ok := nodes[event.Parent]
+		if !ok {
+			parent = &FollowNode{FollowEvent: FollowEvent{URL: event.Parent}}
+			nodes[event.Parent] = parent
+			roots = append(roots, parent)
+		}
+		parent.Children = append(parent.Children, node)
+
+		if _, ok := nodes[event.URL]; !ok {
+			nodes[event.URL] = node
+		}
+	}
+	return roots
+}
+
+// WriteJSON writes the follow tree in JSON format.
+func (log *FollowLog) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(log.Tree())
+}
+
+// Clear removes the recorded events.
+func (log *FollowLog) Clear() {
+	log.mu.Lock()
+	log.events = nil
+	log.mu.Unlock()
+}
@@ -0,0 +1,135 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/eduardogxnzalez/colibri"
+
+	"golang.org/x/net/html/charset"
+)
+
+// KeyParserHints is the key of Fields in which the rules store the ParserHints
+// used to parse the responses, a ParserHints or a map with the names of its fields,
+// e.g. {"ContentType": "text/html", "Charset": "windows-1252"}.
+// The rules of the Follow selectors use the hints of the fields of the selector.
+const KeyParserHints = "ParserHints"
+
+// ErrUnknownCharset is returned when the Charset of the ParserHints is not supported.
+var ErrUnknownCharset = errors.New("unknown charset")
+
+// ParserHints are hints about the content of the responses for the servers whose headers
+// are missing or wrong. They are used instead of the headers of the responses.
+type ParserHints struct {
+	// ContentType specifies the media type used to select the ParserFunc, e.g. "text/html".
+	ContentType string
+
+	// Charset specifies the encoding of the content, e.g. "windows-1252".
+	// The content is converted to UTF-8 before it is parsed.
+	Charset string
+
+	// Language specifies the language of the content, e.g. "es".
+	// It is not used by the ParserFuncs of the package, see HintsOf.
+	Language string
+}
+
+// HintsOf returns the ParserHints of the rules with which the response is parsed,
+// so the ParserFuncs can use them, e.g. the Language.
+func HintsOf(resp colibri.Response) ParserHints {
+	if r, ok := resp.(*hintedResponse); ok {
+		return r.hints
+	}
+	return ParserHints{}
+}
+
+// rulesHints returns the ParserHints stored in the Fields of the rules, see KeyParserHints.
+func rulesHints(rules *colibri.Rules) ParserHints {
+	switch v := rules.Fields[KeyParserHints].(type) {
+	case ParserHints:
+		return v
+
+	case map[string]any:
+		var hints ParserHints
+		hints.ContentType, _ = v["ContentType"].(string)
+		hints.Charset, _ = v["Charset"].(string)
+		hints.Language, _ = v["Language"].(string)
+		return hints
+	}
+	return ParserHints{}
+}
+
+// hintedResponse is a response with the Content-Type and the content of the ParserHints.
+type hintedResponse struct {
+	colibri.Response
+	hints  ParserHints
+	header http.Header
+	body   io.ReadCloser
+}
+
+// withHints returns the response with the Content-Type of the hints and, if they have a Charset,
+// with the content converted to UTF-8. Returns the response if the hints are empty.
+func withHints(resp colibri.Response, hints ParserHints) (colibri.Response, error) {
+	if hints == (ParserHints{}) {
+		return resp, nil
+	}
+
+	hinted := &hintedResponse{
+		Response: resp,
+		hints:    hints,
+		header:   resp.Header().Clone(),
+		body:     resp.Body(),
+	}
+	if hinted.header == nil {
+		hinted.header = make(http.Header)
+	}
+
+	if hints.ContentType != "" {
+		hinted.header.Set("Content-Type", hints.ContentType)
+	}
+
+	if hints.Charset != "" {
+		r, err := charset.NewReaderLabel(hints.Charset, hinted.body)
+		if err != nil {
+			return nil, ErrUnknownCharset
+		}
+		hinted.body = &decodedBody{Reader: r, Closer: hinted.body}
+		hinted.header.Set("Content-Type", withCharset(hinted.header.Get("Content-Type"), "utf-8"))
+	}
+	return hinted, nil
+}
+
+func (resp *hintedResponse) Header() http.Header {
+	return resp.header
+}
+
+func (resp *hintedResponse) Body() io.ReadCloser {
+	return resp.body
+}
+
+// Context returns the context of the response, nil if it does not have a Context method.
+func (resp *hintedResponse) Context() context.Context {
+	if r, ok := resp.Response.(interface{ Context() context.Context }); ok {
+		return r.Context()
+	}
+	return nil
+}
+
+// decodedBody is a body converted to UTF-8 that closes the original body.
+type decodedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// withCharset returns the media type with the charset parameter.
+func withCharset(contentType, label string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+
+	params["charset"] = label
+	return mime.FormatMediaType(mediaType, params)
+}
@@ -68,18 +68,18 @@ func (html *HTMLElement) Value() any {
 }
 
 func (html *HTMLElement) XPathFind(expr string) (Element, error) {
-	htmlNode, err := htmlquery.Query(html.node, expr)
+	htmlNodes, err := queryHTML(html.node, expr, 1)
 	if err != nil {
 		return nil, err
-	} else if htmlNode == nil {
+	} else if len(htmlNodes) == 0 {
 		return nil, nil
 	}
 
-	return &HTMLElement{htmlNode}, nil
+	return &HTMLElement{htmlNodes[0]}, nil
 }
 
 func (html *HTMLElement) XPathFindAll(expr string) ([]Element, error) {
-	htmlNodes, err := htmlquery.QueryAll(html.node, expr)
+	htmlNodes, err := queryHTML(html.node, expr, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -92,7 +92,7 @@ func (html *HTMLElement) XPathFindAll(expr string) ([]Element, error) {
 }
 
 func (html *HTMLElement) CSSFind(expr string) (Element, error) {
-	sel, err := cascadia.Compile(expr)
+	sel, err := precompiled(CSSSelector, expr, cascadia.Compile)
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +105,7 @@ func (html *HTMLElement) CSSFind(expr string) (Element, error) {
 }
 
 func (html *HTMLElement) CSSFindAll(expr string) ([]Element, error) {
-	sel, err := cascadia.Compile(expr)
+	sel, err := precompiled(CSSSelector, expr, cascadia.Compile)
 	if err != nil {
 		return nil, err
 	}
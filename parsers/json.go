@@ -11,7 +11,8 @@ import (
 // JSONRegexp contains a regular expression that matches the JSON MIME type.
 const JSONRegexp = `^application\/(json|x-json|([a-z]+\+json))`
 
-// JSONElement represents a JSON element compatible with XPath expressions.
+// JSONElement represents a JSON element compatible with XPath and JSONPath expressions.
+// If the type of expression is not specified, they assume it is an XPath expression.
 type JSONElement struct {
 	node *jsonquery.Node
 }
@@ -26,26 +27,28 @@ func ParseJSON(resp colibri.Response) (*JSONElement, error) {
 }
 
 func (json *JSONElement) Find(expr, exprType string) (Element, error) {
-	if (exprType != "") && !strings.EqualFold(exprType, XPathExpr) {
-		return nil, ErrExprType
+	query, err := jsonQueryFunc(exprType)
+	if err != nil {
+		return nil, err
 	}
 
-	jsonNode, err := jsonquery.Query(json.node, expr)
+	jsonNodes, err := query(json.node, expr, 1)
 	if err != nil {
 		return nil, err
-	} else if jsonNode == nil {
+	} else if len(jsonNodes) == 0 {
 		return nil, nil
 	}
 
-	return &JSONElement{jsonNode}, nil
+	return &JSONElement{jsonNodes[0]}, nil
 }
 
 func (json *JSONElement) FindAll(expr, exprType string) ([]Element, error) {
-	if (exprType != "") && !strings.EqualFold(exprType, XPathExpr) {
-		return nil, ErrExprType
+	query, err := jsonQueryFunc(exprType)
+	if err != nil {
+		return nil, err
 	}
 
-	jsonNodes, err := jsonquery.QueryAll(json.node, expr)
+	jsonNodes, err := query(json.node, expr, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -60,3 +63,14 @@ func (json *JSONElement) FindAll(expr, exprType string) ([]Element, error) {
 func (json *JSONElement) Value() any {
 	return json.node.Value()
 }
+
+// jsonQueryFunc returns the function that evaluates the type of expression.
+func jsonQueryFunc(exprType string) (func(*jsonquery.Node, string, int) ([]*jsonquery.Node, error), error) {
+	switch {
+	case (exprType == "") || strings.EqualFold(exprType, XPathExpr):
+		return queryJSON, nil
+	case strings.EqualFold(exprType, JSONPathExpr):
+		return queryJSONPath, nil
+	}
+	return nil, ErrExprType
+}
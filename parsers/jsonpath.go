@@ -0,0 +1,641 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/antchfx/jsonquery"
+)
+
+// ErrInvalidJSONPath is returned when the JSONPath expression is not valid.
+var ErrInvalidJSONPath = errors.New("invalid JSONPath expression")
+
+// jsonPath is a compiled JSONPath expression.
+type jsonPath []jsonPathSegment
+
+// jsonPathSegment selects the children of the nodes, or of the nodes and their descendants.
+type jsonPathSegment struct {
+	descendant bool
+	selectors  []jsonPathSelector
+}
+
+// jsonPathSelector is a name, a wildcard, an index, a slice or a filter.
+type jsonPathSelector struct {
+	kind   byte
+	name   string
+	index  int
+	slice  [3]*int
+	filter jsonPathFilter
+}
+
+// Kinds of selectors.
+const (
+	jsonPathKindName     = 'n'
+	jsonPathKindWildcard = '*'
+	jsonPathKindIndex    = 'i'
+	jsonPathKindSlice    = 's'
+	jsonPathKindFilter   = '?'
+)
+
+// jsonPathFilter evaluates a filter expression, current is the node "@" and root is the node "$".
+type jsonPathFilter func(current, root *jsonquery.Node) bool
+
+// jsonPathOperand returns the value of an operand of a comparison, ok is false if there is no value.
+type jsonPathOperand func(current, root *jsonquery.Node) (value any, ok bool)
+
+// queryJSONPath evaluates the JSONPath expression, "$" is the node on which it is evaluated.
+// Returns at most n nodes if n > 0.
+func queryJSONPath(top *jsonquery.Node, expr string, n int) ([]*jsonquery.Node, error) {
+	path, err := precompiled(JSONPathExpr, expr, compileJSONPath)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := path.eval(top, top)
+	if (n > 0) && (len(nodes) > n) {
+		nodes = nodes[:n]
+	}
+	return nodes, nil
+}
+
+func (path jsonPath) eval(node, root *jsonquery.Node) []*jsonquery.Node {
+	nodes := []*jsonquery.Node{node}
+	for _, segment := range path {
+		if segment.descendant {
+			var all []*jsonquery.Node
+			for _, node := range nodes {
+				all = appendJSONDescendants(all, node)
+			}
+			nodes = all
+		}
+
+		var result []*jsonquery.Node
+		for _, node := range nodes {
+			for _, selector := range segment.selectors {
+				result = selector.appendMatches(result, node, root)
+			}
+		}
+		nodes = result
+	}
+	return nodes
+}
+
+// appendMatches appends the children of the node that match the selector.
+func (selector *jsonPathSelector) appendMatches(result []*jsonquery.Node, node, root *jsonquery.Node) []*jsonquery.Node {
+	children := jsonChildren(node)
+
+	switch selector.kind {
+	case jsonPathKindName:
+		if isJSONArray(node) {
+			return result
+		}
+
+		for _, child := range children {
+			if child.Data == selector.name {
+				return append(result, child)
+			}
+		}
+
+	case jsonPathKindWildcard:
+		return append(result, children...)
+
+	case jsonPathKindIndex:
+		if !isJSONArray(node) {
+			return result
+		}
+
+		i := selector.index
+		if i < 0 {
+			i += len(children)
+		}
+
+		if (i >= 0) && (i < len(children)) {
+			return append(result, children[i])
+		}
+
+	case jsonPathKindSlice:
+		if !isJSONArray(node) {
+			return result
+		}
+		return appendJSONSlice(result, children, selector.slice)
+
+	case jsonPathKindFilter:
+		for _, child := range children {
+			if selector.filter(child, root) {
+				result = append(result, child)
+			}
+		}
+	}
+	return result
+}
+
+// appendJSONSlice appends the items of the slice [start:end:step].
+func appendJSONSlice(result, items []*jsonquery.Node, slice [3]*int) []*jsonquery.Node {
+	step := 1
+	if slice[2] != nil {
+		step = *slice[2]
+	}
+
+	if step == 0 {
+		return result
+	}
+
+	bound := func(i int) int {
+		if i < 0 {
+			i += len(items)
+		}
+
+		if step > 0 {
+			return min(max(i, 0), len(items))
+		}
+		return min(max(i, -1), len(items)-1)
+	}
+
+	var start, end int
+	if step > 0 {
+		start, end = 0, len(items)
+	} else {
+		start, end = len(items)-1, -1
+	}
+
+	if slice[0] != nil {
+		start = bound(*slice[0])
+	}
+
+	if slice[1] != nil {
+		end = bound(*slice[1])
+	}
+
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		result = append(result, items[i])
+	}
+	return result
+}
+
+// jsonChildren returns the members of the object or the items of the array.
+func jsonChildren(node *jsonquery.Node) []*jsonquery.Node {
+	var children []*jsonquery.Node
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == jsonquery.ElementNode {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// appendJSONDescendants appends the node and its descendants.
+func appendJSONDescendants(result []*jsonquery.Node, node *jsonquery.Node) []*jsonquery.Node {
+	result = append(result, node)
+	for _, child := range jsonChildren(node) {
+		result = appendJSONDescendants(result, child)
+	}
+	return result
+}
+
+// isJSONArray returns true if the node is an array,
+// the items of the arrays are the children without name.
+func isJSONArray(node *jsonquery.Node) bool {
+	if node.Type == jsonquery.DocumentNode {
+		child := node.FirstChild
+		return (child != nil) && (child.Type == jsonquery.ElementNode) && (child.Data == "")
+	}
+
+	_, ok := node.Value().([]any)
+	return ok
+}
+
+// jsonPathParser parses the JSONPath expressions, see RFC 9535.
+type jsonPathParser struct {
+	expr string
+	pos  int
+}
+
+// compileJSONPath compiles the JSONPath expression, "$" is optional.
+func compileJSONPath(expr string) (jsonPath, error) {
+	p := &jsonPathParser{expr: strings.TrimSpace(expr)}
+	if p.peek() == '$' {
+		p.pos++
+	} else if (p.peek() != '.') && (p.peek() != '[') {
+		// relative path, e.g. "store.book"
+		p.expr = "." + p.expr
+	}
+
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.expr) {
+		return nil, p.errorf("unexpected %q", p.expr[p.pos:])
+	}
+	return path, nil
+}
+
+func (p *jsonPathParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s at %d", ErrInvalidJSONPath, fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *jsonPathParser) peek() byte {
+	if p.pos < len(p.expr) {
+		return p.expr[p.pos]
+	}
+	return 0
+}
+
+func (p *jsonPathParser) skipSpaces() {
+	for (p.pos < len(p.expr)) && strings.IndexByte(" \t\n\r", p.expr[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// consume returns true and advances if the expression continues with s.
+func (p *jsonPathParser) consume(s string) bool {
+	if strings.HasPrefix(p.expr[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// path parses the segments.
+func (p *jsonPathParser) path() (jsonPath, error) {
+	var path jsonPath
+	for {
+		var segment jsonPathSegment
+		switch {
+		case p.consume(".."):
+			segment.descendant = true
+			if p.peek() == '[' {
+				break
+			}
+			fallthrough
+
+		case p.consume("."):
+			if p.consume("*") {
+				segment.selectors = []jsonPathSelector{{kind: jsonPathKindWildcard}}
+				path = append(path, segment)
+				continue
+			}
+
+			name := p.name()
+			if name == "" {
+				return nil, p.errorf("expected name")
+			}
+			segment.selectors = []jsonPathSelector{{kind: jsonPathKindName, name: name}}
+			path = append(path, segment)
+			continue
+
+		case p.peek() != '[':
+			return path, nil
+		}
+
+		selectors, err := p.brackets()
+		if err != nil {
+			return nil, err
+		}
+		segment.selectors = selectors
+		path = append(path, segment)
+	}
+}
+
+// name parses the name of a member after ".".
+func (p *jsonPathParser) name() string {
+	start := p.pos
+	for p.pos < len(p.expr) {
+		c := p.expr[p.pos]
+		if (c >= utf8.RuneSelf) || (c == '_') || (c == '-') ||
+			((c >= 'a') && (c <= 'z')) || ((c >= 'A') && (c <= 'Z')) || ((c >= '0') && (c <= '9')) {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.expr[start:p.pos]
+}
+
+// brackets parses the selectors between brackets separated by commas.
+func (p *jsonPathParser) brackets() ([]jsonPathSelector, error) {
+	p.pos++ // [
+
+	var selectors []jsonPathSelector
+	for {
+		p.skipSpaces()
+		selector, err := p.selector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+
+		p.skipSpaces()
+		if p.consume("]") {
+			return selectors, nil
+		} else if !p.consume(",") {
+			return nil, p.errorf("expected \"]\"")
+		}
+	}
+}
+
+func (p *jsonPathParser) selector() (jsonPathSelector, error) {
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		return jsonPathSelector{kind: jsonPathKindWildcard}, nil
+
+	case (c == '\'') || (c == '"'):
+		name, err := p.string()
+		return jsonPathSelector{kind: jsonPathKindName, name: name}, err
+
+	case c == '?':
+		p.pos++
+		filter, err := p.or()
+		return jsonPathSelector{kind: jsonPathKindFilter, filter: filter}, err
+	}
+
+	var (
+		slice    [3]*int
+		isSlice  bool
+		position int
+	)
+	for {
+		p.skipSpaces()
+		if c := p.peek(); (c == '-') || ((c >= '0') && (c <= '9')) {
+			i, err := p.integer()
+			if err != nil {
+				return jsonPathSelector{}, err
+			}
+			slice[position] = &i
+		}
+
+		p.skipSpaces()
+		if (position < 2) && p.consume(":") {
+			isSlice = true
+			position++
+			continue
+		}
+		break
+	}
+
+	if isSlice {
+		return jsonPathSelector{kind: jsonPathKindSlice, slice: slice}, nil
+	} else if slice[0] == nil {
+		return jsonPathSelector{}, p.errorf("expected selector")
+	}
+	return jsonPathSelector{kind: jsonPathKindIndex, index: *slice[0]}, nil
+}
+
+func (p *jsonPathParser) integer() (int, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+
+	for (p.peek() >= '0') && (p.peek() <= '9') {
+		p.pos++
+	}
+
+	i, err := strconv.Atoi(p.expr[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, p.errorf("invalid integer")
+	}
+	return i, nil
+}
+
+// string parses a string between single or double quotes.
+func (p *jsonPathParser) string() (string, error) {
+	quote := p.peek()
+	p.pos++
+
+	var sb strings.Builder
+	for p.pos < len(p.expr) {
+		c := p.expr[p.pos]
+		p.pos++
+
+		switch {
+		case c == quote:
+			return sb.String(), nil
+
+		case c != '\\':
+			sb.WriteByte(c)
+
+		case p.pos < len(p.expr):
+			c = p.expr[p.pos]
+			p.pos++
+
+			switch c {
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(p.expr) {
+					return "", p.errorf("invalid escape")
+				}
+
+				r, err := strconv.ParseUint(p.expr[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid escape")
+				}
+				sb.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				sb.WriteByte(c)
+			}
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// or parses the logical expressions separated by "||".
+func (p *jsonPathParser) or() (jsonPathFilter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.skipSpaces(); p.consume("||"); p.skipSpaces() {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(current, root *jsonquery.Node) bool {
+			return l(current, root) || right(current, root)
+		}
+	}
+	return left, nil
+}
+
+// and parses the logical expressions separated by "&&".
+func (p *jsonPathParser) and() (jsonPathFilter, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.skipSpaces(); p.consume("&&"); p.skipSpaces() {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(current, root *jsonquery.Node) bool {
+			return l(current, root) && right(current, root)
+		}
+	}
+	return left, nil
+}
+
+// unary parses a negation, a parenthesized expression, a comparison or a test of existence.
+func (p *jsonPathParser) unary() (jsonPathFilter, error) {
+	p.skipSpaces()
+	if p.consume("!") {
+		filter, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(current, root *jsonquery.Node) bool { return !filter(current, root) }, nil
+	}
+
+	if p.consume("(") {
+		filter, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpaces()
+		if !p.consume(")") {
+			return nil, p.errorf("expected \")\"")
+		}
+		return filter, nil
+	}
+
+	isPath := (p.peek() == '@') || (p.peek() == '$')
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.consume(op) {
+			continue
+		}
+
+		p.skipSpaces()
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(current, root *jsonquery.Node) bool {
+			a, aok := left(current, root)
+			b, bok := right(current, root)
+			return compareJSONValues(op, a, aok, b, bok)
+		}, nil
+	}
+
+	if !isPath {
+		return nil, p.errorf("expected comparison")
+	}
+
+	return func(current, root *jsonquery.Node) bool {
+		_, ok := left(current, root)
+		return ok
+	}, nil
+}
+
+// operand parses a path from "@" or "$", whose value is the value of the first node found, or a literal: a string, a number, true, false or null.
+func (p *jsonPathParser) operand() (jsonPathOperand, error) {
+	switch c := p.peek(); {
+	case (c == '@') || (c == '$'):
+		p.pos++
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(current, root *jsonquery.Node) (any, bool) {
+			node := root
+			if c == '@' {
+				node = current
+			}
+
+			nodes := path.eval(node, root)
+			if len(nodes) == 0 {
+				return nil, false
+			}
+			return nodes[0].Value(), true
+		}, nil
+
+	case (c == '\'') || (c == '"'):
+		s, err := p.string()
+		return jsonPathLiteral(s), err
+
+	case (c == '-') || ((c >= '0') && (c <= '9')):
+		start := p.pos
+		for (p.pos < len(p.expr)) && strings.IndexByte("+-.eE0123456789", p.expr[p.pos]) >= 0 {
+			p.pos++
+		}
+
+		f, err := strconv.ParseFloat(p.expr[start:p.pos], 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number")
+		}
+		return jsonPathLiteral(f), nil
+	}
+
+	switch {
+	case p.consume("true"):
+		return jsonPathLiteral(true), nil
+	case p.consume("false"):
+		return jsonPathLiteral(false), nil
+	case p.consume("null"):
+		return jsonPathLiteral(nil), nil
+	}
+	return nil, p.errorf("expected operand")
+}
+
+func jsonPathLiteral(value any) jsonPathOperand {
+	return func(current, root *jsonquery.Node) (any, bool) { return value, true }
+}
+
+// compareJSONValues compares the values with the operator, ok is false if an operand has no value.
+// The values without value are only equal between them, only numbers and strings are ordered.
+func compareJSONValues(op string, a any, aok bool, b any, bok bool) bool {
+	switch op {
+	case "==":
+		return (aok == bok) && (!aok || reflect.DeepEqual(a, b))
+	case "!=":
+		return !compareJSONValues("==", a, aok, b, bok)
+	case "<=", ">=":
+		return compareJSONValues(op[:1], a, aok, b, bok) || compareJSONValues("==", a, aok, b, bok)
+	}
+
+	if op == ">" {
+		a, b = b, a
+	}
+
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		return ok && (a < b)
+	case string:
+		b, ok := b.(string)
+		return ok && (a < b)
+	}
+	return false
+}
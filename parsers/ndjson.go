@@ -0,0 +1,60 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/eduardogxnzalez/colibri"
+
+	"github.com/antchfx/jsonquery"
+)
+
+// NDJSONRegexp contains a regular expression that matches the MIME types of newline delimited JSON.
+const NDJSONRegexp = `(?i)^application\/(x-)?(ndjson|jsonl|jsonlines)`
+
+// ErrInvalidNDJSON is returned when a line of the newline delimited JSON is not a JSON value.
+var ErrInvalidNDJSON = errors.New("invalid JSON value")
+
+// ParseNDJSON parses the newline delimited JSON of the response and returns the root element.
+// The root element is an array with the value of each line, e.g. "/*[1]/name" finds the name
+// of the first value. The empty lines are skipped.
+func ParseNDJSON(resp colibri.Response) (*JSONElement, error) {
+	var (
+		buf     bytes.Buffer
+		scanner = bufio.NewScanner(resp.Body())
+		n       int
+	)
+	scanner.Buffer(nil, bufio.MaxScanTokenSize<<10)
+
+	buf.WriteByte('[')
+	for line := 1; scanner.Scan(); line++ {
+		value := bytes.TrimSpace(scanner.Bytes())
+		if len(value) == 0 {
+			continue
+		}
+
+		if !json.Valid(value) {
+			return nil, fmt.Errorf("line %d: %w", line, ErrInvalidNDJSON)
+		}
+
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(value)
+		n++
+	}
+	buf.WriteByte(']')
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	root, err := jsonquery.Parse(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONElement{root}, nil
+}
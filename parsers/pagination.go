@@ -0,0 +1,273 @@
+package parsers
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// KeyPrevPages is the key of Fields in which the rules of the next pages
+// store the URLs of the previous pages, used to detect cycles.
+const KeyPrevPages = "PrevPages"
+
+// nextLinkXPath finds the URL of the link and a elements with rel="next".
+const nextLinkXPath = `//*[local-name()='link' or local-name()='a'][contains(concat(' ', normalize-space(@rel), ' '), ' next ')]/@href`
+
+// NextPageURL returns the URL of the next page of the response, nil if there is none.
+// The URL is taken from the Link header with rel="next" (RFC 8288) or, if the root element
+// is an HTML or XML document, from the first link or a element with rel="next".
+// Relative URLs are resolved with the URL of the response.
+func NextPageURL(resp colibri.Response, root Element) *url.URL {
+	rawURL := linkHeaderURL(resp.Header(), "next")
+	if rawURL == "" {
+		switch root.(type) {
+		case *HTMLElement, *XMLElement:
+			if element, err := root.Find(nextLinkXPath, XPathExpr); (err == nil) && (element != nil) {
+				rawURL, _ = element.Value().(string)
+			}
+		}
+	}
+	return resolvePageURL(resp, rawURL)
+}
+
+// nextPageURL returns the URL of the next page found by the Next selector of the selectors,
+// see colibri.KeyNext, or by NextPageURL if none of the selectors is a Next selector.
+func nextPageURL(selectors []*colibri.Selector, resp colibri.Response, root Element) *url.URL {
+	i := slices.IndexFunc(selectors, isNextSelector)
+	if i < 0 {
+		return NextPageURL(resp, root)
+	}
+
+	selector := selectors[i]
+	transform, err := pipeline(selector.Pipes)
+	if err != nil {
+		return nil
+	}
+
+	element, err := root.Find(selector.Expr, selector.Type)
+	if (err != nil) || (element == nil) {
+		return nil
+	}
+
+	value, err := elementValue(element, transform)
+	if err != nil {
+		return nil
+	}
+
+	rawURL, _ := value.(string)
+	return resolvePageURL(resp, rawURL)
+}
+
+// isNextSelector reports whether the selector finds the URL of the next page, see colibri.KeyNext.
+func isNextSelector(selector *colibri.Selector) bool {
+	if selector == nil {
+		return false
+	}
+
+	next, _ := selector.Fields[colibri.KeyNext].(bool)
+	return next
+}
+
+// resolvePageURL returns the URL of a page resolved with the URL of the response,
+// nil if it is empty or not an HTTP URL.
+func resolvePageURL(resp colibri.Response, rawURL string) *url.URL {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	if base := resp.URL(); (base != nil) && !u.IsAbs() {
+		u = base.ResolveReference(u)
+	}
+
+	if (u.Scheme != "http") && (u.Scheme != "https") {
+		return nil
+	}
+	return u
+}
+
+// linkHeaderURL returns the URL of the first link of the Link header with the relation type.
+func linkHeaderURL(header http.Header, rel string) string {
+	for _, value := range header.Values("Link") {
+		for value != "" {
+			start := strings.IndexByte(value, '<')
+			if start < 0 {
+				break
+			}
+
+			end := strings.IndexByte(value[start:], '>')
+			if end < 0 {
+				break
+			}
+
+			target := value[start+1 : start+end]
+			value = value[start+end+1:]
+
+			var params string
+			params, value = splitLinkParams(value)
+			for _, param := range strings.Split(params, ";") {
+				key, v, ok := strings.Cut(param, "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+
+				for _, r := range strings.Fields(strings.Trim(strings.TrimSpace(v), `"`)) {
+					if strings.EqualFold(r, rel) {
+						return target
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// splitLinkParams returns the parameters of a link of the Link header
+// and the rest of the header, the commas between quotes are ignored.
+func splitLinkParams(value string) (params, rest string) {
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				return value[:i], value[i+1:]
+			}
+		}
+	}
+	return value, ""
+}
+
+// nextPage extracts the next page of the response with the rules if rules.MaxPages is greater than one.
+// The rules of the next page have MaxPages decreased by one, so the next page extracts the following one.
+// The URL of the next page is found with the Next selector of the selectors, if any, see nextPageURL.
+// Returns nil if there is no next page or it was already extracted.
+func nextPage(rules *colibri.Rules, selectors []*colibri.Selector, resp colibri.Response, root Element) (u *url.URL, output map[string]any, err error) {
+	if rules.MaxPages <= 1 {
+		return nil, nil, nil
+	}
+
+	u = nextPageURL(selectors, resp, root)
+	if u == nil {
+		return nil, nil, nil
+	}
+
+	var (
+		current = branchKey(resp.URL())
+		prev, _ = rules.Fields[KeyPrevPages].([]string)
+		next    = branchKey(u)
+	)
+	if (next == current) || slices.Contains(prev, next) {
+		return nil, nil, nil
+	}
+
+	nextRules := rules.Clone()
+	nextRules.Method = http.MethodGet
+	nextRules.URL = u
+	nextRules.MaxPages = rules.MaxPages - 1
+	nextRules.Fields[KeyPrevPages] = append(slices.Clip(prev), current)
+
+	_, output, err = resp.Extract(nextRules)
+	releaseExtracted(resp, nextRules, err)
+	return u, output, err
+}
+
+// mergePages concatenates the output of the next page to the output of the page.
+// The lists are appended and the maps of the Follow selectors are merged,
+// the other values of the page are kept if they were found.
+func mergePages(output, next map[string]any) map[string]any {
+	if output == nil {
+		output = make(map[string]any, len(next))
+	}
+
+	for name, value := range next {
+		current, ok := output[name]
+		if !ok || isEmpty(current) {
+			output[name] = value
+			continue
+		}
+
+		switch v := value.(type) {
+		case []any:
+			if list, ok := current.([]any); ok {
+				output[name] = append(list, v...)
+			}
+
+		case map[string]any:
+			if m, ok := current.(map[string]any); ok {
+				for key, found := range v {
+					if _, ok := m[key]; !ok {
+						m[key] = found
+					}
+				}
+			}
+		}
+	}
+	return output
+}
+
+// emitPage emits the output of a next page like ParseStream, the items of the All selectors are emitted
+// one by one and the data of each URL of the Follow selectors is emitted as a map with the URL as key.
+func emitPage(selectors []*colibri.Selector, output map[string]any, emit func(name string, value any) error) error {
+	for _, selector := range selectors {
+		if selector == nil {
+			continue
+		}
+
+		value, ok := output[selector.Name]
+		if !ok {
+			continue
+		}
+
+		var err error
+		switch v := value.(type) {
+		case []any:
+			if !selector.All {
+				err = emit(selector.Name, v)
+				break
+			}
+
+			for _, item := range v {
+				if err = emit(selector.Name, item); err != nil {
+					break
+				}
+			}
+
+		case map[string]any:
+			if !selector.Follow {
+				err = emit(selector.Name, v)
+				break
+			}
+
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				if err = emit(selector.Name, map[string]any{key: v[key]}); err != nil {
+					break
+				}
+			}
+
+		default:
+			err = emit(selector.Name, v)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
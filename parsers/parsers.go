@@ -4,28 +4,52 @@ package parsers
 import (
 	"errors"
 	"regexp"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/eduardogxnzalez/colibri"
 )
 
 const (
-	XPathExpr   = "xpath"
-	CSSSelector = "css"
-	RegularExpr = "regular"
+	XPathExpr    = "xpath"
+	CSSSelector  = "css"
+	RegularExpr  = "regular"
+	JSONPathExpr = "jsonpath"
 )
 
+// DefaultMaxFollowDepth default maximum number of nested follows from the seed URL.
+const DefaultMaxFollowDepth = 16
+
+// KeyFollowBranch is the key of Fields in which the rules of the follow requests
+// store the URLs of the pages from the seed URL, used to detect cycles.
+const KeyFollowBranch = "FollowBranch"
+
+// KeyFollowSelector is the key of Fields in which the rules of the follow requests
+// store the name of the Follow selector that made the request.
+const KeyFollowSelector = "FollowSelector"
+
 var (
 	// ErrNotMatch is returned when the Content-Tyepe does not match the Paser.
 	ErrNotMatch = errors.New("Content-Type does not match")
 
 	// ErrExprType is returned when the type of expression is not compatible with the element.
 	ErrExprType = errors.New("ExprType not compatible with Element")
+
+	// ErrExprNotFound is returned when the regular expression is not stored in Parsers.
+	ErrExprNotFound = errors.New("regular expression not found")
+
+	// ErrRequired is returned when a required selector does not find any element.
+	ErrRequired = errors.New("required selector not found")
 )
 
 // ParserFunc parses the content of the response and returns the root element.
 type ParserFunc func(colibri.Response) (Element, error)
 
+// SelectorHook is called after a selector has been evaluated with the value found,
+// the time taken and the error produced (if any).
+type SelectorHook func(resp colibri.Response, selector *colibri.Selector, found any, elapsed time.Duration, err error)
+
 // Parsers stores ParserFunc used to parse the content of the responses.
 // ParserFunc are stored with a regular expression that functions as a key.
 // When a regular expression matches the Content-Type of the response, the content of the response is parsed with the ParserFunc corresponding to the regular expression.
@@ -35,9 +59,14 @@ type Parsers struct {
 		re         *regexp.Regexp
 		parserFunc ParserFunc
 	}
+	hook           SelectorHook
+	followHook     FollowHook
+	maxFollowDepth int
+	protoMessages  map[string]*ProtoMessage
 }
 
-// New returns a new Parsers with ParserFunc to parse HTML, XHML, JSON and Plain Text.
+// New returns a new Parsers with ParserFunc to parse HTML, XHML, JSON, newline delimited JSON,
+// protocol buffers, Plain Text, email messages and archives.
 // See the colibri.Parser interface.
 func New() (*Parsers, error) {
 	parsers := &Parsers{
@@ -52,14 +81,18 @@ func New() (*Parsers, error) {
 	errs = errors.Join(errs, Set(parsers, JSONRegexp, ParseJSON))
 	errs = errors.Join(errs, Set(parsers, TextRegexp, ParseText))
 	errs = errors.Join(errs, Set(parsers, XMLRegexp, ParseXML))
+	errs = errors.Join(errs, Set(parsers, EMLRegexp, ParseEML))
+	errs = errors.Join(errs, Set(parsers, ArchiveRegexp, parsers.ParseArchive))
+	errs = errors.Join(errs, Set(parsers, NDJSONRegexp, ParseNDJSON))
+	errs = errors.Join(errs, Set(parsers, ProtoRegexp, parsers.ParseProto))
 
 	return parsers, errs
 }
 
 // Match returns true if the Content-Type is compatible with the Parser.
 func (parsers *Parsers) Match(contentType string) bool {
-	parsers.rw.Lock()
-	defer parsers.rw.Unlock()
+	parsers.rw.RLock()
+	defer parsers.rw.RUnlock()
 
 	for _, p := range parsers.funcs {
 		if p.re.MatchString(contentType) {
@@ -70,33 +103,166 @@ func (parsers *Parsers) Match(contentType string) bool {
 }
 
 // Parse parses the response based on the rules.
+// If the rules have Variants, the selectors of the variant detected in the response are used.
+// If the rules have MaxPages, the next pages are extracted and their outputs are concatenated,
+// see NextPageURL and colibri.KeyNext.
 func (parsers *Parsers) Parse(rules *colibri.Rules, resp colibri.Response) (map[string]any, error) {
 	if (rules == nil) || (resp == nil) {
 		return nil, nil
 	}
 
-	contentType := resp.Header().Get("Content-Type")
+	parent, state, err := parsers.prepare(rules, resp)
+	if err != nil {
+		return nil, err
+	}
 
-	var parserFunc ParserFunc
-	parsers.rw.Lock()
-	for _, p := range parsers.funcs {
-		if p.re.MatchString(contentType) {
-			parserFunc = p.parserFunc
-			break
+	selectors, detectErr := variantSelectors(rules, resp, parent)
+	output, errs := findSelectors(rules, resp, selectors, parent, state)
+	if detectErr != nil {
+		errs = colibri.AddError(errs, colibri.KeyVariants, detectErr)
+	}
+
+	if u, next, err := nextPage(rules, selectors, resp, parent); u != nil {
+		if err != nil {
+			errs = colibri.AddError(errs, u.String(), err)
 		}
+		output = mergePages(output, next)
 	}
-	parsers.rw.Unlock()
+	return output, errs
+}
+
+// ParseStream parses the response based on the rules and emits the results of the selectors
+// as they are found instead of returning the output map.
+// The items of the All selectors are emitted one by one with the name of the selector,
+// the data of each URL of the Follow selectors is emitted as a map with the URL as key.
+// If emit returns an error, the parse ends and the error is returned.
+// See the colibri.StreamParser interface.
+func (parsers *Parsers) ParseStream(rules *colibri.Rules, resp colibri.Response, emit func(name string, value any) error) error {
+	if (rules == nil) || (resp == nil) {
+		return nil
+	}
+
+	parent, state, err := parsers.prepare(rules, resp)
+	if err != nil {
+		return err
+	}
+
+	selectors, errs := variantSelectors(rules, resp, parent)
+	if errs != nil {
+		errs = colibri.AddError(nil, colibri.KeyVariants, errs)
+	}
+
+	found := make(map[string]any)
+	for _, selector := range sortSelectors(selectors) {
+		if (selector == nil) || skipSelector(selector, found) {
+			continue
+		}
+
+		name, record := selector.Name, !selector.All && !selector.Follow
+		_, err := findSelector(rules, resp, selector, parent, state, func(value any) error {
+			if record {
+				found[name] = value
+			}
+			return emit(name, value)
+		})
+
+		var stop *stopError
+		if errors.As(err, &stop) {
+			return stop.err
+		}
+
+		if err != nil {
+			errs = colibri.AddError(errs, name, err)
+		}
+	}
+
+	if u, next, err := nextPage(rules, selectors, resp, parent); u != nil {
+		if err != nil {
+			errs = colibri.AddError(errs, u.String(), err)
+		}
+
+		if err := emitPage(selectors, next, emit); err != nil {
+			return err
+		}
+	}
+	return errs
+}
+
+// prepare parses the content of the response with the ParserFunc that matches the Content-Type,
+// returns the root element and the state of the parse. The ParserHints of the rules are used
+// instead of the headers of the response, see KeyParserHints.
+func (parsers *Parsers) prepare(rules *colibri.Rules, resp colibri.Response) (Element, *parseState, error) {
+	resp, err := withHints(resp, rulesHints(rules))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parserFunc := parsers.parserFunc(resp.Header().Get("Content-Type"))
+
+	parsers.rw.RLock()
+	hook, followHook, maxFollowDepth := parsers.hook, parsers.followHook, parsers.maxFollowDepth
+	parsers.rw.RUnlock()
 
 	if parserFunc == nil {
-		return nil, ErrNotMatch
+		return nil, nil, ErrNotMatch
 	}
 
 	parent, err := parserFunc(resp)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if maxFollowDepth == 0 {
+		maxFollowDepth = DefaultMaxFollowDepth
+	}
+
+	branch, _ := rules.Fields[KeyFollowBranch].([]string)
+	state := &parseState{
+		hook:           hook,
+		followHook:     followHook,
+		maxFollowDepth: maxFollowDepth,
+		branch:         branch,
+	}
+	return parent, state, nil
+}
+
+// parserFunc returns the ParserFunc that matches the Content-Type, nil if none matches.
+func (parsers *Parsers) parserFunc(contentType string) ParserFunc {
+	parsers.rw.RLock()
+	defer parsers.rw.RUnlock()
+
+	for _, p := range parsers.funcs {
+		if p.re.MatchString(contentType) {
+			return p.parserFunc
+		}
 	}
+	return nil
+}
+
+// SetFollowHook sets the FollowHook called after each request made by a Follow selector.
+// A nil hook removes the current one.
+func (parsers *Parsers) SetFollowHook(hook FollowHook) {
+	parsers.rw.Lock()
+	parsers.followHook = hook
+	parsers.rw.Unlock()
+}
 
-	return findSelectors(rules, resp, rules.Selectors, parent)
+// SetMaxFollowDepth sets the maximum number of nested follows from the seed URL.
+// The URLs that exceed it are skipped, like the URLs that were already visited in the
+// same branch (cycles), see FollowEvent.Skipped.
+// If zero, DefaultMaxFollowDepth is used, a negative value disables the limit.
+func (parsers *Parsers) SetMaxFollowDepth(depth int) {
+	parsers.rw.Lock()
+	parsers.maxFollowDepth = depth
+	parsers.rw.Unlock()
+}
+
+// SetSelectorHook sets the SelectorHook called after each selector is evaluated.
+// A nil hook removes the current one.
+func (parsers *Parsers) SetSelectorHook(hook SelectorHook) {
+	parsers.rw.Lock()
+	parsers.hook = hook
+	parsers.rw.Unlock()
 }
 
 // Clear deletes all stored ParserFunc.
@@ -106,8 +272,49 @@ func (parsers *Parsers) Clear() {
 	parsers.rw.Unlock()
 }
 
+// ReportCapabilities adds the registered parsers and the expression types to the report.
+// See the colibri.CapabilityReporter interface.
+func (parsers *Parsers) ReportCapabilities(caps *colibri.Capabilities) {
+	caps.Parsers = append(caps.Parsers, parsers.List()...)
+	caps.ExprTypes = append(caps.ExprTypes, XPathExpr, CSSSelector, RegularExpr, JSONPathExpr, PathExpr)
+}
+
+// Delete removes the regular expression and the corresponding ParserFunc.
+// Returns true if the regular expression was stored.
+func (parsers *Parsers) Delete(expr string) bool {
+	parsers.rw.Lock()
+	_, ok := parsers.funcs[expr]
+	delete(parsers.funcs, expr)
+	parsers.rw.Unlock()
+	return ok
+}
+
+// List returns the stored regular expressions sorted in increasing order.
+func (parsers *Parsers) List() []string {
+	parsers.rw.RLock()
+	exprs := make([]string, 0, len(parsers.funcs))
+	for expr := range parsers.funcs {
+		exprs = append(exprs, expr)
+	}
+	parsers.rw.RUnlock()
+
+	sort.Strings(exprs)
+	return exprs
+}
+
 // Set adds to parsers the regular expression and the corresponding ParserFunc.
+// If the regular expression is already stored, its ParserFunc is replaced.
 func Set[T Element](parsers *Parsers, expr string, parserFunc func(colibri.Response) (T, error)) error {
+	return set(parsers, expr, parserFunc, false)
+}
+
+// Replace replaces the ParserFunc corresponding to the regular expression.
+// Returns ErrExprNotFound if the regular expression is not stored.
+func Replace[T Element](parsers *Parsers, expr string, parserFunc func(colibri.Response) (T, error)) error {
+	return set(parsers, expr, parserFunc, true)
+}
+
+func set[T Element](parsers *Parsers, expr string, parserFunc func(colibri.Response) (T, error), mustExist bool) error {
 	if parsers == nil || expr == "" || parserFunc == nil {
 		return nil
 	}
@@ -118,6 +325,19 @@ func Set[T Element](parsers *Parsers, expr string, parserFunc func(colibri.Respo
 	}
 
 	parsers.rw.Lock()
+	defer parsers.rw.Unlock()
+
+	if _, ok := parsers.funcs[expr]; mustExist && !ok {
+		return ErrExprNotFound
+	}
+
+	if parsers.funcs == nil {
+		parsers.funcs = make(map[string]struct {
+			re         *regexp.Regexp
+			parserFunc ParserFunc
+		})
+	}
+
 	parsers.funcs[expr] = struct {
 		re         *regexp.Regexp
 		parserFunc ParserFunc
@@ -127,6 +347,5 @@ func Set[T Element](parsers *Parsers, expr string, parserFunc func(colibri.Respo
 			return parserFunc(resp)
 		},
 	}
-	parsers.rw.Unlock()
 	return nil
 }
@@ -1,15 +1,28 @@
 package parsers
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"reflect"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/eduardogxnzalez/colibri"
 )
@@ -483,7 +496,7 @@ func TestParsers(t *testing.T) {
 			output, err := parsers.Parse(tt.Rules, resp)
 			if (err != nil) && (tt.ErrMap != nil) {
 				wantErr, _ := json.Marshal(tt.ErrMap)
-				jsonErrs, _ := json.Marshal(err)
+				jsonErrs := []byte(err.Error()) // the messages without the codes
 
 				if !reflect.DeepEqual(wantErr, jsonErrs) {
 					t.Fatal(err)
@@ -566,6 +579,1967 @@ func TestParsersClear(t *testing.T) {
 	})
 }
 
+func TestParsersManagement(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantList := []string{ArchiveRegexp, EMLRegexp, HTMLRegexp, JSONRegexp, NDJSONRegexp, ProtoRegexp, TextRegexp, XMLRegexp}
+	sort.Strings(wantList)
+	if list := parsers.List(); !reflect.DeepEqual(list, wantList) {
+		t.Fatalf("got %v, want %v", list, wantList)
+	}
+
+	t.Run("Delete", func(t *testing.T) {
+		if !parsers.Delete(TextRegexp) {
+			t.Fatal("expression not deleted")
+		} else if parsers.Delete(TextRegexp) {
+			t.Fatal("expression deleted twice")
+		}
+
+		if parsers.Match("text/plain") {
+			t.Fatal("must not match")
+		}
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		err := Replace(parsers, TextRegexp, ParseText)
+		if !errors.Is(err, ErrExprNotFound) {
+			t.Fatalf("got %v, want %v", err, ErrExprNotFound)
+		}
+
+		if err := Replace(parsers, HTMLRegexp, ParseXML); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(parsers.List()) != 7 {
+			t.Fatal("unexpected number of expressions")
+		}
+	})
+}
+
+func TestFollowMemo(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &testCountClient{}
+	c := colibri.New()
+	c.Client = client
+	c.Parser = parsers
+
+	var (
+		header  = http.Header{"Accept": []string{"text/html"}}
+		follow1 = &colibri.Selector{
+			Name:      "follow1",
+			Expr:      "//a/@href",
+			All:       true,
+			Follow:    true,
+			Selectors: []*colibri.Selector{{Name: "title", Expr: "//title"}},
+			Fields:    map[string]any{"Header": header},
+		}
+		follow2 = follow1.Clone()
+		follow3 = follow1.Clone()
+	)
+	follow2.Name = "follow2"
+	follow3.Name = "follow3"
+	follow3.Selectors[0].Expr = "title"
+	follow3.Selectors[0].Type = "css"
+
+	rules := &colibri.Rules{
+		Selectors: []*colibri.Selector{follow1, follow2, follow3},
+		Fields: map[string]any{
+			"Content-Type": "text/html",
+			"Body":         htmlBody,
+		},
+	}
+
+	output, err := parsers.Parse(rules, newTestResponse(c, rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// follow1 and follow2 share the result, follow3 uses other selectors
+	if client.N != 6 {
+		t.Fatalf("got %v, want %v", client.N, 6)
+	}
+
+	if !reflect.DeepEqual(output["follow1"], output["follow2"]) ||
+		!reflect.DeepEqual(output["follow1"], output["follow3"]) {
+		t.Fatal("not equal")
+	}
+}
+
+func TestFollowGuard(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &testCountClient{}
+	c := colibri.New()
+	c.Client = client
+	c.Parser = parsers
+
+	var (
+		mu      sync.Mutex
+		skipped = make(map[string]int)
+	)
+	parsers.SetFollowHook(func(event FollowEvent) {
+		mu.Lock()
+		skipped[event.Skipped]++
+		mu.Unlock()
+	})
+	parsers.SetMaxFollowDepth(1)
+
+	var (
+		header = http.Header{"Accept": []string{"text/html"}}
+		links  = &colibri.Selector{
+			Name:   "links",
+			Expr:   "//a/@href",
+			All:    true,
+			Follow: true,
+			Fields: map[string]any{"Header": header},
+		}
+	)
+	links.Selectors = []*colibri.Selector{links.Clone()}
+
+	rules := &colibri.Rules{
+		URL:       mustNewURL("https://page.test/html/1#top"),
+		Selectors: []*colibri.Selector{links},
+		Fields: map[string]any{
+			"Content-Type": "text/html",
+			"Body":         htmlBody,
+		},
+	}
+
+	output, err := parsers.Parse(rules, newTestResponse(c, rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// html/1 is the seed page, html/2 and html/3 are followed
+	if client.N != 2 {
+		t.Fatalf("got %v, want %v", client.N, 2)
+	} else if found := output["links"].(map[string]any); len(found) != 2 {
+		t.Fatalf("got %v, want %v", len(found), 2)
+	}
+
+	want := map[string]int{
+		"":           2, // html/2 and html/3
+		SkippedCycle: 1 + 2 + 2,
+		SkippedDepth: 2, // html/3 from html/2 and html/2 from html/3
+	}
+	if !reflect.DeepEqual(skipped, want) {
+		t.Fatalf("got %v, want %v", skipped, want)
+	}
+}
+
+func TestFollowLog(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := colibri.New()
+	c.Client = &testClient{}
+	c.Parser = parsers
+
+	followLog := &FollowLog{}
+	parsers.SetFollowHook(followLog.Hook())
+
+	rules := &colibri.Rules{
+		URL: mustNewURL("https://page.test"),
+		Selectors: []*colibri.Selector{
+			{
+				Name:   "links",
+				Expr:   "//a/@href",
+				All:    true,
+				Follow: true,
+				Selectors: []*colibri.Selector{
+					{
+						Name:   "url",
+						Expr:   "//URL",
+						Follow: true,
+						Fields: map[string]any{
+							"Header": http.Header{"Accept": []string{"text/plain"}},
+						},
+					},
+				},
+				Fields: map[string]any{
+					"Header": http.Header{"Accept": []string{"application/json"}},
+				},
+			},
+		},
+		Fields: map[string]any{
+			"Content-Type": "text/html",
+			"Body":         htmlBody,
+		},
+	}
+
+	if _, err := parsers.Parse(rules, newTestResponse(c, rules)); err != nil {
+		t.Fatal(err)
+	}
+
+	events := followLog.Events()
+	if len(events) != 6 {
+		t.Fatalf("got %v, want %v", len(events), 6)
+	}
+
+	for _, event := range events {
+		wantDepth, wantSelector := 1, "links"
+		if event.Parent != "https://page.test" {
+			wantDepth, wantSelector = 2, "url"
+		}
+
+		if (event.Depth != wantDepth) || (event.Selector != wantSelector) {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	}
+
+	tree := followLog.Tree()
+	if (len(tree) != 1) || (tree[0].URL != "https://page.test") || (len(tree[0].Children) != 3) {
+		t.Fatal("unexpected tree")
+	}
+
+	for _, child := range tree[0].Children {
+		if (len(child.Children) != 1) || (child.Children[0].URL != child.URL) {
+			t.Fatal("unexpected tree")
+		}
+	}
+
+	var buf strings.Builder
+	if err := followLog.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	followLog.Clear()
+	if len(followLog.Events()) > 0 {
+		t.Fatal("Uncleaned")
+	}
+}
+
+func TestFollowParseTimeout(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var extracted []*colibri.Rules
+	rules := &colibri.Rules{
+		Selectors: []*colibri.Selector{{
+			Name:      "items",
+			Expr:      "//a/@href",
+			All:       true,
+			Follow:    true,
+			Selectors: []*colibri.Selector{{Name: "title", Expr: "//title"}},
+		}},
+		Fields: map[string]any{
+			"Content-Type": "text/html",
+			"Body":         htmlBody,
+		},
+	}
+
+	resp := &testTimeoutResp{
+		testResp: newTestResponse(colibri.New(), rules),
+		extract:  func(rules *colibri.Rules) { extracted = append(extracted, rules) },
+	}
+	if _, err := parsers.Parse(rules, resp); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// the abandoned parses still read the rules
+	if len(extracted) != 3 {
+		t.Fatalf("got %v, want %v", len(extracted), 3)
+	} else if got := extracted[0].URL; (got == nil) || (got.String() != "https://page.test/html/1") {
+		t.Fatalf("got %v, want %v", got, "https://page.test/html/1")
+	}
+}
+
+func TestFollowFilters(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		html      = http.Header{"Content-Type": {"text/html"}}
+		requested []string
+		skipped   []string
+	)
+	c := colibri.New()
+	c.Client = &testRecordClient{
+		testPagesClient: testPagesClient{
+			"https://example.com/": {html, `<html><body>
+				<a href="/docs/1">1</a>
+				<a href="/docs/2.pdf">2</a>
+				<a href="/blog/3">3</a>
+				<a href="https://other.example/docs/4">4</a>
+			</body></html>`},
+			"https://example.com/docs/1": {html, `<html><head><title>1</title></head></html>`},
+		},
+		record: func(rules *colibri.Rules) { requested = append(requested, rules.URL.String()) },
+	}
+	c.Parser = parsers
+
+	parsers.SetFollowHook(func(event FollowEvent) {
+		if event.Skipped == SkippedFiltered {
+			skipped = append(skipped, event.URL)
+		}
+	})
+
+	rules, err := colibri.NewRulesBuilder().
+		WithURL("https://example.com/").
+		WithAllow(`^https://example\.com/`).
+		WithSelector(colibri.NewSelector("docs").XPath("//a/@href").All().Follow().
+			Allow(regexp.MustCompile(`/docs/`)).
+			Deny(regexp.MustCompile(`\.pdf$`)).
+			Child(colibri.NewSelector("title").XPath("//title"))).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, output, err := c.Extract(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{"docs": map[string]any{"https://example.com/docs/1": map[string]any{"title": "1"}}}
+	if !reflect.DeepEqual(output, want) {
+		t.Fatalf("got %v, want %v", output, want)
+	}
+
+	if want := []string{"https://example.com/", "https://example.com/docs/1"}; !reflect.DeepEqual(requested, want) {
+		t.Fatalf("got %v, want %v", requested, want)
+	}
+
+	if want := []string{"https://example.com/docs/2.pdf", "https://example.com/blog/3", "https://other.example/docs/4"}; !reflect.DeepEqual(skipped, want) {
+		t.Fatalf("got %v, want %v", skipped, want)
+	}
+}
+
+func TestFollowConcurrency(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		pages = testPagesClient{}
+		list  strings.Builder
+		want  []string
+	)
+	list.WriteString("<html><body>")
+	for i := 1; i <= 8; i++ {
+		rawURL := fmt.Sprintf("https://example.com/item/%d", i)
+		pages[rawURL] = struct {
+			header http.Header
+			body   string
+		}{http.Header{"Content-Type": {"text/html"}}, fmt.Sprintf("<html><head><title>%d</title></head></html>", i)}
+
+		fmt.Fprintf(&list, `<a href="/item/%d">%d</a>`, i, i)
+		want = append(want, rawURL)
+	}
+	list.WriteString("</body></html>")
+	pages["https://example.com/list"] = struct {
+		header http.Header
+		body   string
+	}{http.Header{"Content-Type": {"text/html"}}, list.String()}
+	pages["https://example.com/broken"] = struct {
+		header http.Header
+		body   string
+	}{http.Header{"Content-Type": {"text/html"}}, strings.Replace(list.String(), "</body>", `<a href="/missing">missing</a></body>`, 1)}
+
+	client := &testSlowClient{testPagesClient: pages, delay: 20 * time.Millisecond}
+	c := colibri.New()
+	c.Client = client
+	c.Parser = parsers
+
+	newRules := func(rawURL string) *colibri.Rules {
+		return &colibri.Rules{
+			URL:               mustNewURL(rawURL),
+			FollowConcurrency: 4,
+			Selectors: []*colibri.Selector{{
+				Name:      "items",
+				Expr:      "//a/@href",
+				All:       true,
+				Follow:    true,
+				Selectors: []*colibri.Selector{{Name: "title", Expr: "//title"}},
+			}},
+		}
+	}
+
+	_, output, err := c.Extract(newRules("https://example.com/list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if items := output["items"].(map[string]any); len(items) != len(want) {
+		t.Fatalf("got %v, want %v", len(items), len(want))
+	} else if client.max != 4 {
+		t.Fatalf("got %v, want %v", client.max, 4)
+	}
+
+	_, _, err = c.Extract(newRules("https://example.com/broken"))
+	errs, _ := err.(*colibri.Errs)
+	if errs == nil {
+		t.Fatalf("got %v, want %T", err, errs)
+	}
+
+	nested, _ := errs.Get("items")
+	if errs, _ := nested.(*colibri.Errs); errs == nil {
+		t.Fatalf("got %v, want %T", nested, errs)
+	} else if _, ok := errs.Get("https://example.com/missing"); !ok {
+		t.Fatalf("got %v, want error of the missing page", err)
+	}
+
+	// the URLs are emitted in order
+	var got []string
+	c.ExtractStream(newRules("https://example.com/list"), func(name string, value any) error {
+		for rawURL := range value.(map[string]any) {
+			got = append(got, rawURL)
+		}
+		return nil
+	})
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// the URLs not requested are skipped when emit fails
+	errStop := errors.New("stop")
+	_, err = c.ExtractStream(newRules("https://example.com/list"), func(name string, value any) error {
+		return errStop
+	})
+	if err != errStop {
+		t.Fatalf("got %v, want %v", err, errStop)
+	}
+}
+
+func TestFollowDuplicates(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []string
+	c := colibri.New()
+	c.Client = &testRecordClient{
+		testPagesClient: testPagesClient{
+			"https://example.com/list": {
+				http.Header{"Content-Type": {"text/html"}},
+				`<html><body><a href="/item">A</a><a href="/item#reviews">B</a><a href="/item/">C</a><a href="/other">D</a><a href="/other">E</a></body></html>`,
+			},
+			"https://example.com/item": {
+				http.Header{"Content-Type": {"text/html"}},
+				`<html><head><title>item</title></head></html>`,
+			},
+			"https://example.com/other": {
+				http.Header{"Content-Type": {"text/html"}},
+				`<html><head><title>other</title></head></html>`,
+			},
+		},
+		record: func(rules *colibri.Rules) { requests = append(requests, rules.URL.Path) },
+	}
+	c.Parser = parsers
+
+	var skipped []string
+	parsers.SetFollowHook(func(event FollowEvent) {
+		if event.Skipped != "" {
+			skipped = append(skipped, event.Skipped)
+		}
+	})
+
+	rules := &colibri.Rules{
+		URL: mustNewURL("https://example.com/list"),
+		Selectors: []*colibri.Selector{{
+			Name:      "items",
+			Expr:      "//a/@href",
+			All:       true,
+			Follow:    true,
+			Selectors: []*colibri.Selector{{Name: "title", Expr: "//title"}},
+		}},
+	}
+
+	_, output, err := c.Extract(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"/list", "/item", "/other"}; !reflect.DeepEqual(requests, want) {
+		t.Fatalf("got %v, want %v", requests, want)
+	}
+
+	want := map[string]any{
+		"https://example.com/item":  map[string]any{"title": "item"},
+		"https://example.com/other": map[string]any{"title": "other"},
+	}
+	if !reflect.DeepEqual(output["items"], want) {
+		t.Fatalf("got %v, want %v", output["items"], want)
+	}
+
+	if want := []string{SkippedDuplicate, SkippedDuplicate, SkippedDuplicate}; !reflect.DeepEqual(skipped, want) {
+		t.Fatalf("got %v, want %v", skipped, want)
+	}
+}
+
+func TestParserHints(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var language string
+	err = Set(parsers, `^text/x-lang`, func(resp colibri.Response) (*TextElement, error) {
+		language = HintsOf(resp).Language
+		return ParseText(resp)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := colibri.New()
+	newRules := func(hints any) *colibri.Rules {
+		return &colibri.Rules{
+			URL:       mustNewURL("https://example.com"),
+			Selectors: []*colibri.Selector{{Name: "title", Expr: "//title"}},
+			Fields: map[string]any{
+				"Content-Type": "application/octet-stream",
+				"Body":         "<html><head><meta charset=\"utf-8\"><title>caf\xe9</title></head></html>",
+				KeyParserHints: hints,
+			},
+		}
+	}
+
+	tests := []struct {
+		Hints   any
+		Want    any
+		WantErr error
+	}{
+		{nil, nil, ErrNotMatch},
+		{ParserHints{ContentType: "text/html", Charset: "windows-1252"}, "café", nil},
+		{map[string]any{"ContentType": "text/html", "Charset": "latin1"}, "café", nil},
+		{ParserHints{ContentType: "text/html", Charset: "unknown"}, nil, ErrUnknownCharset},
+	}
+
+	for _, tt := range tests {
+		rules := newRules(tt.Hints)
+		output, err := parsers.Parse(rules, newTestResponse(c, rules))
+		if !errors.Is(err, tt.WantErr) {
+			t.Fatalf("got %v, want %v", err, tt.WantErr)
+		} else if (err == nil) && (output["title"] != tt.Want) {
+			t.Fatalf("got %v, want %v", output["title"], tt.Want)
+		}
+	}
+
+	rules := newRules(map[string]any{"ContentType": "text/x-lang", "Language": "es"})
+	rules.Selectors = []*colibri.Selector{{Name: "title", Expr: `<title>(.*)</title>`}}
+	if _, err := parsers.Parse(rules, newTestResponse(c, rules)); err != nil {
+		t.Fatal(err)
+	} else if language != "es" {
+		t.Fatalf("got %v, want %v", language, "es")
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := http.Header{"Content-Type": {"text/html"}}
+	c := colibri.New()
+	c.Client = &testContextClient{testPagesClient{
+		"https://example.com/": {html, `<html><body>
+			<a href="/docs/1">1</a>
+			<a href="/blog/2">2</a>
+			<a href="/docs/3">3</a>
+		</body></html>`},
+		"https://example.com/docs/1": {html, `<html><head><title>1</title></head></html>`},
+	}}
+	c.RobotsTxt = &testDenyRobots{path: "/docs/3"}
+	c.Parser = parsers
+
+	var got []string
+	c.OnWarning = func(_ context.Context, w *colibri.Warning) {
+		got = append(got, w.Kind+" "+w.Key+" "+w.URL.String())
+	}
+
+	rules, err := colibri.NewRulesBuilder().
+		WithURL("https://example.com/").
+		WithSelector(colibri.NewSelector("docs").XPath("//a/@href").All().Follow().
+			Allow(regexp.MustCompile(`/docs/`)).
+			Child(colibri.NewSelector("title").XPath("//title"))).
+		WithSelector(colibri.NewSelector("missing").XPath("//h2")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, output, err := c.Extract(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"docs":    map[string]any{"https://example.com/docs/1": map[string]any{"title": "1"}},
+		"missing": nil,
+	}
+	if !reflect.DeepEqual(output, want) {
+		t.Fatalf("got %v, want %v", output, want)
+	}
+
+	sort.Strings(got)
+	wantWarnings := []string{
+		colibri.WarningEmpty + " missing https://example.com/",
+		colibri.WarningFiltered + " docs https://example.com/blog/2",
+		colibri.WarningRobotsTxt + " docs https://example.com/docs/3",
+	}
+	if !reflect.DeepEqual(got, wantWarnings) {
+		t.Fatalf("got %v, want %v", got, wantWarnings)
+	}
+}
+
+type testContextResp struct {
+	*testResp
+	ctx context.Context
+}
+
+func (r *testContextResp) Context() context.Context { return r.ctx }
+
+type testContextClient struct {
+	testPagesClient
+}
+
+func (client *testContextClient) DoContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	resp, err := client.testPagesClient.Do(c, rules)
+	if err != nil {
+		return nil, err
+	}
+	return &testContextResp{resp.(*testResp), ctx}, nil
+}
+
+type testDenyRobots struct {
+	path string
+}
+
+func (robots *testDenyRobots) IsAllowed(_ *colibri.Colibri, rules *colibri.Rules) error {
+	if rules.URL.Path == robots.path {
+		return errors.New("disallowed")
+	}
+	return nil
+}
+func (robots *testDenyRobots) Clear() {}
+
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		Err            error
+		Code, Category string
+	}{
+		{ErrRequired, CodeRequiredNotFound, colibri.CategoryParse},
+		{&XPathBudgetError{}, CodeBudgetExceeded, colibri.CategoryParse},
+		{ErrPipeNotFound, colibri.CodeInvalidRules, colibri.CategoryRules},
+	}
+
+	for _, tt := range tests {
+		code, category := colibri.ErrorCode(tt.Err)
+		if (code != tt.Code) || (category != tt.Category) {
+			t.Fatalf("%v: got %v %v, want %v %v", tt.Err, code, category, tt.Code, tt.Category)
+		}
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		articleBody = `<html><head>
+			<meta property="og:title" content="Go 1.21">
+			<meta name="author" content="Gopher">
+			</head><body><article><h1>Release</h1><p>One</p><p>Two</p></article></body></html>`
+
+		productBody = `<html><body><div itemscope itemtype="https://schema.org/Product">
+			<h1 itemprop="name">Gopher plush</h1>
+			<span itemprop="price" content="19.99">$19.99</span>
+			<meta itemprop="priceCurrency" content="USD">
+			</div></body></html>`
+
+		sitemapBody = `<?xml version="1.0" encoding="UTF-8"?>
+			<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+				<url><loc>https://page.test/1</loc></url>
+				<url><loc>https://page.test/2</loc></url>
+			</urlset>`
+	)
+
+	tests := []struct {
+		Template    func(string) (*colibri.Rules, error)
+		ContentType string
+		Body        string
+		Want        map[string]any
+	}{
+		{
+			colibri.TemplateArticle,
+			"text/html",
+			articleBody,
+			map[string]any{"title": "Go 1.21", "author": "Gopher", "paragraphs": []any{"One", "Two"}},
+		},
+		{
+			colibri.TemplateProduct,
+			"text/html",
+			productBody,
+			map[string]any{"name": "Gopher plush", "price": "19.99", "currency": "USD"},
+		},
+		{
+			colibri.TemplateSitemap,
+			"application/xml",
+			sitemapBody,
+			map[string]any{"urls": []any{"https://page.test/1", "https://page.test/2"}},
+		},
+		{
+			colibri.TemplateRSS,
+			"application/xml",
+			xmlBody,
+			map[string]any{"title": "Test RSS", "link": "https://www.test.rss"},
+		},
+	}
+
+	for _, tt := range tests {
+		rules, err := tt.Template("https://page.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rules.Fields["Content-Type"] = tt.ContentType
+		rules.Fields["Body"] = tt.Body
+
+		output, err := parsers.Parse(rules, newTestResponse(nil, rules))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for key, want := range tt.Want {
+			if !reflect.DeepEqual(output[key], want) {
+				t.Fatalf("%v: got %v, want %v", key, output[key], want)
+			}
+		}
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := colibri.New()
+	c.Client = &testClient{}
+	c.Parser = parsers
+
+	rules := &colibri.Rules{
+		Selectors: []*colibri.Selector{
+			{Name: "title", Expr: "//title"},
+			{Name: "links", Expr: "//a/@href", All: true},
+			{
+				Name:      "follow",
+				Expr:      "//a/@href",
+				All:       true,
+				Follow:    true,
+				Selectors: []*colibri.Selector{{Name: "title", Expr: "//title"}},
+				Fields:    map[string]any{"Header": http.Header{"Accept": []string{"text/html"}}},
+			},
+		},
+		Fields: map[string]any{
+			"Content-Type": "text/html",
+			"Body":         htmlBody,
+		},
+	}
+
+	output, err := parsers.Parse(rules, newTestResponse(c, rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		got   = make(map[string]any)
+		count = make(map[string]int)
+	)
+	err = parsers.ParseStream(rules, newTestResponse(c, rules), func(name string, value any) error {
+		count[name]++
+		switch name {
+		case "links":
+			links, _ := got[name].([]any)
+			got[name] = append(links, value)
+
+		case "follow":
+			follow, _ := got[name].(map[string]any)
+			if follow == nil {
+				follow = make(map[string]any)
+			}
+
+			for k, v := range value.(map[string]any) {
+				follow[k] = v
+			}
+			got[name] = follow
+
+		default:
+			got[name] = value
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, output) {
+		t.Fatalf("got %v, want %v", got, output)
+	}
+
+	want := map[string]int{"title": 1, "links": 3, "follow": 3}
+	if !reflect.DeepEqual(count, want) {
+		t.Fatalf("got %v, want %v", count, want)
+	}
+
+	var (
+		n       int
+		testErr = errors.New("Test Error")
+	)
+	err = parsers.ParseStream(rules, newTestResponse(c, rules), func(name string, value any) error {
+		if n++; n == 2 {
+			return testErr
+		}
+		return nil
+	})
+	if !errors.Is(err, testErr) {
+		t.Fatalf("got %v, want %v", err, testErr)
+	} else if n != 2 {
+		t.Fatalf("got %v, want %v", n, 2)
+	}
+}
+
+func TestXPathBudget(t *testing.T) {
+	type xpathTest struct {
+		Parse func(colibri.Response) (Element, error)
+		Body  string
+		Expr  string
+	}
+
+	tests := []xpathTest{
+		{func(resp colibri.Response) (Element, error) { return ParseHTML(resp) }, htmlBody, "//a/@href"},
+		{func(resp colibri.Response) (Element, error) { return ParseXML(resp) }, xmlBody, "//item/link"},
+		{func(resp colibri.Response) (Element, error) { return ParseJSON(resp) }, jsonBody, "//hobbies/*"},
+	}
+
+	maxNodes, timeout := MaxXPathNodes, XPathTimeout
+	defer func() { MaxXPathNodes, XPathTimeout = maxNodes, timeout }()
+
+	values := func(tt xpathTest) ([]any, error) {
+		resp := &testResp{header: http.Header{}, body: io.NopCloser(strings.NewReader(tt.Body))}
+		root, err := tt.Parse(resp)
+		if err != nil {
+			return nil, err
+		}
+
+		elements, err := root.FindAll(tt.Expr, XPathExpr)
+		if err != nil {
+			return nil, err
+		}
+
+		var result []any
+		for _, element := range elements {
+			result = append(result, element.Value())
+		}
+
+		if element, err := root.Find(tt.Expr, XPathExpr); err != nil {
+			return nil, err
+		} else if (element == nil) || !reflect.DeepEqual(element.Value(), result[0]) {
+			t.Fatalf("got %v, want %v", element, result[0])
+		}
+		return result, nil
+	}
+
+	for _, tt := range tests {
+		MaxXPathNodes, XPathTimeout = 0, 0
+		want, err := values(tt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		MaxXPathNodes, XPathTimeout = 10000, time.Second
+		if got, err := values(tt); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+
+		MaxXPathNodes = 3
+		_, err = values(tt)
+
+		var budgetErr *XPathBudgetError
+		if !errors.Is(err, ErrBudgetExceeded) || !errors.As(err, &budgetErr) {
+			t.Fatalf("got %v, want %v", err, ErrBudgetExceeded)
+		} else if budgetErr.Expr != tt.Expr {
+			t.Fatalf("got %v, want %v", budgetErr.Expr, tt.Expr)
+		}
+	}
+}
+
+func TestRegexpSafeguards(t *testing.T) {
+	tests := []struct {
+		Expr string
+		Want error
+	}{
+		{`a(?=b)`, ErrRegexpSyntax},
+		{`(?<!a)b`, ErrRegexpSyntax},
+		{`(a)\1`, ErrRegexpSyntax},
+		{`a++`, ErrRegexpSyntax},
+		{strings.Repeat("a", MaxRegexpLen+1), ErrRegexpTooLong},
+		{`a+b`, nil},
+	}
+
+	text := &TextElement{data: []byte("aab")}
+	for _, tt := range tests {
+		if _, err := text.Find(tt.Expr, RegularExpr); !errors.Is(err, tt.Want) {
+			t.Fatalf("got %v, want %v", err, tt.Want)
+		}
+	}
+
+	maxTextSize := MaxTextSize
+	defer func() { MaxTextSize = maxTextSize }()
+
+	MaxTextSize = 4
+	resp := &testResp{header: http.Header{}, body: io.NopCloser(strings.NewReader("12345"))}
+	if _, err := ParseText(resp); !errors.Is(err, ErrTextTooLarge) {
+		t.Fatalf("got %v, want %v", err, ErrTextTooLarge)
+	}
+
+	resp.body = io.NopCloser(strings.NewReader("1234"))
+	if _, err := ParseText(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	text.ctx = ctx
+	if _, err := text.FindAll(`a`, RegularExpr); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestEML(t *testing.T) {
+	body := strings.ReplaceAll(`From: =?UTF-8?Q?Jos=C3=A9?= <jose@example.com>
+To: a@example.com
+To: b@example.com
+Subject: =?UTF-8?B?SG9sYSBtdW5kbw==?=
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="mixed"
+
+--mixed
+Content-Type: multipart/alternative; boundary="alt"
+
+--alt
+Content-Type: text/plain; charset=iso-8859-1
+Content-Transfer-Encoding: quoted-printable
+
+Ma=F1ana
+--alt
+Content-Type: text/html; charset=utf-8
+
+<p>Ma&ntilde;ana</p>
+--alt--
+--mixed
+Content-Type: application/pdf; name="report.pdf"
+Content-Disposition: attachment; filename="report.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERi0x
+--mixed
+Content-Type: message/rfc822
+
+Subject: Forwarded
+
+Original
+--mixed--
+`, "\n", "\r\n")
+
+	resp := &testResp{header: http.Header{}, body: io.NopCloser(strings.NewReader(body))}
+	root, err := ParseEML(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Expr string
+		Want []any
+	}{
+		{"/message/header/from", []any{"José <jose@example.com>"}},
+		{"/message/header/to", []any{"a@example.com", "b@example.com"}},
+		{"/message/header/subject", []any{"Hola mundo"}},
+		{"/message/text", []any{"Mañana"}},
+		{"/message/html", []any{"<p>Ma&ntilde;ana</p>"}},
+		{"/message/attachment/@filename", []any{"report.pdf"}},
+		{"/message/attachment/@content-type", []any{"application/pdf"}},
+		{"/message/attachment/@size", []any{"6"}},
+		{"/message/attachment", []any{"JVBERi0x"}},
+		{"/message/message/header/subject", []any{"Forwarded"}},
+		{"/message/message/text", []any{"Original"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Expr, func(t *testing.T) {
+			elements, err := root.FindAll(tt.Expr, XPathExpr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []any
+			for _, element := range elements {
+				got = append(got, element.Value())
+			}
+
+			if !reflect.DeepEqual(got, tt.Want) {
+				t.Fatalf("got %v, want %v", got, tt.Want)
+			}
+		})
+	}
+
+	t.Run("MaxEMLSize", func(t *testing.T) {
+		maxEMLSize := MaxEMLSize
+		defer func() { MaxEMLSize = maxEMLSize }()
+
+		MaxEMLSize = 4
+		resp := &testResp{header: http.Header{}, body: io.NopCloser(strings.NewReader(body))}
+		if _, err := ParseEML(resp); !errors.Is(err, ErrEMLTooLarge) {
+			t.Fatalf("got %v, want %v", err, ErrEMLTooLarge)
+		}
+	})
+}
+
+func TestArchive(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []struct {
+		Name string
+		Body string
+	}{
+		{"data/", ""},
+		{"data/a.html", "<html><head><title>A</title></head></html>"},
+		{"./data/b.json", `{"name": "B"}`},
+		{"notes.txt", "version: 1.2"},
+	}
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for _, file := range files {
+		w, _ := zw.Create(file.Name)
+		io.WriteString(w, file.Body)
+	}
+	zw.Close()
+
+	var tgzBuf bytes.Buffer
+	gz := gzip.NewWriter(&tgzBuf)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		if strings.HasSuffix(file.Name, "/") {
+			tw.WriteHeader(&tar.Header{Name: file.Name, Typeflag: tar.TypeDir, Mode: 0o755})
+			continue
+		}
+		tw.WriteHeader(&tar.Header{Name: file.Name, Mode: 0o644, Size: int64(len(file.Body))})
+		io.WriteString(tw, file.Body)
+	}
+	tw.Close()
+	gz.Close()
+
+	rules := &colibri.Rules{
+		URL: mustNewURL("https://example.com/data"),
+		Selectors: []*colibri.Selector{
+			{Name: "files", Expr: "*/*", Type: PathExpr, All: true},
+			{Name: "title", Expr: "data/*.html", Selectors: []*colibri.Selector{{Name: "title", Expr: "//title"}}},
+			{Name: "name", Expr: "data/b.json", Selectors: []*colibri.Selector{{Name: "name", Expr: "//name"}}},
+			{Name: "version", Expr: "notes.txt", Selectors: []*colibri.Selector{{Name: "version", Expr: `[0-9.]+`, Type: RegularExpr}}},
+			{Name: "missing", Expr: "*.csv"},
+		},
+	}
+
+	want := map[string]any{
+		"files":   []any{"data/a.html", "data/b.json"},
+		"title":   map[string]any{"title": "A"},
+		"name":    map[string]any{"name": "B"},
+		"version": map[string]any{"version": "1.2"},
+		"missing": nil,
+	}
+
+	tests := []struct {
+		Name        string
+		ContentType string
+		Body        []byte
+	}{
+		{"Zip", "application/zip", zipBuf.Bytes()},
+		{"TarGzip", "application/gzip", tgzBuf.Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			resp := &testResp{u: rules.URL, header: http.Header{"Content-Type": {tt.ContentType}}, body: io.NopCloser(bytes.NewReader(tt.Body))}
+
+			output, err := parsers.Parse(rules, resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(output, want) {
+				t.Fatalf("got %v, want %v", output, want)
+			}
+		})
+	}
+
+	t.Run("Gzip", func(t *testing.T) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		io.WriteString(gz, "version: 2.0")
+		gz.Close()
+
+		resp := &testResp{u: mustNewURL("https://example.com/notes.txt.gz"), header: http.Header{}, body: io.NopCloser(&buf)}
+		archive, err := parsers.ParseArchive(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if names := archive.Value(); !reflect.DeepEqual(names, []any{"notes.txt"}) {
+			t.Fatalf("got %v, want %v", names, []any{"notes.txt"})
+		}
+	})
+
+	t.Run("MaxArchiveSize", func(t *testing.T) {
+		maxArchiveSize := MaxArchiveSize
+		defer func() { MaxArchiveSize = maxArchiveSize }()
+
+		// the archive fits, its decompressed files do not
+		MaxArchiveSize = int64(tgzBuf.Len()) + 10
+		resp := &testResp{u: rules.URL, header: http.Header{}, body: io.NopCloser(bytes.NewReader(tgzBuf.Bytes()))}
+		if _, err := parsers.ParseArchive(resp); !errors.Is(err, ErrArchiveTooLarge) {
+			t.Fatalf("got %v, want %v", err, ErrArchiveTooLarge)
+		}
+	})
+}
+
+func TestJSONPath(t *testing.T) {
+	body := `{
+		"store": {
+			"book": [
+				{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+				{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+				{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+				{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+			],
+			"bicycle": {"color": "red", "price": 399}
+		}
+	}`
+
+	resp := &testResp{header: http.Header{}, body: io.NopCloser(strings.NewReader(body))}
+	root, err := ParseJSON(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authors := []any{"Nigel Rees", "Evelyn Waugh", "Herman Melville", "J. R. R. Tolkien"}
+
+	tests := []struct {
+		Expr string
+		Want []any
+	}{
+		{"$.store.book[*].author", authors},
+		{"$..author", authors},
+		{"store.book[*].author", authors},
+		{"$['store']['bicycle']['color']", []any{"red"}},
+		{"$.store.bicycle.price", []any{float64(399)}},
+		{"$.store.book[2].title", []any{"Moby Dick"}},
+		{"$.store.book[-1].title", []any{"The Lord of the Rings"}},
+		{"$.store.book[0,1].author", authors[:2]},
+		{"$.store.book[:2].author", authors[:2]},
+		{"$.store.book[1:3].author", authors[1:3]},
+		{"$.store.book[::-2].author", []any{authors[3], authors[1]}},
+		{"$.store.book[?(@.isbn)].title", []any{"Moby Dick", "The Lord of the Rings"}},
+		{"$.store.book[?(@.price < 10)].title", []any{"Sayings of the Century", "Moby Dick"}},
+		{"$..book[?@.category == 'fiction' && @.price >= 20].author", []any{"J. R. R. Tolkien"}},
+		{`$..book[?(!(@.category == "fiction") || @.price > 22)].author`, []any{"Nigel Rees", "J. R. R. Tolkien"}},
+		{"$.store.book[?(@.price > $.store.bicycle.price)]", nil},
+		{"$.store.bicycle[0]", nil},
+		{"$.store.book.author", nil},
+		{"$.store.*.color", []any{"red"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Expr, func(t *testing.T) {
+			elements, err := root.FindAll(tt.Expr, JSONPathExpr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []any
+			for _, element := range elements {
+				got = append(got, element.Value())
+			}
+
+			if !reflect.DeepEqual(got, tt.Want) {
+				t.Fatalf("got %v, want %v", got, tt.Want)
+			}
+		})
+	}
+
+	element, err := root.Find("$.store.book[*].price", "JSONPath")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := element.Value(); got != float64(8.95) {
+		t.Fatalf("got %v, want %v", got, 8.95)
+	}
+
+	for _, expr := range []string{"$.", "$[", "$.store[?(@.price <)]", "$['store", "$.store]"} {
+		if _, err := root.FindAll(expr, JSONPathExpr); !errors.Is(err, ErrInvalidJSONPath) {
+			t.Fatalf("%s: got %v, want %v", expr, err, ErrInvalidJSONPath)
+		}
+	}
+}
+
+func TestPagination(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := colibri.New()
+	c.Client = testPagesClient{
+		"https://api.example.com/items": {
+			http.Header{"Content-Type": {"application/json"}, "Link": {`<https://api.example.com/items?page=3>; rel="last", <?page=2>; rel="next"`}},
+			`{"items": ["a", "b"], "total": 5}`,
+		},
+		"https://api.example.com/items?page=2": {
+			http.Header{"Content-Type": {"application/json"}, "Link": {`</items?page=3>; title="next, please"; rel="next"`}},
+			`{"items": ["c", "d"], "total": 5}`,
+		},
+		"https://api.example.com/items?page=3": {
+			http.Header{"Content-Type": {"application/json"}, "Link": {`</items>; rel="first next"`}},
+			`{"items": ["e"], "total": 5}`,
+		},
+		"https://example.com/blog": {
+			http.Header{"Content-Type": {"text/html"}},
+			`<html><head><link rel="next" href="/blog/2"></head><body><h2>A</h2></body></html>`,
+		},
+		"https://example.com/blog/2": {
+			http.Header{"Content-Type": {"text/html"}},
+			`<html><body><h2>B</h2><a rel="nofollow next" href="/blog/3">Next</a></body></html>`,
+		},
+		"https://example.com/blog/3": {
+			http.Header{"Content-Type": {"text/html"}},
+			`<html><body><h2>C</h2><a rel="prev" href="/blog/2">Prev</a></body></html>`,
+		},
+	}
+	c.Parser = parsers
+
+	tests := []struct {
+		URL      string
+		MaxPages int
+		Want     map[string]any
+	}{
+		{"https://api.example.com/items", 0, map[string]any{"items": []any{"a", "b"}, "total": float64(5)}},
+		{"https://api.example.com/items", 2, map[string]any{"items": []any{"a", "b", "c", "d"}, "total": float64(5)}},
+		{"https://api.example.com/items", 10, map[string]any{"items": []any{"a", "b", "c", "d", "e"}, "total": float64(5)}},
+		{"https://example.com/blog", 10, map[string]any{"items": []any{"A", "B", "C"}, "total": nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.URL, func(t *testing.T) {
+			rules := &colibri.Rules{
+				Method:   "GET",
+				URL:      mustNewURL(tt.URL),
+				MaxPages: tt.MaxPages,
+				Selectors: []*colibri.Selector{
+					{Name: "items", Expr: "//items/* | //h2", All: true},
+					{Name: "total", Expr: "//total"},
+				},
+			}
+
+			_, output, err := c.Extract(rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(output, tt.Want) {
+				t.Fatalf("got %v, want %v", output, tt.Want)
+			}
+		})
+	}
+
+	t.Run("Stream", func(t *testing.T) {
+		rules := &colibri.Rules{
+			Method:    "GET",
+			URL:       mustNewURL("https://api.example.com/items"),
+			MaxPages:  10,
+			Selectors: []*colibri.Selector{{Name: "items", Expr: "//items/*", All: true}},
+		}
+
+		var got []any
+		_, err := c.ExtractStream(rules, func(name string, value any) error {
+			got = append(got, value)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := []any{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Next", func(t *testing.T) {
+		c := colibri.New()
+		c.Client = testPagesClient{
+			"https://example.com/shop": {
+				http.Header{"Content-Type": {"text/html"}, "Link": {`</ignored>; rel="next"`}},
+				`<html><body><p>A</p><a class="more" href="/shop?p=2">More</a></body></html>`,
+			},
+			"https://example.com/shop?p=2": {
+				http.Header{"Content-Type": {"text/html"}},
+				`<html><body><p>B</p><a class="more" href="?p=3">More</a></body></html>`,
+			},
+			"https://example.com/shop?p=3": {
+				http.Header{"Content-Type": {"text/html"}},
+				`<html><body><p>C</p><a class="more" href="/shop">More</a></body></html>`,
+			},
+		}
+		c.Parser = parsers
+
+		rules, err := colibri.NewRules(map[string]any{
+			"URL":      "https://example.com/shop",
+			"MaxPages": 10,
+			"Selectors": map[string]any{
+				"items": map[string]any{"Expr": "//p", "All": true},
+				"next":  map[string]any{"Expr": "//a[@class='more']/@href", "Next": "true"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, output, err := c.Extract(rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the page of the link to the first page is not requested again
+		if want := []any{"A", "B", "C"}; !reflect.DeepEqual(output["items"], want) {
+			t.Fatalf("got %v, want %v", output["items"], want)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		c := colibri.New()
+		c.Client = testPagesClient{
+			"https://api.example.com/items": {
+				http.Header{"Content-Type": {"application/json"}, "Link": {`<?page=2>; rel=next`}},
+				`{"items": ["a"]}`,
+			},
+		}
+		c.Parser = parsers
+
+		rules := &colibri.Rules{
+			URL:       mustNewURL("https://api.example.com/items"),
+			MaxPages:  2,
+			Selectors: []*colibri.Selector{{Name: "items", Expr: "//items/*", All: true}},
+		}
+
+		_, output, err := c.Extract(rules)
+		errs, _ := err.(*colibri.Errs)
+		if errs == nil {
+			t.Fatalf("got %v, want %T", err, errs)
+		}
+
+		if _, ok := errs.Get("https://api.example.com/items?page=2"); !ok {
+			t.Fatalf("got %v, want error of the next page", err)
+		}
+
+		if want := []any{"a"}; !reflect.DeepEqual(output["items"], want) {
+			t.Fatalf("got %v, want %v", output["items"], want)
+		}
+	})
+}
+
+func TestPaginationParseTimeout(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var extracted []*colibri.Rules
+	c := colibri.New()
+	c.Client = &testTimeoutClient{
+		testPagesClient: testPagesClient{
+			"https://example.com/blog": {
+				http.Header{"Content-Type": {"text/html"}},
+				`<html><head><link rel="next" href="/blog/2"></head><body><h2>A</h2></body></html>`,
+			},
+		},
+		extract: func(rules *colibri.Rules) { extracted = append(extracted, rules) },
+	}
+	c.Parser = parsers
+
+	rules := &colibri.Rules{
+		URL:       mustNewURL("https://example.com/blog"),
+		MaxPages:  2,
+		Selectors: []*colibri.Selector{{Name: "items", Expr: "//h2", All: true}},
+	}
+
+	_, _, err = c.Extract(rules)
+	if errs, _ := err.(*colibri.Errs); errs == nil {
+		t.Fatalf("got %v, want %v", err, colibri.ErrParseTimeout)
+	} else if err, _ := errs.Get("https://example.com/blog/2"); !errors.Is(err, colibri.ErrParseTimeout) {
+		t.Fatalf("got %v, want %v", err, colibri.ErrParseTimeout)
+	}
+
+	// the abandoned parse still reads the rules
+	if len(extracted) != 1 {
+		t.Fatalf("got %v, want %v", len(extracted), 1)
+	} else if got := extracted[0].URL; (got == nil) || (got.String() != "https://example.com/blog/2") {
+		t.Fatalf("got %v, want %v", got, "https://example.com/blog/2")
+	}
+}
+
+func TestVars(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tokens []string
+	c := colibri.New()
+	c.Client = &testRecordClient{
+		testPagesClient: testPagesClient{
+			"https://example.com/login": {
+				http.Header{"Content-Type": {"text/html"}},
+				`<html><body><input name="csrf" value="T1"><a href="/items?token={{token}}">Items</a></body></html>`,
+			},
+			"https://example.com/items?token=T1": {
+				http.Header{"Content-Type": {"text/html"}},
+				`<html><body><h1>Items</h1></body></html>`,
+			},
+		},
+		record: func(rules *colibri.Rules) { tokens = append(tokens, rules.Header.Get("X-Token")) },
+	}
+	c.Parser = parsers
+
+	rules := &colibri.Rules{
+		URL: mustNewURL("https://example.com/login"),
+		Selectors: []*colibri.Selector{
+			colibri.NewSelector("items").XPath("//a/@href").Follow().
+				Field(colibri.KeyHeader, http.Header{"X-Token": {"{{token}}"}}).
+				Child(colibri.NewSelector("title").XPath("//h1")).
+				Build(),
+			colibri.NewSelector("token").XPath("//input[@name='csrf']/@value").Var("token").Build(),
+		},
+		Vars: colibri.NewVars(map[string]string{"token": ""}),
+	}
+
+	_, output, err := c.Extract(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"token": "T1",
+		"items": map[string]any{"https://example.com/items?token={{token}}": map[string]any{"title": "Items"}},
+	}
+	if !reflect.DeepEqual(output, want) {
+		t.Fatalf("got %v, want %v", output, want)
+	}
+
+	if want := []string{"", "T1"}; !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("got %v, want %v", tokens, want)
+	}
+
+	rules.Vars = nil
+	_, _, err = c.Extract(rules)
+	if errs, _ := err.(*colibri.Errs); errs == nil {
+		t.Fatalf("got %v, want %v", err, colibri.ErrUndeclaredVar)
+	} else if err, _ := errs.Get("token"); !errors.Is(err, colibri.ErrUndeclaredVar) {
+		t.Fatalf("got %v, want %v", err, colibri.ErrUndeclaredVar)
+	}
+}
+
+func TestSkipIf(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requested []string
+	c := colibri.New()
+	c.Client = &testRecordClient{
+		testPagesClient: testPagesClient{
+			"https://example.com/products": {
+				http.Header{"Content-Type": {"text/html"}},
+				`<html><body>
+					<div class="product"><span class="stock">in</span><a href="/p/1">One</a></div>
+					<div class="product"><span class="stock">out</span><a href="/p/2">Two</a></div>
+					<div class="product"><a href="/p/3">Three</a></div>
+				</body></html>`,
+			},
+			"https://example.com/p/1": {http.Header{"Content-Type": {"text/html"}}, `<html><body><h1>One</h1></body></html>`},
+			"https://example.com/p/2": {http.Header{"Content-Type": {"text/html"}}, `<html><body><h1>Two</h1></body></html>`},
+			"https://example.com/p/3": {http.Header{"Content-Type": {"text/html"}}, `<html><body><h1>Three</h1></body></html>`},
+		},
+		record: func(rules *colibri.Rules) { requested = append(requested, rules.URL.String()) },
+	}
+	c.Parser = parsers
+
+	rules := &colibri.Rules{
+		URL: mustNewURL("https://example.com/products"),
+		Selectors: []*colibri.Selector{
+			colibri.NewSelector("products").XPath("//div[@class='product']").All().
+				Child(colibri.NewSelector("detail").XPath("./a/@href").Follow().
+					SkipIf("stock", []any{"out", nil}).
+					Child(colibri.NewSelector("title").XPath("//h1"))).
+				Child(colibri.NewSelector("stock").XPath("./span[@class='stock']")).
+				Build(),
+		},
+	}
+
+	_, output, err := c.Extract(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"products": []any{
+			map[string]any{"stock": "in", "detail": map[string]any{"https://example.com/p/1": map[string]any{"title": "One"}}},
+			map[string]any{"stock": "out", "detail": nil},
+			map[string]any{"stock": nil, "detail": nil},
+		},
+	}
+	if !reflect.DeepEqual(output, want) {
+		t.Fatalf("got %v, want %v", output, want)
+	}
+
+	if want := []string{"https://example.com/products", "https://example.com/p/1"}; !reflect.DeepEqual(requested, want) {
+		t.Fatalf("got %v, want %v", requested, want)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `<html><body><h1>Mug</h1><div class="item"><span>9.50</span></div><div class="item"></div></body></html>`
+	rules := &colibri.Rules{
+		URL: mustNewURL("https://example.com"),
+		Selectors: []*colibri.Selector{
+			colibri.NewSelector("title").XPath("//h1").Required().Build(),
+			colibri.NewSelector("sku").XPath("//span[@class='sku']").Required().Build(),
+			colibri.NewSelector("tags").XPath("//li").All().Required().Build(),
+			colibri.NewSelector("items").XPath("//div[@class='item']").All().
+				Child(colibri.NewSelector("price").XPath("./span").Required()).
+				Build(),
+		},
+	}
+
+	resp := &testResp{u: rules.URL, header: http.Header{"Content-Type": {"text/html"}}, body: io.NopCloser(strings.NewReader(body))}
+	output, err := parsers.Parse(rules, resp)
+
+	want := map[string]any{"title": "Mug"}
+	if !reflect.DeepEqual(output, want) {
+		t.Fatalf("got %v, want %v", output, want)
+	}
+
+	errs, ok := err.(*colibri.Errs)
+	if !ok {
+		t.Fatalf("got %v, want %v", err, ErrRequired)
+	}
+
+	wantErrs := map[string]string{
+		"sku":  ErrRequired.Error(),
+		"tags": ErrRequired.Error(),
+		"items" + colibri.PathSeparator + "items#1" + colibri.PathSeparator + "price": ErrRequired.Error(),
+	}
+	if got := errs.Flatten(); !reflect.DeepEqual(got, wantErrs) {
+		t.Fatalf("got %v, want %v", got, wantErrs)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	body := "{\"name\": \"a\", \"n\": 1}\n\n{\"name\": \"b\", \"n\": 2}\r\n"
+	resp := &testResp{header: http.Header{}, body: io.NopCloser(strings.NewReader(body))}
+
+	root, err := ParseNDJSON(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Expr string
+		Want []any
+	}{
+		{"/*/name", []any{"a", "b"}},
+		{"/*[2]/n", []any{float64(2)}},
+	}
+
+	for _, tt := range tests {
+		elements, err := root.FindAll(tt.Expr, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []any
+		for _, element := range elements {
+			got = append(got, element.Value())
+		}
+
+		if !reflect.DeepEqual(got, tt.Want) {
+			t.Fatalf("got %v, want %v", got, tt.Want)
+		}
+	}
+
+	resp = &testResp{header: http.Header{}, body: io.NopCloser(strings.NewReader("{}\n{\"name\":"))}
+	if _, err := ParseNDJSON(resp); !errors.Is(err, ErrInvalidNDJSON) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidNDJSON)
+	}
+}
+
+func TestProto(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsers.SetProtoMessage(&ProtoMessage{
+		Name: "shop.Product",
+		Fields: []ProtoField{
+			{Name: "name", Number: 1, Type: "string"},
+			{Name: "price", Number: 2, Type: "double"},
+			{Name: "stock", Number: 3, Type: "int64"},
+			{Name: "tags", Number: 4, Type: "string", Repeated: true},
+			{Name: "sizes", Number: 5, Type: "sint32", Repeated: true},
+			{Name: "seller", Number: 6, Type: "message", Message: &ProtoMessage{
+				Fields: []ProtoField{{Name: "id", Number: 1, Type: "uint32"}},
+			}},
+		},
+	})
+
+	field := func(b []byte, number, wireType int) []byte {
+		return binary.AppendUvarint(b, uint64(number<<3|wireType))
+	}
+	bytesField := func(b []byte, number int, value []byte) []byte {
+		b = field(b, number, 2)
+		b = binary.AppendUvarint(b, uint64(len(value)))
+		return append(b, value...)
+	}
+
+	var msg []byte
+	msg = bytesField(msg, 1, []byte("Mug"))
+	msg = binary.LittleEndian.AppendUint64(field(msg, 2, 1), math.Float64bits(9.5))
+	msg = binary.AppendUvarint(field(msg, 3, 0), 1<<40)
+	msg = bytesField(msg, 4, []byte("kitchen"))
+	msg = bytesField(msg, 4, []byte("gift"))
+	msg = bytesField(msg, 5, []byte{2, 3, 4}) // packed: 1, -2, 2
+	msg = bytesField(msg, 6, binary.AppendUvarint(field(nil, 1, 0), 42))
+	msg = binary.AppendUvarint(field(msg, 7, 0), 1) // not described
+
+	tests := []struct {
+		ContentType string
+		Expr        string
+		Want        []any
+	}{
+		{"application/x-protobuf; messageType=shop.Product", "/name", []any{"Mug"}},
+		{"application/x-protobuf; messageType=shop.Product", "/price", []any{9.5}},
+		{"application/x-protobuf; messageType=shop.Product", "/stock", []any{"1099511627776"}},
+		{"application/x-protobuf; messageType=shop.Product", "/tags/*", []any{"kitchen", "gift"}},
+		{"application/x-protobuf; messageType=shop.Product", "/sizes/*", []any{float64(1), float64(-2), float64(2)}},
+		{"application/protobuf; proto=shop.Product", "/seller/id", []any{float64(42)}},
+		{"application/protobuf; proto=shop.Product", "/*[7]", nil},
+		{"application/x-protobuf", "/*[1]", []any{"Mug"}},
+		{"application/x-protobuf", "/*[6]/*[1]", []any{float64(42)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ContentType+tt.Expr, func(t *testing.T) {
+			resp := &testResp{header: http.Header{"Content-Type": {tt.ContentType}}, body: io.NopCloser(bytes.NewReader(msg))}
+
+			root, err := parsers.ParseProto(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			elements, err := root.FindAll(tt.Expr, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []any
+			for _, element := range elements {
+				got = append(got, element.Value())
+			}
+
+			if !reflect.DeepEqual(got, tt.Want) {
+				t.Fatalf("got %v, want %v", got, tt.Want)
+			}
+		})
+	}
+
+	resp := &testResp{header: http.Header{}, body: io.NopCloser(bytes.NewReader(msg[:len(msg)-1]))}
+	if _, err := parsers.ParseProto(resp); !errors.Is(err, ErrInvalidProto) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidProto)
+	}
+}
+
+func TestPipes(t *testing.T) {
+	body := `<html><body>
+		<span id="price"> 1,234.50 USD </span>
+		<span id="n"> 42 </span>
+		<time>2024-01-02T03:04:05Z</time>
+	</body></html>`
+
+	resp := &testResp{header: http.Header{}, body: io.NopCloser(strings.NewReader(body))}
+	root, err := ParseHTML(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name     string
+		Selector *colibri.Selector
+		Want     any
+		WantErr  error
+	}{
+		{
+			"Trim",
+			colibri.NewSelector("n").XPath("//span[@id='n']").Pipe("trim").Build(),
+			"42",
+			nil,
+		},
+		{
+			"ToInt",
+			colibri.NewSelector("n").XPath("//span[@id='n']").Pipe("toInt").Build(),
+			42,
+			nil,
+		},
+		{
+			"Price",
+			colibri.NewSelector("price").XPath("//span[@id='price']").
+				Pipe("replace", ",", "").
+				Pipe("regexp", `([\d.]+)`).
+				Pipe("toFloat").
+				Build(),
+			1234.5,
+			nil,
+		},
+		{
+			"Currency",
+			colibri.NewSelector("currency").XPath("//span[@id='price']").Pipe("regexp", `[A-Z]+`).Pipe("lower").Build(),
+			"usd",
+			nil,
+		},
+		{
+			"ToDate",
+			colibri.NewSelector("date").XPath("//time").Pipe("toDate").Build(),
+			time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			nil,
+		},
+		{
+			"NotMatch",
+			colibri.NewSelector("n").XPath("//span[@id='n']").Pipe("regexp", `[a-z]+`).Pipe("toInt").Build(),
+			nil,
+			nil,
+		},
+		{
+			"All",
+			colibri.NewSelector("spans").XPath("//span").All().Pipe("trim").Pipe("upper").Build(),
+			[]any{"1,234.50 USD", "42"},
+			nil,
+		},
+		{
+			"NotFound",
+			colibri.NewSelector("n").XPath("//span[@id='n']").Pipe("unknown").Build(),
+			nil,
+			ErrPipeNotFound,
+		},
+		{
+			"Args",
+			colibri.NewSelector("n").XPath("//span[@id='n']").Pipe("replace", ",").Build(),
+			nil,
+			ErrPipeArgs,
+		},
+		{
+			"Conv",
+			colibri.NewSelector("price").XPath("//span[@id='price']").Pipe("toInt").Build(),
+			nil,
+			strconv.ErrSyntax,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, err := findSelector(&colibri.Rules{}, resp, tt.Selector, root, nil, nil)
+			if !errors.Is(err, tt.WantErr) {
+				t.Fatalf("got %v, want %v", err, tt.WantErr)
+			}
+
+			if (tt.WantErr == nil) && !reflect.DeepEqual(got, tt.Want) {
+				t.Fatalf("got %v, want %v", got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := colibri.NewRulesBuilder().
+		WithURL("https://example.com").
+		WithSelector(colibri.NewSelector("price").XPath("//span[@id='price']")).
+		WithVariant("mobile",
+			colibri.NewSelector("").XPath("//div[@class='m-layout']"),
+			colibri.NewSelector("price").XPath("//div[@class='m-price']"),
+		).
+		WithVariant("beta",
+			colibri.NewSelector("").CSS("#beta"),
+			colibri.NewSelector("price").CSS("#beta .price"),
+		).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name string
+		Body string
+		Want any
+	}{
+		{"Default", `<html><body><span id="price">10</span></body></html>`, "10"},
+		{"Mobile", `<html><body><div class="m-layout"><div class="m-price">20</div></div></body></html>`, "20"},
+		{"Beta", `<html><body><div id="beta"><p class="price">30</p></div></body></html>`, "30"},
+		{"First", `<html><body><div class="m-layout" id="beta"><div class="m-price">40</div><p class="price">50</p></div></body></html>`, "40"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			rules.Fields["Content-Type"] = "text/html"
+			rules.Fields["Body"] = tt.Body
+
+			output, err := parsers.Parse(rules, newTestResponse(nil, rules))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if output["price"] != tt.Want {
+				t.Fatalf("got %v, want %v", output["price"], tt.Want)
+			}
+		})
+	}
+
+	t.Run("DetectErr", func(t *testing.T) {
+		rules := rules.Clone()
+		rules.Variants[0].Detect.Expr = "//div["
+		rules.Fields["Content-Type"] = "text/html"
+		rules.Fields["Body"] = tests[2].Body
+
+		output, err := parsers.Parse(rules, newTestResponse(nil, rules))
+		if output["price"] != "30" {
+			t.Fatalf("got %v, want %v", output["price"], "30")
+		}
+
+		variantsErr, _ := err.(*colibri.Errs).Get(colibri.KeyVariants)
+		if _, ok := variantsErr.(*colibri.Errs).Get("mobile"); !ok {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+}
+
+/* Benchmark */
+func BenchmarkParsersParse(b *testing.B) {
+	parsers, err := New()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	rules := &colibri.Rules{
+		Selectors: []*colibri.Selector{
+			{Name: "title", Expr: "//title"},
+			{Name: "a", Expr: "//a/@href", All: true},
+		},
+		Fields: map[string]any{
+			"Content-Type": "text/html",
+			"Body":         htmlBody,
+		},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := parsers.Parse(rules, newTestResponse(nil, rules)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParsersParseParallel(b *testing.B) {
+	parsers, err := New()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	rules := &colibri.Rules{
+		Selectors: []*colibri.Selector{
+			{Name: "title", Expr: "//title"},
+			{Name: "a", Expr: "//a/@href", All: true},
+		},
+		Fields: map[string]any{
+			"Content-Type": "text/html",
+			"Body":         htmlBody,
+		},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := parsers.Parse(rules, newTestResponse(nil, rules)); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkParsersMatchParallel(b *testing.B) {
+	parsers, err := New()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			parsers.Match("application/xml")
+		}
+	})
+}
+
 const (
 	htmlBody = `<!doctype html>
 	<html>
@@ -684,3 +2658,174 @@ func (client *testClient) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.
 }
 
 func (client *testClient) Clear() {}
+
+type testCountClient struct {
+	testClient
+	N int
+}
+
+func (client *testCountClient) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	client.N++
+	return client.testClient.Do(c, rules)
+}
+
+// testTimeoutClient returns responses whose Extract returns colibri.ErrParseTimeout.
+type testTimeoutClient struct {
+	testPagesClient
+	extract func(*colibri.Rules)
+}
+
+func (client *testTimeoutClient) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	resp, err := client.testPagesClient.Do(c, rules)
+	if err != nil {
+		return nil, err
+	}
+	return &testTimeoutResp{testResp: resp.(*testResp), extract: client.extract}, nil
+}
+
+// testTimeoutResp is a response whose Extract returns colibri.ErrParseTimeout.
+type testTimeoutResp struct {
+	*testResp
+	extract func(*colibri.Rules)
+}
+
+func (r *testTimeoutResp) Extract(rules *colibri.Rules) (colibri.Response, map[string]any, error) {
+	r.extract(rules)
+	return nil, nil, colibri.ErrParseTimeout
+}
+
+type testSlowClient struct {
+	testPagesClient
+	delay time.Duration
+
+	mu          sync.Mutex
+	active, max int
+}
+
+func (client *testSlowClient) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	client.mu.Lock()
+	client.active++
+	client.max = max(client.max, client.active)
+	client.mu.Unlock()
+
+	time.Sleep(client.delay)
+
+	client.mu.Lock()
+	client.active--
+	client.mu.Unlock()
+	return client.testPagesClient.Do(c, rules)
+}
+
+type testPagesClient map[string]struct {
+	header http.Header
+	body   string
+}
+
+func (client testPagesClient) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	page, ok := client[rules.URL.String()]
+	if !ok {
+		return nil, errors.New("Not Found")
+	}
+	return &testResp{u: rules.URL, header: page.header, body: io.NopCloser(strings.NewReader(page.body)), c: c}, nil
+}
+
+func (client testPagesClient) Clear() {}
+
+type testRecordClient struct {
+	testPagesClient
+	record func(*colibri.Rules)
+}
+
+func (client *testRecordClient) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	client.record(rules)
+	return client.testPagesClient.Do(c, rules)
+}
+
+func mustNewURL(rawURL string) *url.URL {
+	u, _ := url.Parse(rawURL)
+	return u
+}
+
+func TestCompile(t *testing.T) {
+	parsers, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := colibri.New()
+	c.Parser = parsers
+
+	compiled, err := c.Compile(colibri.RawRules{
+		"Method": "get",
+		"URL":    "https://example.com",
+		"Selectors": map[string]any{
+			"title": "//h1",
+			"price": map[string]any{"Expr": "div.item span", "Type": "css", "Pipes": []any{"trim", "toFloat"}},
+			"code":  map[string]any{"Expr": `#(\d+)`, "Type": "regular"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []exprKey{{XPathExpr, "//h1"}, {CSSSelector, "div.item span"}, {RegularExpr, `#(\d+)`}} {
+		if !isPrecompiled(key.exprType, key.expr) {
+			t.Fatalf("got %v, want precompiled", key)
+		}
+	}
+
+	body := `<html><body><h1>Mug</h1><div class="item"><span> 9.50 </span></div></body></html>`
+	for i := 0; i < 2; i++ {
+		rules := compiled.Rules()
+		if rules.Method != "GET" {
+			t.Fatalf("got %v, want %v", rules.Method, "GET")
+		}
+
+		// the regular expressions are not compatible with HTMLElement
+		rules.Selectors = slices.DeleteFunc(rules.Selectors, func(selector *colibri.Selector) bool {
+			return selector.Name == "code"
+		})
+
+		resp := &testResp{u: rules.URL, header: http.Header{"Content-Type": {"text/html"}}, body: io.NopCloser(strings.NewReader(body))}
+		output, err := parsers.Parse(rules, resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]any{"title": "Mug", "price": 9.5}
+		if !reflect.DeepEqual(output, want) {
+			t.Fatalf("got %v, want %v", output, want)
+		}
+	}
+
+	_, err = c.Compile(colibri.RawRules{
+		"URL": "https://example.com",
+		"Selectors": map[string]any{
+			"title": map[string]any{"Expr": "div[", "Type": "css"},
+			"items": map[string]any{"Expr": "//li", "Selectors": map[string]any{
+				"name": map[string]any{"Expr": "$.[", "Type": "jsonpath"},
+			}},
+			"price": map[string]any{"Expr": "//span", "Pipes": []any{"unknown"}},
+		},
+	})
+
+	errs, ok := err.(*colibri.Errs)
+	if !ok {
+		t.Fatalf("got %v, want *colibri.Errs", err)
+	}
+
+	var got []string
+	for key := range errs.Flatten() {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+
+	want := []string{
+		colibri.KeySelectors + colibri.PathSeparator + "items" + colibri.PathSeparator + "name",
+		colibri.KeySelectors + colibri.PathSeparator + "price",
+		colibri.KeySelectors + colibri.PathSeparator + "title",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+var (
+	// ErrPipeNotFound is returned when the pipe is not registered.
+	ErrPipeNotFound = errors.New("pipe not found")
+
+	// ErrPipeArgs is returned when the number of arguments of the pipe is invalid.
+	ErrPipeArgs = errors.New("invalid number of pipe arguments")
+)
+
+// Transform transforms a value found by a selector.
+type Transform func(value any) (any, error)
+
+// PipeFunc returns the Transform of a pipe with the arguments,
+// it is called once each time the selector is evaluated.
+type PipeFunc func(args ...string) (Transform, error)
+
+var pipeFuncs = struct {
+	rw   sync.RWMutex
+	data map[string]PipeFunc
+}{data: map[string]PipeFunc{
+	"trim":    pipeTrim,
+	"lower":   pipeString(strings.ToLower),
+	"upper":   pipeString(strings.ToUpper),
+	"replace": pipeReplace,
+	"regexp":  pipeRegexp,
+	"toInt":   pipeToInt,
+	"toFloat": pipeToFloat,
+	"toDate":  pipeToDate,
+}}
+
+// RegisterPipe registers the PipeFunc with the name, replaces the PipeFunc if it already exists.
+//
+// The registered pipes are:
+//   - trim [cutset]: removes the leading and trailing white space or the characters of the cutset.
+//   - lower, upper: converts the string to lower or upper case.
+//   - replace old new: replaces all the occurrences of old with new.
+//   - regexp expr [group]: returns the first match or the submatch of the group, nil if it does not match.
+//   - toInt, toFloat: converts the string to int or float64.
+//   - toDate [layout]: parses the string as a time.Time with the layout, time.RFC3339 by default.
+func RegisterPipe(name string, pipeFunc PipeFunc) {
+	pipeFuncs.rw.Lock()
+	pipeFuncs.data[name] = pipeFunc
+	pipeFuncs.rw.Unlock()
+}
+
+// UnregisterPipe removes the PipeFunc with the name.
+func UnregisterPipe(name string) {
+	pipeFuncs.rw.Lock()
+	delete(pipeFuncs.data, name)
+	pipeFuncs.rw.Unlock()
+}
+
+// pipeline returns the Transform that applies the pipes in order, nil if there are no pipes.
+// The nil values are not transformed.
+func pipeline(pipes []colibri.Pipe) (Transform, error) {
+	if len(pipes) == 0 {
+		return nil, nil
+	}
+
+	transforms := make([]Transform, 0, len(pipes))
+	for _, pipe := range pipes {
+		pipeFuncs.rw.RLock()
+		pipeFunc, ok := pipeFuncs.data[pipe.Name]
+		pipeFuncs.rw.RUnlock()
+
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrPipeNotFound, pipe.Name)
+		}
+
+		transform, err := pipeFunc(pipe.Args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pipe.Name, err)
+		}
+		transforms = append(transforms, transform)
+	}
+
+	return func(value any) (any, error) {
+		for i, transform := range transforms {
+			if value == nil {
+				return nil, nil
+			}
+
+			var err error
+			value, err = transform(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", pipes[i].Name, err)
+			}
+		}
+		return value, nil
+	}, nil
+}
+
+// pipeValue returns the value as a string.
+func pipeValue(value any) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+	return fmt.Sprint(value)
+}
+
+func pipeString(fn func(string) string) PipeFunc {
+	return func(args ...string) (Transform, error) {
+		if len(args) != 0 {
+			return nil, ErrPipeArgs
+		}
+
+		return func(value any) (any, error) {
+			return fn(pipeValue(value)), nil
+		}, nil
+	}
+}
+
+func pipeTrim(args ...string) (Transform, error) {
+	switch len(args) {
+	case 0:
+		return pipeString(strings.TrimSpace)()
+
+	case 1:
+		return pipeString(func(s string) string { return strings.Trim(s, args[0]) })()
+	}
+	return nil, ErrPipeArgs
+}
+
+func pipeReplace(args ...string) (Transform, error) {
+	if len(args) != 2 {
+		return nil, ErrPipeArgs
+	}
+	return pipeString(func(s string) string { return strings.ReplaceAll(s, args[0], args[1]) })()
+}
+
+func pipeRegexp(args ...string) (Transform, error) {
+	if (len(args) < 1) || (len(args) > 2) {
+		return nil, ErrPipeArgs
+	}
+
+	re, err := compileRegexp(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	group := 0
+	if len(args) == 2 {
+		group, err = strconv.Atoi(args[1])
+		if err != nil {
+			return nil, err
+		}
+	} else if re.NumSubexp() > 0 {
+		group = 1
+	}
+
+	if (group < 0) || (group > re.NumSubexp()) {
+		return nil, fmt.Errorf("group %d out of range", group)
+	}
+
+	return func(value any) (any, error) {
+		match := re.FindStringSubmatch(pipeValue(value))
+		if match == nil {
+			return nil, nil
+		}
+		return match[group], nil
+	}, nil
+}
+
+func pipeToInt(args ...string) (Transform, error) {
+	if len(args) != 0 {
+		return nil, ErrPipeArgs
+	}
+
+	return func(value any) (any, error) {
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case float64:
+			return int(v), nil
+		}
+		return strconv.Atoi(strings.TrimSpace(pipeValue(value)))
+	}, nil
+}
+
+func pipeToFloat(args ...string) (Transform, error) {
+	if len(args) != 0 {
+		return nil, ErrPipeArgs
+	}
+
+	return func(value any) (any, error) {
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+		return strconv.ParseFloat(strings.TrimSpace(pipeValue(value)), 64)
+	}, nil
+}
+
+func pipeToDate(args ...string) (Transform, error) {
+	layout := time.RFC3339
+	switch len(args) {
+	case 0:
+	case 1:
+		layout = args[0]
+	default:
+		return nil, ErrPipeArgs
+	}
+
+	return func(value any) (any, error) {
+		if t, ok := value.(time.Time); ok {
+			return t, nil
+		}
+		return time.Parse(layout, strings.TrimSpace(pipeValue(value)))
+	}, nil
+}
@@ -0,0 +1,389 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"mime"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/eduardogxnzalez/colibri"
+
+	"github.com/antchfx/jsonquery"
+)
+
+// ProtoRegexp contains a regular expression that matches the MIME types of protocol buffers.
+const ProtoRegexp = `(?i)^application\/(x-)?(protobuf|proto)`
+
+// Wire types of the protocol buffers encoding.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+var (
+	// ErrInvalidProto is returned when the content is not a valid protocol buffers message.
+	ErrInvalidProto = errors.New("invalid protocol buffers message")
+
+	// ErrInvalidProtoType is returned when the type of a field is not a protocol buffers type.
+	ErrInvalidProtoType = errors.New("invalid protocol buffers type")
+)
+
+// ProtoMessage describes a protocol buffers message, used to decode the responses.
+type ProtoMessage struct {
+	// Name full name of the message, e.g. "shop.v1.Product".
+	Name string
+
+	Fields []ProtoField
+}
+
+// ProtoField describes a field of a protocol buffers message.
+type ProtoField struct {
+	// Name of the field in the decoded message.
+	Name string
+
+	// Number of the field.
+	Number int
+
+	// Type of the field: "double", "float", "int32", "int64", "uint32", "uint64", "sint32", "sint64",
+	// "fixed32", "fixed64", "sfixed32", "sfixed64", "bool", "enum", "string", "bytes" or "message".
+	Type string
+
+	// Repeated specifies whether the field is a list, the packed lists are supported.
+	Repeated bool
+
+	// Message describes the message of the fields of type "message".
+	Message *ProtoMessage
+}
+
+// field returns the field with the number.
+func (message *ProtoMessage) field(number int) (*ProtoField, bool) {
+	for i := range message.Fields {
+		if message.Fields[i].Number == number {
+			return &message.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// SetProtoMessage registers the description of the protocol buffers message used by ParseProto
+// to decode the responses with the name of the message in the "messageType" or "proto"
+// parameter of the Content-Type, e.g. "application/x-protobuf; messageType=shop.v1.Product".
+func (parsers *Parsers) SetProtoMessage(message *ProtoMessage) {
+	if message == nil {
+		return
+	}
+
+	parsers.rw.Lock()
+	if parsers.protoMessages == nil {
+		parsers.protoMessages = make(map[string]*ProtoMessage)
+	}
+	parsers.protoMessages[message.Name] = message
+	parsers.rw.Unlock()
+}
+
+// ParseProto decodes the protocol buffers message of the response and returns the root element,
+// compatible with the XPath expressions of JSON.
+// The message is decoded with the ProtoMessage registered with the name of the Content-Type,
+// see SetProtoMessage, the values follow the JSON mapping of protocol buffers: the 64-bit integers
+// are strings and the bytes are encoded in base64. The fields not described are skipped.
+// If there is no ProtoMessage, the names of the fields are their numbers and the types are inferred
+// from the encoding: the varints are numbers and the length-delimited values are strings if they are
+// printable UTF-8, otherwise messages if they can be decoded, otherwise bytes.
+func (parsers *Parsers) ParseProto(resp colibri.Response) (*JSONElement, error) {
+	data, err := io.ReadAll(resp.Body())
+	if err != nil {
+		return nil, err
+	}
+
+	var message *ProtoMessage
+	if _, params, err := mime.ParseMediaType(resp.Header().Get("Content-Type")); err == nil {
+		name := params["messagetype"]
+		if name == "" {
+			name = params["proto"]
+		}
+
+		parsers.rw.RLock()
+		message = parsers.protoMessages[name]
+		parsers.rw.RUnlock()
+	}
+
+	value, err := decodeProto(data, message)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := jsonquery.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return &JSONElement{root}, nil
+}
+
+// protoReader reads the protocol buffers encoding.
+type protoReader struct {
+	data []byte
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return 0, ErrInvalidProto
+	}
+	r.data = r.data[n:]
+	return v, nil
+}
+
+func (r *protoReader) fixed(size int) (uint64, error) {
+	if len(r.data) < size {
+		return 0, ErrInvalidProto
+	}
+
+	var v uint64
+	if size == 4 {
+		v = uint64(binary.LittleEndian.Uint32(r.data))
+	} else {
+		v = binary.LittleEndian.Uint64(r.data)
+	}
+	r.data = r.data[size:]
+	return v, nil
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	size, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+
+	if size > uint64(len(r.data)) {
+		return nil, ErrInvalidProto
+	}
+
+	b := r.data[:size]
+	r.data = r.data[size:]
+	return b, nil
+}
+
+// protoValue is a raw value of a field.
+type protoValue struct {
+	wireType int
+	n        uint64
+	b        []byte
+}
+
+// decodeProto decodes the message, if message is nil the names and the types of the fields are inferred.
+func decodeProto(data []byte, message *ProtoMessage) (map[string]any, error) {
+	var (
+		r      = &protoReader{data}
+		result = make(map[string]any)
+	)
+	for len(r.data) > 0 {
+		key, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			number = int(key >> 3)
+			value  = protoValue{wireType: int(key & 7)}
+		)
+		if number <= 0 {
+			return nil, ErrInvalidProto
+		}
+
+		switch value.wireType {
+		case protoVarint:
+			value.n, err = r.varint()
+		case protoFixed64:
+			value.n, err = r.fixed(8)
+		case protoFixed32:
+			value.n, err = r.fixed(4)
+		case protoBytes:
+			value.b, err = r.bytes()
+		default:
+			err = ErrInvalidProto
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if message == nil {
+			addProtoValue(result, strconv.Itoa(number), inferProtoValue(value), false)
+			continue
+		}
+
+		field, ok := message.field(number)
+		if !ok {
+			continue
+		}
+
+		if err := decodeProtoField(result, field, value); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// decodeProtoField adds the value of the field to the result, the packed lists are unpacked.
+func decodeProtoField(result map[string]any, field *ProtoField, value protoValue) error {
+	wireType, ok := protoWireType(field.Type)
+	if !ok {
+		return ErrInvalidProtoType
+	}
+
+	// the last value of the fields that are not repeated is kept
+	set := func(v any) {
+		if field.Repeated {
+			addProtoValue(result, field.Name, v, true)
+			return
+		}
+		result[field.Name] = v
+	}
+
+	if (value.wireType == protoBytes) && (wireType != protoBytes) {
+		// packed list
+		r := &protoReader{value.b}
+		for len(r.data) > 0 {
+			item := protoValue{wireType: wireType}
+
+			var err error
+			switch wireType {
+			case protoVarint:
+				item.n, err = r.varint()
+			case protoFixed64:
+				item.n, err = r.fixed(8)
+			case protoFixed32:
+				item.n, err = r.fixed(4)
+			}
+
+			if err != nil {
+				return err
+			}
+			set(protoFieldValue(field, item))
+		}
+		return nil
+	}
+
+	if value.wireType != wireType {
+		return ErrInvalidProto
+	}
+
+	if field.Type == "message" {
+		nested, err := decodeProto(value.b, field.Message)
+		if err != nil {
+			return err
+		}
+		set(nested)
+		return nil
+	}
+
+	set(protoFieldValue(field, value))
+	return nil
+}
+
+// protoWireType returns the wire type of the protocol buffers type.
+func protoWireType(protoType string) (int, bool) {
+	switch protoType {
+	case "int32", "int64", "uint32", "uint64", "sint32", "sint64", "bool", "enum":
+		return protoVarint, true
+	case "fixed64", "sfixed64", "double":
+		return protoFixed64, true
+	case "fixed32", "sfixed32", "float":
+		return protoFixed32, true
+	case "string", "bytes", "message":
+		return protoBytes, true
+	}
+	return 0, false
+}
+
+// protoFieldValue converts the raw value to the value of the type of the field.
+func protoFieldValue(field *ProtoField, value protoValue) any {
+	switch field.Type {
+	case "int32", "enum":
+		return int32(value.n)
+	case "uint32", "fixed32":
+		return uint32(value.n)
+	case "sfixed32":
+		return int32(uint32(value.n))
+	case "sint32":
+		return int32(uint32(value.n)>>1) ^ -int32(value.n&1)
+	case "int64", "sfixed64":
+		return strconv.FormatInt(int64(value.n), 10)
+	case "uint64", "fixed64":
+		return strconv.FormatUint(value.n, 10)
+	case "sint64":
+		return strconv.FormatInt(int64(value.n>>1)^-int64(value.n&1), 10)
+	case "bool":
+		return value.n != 0
+	case "float":
+		return math.Float32frombits(uint32(value.n))
+	case "double":
+		return math.Float64frombits(value.n)
+	case "string":
+		return string(value.b)
+	}
+	return base64.StdEncoding.EncodeToString(value.b)
+}
+
+// inferProtoValue returns the value of a field without description.
+func inferProtoValue(value protoValue) any {
+	if value.wireType != protoBytes {
+		return value.n
+	}
+
+	if isProtoText(value.b) {
+		return string(value.b)
+	}
+
+	if nested, err := decodeProto(value.b, nil); err == nil {
+		return nested
+	}
+	return base64.StdEncoding.EncodeToString(value.b)
+}
+
+// isProtoText returns true if b is valid UTF-8 without control characters other than whitespace.
+func isProtoText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+
+	for _, r := range string(b) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// addProtoValue adds the value to the result, the repeated values are stored in a list.
+// If list is true, the first value is stored in a list.
+func addProtoValue(result map[string]any, name string, value any, list bool) {
+	current, ok := result[name]
+	if !ok {
+		if list {
+			value = []any{value}
+		}
+		result[name] = value
+		return
+	}
+
+	if values, ok := current.([]any); ok {
+		result[name] = append(values, value)
+		return
+	}
+	result[name] = []any{current, value}
+}
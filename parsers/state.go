@@ -0,0 +1,138 @@
+package parsers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// parseState stores the data shared by the selectors during a Parse call.
+type parseState struct {
+	hook       SelectorHook
+	followHook FollowHook
+
+	// maxFollowDepth maximum number of nested follows, negative means no limit.
+	maxFollowDepth int
+
+	// branch URLs of the pages from the seed URL to the parent of the parsed page.
+	branch []string
+
+	mu      sync.Mutex
+	follows map[string]*followResult
+}
+
+type followResult struct {
+	wait  chan struct{}
+	found map[string]any
+	err   error
+}
+
+// follow returns the result stored with the key, if there is no result
+// stored, extractFunc is called once and its result is stored.
+// Calls with the same key wait for the first call to finish and share its result.
+func (state *parseState) follow(key string, extractFunc func() (map[string]any, error)) (map[string]any, error) {
+	if state == nil {
+		return extractFunc()
+	}
+
+	state.mu.Lock()
+	if state.follows == nil {
+		state.follows = make(map[string]*followResult)
+	}
+
+	if r, ok := state.follows[key]; ok {
+		state.mu.Unlock()
+		<-r.wait
+		return r.found, r.err
+	}
+
+	r := &followResult{wait: make(chan struct{})}
+	state.follows[key] = r
+	state.mu.Unlock()
+
+	r.found, r.err = extractFunc()
+	close(r.wait)
+	return r.found, r.err
+}
+
+// skipFollow returns the reason to skip the follow of the URL found
+// in the page with the URL current, or an empty string.
+func (state *parseState) skipFollow(current, u string) string {
+	if state == nil {
+		return ""
+	}
+
+	if (u == current) || slices.Contains(state.branch, u) {
+		return SkippedCycle
+	}
+
+	if (state.maxFollowDepth > 0) && (len(state.branch)+1 > state.maxFollowDepth) {
+		return SkippedDepth
+	}
+	return ""
+}
+
+// childBranch returns the branch of the pages followed from the page with the URL current.
+func (state *parseState) childBranch(current string) []string {
+	if state == nil {
+		return []string{current}
+	}
+	return append(slices.Clip(state.branch), current)
+}
+
+// branchKey returns the URL used to compare the pages of a branch and to deduplicate
+// the follows, without the fragment and the trailing slash and with the host normalized.
+func branchKey(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	key := *u
+	key.Fragment, key.RawFragment = "", ""
+	key.Host = colibri.NormalizeHost(key.Host)
+	key.Path = strings.TrimSuffix(key.Path, "/")
+	key.RawPath = strings.TrimSuffix(key.RawPath, "/")
+	return key.String()
+}
+
+// selectorHash returns a hash of the nested selectors and
+// the fields of the selector used to follow the URLs.
+func selectorHash(selector *colibri.Selector) string {
+	h := fnv.New64a()
+	writeFields(h, selector.Fields)
+	writeSelectors(h, selector.Selectors)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+func writeSelectors(w io.Writer, selectors []*colibri.Selector) {
+	sorted := make([]*colibri.Selector, len(selectors))
+	copy(sorted, selectors)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	for _, sel := range sorted {
+		fmt.Fprintf(w, "{%q %q %q %t %t ", sel.Name, sel.Expr, sel.Type, sel.All, sel.Follow)
+		writeFields(w, sel.Fields)
+		writeSelectors(w, sel.Selectors)
+		io.WriteString(w, "}")
+	}
+}
+
+func writeFields(w io.Writer, fields map[string]any) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "%q:%v;", key, fields[key])
+	}
+}
@@ -1,9 +1,14 @@
 package parsers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"regexp"
+	"regexp/syntax"
 	"strings"
+	"time"
 
 	"github.com/eduardogxnzalez/colibri"
 )
@@ -11,18 +16,74 @@ import (
 // TextRegexp contains a regular expression that matches the MIME type plain text.
 const TextRegexp = `^text\/plain`
 
+const (
+	// DefaultMaxTextSize default maximum number of bytes of plain text parsed.
+	DefaultMaxTextSize = 10 << 20
+
+	// DefaultMaxRegexpLen default maximum length of the regular expressions.
+	DefaultMaxRegexpLen = 4096
+
+	// DefaultRegexpTimeout default maximum duration of each regular expression evaluation.
+	DefaultRegexpTimeout = 5 * time.Second
+)
+
+var (
+	// MaxTextSize maximum number of bytes of plain text parsed by ParseText.
+	// Zero or negative means no limit.
+	MaxTextSize int64 = DefaultMaxTextSize
+
+	// MaxRegexpLen maximum length of the regular expressions of the selectors.
+	// Zero or negative means no limit.
+	MaxRegexpLen = DefaultMaxRegexpLen
+
+	// RegexpTimeout maximum duration of each regular expression evaluation.
+	// Zero or negative means no limit.
+	RegexpTimeout = DefaultRegexpTimeout
+)
+
+var (
+	// ErrTextTooLarge is returned when the plain text exceeds MaxTextSize.
+	ErrTextTooLarge = errors.New("text exceeds the maximum size")
+
+	// ErrRegexpTooLong is returned when the regular expression exceeds MaxRegexpLen.
+	ErrRegexpTooLong = errors.New("regular expression exceeds the maximum length")
+
+	// ErrRegexpTimeout is returned when the evaluation of the regular expression exceeds RegexpTimeout.
+	ErrRegexpTimeout = errors.New("regular expression evaluation timed out")
+
+	// ErrRegexpSyntax is returned when the regular expression uses syntax not supported by RE2.
+	ErrRegexpSyntax = errors.New("regular expression syntax not supported by RE2, backreferences and lookarounds are not available")
+)
+
 // TextElement represents a Text element compatible with regular expressions.
 type TextElement struct {
 	data []byte
+	ctx  context.Context
 }
 
 // ParseText parses the content of the response and returns the root element.
+// Returns ErrTextTooLarge if the content exceeds MaxTextSize.
+// If the response has a Context method, the context cancels the evaluation of the regular expressions.
 func ParseText(resp colibri.Response) (*TextElement, error) {
-	b, err := io.ReadAll(resp.Body())
+	var r io.Reader = resp.Body()
+	if MaxTextSize > 0 {
+		r = io.LimitReader(r, MaxTextSize+1)
+	}
+
+	b, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	return &TextElement{b}, nil
+
+	if (MaxTextSize > 0) && (int64(len(b)) > MaxTextSize) {
+		return nil, ErrTextTooLarge
+	}
+
+	ctx := context.Background()
+	if r, ok := resp.(interface{ Context() context.Context }); ok && (r.Context() != nil) {
+		ctx = r.Context()
+	}
+	return &TextElement{b, ctx}, nil
 }
 
 func (text *TextElement) Find(expr, exprType string) (Element, error) {
@@ -30,13 +91,19 @@ func (text *TextElement) Find(expr, exprType string) (Element, error) {
 		return nil, ErrExprType
 	}
 
-	re, err := regexp.Compile(expr)
+	re, err := compileRegexp(expr)
 	if err != nil {
 		return nil, err
 	}
 
-	data := re.Find(text.data)
-	return &TextElement{data}, nil
+	var data []byte
+	err = text.run(func() {
+		data = re.Find(text.data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TextElement{data, text.ctx}, nil
 }
 
 func (text *TextElement) FindAll(expr, exprType string) ([]Element, error) {
@@ -44,14 +111,22 @@ func (text *TextElement) FindAll(expr, exprType string) ([]Element, error) {
 		return nil, ErrExprType
 	}
 
-	re, err := regexp.Compile(expr)
+	re, err := compileRegexp(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var found [][]byte
+	err = text.run(func() {
+		found = re.FindAll(text.data, -1)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	var elements []Element
-	for _, data := range re.FindAll(text.data, -1) {
-		elements = append(elements, &TextElement{data})
+	for _, data := range found {
+		elements = append(elements, &TextElement{data, text.ctx})
 	}
 	return elements, nil
 }
@@ -59,3 +134,83 @@ func (text *TextElement) FindAll(expr, exprType string) ([]Element, error) {
 func (text *TextElement) Value() any {
 	return string(text.data)
 }
+
+// run calls fn and waits until it returns, RegexpTimeout is exceeded or the context is cancelled.
+// The evaluation of RE2 regular expressions runs in linear time and the text is limited by
+// MaxTextSize, so fn always finishes, even if run stops waiting for it.
+func (text *TextElement) run(fn func()) error {
+	ctx := text.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if (RegexpTimeout <= 0) && (ctx.Done() == nil) {
+		fn()
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	var timeout <-chan time.Time
+	if RegexpTimeout > 0 {
+		timer := time.NewTimer(RegexpTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-timeout:
+		return ErrRegexpTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// compileRegexp compiles the regular expression verifying MaxRegexpLen.
+// Returns ErrRegexpSyntax if the regular expression uses Perl syntax not supported by RE2.
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	if (MaxRegexpLen > 0) && (len(expr) > MaxRegexpLen) {
+		return nil, ErrRegexpTooLong
+	}
+
+	if re, ok := compiledExprs.Load(exprKey{RegularExpr, expr}); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		var syntaxErr *syntax.Error
+		if errors.As(err, &syntaxErr) && unsupportedRE2(syntaxErr) {
+			return nil, fmt.Errorf("%w: %w", ErrRegexpSyntax, err)
+		}
+		return nil, err
+	}
+	return re, nil
+}
+
+// unsupportedRE2 returns true if the error is produced by backreferences,
+// lookarounds or possessive quantifiers.
+func unsupportedRE2(err *syntax.Error) bool {
+	switch err.Code {
+	case syntax.ErrInvalidPerlOp, syntax.ErrInvalidNamedCapture:
+		return strings.HasPrefix(err.Expr, "(?=") || strings.HasPrefix(err.Expr, "(?!") ||
+			strings.HasPrefix(err.Expr, "(?<=") || strings.HasPrefix(err.Expr, "(?<!")
+
+	case syntax.ErrInvalidEscape:
+		return (len(err.Expr) == 2) && (err.Expr[1] >= '1') && (err.Expr[1] <= '9')
+
+	case syntax.ErrInvalidRepeatOp:
+		return strings.HasSuffix(err.Expr, "+")
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package parsers
+
+import (
+	"reflect"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// variantSelectors returns the selectors of the first variant of the rules whose Detect selector
+// finds a value, or the selectors of the rules if none does, see colibri.Variant.
+// The Detect selectors are not reported to the SelectorHook, their errors are returned
+// with the names of the variants as keys and the next variant is tried.
+func variantSelectors(rules *colibri.Rules, resp colibri.Response, parent Element) ([]*colibri.Selector, error) {
+	var errs error
+	for _, variant := range rules.Variants {
+		if (variant == nil) || (variant.Detect == nil) {
+			continue
+		}
+
+		found, err := findSelector(rules, resp, variant.Detect, parent, nil, nil)
+		if err != nil {
+			errs = colibri.AddError(errs, variant.Name, err)
+			continue
+		}
+
+		if !isEmpty(found) {
+			return variant.Selectors, errs
+		}
+	}
+	return rules.Selectors, errs
+}
+
+// isEmpty returns true if the value is nil, an empty string or an empty slice or map.
+func isEmpty(value any) bool {
+	if value == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return false
+}
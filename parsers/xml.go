@@ -30,14 +30,14 @@ func (xml *XMLElement) Find(expr, exprType string) (Element, error) {
 		return nil, ErrExprType
 	}
 
-	xmlNode, err := xmlquery.Query(xml.node, expr)
+	xmlNodes, err := queryXML(xml.node, expr, 1)
 	if err != nil {
 		return nil, err
-	} else if xmlNode == nil {
+	} else if len(xmlNodes) == 0 {
 		return nil, nil
 	}
 
-	return &XMLElement{xmlNode}, nil
+	return &XMLElement{xmlNodes[0]}, nil
 }
 
 func (xml *XMLElement) FindAll(expr, exprType string) ([]Element, error) {
@@ -45,7 +45,7 @@ func (xml *XMLElement) FindAll(expr, exprType string) ([]Element, error) {
 		return nil, ErrExprType
 	}
 
-	xmlNodes, err := xmlquery.QueryAll(xml.node, expr)
+	xmlNodes, err := queryXML(xml.node, expr, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,229 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/jsonquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// checkEvery number of nodes visited between each check of XPathTimeout.
+const checkEvery = 64
+
+var (
+	// MaxXPathNodes maximum number of nodes visited during each XPath expression evaluation.
+	// Zero or negative means no limit.
+	MaxXPathNodes = 0
+
+	// XPathTimeout maximum duration of each XPath expression evaluation.
+	// Zero or negative means no limit.
+	XPathTimeout time.Duration = 0
+)
+
+// ErrBudgetExceeded is the error matched by XPathBudgetError with errors.Is.
+var ErrBudgetExceeded = errors.New("evaluation budget exceeded")
+
+// XPathBudgetError is returned when the evaluation of an XPath expression
+// exceeds MaxXPathNodes or XPathTimeout.
+type XPathBudgetError struct {
+	// Expr XPath expression evaluated.
+	Expr string
+
+	// Nodes number of nodes visited.
+	Nodes int
+
+	// Elapsed duration of the evaluation.
+	Elapsed time.Duration
+}
+
+func (err *XPathBudgetError) Error() string {
+	return fmt.Sprintf("XPath %q: %v after visiting %d nodes in %v", err.Expr, ErrBudgetExceeded, err.Nodes, err.Elapsed)
+}
+
+// Is returns true if the target is ErrBudgetExceeded.
+func (err *XPathBudgetError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// xpathLimited returns true if the evaluation of the XPath expressions is limited.
+func xpathLimited() bool {
+	return (MaxXPathNodes > 0) || (XPathTimeout > 0)
+}
+
+// queryHTML returns the nodes that match the XPath expression, at most n nodes if n > 0.
+func queryHTML(top *html.Node, expr string, n int) ([]*html.Node, error) {
+	if !xpathLimited() && !isPrecompiled(XPathExpr, expr) {
+		if n != 1 {
+			return htmlquery.QueryAll(top, expr)
+		}
+
+		node, err := htmlquery.Query(top, expr)
+		if (err != nil) || (node == nil) {
+			return nil, err
+		}
+		return []*html.Node{node}, nil
+	}
+
+	return queryXPath(htmlquery.CreateXPathNavigator(top), expr, n, func(nav xpath.NodeNavigator) *html.Node {
+		if nav.NodeType() == xpath.AttributeNode {
+			text := &html.Node{Type: html.TextNode, Data: nav.Value()}
+			return &html.Node{Type: html.ElementNode, Data: nav.LocalName(), FirstChild: text, LastChild: text}
+		}
+		return nav.(*htmlquery.NodeNavigator).Current()
+	})
+}
+
+// queryXML returns the nodes that match the XPath expression, at most n nodes if n > 0.
+func queryXML(top *xmlquery.Node, expr string, n int) ([]*xmlquery.Node, error) {
+	if !xpathLimited() && !isPrecompiled(XPathExpr, expr) {
+		if n != 1 {
+			return xmlquery.QueryAll(top, expr)
+		}
+
+		node, err := xmlquery.Query(top, expr)
+		if (err != nil) || (node == nil) {
+			return nil, err
+		}
+		return []*xmlquery.Node{node}, nil
+	}
+
+	return queryXPath(xmlquery.CreateXPathNavigator(top), expr, n, func(nav xpath.NodeNavigator) *xmlquery.Node {
+		current := nav.(*xmlquery.NodeNavigator).Current()
+		if nav.NodeType() == xpath.AttributeNode {
+			text := &xmlquery.Node{Type: xmlquery.TextNode, Data: nav.Value()}
+			return &xmlquery.Node{Parent: current, Type: xmlquery.AttributeNode, Data: nav.LocalName(), FirstChild: text, LastChild: text}
+		}
+		return current
+	})
+}
+
+// queryJSON returns the nodes that match the XPath expression, at most n nodes if n > 0.
+func queryJSON(top *jsonquery.Node, expr string, n int) ([]*jsonquery.Node, error) {
+	if !xpathLimited() && !isPrecompiled(XPathExpr, expr) {
+		if n != 1 {
+			return jsonquery.QueryAll(top, expr)
+		}
+
+		node, err := jsonquery.Query(top, expr)
+		if (err != nil) || (node == nil) {
+			return nil, err
+		}
+		return []*jsonquery.Node{node}, nil
+	}
+
+	return queryXPath(jsonquery.CreateXPathNavigator(top), expr, n, func(nav xpath.NodeNavigator) *jsonquery.Node {
+		return nav.(*jsonquery.NodeNavigator).Current()
+	})
+}
+
+// queryXPath evaluates the XPath expression limited by MaxXPathNodes and XPathTimeout,
+// returns the nodes converted with the node function, at most n nodes if n > 0.
+func queryXPath[T any](nav xpath.NodeNavigator, expr string, n int, node func(xpath.NodeNavigator) T) ([]T, error) {
+	exp, err := precompiled(XPathExpr, expr, xpath.Compile)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &budget{maxNodes: MaxXPathNodes, start: time.Now()}
+	if XPathTimeout > 0 {
+		b.deadline = b.start.Add(XPathTimeout)
+	}
+
+	var (
+		nodes []T
+		it    = exp.Select(&budgetNavigator{nav, b})
+	)
+	for ((n <= 0) || (len(nodes) < n)) && it.MoveNext() {
+		if b.exceeded {
+			break
+		}
+
+		current := it.Current()
+		if bn, ok := current.(*budgetNavigator); ok {
+			current = bn.NodeNavigator
+		}
+		nodes = append(nodes, node(current))
+	}
+
+	if b.exceeded {
+		return nil, &XPathBudgetError{Expr: expr, Nodes: b.nodes, Elapsed: time.Since(b.start)}
+	}
+	return nodes, nil
+}
+
+// budget stores the nodes visited during an XPath expression evaluation.
+type budget struct {
+	maxNodes int
+	start    time.Time
+	deadline time.Time
+	nodes    int
+	exceeded bool
+}
+
+// visit counts a node visited, returns false if the budget is exceeded.
+func (b *budget) visit() bool {
+	if b.exceeded {
+		return false
+	}
+
+	b.nodes++
+	if (b.maxNodes > 0) && (b.nodes > b.maxNodes) {
+		b.exceeded = true
+	} else if !b.deadline.IsZero() && (b.nodes%checkEvery == 0) && time.Now().After(b.deadline) {
+		b.exceeded = true
+	}
+	return !b.exceeded
+}
+
+// budgetNavigator is an xpath.NodeNavigator that counts the nodes visited,
+// the moves fail when the budget is exceeded so the evaluation ends.
+type budgetNavigator struct {
+	xpath.NodeNavigator
+	b *budget
+}
+
+func (nav *budgetNavigator) Copy() xpath.NodeNavigator {
+	return &budgetNavigator{nav.NodeNavigator.Copy(), nav.b}
+}
+
+func (nav *budgetNavigator) MoveToRoot() {
+	if nav.b.visit() {
+		nav.NodeNavigator.MoveToRoot()
+	}
+}
+
+func (nav *budgetNavigator) MoveToParent() bool {
+	return nav.b.visit() && nav.NodeNavigator.MoveToParent()
+}
+
+func (nav *budgetNavigator) MoveToNextAttribute() bool {
+	return nav.b.visit() && nav.NodeNavigator.MoveToNextAttribute()
+}
+
+func (nav *budgetNavigator) MoveToChild() bool {
+	return nav.b.visit() && nav.NodeNavigator.MoveToChild()
+}
+
+func (nav *budgetNavigator) MoveToFirst() bool {
+	return nav.b.visit() && nav.NodeNavigator.MoveToFirst()
+}
+
+func (nav *budgetNavigator) MoveToNext() bool {
+	return nav.b.visit() && nav.NodeNavigator.MoveToNext()
+}
+
+func (nav *budgetNavigator) MoveToPrevious() bool {
+	return nav.b.visit() && nav.NodeNavigator.MoveToPrevious()
+}
+
+func (nav *budgetNavigator) MoveTo(other xpath.NodeNavigator) bool {
+	if bn, ok := other.(*budgetNavigator); ok {
+		other = bn.NodeNavigator
+	}
+	return nav.b.visit() && nav.NodeNavigator.MoveTo(other)
+}
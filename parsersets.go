@@ -0,0 +1,160 @@
+package colibri
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrParserSetNotFound is returned when the parser set with the name is not stored.
+	ErrParserSetNotFound = errors.New("parser set not found")
+
+	// ErrParserSetActive is returned when the active parser set is deleted.
+	ErrParserSetActive = errors.New("parser set is active")
+)
+
+// ParserSets stores named Parsers, one of them is the active set used by Colibri instead of
+// its Parser, see Colibri.ParserSets. The active set can be swapped at runtime with Activate,
+// or replaced with Set, without restarting: the extractions that have already started,
+// including the requests of their Follow selectors, keep using the Parser with which they started.
+type ParserSets struct {
+	rw     sync.RWMutex
+	sets   map[string]Parser
+	active string
+}
+
+// NewParserSets returns a new empty ParserSets.
+func NewParserSets() *ParserSets {
+	return &ParserSets{sets: make(map[string]Parser)}
+}
+
+// Set stores the Parser with the name, it replaces the Parser stored with the same name.
+// If the name is the active set, the new extractions use the new Parser.
+// The first set stored is activated.
+func (sets *ParserSets) Set(name string, parser Parser) error {
+	if parser == nil {
+		return ErrParserIsNil
+	}
+
+	sets.rw.Lock()
+	defer sets.rw.Unlock()
+
+	if sets.sets == nil {
+		sets.sets = make(map[string]Parser)
+	}
+
+	if len(sets.sets) == 0 {
+		sets.active = name
+	}
+	sets.sets[name] = parser
+	return nil
+}
+
+// Get returns the Parser stored with the name.
+func (sets *ParserSets) Get(name string) (Parser, bool) {
+	sets.rw.RLock()
+	defer sets.rw.RUnlock()
+
+	parser, ok := sets.sets[name]
+	return parser, ok
+}
+
+// Activate sets the parser set with the name as the active set.
+// Returns ErrParserSetNotFound if the set is not stored.
+func (sets *ParserSets) Activate(name string) error {
+	sets.rw.Lock()
+	defer sets.rw.Unlock()
+
+	if _, ok := sets.sets[name]; !ok {
+		return ErrParserSetNotFound
+	}
+	sets.active = name
+	return nil
+}
+
+// Active returns the name and the Parser of the active set, nil if no set is stored.
+func (sets *ParserSets) Active() (string, Parser) {
+	sets.rw.RLock()
+	defer sets.rw.RUnlock()
+
+	parser, ok := sets.sets[sets.active]
+	if !ok {
+		return "", nil
+	}
+	return sets.active, parser
+}
+
+// Delete removes the parser set with the name, the Parser is not cleared.
+// Returns ErrParserSetActive if it is the active set.
+func (sets *ParserSets) Delete(name string) error {
+	sets.rw.Lock()
+	defer sets.rw.Unlock()
+
+	if _, ok := sets.sets[name]; ok && (name == sets.active) {
+		return ErrParserSetActive
+	}
+
+	delete(sets.sets, name)
+	return nil
+}
+
+// Names returns the sorted names of the parser sets.
+func (sets *ParserSets) Names() []string {
+	sets.rw.RLock()
+	defer sets.rw.RUnlock()
+
+	names := make([]string, 0, len(sets.sets))
+	for name := range sets.sets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Clear clears the Parsers of all the sets, the sets are kept.
+func (sets *ParserSets) Clear() {
+	sets.rw.RLock()
+	defer sets.rw.RUnlock()
+
+	for _, parser := range sets.sets {
+		parser.Clear()
+	}
+}
+
+// parserKey is the key of the context in which the extractions store their Parser.
+type parserKey struct{}
+
+// ctxParser is the Parser of an extraction of a Colibri.
+type ctxParser struct {
+	c      *Colibri
+	parser Parser
+}
+
+// selectParser returns the Parser of the extraction: the Parser stored in the context,
+// the active set of the ParserSets or the Parser of Colibri. The returned context stores it,
+// so the requests of the Follow selectors use the same Parser if the active set is swapped.
+func (c *Colibri) selectParser(ctx context.Context) (context.Context, Parser) {
+	if p, ok := ctx.Value(parserKey{}).(ctxParser); ok && (p.c == c) {
+		return ctx, p.parser
+	}
+
+	parser := c.activeParser()
+	if (parser == nil) || (c.ParserSets == nil) {
+		return ctx, parser
+	}
+	return context.WithValue(ctx, parserKey{}, ctxParser{c, parser}), parser
+}
+
+// activeParser returns the Parser of the active set of the ParserSets or,
+// if there is none, the Parser of Colibri.
+func (c *Colibri) activeParser() Parser {
+	if c.ParserSets != nil {
+		if _, parser := c.ParserSets.Active(); parser != nil {
+			return parser
+		}
+	}
+	return c.Parser
+}
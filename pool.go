@@ -0,0 +1,133 @@
+package colibri
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrRulesReleased is returned when the rules are used after being released, see ReleaseRules.
+	ErrRulesReleased = errors.New("Rules is released")
+
+	// ErrDoubleRelease is the panic value of the rules and the selectors released twice in debug mode, see SetPoolDebug.
+	ErrDoubleRelease = errors.New("released twice")
+)
+
+var poolDebug atomic.Bool
+
+// SetPoolDebug enables or disables the debug mode of the pools of the rules and the selectors.
+// In debug mode, ReleaseRules and ReleaseSelector panic with ErrDoubleRelease if the rules or
+// the selector were already released and the released values are not reused, so the rules
+// used after being released return ErrRulesReleased instead of sharing the values of other rules.
+// Outside debug mode, the second release is ignored.
+func SetPoolDebug(enabled bool) {
+	poolDebug.Store(enabled)
+}
+
+// getRules returns rules of the pool.
+// The rules released without Fields, e.g. created with a literal, get a new map.
+func getRules() *Rules {
+	rules := rulesPool.Get().(*Rules)
+	rules.released = false
+	if rules.Fields == nil {
+		rules.Fields = make(map[string]any)
+	}
+	return rules
+}
+
+// getSelector returns a selector of the pool.
+// The selectors released without Fields, e.g. created with a literal, get a new map.
+func getSelector() *Selector {
+	selector := selectorPool.Get().(*Selector)
+	selector.released = false
+	if selector.Fields == nil {
+		selector.Fields = make(map[string]any)
+	}
+	return selector
+}
+
+// markReleased marks the value as released, returns false if it was already released.
+func markReleased(released *bool) bool {
+	if *released {
+		if poolDebug.Load() {
+			panic(ErrDoubleRelease)
+		}
+		return false
+	}
+
+	*released = true
+	return true
+}
+
+// Scope owns the rules and the selectors added to it and releases them together with Release,
+// e.g. the rules derived while a request is processed, so they are not released by hand.
+// The selectors of the rules added must not be added, they are released with the rules.
+// The zero value is ready to use and it is safe for concurrent use.
+type Scope struct {
+	mu        sync.Mutex
+	rules     []*Rules
+	selectors []*Selector
+}
+
+// NewScope returns a new empty Scope.
+func NewScope() *Scope {
+	return &Scope{}
+}
+
+// Rules adds the rules to the Scope and returns them.
+func (scope *Scope) Rules(rules *Rules) *Rules {
+	if rules == nil {
+		return nil
+	}
+
+	scope.mu.Lock()
+	scope.rules = append(scope.rules, rules)
+	scope.mu.Unlock()
+	return rules
+}
+
+// Selector adds the selector to the Scope and returns it.
+func (scope *Scope) Selector(selector *Selector) *Selector {
+	if selector == nil {
+		return nil
+	}
+
+	scope.mu.Lock()
+	scope.selectors = append(scope.selectors, selector)
+	scope.mu.Unlock()
+	return selector
+}
+
+// NewRules is like colibri.NewRules, the rules are owned by the Scope, even if there is an error.
+func (scope *Scope) NewRules(rawRules RawRules) (*Rules, error) {
+	rules, err := NewRules(rawRules)
+	return scope.Rules(rules), err
+}
+
+// Clone returns a copy of the rules owned by the Scope, see Rules.Clone.
+func (scope *Scope) Clone(rules *Rules) *Rules {
+	return scope.Rules(rules.Clone())
+}
+
+// SelectorRules returns the rules of the selector owned by the Scope, see Selector.Rules.
+func (scope *Scope) SelectorRules(selector *Selector, src *Rules) (*Rules, error) {
+	rules, err := selector.RulesWithConvFunc(src, DefaultConvFunc)
+	return scope.Rules(rules), err
+}
+
+// Release releases the rules and the selectors of the Scope, the Scope can be used again.
+func (scope *Scope) Release() {
+	scope.mu.Lock()
+	rules, selectors := scope.rules, scope.selectors
+	scope.rules, scope.selectors = nil, nil
+	scope.mu.Unlock()
+
+	for _, r := range rules {
+		ReleaseRules(r)
+	}
+
+	for _, sel := range selectors {
+		ReleaseSelector(sel)
+	}
+}
@@ -0,0 +1,269 @@
+// prometheus collects the metrics of Colibri and exposes them in the Prometheus text format,
+// so they can be scraped by a Prometheus server to monitor the crawls.
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// DefaultNamespace is the prefix of the metric names used by default.
+const DefaultNamespace = "colibri"
+
+// ContentType is the Content-Type of the Prometheus text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds in seconds of the buckets of the duration histograms.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics collects the metrics of Colibri, see the colibri.Metrics interface.
+//
+//	colibri_requests_total{host, code}     requests by host and status code, "error" if the request failed
+//	colibri_request_duration_seconds       histogram of the time taken by the requests
+//	colibri_response_bytes_total{host}     bytes of the bodies of the responses downloaded
+//	colibri_parse_duration_seconds         histogram of the time taken by the parses
+//	colibri_parse_errors_total             parses that failed
+//	colibri_robots_denials_total{host}     requests not allowed by the robots.txt
+type Metrics struct {
+	// Namespace is the prefix of the metric names, DefaultNamespace if empty.
+	Namespace string
+
+	// Buckets are the upper bounds in seconds of the buckets of the histograms,
+	// in increasing order. If nil, DefaultBuckets are used.
+	Buckets []float64
+
+	mu              sync.Mutex
+	requests        map[[2]string]float64
+	bytes           map[string]float64
+	robotsDenials   map[string]float64
+	parseErrors     float64
+	requestDuration *histogram
+	parseDuration   *histogram
+}
+
+// New returns new Metrics.
+func New() *Metrics {
+	return &Metrics{}
+}
+
+// Wrap sets the Metrics of Colibri.
+func (metrics *Metrics) Wrap(c *colibri.Colibri) {
+	c.Metrics = metrics
+}
+
+func (metrics *Metrics) ObserveRequest(u *url.URL, statusCode int, duration time.Duration, err error) {
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(statusCode)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	metrics.init()
+	metrics.requests[[2]string{host(u), code}]++
+	metrics.requestDuration.observe(duration.Seconds())
+}
+
+func (metrics *Metrics) ObserveBytes(u *url.URL, n int) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	metrics.init()
+	metrics.bytes[host(u)] += float64(n)
+}
+
+func (metrics *Metrics) ObserveParse(_ *url.URL, duration time.Duration, err error) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	metrics.init()
+	metrics.parseDuration.observe(duration.Seconds())
+	if err != nil {
+		metrics.parseErrors++
+	}
+}
+
+func (metrics *Metrics) ObserveRobotsDenial(u *url.URL) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	metrics.init()
+	metrics.robotsDenials[host(u)]++
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (metrics *Metrics) WriteTo(w io.Writer) (int64, error) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	metrics.init()
+
+	var (
+		cw = &countWriter{w: w}
+		bw = bufio.NewWriter(cw)
+		ns = metrics.Namespace
+	)
+	if ns == "" {
+		ns = DefaultNamespace
+	}
+
+	header := func(name, help, kind string) {
+		fmt.Fprintf(bw, "# HELP %s_%s %s\n# TYPE %s_%s %s\n", ns, name, help, ns, name, kind)
+	}
+
+	header("requests_total", "Requests by host and status code.", "counter")
+	keys := make([][2]string, 0, len(metrics.requests))
+	for key := range metrics.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, key := range keys {
+		fmt.Fprintf(bw, "%s_requests_total{host=%s,code=%s} %s\n", ns, quote(key[0]), quote(key[1]), formatFloat(metrics.requests[key]))
+	}
+
+	header("request_duration_seconds", "Time taken by the requests.", "histogram")
+	metrics.requestDuration.write(bw, ns+"_request_duration_seconds")
+
+	header("response_bytes_total", "Bytes of the bodies of the responses by host.", "counter")
+	writeHostCounter(bw, ns+"_response_bytes_total", metrics.bytes)
+
+	header("parse_duration_seconds", "Time taken by the parses.", "histogram")
+	metrics.parseDuration.write(bw, ns+"_parse_duration_seconds")
+
+	header("parse_errors_total", "Parses that failed.", "counter")
+	fmt.Fprintf(bw, "%s_parse_errors_total %s\n", ns, formatFloat(metrics.parseErrors))
+
+	header("robots_denials_total", "Requests not allowed by the robots.txt by host.", "counter")
+	writeHostCounter(bw, ns+"_robots_denials_total", metrics.robotsDenials)
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler returns an http.Handler that serves the metrics in the Prometheus text format.
+func (metrics *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		metrics.WriteTo(w)
+	})
+}
+
+// Clear removes the collected metrics.
+func (metrics *Metrics) Clear() {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	metrics.requests = nil
+	metrics.bytes = nil
+	metrics.robotsDenials = nil
+	metrics.parseErrors = 0
+	metrics.requestDuration = nil
+	metrics.parseDuration = nil
+}
+
+// init initializes the metrics, the lock must be held.
+func (metrics *Metrics) init() {
+	if metrics.requests != nil {
+		return
+	}
+
+	buckets := metrics.Buckets
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+
+	metrics.requests = make(map[[2]string]float64)
+	metrics.bytes = make(map[string]float64)
+	metrics.robotsDenials = make(map[string]float64)
+	metrics.requestDuration = newHistogram(buckets)
+	metrics.parseDuration = newHistogram(buckets)
+}
+
+// histogram counts the observations in cumulative buckets.
+type histogram struct {
+	bounds []float64
+	counts []float64
+	count  float64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]float64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) write(w io.Writer, name string) {
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=%s} %s\n", name, quote(formatFloat(bound)), formatFloat(h.counts[i]))
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %s\n", name, formatFloat(h.count))
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %s\n", name, formatFloat(h.count))
+}
+
+// writeHostCounter writes the counter with the host label, sorted by host.
+func writeHostCounter(w io.Writer, name string, values map[string]float64) {
+	hosts := make([]string, 0, len(values))
+	for h := range values {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+
+	for _, h := range hosts {
+		fmt.Fprintf(w, "%s{host=%s} %s\n", name, quote(h), formatFloat(values[h]))
+	}
+}
+
+// host returns the normalized host of the URL, empty if it is nil.
+func host(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return colibri.NormalizeHost(u.Host)
+}
+
+// quote returns the label value quoted and escaped.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countWriter counts the bytes written.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,128 @@
+package prometheus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+const (
+	testBody   = "<html><head><title>Metrics</title></head></html>\n"
+	testRobots = "User-agent: *\nDisallow: /disallow\n"
+)
+
+func TestMetrics(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	we, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	metrics := New()
+	metrics.Wrap(we)
+
+	tests := []struct {
+		Path    string
+		WantErr error
+	}{
+		{"/html", nil},
+		{"/html", nil},
+		{"/missing", nil},
+		{"/disallow", webextractor.ErrorRobotstxtRestriction},
+	}
+
+	for _, tt := range tests {
+		rules := &colibri.Rules{
+			Method:    "GET",
+			URL:       mustNewURL(ts.URL + tt.Path),
+			Selectors: []*colibri.Selector{{Name: "title", Expr: "//title"}},
+		}
+
+		_, _, err := we.Extract(rules)
+		if (tt.WantErr != nil) && !errors.Is(err, tt.WantErr) {
+			t.Fatalf("got %v, want %v", err, tt.WantErr)
+		}
+	}
+
+	var (
+		host = quote(mustNewURL(ts.URL).Host)
+		buf  bytes.Buffer
+	)
+	if _, err := metrics.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"# TYPE colibri_requests_total counter\n",
+		fmt.Sprintf("colibri_requests_total{host=%s,code=\"200\"} 3\n", host), // robots.txt and /html
+		fmt.Sprintf("colibri_requests_total{host=%s,code=\"404\"} 1\n", host),
+		"colibri_request_duration_seconds_count 4\n",
+		"colibri_request_duration_seconds_bucket{le=\"+Inf\"} 4\n",
+		fmt.Sprintf("colibri_response_bytes_total{host=%s} %d\n", host, 3*len(testBody)+len(testRobots)),
+		"colibri_parse_duration_seconds_count 3\n",
+		"colibri_parse_errors_total 0\n",
+		fmt.Sprintf("colibri_robots_denials_total{host=%s} 1\n", host),
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("%q not found in\n%s", want, buf.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != ContentType {
+		t.Fatalf("got %v, want %v", got, ContentType)
+	} else if got := rec.Body.String(); got != buf.String() {
+		t.Fatalf("got %v, want %v", got, buf.String())
+	}
+
+	metrics.Clear()
+	metrics.ObserveRequest(mustNewURL("https://example.com"), 0, 0, errors.New("Test Error"))
+	buf.Reset()
+	if _, err := metrics.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "colibri_requests_total{host=\"example.com\",code=\"error\"} 1\n"; !strings.Contains(buf.String(), want) {
+		t.Fatalf("%q not found in\n%s", want, buf.String())
+	} else if strings.Contains(buf.String(), "code=\"200\"") {
+		t.Fatalf("got %v, want cleared metrics", buf.String())
+	}
+}
+
+func testServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, testRobots)
+
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, testBody)
+
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, testBody)
+		}
+	}))
+}
+
+func mustNewURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,89 @@
+package colibri
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// DoRaw is like Do, the rules are built from the raw rules with NewRules
+// and released after the request, see ReleaseRules.
+func (c *Colibri) DoRaw(rawRules RawRules) (Response, error) {
+	return c.DoRawContext(context.Background(), rawRules)
+}
+
+// DoRawContext is like DoRaw, the context is propagated to DoContext.
+func (c *Colibri) DoRawContext(ctx context.Context, rawRules RawRules) (Response, error) {
+	rules, err := NewRules(rawRules)
+	if err != nil {
+		ReleaseRules(rules)
+		return nil, err
+	}
+	defer ReleaseRules(rules)
+
+	return c.DoContext(ctx, rules)
+}
+
+// DoJSON is like DoRaw, the raw rules are decoded from the JSON document.
+func (c *Colibri) DoJSON(b []byte) (Response, error) {
+	return c.DoJSONContext(context.Background(), b)
+}
+
+// DoJSONContext is like DoJSON, the context is propagated to DoContext.
+func (c *Colibri) DoJSONContext(ctx context.Context, b []byte) (Response, error) {
+	rawRules, err := decodeRawRules(b)
+	if err != nil {
+		return nil, err
+	}
+	return c.DoRawContext(ctx, rawRules)
+}
+
+// ExtractRaw is like Extract, the rules are built from the raw rules with NewRules
+// and released after the extraction, see ReleaseRules. The rules of a parse that
+// is abandoned (ErrParseTimeout or the context is done) are not released,
+// because the parse continues in the background.
+func (c *Colibri) ExtractRaw(rawRules RawRules) (Response, map[string]any, error) {
+	return c.ExtractRawContext(context.Background(), rawRules)
+}
+
+// ExtractRawContext is like ExtractRaw, the context is propagated to ExtractContext.
+func (c *Colibri) ExtractRawContext(ctx context.Context, rawRules RawRules) (Response, map[string]any, error) {
+	rules, err := NewRules(rawRules)
+	if err != nil {
+		ReleaseRules(rules)
+		return nil, nil, err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	resp, output, err := c.ExtractContext(ctx, rules)
+	if !errors.Is(err, ErrParseTimeout) && (ctx.Err() == nil) {
+		ReleaseRules(rules)
+	}
+	return resp, output, err
+}
+
+// ExtractJSON is like ExtractRaw, the raw rules are decoded from the JSON document.
+func (c *Colibri) ExtractJSON(b []byte) (Response, map[string]any, error) {
+	return c.ExtractJSONContext(context.Background(), b)
+}
+
+// ExtractJSONContext is like ExtractJSON, the context is propagated to ExtractContext.
+func (c *Colibri) ExtractJSONContext(ctx context.Context, b []byte) (Response, map[string]any, error) {
+	rawRules, err := decodeRawRules(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.ExtractRawContext(ctx, rawRules)
+}
+
+// decodeRawRules decodes the raw rules of the JSON document.
+func decodeRawRules(b []byte) (RawRules, error) {
+	var rawRules RawRules
+	if err := json.Unmarshal(b, &rawRules); err != nil {
+		return nil, err
+	}
+	return rawRules, nil
+}
@@ -0,0 +1,156 @@
+package render
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// launchTimeout maximum time to wait for the browser to start.
+const launchTimeout = 30 * time.Second
+
+var (
+	// ErrBrowserNotFound is returned when the ExecPath is empty and no Chrome or Chromium executable is found.
+	ErrBrowserNotFound = errors.New("browser not found")
+
+	// ErrBrowserExited is returned when the browser exits before listening for DevTools connections.
+	ErrBrowserExited = errors.New("browser exited")
+)
+
+// execNames names of the executables searched in the PATH.
+var execNames = []string{
+	"google-chrome",
+	"google-chrome-stable",
+	"chromium",
+	"chromium-browser",
+	"chrome",
+	"headless-shell",
+}
+
+// DefaultFlags flags used to start the browser.
+var DefaultFlags = []string{
+	"--headless=new",
+	"--disable-gpu",
+	"--no-first-run",
+	"--no-default-browser-check",
+	"--disable-background-networking",
+	"--disable-extensions",
+	"--mute-audio",
+	"--remote-allow-origins=*",
+}
+
+// browser is a process of the browser.
+type browser struct {
+	cmd     *exec.Cmd
+	dataDir string
+	conn    *conn
+}
+
+// findExec returns the path of the first executable of execNames found in the PATH.
+func findExec() (string, error) {
+	for _, name := range execNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", ErrBrowserNotFound
+}
+
+// launch starts the browser and connects to it.
+func launch(ctx context.Context, execPath string, flags []string) (*browser, error) {
+	if execPath == "" {
+		path, err := findExec()
+		if err != nil {
+			return nil, err
+		}
+		execPath = path
+	}
+
+	dataDir, err := os.MkdirTemp("", "colibri-render-")
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{}, flags...)
+	args = append(args, "--remote-debugging-port=0", "--user-data-dir="+dataDir, "about:blank")
+
+	// the browser is not tied to the context of the request that starts it
+	cmd := exec.Command(execPath, args...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		os.RemoveAll(dataDir)
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dataDir)
+		return nil, err
+	}
+
+	b := &browser{cmd: cmd, dataDir: dataDir}
+
+	wsURL := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if _, after, ok := strings.Cut(line, "DevTools listening on "); ok {
+				wsURL <- strings.TrimSpace(after)
+				break
+			}
+		}
+		close(wsURL)
+
+		// the output is discarded so the browser does not block writing to stderr
+		for scanner.Scan() {
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, launchTimeout)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		b.close()
+		return nil, ctx.Err()
+
+	case u, ok := <-wsURL:
+		if !ok {
+			b.close()
+			return nil, ErrBrowserExited
+		}
+
+		b.conn, err = dial(u)
+		if err != nil {
+			b.close()
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// connect connects to a running browser with the DevTools websocket URL.
+func connect(wsURL string) (*browser, error) {
+	cn, err := dial(wsURL)
+	if err != nil {
+		return nil, err
+	}
+	return &browser{conn: cn}, nil
+}
+
+// close closes the connection and stops the process of the browser.
+func (b *browser) close() {
+	if b.conn != nil {
+		b.conn.close(nil)
+	}
+
+	if b.cmd != nil {
+		b.cmd.Process.Kill()
+		b.cmd.Wait()
+		os.RemoveAll(b.dataDir)
+	}
+}
@@ -0,0 +1,187 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// maxPayloadBytes maximum size of a message received from the browser,
+// the rendered documents are returned in a single message.
+const maxPayloadBytes = 256 << 20
+
+// ErrConnClosed is returned when the connection to the browser is closed.
+var ErrConnClosed = errors.New("connection to the browser closed")
+
+// message is a message of the Chrome DevTools Protocol.
+type message struct {
+	ID        int64           `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    any             `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *cdpError       `json:"error,omitempty"`
+}
+
+// event is a message of the browser without ID.
+type event struct {
+	Method string
+	Params json.RawMessage
+}
+
+type cdpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *cdpError) Error() string {
+	return fmt.Sprintf("%s (code %d)", err.Message, err.Code)
+}
+
+// conn is a connection to the browser with the Chrome DevTools Protocol.
+type conn struct {
+	ws *websocket.Conn
+
+	sendMu sync.Mutex
+
+	mu       sync.Mutex
+	nextID   int64
+	pending  map[int64]chan *message
+	sessions map[string]func(event)
+	err      error
+	done     chan struct{}
+}
+
+// dial connects to the DevTools websocket URL of the browser.
+func dial(wsURL string) (*conn, error) {
+	ws, err := websocket.Dial(wsURL, "", "http://127.0.0.1/")
+	if err != nil {
+		return nil, err
+	}
+	ws.MaxPayloadBytes = maxPayloadBytes
+
+	cn := &conn{
+		ws:       ws,
+		pending:  make(map[int64]chan *message),
+		sessions: make(map[string]func(event)),
+		done:     make(chan struct{}),
+	}
+	go cn.read()
+	return cn, nil
+}
+
+// read receives the messages until the connection is closed.
+func (cn *conn) read() {
+	for {
+		var raw struct {
+			message
+			Params json.RawMessage `json:"params,omitempty"`
+		}
+		if err := websocket.JSON.Receive(cn.ws, &raw); err != nil {
+			cn.close(err)
+			return
+		}
+
+		cn.mu.Lock()
+		var handler func(event)
+		if raw.ID != 0 {
+			if ch, ok := cn.pending[raw.ID]; ok {
+				delete(cn.pending, raw.ID)
+				ch <- &raw.message
+			}
+		} else {
+			handler = cn.sessions[raw.SessionID]
+		}
+		cn.mu.Unlock()
+
+		if handler != nil {
+			handler(event{Method: raw.Method, Params: raw.Params})
+		}
+	}
+}
+
+// alive returns true if the connection is not closed.
+func (cn *conn) alive() bool {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	return cn.err == nil
+}
+
+// close closes the connection, the pending calls return the error.
+func (cn *conn) close(err error) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+
+	if cn.err != nil {
+		return
+	}
+
+	cn.err = errors.Join(ErrConnClosed, err)
+	cn.ws.Close()
+	close(cn.done)
+}
+
+// subscribe sets the handler of the events of the session,
+// it is called by the reader so it must not block.
+func (cn *conn) subscribe(sessionID string, handler func(event)) {
+	cn.mu.Lock()
+	cn.sessions[sessionID] = handler
+	cn.mu.Unlock()
+}
+
+func (cn *conn) unsubscribe(sessionID string) {
+	cn.mu.Lock()
+	delete(cn.sessions, sessionID)
+	cn.mu.Unlock()
+}
+
+// call calls the method and decodes the result, if the result is not nil.
+func (cn *conn) call(ctx context.Context, sessionID, method string, params, result any) error {
+	ch := make(chan *message, 1)
+
+	cn.mu.Lock()
+	if cn.err != nil {
+		cn.mu.Unlock()
+		return cn.err
+	}
+	cn.nextID++
+	id := cn.nextID
+	cn.pending[id] = ch
+	cn.mu.Unlock()
+
+	cn.sendMu.Lock()
+	err := websocket.JSON.Send(cn.ws, &message{ID: id, SessionID: sessionID, Method: method, Params: params})
+	cn.sendMu.Unlock()
+
+	if err != nil {
+		cn.mu.Lock()
+		delete(cn.pending, id)
+		cn.mu.Unlock()
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		cn.mu.Lock()
+		delete(cn.pending, id)
+		cn.mu.Unlock()
+		return ctx.Err()
+
+	case <-cn.done:
+		return cn.err
+
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+
+		if (result != nil) && (len(msg.Result) > 0) {
+			return json.Unmarshal(msg.Result, result)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,359 @@
+// render renders the pages that require JavaScript with a headless Chrome or Chromium browser
+// controlled with the Chrome DevTools Protocol. The rules with Render use the browser,
+// the other rules use the wrapped HTTPClient.
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// DefaultTimeout default time to load and render a page, including the RenderWait.
+const DefaultTimeout = 30 * time.Second
+
+// disposeTimeout maximum time to wait for the browser context of a page to be disposed.
+const disposeTimeout = 5 * time.Second
+
+// documentExpr expression evaluated to get the URL and the rendered document of the page.
+const documentExpr = `({url: location.href, html: document.documentElement ? document.documentElement.outerHTML : ""})`
+
+var (
+	// ErrClientIsNil is returned when the rules do not use Render and the wrapped HTTPClient is nil.
+	ErrClientIsNil = errors.New("HTTPClient is nil")
+
+	// ErrMethodNotSupported is returned when the method of the rules with Render is not GET.
+	ErrMethodNotSupported = errors.New("method not supported by the browser")
+)
+
+// Client renders the pages of the rules with Render in the browser and returns the
+// document after executing its JavaScript as the body of the response.
+// Each page is loaded in a new browser context with the Header and the Proxy of the rules,
+// so the cookies are not shared between pages nor with the wrapped HTTPClient.
+// Only GET requests are supported.
+// See the colibri.HTTPClient interface.
+type Client struct {
+	colibri.HTTPClient
+
+	// ExecPath path of the browser executable.
+	// If empty, Chrome or Chromium is searched in the PATH.
+	ExecPath string
+
+	// Flags flags used to start the browser, if nil DefaultFlags is used.
+	Flags []string
+
+	// DevToolsURL websocket URL of a running browser, e.g. ws://127.0.0.1:9222/devtools/browser/<id>.
+	// If not empty, the browser is not started.
+	DevToolsURL string
+
+	mu      sync.Mutex
+	browser *browser
+}
+
+// Wrap replaces the Client of Colibri with a Client that wraps it and returns the new Client.
+func Wrap(c *colibri.Colibri) *Client {
+	client := &Client{HTTPClient: c.Client}
+	c.Client = client
+	return client
+}
+
+func (client *Client) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	return client.DoContext(context.Background(), c, rules)
+}
+
+// DoContext renders the page if the rules use Render, otherwise calls DoContext of the
+// wrapped HTTPClient if it implements colibri.HTTPClientContext, or Do.
+func (client *Client) DoContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	if !rules.Render {
+		if client.HTTPClient == nil {
+			return nil, ErrClientIsNil
+		}
+
+		return colibri.ClientDo(ctx, client.HTTPClient, c, rules)
+	}
+
+	if (rules.Method != "") && (rules.Method != http.MethodGet) {
+		return nil, fmt.Errorf("%w: %s", ErrMethodNotSupported, rules.Method)
+	}
+
+	timeout := rules.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	renderCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	b, err := client.getBrowser(renderCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.render(renderCtx, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.c = c
+	resp.ctx = ctx
+	return resp, nil
+}
+
+// Clear stops the browser and clears the wrapped HTTPClient.
+func (client *Client) Clear() {
+	client.mu.Lock()
+	if client.browser != nil {
+		client.browser.close()
+		client.browser = nil
+	}
+	client.mu.Unlock()
+
+	if client.HTTPClient != nil {
+		client.HTTPClient.Clear()
+	}
+}
+
+// ReportCapabilities adds the "render" feature and the capabilities of the wrapped HTTPClient to the report.
+// See the colibri.CapabilityReporter interface.
+func (client *Client) ReportCapabilities(caps *colibri.Capabilities) {
+	colibri.ReportCapabilitiesOf(client.HTTPClient, caps)
+	caps.Features = append(caps.Features, "render")
+}
+
+// getBrowser returns the browser, it is started or connected on first use
+// and again if the connection is closed.
+func (client *Client) getBrowser(ctx context.Context) (*browser, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if (client.browser != nil) && client.browser.conn.alive() {
+		return client.browser, nil
+	}
+
+	if client.browser != nil {
+		client.browser.close()
+		client.browser = nil
+	}
+
+	var (
+		b   *browser
+		err error
+	)
+	if client.DevToolsURL != "" {
+		b, err = connect(client.DevToolsURL)
+	} else {
+		flags := client.Flags
+		if flags == nil {
+			flags = DefaultFlags
+		}
+		b, err = launch(ctx, client.ExecPath, flags)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	client.browser = b
+	return b, nil
+}
+
+// page state of a page loaded in the browser.
+type page struct {
+	frameID string
+
+	mu         sync.Mutex
+	statusCode int
+	header     http.Header
+	loaded     chan struct{}
+	loadedOnce sync.Once
+}
+
+// handle handles the events of the page.
+func (p *page) handle(ev event) {
+	switch ev.Method {
+	case "Network.responseReceived":
+		var params struct {
+			Type     string `json:"type"`
+			FrameID  string `json:"frameId"`
+			Response struct {
+				Status  int            `json:"status"`
+				Headers map[string]any `json:"headers"`
+			} `json:"response"`
+		}
+		if (json.Unmarshal(ev.Params, &params) != nil) || (params.Type != "Document") {
+			return
+		}
+
+		if params.FrameID != p.frameID {
+			return
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.statusCode = params.Response.Status
+		p.header = make(http.Header, len(params.Response.Headers))
+		for key, value := range params.Response.Headers {
+			// the values of the repeated headers are separated by new lines
+			for _, v := range splitLines(fmt.Sprint(value)) {
+				p.header.Add(key, v)
+			}
+		}
+
+	case "Page.loadEventFired":
+		p.loadedOnce.Do(func() { close(p.loaded) })
+	}
+}
+
+// render loads the URL of the rules in a new browser context and returns the rendered document.
+func (b *browser) render(ctx context.Context, rules *colibri.Rules) (*Response, error) {
+	cn := b.conn
+
+	contextParams := map[string]any{"disposeOnDetach": true}
+	if rules.Proxy != nil {
+		contextParams["proxyServer"] = rules.Proxy.String()
+	}
+
+	var browserContext struct {
+		BrowserContextID string `json:"browserContextId"`
+	}
+	if err := cn.call(ctx, "", "Target.createBrowserContext", contextParams, &browserContext); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), disposeTimeout)
+		defer cancel()
+
+		params := map[string]any{"browserContextId": browserContext.BrowserContextID}
+		cn.call(ctx, "", "Target.disposeBrowserContext", params, nil)
+	}()
+
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	targetParams := map[string]any{"url": "about:blank", "browserContextId": browserContext.BrowserContextID}
+	if err := cn.call(ctx, "", "Target.createTarget", targetParams, &target); err != nil {
+		return nil, err
+	}
+
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	attachParams := map[string]any{"targetId": target.TargetID, "flatten": true}
+	if err := cn.call(ctx, "", "Target.attachToTarget", attachParams, &session); err != nil {
+		return nil, err
+	}
+
+	// the ID of the main frame is the ID of the target
+	p := &page{frameID: target.TargetID, loaded: make(chan struct{})}
+	cn.subscribe(session.SessionID, p.handle)
+	defer cn.unsubscribe(session.SessionID)
+
+	sid := session.SessionID
+	for _, method := range []string{"Page.enable", "Network.enable"} {
+		if err := cn.call(ctx, sid, method, nil, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if ua := rules.Header.Get("User-Agent"); ua != "" {
+		if err := cn.call(ctx, sid, "Network.setUserAgentOverride", map[string]any{"userAgent": ua}, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	headers := make(map[string]string, len(rules.Header))
+	for key, values := range rules.Header {
+		if (http.CanonicalHeaderKey(key) != "User-Agent") && (len(values) > 0) {
+			headers[key] = values[0]
+		}
+	}
+
+	if len(headers) > 0 {
+		if err := cn.call(ctx, sid, "Network.setExtraHTTPHeaders", map[string]any{"headers": headers}, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	var nav struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := cn.call(ctx, sid, "Page.navigate", map[string]any{"url": rules.URL.String()}, &nav); err != nil {
+		return nil, err
+	} else if nav.ErrorText != "" {
+		return nil, errors.New(nav.ErrorText)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-cn.done:
+		return nil, cn.err
+	case <-p.loaded:
+	}
+
+	if rules.RenderWait > 0 {
+		timer := time.NewTimer(rules.RenderWait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	var eval struct {
+		Result struct {
+			Value struct {
+				URL  string `json:"url"`
+				HTML string `json:"html"`
+			} `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	evalParams := map[string]any{"expression": documentExpr, "returnByValue": true}
+	if err := cn.call(ctx, sid, "Runtime.evaluate", evalParams, &eval); err != nil {
+		return nil, err
+	} else if eval.ExceptionDetails != nil {
+		return nil, errors.New(eval.ExceptionDetails.Text)
+	}
+
+	u, err := url.Parse(eval.Result.Value.URL)
+	if (err != nil) || (eval.Result.Value.URL == "") {
+		u = rules.URL
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	resp := &Response{
+		u:          u,
+		statusCode: p.statusCode,
+		header:     p.header,
+		body:       []byte(eval.Result.Value.HTML),
+	}
+
+	if resp.statusCode == 0 {
+		resp.statusCode = http.StatusOK
+	}
+
+	if resp.header == nil {
+		resp.header = make(http.Header)
+	}
+
+	// the body is the serialized document, not the body received
+	resp.header.Set("Content-Type", "text/html; charset=utf-8")
+	resp.header.Del("Content-Encoding")
+	resp.header.Del("Content-Length")
+	return resp, nil
+}
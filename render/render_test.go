@@ -0,0 +1,213 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+// fakeBrowser implements the methods of the Chrome DevTools Protocol used by the Client.
+// The rendered document contains the User-Agent and the X-Token header of the page.
+type fakeBrowser struct {
+	mu       sync.Mutex
+	contexts map[string]bool
+	created  int
+}
+
+func (fb *fakeBrowser) handler(ws *websocket.Conn) {
+	var (
+		sendMu    sync.Mutex
+		userAgent string
+		token     string
+		pageURL   string
+	)
+
+	send := func(msg map[string]any) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		websocket.JSON.Send(ws, msg)
+	}
+
+	for {
+		var msg struct {
+			ID        int64           `json:"id"`
+			SessionID string          `json:"sessionId"`
+			Method    string          `json:"method"`
+			Params    json.RawMessage `json:"params"`
+		}
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return
+		}
+
+		var params map[string]any
+		json.Unmarshal(msg.Params, &params)
+
+		result := map[string]any{}
+		switch msg.Method {
+		case "Target.createBrowserContext":
+			fb.mu.Lock()
+			fb.created++
+			id := fmt.Sprintf("context-%d", fb.created)
+			fb.contexts[id] = true
+			fb.mu.Unlock()
+			result["browserContextId"] = id
+
+		case "Target.disposeBrowserContext":
+			fb.mu.Lock()
+			delete(fb.contexts, params["browserContextId"].(string))
+			fb.mu.Unlock()
+
+		case "Target.createTarget":
+			result["targetId"] = "target"
+
+		case "Target.attachToTarget":
+			result["sessionId"] = "session"
+
+		case "Network.setUserAgentOverride":
+			userAgent = params["userAgent"].(string)
+
+		case "Network.setExtraHTTPHeaders":
+			token, _ = params["headers"].(map[string]any)["X-Token"].(string)
+
+		case "Page.navigate":
+			pageURL = params["url"].(string)
+			if strings.HasSuffix(pageURL, "/error") {
+				result["errorText"] = "net::ERR_NAME_NOT_RESOLVED"
+				break
+			}
+
+			result["frameId"] = "target"
+			send(map[string]any{"sessionId": msg.SessionID, "method": "Network.responseReceived", "params": map[string]any{
+				"type":     "Image",
+				"frameId":  "target",
+				"response": map[string]any{"status": 404},
+			}})
+			send(map[string]any{"sessionId": msg.SessionID, "method": "Network.responseReceived", "params": map[string]any{
+				"type":     "Document",
+				"frameId":  "target",
+				"response": map[string]any{"status": 203, "headers": map[string]any{"X-Served": "fake", "Content-Length": "10"}},
+			}})
+			send(map[string]any{"sessionId": msg.SessionID, "method": "Page.loadEventFired", "params": map[string]any{}})
+
+		case "Runtime.evaluate":
+			html := fmt.Sprintf(`<html><head><title>Rendered</title></head><body><p id="ua">%s</p><p id="token">%s</p></body></html>`, userAgent, token)
+			result["result"] = map[string]any{"type": "object", "value": map[string]any{"url": pageURL + "#rendered", "html": html}}
+		}
+
+		send(map[string]any{"id": msg.ID, "sessionId": msg.SessionID, "result": result})
+	}
+}
+
+func TestClient(t *testing.T) {
+	fb := &fakeBrowser{contexts: make(map[string]bool)}
+
+	mux := http.NewServeMux()
+	mux.Handle("/devtools/browser/test", websocket.Handler(fb.handler))
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Static</title></head></html>`))
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	we, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	client := Wrap(we)
+	client.DevToolsURL = "ws" + strings.TrimPrefix(ts.URL, "http") + "/devtools/browser/test"
+	defer client.Clear()
+
+	newRules := func(render bool) *colibri.Rules {
+		return &colibri.Rules{
+			URL:             mustNewURL(ts.URL + "/page"),
+			Header:          http.Header{"User-Agent": {"test/0.0.1"}, "X-Token": {"T123"}},
+			IgnoreRobotsTxt: true,
+			Render:          render,
+			Selectors: []*colibri.Selector{
+				{Name: "title", Expr: "//title"},
+				{Name: "ua", Expr: `//p[@id="ua"]`},
+				{Name: "token", Expr: `//p[@id="token"]`},
+			},
+		}
+	}
+
+	t.Run("Render", func(t *testing.T) {
+		resp, output, err := we.Extract(newRules(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]any{"title": "Rendered", "ua": "test/0.0.1", "token": "T123"}
+		if fmt.Sprint(output) != fmt.Sprint(want) {
+			t.Fatalf("got %v, want %v", output, want)
+		}
+
+		if resp.StatusCode() != 203 {
+			t.Fatalf("got %v, want %v", resp.StatusCode(), 203)
+		}
+
+		if got := resp.URL().String(); got != ts.URL+"/page#rendered" {
+			t.Fatalf("got %v, want %v", got, ts.URL+"/page#rendered")
+		}
+
+		header := resp.Header()
+		if (header.Get("X-Served") != "fake") || (header.Get("Content-Type") != "text/html; charset=utf-8") || (header.Get("Content-Length") != "") {
+			t.Fatalf("unexpected header %v", header)
+		}
+	})
+
+	t.Run("NoRender", func(t *testing.T) {
+		_, output, err := we.Extract(newRules(false))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output["title"] != "Static" {
+			t.Fatalf("got %v, want %v", output["title"], "Static")
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		rules := newRules(true)
+		rules.Method = "POST"
+		if _, err := we.Do(rules); !errors.Is(err, ErrMethodNotSupported) {
+			t.Fatalf("got %v, want %v", err, ErrMethodNotSupported)
+		}
+
+		rules = newRules(true)
+		rules.URL = mustNewURL(ts.URL + "/error")
+		if _, err := we.Do(rules); (err == nil) || (err.Error() != "net::ERR_NAME_NOT_RESOLVED") {
+			t.Fatalf("got %v, want %v", err, "net::ERR_NAME_NOT_RESOLVED")
+		}
+	})
+
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	if (fb.created != 2) || (len(fb.contexts) != 0) {
+		t.Fatalf("got %v contexts created and %v not disposed, want %v and %v", fb.created, len(fb.contexts), 2, 0)
+	}
+}
+
+func mustNewURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,65 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// Response represents a page rendered by the browser.
+// The status code and the header are the ones of the response of the document,
+// the body is the document after executing its JavaScript.
+// See the colibri.Response interface.
+type Response struct {
+	u          *url.URL
+	statusCode int
+	header     http.Header
+	body       []byte
+	c          *colibri.Colibri
+	ctx        context.Context
+}
+
+// URL returns the URL of the page after the redirects, including the ones made with JavaScript.
+func (resp *Response) URL() *url.URL {
+	return resp.u
+}
+
+func (resp *Response) StatusCode() int {
+	return resp.statusCode
+}
+
+func (resp *Response) Header() http.Header {
+	return resp.header
+}
+
+func (resp *Response) Body() io.ReadCloser {
+	return io.NopCloser(bytes.NewReader(resp.body))
+}
+
+// Context returns the context of the request used to obtain the response.
+func (resp *Response) Context() context.Context {
+	if resp.ctx == nil {
+		return context.Background()
+	}
+	return resp.ctx
+}
+
+// Do Colibri DoContext method wrapper, the context of the response is used.
+func (resp *Response) Do(rules *colibri.Rules) (colibri.Response, error) {
+	return resp.c.DoContext(resp.Context(), rules)
+}
+
+// Extract Colibri ExtractContext method wrapper, the context of the response is used.
+func (resp *Response) Extract(rules *colibri.Rules) (colibri.Response, map[string]any, error) {
+	return resp.c.ExtractContext(resp.Context(), rules)
+}
+
+// splitLines returns the lines of the string.
+func splitLines(s string) []string {
+	return strings.Split(s, "\n")
+}
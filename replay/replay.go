@@ -0,0 +1,282 @@
+// replay records the requests and the responses of Colibri in cassettes and serves them back,
+// so the selectors can be developed and tested offline against captured pages.
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+// ErrNotRecorded is returned by the Player when the cassette does not have the request.
+var ErrNotRecorded = errors.New("request not recorded")
+
+// Interaction is a request and the response obtained.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request, the requests are matched by method and URL.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	// URL of the response, after following the redirects.
+	URL string `json:"url"`
+
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+
+	// Body is the body of the response if it is valid UTF-8, otherwise BodyBase64
+	// is the body encoded in base64.
+	Body       string `json:"body,omitempty"`
+	BodyBase64 string `json:"bodyBase64,omitempty"`
+}
+
+// body returns the decoded body of the response.
+func (resp *Response) body() ([]byte, error) {
+	if resp.BodyBase64 != "" {
+		return base64.StdEncoding.DecodeString(resp.BodyBase64)
+	}
+	return []byte(resp.Body), nil
+}
+
+// setBody stores the body of the response.
+func (resp *Response) setBody(b []byte) {
+	if utf8.Valid(b) {
+		resp.Body = string(b)
+		return
+	}
+	resp.BodyBase64 = base64.StdEncoding.EncodeToString(b)
+}
+
+// Cassette stores the interactions recorded by a Recorder and served by a Player.
+// It is encoded in JSON, see Load and Save.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+
+	mu     sync.Mutex
+	played map[Request]int
+}
+
+// NewCassette returns a new empty Cassette.
+func NewCassette() *Cassette {
+	return &Cassette{}
+}
+
+// Load returns the Cassette stored in the file.
+func Load(name string) (*Cassette, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cassette := NewCassette()
+	if err := json.Unmarshal(b, cassette); err != nil {
+		return nil, err
+	}
+	return cassette, nil
+}
+
+// Save stores the Cassette in the file.
+func (cassette *Cassette) Save(name string) error {
+	cassette.mu.Lock()
+	b, err := json.MarshalIndent(cassette, "", "\t")
+	cassette.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, b, 0o644)
+}
+
+// Add adds the interaction to the Cassette.
+func (cassette *Cassette) Add(interaction *Interaction) {
+	cassette.mu.Lock()
+	cassette.Interactions = append(cassette.Interactions, interaction)
+	cassette.mu.Unlock()
+}
+
+// Find returns the response recorded for the request. The responses of a request
+// recorded several times are returned in the order in which they were recorded,
+// the last one is returned again once all of them were returned.
+func (cassette *Cassette) Find(req Request) (*Response, bool) {
+	req.Method = normalizeMethod(req.Method)
+
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+
+	var matches []*Interaction
+	for _, interaction := range cassette.Interactions {
+		if (normalizeMethod(interaction.Request.Method) == req.Method) && (interaction.Request.URL == req.URL) {
+			matches = append(matches, interaction)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	if cassette.played == nil {
+		cassette.played = make(map[Request]int)
+	}
+
+	i := min(cassette.played[req], len(matches)-1)
+	cassette.played[req]++
+	return &matches[i].Response, true
+}
+
+// Len returns the number of interactions.
+func (cassette *Cassette) Len() int {
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+	return len(cassette.Interactions)
+}
+
+// Rewind serves the responses again from the first one recorded, see Find.
+func (cassette *Cassette) Rewind() {
+	cassette.mu.Lock()
+	clear(cassette.played)
+	cassette.mu.Unlock()
+}
+
+// Record replaces the Client of Colibri with a Recorder that records the interactions in the Cassette.
+func (cassette *Cassette) Record(c *colibri.Colibri) {
+	if c.Client != nil {
+		c.Client = &Recorder{HTTPClient: c.Client, Cassette: cassette}
+	}
+}
+
+// Replay replaces the Client of Colibri with a Player that serves the interactions of the Cassette.
+func (cassette *Cassette) Replay(c *colibri.Colibri) {
+	c.Client = &Player{Cassette: cassette}
+}
+
+// Recorder records the requests of the wrapped HTTPClient and their responses in the Cassette.
+// The bodies of the responses are read to be recorded. The failed requests are not recorded.
+// See the colibri.HTTPClient interface.
+type Recorder struct {
+	colibri.HTTPClient
+	Cassette *Cassette
+}
+
+func (recorder *Recorder) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	return recorder.DoContext(context.Background(), c, rules)
+}
+
+// DoContext calls DoContext of the wrapped HTTPClient if it implements
+// colibri.HTTPClientContext, otherwise Do is called. See colibri.ClientDo.
+func (recorder *Recorder) DoContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	resp, err := colibri.ClientDo(ctx, recorder.HTTPClient, c, rules)
+
+	if (err != nil) || (resp == nil) {
+		return resp, err
+	}
+
+	var b []byte
+	if body := resp.Body(); body != nil {
+		b, err = io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	interaction := &Interaction{
+		Request:  Request{Method: normalizeMethod(rules.Method), URL: rules.URL.String()},
+		Response: Response{URL: urlString(resp.URL()), StatusCode: resp.StatusCode(), Header: resp.Header().Clone()},
+	}
+	interaction.Response.setBody(b)
+	recorder.Cassette.Add(interaction)
+
+	if r, ok := resp.(*webextractor.Response); ok {
+		r.HTTP.Body = io.NopCloser(bytes.NewReader(b))
+		return r, nil
+	}
+	return colibri.NewResponseContext(ctx, resp.URL(), resp.StatusCode(), resp.Header(), bytes.NewReader(b), c), nil
+}
+
+// ReportCapabilities adds the "record" feature and the capabilities of the wrapped HTTPClient to the report.
+// See the colibri.CapabilityReporter interface.
+func (recorder *Recorder) ReportCapabilities(caps *colibri.Capabilities) {
+	colibri.ReportCapabilitiesOf(recorder.HTTPClient, caps)
+	caps.Features = append(caps.Features, "record")
+}
+
+// Player serves the responses recorded in the Cassette without making requests,
+// the requests that were not recorded return ErrNotRecorded.
+// See the colibri.HTTPClient interface.
+type Player struct {
+	Cassette *Cassette
+}
+
+func (player *Player) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	return player.DoContext(context.Background(), c, rules)
+}
+
+// DoContext returns the response recorded for the method and the URL of the rules.
+func (player *Player) DoContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	recorded, ok := player.Cassette.Find(Request{Method: rules.Method, URL: rules.URL.String()})
+	if !ok {
+		return nil, ErrNotRecorded
+	}
+
+	b, err := recorded.body()
+	if err != nil {
+		return nil, err
+	}
+
+	u := rules.URL
+	if recorded.URL != "" {
+		if u, err = url.Parse(recorded.URL); err != nil {
+			return nil, err
+		}
+	}
+	return colibri.NewResponseContext(ctx, u, recorded.StatusCode, recorded.Header.Clone(), bytes.NewReader(b), c), nil
+}
+
+// Clear serves the responses again from the first one recorded, see Cassette.Rewind.
+func (player *Player) Clear() {
+	player.Cassette.Rewind()
+}
+
+// ReportCapabilities adds the "replay" feature to the report.
+// See the colibri.CapabilityReporter interface.
+func (player *Player) ReportCapabilities(caps *colibri.Capabilities) {
+	caps.Features = append(caps.Features, "replay")
+}
+
+// normalizeMethod returns the method in upper case, GET if it is empty.
+func normalizeMethod(method string) string {
+	if method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(method)
+}
+
+// urlString returns the URL as a string, empty if it is nil.
+func urlString(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
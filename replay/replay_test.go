@@ -0,0 +1,138 @@
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+func TestReplay(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><title>%s</title></head><body><a href="/b">b</a></body></html>`, r.URL.Path)
+	}))
+
+	newRules := func() *colibri.Rules {
+		links := &colibri.Selector{Name: "links", Expr: "//a/@href", All: true, Follow: true}
+		links.Selectors = []*colibri.Selector{{Name: "title", Expr: "//title"}}
+
+		return &colibri.Rules{
+			Method:          "GET",
+			URL:             mustNewURL(ts.URL + "/a"),
+			IgnoreRobotsTxt: true,
+			Selectors:       []*colibri.Selector{{Name: "title", Expr: "//title"}, links},
+		}
+	}
+
+	c, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Delay = nil // Deactivate Delay
+
+	cassette := NewCassette()
+	cassette.Record(c)
+
+	_, want, err := c.Extract(newRules())
+	if err != nil {
+		t.Fatal(err)
+	} else if want["title"] != "/a" {
+		t.Fatalf("got %v, want %v", want["title"], "/a")
+	} else if cassette.Len() != 2 {
+		t.Fatalf("got %v, want %v", cassette.Len(), 2)
+	}
+
+	name := filepath.Join(t.TempDir(), "cassette.json")
+	if err := cassette.Save(name); err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+
+	loaded, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Replay(c)
+
+	_, got, err := c.Extract(newRules())
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	} else if requests != 2 {
+		t.Fatalf("got %v, want %v", requests, 2)
+	}
+
+	rules := newRules()
+	rules.URL = mustNewURL(ts.URL + "/c")
+	if _, _, err := c.Extract(rules); !errors.Is(err, ErrNotRecorded) {
+		t.Fatalf("got %v, want %v", err, ErrNotRecorded)
+	}
+}
+
+func TestCassetteFind(t *testing.T) {
+	cassette := NewCassette()
+	for _, statusCode := range []int{http.StatusServiceUnavailable, http.StatusOK} {
+		cassette.Add(&Interaction{
+			Request:  Request{Method: "GET", URL: "https://example.com"},
+			Response: Response{StatusCode: statusCode},
+		})
+	}
+
+	req := Request{Method: "get", URL: "https://example.com"}
+	for _, want := range []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusOK} {
+		resp, ok := cassette.Find(req)
+		if !ok {
+			t.Fatal("response expected")
+		} else if resp.StatusCode != want {
+			t.Fatalf("got %v, want %v", resp.StatusCode, want)
+		}
+	}
+
+	cassette.Rewind()
+	if resp, _ := cassette.Find(req); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got %v, want %v", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	if _, ok := cassette.Find(Request{Method: "POST", URL: "https://example.com"}); ok {
+		t.Fatal("no response expected")
+	}
+}
+
+func TestResponseBody(t *testing.T) {
+	tests := [][]byte{
+		[]byte("<title>Test</title>"),
+		{0xff, 0xfe, 0x00},
+		nil,
+	}
+
+	for _, tt := range tests {
+		var resp Response
+		resp.setBody(tt)
+
+		got, err := resp.body()
+		if err != nil {
+			t.Fatal(err)
+		} else if string(got) != string(tt) {
+			t.Fatalf("got %v, want %v", got, tt)
+		}
+	}
+}
+
+func mustNewURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,76 @@
+package colibri
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// response is the Response returned by NewResponse.
+type response struct {
+	u          *url.URL
+	statusCode int
+	header     http.Header
+	body       io.ReadCloser
+	c          *Colibri
+	ctx        context.Context
+}
+
+// NewResponse returns a Response with the URL, the status code, the header and the body,
+// e.g. a response received from a message queue, stored in a cache or written in a test.
+// If the body is not an io.ReadCloser, it is closed without effect. A nil header or body is empty.
+// Do and Extract use the Colibri c, they return ErrColibriIsNil if it is nil.
+func NewResponse(u *url.URL, statusCode int, header http.Header, body io.Reader, c *Colibri) Response {
+	return NewResponseContext(context.Background(), u, statusCode, header, body, c)
+}
+
+// NewResponseContext is like NewResponse, the context is used by Do and Extract,
+// e.g. the context with the trace ID of the request, see WithTraceID.
+func NewResponseContext(ctx context.Context, u *url.URL, statusCode int, header http.Header, body io.Reader, c *Colibri) Response {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if header == nil {
+		header = http.Header{}
+	}
+
+	var rc io.ReadCloser
+	switch b := body.(type) {
+	case nil:
+		rc = http.NoBody
+	case io.ReadCloser:
+		rc = b
+	default:
+		rc = io.NopCloser(b)
+	}
+
+	return &response{u: u, statusCode: statusCode, header: header, body: rc, c: c, ctx: ctx}
+}
+
+func (resp *response) URL() *url.URL       { return resp.u }
+func (resp *response) StatusCode() int     { return resp.statusCode }
+func (resp *response) Header() http.Header { return resp.header }
+func (resp *response) Body() io.ReadCloser { return resp.body }
+
+// Context returns the context of the response, see NewResponseContext.
+func (resp *response) Context() context.Context {
+	return resp.ctx
+}
+
+// Do Colibri DoContext method wrapper, the context of the response is used.
+func (resp *response) Do(rules *Rules) (Response, error) {
+	if resp.c == nil {
+		return nil, ErrColibriIsNil
+	}
+	return resp.c.DoContext(resp.ctx, rules)
+}
+
+// Extract Colibri ExtractContext method wrapper, the context of the response is used.
+func (resp *response) Extract(rules *Rules) (Response, map[string]any, error) {
+	if resp.c == nil {
+		return nil, nil, ErrColibriIsNil
+	}
+	return resp.c.ExtractContext(resp.ctx, rules)
+}
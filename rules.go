@@ -6,30 +6,73 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"regexp"
+	"slices"
 	"sync"
 	"time"
 )
 
 const (
+	// KeyAllow is the key of the regular expressions of the URLs that can be followed, see Rules.Allow.
+	KeyAllow = "Allow"
+
 	KeyDelay = "Delay"
 
+	// KeyDeny is the key of the regular expressions of the URLs that are not followed, see Rules.Deny.
+	KeyDeny = "Deny"
+
+	KeyDisableCompression = "DisableCompression"
+
 	KeyFields = "Fields"
 
+	KeyFollowConcurrency = "FollowConcurrency"
+
 	KeyHeader = "Header"
 
 	KeyIgnoreRobotsTxt = "IgnoreRobotsTxt"
 
+	KeyMaxBodySize = "MaxBodySize"
+
+	KeyMaxDepth = "MaxDepth"
+
+	KeyMaxPages = "MaxPages"
+
+	KeyMaxRequests = "MaxRequests"
+
+	KeyMaxWait = "MaxWait"
+
 	KeyMethod = "Method"
 
+	KeyParseTimeout = "ParseTimeout"
+
 	KeyProxy = "Proxy"
 
+	KeyRender = "Render"
+
+	KeyRenderWait = "RenderWait"
+
+	KeyRetries = "Retries"
+
+	KeyRetryBackoff = "RetryBackoff"
+
+	KeyRetryOn = "RetryOn"
+
 	KeySelectors = "Selectors"
 
+	KeySession = "Session"
+
 	KeyTimeout = "Timeout"
 
+	// KeyTLS is the key of the TLS configuration of the requests, see Rules.TLS.
+	KeyTLS = "TLS"
+
 	KeyUseCookies = "UseCookies"
 
 	KeyURL = "URL"
+
+	KeyVariants = "Variants"
+
+	KeyVars = "Vars"
 )
 
 // ErrNotAssignable is returned when the value of RawRules cannot be assigned to the structure field.
@@ -57,9 +100,21 @@ type Rules struct {
 	// Header contains the HTTP header.
 	Header http.Header
 
-	// Timeout specifies the time limit for the HTTP request.
+	// TLS configures the TLS connections of the requests, e.g. custom root CAs
+	// or a client certificate. If nil, the configuration of the HTTPClient is used.
+	TLS *TLS
+
+	// Timeout specifies the time limit for the HTTP request,
+	// including the redirects and the reading of the response body.
 	Timeout time.Duration
 
+	// ParseTimeout specifies the time limit for parsing the response,
+	// including the requests of the Follow selectors. Zero means no limit.
+	// The parser can not be interrupted, an abandoned parse continues in the background
+	// and reads the rules, so after ErrParseTimeout or the cancellation of the context
+	// the rules must not be reused, modified or released.
+	ParseTimeout time.Duration
+
 	// UseCookies specifies whether the client should send and store Cookies.
 	UseCookies bool
 
@@ -69,11 +124,84 @@ type Rules struct {
 	// Delay specifies the delay time between requests.
 	Delay time.Duration
 
+	// Retries specifies the maximum number of times the HTTP request is retried
+	// when it fails or the status code of the response is in RetryOn.
+	Retries int
+
+	// RetryBackoff specifies the base delay between retries,
+	// the delay grows exponentially with each retry.
+	RetryBackoff time.Duration
+
+	// RetryOn specifies the status codes of the responses that are retried.
+	RetryOn []int
+
+	// MaxWait specifies the maximum total time waited for the Retry-After header of the
+	// 429 (Too Many Requests) and 503 (Service Unavailable) responses, which are retried
+	// after it. Zero means the Retry-After header is not waited for.
+	MaxWait time.Duration
+
+	// DisableCompression specifies whether the compressed response bodies should not be requested
+	// and decoded, the body is returned as sent by the server.
+	DisableCompression bool
+
+	// MaxBodySize specifies the maximum number of bytes of the response body,
+	// reading more bytes returns an error. Zero means no limit.
+	MaxBodySize int64
+
+	// MaxPages specifies the maximum number of pages extracted following the links to the
+	// next page (Link header or link elements with rel="next"), the outputs of the pages
+	// are concatenated. Zero means that only the requested page is extracted.
+	MaxPages int
+
+	// MaxDepth specifies the maximum number of nested follows from the requested page,
+	// the URLs found deeper return ErrDepthExceeded instead of being requested.
+	// Zero means no limit.
+	MaxDepth int
+
+	// MaxRequests specifies the maximum number of requests of an extraction, including
+	// the requests of the Follow selectors and of the next pages, the requests beyond it
+	// return ErrBudgetExceeded. Zero means no limit.
+	MaxRequests int
+
+	// FollowConcurrency specifies the maximum number of URLs of a Follow selector
+	// requested concurrently, the results keep the order of the URLs.
+	// Zero or one means that the URLs are requested one by one.
+	FollowConcurrency int
+
+	// Render specifies whether the page should be rendered executing its JavaScript,
+	// the HTTPClient must support it, see the render package.
+	Render bool
+
+	// RenderWait specifies the time to wait after the page is loaded
+	// before getting the rendered content.
+	RenderWait time.Duration
+
+	// Allow specifies the regular expressions of the URLs that the Follow selectors can follow,
+	// if not empty the URLs that do not match any of them are not requested. See AllowsURL.
+	Allow []*regexp.Regexp
+
+	// Deny specifies the regular expressions of the URLs that the Follow selectors do not follow,
+	// it has priority over Allow.
+	Deny []*regexp.Regexp
+
+	// Session identifies the session of the request.
+	// Follow requests of a session use the proxy and the cookies of the session,
+	// the Proxy and UseCookies of the selectors are ignored.
+	Session string
+
 	// Selectors
 	Selectors []*Selector
 
+	// Variants alternative selectors for the layouts of the pages, see Variant.
+	Variants []*Variant
+
+	// Vars declares the variables of the crawl, see Vars.
+	Vars *Vars
+
 	// Fields stores additional data.
 	Fields map[string]any
+
+	released bool
 }
 
 // NewRules returns the rules processed using DefaultConvFunc.
@@ -82,24 +210,59 @@ func NewRules(rawRules RawRules) (*Rules, error) {
 }
 
 // NewRulesWithConvFunc returns the processed rules.
+// The raw rules are upgraded to RulesVersion with DefaultMigrator, see KeyVersion.
 func NewRulesWithConvFunc(rawRules RawRules, convFunc ConvFunc) (*Rules, error) {
-	newRules := rulesPool.Get().(*Rules)
-	err := processRaw(rawRules, newRules, convFunc)
+	newRules := getRules()
+
+	migrated, err := DefaultMigrator.Migrate(rawRules)
+	if err != nil {
+		return newRules, AddError(nil, KeyVersion, err)
+	}
+
+	if _, ok := migrated[KeyVersion]; ok {
+		rawRules = make(RawRules, len(migrated))
+		for key, value := range migrated {
+			if key != KeyVersion {
+				rawRules[key] = value
+			}
+		}
+	}
+
+	err = processRaw(rawRules, newRules, convFunc)
 	return newRules, err
 }
 
-// Clone returns a copy of the original rules.
+// Clone returns a copy of the original rules, the copy shares the Vars.
 // Cloning the Fields field may produce errors, avoid storing pointer.
 func (rules *Rules) Clone() *Rules {
 	newRules := &Rules{
-		Method:          rules.Method,
-		Header:          rules.Header.Clone(),
-		Timeout:         rules.Timeout,
-		UseCookies:      rules.UseCookies,
-		IgnoreRobotsTxt: rules.IgnoreRobotsTxt,
-		Delay:           rules.Delay,
-		Selectors:       CloneSelectors(rules.Selectors),
-		Fields:          make(map[string]any),
+		Method:             rules.Method,
+		Header:             rules.Header.Clone(),
+		TLS:                rules.TLS.Clone(),
+		Timeout:            rules.Timeout,
+		ParseTimeout:       rules.ParseTimeout,
+		UseCookies:         rules.UseCookies,
+		IgnoreRobotsTxt:    rules.IgnoreRobotsTxt,
+		Delay:              rules.Delay,
+		Retries:            rules.Retries,
+		RetryBackoff:       rules.RetryBackoff,
+		RetryOn:            slices.Clone(rules.RetryOn),
+		MaxWait:            rules.MaxWait,
+		DisableCompression: rules.DisableCompression,
+		MaxBodySize:        rules.MaxBodySize,
+		MaxPages:           rules.MaxPages,
+		MaxDepth:           rules.MaxDepth,
+		MaxRequests:        rules.MaxRequests,
+		FollowConcurrency:  rules.FollowConcurrency,
+		Render:             rules.Render,
+		RenderWait:         rules.RenderWait,
+		Allow:              slices.Clone(rules.Allow),
+		Deny:               slices.Clone(rules.Deny),
+		Session:            rules.Session,
+		Selectors:          CloneSelectors(rules.Selectors),
+		Variants:           CloneVariants(rules.Variants),
+		Vars:               rules.Vars,
+		Fields:             make(map[string]any),
 	}
 
 	if rules.URL != nil {
@@ -123,16 +286,35 @@ func (rules *Rules) Clear() {
 	rules.URL = nil
 	rules.Proxy = nil
 	rules.Header = nil
+	rules.TLS = nil
 	rules.Timeout = 0
+	rules.ParseTimeout = 0
 
 	rules.UseCookies = false
 	rules.IgnoreRobotsTxt = false
 	rules.Delay = 0
+	rules.Retries = 0
+	rules.RetryBackoff = 0
+	rules.RetryOn = nil
+	rules.MaxWait = 0
+	rules.DisableCompression = false
+	rules.MaxBodySize = 0
+	rules.MaxPages = 0
+	rules.MaxDepth = 0
+	rules.MaxRequests = 0
+	rules.FollowConcurrency = 0
+	rules.Render = false
+	rules.RenderWait = 0
+	rules.Allow = nil
+	rules.Deny = nil
+	rules.Session = ""
 
 	for _, sel := range rules.Selectors {
 		ReleaseSelector(sel)
 	}
 	rules.Selectors = nil
+	rules.Variants = nil
+	rules.Vars = nil
 
 	clear(rules.Fields)
 }
@@ -152,6 +334,129 @@ func (rules *Rules) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// MarshalJSON encodes the rules with the format of the raw rules, see Raw.
+func (rules *Rules) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rules.Raw())
+}
+
+// Raw returns the raw rules that NewRules converts into rules equal to the original.
+// The URLs and the durations are formatted as strings and the Fields are stored
+// as keys of the raw rules. The zero values are omitted and Version is RulesVersion.
+func (rules *Rules) Raw() RawRules {
+	raw := make(RawRules)
+	for key, value := range rules.Fields {
+		raw[key] = rawValue(value)
+	}
+
+	raw[KeyVersion] = RulesVersion
+	setRaw(raw, KeyMethod, rules.Method, rules.Method != "")
+	setRaw(raw, KeyURL, rules.URL, rules.URL != nil)
+	setRaw(raw, KeyProxy, rules.Proxy, rules.Proxy != nil)
+	setRaw(raw, KeyHeader, rules.Header, len(rules.Header) > 0)
+	setRaw(raw, KeyTLS, rules.TLS, rules.TLS != nil)
+	setRaw(raw, KeyTimeout, rules.Timeout, rules.Timeout != 0)
+	setRaw(raw, KeyParseTimeout, rules.ParseTimeout, rules.ParseTimeout != 0)
+	setRaw(raw, KeyUseCookies, rules.UseCookies, rules.UseCookies)
+	setRaw(raw, KeyIgnoreRobotsTxt, rules.IgnoreRobotsTxt, rules.IgnoreRobotsTxt)
+	setRaw(raw, KeyDelay, rules.Delay, rules.Delay != 0)
+	setRaw(raw, KeyRetries, rules.Retries, rules.Retries != 0)
+	setRaw(raw, KeyRetryBackoff, rules.RetryBackoff, rules.RetryBackoff != 0)
+	setRaw(raw, KeyRetryOn, rules.RetryOn, len(rules.RetryOn) > 0)
+	setRaw(raw, KeyMaxWait, rules.MaxWait, rules.MaxWait != 0)
+	setRaw(raw, KeyDisableCompression, rules.DisableCompression, rules.DisableCompression)
+	setRaw(raw, KeyMaxBodySize, rules.MaxBodySize, rules.MaxBodySize != 0)
+	setRaw(raw, KeyMaxPages, rules.MaxPages, rules.MaxPages != 0)
+	setRaw(raw, KeyMaxDepth, rules.MaxDepth, rules.MaxDepth != 0)
+	setRaw(raw, KeyMaxRequests, rules.MaxRequests, rules.MaxRequests != 0)
+	setRaw(raw, KeyFollowConcurrency, rules.FollowConcurrency, rules.FollowConcurrency != 0)
+	setRaw(raw, KeyRender, rules.Render, rules.Render)
+	setRaw(raw, KeyRenderWait, rules.RenderWait, rules.RenderWait != 0)
+	setRaw(raw, KeyAllow, rules.Allow, len(rules.Allow) > 0)
+	setRaw(raw, KeyDeny, rules.Deny, len(rules.Deny) > 0)
+	setRaw(raw, KeySession, rules.Session, rules.Session != "")
+	setRaw(raw, KeySelectors, rules.Selectors, len(rules.Selectors) > 0)
+	setRaw(raw, KeyVariants, rules.Variants, len(rules.Variants) > 0)
+	setRaw(raw, KeyVars, rules.Vars, rules.Vars != nil)
+	return raw
+}
+
+// setRaw stores the raw value in the raw rules if ok is true.
+func setRaw(raw map[string]any, key string, value any, ok bool) {
+	if ok {
+		raw[key] = rawValue(value)
+	}
+}
+
+// rawValue converts the value to the format of the raw rules.
+func rawValue(value any) any {
+	switch v := value.(type) {
+	case *url.URL:
+		return v.String()
+
+	case time.Duration:
+		return v.String()
+
+	case []*regexp.Regexp:
+		patterns := make([]any, 0, len(v))
+		for _, re := range v {
+			patterns = append(patterns, re.String())
+		}
+		return patterns
+
+	case *TLS:
+		return v.raw()
+
+	case http.Header:
+		header := make(map[string]any, len(v))
+		for key, values := range v {
+			header[key] = slices.Clone(values)
+		}
+		return header
+
+	case []Pipe:
+		pipes := make([]any, 0, len(v))
+		for _, pipe := range v {
+			if len(pipe.Args) == 0 {
+				pipes = append(pipes, pipe.Name)
+				continue
+			}
+
+			raw := []any{pipe.Name}
+			for _, arg := range pipe.Args {
+				raw = append(raw, arg)
+			}
+			pipes = append(pipes, raw)
+		}
+		return pipes
+
+	case []*Selector:
+		selectors := make(map[string]any, len(v))
+		for _, selector := range v {
+			if selector != nil {
+				selectors[selector.Name] = selector.raw()
+			}
+		}
+		return selectors
+
+	case *Vars:
+		values := make(map[string]any)
+		for name, value := range v.Values() {
+			values[name] = value
+		}
+		return values
+
+	case []*Variant:
+		variants := make([]any, 0, len(v))
+		for _, variant := range v {
+			if variant != nil {
+				variants = append(variants, variant.raw())
+			}
+		}
+		return variants
+	}
+	return value
+}
+
 func processRaw[T Rules | Selector](raw map[string]any, output *T, convFunc ConvFunc) error {
 	if raw == nil {
 		return nil
@@ -187,18 +492,31 @@ func processRaw[T Rules | Selector](raw map[string]any, output *T, convFunc Conv
 		// Fields
 		field = rOutput.Elem().FieldByName(KeyFields)
 		if field.IsValid() && field.CanSet() && (field.Kind() == reflect.Map) {
+			value, err := convertField(key, value)
+			if err != nil {
+				errs = AddError(errs, key, err)
+				continue
+			}
+
 			if field.IsNil() {
 				field.Set(reflect.MakeMap(field.Type()))
 			}
 
-			field.SetMapIndex(reflect.ValueOf(key), rValue)
+			field.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
 		}
 	}
 	return errs
 }
 
 // ReleaseRules clears and sends the rules to the rules pool.
+// The rules must not be used after being released, the second release is ignored, see SetPoolDebug.
 func ReleaseRules(rules *Rules) {
+	if (rules == nil) || !markReleased(&rules.released) {
+		return
+	}
+
 	rules.Clear()
-	rulesPool.Put(rules)
+	if !poolDebug.Load() {
+		rulesPool.Put(rules)
+	}
 }
@@ -0,0 +1,181 @@
+// rulestore stores rule definitions loaded from a Source and reloads them when they change,
+// so the crawls started after the change use the new rules without restarting the process.
+package rulestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// DefaultInterval default time between each load of the Source.
+const DefaultInterval = 30 * time.Second
+
+var (
+	// ErrSourceIsNil is returned when the Source is nil.
+	ErrSourceIsNil = errors.New("Source is nil")
+
+	// ErrNotModified is returned by the sources when the definitions have not changed
+	// since the last load, e.g. with HTTP conditional requests.
+	ErrNotModified = errors.New("not modified")
+)
+
+// Source loads the rule definitions.
+// The definitions are a JSON object with the names of the rules as keys
+// and the raw rules as values, see colibri.Rules.UnmarshalJSON.
+// File and HTTP are provided, other stores (e.g. etcd) can implement the interface.
+type Source interface {
+	// Load returns the definitions or ErrNotModified.
+	Load(ctx context.Context) ([]byte, error)
+}
+
+// Store stores the rules loaded from the Source.
+type Store struct {
+	// Source of the definitions.
+	Source Source
+
+	// Interval time between each load of the Source in Watch.
+	// If zero, DefaultInterval is used.
+	Interval time.Duration
+
+	// OnReload is called by Watch after each load that changes the rules or fails,
+	// including the first load.
+	OnReload func(err error)
+
+	rw    sync.RWMutex
+	rules map[string]*colibri.Rules
+	hash  [sha256.Size]byte
+}
+
+// New returns a new Store with the Source.
+func New(source Source) *Store {
+	return &Store{Source: source}
+}
+
+// Load loads the definitions from the Source, returns true if the rules changed.
+// If a definition is invalid, the stored rules are not modified and the errors
+// are returned with the names of the rules as keys, see colibri.AddError.
+func (store *Store) Load(ctx context.Context) (bool, error) {
+	if store.Source == nil {
+		return false, ErrSourceIsNil
+	}
+
+	data, err := store.Source.Load(ctx)
+	if errors.Is(err, ErrNotModified) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	hash := sha256.Sum256(data)
+
+	store.rw.RLock()
+	unchanged := (store.rules != nil) && (hash == store.hash)
+	store.rw.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	rules, err := parse(data)
+	if err != nil {
+		return false, err
+	}
+
+	store.rw.Lock()
+	store.rules, store.hash = rules, hash
+	store.rw.Unlock()
+	return true, nil
+}
+
+// Watch loads the definitions every Interval until the context is cancelled.
+// The first load is done immediately, its error is returned.
+func (store *Store) Watch(ctx context.Context) error {
+	changed, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if changed && (store.OnReload != nil) {
+		store.OnReload(nil)
+	}
+
+	interval := store.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-ticker.C:
+			changed, err := store.Load(ctx)
+			if (changed || (err != nil)) && (store.OnReload != nil) && (ctx.Err() == nil) {
+				store.OnReload(err)
+			}
+		}
+	}
+}
+
+// Get returns a copy of the rules with the name, so each crawl uses
+// the rules loaded when it starts.
+func (store *Store) Get(name string) (*colibri.Rules, bool) {
+	store.rw.RLock()
+	defer store.rw.RUnlock()
+
+	rules, ok := store.rules[name]
+	if !ok {
+		return nil, false
+	}
+	return rules.Clone(), true
+}
+
+// Names returns the sorted names of the rules.
+func (store *Store) Names() []string {
+	store.rw.RLock()
+	defer store.rw.RUnlock()
+
+	names := make([]string, 0, len(store.rules))
+	for name := range store.rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parse parses the definitions.
+func parse(data []byte) (map[string]*colibri.Rules, error) {
+	var definitions map[string]json.RawMessage
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		return nil, err
+	}
+
+	var (
+		result = make(map[string]*colibri.Rules, len(definitions))
+		errs   error
+	)
+	for name, definition := range definitions {
+		rules := &colibri.Rules{}
+		if err := rules.UnmarshalJSON(definition); err != nil {
+			errs = colibri.AddError(errs, name, err)
+			continue
+		}
+		result[name] = rules
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+	return result, nil
+}
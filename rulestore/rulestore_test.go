@@ -0,0 +1,174 @@
+package rulestore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+const (
+	testDefinitions = `{
+		"title": {"URL": "https://example.com", "Selectors": {"title": "//title"}},
+		"links": {"URL": "https://example.com", "Selectors": {"links": {"Expr": "//a/@href", "All": true}}}
+	}`
+
+	testDefinitions2 = `{
+		"title": {"URL": "https://example.org", "Selectors": {"title": "//title"}}
+	}`
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := New(&File{Path: path})
+	ctx := context.Background()
+
+	write(testDefinitions)
+	if changed, err := store.Load(ctx); err != nil {
+		t.Fatal(err)
+	} else if !changed {
+		t.Fatal("not changed")
+	}
+
+	if names := store.Names(); !reflect.DeepEqual(names, []string{"links", "title"}) {
+		t.Fatalf("got %v, want %v", names, []string{"links", "title"})
+	}
+
+	rules, ok := store.Get("title")
+	if !ok || (rules.URL.String() != "https://example.com") {
+		t.Fatalf("got %v, want %v", rules, "https://example.com")
+	}
+
+	// the rules returned are copies
+	rules.URL = nil
+	if rules, _ := store.Get("title"); rules.URL == nil {
+		t.Fatal("stored rules modified")
+	}
+
+	if changed, err := store.Load(ctx); err != nil {
+		t.Fatal(err)
+	} else if changed {
+		t.Fatal("changed")
+	}
+
+	// invalid definitions do not replace the rules
+	write(`{"title": {"URL": 123}}`)
+	if _, err := store.Load(ctx); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := err.(*colibri.Errs).Get("title"); !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, ok := store.Get("links"); !ok {
+		t.Fatal("rules removed")
+	}
+
+	write(testDefinitions2)
+	if changed, err := store.Load(ctx); (err != nil) || !changed {
+		t.Fatalf("got %v %v, want %v", changed, err, true)
+	}
+
+	if _, ok := store.Get("links"); ok {
+		t.Fatal("rules not removed")
+	} else if rules, _ := store.Get("title"); rules.URL.String() != "https://example.org" {
+		t.Fatalf("got %v, want %v", rules.URL, "https://example.org")
+	}
+
+	if _, err := New(nil).Load(ctx); !errors.Is(err, ErrSourceIsNil) {
+		t.Fatalf("got %v, want %v", err, ErrSourceIsNil)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(testDefinitions), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloads := make(chan error, 1)
+	store := New(&File{Path: path})
+	store.Interval = 5 * time.Millisecond
+	store.OnReload = func(err error) { reloads <- err }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- store.Watch(ctx) }()
+
+	// first load
+	if err := <-reloads; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(testDefinitions2), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		if rules, _ := store.Get("title"); rules.URL.String() == "https://example.org" {
+			break
+		}
+
+		select {
+		case err := <-reloads:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-timeout:
+			t.Fatal("not reloaded")
+		}
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testDefinitions))
+	}))
+	defer ts.Close()
+
+	store := New(&HTTP{URL: ts.URL})
+	for i, want := range []bool{true, false} {
+		changed, err := store.Load(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		} else if changed != want {
+			t.Fatalf("load %d: got %v, want %v", i, changed, want)
+		}
+	}
+
+	if requests != 2 {
+		t.Fatalf("got %v, want %v", requests, 2)
+	}
+
+	if _, ok := store.Get("links"); !ok {
+		t.Fatal("rules not found")
+	}
+}
@@ -0,0 +1,81 @@
+package rulestore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// File loads the definitions from a file.
+type File struct {
+	Path string
+}
+
+func (file *File) Load(_ context.Context) ([]byte, error) {
+	return os.ReadFile(file.Path)
+}
+
+// HTTP loads the definitions from a URL with GET requests.
+// The ETag of the response is sent in the If-None-Match header of the next request,
+// so the definitions are not downloaded again if they have not changed.
+type HTTP struct {
+	URL    string
+	Header http.Header
+
+	// Client used to send the requests, if nil http.DefaultClient is used.
+	Client *http.Client
+
+	mu   sync.Mutex
+	etag string
+}
+
+func (source *HTTP) Load(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range source.Header {
+		req.Header[key] = values
+	}
+
+	source.mu.Lock()
+	etag := source.etag
+	source.mu.Unlock()
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	client := source.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
+
+	if (resp.StatusCode < 200) || (resp.StatusCode > 299) {
+		return nil, fmt.Errorf("rulestore: unexpected status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	source.mu.Lock()
+	source.etag = resp.Header.Get("ETag")
+	source.mu.Unlock()
+	return data, nil
+}
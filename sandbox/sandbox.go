@@ -0,0 +1,314 @@
+// sandbox restricts the requests made by Colibri to run rules supplied by users safely.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/parsers"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+const (
+	// DefaultMaxDepth default maximum number of nested follows.
+	DefaultMaxDepth = 3
+
+	// DefaultMaxPages default maximum number of requests.
+	DefaultMaxPages = 100
+
+	// DefaultMaxBytes default maximum number of bytes read from the bodies of the responses.
+	DefaultMaxBytes = 50 << 20
+)
+
+var (
+	// ErrColibriIsNil is returned when Colibri is nil.
+	ErrColibriIsNil = errors.New("Colibri is nil")
+
+	// ErrClientIsNil is returned when the Client of Colibri is nil.
+	ErrClientIsNil = errors.New("Client is nil")
+
+	// ErrRobotsTxtRequired is returned when the RobotsTxt of Colibri is nil.
+	ErrRobotsTxtRequired = errors.New("RobotsTxt is required")
+
+	// ErrSchemeNotAllowed is returned when the scheme of the URL is not http or https.
+	ErrSchemeNotAllowed = errors.New("URL scheme not allowed")
+
+	// ErrDomainNotAllowed is returned when the host of the URL is not an allowed domain.
+	ErrDomainNotAllowed = errors.New("domain not allowed")
+
+	// ErrProxyNotAllowed is returned when the rules specify a proxy.
+	ErrProxyNotAllowed = errors.New("proxy not allowed")
+
+	// ErrIgnoreRobotsTxt is returned when the rules ignore robots.txt.
+	ErrIgnoreRobotsTxt = errors.New("robots.txt cannot be ignored")
+
+	// ErrDepthLimit is returned when the number of nested follows exceeds MaxDepth.
+	ErrDepthLimit = errors.New("follow depth limit exceeded")
+
+	// ErrPageLimit is returned when the number of requests exceeds MaxPages.
+	ErrPageLimit = errors.New("page limit exceeded")
+
+	// ErrByteLimit is returned when the bytes read from the responses exceed MaxBytes.
+	ErrByteLimit = errors.New("byte limit exceeded")
+
+	// ErrClientNotEnforced is returned when the private addresses are not allowed
+	// and the Client is not a *webextractor.Client, see Profile.AllowPrivateIPs.
+	ErrClientNotEnforced = errors.New("Client cannot block the private addresses")
+)
+
+// Profile specifies the restrictions of the requests.
+type Profile struct {
+	// AllowedDomains specifies the domains that can be requested, including their subdomains.
+	// If empty, all domains are allowed.
+	AllowedDomains []string
+
+	// MaxDepth specifies the maximum number of nested follows.
+	// Zero means no limit.
+	MaxDepth int
+
+	// MaxPages specifies the maximum number of requests, including robots.txt.
+	// Zero means no limit.
+	MaxPages int
+
+	// MaxBytes specifies the maximum number of bytes read from the bodies of the responses.
+	// Zero means no limit.
+	MaxBytes int64
+
+	// AllowProxy specifies whether the rules can specify a proxy.
+	AllowProxy bool
+
+	// AllowPrivateIPs specifies whether private, loopback, link-local
+	// and reserved addresses can be requested, see webextractor.IsPublicAddr.
+	// The addresses are only blocked by a *webextractor.Client, which verifies the address
+	// of each connection, so the other clients (e.g. render.Client, whose browser loads
+	// the subresources and follows the redirects itself) require AllowPrivateIPs.
+	AllowPrivateIPs bool
+}
+
+// DefaultProfile returns a Profile with the default limits that allows the domains.
+func DefaultProfile(allowedDomains ...string) *Profile {
+	return &Profile{
+		AllowedDomains: allowedDomains,
+		MaxDepth:       DefaultMaxDepth,
+		MaxPages:       DefaultMaxPages,
+		MaxBytes:       DefaultMaxBytes,
+	}
+}
+
+// Wrap replaces the Client of Colibri with a Client that applies the restrictions of the Profile.
+// Only http and https URLs can be requested and robots.txt cannot be ignored, so Colibri must have a RobotsTxt.
+// If the Client is a *webextractor.Client, its CheckRedirect is set to verify the redirects
+// and BlockPrivateIPs is enabled unless AllowPrivateIPs is true. The other clients
+// return ErrClientNotEnforced unless AllowPrivateIPs is true, because a check of the host
+// before the request does not cover the DNS rebinding, the redirects or the subresources.
+// The limits are shared by all the requests made with Colibri, see Client.Reset.
+func (profile *Profile) Wrap(c *colibri.Colibri) error {
+	if c == nil {
+		return ErrColibriIsNil
+	}
+
+	if c.Client == nil {
+		return ErrClientIsNil
+	}
+
+	if c.RobotsTxt == nil {
+		return ErrRobotsTxtRequired
+	}
+
+	client, ok := c.Client.(*webextractor.Client)
+	if !ok && !profile.AllowPrivateIPs {
+		return ErrClientNotEnforced
+	}
+
+	if ok {
+		if !profile.AllowPrivateIPs {
+			client.BlockPrivateIPs = true
+		}
+
+		next := client.CheckRedirect
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if err := profile.checkURL(req.URL); err != nil {
+				return err
+			}
+
+			if next != nil {
+				return next(req, via)
+			}
+			return nil
+		}
+	}
+
+	c.Client = &Client{HTTPClient: c.Client, Profile: profile}
+	return nil
+}
+
+// Client is an HTTP client that applies the restrictions of the Profile.
+// See the colibri.HTTPClient interface.
+type Client struct {
+	colibri.HTTPClient
+	Profile *Profile
+
+	pages atomic.Int64
+	bytes atomic.Int64
+}
+
+func (client *Client) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	return client.DoContext(context.Background(), c, rules)
+}
+
+// DoContext verifies the rules before making the request.
+// See the colibri.HTTPClientContext interface.
+func (client *Client) DoContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	if err := client.check(rules); err != nil {
+		return nil, err
+	}
+
+	if _, ok := client.HTTPClient.(*webextractor.Client); !ok && !client.Profile.AllowPrivateIPs {
+		return nil, ErrClientNotEnforced
+	}
+
+	resp, err := colibri.ClientDo(ctx, client.HTTPClient, c, rules)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Profile.checkURL(resp.URL()); err != nil {
+		resp.Body().Close()
+		return nil, err
+	}
+	return client.limitBody(resp), nil
+}
+
+// Clear resets the limits and clears the wrapped Client.
+func (client *Client) Clear() {
+	client.Reset()
+	client.HTTPClient.Clear()
+}
+
+// Reset resets the number of pages requested and the bytes read.
+func (client *Client) Reset() {
+	client.pages.Store(0)
+	client.bytes.Store(0)
+}
+
+// Pages returns the number of requests made.
+func (client *Client) Pages() int {
+	return int(client.pages.Load())
+}
+
+// Bytes returns the number of bytes read from the bodies of the responses.
+func (client *Client) Bytes() int64 {
+	return client.bytes.Load()
+}
+
+// ReportCapabilities adds the "sandbox" feature to the report.
+// See the colibri.CapabilityReporter interface.
+func (client *Client) ReportCapabilities(caps *colibri.Capabilities) {
+	colibri.ReportCapabilitiesOf(client.HTTPClient, caps)
+	caps.Features = append(caps.Features, "sandbox")
+}
+
+// check verifies the rules and counts the request.
+func (client *Client) check(rules *colibri.Rules) error {
+	profile := client.Profile
+
+	if err := profile.checkURL(rules.URL); err != nil {
+		return err
+	}
+
+	if (rules.Proxy != nil) && !profile.AllowProxy {
+		return ErrProxyNotAllowed
+	}
+
+	if rules.IgnoreRobotsTxt && (rules.URL.Path != "/robots.txt") {
+		return ErrIgnoreRobotsTxt
+	}
+
+	branch, _ := rules.Fields[parsers.KeyFollowBranch].([]string)
+	if (profile.MaxDepth > 0) && (len(branch) > profile.MaxDepth) {
+		return ErrDepthLimit
+	}
+
+	if (profile.MaxPages > 0) && (client.pages.Add(1) > int64(profile.MaxPages)) {
+		return ErrPageLimit
+	}
+
+	if (profile.MaxBytes > 0) && (client.bytes.Load() >= profile.MaxBytes) {
+		return ErrByteLimit
+	}
+	return nil
+}
+
+// limitBody replaces the body of the response with a body that counts the bytes read.
+func (client *Client) limitBody(resp colibri.Response) colibri.Response {
+	if r, ok := resp.(*webextractor.Response); ok {
+		r.HTTP.Body = &limitedBody{ReadCloser: r.HTTP.Body, client: client}
+		return r
+	}
+	return &limitedResponse{Response: resp, body: &limitedBody{ReadCloser: resp.Body(), client: client}}
+}
+
+// checkURL verifies the scheme and the domain of the URL.
+func (profile *Profile) checkURL(u *url.URL) error {
+	if u == nil {
+		return ErrSchemeNotAllowed
+	}
+
+	if scheme := strings.ToLower(u.Scheme); (scheme != "http") && (scheme != "https") {
+		return ErrSchemeNotAllowed
+	}
+
+	if len(profile.AllowedDomains) == 0 {
+		return nil
+	}
+
+	host := strings.TrimSuffix(colibri.NormalizeHost(u.Hostname()), ".")
+	for _, domain := range profile.AllowedDomains {
+		domain = strings.TrimSuffix(colibri.NormalizeHost(domain), ".")
+		if (host == domain) || strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+	}
+	return ErrDomainNotAllowed
+}
+
+// limitedResponse is a response whose body counts the bytes read.
+type limitedResponse struct {
+	colibri.Response
+	body io.ReadCloser
+}
+
+func (resp *limitedResponse) Body() io.ReadCloser {
+	return resp.body
+}
+
+// Context returns the context of the wrapped response, nil if it has none.
+func (resp *limitedResponse) Context() context.Context {
+	if r, ok := resp.Response.(interface{ Context() context.Context }); ok {
+		return r.Context()
+	}
+	return nil
+}
+
+// limitedBody counts the bytes read, returns ErrByteLimit when MaxBytes is exceeded.
+type limitedBody struct {
+	io.ReadCloser
+	client *Client
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+
+	maxBytes := body.client.Profile.MaxBytes
+	if (body.client.bytes.Add(int64(n)) > maxBytes) && (maxBytes > 0) {
+		return n, ErrByteLimit
+	}
+	return n, err
+}
@@ -0,0 +1,178 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/parsers"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+func mustNewURL(rawURL string) *url.URL {
+	u, _ := url.Parse(rawURL)
+	return u
+}
+
+func TestProfile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			http.NotFound(w, r)
+		case "/redirect":
+			http.Redirect(w, r, "http://example.com/", http.StatusFound)
+		case "/large":
+			io.WriteString(w, strings.Repeat("a", 1024))
+		default:
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer ts.Close()
+
+	c, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := DefaultProfile(mustNewURL(ts.URL).Hostname())
+	profile.MaxPages = 6
+	profile.MaxBytes = 512
+	profile.AllowPrivateIPs = true
+	if err := profile.Wrap(c); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Rules *colibri.Rules
+		Want  error
+	}{
+		{&colibri.Rules{URL: mustNewURL("file:///etc/passwd")}, ErrSchemeNotAllowed},
+		{&colibri.Rules{URL: mustNewURL("data:text/plain,hello")}, ErrSchemeNotAllowed},
+		{&colibri.Rules{URL: mustNewURL("http://example.com/")}, ErrDomainNotAllowed},
+		{&colibri.Rules{URL: mustNewURL(ts.URL), Proxy: mustNewURL("http://proxy.test")}, ErrProxyNotAllowed},
+		{&colibri.Rules{URL: mustNewURL(ts.URL), IgnoreRobotsTxt: true}, ErrIgnoreRobotsTxt},
+		{
+			&colibri.Rules{URL: mustNewURL(ts.URL), Fields: map[string]any{parsers.KeyFollowBranch: []string{"1", "2", "3", "4"}}},
+			ErrDepthLimit,
+		},
+		{&colibri.Rules{URL: mustNewURL(ts.URL)}, nil}, // robots.txt + page
+		{&colibri.Rules{URL: mustNewURL(ts.URL + "/redirect")}, ErrDomainNotAllowed},
+		{&colibri.Rules{URL: mustNewURL(ts.URL + "/large")}, ErrByteLimit},
+		{&colibri.Rules{URL: mustNewURL(ts.URL)}, ErrByteLimit},
+	}
+
+	for _, tt := range tests {
+		resp, err := c.Do(tt.Rules)
+		if err == nil {
+			_, err = io.ReadAll(resp.Body())
+		}
+
+		if !errors.Is(err, tt.Want) {
+			t.Fatalf("%v: got %v, want %v", tt.Rules.URL, err, tt.Want)
+		}
+	}
+
+	client := c.Client.(*Client)
+	if got := client.Pages(); got != 5 {
+		t.Fatalf("got %v, want %v", got, 5)
+	}
+
+	client.Reset()
+	for i := 0; i < profile.MaxPages; i++ {
+		if _, err := c.Do(&colibri.Rules{URL: mustNewURL(ts.URL)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := c.Do(&colibri.Rules{URL: mustNewURL(ts.URL)}); !errors.Is(err, ErrPageLimit) {
+		t.Fatalf("got %v, want %v", err, ErrPageLimit)
+	}
+}
+
+func TestProfileBlockPrivateIPs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	c, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DefaultProfile().Wrap(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do(&colibri.Rules{URL: mustNewURL(ts.URL)}); !errors.Is(err, webextractor.ErrBlockedAddress) {
+		t.Fatalf("got %v, want %v", err, webextractor.ErrBlockedAddress)
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	c, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RobotsTxt = nil
+
+	// the context of the response is forwarded
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	profile := DefaultProfile()
+	profile.AllowPrivateIPs = true
+
+	client := &Client{HTTPClient: c.Client, Profile: profile}
+	resp, err := client.DoContext(ctx, c, &colibri.Rules{URL: mustNewURL(ts.URL)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, ok := resp.(interface{ Context() context.Context })
+	if !ok {
+		t.Fatal("Context not implemented")
+	} else if got := r.Context().Value(ctxKey{}); got != "value" {
+		t.Fatalf("got %v, want %v", got, "value")
+	}
+}
+
+// testClient does not make requests, like render.Client it can not block the private addresses.
+type testClient struct{}
+
+func (client *testClient) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (client *testClient) Clear() {}
+
+func TestProfileOtherClient(t *testing.T) {
+	c, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Client = &testClient{}
+
+	// the private addresses can not be blocked
+	if err := DefaultProfile().Wrap(c); !errors.Is(err, ErrClientNotEnforced) {
+		t.Fatalf("got %v, want %v", err, ErrClientNotEnforced)
+	}
+
+	client := &Client{HTTPClient: &testClient{}, Profile: DefaultProfile()}
+	if _, err := client.Do(c, &colibri.Rules{URL: mustNewURL("https://example.com/")}); !errors.Is(err, ErrClientNotEnforced) {
+		t.Fatalf("got %v, want %v", err, ErrClientNotEnforced)
+	}
+
+	profile := DefaultProfile()
+	profile.AllowPrivateIPs = true
+	if err := profile.Wrap(c); err != nil {
+		t.Fatal(err)
+	}
+}
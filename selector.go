@@ -4,6 +4,9 @@ import (
 	"errors"
 	"net/http"
 	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
 	"sync"
 	"time"
 )
@@ -17,7 +20,30 @@ const (
 
 	KeyName = "Name"
 
+	// KeyNext is the key of the Fields of a selector that finds the URL of the next page,
+	// true to use the value found instead of the links with rel="next" when the rules
+	// have MaxPages. Only the selectors of the first level are used.
+	KeyNext = "Next"
+
+	KeyPipes = "Pipes"
+
+	KeyRequired = "Required"
+
+	// KeySkipIf is the key of the Fields of a selector with the conditions to skip it,
+	// a map of the names of the selectors of the same level to the values that skip the selector,
+	// or to a list of values. The selector is skipped if any of the selectors found one of the values,
+	// so a Follow selector does not request pages known to be irrelevant, e.g. {"out_of_stock": true}.
+	// The values are compared formatted with fmt.Sprint and nil matches the values not found.
+	// The selectors with conditions are evaluated after the other selectors of the same level.
+	KeySkipIf = "SkipIf"
+
 	KeyType = "Type"
+
+	// KeyVar is the key of the Fields of a selector with the name of the variable
+	// in which the value found is stored, see Vars. The selectors that set
+	// variables are evaluated before the other selectors of the same level.
+	// The values of the All and Follow selectors and the values not found are not stored.
+	KeyVar = "Var"
 )
 
 var (
@@ -50,16 +76,41 @@ type Selector struct {
 	// Follow specifies whether the URLs found by the selector should be followed.
 	Follow bool
 
+	// Required specifies whether the selector must find an element,
+	// if it finds nothing the parse returns an error with the name of the selector.
+	Required bool
+
+	// Allow regular expressions of the URLs that the Follow selector can follow,
+	// they are checked in addition to the Allow of the Rules, see AllowsURL.
+	Allow []*regexp.Regexp
+
+	// Deny regular expressions of the URLs that the Follow selector does not follow.
+	Deny []*regexp.Regexp
+
 	// Selectors nested selectors.
 	Selectors []*Selector
 
+	// Pipes operations applied in order to the values found by the selector,
+	// e.g. trim, lower or toInt. The values of the nested selectors are not modified.
+	Pipes []Pipe
+
 	// Fields stores additional data.
 	Fields map[string]any
+
+	released bool
+}
+
+// Pipe is an operation applied to the values found by a selector.
+// The raw pipes are the name of the operation or a list with the name and the arguments,
+// e.g. "trim" or ["replace", "old", "new"].
+type Pipe struct {
+	Name string
+	Args []string
 }
 
 func newSelector(name string, rawSelector any, convFunc ConvFunc) (*Selector, error) {
 	var (
-		selector = selectorPool.Get().(*Selector)
+		selector = getSelector()
 		err      error
 	)
 
@@ -115,14 +166,47 @@ func newSelectors(rawSelectors any, convFunc ConvFunc) ([]*Selector, error) {
 // Copies the nested selectors from the Selector and
 // gets the rest of the data from Fields, if they are
 // not in Fields it uses the data from the source Rules.
+// The Allow and Deny of the source Rules are always used.
+// If the source Rules has a Session, the Proxy and UseCookies
+// of the source Rules are always used.
+// The MaxPages of the source Rules is not used, the pages
+// following the URLs are only extracted if Fields has MaxPages.
+// The values of Fields are converted with DefaultConvFunc,
+// invalid values are ignored, see RulesWithConvFunc.
 func (selector *Selector) Rules(src *Rules) *Rules {
+	newRules, _ := selector.RulesWithConvFunc(src, DefaultConvFunc)
+	return newRules
+}
+
+// RulesWithConvFunc returns a Rules with the Selector data like Rules,
+// the values of Fields are converted with the ConvFunc.
+// Returns the Rules and the errors of the values that could not be converted,
+// the invalid values are ignored.
+func (selector *Selector) RulesWithConvFunc(src *Rules, convFunc ConvFunc) (*Rules, error) {
 	newRules := &Rules{
-		Timeout:         src.Timeout,
-		UseCookies:      src.UseCookies,
-		IgnoreRobotsTxt: src.IgnoreRobotsTxt,
-		Delay:           src.Delay,
-		Selectors:       CloneSelectors(selector.Selectors),
-		Fields:          make(map[string]any),
+		Timeout:            src.Timeout,
+		ParseTimeout:       src.ParseTimeout,
+		UseCookies:         src.UseCookies,
+		IgnoreRobotsTxt:    src.IgnoreRobotsTxt,
+		Delay:              src.Delay,
+		Retries:            src.Retries,
+		RetryBackoff:       src.RetryBackoff,
+		RetryOn:            slices.Clone(src.RetryOn),
+		MaxWait:            src.MaxWait,
+		DisableCompression: src.DisableCompression,
+		MaxBodySize:        src.MaxBodySize,
+		MaxDepth:           src.MaxDepth,
+		MaxRequests:        src.MaxRequests,
+		FollowConcurrency:  src.FollowConcurrency,
+		Render:             src.Render,
+		RenderWait:         src.RenderWait,
+		TLS:                src.TLS.Clone(),
+		Allow:              slices.Clone(src.Allow),
+		Deny:               slices.Clone(src.Deny),
+		Session:            src.Session,
+		Selectors:          CloneSelectors(selector.Selectors),
+		Vars:               src.Vars,
+		Fields:             make(map[string]any),
 	}
 
 	if len(selector.Fields) == 0 {
@@ -131,49 +215,167 @@ func (selector *Selector) Rules(src *Rules) *Rules {
 		}
 		newRules.Header = src.Header.Clone()
 
-		return newRules
+		return newRules, nil
+	}
+
+	var errs error
+	field := func(key string, zero any) (any, bool) {
+		v, ok := selector.Fields[key]
+		if !ok || (convFunc == nil) || (reflect.TypeOf(v) == reflect.TypeOf(zero)) {
+			return v, ok
+		}
+
+		v, err := convFunc(key, v)
+		if err != nil {
+			errs = AddError(errs, key, err)
+			return nil, false
+		}
+		return v, true
+	}
+
+	assign := func(key string, ok bool) {
+		if !ok {
+			errs = AddError(errs, key, ErrNotAssignable)
+		}
 	}
 
 	// METHOD
-	if v, ok := selector.Fields[KeyMethod]; ok {
-		newRules.Method, _ = v.(string)
+	if v, ok := field(KeyMethod, ""); ok {
+		newRules.Method, ok = v.(string)
+		assign(KeyMethod, ok)
 	}
 
 	// PROXY
-	if v, ok := selector.Fields[KeyProxy]; ok {
-		newRules.Proxy, _ = v.(*url.URL)
+	if v, ok := field(KeyProxy, (*url.URL)(nil)); ok && (src.Session == "") {
+		newRules.Proxy, ok = v.(*url.URL)
+		assign(KeyProxy, ok)
 	} else if src.Proxy != nil {
 		newRules.Proxy = src.Proxy.ResolveReference(&url.URL{})
 	}
 
 	// HEADER
-	if v, ok := selector.Fields[KeyHeader]; ok {
-		newRules.Header, _ = v.(http.Header)
+	if v, ok := field(KeyHeader, http.Header(nil)); ok {
+		newRules.Header, ok = v.(http.Header)
+		assign(KeyHeader, ok)
 	} else {
 		newRules.Header = src.Header.Clone()
 	}
 
+	// TLS
+	if v, ok := field(KeyTLS, (*TLS)(nil)); ok {
+		newRules.TLS, ok = v.(*TLS)
+		assign(KeyTLS, ok)
+	}
+
 	// TIMEOUT
-	if v, ok := selector.Fields[KeyTimeout]; ok {
-		newRules.Timeout, _ = v.(time.Duration)
+	if v, ok := field(KeyTimeout, time.Duration(0)); ok {
+		newRules.Timeout, ok = v.(time.Duration)
+		assign(KeyTimeout, ok)
+	}
+
+	// PARSETIMEOUT
+	if v, ok := field(KeyParseTimeout, time.Duration(0)); ok {
+		newRules.ParseTimeout, ok = v.(time.Duration)
+		assign(KeyParseTimeout, ok)
 	}
 
 	// USECOOKIES
-	if v, ok := selector.Fields[KeyUseCookies]; ok {
-		newRules.UseCookies, _ = v.(bool)
+	if v, ok := field(KeyUseCookies, false); ok && (src.Session == "") {
+		newRules.UseCookies, ok = v.(bool)
+		assign(KeyUseCookies, ok)
 	}
 
 	// IGNOREROBOTSTXT
-	if v, ok := selector.Fields[KeyIgnoreRobotsTxt]; ok {
-		newRules.IgnoreRobotsTxt, _ = v.(bool)
+	if v, ok := field(KeyIgnoreRobotsTxt, false); ok {
+		newRules.IgnoreRobotsTxt, ok = v.(bool)
+		assign(KeyIgnoreRobotsTxt, ok)
 	}
 
 	// DELAY
-	if v, ok := selector.Fields[KeyDelay]; ok {
-		newRules.Delay, _ = v.(time.Duration)
+	if v, ok := field(KeyDelay, time.Duration(0)); ok {
+		newRules.Delay, ok = v.(time.Duration)
+		assign(KeyDelay, ok)
 	}
 
-	return newRules
+	// RETRIES
+	if v, ok := field(KeyRetries, 0); ok {
+		newRules.Retries, ok = v.(int)
+		assign(KeyRetries, ok)
+	}
+
+	// RETRYBACKOFF
+	if v, ok := field(KeyRetryBackoff, time.Duration(0)); ok {
+		newRules.RetryBackoff, ok = v.(time.Duration)
+		assign(KeyRetryBackoff, ok)
+	}
+
+	// RETRYON
+	if v, ok := field(KeyRetryOn, []int(nil)); ok {
+		newRules.RetryOn, ok = v.([]int)
+		assign(KeyRetryOn, ok)
+	}
+
+	// MAXWAIT
+	if v, ok := field(KeyMaxWait, time.Duration(0)); ok {
+		newRules.MaxWait, ok = v.(time.Duration)
+		assign(KeyMaxWait, ok)
+	}
+
+	// DISABLECOMPRESSION
+	if v, ok := field(KeyDisableCompression, false); ok {
+		newRules.DisableCompression, ok = v.(bool)
+		assign(KeyDisableCompression, ok)
+	}
+
+	// MAXBODYSIZE
+	if v, ok := field(KeyMaxBodySize, int64(0)); ok {
+		newRules.MaxBodySize, ok = v.(int64)
+		assign(KeyMaxBodySize, ok)
+	}
+
+	// MAXPAGES
+	if v, ok := field(KeyMaxPages, 0); ok {
+		newRules.MaxPages, ok = v.(int)
+		assign(KeyMaxPages, ok)
+	}
+
+	// MAXDEPTH
+	if v, ok := field(KeyMaxDepth, 0); ok {
+		newRules.MaxDepth, ok = v.(int)
+		assign(KeyMaxDepth, ok)
+	}
+
+	// MAXREQUESTS
+	if v, ok := field(KeyMaxRequests, 0); ok {
+		newRules.MaxRequests, ok = v.(int)
+		assign(KeyMaxRequests, ok)
+	}
+
+	// FOLLOWCONCURRENCY
+	if v, ok := field(KeyFollowConcurrency, 0); ok {
+		newRules.FollowConcurrency, ok = v.(int)
+		assign(KeyFollowConcurrency, ok)
+	}
+
+	// RENDER
+	if v, ok := field(KeyRender, false); ok {
+		newRules.Render, ok = v.(bool)
+		assign(KeyRender, ok)
+	}
+
+	// RENDERWAIT
+	if v, ok := field(KeyRenderWait, time.Duration(0)); ok {
+		newRules.RenderWait, ok = v.(time.Duration)
+		assign(KeyRenderWait, ok)
+	}
+
+	// VARIANTS
+	if v, ok := field(KeyVariants, []*Variant(nil)); ok {
+		newRules.Variants, ok = v.([]*Variant)
+		assign(KeyVariants, ok)
+	}
+
+	return newRules, errs
 }
 
 // Clone returns a copy of the original selector.
@@ -185,7 +387,11 @@ func (selector *Selector) Clone() *Selector {
 		Type:      selector.Type,
 		All:       selector.All,
 		Follow:    selector.Follow,
+		Required:  selector.Required,
+		Allow:     slices.Clone(selector.Allow),
+		Deny:      slices.Clone(selector.Deny),
 		Selectors: CloneSelectors(selector.Selectors),
+		Pipes:     ClonePipes(selector.Pipes),
 		Fields:    make(map[string]any),
 	}
 
@@ -203,11 +409,15 @@ func (selector *Selector) Clear() {
 	selector.Type = ""
 	selector.All = false
 	selector.Follow = false
+	selector.Required = false
+	selector.Allow = nil
+	selector.Deny = nil
 
 	for _, sel := range selector.Selectors {
 		ReleaseSelector(sel)
 	}
 	selector.Selectors = nil
+	selector.Pipes = nil
 
 	clear(selector.Fields)
 }
@@ -221,8 +431,43 @@ func CloneSelectors(selectors []*Selector) []*Selector {
 	return result
 }
 
-// ReleaseRules clears and sends the selector to the selector pool.
+// ClonePipes clones the pipes.
+func ClonePipes(pipes []Pipe) []Pipe {
+	var result []Pipe
+	for _, pipe := range pipes {
+		result = append(result, Pipe{Name: pipe.Name, Args: slices.Clone(pipe.Args)})
+	}
+	return result
+}
+
+// raw returns the raw selector, see Rules.Raw.
+func (selector *Selector) raw() map[string]any {
+	raw := make(map[string]any)
+	for key, value := range selector.Fields {
+		raw[key] = rawValue(value)
+	}
+
+	raw[KeyExpr] = selector.Expr
+	setRaw(raw, KeyType, selector.Type, selector.Type != "")
+	setRaw(raw, KeyAll, selector.All, selector.All)
+	setRaw(raw, KeyFollow, selector.Follow, selector.Follow)
+	setRaw(raw, KeyRequired, selector.Required, selector.Required)
+	setRaw(raw, KeyAllow, selector.Allow, len(selector.Allow) > 0)
+	setRaw(raw, KeyDeny, selector.Deny, len(selector.Deny) > 0)
+	setRaw(raw, KeySelectors, selector.Selectors, len(selector.Selectors) > 0)
+	setRaw(raw, KeyPipes, selector.Pipes, len(selector.Pipes) > 0)
+	return raw
+}
+
+// ReleaseSelector clears and sends the selector to the selector pool.
+// The selector must not be used after being released, the second release is ignored, see SetPoolDebug.
 func ReleaseSelector(selector *Selector) {
+	if (selector == nil) || !markReleased(&selector.released) {
+		return
+	}
+
 	selector.Clear()
-	selectorPool.Put(selector)
+	if !poolDebug.Load() {
+		selectorPool.Put(selector)
+	}
 }
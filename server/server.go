@@ -0,0 +1,190 @@
+// server exposes the extractions of Colibri over HTTP, the raw rules are posted in JSON
+// and the data extracted is returned in JSON, so Colibri can be used from other languages.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+const (
+	// DefaultMaxConcurrency default maximum number of extractions run concurrently.
+	DefaultMaxConcurrency = 10
+
+	// DefaultTimeout default maximum duration of each extraction.
+	DefaultTimeout = time.Minute
+
+	// DefaultMaxBodySize default maximum size in bytes of the raw rules posted.
+	DefaultMaxBodySize = 1 << 20
+)
+
+// ErrBusy is returned when the extraction does not start before the timeout
+// because MaxConcurrency extractions are running.
+var ErrBusy = errors.New("too many concurrent extractions")
+
+// Result is the JSON document returned for each extraction.
+type Result struct {
+	// URL of the response, empty if the request failed.
+	URL string `json:"url,omitempty"`
+
+	// StatusCode of the response, zero if the request failed.
+	StatusCode int `json:"statusCode,omitempty"`
+
+	// Output is the data extracted with the selectors.
+	Output map[string]any `json:"output,omitempty"`
+
+	// Errors are the errors of the rules or of the selectors, see colibri.Errs.Details.
+	Errors map[string]any `json:"errors,omitempty"`
+
+	// Error is the error of the extraction when it is not an Errs.
+	Error *colibri.ErrorDetail `json:"error,omitempty"`
+}
+
+// Server is an http.Handler that extracts the data of the raw rules posted in JSON with Colibri.
+// The extractions whose request failed are answered with the status code of the category
+// of the error, e.g. 400 (Bad Request) for invalid rules or 502 (Bad Gateway) for network errors,
+// the other extractions with 200 (OK) and the errors of the selectors, if any.
+type Server struct {
+	// Colibri used for the extractions.
+	Colibri *colibri.Colibri
+
+	// MaxConcurrency maximum number of extractions run concurrently, the others wait
+	// until one ends or their timeout is exceeded. If zero, DefaultMaxConcurrency is used.
+	MaxConcurrency int
+
+	// Timeout maximum duration of each extraction, including the wait for the concurrency limit.
+	// If zero, DefaultTimeout is used.
+	Timeout time.Duration
+
+	// MaxBodySize maximum size in bytes of the raw rules posted.
+	// If zero, DefaultMaxBodySize is used.
+	MaxBodySize int64
+
+	once sync.Once
+	sem  chan struct{}
+}
+
+// New returns a new Server that extracts with Colibri.
+func New(c *colibri.Colibri) *Server {
+	return &Server{Colibri: c}
+}
+
+// ServeHTTP extracts the data of the raw rules of the body of the POST request.
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+		return
+	}
+
+	maxBodySize := server.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+
+	var rawRules colibri.RawRules
+	if err := json.Unmarshal(b, &rawRules); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	timeout := server.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	if err := server.acquire(ctx); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer server.release()
+
+	resp, output, err := server.Colibri.ExtractRawContext(ctx, rawRules)
+
+	result := &Result{Output: output}
+	if resp != nil {
+		if resp.URL() != nil {
+			result.URL = resp.URL().String()
+		}
+		result.StatusCode = resp.StatusCode()
+	}
+
+	if errs, ok := err.(*colibri.Errs); ok {
+		result.Errors = errs.Details()
+	} else if err != nil {
+		detail := colibri.DetailOf(err)
+		result.Error = &detail
+	}
+
+	statusCode := http.StatusOK
+	if (resp == nil) && (err != nil) {
+		statusCode = errorStatusCode(err)
+	}
+	writeJSON(w, statusCode, result)
+}
+
+// acquire waits until an extraction can be run, returns ErrBusy if the context is done before.
+func (server *Server) acquire(ctx context.Context) error {
+	server.once.Do(func() {
+		n := server.MaxConcurrency
+		if n <= 0 {
+			n = DefaultMaxConcurrency
+		}
+		server.sem = make(chan struct{}, n)
+	})
+
+	select {
+	case server.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ErrBusy
+	}
+}
+
+func (server *Server) release() {
+	<-server.sem
+}
+
+// errorStatusCode returns the status code of the failed extraction by the category of the error.
+func errorStatusCode(err error) int {
+	code, category := colibri.ErrorCode(err)
+	switch {
+	case code == colibri.CodeTimeout:
+		return http.StatusGatewayTimeout
+	case category == colibri.CategoryRules:
+		return http.StatusBadRequest
+	case (category == colibri.CategoryPolicy) || (category == colibri.CategoryWarning):
+		return http.StatusForbidden
+	case category == colibri.CategoryNetwork:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError writes the result with the error.
+func writeError(w http.ResponseWriter, statusCode int, err error) {
+	detail := colibri.DetailOf(err)
+	writeJSON(w, statusCode, &Result{Error: &detail})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+func TestServer(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head></html>", r.URL.Path)
+	}))
+	defer target.Close()
+
+	c, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Delay = nil // Deactivate Delay
+
+	server := New(c)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	rules := func(path string) string {
+		return fmt.Sprintf(`{"URL": %q, "IgnoreRobotsTxt": true, "Selectors": {"title": "//title"}}`, target.URL+path)
+	}
+
+	tests := []struct {
+		Method     string
+		Body       string
+		WantStatus int
+		WantCode   string
+		WantTitle  any
+	}{
+		{http.MethodPost, rules("/a"), http.StatusOK, "", "/a"},
+		{http.MethodGet, "", http.StatusMethodNotAllowed, colibri.CodeUnknown, nil},
+		{http.MethodPost, `{"URL":`, http.StatusBadRequest, colibri.CodeUnknown, nil},
+		{http.MethodPost, `{"URL": 1}`, http.StatusBadRequest, "", nil},
+		{http.MethodPost, `{"URL": "http://127.0.0.1:1", "IgnoreRobotsTxt": true}`, http.StatusBadGateway, colibri.CodeNetwork, nil},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.Method, ts.URL, strings.NewReader(tt.Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result Result
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != tt.WantStatus {
+			t.Fatalf("got %v, want %v", resp.StatusCode, tt.WantStatus)
+		} else if result.Output["title"] != tt.WantTitle {
+			t.Fatalf("got %v, want %v", result.Output["title"], tt.WantTitle)
+		} else if (tt.WantCode != "") && ((result.Error == nil) || (result.Error.Code != tt.WantCode)) {
+			t.Fatalf("got %v, want %v", result.Error, tt.WantCode)
+		}
+	}
+
+	// the extractions that do not start before the timeout are refused
+	limited := &Server{Colibri: c, MaxConcurrency: 1, Timeout: 50 * time.Millisecond}
+	if err := limited.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	limited.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(rules("/a"))))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+	limited.release()
+
+	rec = httptest.NewRecorder()
+	limited.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(rules("/slow"))))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("got %v, want %v", rec.Code, http.StatusGatewayTimeout)
+	}
+}
@@ -0,0 +1,212 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxSize maximum size of a sitemap after decompression, the limit of the sitemaps protocol.
+const MaxSize = 50 << 20
+
+// DefaultPriority priority of the URLs that do not specify it.
+const DefaultPriority = 0.5
+
+var (
+	// ErrNotSitemap is returned when the content is not a urlset, a sitemap index or a text sitemap.
+	ErrNotSitemap = errors.New("not a sitemap")
+
+	// ErrTooLarge is returned when the sitemap exceeds MaxSize.
+	ErrTooLarge = errors.New("sitemap too large")
+)
+
+// lastModLayouts W3C Datetime layouts of the lastmod values.
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// Entry is a URL listed in a sitemap.
+type Entry struct {
+	URL *url.URL
+
+	// LastMod date of the last modification of the page, zero if not specified.
+	LastMod time.Time
+
+	// ChangeFreq how frequently the page is likely to change, e.g. "daily", empty if not specified.
+	ChangeFreq string
+
+	// Priority of the URL relative to the other URLs of the site, from 0 to 1.
+	Priority float64
+}
+
+// Sitemap is the content of a sitemap.
+type Sitemap struct {
+	// Entries URLs of the urlset or the text sitemap.
+	Entries []Entry
+
+	// Sitemaps URLs of the sitemaps of the sitemap index.
+	Sitemaps []*url.URL
+}
+
+type xmlURLSet struct {
+	URLs []struct {
+		Loc        string `xml:"loc"`
+		LastMod    string `xml:"lastmod"`
+		ChangeFreq string `xml:"changefreq"`
+		Priority   string `xml:"priority"`
+	} `xml:"url"`
+}
+
+type xmlIndex struct {
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// Parse parses a sitemap: a urlset, a sitemap index or a text file with one URL per line.
+// The gzip compressed sitemaps are decompressed. The relative URLs are resolved with the
+// base URL (if not nil) and the invalid URLs are skipped.
+func Parse(r io.Reader, base *url.URL) (*Sitemap, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+
+		br = bufio.NewReader(gz)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(br, MaxSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, ErrNotSitemap
+	}
+
+	if trimmed[0] != '<' {
+		return parseText(trimmed, base), nil
+	}
+	return parseXML(data, base)
+}
+
+func parseXML(data []byte, base *url.URL) (*Sitemap, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return nil, ErrNotSitemap
+		} else if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		sitemap := &Sitemap{}
+		switch start.Name.Local {
+		case "urlset":
+			var set xmlURLSet
+			if err := dec.DecodeElement(&set, &start); err != nil {
+				return nil, err
+			}
+
+			for _, u := range set.URLs {
+				loc, ok := resolve(u.Loc, base)
+				if !ok {
+					continue
+				}
+
+				entry := Entry{
+					URL:        loc,
+					LastMod:    parseLastMod(u.LastMod),
+					ChangeFreq: strings.ToLower(strings.TrimSpace(u.ChangeFreq)),
+					Priority:   DefaultPriority,
+				}
+
+				if p, err := strconv.ParseFloat(strings.TrimSpace(u.Priority), 64); (err == nil) && (p >= 0) && (p <= 1) {
+					entry.Priority = p
+				}
+				sitemap.Entries = append(sitemap.Entries, entry)
+			}
+
+		case "sitemapindex":
+			var index xmlIndex
+			if err := dec.DecodeElement(&index, &start); err != nil {
+				return nil, err
+			}
+
+			for _, s := range index.Sitemaps {
+				if loc, ok := resolve(s.Loc, base); ok {
+					sitemap.Sitemaps = append(sitemap.Sitemaps, loc)
+				}
+			}
+
+		default:
+			return nil, ErrNotSitemap
+		}
+		return sitemap, nil
+	}
+}
+
+func parseText(data []byte, base *url.URL) *Sitemap {
+	sitemap := &Sitemap{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if loc, ok := resolve(line, base); ok {
+			sitemap.Entries = append(sitemap.Entries, Entry{URL: loc, Priority: DefaultPriority})
+		}
+	}
+	return sitemap
+}
+
+// resolve returns the http or https URL, resolved with the base URL if it is not nil.
+func resolve(rawURL string, base *url.URL) (*url.URL, bool) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return nil, false
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, false
+	}
+
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+
+	if (u.Scheme != "http") && (u.Scheme != "https") {
+		return nil, false
+	}
+	return u, true
+}
+
+// parseLastMod parses the W3C Datetime, returns the zero time if it is invalid.
+func parseLastMod(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
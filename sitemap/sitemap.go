@@ -0,0 +1,210 @@
+// sitemap discovers and parses the sitemaps of the websites (sitemap.xml and sitemap index files)
+// to seed the crawls with the URLs they list.
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/eduardogxnzalez/colibri"
+
+	"github.com/temoto/robotstxt"
+)
+
+const (
+	// DefaultPath path of the sitemap requested when robots.txt does not list any sitemap.
+	DefaultPath = "/sitemap.xml"
+
+	// DefaultMaxSitemaps default maximum number of sitemaps fetched by Fetch,
+	// including the sitemaps listed in the sitemap indexes.
+	DefaultMaxSitemaps = 100
+)
+
+// robotsTxtPath path of robots.txt.
+const robotsTxtPath = "/robots.txt"
+
+var (
+	// ErrColibriIsNil is returned when Colibri is nil.
+	ErrColibriIsNil = errors.New("Colibri is nil")
+
+	// ErrURLIsNil is returned when the URL of the rules is nil.
+	ErrURLIsNil = errors.New("URL is nil")
+)
+
+// Fetcher fetches the sitemaps with Colibri, so the requests use its Client,
+// respect robots.txt and wait the Delay like the rest of the crawl.
+type Fetcher struct {
+	// Colibri used to request the sitemaps.
+	Colibri *colibri.Colibri
+
+	// MaxSitemaps maximum number of sitemaps fetched by each call to Fetch.
+	// If zero, DefaultMaxSitemaps is used.
+	MaxSitemaps int
+}
+
+// New returns a new Fetcher.
+func New(c *colibri.Colibri) *Fetcher {
+	return &Fetcher{Colibri: c}
+}
+
+// Discover returns the URLs of the sitemaps listed in the robots.txt of the host of the URL
+// of the rules, or the URL of DefaultPath if robots.txt does not list any sitemap.
+// The request of robots.txt uses the rules, without selectors.
+func (fetcher *Fetcher) Discover(ctx context.Context, rules *colibri.Rules) ([]*url.URL, error) {
+	if fetcher.Colibri == nil {
+		return nil, ErrColibriIsNil
+	} else if rules.URL == nil {
+		return nil, ErrURLIsNil
+	}
+
+	robotsURL := rules.URL.ResolveReference(&url.URL{Path: robotsTxtPath})
+	data, statusCode, err := fetcher.get(ctx, rules, robotsURL, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var sitemaps []*url.URL
+	if robots, err := robotstxt.FromStatusAndBytes(statusCode, data); err == nil {
+		for _, rawURL := range robots.Sitemaps {
+			if u, ok := resolve(rawURL, robotsURL); ok {
+				sitemaps = append(sitemaps, u)
+			}
+		}
+	}
+
+	if len(sitemaps) == 0 {
+		sitemaps = append(sitemaps, rules.URL.ResolveReference(&url.URL{Path: DefaultPath}))
+	}
+	return sitemaps, nil
+}
+
+// Fetch fetches the sitemap of the URL of the rules and the sitemaps listed in it if it
+// is a sitemap index, returns the entries of all of them in the order they are found.
+// The requests use the rules, without selectors. The sitemaps are fetched once and at most
+// MaxSitemaps are fetched. The errors of the sitemaps listed in the indexes are returned
+// with their URLs as keys along with the entries of the other sitemaps, see colibri.AddError.
+func (fetcher *Fetcher) Fetch(ctx context.Context, rules *colibri.Rules) ([]Entry, error) {
+	if fetcher.Colibri == nil {
+		return nil, ErrColibriIsNil
+	} else if rules.URL == nil {
+		return nil, ErrURLIsNil
+	}
+
+	maxSitemaps := fetcher.MaxSitemaps
+	if maxSitemaps <= 0 {
+		maxSitemaps = DefaultMaxSitemaps
+	}
+
+	var (
+		entries []Entry
+		errs    error
+		queue   = []*url.URL{rules.URL}
+		visited = map[string]bool{rules.URL.String(): true}
+	)
+	for fetched := 0; (len(queue) > 0) && (fetched < maxSitemaps); fetched++ {
+		u := queue[0]
+		queue = queue[1:]
+
+		sitemap, err := fetcher.fetch(ctx, rules, u)
+		if (err != nil) && (fetched == 0) {
+			return nil, err
+		} else if err != nil {
+			errs = colibri.AddError(errs, u.String(), err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		entries = append(entries, sitemap.Entries...)
+		for _, s := range sitemap.Sitemaps {
+			if key := s.String(); !visited[key] {
+				visited[key] = true
+				queue = append(queue, s)
+			}
+		}
+	}
+	return entries, errs
+}
+
+// FetchSite discovers the sitemaps of the host of the URL of the rules and fetches them,
+// see Discover and Fetch. The errors are returned with the URLs of the sitemaps as keys
+// along with the entries of the other sitemaps.
+func (fetcher *Fetcher) FetchSite(ctx context.Context, rules *colibri.Rules) ([]Entry, error) {
+	sitemaps, err := fetcher.Discover(ctx, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries []Entry
+		errs    error
+	)
+	for _, u := range sitemaps {
+		sitemapRules := rules.Clone()
+		sitemapRules.URL = u
+
+		found, err := fetcher.Fetch(ctx, sitemapRules)
+		if err != nil {
+			errs = colibri.AddError(errs, u.String(), err)
+		}
+		entries = append(entries, found...)
+	}
+	return entries, errs
+}
+
+// fetch fetches and parses the sitemap of the URL.
+func (fetcher *Fetcher) fetch(ctx context.Context, rules *colibri.Rules, u *url.URL) (*Sitemap, error) {
+	data, statusCode, err := fetcher.get(ctx, rules, u, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if (statusCode < 200) || (statusCode > 299) {
+		return nil, fmt.Errorf("sitemap: unexpected status code %d", statusCode)
+	}
+	return Parse(bytes.NewReader(data), u)
+}
+
+// get requests the URL with the rules, without selectors, and returns the body of the response.
+func (fetcher *Fetcher) get(ctx context.Context, rules *colibri.Rules, u *url.URL, ignoreRobotsTxt bool) ([]byte, int, error) {
+	getRules := rules.Clone()
+	getRules.Method = "GET"
+	getRules.URL = u
+	getRules.Selectors = nil
+	getRules.Variants = nil
+	getRules.IgnoreRobotsTxt = getRules.IgnoreRobotsTxt || ignoreRobotsTxt
+
+	resp, err := fetcher.Colibri.DoContext(ctx, getRules)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body().Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body(), MaxSize+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, resp.StatusCode(), nil
+}
+
+// Seeds returns a copy of the rules for each entry with the URL of the entry,
+// used as the seeds of a crawl, see crawler.Crawler.Run.
+// If filter is not nil, only the entries for which it returns true are included.
+func Seeds(rules *colibri.Rules, entries []Entry, filter func(entry Entry) bool) []*colibri.Rules {
+	seeds := make([]*colibri.Rules, 0, len(entries))
+	for _, entry := range entries {
+		if (filter != nil) && !filter(entry) {
+			continue
+		}
+
+		seed := rules.Clone()
+		seed.URL = entry.URL
+		seeds = append(seeds, seed)
+	}
+	return seeds
+}
@@ -0,0 +1,235 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+func TestParse(t *testing.T) {
+	base := mustNewURL("https://example.com/sitemap.xml")
+
+	tests := []struct {
+		Name    string
+		Body    string
+		Want    *Sitemap
+		WantErr error
+	}{
+		{
+			"URLSet",
+			`<?xml version="1.0" encoding="UTF-8"?>
+			<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+				<url>
+					<loc>https://example.com/a</loc>
+					<lastmod>2024-01-02</lastmod>
+					<changefreq>Daily</changefreq>
+					<priority>0.8</priority>
+				</url>
+				<url><loc>/b</loc><lastmod>2024-01-02T03:04:05Z</lastmod></url>
+				<url><loc>ftp://example.com/c</loc></url>
+				<url><loc> </loc></url>
+			</urlset>`,
+			&Sitemap{Entries: []Entry{
+				{
+					URL:        mustNewURL("https://example.com/a"),
+					LastMod:    time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+					ChangeFreq: "daily",
+					Priority:   0.8,
+				},
+				{
+					URL:      mustNewURL("https://example.com/b"),
+					LastMod:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+					Priority: DefaultPriority,
+				},
+			}},
+			nil,
+		},
+		{
+			"Index",
+			`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+				<sitemap><loc>https://example.com/s1.xml</loc></sitemap>
+				<sitemap><loc>s2.xml.gz</loc></sitemap>
+			</sitemapindex>`,
+			&Sitemap{Sitemaps: []*url.URL{
+				mustNewURL("https://example.com/s1.xml"),
+				mustNewURL("https://example.com/s2.xml.gz"),
+			}},
+			nil,
+		},
+		{
+			"Text",
+			"https://example.com/a\n\nhttps://example.com/b\r\n",
+			&Sitemap{Entries: []Entry{
+				{URL: mustNewURL("https://example.com/a"), Priority: DefaultPriority},
+				{URL: mustNewURL("https://example.com/b"), Priority: DefaultPriority},
+			}},
+			nil,
+		},
+		{"HTML", `<html><body></body></html>`, nil, ErrNotSitemap},
+		{"Empty", " \n", nil, ErrNotSitemap},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			sitemap, err := Parse(strings.NewReader(tt.Body), base)
+			if !errors.Is(err, tt.WantErr) {
+				t.Fatalf("got %v, want %v", err, tt.WantErr)
+			}
+
+			if !reflect.DeepEqual(sitemap, tt.Want) {
+				t.Fatalf("got %+v, want %+v", sitemap, tt.Want)
+			}
+		})
+	}
+
+	t.Run("Gzip", func(t *testing.T) {
+		sitemap, err := Parse(bytes.NewReader(gzipBytes(tests[0].Body)), base)
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(sitemap, tests[0].Want) {
+			t.Fatalf("got %+v, want %+v", sitemap, tests[0].Want)
+		}
+	})
+}
+
+func TestFetcher(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintln(w, "User-agent: *\nDisallow: /private\nSitemap: /index.xml")
+
+		case "/index.xml":
+			fmt.Fprint(w, `<sitemapindex>
+				<sitemap><loc>/pages.xml.gz</loc></sitemap>
+				<sitemap><loc>/posts.txt</loc></sitemap>
+				<sitemap><loc>/missing.xml</loc></sitemap>
+				<sitemap><loc>/index.xml</loc></sitemap>
+			</sitemapindex>`)
+
+		case "/pages.xml.gz":
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Write(gzipBytes(`<urlset>
+				<url><loc>/a</loc><lastmod>2024-01-01</lastmod></url>
+				<url><loc>/b</loc><lastmod>2024-03-01</lastmod></url>
+			</urlset>`))
+
+		case "/posts.txt":
+			fmt.Fprint(w, "/posts/1\n/posts/2")
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	we, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	fetcher := New(we)
+	rules := &colibri.Rules{
+		URL:       mustNewURL(ts.URL),
+		Selectors: []*colibri.Selector{{Name: "title", Expr: "//title"}},
+	}
+
+	entries, err := fetcher.FetchSite(context.Background(), rules)
+
+	// the missing sitemap is reported with the other entries
+	var errs *colibri.Errs
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v, want *colibri.Errs", err)
+	}
+
+	indexErrs, _ := errs.Get(ts.URL + "/index.xml")
+	if _, ok := indexErrs.(*colibri.Errs).Get(ts.URL + "/missing.xml"); !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.URL.Path)
+	}
+
+	want := []string{"/a", "/b", "/posts/1", "/posts/2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// robots.txt is requested by Discover and by the RobotsTxt of Colibri, the index is fetched once
+	wantRequests := []string{"/robots.txt", "/robots.txt", "/index.xml", "/pages.xml.gz", "/posts.txt", "/missing.xml"}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Fatalf("got %v, want %v", requests, wantRequests)
+	}
+
+	t.Run("Seeds", func(t *testing.T) {
+		since := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		seeds := Seeds(rules, entries, func(entry Entry) bool {
+			return entry.LastMod.After(since)
+		})
+
+		if (len(seeds) != 1) || (seeds[0].URL.Path != "/b") || (len(seeds[0].Selectors) != 1) {
+			t.Fatalf("unexpected seeds %v", seeds)
+		}
+	})
+
+	t.Run("MaxSitemaps", func(t *testing.T) {
+		fetcher := &Fetcher{Colibri: we, MaxSitemaps: 2}
+		rules := &colibri.Rules{URL: mustNewURL(ts.URL + "/index.xml")}
+
+		entries, err := fetcher.Fetch(context.Background(), rules)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(entries) != 2 {
+			t.Fatalf("got %v, want %v", len(entries), 2)
+		}
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		ts := httptest.NewServer(http.NotFoundHandler())
+		defer ts.Close()
+
+		sitemaps, err := fetcher.Discover(context.Background(), &colibri.Rules{URL: mustNewURL(ts.URL + "/page")})
+		if err != nil {
+			t.Fatal(err)
+		} else if (len(sitemaps) != 1) || (sitemaps[0].String() != ts.URL+DefaultPath) {
+			t.Fatalf("got %v, want %v", sitemaps, ts.URL+DefaultPath)
+		}
+
+		if _, err := fetcher.Fetch(context.Background(), &colibri.Rules{URL: sitemaps[0]}); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func gzipBytes(s string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(s))
+	gz.Close()
+	return buf.Bytes()
+}
+
+func mustNewURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,214 @@
+package stats
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+)
+
+// DefaultTopN is the number of errors and selectors included in the report.
+const DefaultTopN = 10
+
+// Report summarizes a crawl.
+type Report struct {
+	Start            time.Time      `json:"start"`
+	Duration         time.Duration  `json:"duration"`
+	TotalPages       int            `json:"totalPages"`
+	PagesPerHost     map[string]int `json:"pagesPerHost"`
+	StatusCodes      map[int]int    `json:"statusCodes"`
+	TopErrors        []ErrorCount   `json:"topErrors"`
+	SlowestSelectors []SelectorTime `json:"slowestSelectors"`
+
+	// SelectorMatches match rates of the selectors, the lowest first,
+	// so the selectors broken by changes of the pages stand out.
+	SelectorMatches []SelectorMatch `json:"selectorMatches"`
+	RobotsDenials   map[string]int  `json:"robotsDenials"`
+}
+
+// ErrorCount represents the number of times an error occurred.
+type ErrorCount struct {
+	Error string `json:"error"`
+	Count int    `json:"count"`
+}
+
+// SelectorTime represents the time taken to evaluate a selector.
+type SelectorTime struct {
+	Name    string        `json:"name"`
+	Count   int           `json:"count"`
+	Average time.Duration `json:"average"`
+	Max     time.Duration `json:"max"`
+}
+
+// SelectorMatch represents the results of the evaluations of a selector.
+type SelectorMatch struct {
+	Name    string `json:"name"`
+	Matched int    `json:"matched"`
+	Empty   int    `json:"empty"`
+	Errors  int    `json:"errors"`
+
+	// MatchRate fraction of the evaluations that matched, from 0 to 1.
+	MatchRate float64 `json:"matchRate"`
+}
+
+// Report returns the report of the crawl with the DefaultTopN errors, slowest selectors
+// and selectors with the lowest match rates.
+func (stats *Stats) Report() *Report {
+	return stats.ReportTop(DefaultTopN)
+}
+
+// ReportTop returns the report of the crawl with the n most frequent errors, slowest selectors
+// and selectors with the lowest match rates.
+// If n is less than or equal to zero, all errors and selectors are included.
+func (stats *Stats) ReportTop(n int) *Report {
+	stats.rw.RLock()
+	defer stats.rw.RUnlock()
+
+	report := &Report{
+		Start:         stats.start,
+		Duration:      time.Since(stats.start),
+		PagesPerHost:  make(map[string]int, len(stats.pages)),
+		StatusCodes:   make(map[int]int, len(stats.statusCodes)),
+		RobotsDenials: make(map[string]int, len(stats.robotsDenials)),
+	}
+
+	for host, count := range stats.pages {
+		report.PagesPerHost[host] = count
+		report.TotalPages += count
+	}
+
+	for code, count := range stats.statusCodes {
+		report.StatusCodes[code] = count
+	}
+
+	for host, count := range stats.robotsDenials {
+		report.RobotsDenials[host] = count
+	}
+
+	for err, count := range stats.errs {
+		report.TopErrors = append(report.TopErrors, ErrorCount{Error: err, Count: count})
+	}
+	sort.Slice(report.TopErrors, func(i, j int) bool {
+		if report.TopErrors[i].Count == report.TopErrors[j].Count {
+			return report.TopErrors[i].Error < report.TopErrors[j].Error
+		}
+		return report.TopErrors[i].Count > report.TopErrors[j].Count
+	})
+
+	for name, sel := range stats.selectors {
+		if sel.count > 0 {
+			report.SlowestSelectors = append(report.SlowestSelectors, SelectorTime{
+				Name:    name,
+				Count:   sel.count,
+				Average: sel.total / time.Duration(sel.count),
+				Max:     sel.max,
+			})
+		}
+
+		if total := sel.matched + sel.empty + sel.errs; total > 0 {
+			report.SelectorMatches = append(report.SelectorMatches, SelectorMatch{
+				Name:      name,
+				Matched:   sel.matched,
+				Empty:     sel.empty,
+				Errors:    sel.errs,
+				MatchRate: float64(sel.matched) / float64(total),
+			})
+		}
+	}
+	sort.Slice(report.SlowestSelectors, func(i, j int) bool {
+		if report.SlowestSelectors[i].Max == report.SlowestSelectors[j].Max {
+			return report.SlowestSelectors[i].Name < report.SlowestSelectors[j].Name
+		}
+		return report.SlowestSelectors[i].Max > report.SlowestSelectors[j].Max
+	})
+
+	if (n > 0) && (len(report.TopErrors) > n) {
+		report.TopErrors = report.TopErrors[:n]
+	}
+
+	sort.Slice(report.SelectorMatches, func(i, j int) bool {
+		if report.SelectorMatches[i].MatchRate == report.SelectorMatches[j].MatchRate {
+			return report.SelectorMatches[i].Name < report.SelectorMatches[j].Name
+		}
+		return report.SelectorMatches[i].MatchRate < report.SelectorMatches[j].MatchRate
+	})
+
+	if (n > 0) && (len(report.SlowestSelectors) > n) {
+		report.SlowestSelectors = report.SlowestSelectors[:n]
+	}
+
+	if (n > 0) && (len(report.SelectorMatches) > n) {
+		report.SelectorMatches = report.SelectorMatches[:n]
+	}
+	return report
+}
+
+// WriteJSON writes the JSON representation of the report.
+func (report *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(report)
+}
+
+// WriteHTML writes the HTML representation of the report.
+func (report *Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, report)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(rate float64) float64 { return rate * 100 },
+}).Parse(`<!doctype html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Crawl report</title>
+</head>
+<body>
+	<h1>Crawl report</h1>
+	<p>Start: {{.Start.Format "2006-01-02 15:04:05"}} &middot; Duration: {{.Duration}} &middot; Pages: {{.TotalPages}}</p>
+
+	<h2>Pages per host</h2>
+	<table>
+		<tr><th>Host</th><th>Pages</th></tr>
+		{{range $host, $count := .PagesPerHost}}<tr><td>{{$host}}</td><td>{{$count}}</td></tr>
+		{{end}}
+	</table>
+
+	<h2>Status codes</h2>
+	<table>
+		<tr><th>Status code</th><th>Responses</th></tr>
+		{{range $code, $count := .StatusCodes}}<tr><td>{{$code}}</td><td>{{$count}}</td></tr>
+		{{end}}
+	</table>
+
+	<h2>Top errors</h2>
+	<table>
+		<tr><th>Error</th><th>Count</th></tr>
+		{{range .TopErrors}}<tr><td>{{.Error}}</td><td>{{.Count}}</td></tr>
+		{{end}}
+	</table>
+
+	<h2>Slowest selectors</h2>
+	<table>
+		<tr><th>Selector</th><th>Count</th><th>Average</th><th>Max</th></tr>
+		{{range .SlowestSelectors}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{.Average}}</td><td>{{.Max}}</td></tr>
+		{{end}}
+	</table>
+
+	<h2>Selector match rates</h2>
+	<table>
+		<tr><th>Selector</th><th>Matched</th><th>Empty</th><th>Errors</th><th>Match rate</th></tr>
+		{{range .SelectorMatches}}<tr><td>{{.Name}}</td><td>{{.Matched}}</td><td>{{.Empty}}</td><td>{{.Errors}}</td><td>{{printf "%.1f%%" (percent .MatchRate)}}</td></tr>
+		{{end}}
+	</table>
+
+	<h2>Robots.txt denials</h2>
+	<table>
+		<tr><th>Host</th><th>Denials</th></tr>
+		{{range $host, $count := .RobotsDenials}}<tr><td>{{$host}}</td><td>{{$count}}</td></tr>
+		{{end}}
+	</table>
+</body>
+</html>
+`))
@@ -0,0 +1,234 @@
+// stats collects statistics of the requests made by Colibri and generates crawl reports.
+package stats
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/parsers"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+// Stats stores the statistics of a crawl.
+type Stats struct {
+	rw            sync.RWMutex
+	start         time.Time
+	pages         map[string]int
+	statusCodes   map[int]int
+	errs          map[string]int
+	selectors     map[string]*selectorStat
+	robotsDenials map[string]int
+}
+
+type selectorStat struct {
+	count int
+	total time.Duration
+	max   time.Duration
+
+	matched int
+	empty   int
+	errs    int
+}
+
+// New returns a new Stats structure.
+func New() *Stats {
+	return &Stats{
+		start:         time.Now(),
+		pages:         make(map[string]int),
+		statusCodes:   make(map[int]int),
+		errs:          make(map[string]int),
+		selectors:     make(map[string]*selectorStat),
+		robotsDenials: make(map[string]int),
+	}
+}
+
+// Wrap replaces the Client and RobotsTxt of Colibri with wrappers that record statistics.
+// If the Parser is a *parsers.Parsers, a SelectorHook is set to record the time and the result of each selector.
+func (stats *Stats) Wrap(c *colibri.Colibri) {
+	if c.Client != nil {
+		c.Client = &Client{HTTPClient: c.Client, Stats: stats}
+	}
+
+	if c.RobotsTxt != nil {
+		c.RobotsTxt = &RobotsTxt{RobotsTxt: c.RobotsTxt, Stats: stats}
+	}
+
+	if p, ok := c.Parser.(*parsers.Parsers); ok {
+		p.SetSelectorHook(func(_ colibri.Response, selector *colibri.Selector, found any, elapsed time.Duration, err error) {
+			stats.ObserveSelector(selector.Name, elapsed)
+			stats.ObserveSelectorResult(selector.Name, found, err)
+		})
+	}
+}
+
+// ObserveResponse records the host and the status code of the response.
+func (stats *Stats) ObserveResponse(resp colibri.Response) {
+	stats.rw.Lock()
+	if u := resp.URL(); u != nil {
+		stats.pages[u.Host]++
+	}
+	stats.statusCodes[resp.StatusCode()]++
+	stats.rw.Unlock()
+}
+
+// ObserveError records the error.
+func (stats *Stats) ObserveError(err error) {
+	if err == nil {
+		return
+	}
+
+	stats.rw.Lock()
+	stats.errs[err.Error()]++
+	stats.rw.Unlock()
+}
+
+// ObserveSelector records the time taken to evaluate the selector.
+func (stats *Stats) ObserveSelector(name string, elapsed time.Duration) {
+	stats.rw.Lock()
+	sel := stats.selector(name)
+	sel.count++
+	sel.total += elapsed
+	if elapsed > sel.max {
+		sel.max = elapsed
+	}
+	stats.rw.Unlock()
+}
+
+// ObserveSelectorResult records whether the selector matched, found an empty value or failed.
+// The nil values, the empty strings and the empty slices and maps are empty.
+func (stats *Stats) ObserveSelectorResult(name string, found any, err error) {
+	stats.rw.Lock()
+	sel := stats.selector(name)
+	switch {
+	case err != nil:
+		sel.errs++
+	case isEmpty(found):
+		sel.empty++
+	default:
+		sel.matched++
+	}
+	stats.rw.Unlock()
+}
+
+// ObserveRobotsDenial records that robots.txt denied access to the host.
+func (stats *Stats) ObserveRobotsDenial(host string) {
+	stats.rw.Lock()
+	stats.robotsDenials[host]++
+	stats.rw.Unlock()
+}
+
+// Clear removes the stored statistics and restarts the crawl time.
+func (stats *Stats) Clear() {
+	stats.rw.Lock()
+	stats.start = time.Now()
+	clear(stats.pages)
+	clear(stats.statusCodes)
+	clear(stats.errs)
+	clear(stats.selectors)
+	clear(stats.robotsDenials)
+	stats.rw.Unlock()
+}
+
+// selector returns the statistics of the selector, creating them if they do not exist.
+// The lock must be held.
+func (stats *Stats) selector(name string) *selectorStat {
+	sel, ok := stats.selectors[name]
+	if !ok {
+		sel = &selectorStat{}
+		stats.selectors[name] = sel
+	}
+	return sel
+}
+
+// isEmpty returns true if the value is nil, an empty string or an empty slice or map.
+func isEmpty(value any) bool {
+	if value == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// Client records the statistics of the HTTP requests.
+// See the colibri.HTTPClient interface.
+type Client struct {
+	colibri.HTTPClient
+	Stats *Stats
+}
+
+func (client *Client) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	return client.DoContext(context.Background(), c, rules)
+}
+
+// DoContext calls DoContext of the wrapped HTTPClient if it implements
+// colibri.HTTPClientContext, otherwise Do is called. See colibri.ClientDo.
+func (client *Client) DoContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	resp, err := colibri.ClientDo(ctx, client.HTTPClient, c, rules)
+	if err != nil {
+		client.Stats.ObserveError(err)
+		return resp, err
+	}
+
+	client.Stats.ObserveResponse(resp)
+	return resp, nil
+}
+
+// ReportCapabilities adds the "stats" feature and the capabilities of the wrapped HTTPClient to the report.
+// See the colibri.CapabilityReporter interface.
+func (client *Client) ReportCapabilities(caps *colibri.Capabilities) {
+	colibri.ReportCapabilitiesOf(client.HTTPClient, caps)
+	caps.Features = append(caps.Features, "stats")
+}
+
+// RobotsTxt records the robots.txt restrictions.
+// See the colibri.RobotsTxt interface.
+type RobotsTxt struct {
+	colibri.RobotsTxt
+	Stats *Stats
+}
+
+func (robots *RobotsTxt) IsAllowed(c *colibri.Colibri, rules *colibri.Rules) error {
+	return robots.IsAllowedContext(context.Background(), c, rules)
+}
+
+// IsAllowedContext calls IsAllowedContext of the wrapped RobotsTxt if it implements
+// colibri.RobotsTxtContext, otherwise IsAllowed is called. See colibri.RobotsIsAllowed.
+func (robots *RobotsTxt) IsAllowedContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) error {
+	err := colibri.RobotsIsAllowed(ctx, robots.RobotsTxt, c, rules)
+	if errors.Is(err, webextractor.ErrorRobotstxtRestriction) {
+		robots.Stats.ObserveRobotsDenial(rules.URL.Host)
+	} else if err != nil {
+		robots.Stats.ObserveError(err)
+	}
+	return err
+}
+
+// ReportCapabilities adds the capabilities of the wrapped RobotsTxt to the report.
+// See the colibri.CapabilityReporter interface.
+func (robots *RobotsTxt) ReportCapabilities(caps *colibri.Capabilities) {
+	colibri.ReportCapabilitiesOf(robots.RobotsTxt, caps)
+}
+
+// CrawlDelay returns the crawl delay of the wrapped RobotsTxt if it implements
+// colibri.CrawlDelayer, otherwise zero. See the colibri.CrawlDelayer interface.
+func (robots *RobotsTxt) CrawlDelay(u *url.URL, userAgent string) time.Duration {
+	return colibri.RobotsCrawlDelay(robots.RobotsTxt, u, userAgent)
+}
+
+// Sitemaps returns the URLs of the Sitemap directives of the wrapped RobotsTxt if it implements
+// colibri.Sitemapper, otherwise nil. See the colibri.Sitemapper interface.
+func (robots *RobotsTxt) Sitemaps(host string) []string {
+	return colibri.RobotsSitemaps(robots.RobotsTxt, host)
+}
@@ -0,0 +1,200 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/webextractor"
+)
+
+func TestStatsReport(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	we, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	stats := New()
+	stats.Wrap(we)
+
+	tests := []struct {
+		Path    string
+		WantErr error
+	}{
+		{"/html", nil},
+		{"/html", nil},
+		{"/missing", nil},
+		{"/disallow", webextractor.ErrorRobotstxtRestriction},
+	}
+
+	for _, tt := range tests {
+		rules := &colibri.Rules{
+			Method: "GET",
+			URL:    mustNewURL(ts.URL + tt.Path),
+			Selectors: []*colibri.Selector{
+				{Name: "title", Expr: "//title"},
+				{Name: "price", Expr: `//span[@class="price"]`},
+			},
+		}
+
+		_, _, err := we.Extract(rules)
+		if (tt.WantErr != nil) && !errors.Is(err, tt.WantErr) {
+			t.Fatalf("got %v, want %v", err, tt.WantErr)
+		}
+	}
+
+	var (
+		host   = mustNewURL(ts.URL).Host
+		report = stats.Report()
+	)
+
+	// the robots.txt request is also counted
+	if report.TotalPages != 4 || report.PagesPerHost[host] != 4 {
+		t.Fatalf("got %v, want %v", report.PagesPerHost, 4)
+	}
+
+	if report.StatusCodes[http.StatusOK] != 3 || report.StatusCodes[http.StatusNotFound] != 1 {
+		t.Fatalf("unexpected status codes %v", report.StatusCodes)
+	}
+
+	if report.RobotsDenials[host] != 1 {
+		t.Fatalf("got %v, want %v", report.RobotsDenials[host], 1)
+	}
+
+	if len(report.SlowestSelectors) != 2 || report.SlowestSelectors[0].Count != 3 {
+		t.Fatalf("unexpected selectors %v", report.SlowestSelectors)
+	}
+
+	wantMatches := []SelectorMatch{
+		{Name: "price", Empty: 3, MatchRate: 0},
+		{Name: "title", Matched: 3, MatchRate: 1},
+	}
+	if !reflect.DeepEqual(report.SelectorMatches, wantMatches) {
+		t.Fatalf("got %v, want %v", report.SelectorMatches, wantMatches)
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := report.WriteJSON(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		var output Report
+		if err := json.Unmarshal(buf.Bytes(), &output); err != nil {
+			t.Fatal(err)
+		} else if output.TotalPages != report.TotalPages {
+			t.Fatalf("got %v, want %v", output.TotalPages, report.TotalPages)
+		}
+	})
+
+	t.Run("HTML", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := report.WriteHTML(&buf); err != nil {
+			t.Fatal(err)
+		} else if !strings.Contains(buf.String(), host) {
+			t.Fatal("host not found in report")
+		}
+	})
+
+	t.Run("TopErrors", func(t *testing.T) {
+		stats.ObserveError(errors.New("err 1"))
+		stats.ObserveError(errors.New("err 2"))
+		stats.ObserveError(errors.New("err 2"))
+
+		report := stats.ReportTop(1)
+		if len(report.TopErrors) != 1 || report.TopErrors[0].Error != "err 2" {
+			t.Fatalf("unexpected errors %v", report.TopErrors)
+		}
+	})
+
+	t.Run("SelectorMatches", func(t *testing.T) {
+		stats.ObserveSelectorResult("links", []any{}, nil)
+		stats.ObserveSelectorResult("links", []any{"/a"}, nil)
+		stats.ObserveSelectorResult("links", nil, errors.New("invalid expression"))
+
+		report := stats.ReportTop(1)
+		want := []SelectorMatch{{Name: "price", Empty: 3, MatchRate: 0}}
+		if !reflect.DeepEqual(report.SelectorMatches, want) {
+			t.Fatalf("got %v, want %v", report.SelectorMatches, want)
+		}
+
+		for _, match := range stats.Report().SelectorMatches {
+			if (match.Name == "links") && ((match.Matched != 1) || (match.Empty != 1) || (match.Errors != 1)) {
+				t.Fatalf("unexpected links %v", match)
+			}
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		stats.Clear()
+		if report := stats.Report(); report.TotalPages != 0 {
+			t.Fatal("Uncleaned")
+		}
+	})
+}
+
+func TestRobotsTxtCrawlDelay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nCrawl-delay: 2\nSitemap: https://example.com/sitemap.xml")
+	}))
+	defer ts.Close()
+
+	we, err := webextractor.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	New().Wrap(we)
+
+	u := mustNewURL(ts.URL + "/a")
+	if err := we.RobotsTxt.IsAllowed(we, &colibri.Rules{Method: "GET", URL: u}); err != nil {
+		t.Fatal(err)
+	}
+
+	crawlDelayer, ok := we.RobotsTxt.(colibri.CrawlDelayer)
+	if !ok {
+		t.Fatal("CrawlDelayer not implemented")
+	} else if got := crawlDelayer.CrawlDelay(u, "colibri"); got != 2*time.Second {
+		t.Fatalf("got %v, want %v", got, 2*time.Second)
+	}
+
+	sitemaps := colibri.RobotsSitemaps(we.RobotsTxt, u.Host)
+	if want := []string{"https://example.com/sitemap.xml"}; !reflect.DeepEqual(sitemaps, want) {
+		t.Fatalf("got %v, want %v", sitemaps, want)
+	}
+}
+
+func mustNewURL(rawURL string) *url.URL {
+	u, _ := url.Parse(rawURL)
+	return u
+}
+
+func testServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintln(w, "User-agent: *\nDisallow: /disallow")
+
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, "<html><head><title>Stats</title></head></html>")
+
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintln(w, "<html><head><title>Not Found</title></head></html>")
+		}
+	}))
+}
@@ -0,0 +1,186 @@
+// bolt stores the state of the crawls in a BoltDB database, see the storage.Storage interface.
+// It is a separate module, so Colibri does not require BoltDB.
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri/storage"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	visitedBucket = []byte("visited")
+	cookiesBucket = []byte("cookies")
+	robotsBucket  = []byte("robots")
+)
+
+// robotsTxt status code and content of a robots.txt.
+type robotsTxt struct {
+	StatusCode int
+	Data       []byte
+}
+
+// Bolt stores the state in a BoltDB database, so it is kept after a restart.
+// The database is locked by the process, use Close to release it.
+type Bolt struct {
+	db *bbolt.DB
+
+	mu  sync.Mutex
+	jar *storage.Jar
+	err error
+}
+
+// Open opens the database of the path, creates it if it does not exist.
+// It waits one second at most for the lock of the database.
+func Open(path string) (*Bolt, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{visitedBucket, cookiesBucket, robotsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Bolt{db: db}, nil
+}
+
+func (b *Bolt) Visited(key string) (bool, error) {
+	var ok bool
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		ok = tx.Bucket(visitedBucket).Get([]byte(key)) != nil
+		return nil
+	})
+	return ok, err
+}
+
+func (b *Bolt) MarkVisited(key string) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(visitedBucket).Put([]byte(key), []byte{})
+	})
+}
+
+// Jar returns the cookie jar of the storage, loaded with the cookies stored.
+// The cookies set are stored, see storage.Jar.
+func (b *Bolt) Jar() (http.CookieJar, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.jar != nil {
+		return b.jar, nil
+	}
+
+	var stored []storage.Cookies
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(cookiesBucket).ForEach(func(_, v []byte) error {
+			var cookies storage.Cookies
+			if json.Unmarshal(v, &cookies) == nil {
+				stored = append(stored, cookies)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the error is kept, see Err
+	b.jar, err = storage.NewJar(stored, func(cookies storage.Cookies) { b.setErr(b.storeCookies(cookies)) })
+	if err != nil {
+		return nil, err
+	}
+	return b.jar, nil
+}
+
+// storeCookies appends the cookies to the cookies bucket.
+func (b *Bolt) storeCookies(cookies storage.Cookies) error {
+	data, err := json.Marshal(cookies)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(cookiesBucket)
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(binary.BigEndian.AppendUint64(nil, seq), data)
+	})
+}
+
+func (b *Bolt) RobotsTxt(host string) (int, []byte, bool, error) {
+	var (
+		robots robotsTxt
+		ok     bool
+	)
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(robotsBucket).Get([]byte(host))
+		if v == nil {
+			return nil
+		}
+
+		ok = true
+		return json.Unmarshal(v, &robots)
+	})
+	if err != nil {
+		return 0, nil, false, err
+	}
+	return robots.StatusCode, robots.Data, ok, nil
+}
+
+func (b *Bolt) SetRobotsTxt(host string, statusCode int, data []byte) error {
+	v, err := json.Marshal(robotsTxt{StatusCode: statusCode, Data: data})
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(robotsBucket).Put([]byte(host), v)
+	})
+}
+
+// Len returns the number of keys marked as visited.
+func (b *Bolt) Len() int {
+	var n int
+	b.db.View(func(tx *bbolt.Tx) error {
+		n = tx.Bucket(visitedBucket).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+// Err returns the first error storing the cookies set in the Jar.
+func (b *Bolt) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+func (b *Bolt) setErr(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Close closes the database.
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,81 @@
+package bolt
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/eduardogxnzalez/colibri/storage"
+)
+
+var _ storage.Storage = (*Bolt)(nil)
+
+func TestBolt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	b, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := b.Visited("GET https://example.com/a"); (err != nil) || ok {
+		t.Fatalf("got %v %v, want %v %v", ok, err, false, nil)
+	}
+
+	b.MarkVisited("GET https://example.com/a")
+	b.MarkVisited("GET https://example.com/a")
+	b.MarkVisited("GET https://example.com/b")
+
+	if _, _, ok, _ := b.RobotsTxt("example.com"); ok {
+		t.Fatal("robots.txt must not be stored")
+	}
+
+	if err := b.SetRobotsTxt("example.com", 200, []byte("User-agent: *")); err != nil {
+		t.Fatal(err)
+	}
+
+	jar, err := b.Jar()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("https://example.com/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "S1", MaxAge: 3600}})
+
+	if err := b.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if ok, err := b.Visited("GET https://example.com/b"); (err != nil) || !ok {
+		t.Fatalf("got %v %v, want %v %v", ok, err, true, nil)
+	}
+
+	if b.Len() != 2 {
+		t.Fatalf("got %v, want %v", b.Len(), 2)
+	}
+
+	statusCode, data, ok, err := b.RobotsTxt("example.com")
+	if (err != nil) || !ok || (statusCode != 200) || (string(data) != "User-agent: *") {
+		t.Fatalf("got %v %q %v %v, want %v %q %v %v", statusCode, data, ok, err, 200, "User-agent: *", true, nil)
+	}
+
+	jar, err = b.Jar()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cookies := jar.Cookies(u); (len(cookies) != 1) || (cookies[0].Value != "S1") {
+		t.Fatalf("unexpected cookies %v", cookies)
+	}
+}
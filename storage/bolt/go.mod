@@ -0,0 +1,16 @@
+module github.com/eduardogxnzalez/colibri/storage/bolt
+
+go 1.21.0
+
+require (
+	github.com/eduardogxnzalez/colibri v0.0.0-00010101000000-000000000000
+	go.etcd.io/bbolt v1.3.10
+)
+
+require (
+	github.com/klauspost/compress v1.17.11 // indirect
+	golang.org/x/net v0.15.0 // indirect
+	golang.org/x/sys v0.12.0 // indirect
+)
+
+replace github.com/eduardogxnzalez/colibri => ../..
@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/eduardogxnzalez/colibri/compress"
+)
+
+// ErrClosed is returned when the File is closed.
+var ErrClosed = errors.New("storage is closed")
+
+// File stores the state in a file, so it is kept after a restart.
+// The changes are appended to the file as JSON lines and loaded again by OpenFile.
+// The lines can be compressed with a codec, see OpenFileWithCodec.
+// Use Close to write the pending changes to the disk.
+type File struct {
+	mem *Memory
+
+	mu   sync.Mutex
+	file *os.File
+	w    io.Writer       // file or cw
+	cw   compress.Writer // nil if the file is not compressed
+	jar  *Jar
+	err  error
+}
+
+// record is a line of the file.
+type record struct {
+	Visited string        `json:",omitempty"`
+	Cookies *Cookies      `json:",omitempty"`
+	Robots  *robotsRecord `json:",omitempty"`
+}
+
+type robotsRecord struct {
+	Host       string
+	StatusCode int
+	Data       []byte
+}
+
+// OpenFile opens the file of the path, creates it if it does not exist,
+// and loads the state stored in it.
+// The invalid lines are skipped, e.g. the last line if the process ended while writing it.
+func OpenFile(path string) (*File, error) {
+	return OpenFileWithCodec(path, nil)
+}
+
+// OpenFileWithCodec opens the file of the path like OpenFile, the new files are compressed
+// with the codec, e.g. compress.Default. If nil, the new files are not compressed.
+// The existing files keep the format in which they were created, the codec is detected.
+// If the compressed stream is incomplete, e.g. because the process ended without Close,
+// the file is rewritten with the records read.
+func OpenFileWithCodec(path string, codec compress.Codec) (*File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	file := &File{mem: NewMemory(), file: f}
+	detected, lines, complete, err := file.load()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if info.Size() > 0 {
+		codec = detected
+	}
+
+	if !complete {
+		f.Close()
+		if f, err = rewriteFile(path, codec, lines); err != nil {
+			return nil, err
+		}
+		file.file = f
+	}
+
+	if err := file.setCodec(codec); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// load replays the records of the file. Returns the codec of the file and, if it is
+// compressed, the lines of the records and whether the compressed stream is complete.
+func (file *File) load() (compress.Codec, [][]byte, bool, error) {
+	jar, err := file.mem.Jar()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	r, codec, err := compress.NewReader(file.file)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer r.Close()
+
+	var (
+		br    = bufio.NewReader(r)
+		lines [][]byte
+	)
+	for {
+		line, err := br.ReadBytes('\n')
+		if (err != nil) && (err != io.EOF) {
+			if codec != nil {
+				// incomplete stream, the records read are kept
+				return codec, lines, false, nil
+			}
+			return nil, nil, false, err
+		}
+
+		var rec record
+		if json.Unmarshal(line, &rec) == nil {
+			switch {
+			case rec.Visited != "":
+				file.mem.MarkVisited(rec.Visited)
+
+			case rec.Cookies != nil:
+				if u, err := url.Parse(rec.Cookies.URL); err == nil {
+					jar.SetCookies(u, rec.Cookies.Cookies)
+				}
+
+			case rec.Robots != nil:
+				file.mem.SetRobotsTxt(rec.Robots.Host, rec.Robots.StatusCode, rec.Robots.Data)
+			}
+
+			if codec != nil {
+				lines = append(lines, line)
+			}
+		}
+
+		if err == io.EOF {
+			return codec, lines, true, nil
+		}
+	}
+}
+
+// setCodec sets the writer of the records compressed with the codec.
+func (file *File) setCodec(codec compress.Codec) error {
+	file.w, file.cw = file.file, nil
+	if codec == nil {
+		return nil
+	}
+
+	cw, err := codec.NewWriter(file.file)
+	if err != nil {
+		return err
+	}
+	file.w, file.cw = cw, cw
+	return nil
+}
+
+// rewriteFile replaces the file of the path with a file with the lines compressed with the codec.
+// Returns the new file opened for appending.
+func rewriteFile(path string, codec compress.Codec, lines [][]byte) (*os.File, error) {
+	data, err := compress.Compress(codec, bytes.Join(lines, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+
+	if err := errors.Join(tmp.Sync(), tmp.Close()); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0o600)
+}
+
+func (file *File) Visited(key string) (bool, error) {
+	return file.mem.Visited(key)
+}
+
+func (file *File) MarkVisited(key string) error {
+	if ok, _ := file.mem.Visited(key); ok {
+		return nil
+	}
+
+	if err := file.append(&record{Visited: key}); err != nil {
+		return err
+	}
+	return file.mem.MarkVisited(key)
+}
+
+// Jar returns the cookie jar of the storage.
+// The cookies with a Max-Age are stored with the equivalent Expires,
+// so they expire at the same time after a restart.
+func (file *File) Jar() (http.CookieJar, error) {
+	file.mu.Lock()
+	defer file.mu.Unlock()
+
+	if file.jar == nil {
+		jar, err := file.mem.Jar()
+		if err != nil {
+			return nil, err
+		}
+
+		// the error is kept, see Err
+		file.jar = &Jar{CookieJar: jar, Store: func(cookies Cookies) { file.append(&record{Cookies: &cookies}) }}
+	}
+	return file.jar, nil
+}
+
+func (file *File) RobotsTxt(host string) (int, []byte, bool, error) {
+	return file.mem.RobotsTxt(host)
+}
+
+func (file *File) SetRobotsTxt(host string, statusCode int, data []byte) error {
+	if err := file.append(&record{Robots: &robotsRecord{Host: host, StatusCode: statusCode, Data: data}}); err != nil {
+		return err
+	}
+	return file.mem.SetRobotsTxt(host, statusCode, data)
+}
+
+// Len returns the number of keys marked as visited.
+func (file *File) Len() int {
+	return file.mem.Len()
+}
+
+// Err returns the first error writing the file, e.g. while storing the cookies.
+func (file *File) Err() error {
+	file.mu.Lock()
+	defer file.mu.Unlock()
+	return file.err
+}
+
+// Close writes the pending changes to the disk and closes the file.
+// Returns the first error writing the file.
+func (file *File) Close() error {
+	file.mu.Lock()
+	defer file.mu.Unlock()
+
+	if file.file == nil {
+		return file.err
+	}
+
+	var err error
+	if file.cw != nil {
+		err = file.cw.Close()
+	}
+
+	err = errors.Join(err, file.file.Sync(), file.file.Close())
+	file.file, file.w, file.cw = nil, nil, nil
+	if file.err == nil {
+		file.err = err
+	}
+	return file.err
+}
+
+// append appends the record to the file.
+func (file *File) append(rec *record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	file.mu.Lock()
+	defer file.mu.Unlock()
+
+	if file.file == nil {
+		return ErrClosed
+	}
+
+	_, err = file.w.Write(append(line, '\n'))
+	if (err == nil) && (file.cw != nil) {
+		err = file.cw.Flush()
+	}
+
+	if err != nil {
+		if file.err == nil {
+			file.err = err
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+module github.com/eduardogxnzalez/colibri/storage/redis
+
+go 1.21.0
+
+require (
+	github.com/alicebob/miniredis/v2 v2.33.0
+	github.com/eduardogxnzalez/colibri v0.0.0-00010101000000-000000000000
+	github.com/redis/go-redis/v9 v9.7.3
+)
+
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	golang.org/x/net v0.15.0 // indirect
+)
+
+replace github.com/eduardogxnzalez/colibri => ../..
@@ -0,0 +1,138 @@
+// redis stores the state of the crawls in Redis, see the storage.Storage interface.
+// It is a separate module, so Colibri does not require the Redis client.
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/eduardogxnzalez/colibri/storage"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultPrefix is the prefix of the keys if none is specified.
+const DefaultPrefix = "colibri:"
+
+// robotsTxt status code and content of a robots.txt.
+type robotsTxt struct {
+	StatusCode int
+	Data       []byte
+}
+
+// Redis stores the state in Redis, so it is shared by the crawlers of many processes.
+// The visited keys are stored in the set "<prefix>visited", the cookies in the list
+// "<prefix>cookies" and the robots.txt in the hash "<prefix>robots".
+type Redis struct {
+	client redis.UniversalClient
+	prefix string
+
+	mu  sync.Mutex
+	jar *storage.Jar
+	err error
+}
+
+// New returns a new Redis with the client and the prefix of the keys.
+// If the prefix is empty, DefaultPrefix is used.
+func New(client redis.UniversalClient, prefix string) *Redis {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return &Redis{client: client, prefix: prefix}
+}
+
+func (r *Redis) Visited(key string) (bool, error) {
+	return r.client.SIsMember(context.Background(), r.prefix+"visited", key).Result()
+}
+
+func (r *Redis) MarkVisited(key string) error {
+	return r.client.SAdd(context.Background(), r.prefix+"visited", key).Err()
+}
+
+// Jar returns the cookie jar of the storage, loaded with the cookies stored.
+// The cookies set are stored, see storage.Jar.
+func (r *Redis) Jar() (http.CookieJar, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.jar != nil {
+		return r.jar, nil
+	}
+
+	values, err := r.client.LRange(context.Background(), r.prefix+"cookies", 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []storage.Cookies
+	for _, v := range values {
+		var cookies storage.Cookies
+		if json.Unmarshal([]byte(v), &cookies) == nil {
+			stored = append(stored, cookies)
+		}
+	}
+
+	// the error is kept, see Err
+	r.jar, err = storage.NewJar(stored, func(cookies storage.Cookies) { r.setErr(r.storeCookies(cookies)) })
+	if err != nil {
+		return nil, err
+	}
+	return r.jar, nil
+}
+
+// storeCookies appends the cookies to the cookies list.
+func (r *Redis) storeCookies(cookies storage.Cookies) error {
+	data, err := json.Marshal(cookies)
+	if err != nil {
+		return err
+	}
+	return r.client.RPush(context.Background(), r.prefix+"cookies", data).Err()
+}
+
+func (r *Redis) RobotsTxt(host string) (int, []byte, bool, error) {
+	v, err := r.client.HGet(context.Background(), r.prefix+"robots", host).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil, false, nil
+	} else if err != nil {
+		return 0, nil, false, err
+	}
+
+	var robots robotsTxt
+	if err := json.Unmarshal(v, &robots); err != nil {
+		return 0, nil, false, err
+	}
+	return robots.StatusCode, robots.Data, true, nil
+}
+
+func (r *Redis) SetRobotsTxt(host string, statusCode int, data []byte) error {
+	v, err := json.Marshal(robotsTxt{StatusCode: statusCode, Data: data})
+	if err != nil {
+		return err
+	}
+	return r.client.HSet(context.Background(), r.prefix+"robots", host, v).Err()
+}
+
+// Len returns the number of keys marked as visited, 0 if Redis is unreachable.
+func (r *Redis) Len() int {
+	n, _ := r.client.SCard(context.Background(), r.prefix+"visited").Result()
+	return int(n)
+}
+
+// Err returns the first error storing the cookies set in the Jar.
+func (r *Redis) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Redis) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err == nil {
+		r.err = err
+	}
+}
@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/eduardogxnzalez/colibri/storage"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ storage.Storage = (*Redis)(nil)
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	r := New(client, "")
+
+	if ok, err := r.Visited("GET https://example.com/a"); (err != nil) || ok {
+		t.Fatalf("got %v %v, want %v %v", ok, err, false, nil)
+	}
+
+	r.MarkVisited("GET https://example.com/a")
+	r.MarkVisited("GET https://example.com/a")
+	r.MarkVisited("GET https://example.com/b")
+
+	if _, _, ok, _ := r.RobotsTxt("example.com"); ok {
+		t.Fatal("robots.txt must not be stored")
+	}
+
+	if err := r.SetRobotsTxt("example.com", 200, []byte("User-agent: *")); err != nil {
+		t.Fatal(err)
+	}
+
+	jar, err := r.Jar()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("https://example.com/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "S1", MaxAge: 3600}})
+
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !server.Exists(DefaultPrefix + "visited") {
+		t.Fatalf("key %q not found", DefaultPrefix+"visited")
+	}
+
+	// another process with the same Redis
+	r = New(client, DefaultPrefix)
+
+	if ok, err := r.Visited("GET https://example.com/b"); (err != nil) || !ok {
+		t.Fatalf("got %v %v, want %v %v", ok, err, true, nil)
+	}
+
+	if r.Len() != 2 {
+		t.Fatalf("got %v, want %v", r.Len(), 2)
+	}
+
+	statusCode, data, ok, err := r.RobotsTxt("example.com")
+	if (err != nil) || !ok || (statusCode != 200) || (string(data) != "User-agent: *") {
+		t.Fatalf("got %v %q %v %v, want %v %q %v %v", statusCode, data, ok, err, 200, "User-agent: *", true, nil)
+	}
+
+	jar, err = r.Jar()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cookies := jar.Cookies(u); (len(cookies) != 1) || (cookies[0].Value != "S1") {
+		t.Fatalf("unexpected cookies %v", cookies)
+	}
+
+	if New(client, "other:").Len() != 0 {
+		t.Fatal("the prefixes must not share the keys")
+	}
+}
@@ -0,0 +1,164 @@
+// storage stores the state of the crawls (visited URLs, cookies and robots.txt files),
+// so the crawls can be resumed after a restart without requesting again the processed URLs.
+package storage
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Storage stores the state of the crawls.
+// Memory and File are provided, the BoltDB and Redis stores are in the storage/bolt
+// and storage/redis modules, so their dependencies are not required by Colibri.
+// The implementations must be safe for concurrent use.
+type Storage interface {
+	// Visited returns true if the key was marked as visited.
+	Visited(key string) (bool, error)
+
+	// MarkVisited marks the key as visited.
+	MarkVisited(key string) error
+
+	// Jar returns the cookie jar of the storage, the cookies set are stored.
+	Jar() (http.CookieJar, error)
+
+	// RobotsTxt returns the status code and the content of the robots.txt of the host,
+	// false if it is not stored.
+	RobotsTxt(host string) (int, []byte, bool, error)
+
+	// SetRobotsTxt stores the status code and the content of the robots.txt of the host.
+	SetRobotsTxt(host string, statusCode int, data []byte) error
+}
+
+// robotsTxt status code and content of a robots.txt.
+type robotsTxt struct {
+	StatusCode int
+	Data       []byte
+}
+
+// Memory stores the state in memory, it is lost when the process ends.
+type Memory struct {
+	rw      sync.RWMutex
+	visited map[string]struct{}
+	robots  map[string]robotsTxt
+	jar     http.CookieJar
+}
+
+// NewMemory returns a new Memory.
+func NewMemory() *Memory {
+	return &Memory{
+		visited: make(map[string]struct{}),
+		robots:  make(map[string]robotsTxt),
+	}
+}
+
+func (memory *Memory) Visited(key string) (bool, error) {
+	memory.rw.RLock()
+	defer memory.rw.RUnlock()
+
+	_, ok := memory.visited[key]
+	return ok, nil
+}
+
+func (memory *Memory) MarkVisited(key string) error {
+	memory.rw.Lock()
+	defer memory.rw.Unlock()
+
+	if memory.visited == nil {
+		memory.visited = make(map[string]struct{})
+	}
+	memory.visited[key] = struct{}{}
+	return nil
+}
+
+func (memory *Memory) Jar() (http.CookieJar, error) {
+	memory.rw.Lock()
+	defer memory.rw.Unlock()
+
+	if memory.jar == nil {
+		jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		if err != nil {
+			return nil, err
+		}
+		memory.jar = jar
+	}
+	return memory.jar, nil
+}
+
+func (memory *Memory) RobotsTxt(host string) (int, []byte, bool, error) {
+	memory.rw.RLock()
+	defer memory.rw.RUnlock()
+
+	robots, ok := memory.robots[host]
+	return robots.StatusCode, robots.Data, ok, nil
+}
+
+func (memory *Memory) SetRobotsTxt(host string, statusCode int, data []byte) error {
+	memory.rw.Lock()
+	defer memory.rw.Unlock()
+
+	if memory.robots == nil {
+		memory.robots = make(map[string]robotsTxt)
+	}
+	memory.robots[host] = robotsTxt{StatusCode: statusCode, Data: data}
+	return nil
+}
+
+// Len returns the number of keys marked as visited.
+func (memory *Memory) Len() int {
+	memory.rw.RLock()
+	defer memory.rw.RUnlock()
+	return len(memory.visited)
+}
+
+// Cookies are the cookies set for a URL, as they are stored by the stores.
+type Cookies struct {
+	URL     string
+	Cookies []*http.Cookie
+}
+
+// Jar is a cookie jar that passes the cookies set to Store, so they are stored.
+// The cookies with a Max-Age are passed with the equivalent Expires,
+// so they expire at the same time after a restart.
+type Jar struct {
+	http.CookieJar
+	Store func(cookies Cookies)
+}
+
+// NewJar returns a new Jar with the stored cookies.
+func NewJar(stored []Cookies, store func(cookies Cookies)) (*Jar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cookies := range stored {
+		if u, err := url.Parse(cookies.URL); err == nil {
+			jar.SetCookies(u, cookies.Cookies)
+		}
+	}
+	return &Jar{CookieJar: jar, Store: store}, nil
+}
+
+func (jar *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	jar.CookieJar.SetCookies(u, cookies)
+	if jar.Store == nil {
+		return
+	}
+
+	now := time.Now()
+	stored := make([]*http.Cookie, 0, len(cookies))
+	for _, cookie := range cookies {
+		c := *cookie
+		if c.MaxAge > 0 {
+			c.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+		stored = append(stored, &c)
+	}
+	jar.Store(Cookies{URL: u.String(), Cookies: stored})
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri/compress"
+)
+
+func TestStorage(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Storage func(t *testing.T) Storage
+	}{
+		{"Memory", func(t *testing.T) Storage { return NewMemory() }},
+		{"File", func(t *testing.T) Storage {
+			file, err := OpenFile(filepath.Join(t.TempDir(), "state"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { file.Close() })
+			return file
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			s := tt.Storage(t)
+
+			if ok, err := s.Visited("GET https://example.com/a"); (err != nil) || ok {
+				t.Fatalf("got %v %v, want %v %v", ok, err, false, nil)
+			}
+
+			if err := s.MarkVisited("GET https://example.com/a"); err != nil {
+				t.Fatal(err)
+			}
+
+			if ok, err := s.Visited("GET https://example.com/a"); (err != nil) || !ok {
+				t.Fatalf("got %v %v, want %v %v", ok, err, true, nil)
+			}
+
+			if _, _, ok, _ := s.RobotsTxt("example.com"); ok {
+				t.Fatal("robots.txt must not be stored")
+			}
+
+			if err := s.SetRobotsTxt("example.com", 200, []byte("User-agent: *")); err != nil {
+				t.Fatal(err)
+			}
+
+			statusCode, data, ok, err := s.RobotsTxt("example.com")
+			if (err != nil) || !ok || (statusCode != 200) || (string(data) != "User-agent: *") {
+				t.Fatalf("got %v %q %v %v, want %v %q %v %v", statusCode, data, ok, err, 200, "User-agent: *", true, nil)
+			}
+
+			jar, err := s.Jar()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			u := mustNewURL("https://example.com/")
+			jar.SetCookies(u, []*http.Cookie{{Name: "id", Value: "1"}})
+			if cookies := jar.Cookies(u); (len(cookies) != 1) || (cookies[0].Value != "1") {
+				t.Fatalf("unexpected cookies %v", cookies)
+			}
+		})
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+
+	file, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := mustNewURL("https://example.com/")
+	jar, err := file.Jar()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "S1", MaxAge: 3600},
+		{Name: "expired", Value: "E1", Expires: time.Now().Add(-time.Hour)},
+	})
+
+	file.MarkVisited("GET https://example.com/a")
+	file.MarkVisited("GET https://example.com/a")
+	file.MarkVisited("GET https://example.com/b")
+	file.SetRobotsTxt("example.com", 404, nil)
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.MarkVisited("GET https://example.com/c"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("got %v, want %v", err, ErrClosed)
+	}
+
+	// line interrupted by the end of the process
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"Visited":"GET https://exa`)
+	f.Close()
+
+	file, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if file.Len() != 2 {
+		t.Fatalf("got %v, want %v", file.Len(), 2)
+	}
+
+	if statusCode, _, ok, _ := file.RobotsTxt("example.com"); !ok || (statusCode != 404) {
+		t.Fatalf("got %v %v, want %v %v", statusCode, ok, 404, true)
+	}
+
+	jar, err = file.Jar()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cookies := jar.Cookies(u); (len(cookies) != 1) || (cookies[0].Value != "S1") {
+		t.Fatalf("unexpected cookies %v", cookies)
+	}
+}
+
+func TestFileWithCodec(t *testing.T) {
+	for _, codec := range []compress.Codec{compress.Gzip, compress.Zstd} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			testFileWithCodec(t, codec)
+		})
+	}
+}
+
+func testFileWithCodec(t *testing.T, codec compress.Codec) {
+	path := filepath.Join(t.TempDir(), "state")
+
+	file, err := OpenFileWithCodec(path, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.MarkVisited("GET https://example.com/a")
+	file.MarkVisited("GET https://example.com/b")
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the existing file keeps its codec
+	file, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.MarkVisited("GET https://example.com/c")
+
+	// the process ends without Close, the stream is incomplete
+	file.file.Close()
+
+	file, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Len() != 3 {
+		t.Fatalf("got %v, want %v", file.Len(), 3)
+	}
+	file.MarkVisited("GET https://example.com/d")
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := compress.Detect(data); got != codec {
+		t.Fatalf("got %v, want %v", got, codec)
+	}
+
+	file, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if file.Len() != 4 {
+		t.Fatalf("got %v, want %v", file.Len(), 4)
+	}
+}
+
+func mustNewURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
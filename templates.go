@@ -0,0 +1,93 @@
+package colibri
+
+// Templates contains the ready-made rule templates by name.
+var Templates = map[string]func(rawURL string) (*Rules, error){
+	"article": TemplateArticle,
+	"product": TemplateProduct,
+	"sitemap": TemplateSitemap,
+	"rss":     TemplateRSS,
+}
+
+// TemplateArticle returns rules to extract the title, author, publication date,
+// description, image and paragraphs of an HTML article.
+func TemplateArticle(rawURL string) (*Rules, error) {
+	return NewRules(RawRules{
+		KeyMethod: "GET",
+		KeyURL:    rawURL,
+		KeySelectors: map[string]any{
+			"title":       "(//meta[@property='og:title']/@content | //h1)[1]",
+			"author":      "(//meta[@name='author']/@content | //*[@rel='author'])[1]",
+			"published":   "(//meta[@property='article:published_time']/@content | //time/@datetime)[1]",
+			"description": "(//meta[@property='og:description']/@content | //meta[@name='description']/@content)[1]",
+			"image":       "//meta[@property='og:image']/@content",
+			"paragraphs": map[string]any{
+				KeyExpr: "//article//p",
+				KeyType: "xpath",
+				KeyAll:  true,
+			},
+		},
+	})
+}
+
+// TemplateProduct returns rules to extract the name, price, currency, availability,
+// SKU, description and image of an HTML product page with schema.org microdata or Open Graph tags.
+func TemplateProduct(rawURL string) (*Rules, error) {
+	return NewRules(RawRules{
+		KeyMethod: "GET",
+		KeyURL:    rawURL,
+		KeySelectors: map[string]any{
+			"name":         "(//*[@itemprop='name'] | //meta[@property='og:title']/@content | //h1)[1]",
+			"price":        "(//*[@itemprop='price']/@content | //*[@itemprop='price'] | //meta[@property='product:price:amount']/@content)[1]",
+			"currency":     "(//*[@itemprop='priceCurrency']/@content | //meta[@property='product:price:currency']/@content)[1]",
+			"availability": "(//*[@itemprop='availability']/@href | //*[@itemprop='availability']/@content)[1]",
+			"sku":          "(//*[@itemprop='sku']/@content | //*[@itemprop='sku'])[1]",
+			"description":  "(//*[@itemprop='description'] | //meta[@name='description']/@content)[1]",
+			"image":        "(//meta[@property='og:image']/@content | //*[@itemprop='image']/@src)[1]",
+		},
+	})
+}
+
+// TemplateSitemap returns rules to extract the URLs and the nested sitemaps of a sitemap.xml.
+func TemplateSitemap(rawURL string) (*Rules, error) {
+	return NewRules(RawRules{
+		KeyMethod: "GET",
+		KeyURL:    rawURL,
+		KeySelectors: map[string]any{
+			"urls": map[string]any{
+				KeyExpr: "//url/loc",
+				KeyType: "xpath",
+				KeyAll:  true,
+			},
+			"sitemaps": map[string]any{
+				KeyExpr: "//sitemap/loc",
+				KeyType: "xpath",
+				KeyAll:  true,
+			},
+		},
+	})
+}
+
+// TemplateRSS returns rules to extract the channel and the items of an RSS feed.
+func TemplateRSS(rawURL string) (*Rules, error) {
+	return NewRules(RawRules{
+		KeyMethod: "GET",
+		KeyURL:    rawURL,
+		KeySelectors: map[string]any{
+			"title":       "//channel/title",
+			"link":        "//channel/link",
+			"description": "//channel/description",
+			"items": map[string]any{
+				KeyExpr: "//channel/item",
+				KeyType: "xpath",
+				KeyAll:  true,
+				KeySelectors: map[string]any{
+					"title":       "title",
+					"link":        "link",
+					"description": "description",
+					"pubDate":     "pubDate",
+					"guid":        "guid",
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,208 @@
+package colibri
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrMustBeConvTLS is returned when the value is not convertible to TLS.
+	ErrMustBeConvTLS = errors.New("must be a map of TLS options")
+
+	// ErrTLSVersion is returned when the TLS version is unknown, see TLS.MinVersion.
+	ErrTLSVersion = errors.New("unknown TLS version")
+
+	// ErrNoCertificates is returned when a root CA does not contain certificates, see TLS.RootCAs.
+	ErrNoCertificates = errors.New("no certificates found")
+)
+
+// tlsVersions are the TLS versions by name, see TLS.MinVersion.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLS configures the TLS connections of the requests, e.g. to request internal
+// endpoints with self-signed certificates. The raw TLS is a map with the names
+// of the fields as keys, the MinVersion is a string, e.g. "1.2".
+type TLS struct {
+	// InsecureSkipVerify specifies whether the certificate of the server is not verified.
+	InsecureSkipVerify bool
+
+	// CertFile and KeyFile are the PEM files of the client certificate and its private key.
+	CertFile string
+	KeyFile  string
+
+	// RootCAs are the PEM files, or the PEM encoded certificates, of the root CAs
+	// used to verify the certificate of the server. If empty, the system roots are used.
+	RootCAs []string
+
+	// MinVersion is the minimum TLS version, e.g. tls.VersionTLS12.
+	// Zero means the default of crypto/tls.
+	MinVersion uint16
+}
+
+// Clone returns a copy of the TLS, nil if the TLS is nil.
+func (t *TLS) Clone() *TLS {
+	if t == nil {
+		return nil
+	}
+
+	newTLS := *t
+	newTLS.RootCAs = slices.Clone(t.RootCAs)
+	return &newTLS
+}
+
+// Config returns the TLS configuration loading the certificates,
+// the fields of base are used as default values. The base is not modified.
+func (t *TLS) Config(base *tls.Config) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if base != nil {
+		cfg = base.Clone()
+	}
+
+	if t == nil {
+		return cfg, nil
+	}
+
+	if t.InsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+	}
+
+	if t.MinVersion != 0 {
+		cfg.MinVersion = t.MinVersion
+	}
+
+	if (t.CertFile != "") || (t.KeyFile != "") {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(t.RootCAs) > 0 {
+		pool := x509.NewCertPool()
+		for _, rootCA := range t.RootCAs {
+			data := []byte(rootCA)
+			if !strings.HasPrefix(strings.TrimSpace(rootCA), "-----BEGIN") {
+				var err error
+				if data, err = os.ReadFile(rootCA); err != nil {
+					return nil, err
+				}
+			}
+
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, ErrNoCertificates
+			}
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// raw returns the raw TLS.
+func (t *TLS) raw() map[string]any {
+	raw := make(map[string]any)
+	setRaw(raw, "InsecureSkipVerify", t.InsecureSkipVerify, t.InsecureSkipVerify)
+	setRaw(raw, "CertFile", t.CertFile, t.CertFile != "")
+	setRaw(raw, "KeyFile", t.KeyFile, t.KeyFile != "")
+	setRaw(raw, "RootCAs", slices.Clone(t.RootCAs), len(t.RootCAs) > 0)
+
+	for name, version := range tlsVersions {
+		if (t.MinVersion != 0) && (version == t.MinVersion) {
+			raw["MinVersion"] = name
+		}
+	}
+	return raw
+}
+
+// toTLS converts a value to a *TLS.
+func toTLS(value any) (*TLS, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+
+	case *TLS:
+		return v, nil
+
+	case map[string]any:
+		t := &TLS{}
+		for key, rawValue := range v {
+			var err error
+			switch key {
+			case "InsecureSkipVerify":
+				t.InsecureSkipVerify, err = toBool(rawValue)
+
+			case "CertFile", "KeyFile":
+				str, ok := rawValue.(string)
+				if !ok {
+					err = ErrMustBeString
+				} else if key == "CertFile" {
+					t.CertFile = str
+				} else {
+					t.KeyFile = str
+				}
+
+			case "RootCAs":
+				t.RootCAs, err = toStrings(rawValue)
+
+			case "MinVersion":
+				t.MinVersion, err = toTLSVersion(rawValue)
+
+			default:
+				err = ErrMustBeConvTLS
+			}
+
+			if err != nil {
+				return nil, AddError(nil, key, err)
+			}
+		}
+		return t, nil
+	}
+	return nil, ErrMustBeConvTLS
+}
+
+// toStrings converts a string or a list of strings to a []string.
+func toStrings(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+
+	case []string:
+		return slices.Clone(v), nil
+
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			str, ok := e.(string)
+			if !ok {
+				return nil, ErrMustBeString
+			}
+			values = append(values, str)
+		}
+		return values, nil
+	}
+	return nil, ErrMustBeString
+}
+
+// toTLSVersion converts a version name, e.g. "1.2", to a TLS version.
+func toTLSVersion(value any) (uint16, error) {
+	str, ok := value.(string)
+	if !ok {
+		return 0, ErrMustBeString
+	}
+
+	str = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(str)), "TLS")
+	version, ok := tlsVersions[strings.TrimSpace(str)]
+	if !ok {
+		return 0, ErrTLSVersion
+	}
+	return version, nil
+}
@@ -0,0 +1,81 @@
+package colibri
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+)
+
+// LogTraceID is the attribute of the events logged with the trace ID of the request.
+const LogTraceID = "trace_id"
+
+// traceKey is the key of the context in which the trace ID is stored.
+type traceKey struct{}
+
+// WithTraceID returns the context with the trace ID, it is used instead of a new one
+// by the requests and the extractions made with the context, e.g. the ID of the
+// request of a queue or of a distributed crawl.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, traceKey{}, id)
+}
+
+// TraceID returns the trace ID of the context, empty if it does not have one.
+// The context of the responses has the trace ID of the request.
+func TraceID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(traceKey{}).(string)
+	return id
+}
+
+// NewTraceID returns a new random trace ID of 32 hexadecimal characters.
+func NewTraceID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// TraceIDOf returns the trace ID of the error, the Errs of the extractions
+// and the Warnings have the trace ID of the request. Empty if it does not have one.
+func TraceIDOf(err error) string {
+	var errs *Errs
+	if errors.As(err, &errs) {
+		return errs.TraceID()
+	}
+
+	var w *Warning
+	if errors.As(err, &w) {
+		return w.TraceID
+	}
+	return ""
+}
+
+// withTraceID returns the context with a new trace ID, if it does not have one.
+func withTraceID(ctx context.Context) context.Context {
+	if TraceID(ctx) != "" {
+		return ctx
+	}
+	return WithTraceID(ctx, NewTraceID())
+}
+
+// traceAttrs returns the attributes with the trace ID of the context, if any.
+func traceAttrs(ctx context.Context, attrs []slog.Attr) []slog.Attr {
+	if id := TraceID(ctx); id != "" {
+		return append(attrs, slog.String(LogTraceID, id))
+	}
+	return attrs
+}
+
+// setTraceID sets the trace ID of the context to the Errs of the extraction.
+func setTraceID(ctx context.Context, err error) {
+	if errs, ok := err.(*Errs); ok {
+		errs.SetTraceID(TraceID(ctx))
+	}
+}
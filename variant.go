@@ -0,0 +1,156 @@
+package colibri
+
+import (
+	"errors"
+	"strconv"
+)
+
+// KeyDetect is the key of the raw variants that stores the Detect selector.
+const KeyDetect = "Detect"
+
+var (
+	// ErrInvalidVariants is returned when the variants are not a list of variants.
+	ErrInvalidVariants = errors.New("must be a list of variants")
+
+	// ErrInvalidVariant is returned when the variant does not have a name and a Detect selector.
+	ErrInvalidVariant = errors.New("variant must have a name and a Detect selector")
+)
+
+// Variant is an alternative set of selectors for one of the layouts of the pages,
+// e.g. for sites that serve different layouts to different users.
+// The selectors of the first variant whose Detect selector finds a value are used
+// instead of the selectors of the rules.
+type Variant struct {
+	Name string
+
+	// Detect selector that finds a value in the pages with the layout of the variant,
+	// e.g. the presence of a marker element.
+	Detect *Selector
+
+	// Selectors used to extract the data of the pages with the layout of the variant.
+	Selectors []*Selector
+}
+
+// Clone returns a copy of the original variant.
+func (variant *Variant) Clone() *Variant {
+	newVariant := &Variant{
+		Name:      variant.Name,
+		Selectors: CloneSelectors(variant.Selectors),
+	}
+
+	if variant.Detect != nil {
+		newVariant.Detect = variant.Detect.Clone()
+	}
+	return newVariant
+}
+
+// CloneVariants clones the variants.
+func CloneVariants(variants []*Variant) []*Variant {
+	var result []*Variant
+	for _, variant := range variants {
+		result = append(result, variant.Clone())
+	}
+	return result
+}
+
+// raw returns the raw variant.
+func (variant *Variant) raw() map[string]any {
+	raw := map[string]any{KeyName: variant.Name}
+	if variant.Detect != nil {
+		raw[KeyDetect] = variant.Detect.raw()
+	}
+
+	setRaw(raw, KeySelectors, variant.Selectors, len(variant.Selectors) > 0)
+	return raw
+}
+
+// newVariants returns the variants of the raw list, the order is kept.
+// The errors are stored with the names of the variants as keys,
+// or their positions if they do not have a name.
+func newVariants(rawVariants any, convFunc ConvFunc) ([]*Variant, error) {
+	if rawVariants == nil {
+		return nil, nil
+	}
+
+	list, ok := rawVariants.([]any)
+	if !ok {
+		return nil, ErrInvalidVariants
+	}
+
+	var (
+		variants = make([]*Variant, 0, len(list))
+		errs     error
+	)
+	for i, value := range list {
+		variant, err := newVariant(value, convFunc)
+		if err != nil {
+			key := strconv.Itoa(i)
+			if (variant != nil) && (variant.Name != "") {
+				key = variant.Name
+			}
+
+			errs = AddError(errs, key, err)
+			continue
+		}
+		variants = append(variants, variant)
+	}
+	return variants, errs
+}
+
+func newVariant(rawVariant any, convFunc ConvFunc) (*Variant, error) {
+	raw, ok := rawVariant.(map[string]any)
+	if !ok {
+		return nil, ErrInvalidVariant
+	}
+
+	variant := &Variant{}
+	variant.Name, _ = raw[KeyName].(string)
+	if (variant.Name == "") || (raw[KeyDetect] == nil) {
+		return variant, ErrInvalidVariant
+	}
+
+	var errs error
+	detect, err := newSelector(variant.Name, raw[KeyDetect], convFunc)
+	if err != nil {
+		errs = AddError(errs, KeyDetect, err)
+	} else if detect == nil {
+		errs = AddError(errs, KeyDetect, ErrInvalidSelector)
+	}
+	variant.Detect = detect
+
+	variant.Selectors, err = newSelectors(raw[KeySelectors], convFunc)
+	if err != nil {
+		errs = AddError(errs, KeySelectors, err)
+	}
+	return variant, errs
+}
+
+// hasSelectors returns true if the rules have selectors or variants.
+func (rules *Rules) hasSelectors() bool {
+	return (len(rules.Selectors) > 0) || (len(rules.Variants) > 0)
+}
+
+// selectorNames returns the names of the selectors of the rules and then
+// the names of the selectors of the variants, without duplicates.
+func (rules *Rules) selectorNames() []string {
+	var (
+		names []string
+		seen  = make(map[string]bool)
+	)
+	add := func(selectors []*Selector) {
+		for _, selector := range selectors {
+			if (selector != nil) && !seen[selector.Name] {
+				seen[selector.Name] = true
+				names = append(names, selector.Name)
+			}
+		}
+	}
+
+	add(rules.Selectors)
+	for _, variant := range rules.Variants {
+		if variant != nil {
+			add(variant.Selectors)
+		}
+	}
+	return names
+}
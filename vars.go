@@ -0,0 +1,160 @@
+package colibri
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUndeclaredVar is returned when a selector sets a variable that is not declared in the rules.
+var ErrUndeclaredVar = errors.New("variable is not declared")
+
+// varRegexp matches the references to the variables, "{{name}}", also escaped in the URLs.
+var varRegexp = regexp.MustCompile(`(?i)(?:\{\{|%7B%7B)\s*([\w.\-]+)\s*(?:\}\}|%7D%7D)`)
+
+// Vars stores the variables of a crawl, declared in the Vars of the rules with their initial values.
+// The selectors with a Var field set the variable to the value found, see KeyVar, and the references
+// "{{name}}" of the URL and the header of the rules are replaced by the values before each request.
+// The clones of the rules and the rules of the selectors share the Vars, so the values captured
+// on a page are used by the requests of the following pages, e.g. a token used in a header.
+type Vars struct {
+	rw     sync.RWMutex
+	values map[string]string
+}
+
+// NewVars returns new Vars with the declared variables and their initial values.
+func NewVars(values map[string]string) *Vars {
+	vars := &Vars{values: make(map[string]string, len(values))}
+	for name, value := range values {
+		vars.values[name] = value
+	}
+	return vars
+}
+
+// Get returns the value of the variable and true if it is declared.
+func (vars *Vars) Get(name string) (string, bool) {
+	vars.rw.RLock()
+	defer vars.rw.RUnlock()
+
+	value, ok := vars.values[name]
+	return value, ok
+}
+
+// Set sets the value of the variable, the values that are not strings are formatted with fmt.Sprint.
+// Returns ErrUndeclaredVar if the variable is not declared.
+func (vars *Vars) Set(name string, value any) error {
+	s, ok := value.(string)
+	if !ok {
+		s = fmt.Sprint(value)
+	}
+
+	vars.rw.Lock()
+	defer vars.rw.Unlock()
+
+	if _, ok := vars.values[name]; !ok {
+		return ErrUndeclaredVar
+	}
+	vars.values[name] = s
+	return nil
+}
+
+// Names returns the names of the declared variables sorted in increasing order.
+func (vars *Vars) Names() []string {
+	vars.rw.RLock()
+	names := make([]string, 0, len(vars.values))
+	for name := range vars.values {
+		names = append(names, name)
+	}
+	vars.rw.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// Values returns a copy of the values of the variables.
+func (vars *Vars) Values() map[string]string {
+	vars.rw.RLock()
+	defer vars.rw.RUnlock()
+
+	values := make(map[string]string, len(vars.values))
+	for name, value := range vars.values {
+		values[name] = value
+	}
+	return values
+}
+
+// Expand replaces the references "{{name}}" of s by the values of the variables.
+// The references to variables that are not declared are not replaced.
+func (vars *Vars) Expand(s string) string {
+	return vars.expand(s, nil)
+}
+
+func (vars *Vars) expand(s string, escape func(string) string) string {
+	if !strings.Contains(s, "{{") && !strings.Contains(strings.ToUpper(s), "%7B%7B") {
+		return s
+	}
+
+	vars.rw.RLock()
+	defer vars.rw.RUnlock()
+
+	return varRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		value, ok := vars.values[varRegexp.FindStringSubmatch(ref)[1]]
+		if !ok {
+			return ref
+		}
+
+		if escape != nil {
+			return escape(value)
+		}
+		return value
+	})
+}
+
+// expandRules returns the rules with the references to the variables of the URL and the header replaced,
+// the values are escaped in the URL. If there are no references, the rules are returned.
+func (vars *Vars) expandRules(rules *Rules) (*Rules, error) {
+	var (
+		expanded = *rules
+		changed  bool
+		cloned   bool
+	)
+
+	if rules.URL != nil {
+		rawURL := rules.URL.String()
+		if s := vars.expand(rawURL, escapeURLVar); s != rawURL {
+			u, err := ToURL(s)
+			if err != nil {
+				return nil, AddError(nil, KeyURL, err)
+			}
+			expanded.URL, changed = u, true
+		}
+	}
+
+	for key, values := range rules.Header {
+		for i, value := range values {
+			s := vars.expand(value, nil)
+			if s == value {
+				continue
+			}
+
+			if !cloned {
+				expanded.Header, cloned = rules.Header.Clone(), true
+			}
+			expanded.Header[key][i], changed = s, true
+		}
+	}
+
+	if !changed {
+		return rules, nil
+	}
+	return &expanded, nil
+}
+
+// escapeURLVar escapes the value of a variable used in a URL, valid in the path and in the query.
+func escapeURLVar(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
@@ -0,0 +1,75 @@
+package colibri
+
+import (
+	"runtime"
+	"sort"
+)
+
+// version of Colibri.
+const version = "0.1.0"
+
+// Version returns the version of Colibri.
+func Version() string {
+	return version
+}
+
+// Capabilities reports the version of Colibri and the features available
+// in the Client, Delay, RobotsTxt and Parser of a Colibri.
+type Capabilities struct {
+	// Version of Colibri.
+	Version string `json:"version"`
+
+	// GoVersion Go version used to build Colibri.
+	GoVersion string `json:"goVersion"`
+
+	// Parsers Content-Type expressions of the registered parsers.
+	Parsers []string `json:"parsers,omitempty"`
+
+	// ExprTypes expression types supported by the parsers.
+	ExprTypes []string `json:"exprTypes,omitempty"`
+
+	// Features features of the components, e.g. "cookies" or "sessions".
+	Features []string `json:"features,omitempty"`
+}
+
+// CapabilityReporter is implemented by the components of Colibri
+// that report their capabilities.
+type CapabilityReporter interface {
+	// ReportCapabilities adds the capabilities of the component to the report.
+	ReportCapabilities(caps *Capabilities)
+}
+
+// Capabilities returns the report of the capabilities of the components
+// that implement the CapabilityReporter interface.
+// The lists of the report are sorted and without duplicates.
+func (c *Colibri) Capabilities() Capabilities {
+	caps := Capabilities{
+		Version:   version,
+		GoVersion: runtime.Version(),
+	}
+
+	for _, component := range []any{c.Client, c.Delay, c.RobotsTxt, c.activeParser()} {
+		ReportCapabilitiesOf(component, &caps)
+	}
+
+	caps.Parsers = sortUnique(caps.Parsers)
+	caps.ExprTypes = sortUnique(caps.ExprTypes)
+	caps.Features = sortUnique(caps.Features)
+	return caps
+}
+
+func sortUnique(s []string) []string {
+	if len(s) == 0 {
+		return s
+	}
+
+	sort.Strings(s)
+
+	result := s[:1]
+	for _, v := range s[1:] {
+		if v != result[len(result)-1] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+package colibri
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// Kinds of the Warnings.
+const (
+	// WarningRobotsTxt the robots.txt does not allow the request.
+	WarningRobotsTxt = "robots.txt"
+
+	// WarningFiltered the URL found by a Follow selector is not allowed by the filters, see FollowAllowed.
+	WarningFiltered = "filtered"
+
+	// WarningSkipped the response was rejected by a FetchFilter, see SkipError.
+	WarningSkipped = "skipped"
+
+	// WarningEmpty the selector did not find any element.
+	WarningEmpty = "empty"
+
+	// WarningTruncated the response body exceeds the MaxBodySize of the rules.
+	WarningTruncated = "truncated"
+)
+
+// Warning is an expected condition reported separately from the errors,
+// e.g. a URL blocked by the robots.txt. It is also an error, so it can be
+// returned by Do and Extract, see AsWarning.
+type Warning struct {
+	// Kind of the warning, e.g. WarningRobotsTxt.
+	Kind string
+
+	// URL of the request or of the response.
+	URL *url.URL
+
+	// Key is the name of the selector, if any.
+	Key string
+
+	// Err is the cause of the warning, if any.
+	Err error
+
+	// TraceID is the trace ID of the request, see WithTraceID.
+	TraceID string
+}
+
+// Error returns the message of the cause or, if there is none, the kind and the URL of the warning.
+func (w *Warning) Error() string {
+	if w.Err != nil {
+		return w.Err.Error()
+	}
+
+	msg := "warning " + w.Kind
+	if w.Key != "" {
+		msg += " (" + w.Key + ")"
+	}
+
+	if w.URL != nil {
+		msg += ": " + w.URL.String()
+	}
+	return msg
+}
+
+func (w *Warning) Unwrap() error {
+	return w.Err
+}
+
+// AsWarning returns the Warning of the error, true if the error is an expected condition:
+// a *Warning or a *SkipError of the FetchFilters.
+func AsWarning(err error) (*Warning, bool) {
+	if _, ok := err.(*Errs); ok {
+		// the errors of a parse, they are not a single condition
+		return nil, false
+	}
+
+	var w *Warning
+	if errors.As(err, &w) {
+		return w, true
+	}
+
+	var skipErr *SkipError
+	if errors.As(err, &skipErr) {
+		return &Warning{Kind: WarningSkipped, URL: skipErr.URL, Err: skipErr}, true
+	}
+	return nil, false
+}
+
+// Warn reports the warning to the OnWarning of the Colibri that made the request
+// with the context, e.g. the context of a response. If the context was not used
+// by a Colibri with OnWarning, the warning is discarded.
+// The parsers report the Warnings of the Follow requests with it instead of adding them to the Errs.
+func Warn(ctx context.Context, warning *Warning) {
+	if (ctx == nil) || (warning == nil) {
+		return
+	}
+
+	if c, ok := ctx.Value(warnKey{}).(*Colibri); ok {
+		if warning.TraceID == "" {
+			warning.TraceID = TraceID(ctx)
+		}
+		c.OnWarning(ctx, warning)
+	}
+}
+
+// warnKey is the key of the context in which the Colibri with OnWarning is stored, see Warn.
+type warnKey struct{}
+
+// withWarnings returns the context with the Colibri, if it has OnWarning.
+func (c *Colibri) withWarnings(ctx context.Context) context.Context {
+	if c.OnWarning == nil {
+		return ctx
+	}
+
+	if prev, ok := ctx.Value(warnKey{}).(*Colibri); ok && (prev == c) {
+		return ctx
+	}
+	return context.WithValue(ctx, warnKey{}, c)
+}
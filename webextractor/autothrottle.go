@@ -0,0 +1,200 @@
+package webextractor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+const (
+	// DefaultAutoThrottleStartDelay default delay of the hosts without responses.
+	DefaultAutoThrottleStartDelay = time.Second
+
+	// DefaultAutoThrottleMaxDelay default maximum delay of a host.
+	DefaultAutoThrottleMaxDelay = time.Minute
+
+	// DefaultAutoThrottleTargetConcurrency default number of requests that should be
+	// sent in parallel to each host.
+	DefaultAutoThrottleTargetConcurrency = 1.0
+)
+
+// AutoThrottle is a Delay that adjusts the delay of each host to its response times,
+// like the AutoThrottle of Scrapy. The delay of a host tends to the latency of its responses
+// divided by TargetConcurrency, the responses with errors do not reduce it and the 429
+// (Too Many Requests) and 503 (Service Unavailable) responses double it, or set it to
+// their Retry-After header. The delay of the rules is the minimum delay.
+// The requests to the same host are serialized like with ReqDelay.
+// See the colibri.Delay and colibri.DelayObserver interfaces.
+type AutoThrottle struct {
+	*ReqDelay
+
+	// StartDelay specifies the delay of the hosts without responses.
+	StartDelay time.Duration
+
+	// MaxDelay specifies the maximum delay of a host, including the Retry-After.
+	MaxDelay time.Duration
+
+	// TargetConcurrency specifies the average number of requests that should be
+	// sent in parallel to each host, the higher the value, the shorter the delay.
+	TargetConcurrency float64
+
+	mu     sync.Mutex
+	delays map[string]time.Duration
+	lru    *hostLRU
+}
+
+// NewAutoThrottle returns a new AutoThrottle structure.
+func NewAutoThrottle() *AutoThrottle {
+	return &AutoThrottle{
+		ReqDelay:          NewReqDelay(),
+		StartDelay:        DefaultAutoThrottleStartDelay,
+		MaxDelay:          DefaultAutoThrottleMaxDelay,
+		TargetConcurrency: DefaultAutoThrottleTargetConcurrency,
+		delays:            make(map[string]time.Duration),
+		lru:               newHostLRU(),
+	}
+}
+
+func (at *AutoThrottle) Wait(u *url.URL, duration time.Duration) {
+	at.WaitContext(context.Background(), u, duration)
+}
+
+// WaitContext is like Wait, but it returns the error of the context
+// if the context is cancelled before the wait ends.
+// The delay is the longest between the duration and the delay of the host.
+// See the colibri.DelayContext interface.
+func (at *AutoThrottle) WaitContext(ctx context.Context, u *url.URL, duration time.Duration) error {
+	return at.ReqDelay.WaitContext(ctx, u, max(duration, at.HostDelay(u.Host)))
+}
+
+// ObserveResponse adjusts the delay of the host of the URL with the response.
+// The failed requests do not change it, except those that return a RateLimitedError.
+// See the colibri.DelayObserver interface.
+func (at *AutoThrottle) ObserveResponse(u *url.URL, resp colibri.Response, latency time.Duration, err error) {
+	var rateLimitedErr *RateLimitedError
+	if (u == nil) || (((resp == nil) || (err != nil)) && !errors.As(err, &rateLimitedErr)) {
+		return
+	}
+
+	var (
+		host  = colibri.NormalizeHost(u.Host)
+		delay = at.HostDelay(host)
+	)
+
+	switch {
+	case rateLimitedErr != nil:
+		delay = max(delay, rateLimitedErr.RetryAfter)
+
+	case rateLimitStatus(resp.StatusCode()):
+		if retryAfter, ok := RetryAfter(resp); ok {
+			delay = max(delay, retryAfter)
+		} else {
+			delay = max(2*delay, at.StartDelay)
+		}
+
+	default:
+		targetConcurrency := at.TargetConcurrency
+		if targetConcurrency <= 0 {
+			targetConcurrency = DefaultAutoThrottleTargetConcurrency
+		}
+
+		target := time.Duration(float64(latency) / targetConcurrency)
+		newDelay := max(target, (delay+target)/2)
+
+		// the responses with errors do not reduce the delay
+		if (resp.StatusCode() >= 400) && (newDelay < delay) {
+			return
+		}
+		delay = newDelay
+	}
+
+	if (at.MaxDelay > 0) && (delay > at.MaxDelay) {
+		delay = at.MaxDelay
+	}
+
+	at.mu.Lock()
+	at.setDelay(host, delay)
+	at.mu.Unlock()
+}
+
+// HostDelay returns the current delay of the host, StartDelay if it has no responses.
+func (at *AutoThrottle) HostDelay(host string) time.Duration {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	if delay, ok := at.delays[colibri.NormalizeHost(host)]; ok {
+		return delay
+	}
+	return at.StartDelay
+}
+
+func (at *AutoThrottle) Clear() {
+	at.ReqDelay.Clear()
+
+	at.mu.Lock()
+	clear(at.delays)
+	if at.lru != nil {
+		at.lru.clear()
+	}
+	at.mu.Unlock()
+}
+
+// ReportCapabilities adds the "delay" and "auto-throttle" features to the report.
+// See the colibri.CapabilityReporter interface.
+func (at *AutoThrottle) ReportCapabilities(caps *colibri.Capabilities) {
+	caps.Features = append(caps.Features, "delay", "auto-throttle")
+}
+
+// setDelay stores the delay of the host and removes the least recently
+// used hosts while the MaxEntries of the ReqDelay is exceeded.
+// The caller must hold the lock.
+func (at *AutoThrottle) setDelay(host string, delay time.Duration) {
+	if at.delays == nil {
+		at.delays = make(map[string]time.Duration)
+	}
+
+	if at.lru == nil {
+		at.lru = newHostLRU()
+	}
+
+	at.delays[host] = delay
+	at.lru.touch(host)
+
+	for (at.MaxEntries > 0) && (at.lru.len() > at.MaxEntries) {
+		oldest, ok := at.lru.oldest(func(h string) bool { return h != host })
+		if !ok {
+			return
+		}
+
+		at.lru.remove(oldest)
+		delete(at.delays, oldest)
+	}
+}
+
+// parseRetryAfter returns the delay of the Retry-After header, in seconds or as an HTTP date.
+func parseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	v := strings.TrimSpace(header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
@@ -0,0 +1,123 @@
+package webextractor
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+func TestAutoThrottle(t *testing.T) {
+	var (
+		at = NewAutoThrottle()
+		u  = mustNewURL("https://example.com")
+	)
+	at.StartDelay = time.Second
+	at.MaxDelay = 10 * time.Second
+
+	tests := []struct {
+		StatusCode int
+		RetryAfter string
+		Latency    time.Duration
+		Err        error
+		Want       time.Duration
+	}{
+		{http.StatusOK, "", 200 * time.Millisecond, nil, 600 * time.Millisecond},
+		{http.StatusOK, "", 200 * time.Millisecond, nil, 400 * time.Millisecond},
+		{http.StatusInternalServerError, "", 100 * time.Millisecond, nil, 400 * time.Millisecond},
+		{http.StatusTooManyRequests, "", 0, nil, time.Second},
+		{http.StatusTooManyRequests, "", 0, nil, 2 * time.Second},
+		{http.StatusServiceUnavailable, "5", 0, nil, 5 * time.Second},
+		{0, "", 0, &RateLimitedError{RetryAfter: 8 * time.Second}, 8 * time.Second},
+		{http.StatusServiceUnavailable, "120", 0, nil, 10 * time.Second},
+		{0, "", time.Millisecond, errors.New("timeout"), 10 * time.Second},
+	}
+
+	if got := at.HostDelay(u.Host); got != at.StartDelay {
+		t.Fatalf(gotWantFormat, got, at.StartDelay)
+	}
+
+	for i, tt := range tests {
+		var resp colibri.Response
+		if tt.Err == nil {
+			header := http.Header{}
+			if tt.RetryAfter != "" {
+				header.Set("Retry-After", tt.RetryAfter)
+			}
+			resp = &Response{HTTP: &http.Response{StatusCode: tt.StatusCode, Header: header}}
+		}
+
+		at.ObserveResponse(u, resp, tt.Latency, tt.Err)
+		if got := at.HostDelay(u.Host); got != tt.Want {
+			t.Fatalf("#%d: got %v, want %v", i, got, tt.Want)
+		}
+	}
+
+	at.Clear()
+	if got := at.HostDelay(u.Host); got != at.StartDelay {
+		t.Fatalf(gotWantFormat, got, at.StartDelay)
+	}
+}
+
+func TestAutoThrottleColibri(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.RobotsTxt = nil // Deactivate RobotsTxt
+
+	at := NewAutoThrottle()
+	at.StartDelay = time.Millisecond
+	we.Delay = at
+
+	// the requests without delay are observed
+	u := mustNewURL(ts.URL)
+	if _, err := we.Do(&colibri.Rules{Method: "GET", URL: u}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := at.HostDelay(u.Host); got < 10*time.Millisecond {
+		t.Fatalf("got %v, want at least %v", got, 10*time.Millisecond)
+	}
+
+	start := time.Now()
+	if _, err := we.Do(&colibri.Rules{Method: "GET", URL: u}); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("got %v, want at least %v", elapsed, 30*time.Millisecond)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		Value string
+		Want  time.Duration
+		OK    bool
+	}{
+		{"", 0, false},
+		{"30", 30 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(http.Header{"Retry-After": {tt.Value}}, now)
+		if (got != tt.Want) || (ok != tt.OK) {
+			t.Fatalf("%q: got %v %v, want %v %v", tt.Value, got, ok, tt.Want, tt.OK)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package webextractor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/compress"
+)
+
+// cacheExt extension of the files of the responses stored on disk.
+const cacheExt = ".json"
+
+// cacheableStatus are the status codes of the responses that can be stored.
+var cacheableStatus = []int{
+	http.StatusOK,
+	http.StatusNonAuthoritativeInfo,
+	http.StatusNoContent,
+	http.StatusMultipleChoices,
+	http.StatusMovedPermanently,
+	http.StatusNotFound,
+	http.StatusMethodNotAllowed,
+	http.StatusGone,
+	http.StatusRequestURITooLong,
+	http.StatusNotImplemented,
+}
+
+// Cache stores the HTTP responses in memory or on disk.
+// Only the responses to GET and HEAD requests are stored, the requests are
+// identified by the method, the URL and the header.
+// The freshness of the responses is calculated with the Cache-Control
+// (no-store, no-cache, max-age) and Expires headers, the stale responses
+// are revalidated with the ETag and Last-Modified headers.
+// See the colibri.Cache and colibri.CacheValidator interfaces.
+type Cache struct {
+	// DefaultTTL specifies the time during which the responses without
+	// Cache-Control max-age or Expires headers are fresh.
+	// Zero means that they are stale and are revalidated.
+	DefaultTTL time.Duration
+
+	store cacheStore
+}
+
+// NewMemoryCache returns a new Cache that stores the responses in memory.
+func NewMemoryCache() *Cache {
+	return &Cache{store: &memoryStore{entries: make(map[string]*cacheEntry)}}
+}
+
+// NewDiskCache returns a new Cache that stores the responses in the directory,
+// the directory is created if it does not exist.
+// The errors writing the responses on disk are ignored.
+func NewDiskCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{store: &diskStore{dir: dir}}, nil
+}
+
+// NewDiskCacheWithCodec returns a new Cache like NewDiskCache that stores the responses
+// compressed with the codec, e.g. compress.Default. The responses stored with
+// another codec, or without compression, are still loaded.
+func NewDiskCacheWithCodec(dir string, codec compress.Codec) (*Cache, error) {
+	cache, err := NewDiskCache(dir)
+	if err != nil {
+		return nil, err
+	}
+	cache.store.(*diskStore).codec = codec
+	return cache, nil
+}
+
+// Get returns the fresh response stored for the rules.
+func (cache *Cache) Get(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, bool) {
+	if !cacheableRequest(rules) || hasDirective(rules.Header, "no-cache") {
+		return nil, false
+	}
+
+	entry, ok := cache.getStore().load(cacheKey(rules))
+	if !ok || !time.Now().Before(entry.Expires) {
+		return nil, false
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Response{HTTP: entry.httpResponse(rules.Method, nil), c: c, ctx: ctx, cached: true}, true
+}
+
+// Validators returns the If-None-Match and If-Modified-Since headers
+// used to revalidate the stale response stored for the rules.
+func (cache *Cache) Validators(rules *colibri.Rules) http.Header {
+	if !cacheableRequest(rules) {
+		return nil
+	}
+
+	entry, ok := cache.getStore().load(cacheKey(rules))
+	if !ok {
+		return nil
+	}
+
+	header := http.Header{}
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+
+	if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+	}
+	return header
+}
+
+// Set stores the response obtained with the rules.
+// If the status code is 304 (Not Modified), the stored response is updated
+// with the header of the response and returned.
+func (cache *Cache) Set(rules *colibri.Rules, resp colibri.Response) (colibri.Response, error) {
+	if (resp == nil) || !cacheableRequest(rules) {
+		return resp, nil
+	}
+
+	var (
+		store = cache.getStore()
+		key   = cacheKey(rules)
+		now   = time.Now()
+	)
+
+	if resp.StatusCode() == http.StatusNotModified {
+		entry, ok := store.load(key)
+		if !ok {
+			return resp, nil
+		}
+
+		discardBody(&http.Response{Body: resp.Body()})
+		for k, values := range resp.Header() {
+			entry.Header[k] = values
+		}
+
+		entry.Expires, _ = freshUntil(entry.Header, now, cache.DefaultTTL)
+		store.save(key, entry)
+		return entry.response(rules.Method, resp), nil
+	}
+
+	expires, ok := freshUntil(resp.Header(), now, cache.DefaultTTL)
+	if !ok || !slices.Contains(cacheableStatus, resp.StatusCode()) {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body())
+	resp.Body().Close()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &cacheEntry{
+		URL:        resp.URL().String(),
+		StatusCode: resp.StatusCode(),
+		Header:     resp.Header().Clone(),
+		Body:       body,
+		Expires:    expires,
+	}
+	store.save(key, entry)
+
+	if r, ok := resp.(*Response); ok {
+		r.HTTP.Body = io.NopCloser(bytes.NewReader(body))
+		return r, nil
+	}
+	return &storedResponse{Response: resp, entry: entry, body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+// Clear removes the stored responses.
+func (cache *Cache) Clear() {
+	cache.getStore().clear()
+}
+
+// ReportCapabilities adds the "cache" feature to the report.
+// See the colibri.CapabilityReporter interface.
+func (cache *Cache) ReportCapabilities(caps *colibri.Capabilities) {
+	caps.Features = append(caps.Features, "cache")
+}
+
+func (cache *Cache) getStore() cacheStore {
+	if cache.store == nil {
+		cache.store = &memoryStore{entries: make(map[string]*cacheEntry)}
+	}
+	return cache.store
+}
+
+// cacheEntry represents a stored response.
+type cacheEntry struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	Expires    time.Time   `json:"expires"`
+}
+
+// httpResponse returns an *http.Response with the stored data.
+// If req is nil, a request with the method and the stored URL is used.
+func (entry *cacheEntry) httpResponse(method string, req *http.Request) *http.Response {
+	if req == nil {
+		u, _ := url.Parse(entry.URL)
+		req = &http.Request{Method: method, URL: u, Header: http.Header{}}
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(entry.StatusCode) + " " + http.StatusText(entry.StatusCode),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
+
+// response returns the stored response with the data of the response
+// used to revalidate it.
+func (entry *cacheEntry) response(method string, resp colibri.Response) colibri.Response {
+	if r, ok := resp.(*Response); ok {
+		return &Response{HTTP: entry.httpResponse(method, r.HTTP.Request), c: r.c, tr: r.tr, ctx: r.ctx, cached: true}
+	}
+	return &storedResponse{Response: resp, entry: entry, body: io.NopCloser(bytes.NewReader(entry.Body))}
+}
+
+// storedResponse is a response of another HTTP client with the stored data.
+type storedResponse struct {
+	colibri.Response
+	entry *cacheEntry
+	body  io.ReadCloser
+}
+
+func (resp *storedResponse) StatusCode() int {
+	return resp.entry.StatusCode
+}
+
+func (resp *storedResponse) Header() http.Header {
+	return resp.entry.Header
+}
+
+func (resp *storedResponse) Body() io.ReadCloser {
+	return resp.body
+}
+
+// cacheableRequest returns true if the response to the request can be stored.
+func cacheableRequest(rules *colibri.Rules) bool {
+	if (rules == nil) || (rules.URL == nil) || hasDirective(rules.Header, "no-store") {
+		return false
+	}
+
+	method := strings.ToUpper(rules.Method)
+	return (method == "") || (method == http.MethodGet) || (method == http.MethodHead)
+}
+
+// cacheKey returns the key of the request, a hash of the method,
+// the URL without the fragment and the header.
+func cacheKey(rules *colibri.Rules) string {
+	method := strings.ToUpper(rules.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	u := *rules.URL
+	u.Fragment, u.RawFragment = "", ""
+	u.Host = colibri.NormalizeHost(u.Host)
+
+	h := sha256.New()
+	io.WriteString(h, method+" "+u.String()+"\n")
+
+	keys := make([]string, 0, len(rules.Header))
+	for key := range rules.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		io.WriteString(h, http.CanonicalHeaderKey(key)+": "+strings.Join(rules.Header[key], ",")+"\n")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// freshUntil returns the time until which the response is fresh,
+// false if the response must not be stored.
+func freshUntil(header http.Header, now time.Time, defaultTTL time.Duration) (time.Time, bool) {
+	if hasDirective(header, "no-store") {
+		return time.Time{}, false
+	}
+
+	if hasDirective(header, "no-cache") {
+		return now, true
+	}
+
+	if maxAge, ok := directiveValue(header, "max-age"); ok {
+		seconds, err := strconv.ParseInt(maxAge, 10, 64)
+		if err != nil {
+			return now, true
+		}
+
+		if age, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil {
+			seconds -= age
+		}
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+
+	if v := header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return now, true
+		}
+
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			return now.Add(expires.Sub(date)), true
+		}
+		return expires, true
+	}
+
+	return now.Add(defaultTTL), true
+}
+
+// hasDirective returns true if the Cache-Control header has the directive.
+func hasDirective(header http.Header, directive string) bool {
+	_, ok := directiveValue(header, directive)
+	return ok
+}
+
+// directiveValue returns the value of the directive of the Cache-Control header.
+func directiveValue(header http.Header, directive string) (string, bool) {
+	for _, v := range header.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if strings.EqualFold(name, directive) {
+				return strings.Trim(value, `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+// cacheStore stores the entries of the cache.
+type cacheStore interface {
+	load(key string) (*cacheEntry, bool)
+	save(key string, entry *cacheEntry)
+	clear()
+}
+
+// memoryStore stores the entries in memory.
+type memoryStore struct {
+	rw      sync.RWMutex
+	entries map[string]*cacheEntry
+}
+
+func (store *memoryStore) load(key string) (*cacheEntry, bool) {
+	store.rw.RLock()
+	entry, ok := store.entries[key]
+	store.rw.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	e := *entry
+	e.Header = entry.Header.Clone()
+	return &e, true
+}
+
+func (store *memoryStore) save(key string, entry *cacheEntry) {
+	store.rw.Lock()
+	store.entries[key] = entry
+	store.rw.Unlock()
+}
+
+func (store *memoryStore) clear() {
+	store.rw.Lock()
+	clear(store.entries)
+	store.rw.Unlock()
+}
+
+// diskStore stores the entries in JSON files in the directory,
+// compressed with the codec if it is not nil.
+type diskStore struct {
+	mu    sync.Mutex
+	dir   string
+	codec compress.Codec
+}
+
+func (store *diskStore) load(key string) (*cacheEntry, bool) {
+	b, err := os.ReadFile(filepath.Join(store.dir, key+cacheExt))
+	if err != nil {
+		return nil, false
+	}
+
+	if b, err = compress.Decompress(b); err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, false
+	}
+
+	if entry.Header == nil {
+		entry.Header = http.Header{}
+	}
+	return &entry, true
+}
+
+func (store *diskStore) save(key string, entry *cacheEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	if b, err = compress.Compress(store.codec, b); err != nil {
+		return
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	tmp, err := os.CreateTemp(store.dir, key+"-*.tmp")
+	if err != nil {
+		return
+	}
+
+	_, err = tmp.Write(b)
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(store.dir, key+cacheExt))
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (store *diskStore) clear() {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	filepath.WalkDir(store.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if d.IsDir() {
+			if path != store.dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(path) == cacheExt {
+			os.Remove(path)
+		}
+		return nil
+	})
+}
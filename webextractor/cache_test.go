@@ -0,0 +1,110 @@
+package webextractor
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/compress"
+)
+
+func TestCache(t *testing.T) {
+	var requests, notModified atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	disk, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gzipDisk, err := NewDiskCacheWithCodec(t.TempDir(), compress.Gzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Path            string
+		WantRequests    int32
+		WantNotModified int32
+		WantCached      bool
+	}{
+		{"/max-age", 1, 0, true},
+		{"/etag", 3, 2, true},
+		{"/no-store", 3, 0, false},
+	}
+
+	for name, cache := range map[string]*Cache{"Memory": NewMemoryCache(), "Disk": disk, "GzipDisk": gzipDisk} {
+		t.Run(name, func(t *testing.T) {
+			c, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.RobotsTxt = nil
+			c.Cache = cache
+
+			for _, tt := range tests {
+				requests.Store(0)
+				notModified.Store(0)
+
+				var cached bool
+				for i := 0; i < 3; i++ {
+					rules := &colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + tt.Path)}
+					resp, err := c.Do(rules)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					body, err := io.ReadAll(resp.Body())
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if string(body) != tt.Path {
+						t.Fatalf(prefixGotWantFormat, tt.Path, string(body), tt.Path)
+					} else if resp.StatusCode() != http.StatusOK {
+						t.Fatalf(prefixGotWantFormat, tt.Path, resp.StatusCode(), http.StatusOK)
+					}
+					cached = resp.(*Response).Cached()
+				}
+
+				if got := requests.Load(); got != tt.WantRequests {
+					t.Fatalf(prefixGotWantFormat, tt.Path, got, tt.WantRequests)
+				} else if got := notModified.Load(); got != tt.WantNotModified {
+					t.Fatalf(prefixGotWantFormat, tt.Path, got, tt.WantNotModified)
+				} else if cached != tt.WantCached {
+					t.Fatalf(prefixGotWantFormat, tt.Path, cached, tt.WantCached)
+				}
+			}
+
+			c.Clear()
+			requests.Store(0)
+			if _, err := c.Do(&colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + "/max-age")}); err != nil {
+				t.Fatal(err)
+			} else if got := requests.Load(); got != 1 {
+				t.Fatalf(gotWantFormat, got, 1)
+			}
+		})
+	}
+}
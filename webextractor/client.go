@@ -1,9 +1,14 @@
 package webextractor
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptrace"
 	"net/url"
 	"sync"
 	"time"
@@ -11,12 +16,24 @@ import (
 	"github.com/eduardogxnzalez/colibri"
 	"github.com/eduardogxnzalez/colibri/parsers"
 
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
 	"golang.org/x/net/publicsuffix"
 )
 
 // DefaultTimeout default timeout used for HTTP requests.
 const DefaultTimeout = 5 * time.Second
 
+// DefaultMaxRedirects default maximum number of redirects followed.
+const DefaultMaxRedirects = 10
+
+// DefaultRedirectStripHeaders are the headers removed by default
+// from the requests redirected to another host.
+var DefaultRedirectStripHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// ErrTooManyRedirects is returned when the maximum number of redirects is exceeded.
+var ErrTooManyRedirects = errors.New("too many redirects")
+
 // New returns a new Colibri structure with default values.
 // Returns an error if an error occurs when initializing the values.
 func New(cookieJar ...http.CookieJar) (*colibri.Colibri, error) {
@@ -44,7 +61,59 @@ type Client struct {
 	// Jar specifies the cookie jar.
 	Jar http.CookieJar
 
+	// MaxRedirects specifies the maximum number of redirects followed.
+	// If zero, DefaultMaxRedirects is used, a negative value disables redirects.
+	MaxRedirects int
+
+	// RedirectStripHeaders specifies the headers removed from
+	// the requests redirected to another host.
+	// If nil, DefaultRedirectStripHeaders is used.
+	RedirectStripHeaders []string
+
+	// CheckRedirect specifies a function called before following each redirect,
+	// if it returns an error, the redirect is not followed and the error is returned.
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+
+	// DialContext specifies the dial function used to create the connections.
+	// If nil, a net.Dialer is used.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// HostOverride maps hosts ("host:port" or "host") to the addresses ("IP:port" or "IP")
+	// used to connect, without modifying the URL, the Host header or the TLS server name.
+	// If the address does not have a port, the port of the request is used.
+	HostOverride map[string]string
+
+	// BlockPrivateIPs specifies whether the connections to private, loopback,
+	// link-local and reserved addresses are refused, see IsPublicAddr.
+	// The hosts are resolved and verified before each connection, including the redirects.
+	// If a proxy is used, the address of the proxy is verified.
+	BlockPrivateIPs bool
+
+	// MaxRetryBackoff specifies the maximum delay between retries, see Rules.Retries.
+	// If zero, DefaultMaxRetryBackoff is used.
+	MaxRetryBackoff time.Duration
+
+	// Decoders specifies the decoders of the content encodings of the response bodies,
+	// the encodings are sent in the Accept-Encoding header if the rules do not set it.
+	// See Rules.DisableCompression. If nil, DefaultDecoders is used.
+	Decoders map[string]Decoder
+
+	// Revisit stores the validators of the responses and sends them in the next
+	// requests to the same URL, see Response.NotModified. If nil, the requests are not conditional.
+	Revisit *Revisit
+
+	// Options configures the transport, see ClientOptions.
+	// It must not be modified after the first request.
+	Options ClientOptions
+
 	pool sync.Pool
+
+	rw         sync.RWMutex
+	sessions   map[string]http.CookieJar
+	tlsConfigs map[string]*tls.Config // by Rules.TLS, see tlsConfig
+	h2         map[*tls.Config]*http2.Transport
+	h2c        *http2.Transport
+	h3         map[*tls.Config]*http3.RoundTripper
 }
 
 // NewClient returns a new Client structure.
@@ -65,14 +134,48 @@ func NewClient(cookieJar ...http.CookieJar) (*Client, error) {
 	return &client, nil
 }
 
+// NewClientWithOptions returns a new Client structure with the transport options, see NewClient.
+func NewClientWithOptions(opts ClientOptions, cookieJar ...http.CookieJar) (*Client, error) {
+	client, err := NewClient(cookieJar...)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Options = opts
+	return client, nil
+}
+
 // Do performs an HTTP request according to the rules.
 func (client *Client) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
-	httpClient := client.getClient(rules.Proxy)
+	return client.DoContext(context.Background(), c, rules)
+}
+
+// DoContext performs an HTTP request according to the rules with the context.
+// The context is stored in the response and used in the requests made with it.
+// See the colibri.HTTPClientContext interface.
+func (client *Client) DoContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	if err := client.checkProxy(rules.URL, rules.Proxy); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := client.tlsConfig(rules.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := client.getClient(rules.Proxy, tlsConfig)
 	defer client.pool.Put(httpClient)
 
 	// CookieJar
 	if rules.UseCookies {
 		httpClient.Jar = client.Jar
+		if rules.Session != "" {
+			jar, err := client.SessionJar(rules.Session)
+			if err != nil {
+				return nil, err
+			}
+			httpClient.Jar = jar
+		}
 	} else {
 		httpClient.Jar = nil
 	}
@@ -85,23 +188,151 @@ func (client *Client) Do(c *colibri.Colibri, rules *colibri.Rules) (colibri.Resp
 	}
 
 	// Request
-	req, err := httpRequest(rules)
+	req, err := httpRequest(ctx, rules)
 	if err != nil {
 		return nil, err
 	}
 
+	if !rules.DisableCompression {
+		client.acceptEncoding(req)
+	}
+
+	if client.Revisit != nil {
+		client.Revisit.conditional(req)
+	}
+
 	// Response
-	resp, err := httpClient.Do(req)
+	var waited time.Duration
+	for attempt := 0; ; {
+		tr := newTracer()
+		resp, err := httpClient.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), tr.clientTrace())))
+		if retryAfter, ok := rateLimited(rules, resp, err); ok {
+			discardBody(resp)
+			if waited+retryAfter > rules.MaxWait {
+				return nil, &RateLimitedError{URL: resp.Request.URL, StatusCode: resp.StatusCode, RetryAfter: retryAfter}
+			}
+
+			if err := sleepContext(ctx, retryAfter); err != nil {
+				return nil, err
+			}
+			waited += retryAfter
+			continue
+		}
+
+		if (attempt >= rules.Retries) || !shouldRetry(ctx, rules, resp, err) {
+			if err != nil {
+				return nil, err
+			}
+			if !rules.DisableCompression {
+				if err := client.decodeBody(resp); err != nil {
+					return nil, err
+				}
+			}
+
+			if err := limitBody(resp, rules); err != nil {
+				return nil, err
+			}
+
+			if client.Revisit != nil {
+				client.Revisit.update(req, resp)
+			}
+
+			tr.done(resp)
+			return &Response{HTTP: resp, c: c, tr: tr, ctx: ctx}, nil
+		}
+
+		if resp != nil {
+			discardBody(resp)
+		}
+
+		if err := client.waitRetry(ctx, rules, attempt); err != nil {
+			return nil, err
+		}
+		attempt++
+	}
+}
+
+// SessionJar returns the cookie jar of the session.
+// If the session does not have a cookie jar, a new cookiejar.Jar is initialized.
+func (client *Client) SessionJar(session string) (http.CookieJar, error) {
+	client.rw.RLock()
+	jar, ok := client.sessions[session]
+	client.rw.RUnlock()
+	if ok {
+		return jar, nil
+	}
+
+	client.rw.Lock()
+	defer client.rw.Unlock()
+
+	if jar, ok := client.sessions[session]; ok {
+		return jar, nil
+	}
+
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 	if err != nil {
 		return nil, err
 	}
-	return &Response{HTTP: resp, c: c}, nil
+
+	if client.sessions == nil {
+		client.sessions = make(map[string]http.CookieJar)
+	}
+	client.sessions[session] = jar
+	return jar, nil
+}
+
+// DeleteSession removes the cookie jar of the session.
+func (client *Client) DeleteSession(session string) {
+	client.rw.Lock()
+	delete(client.sessions, session)
+	client.rw.Unlock()
+}
+
+// Clear assigns nil to Jar, removes the cookie jars of the sessions, the TLS configurations
+// of the rules and the validators of the Revisit, and closes the idle HTTP/2 connections.
+func (client *Client) Clear() {
+	client.Jar = nil
+	client.closeIdleConnections()
+
+	if client.Revisit != nil {
+		client.Revisit.Clear()
+	}
+
+	client.rw.Lock()
+	clear(client.sessions)
+	clear(client.tlsConfigs)
+	clear(client.h2)
+	for _, t := range client.h3 {
+		t.Close()
+	}
+	clear(client.h3)
+	client.rw.Unlock()
 }
 
-// Clear assigns nil to Jar.
-func (client *Client) Clear() { client.Jar = nil }
+// ReportCapabilities adds the features of the client to the report.
+// See the colibri.CapabilityReporter interface.
+func (client *Client) ReportCapabilities(caps *colibri.Capabilities) {
+	caps.Features = append(caps.Features,
+		"cookies", "sessions", "login", "totp", "proxy", "unix-proxy",
+		"redirects", "host-override", "timing", "retries", "ssrf-guard", "compression",
+	)
+
+	if client.Revisit != nil {
+		caps.Features = append(caps.Features, "revisit")
+	}
 
-func (client *Client) getClient(proxyURL *url.URL) *http.Client {
+	if !client.Options.DisableHTTP2 {
+		caps.Features = append(caps.Features, "http2")
+	}
+
+	if client.Options.EnableHTTP3 && (client.Options.HTTP3Transport != nil) {
+		caps.Features = append(caps.Features, "http3")
+	}
+}
+
+// getClient returns an http.Client of the pool with the proxy and the TLS configuration.
+// If tlsConfig is nil, the TLS configuration of the Options is used.
+func (client *Client) getClient(proxyURL *url.URL, tlsConfig *tls.Config) *http.Client {
 	var httpClient *http.Client
 	if v := client.pool.Get(); v != nil {
 		httpClient = v.(*http.Client)
@@ -109,36 +340,128 @@ func (client *Client) getClient(proxyURL *url.URL) *http.Client {
 		httpClient = &http.Client{}
 	}
 
-	t, ok := httpClient.Transport.(*http.Transport)
-	if (httpClient.Transport == nil) || !ok {
-		t = defaultTransport()
+	var t *http.Transport
+	switch tr := httpClient.Transport.(type) {
+	case *http.Transport:
+		t = tr
+	case *protocolTransport:
+		t = tr.transport
+	default:
+		t = defaultTransport(client.dialContext)
+		client.Options.applyTo(t)
 	}
 
-	if proxyURL != nil {
+	switch {
+	case proxyURL == nil:
+		t.Proxy = http.ProxyFromEnvironment
+	case proxyURL.Scheme == unixScheme:
+		t.Proxy = nil
+	default:
 		t.Proxy = http.ProxyURL(proxyURL)
 	}
 
+	// the transports of the pool are used by rules with different TLS configurations
+	t.TLSClientConfig = client.Options.transportTLSConfig(tlsConfig)
+
 	httpClient.Transport = t
+	if client.Options.usesProtocolTransport() {
+		httpClient.Transport = &protocolTransport{client: client, transport: t, tlsConfig: tlsConfig}
+	}
+
+	httpClient.CheckRedirect = client.checkRedirect
 	return httpClient
 }
 
-func httpRequest(rules *colibri.Rules) (*http.Request, error) {
-	req, err := http.NewRequest(rules.Method, rules.URL.String(), nil /* Body */)
+// tlsConfig returns the TLS configuration of the rules based on the TLSConfig of the Options,
+// nil if the rules do not have TLS. The configurations are stored, so the certificates
+// are loaded once for each TLS, see Clear.
+func (client *Client) tlsConfig(rulesTLS *colibri.TLS) (*tls.Config, error) {
+	if rulesTLS == nil {
+		return nil, nil
+	}
+
+	key := fmt.Sprint(*rulesTLS)
+	client.rw.RLock()
+	cfg, ok := client.tlsConfigs[key]
+	client.rw.RUnlock()
+	if ok {
+		return cfg, nil
+	}
+
+	cfg, err := rulesTLS.Config(client.Options.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client.rw.Lock()
+	defer client.rw.Unlock()
+
+	if client.tlsConfigs == nil {
+		client.tlsConfigs = make(map[string]*tls.Config)
+	}
+	client.tlsConfigs[key] = cfg
+	return cfg, nil
+}
+
+// checkProxy returns ErrProxyUnsupported if the proxy is not supported
+// by the protocol used to request the URL, see ClientOptions.
+func (client *Client) checkProxy(u, proxyURL *url.URL) error {
+	if proxyURL == nil {
+		return nil
+	}
+
+	if client.Options.ForceHTTP2 || (client.Options.EnableHTTP3 && (u != nil) && (u.Scheme == "https")) {
+		return ErrProxyUnsupported
+	}
+	return nil
+}
+
+func (client *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	maxRedirects := client.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+
+	if maxRedirects < 0 {
+		return http.ErrUseLastResponse
+	} else if len(via) > maxRedirects {
+		return ErrTooManyRedirects
+	}
+
+	if client.CheckRedirect != nil {
+		if err := client.CheckRedirect(req, via); err != nil {
+			return &redirectError{err}
+		}
+	}
+
+	if req.URL.Host == via[0].URL.Host {
+		return nil
+	}
+
+	stripHeaders := client.RedirectStripHeaders
+	if stripHeaders == nil {
+		stripHeaders = DefaultRedirectStripHeaders
+	}
+
+	for _, header := range stripHeaders {
+		req.Header.Del(header)
+	}
+	return nil
+}
+
+func httpRequest(ctx context.Context, rules *colibri.Rules) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, rules.Method, rules.URL.String(), nil /* Body */)
 	if err != nil {
 		return nil, err
 	}
 	req.Header = rules.Header
-	return req, nil
+	return withUnixSocket(req, rules.Proxy), nil
 }
 
-func defaultTransport() *http.Transport {
+func defaultTransport(dialContext func(ctx context.Context, network, addr string) (net.Conn, error)) *http.Transport {
 	return &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialContext,
 		TLSHandshakeTimeout:   10 * time.Second,
 		DisableKeepAlives:     true,
 		MaxIdleConns:          1,
@@ -146,5 +469,6 @@ func defaultTransport() *http.Transport {
 		IdleConnTimeout:       30 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 		ForceAttemptHTTP2:     true,
+		DisableCompression:    true, // see Client.Decoders
 	}
 }
@@ -1,65 +1,125 @@
 package webextractor
 
 import (
+	"context"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/eduardogxnzalez/colibri"
 )
 
 // ReqDelay manages the delay between each HTTP request.
 // See the colibri.Delay interface.
 type ReqDelay struct {
+	// MaxEntries specifies the maximum number of hosts stored.
+	// When the limit is exceeded, the least recently used idle host is removed.
+	// Zero means no limit.
+	MaxEntries int
+
 	rw        sync.RWMutex
 	timestamp map[string]int64
 	done      map[string]chan struct{}
+	waiting   map[string]int // requests waiting for the token of the host
+	lru       *hostLRU
+	evictions atomic.Uint64
 }
 
 // NewReqDelay returns a new ReqDelay structure.
 func NewReqDelay() *ReqDelay {
 	return &ReqDelay{
-		timestamp: make(map[string]int64),
-		done:      make(map[string]chan struct{}),
+		MaxEntries: DefaultMaxEntries,
+		timestamp:  make(map[string]int64),
+		done:       make(map[string]chan struct{}),
+		waiting:    make(map[string]int),
+		lru:        newHostLRU(),
 	}
 }
 
 func (rd *ReqDelay) Wait(u *url.URL, duration time.Duration) {
-	rd.rw.RLock()
-	ch, ok := rd.done[u.Host]
-	rd.rw.RUnlock()
+	rd.WaitContext(context.Background(), u, duration)
+}
 
-	if ok {
-		<-ch
+// WaitContext is like Wait, but it returns the error of the context
+// if the context is cancelled before the wait ends.
+// If an error is returned, Done must not be called.
+// See the colibri.DelayContext interface.
+func (rd *ReqDelay) WaitContext(ctx context.Context, u *url.URL, duration time.Duration) error {
+	host := colibri.NormalizeHost(u.Host)
 
+	// the first request of the host takes the token of the new channel,
+	// the next ones wait for it and are counted, so the host is not removed meanwhile
+	rd.rw.Lock()
+	ch, ok := rd.done[host]
+	if ok {
+		rd.waiting[host]++
 	} else {
-		rd.rw.Lock()
-		rd.done[u.Host] = make(chan struct{}, 1)
-		rd.rw.Unlock()
+		rd.done[host] = make(chan struct{}, 1)
+		rd.touch(host)
+	}
+	rd.rw.Unlock()
+
+	if ok {
+		select {
+		case <-ch:
+			rd.unwait(host)
+		case <-ctx.Done():
+			rd.unwait(host)
+			return ctx.Err()
+		}
 	}
 
 	rd.rw.RLock()
-	timestamp, ok := rd.timestamp[u.Host]
+	timestamp, ok := rd.timestamp[host]
 	rd.rw.RUnlock()
 
 	if ok {
 		diff := duration.Milliseconds() - (time.Now().UnixMilli() - timestamp)
 		if diff > 0 {
-			time.Sleep(time.Duration(diff) * time.Millisecond)
+			timer := time.NewTimer(time.Duration(diff) * time.Millisecond)
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				rd.Done(u)
+				return ctx.Err()
+			}
 		}
 	}
+	return nil
+}
+
+// unwait removes a request from the requests waiting for the token of the host.
+func (rd *ReqDelay) unwait(host string) {
+	rd.rw.Lock()
+	defer rd.rw.Unlock()
+
+	if n := rd.waiting[host]; n > 1 {
+		rd.waiting[host] = n - 1
+	} else {
+		delete(rd.waiting, host)
+	}
 }
 
 func (rd *ReqDelay) Done(u *url.URL) {
+	host := colibri.NormalizeHost(u.Host)
+
 	rd.rw.Lock()
 	select {
-	case rd.done[u.Host] <- struct{}{}:
+	case rd.done[host] <- struct{}{}:
 	default:
 	}
 	rd.rw.Unlock()
 }
 
 func (rd *ReqDelay) Stamp(u *url.URL) {
+	host := colibri.NormalizeHost(u.Host)
+
 	rd.rw.Lock()
-	rd.timestamp[u.Host] = time.Now().UnixMilli()
+	rd.timestamp[host] = time.Now().UnixMilli()
+	rd.touch(host)
 	rd.rw.Unlock()
 }
 
@@ -71,12 +131,71 @@ func (rd *ReqDelay) Clear() {
 		close(rd.done[host])
 		delete(rd.done, host)
 	}
+	clear(rd.waiting)
+
+	if rd.lru != nil {
+		rd.lru.clear()
+	}
 	rd.rw.Unlock()
 }
 
+// ReportCapabilities adds the "delay" feature to the report.
+// See the colibri.CapabilityReporter interface.
+func (rd *ReqDelay) ReportCapabilities(caps *colibri.Capabilities) {
+	caps.Features = append(caps.Features, "delay")
+}
+
+// Len returns the number of hosts stored.
+func (rd *ReqDelay) Len() int {
+	rd.rw.RLock()
+	defer rd.rw.RUnlock()
+
+	if rd.lru == nil {
+		return len(rd.timestamp)
+	}
+	return rd.lru.len()
+}
+
+// Evictions returns the number of hosts removed because MaxEntries was exceeded.
+func (rd *ReqDelay) Evictions() uint64 {
+	return rd.evictions.Load()
+}
+
+// touch marks the host as recently used and removes the least recently used
+// idle hosts while MaxEntries is exceeded. Hosts whose token is taken or awaited,
+// i.e. with a request in progress or waiting, are not removed.
+// The caller must hold the write lock.
+func (rd *ReqDelay) touch(host string) {
+	if rd.lru == nil {
+		rd.lru = newHostLRU()
+	}
+	rd.lru.touch(host)
+
+	for (rd.MaxEntries > 0) && (rd.lru.len() > rd.MaxEntries) {
+		oldest, ok := rd.lru.oldest(func(h string) bool {
+			if h == host {
+				return false
+			}
+
+			ch, ok := rd.done[h]
+			return !ok || ((len(ch) > 0) && (rd.waiting[h] == 0))
+		})
+		if !ok {
+			return
+		}
+
+		rd.lru.remove(oldest)
+		delete(rd.timestamp, oldest)
+		delete(rd.done, oldest)
+		rd.evictions.Add(1)
+	}
+}
+
 func (rd *ReqDelay) visit(u *url.URL) bool {
+	host := colibri.NormalizeHost(u.Host)
+
 	rd.rw.RLock()
-	_, ok := rd.timestamp[u.Host]
+	_, ok := rd.timestamp[host]
 	rd.rw.RUnlock()
 	return ok
 }
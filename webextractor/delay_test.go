@@ -1,6 +1,8 @@
 package webextractor
 
 import (
+	"context"
+	"errors"
 	"net/url"
 	"testing"
 	"time"
@@ -69,3 +71,135 @@ func TestReqClear(t *testing.T) {
 		t.Fatal("Uncleaned")
 	}
 }
+
+func TestReqDelayMaxEntries(t *testing.T) {
+	var (
+		delay = NewReqDelay()
+		urls  = []*url.URL{
+			mustNewURL("https://pkg.go.dev"),
+			mustNewURL("https://go.dev"),
+			mustNewURL("https://example.com"),
+		}
+	)
+	delay.MaxEntries = 2
+
+	for _, u := range urls {
+		delay.Wait(u, 0)
+		delay.Done(u)
+		delay.Stamp(u)
+	}
+
+	if delay.Len() != 2 {
+		t.Fatalf(gotWantFormat, delay.Len(), 2)
+	} else if delay.Evictions() != 1 {
+		t.Fatalf(gotWantFormat, delay.Evictions(), 1)
+	}
+
+	if delay.visit(urls[0]) {
+		t.Fatal("least recently used host not removed")
+	}
+
+	t.Run("InProgress", func(t *testing.T) {
+		u := mustNewURL("https://go.googlesource.com")
+		delay.Wait(u, 0) // in progress
+
+		delay.Wait(urls[0], 0)
+		delay.Done(urls[0])
+		delay.Stamp(urls[0])
+
+		delay.Done(u)
+		if delay.Evictions() != 3 {
+			t.Fatalf(gotWantFormat, delay.Evictions(), 3)
+		}
+
+		delay.rw.RLock()
+		_, ok := delay.done[u.Host]
+		delay.rw.RUnlock()
+		if !ok {
+			t.Fatal("host with a request in progress removed")
+		}
+	})
+
+	t.Run("Waiting", func(t *testing.T) {
+		delay := NewReqDelay()
+		delay.MaxEntries = 1
+
+		u := mustNewURL("https://go.dev")
+		delay.Wait(u, 0) // in progress
+
+		// a request has found the channel of the host and has not received the token yet
+		delay.rw.Lock()
+		ch := delay.done[u.Host]
+		delay.waiting[u.Host]++
+		delay.rw.Unlock()
+
+		// the token is released, the host must not be removed while the request waits
+		other := mustNewURL("https://example.com")
+		delay.Done(u)
+		delay.Wait(other, 0)
+		delay.Done(other)
+
+		<-ch
+		delay.unwait(u.Host)
+
+		delay.rw.RLock()
+		_, ok := delay.done[u.Host]
+		delay.rw.RUnlock()
+		if !ok {
+			t.Fatal("host with a waiting request removed")
+		}
+	})
+}
+
+func TestReqDelayIDN(t *testing.T) {
+	var (
+		delay    = NewReqDelay()
+		unicode  = mustNewURL("https://Bücher.example")
+		punycode = mustNewURL("https://xn--bcher-kva.example")
+	)
+
+	delay.Wait(unicode, 0)
+	delay.Done(unicode)
+	delay.Stamp(unicode)
+
+	if !delay.visit(punycode) {
+		t.Fatal("IDN host stored with a different key")
+	} else if delay.Len() != 1 {
+		t.Fatalf(gotWantFormat, delay.Len(), 1)
+	}
+}
+
+func TestReqDelayWaitContext(t *testing.T) {
+	var (
+		delay = NewReqDelay()
+		u     = mustNewURL("https://pkg.go.dev")
+	)
+
+	delay.Wait(u, 0)
+	delay.Done(u)
+	delay.Stamp(u)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := delay.WaitContext(ctx, u, time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf(gotWantFormat, err, context.DeadlineExceeded)
+	} else if time.Since(start) > time.Second {
+		t.Fatal("wait not cancelled")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- delay.WaitContext(context.Background(), u, 0)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("host not released after the cancelled wait")
+	}
+}
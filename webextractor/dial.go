@@ -0,0 +1,92 @@
+package webextractor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// unixScheme is the proxy scheme used to send the requests through a unix domain socket,
+// e.g. unix:///var/run/docker.sock.
+const unixScheme = "unix"
+
+var defaultDialer = &net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+}
+
+type unixSocketKey struct{}
+
+// withUnixSocket stores in the request context the socket path of the unix proxy.
+func withUnixSocket(req *http.Request, proxyURL *url.URL) *http.Request {
+	if (proxyURL == nil) || (proxyURL.Scheme != unixScheme) {
+		return req
+	}
+
+	path := proxyURL.Path
+	if path == "" {
+		path = proxyURL.Opaque
+	}
+	return req.WithContext(context.WithValue(req.Context(), unixSocketKey{}, path))
+}
+
+func (client *Client) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if path, ok := ctx.Value(unixSocketKey{}).(string); ok {
+		if client.BlockPrivateIPs {
+			return nil, fmt.Errorf("%w: %s", ErrBlockedAddress, path)
+		}
+		network, addr = unixScheme, path
+	} else {
+		addr = client.overrideAddr(addr)
+	}
+
+	dial := defaultDialer.DialContext
+	if client.DialContext != nil {
+		dial = client.DialContext
+	}
+
+	if client.BlockPrivateIPs {
+		return client.dialPublic(ctx, network, addr, dial)
+	}
+	return dial(ctx, network, addr)
+}
+
+// overrideAddr returns the address of HostOverride corresponding to addr.
+func (client *Client) overrideAddr(addr string) string {
+	if len(client.HostOverride) == 0 {
+		return addr
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	override, ok := client.HostOverride[addr]
+	if !ok {
+		override, ok = client.HostOverride[host]
+	}
+
+	if !ok {
+		normalized := colibri.NormalizeHost(host)
+		override, ok = client.HostOverride[net.JoinHostPort(normalized, port)]
+		if !ok {
+			override, ok = client.HostOverride[normalized]
+		}
+	}
+
+	if !ok {
+		return addr
+	}
+
+	if _, _, err := net.SplitHostPort(override); err == nil {
+		return override
+	}
+	return net.JoinHostPort(strings.Trim(override, "[]"), port)
+}
@@ -0,0 +1,119 @@
+package webextractor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+func TestHostOverride(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil     // Deactivate Delay
+	we.RobotsTxt = nil // Deactivate RobotsTxt
+
+	addr := mustNewURL(ts.URL).Host
+
+	client := we.Client.(*Client)
+	client.HostOverride = map[string]string{
+		"colibri.test":    addr,
+		"staging.test:80": addr,
+	}
+
+	for _, rawURL := range []string{"http://colibri.test/", "http://staging.test/", "http://COLIBRI.test:8080/"} {
+		rules := &colibri.Rules{Method: "GET", URL: mustNewURL(rawURL)}
+
+		resp, err := we.Do(rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reqDump, err := http.ReadRequest(bufio.NewReader(resp.Body()))
+		if err != nil {
+			t.Fatal(err)
+		} else if reqDump.Host != rules.URL.Host {
+			t.Fatalf(prefixGotWantFormat, "Host", reqDump.Host, rules.URL.Host)
+		}
+	}
+
+	client.HostOverride["ip.test"] = "127.0.0.2"
+
+	tests := []struct {
+		Addr, Want string
+	}{
+		{"staging.test:443", "staging.test:443"},
+		{"other.test:80", "other.test:80"},
+		{"ip.test:443", "127.0.0.2:443"},
+	}
+
+	for _, tt := range tests {
+		if got := client.overrideAddr(tt.Addr); got != tt.Want {
+			t.Fatalf(gotWantFormat, got, tt.Want)
+		}
+	}
+}
+
+func TestUnixSocketAndDialContext(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "colibri.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Host+r.URL.Path)
+	})}
+	go server.Serve(l)
+	defer server.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil     // Deactivate Delay
+	we.RobotsTxt = nil // Deactivate RobotsTxt
+
+	var dials int
+	client := we.Client.(*Client)
+	client.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		if network != "unix" || addr != socket {
+			t.Errorf(gotWantFormat, network+" "+addr, "unix "+socket)
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+
+	rules, err := colibri.NewRules(colibri.RawRules{
+		"Method": "GET",
+		"URL":    "http://docker/containers/json",
+		"Proxy":  "unix://" + socket,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := we.Do(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64)
+	n, _ := resp.Body().Read(buf)
+	if string(buf[:n]) != "docker/containers/json" {
+		t.Fatalf(gotWantFormat, string(buf[:n]), "docker/containers/json")
+	} else if dials != 1 {
+		t.Fatalf(prefixGotWantFormat, "Dials", dials, 1)
+	}
+}
@@ -0,0 +1,148 @@
+package webextractor
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Decoder returns a reader of the content decoded from r.
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+// DefaultDecoders are the decoders of the content encodings used by default, see Client.Decoders.
+var DefaultDecoders = map[string]Decoder{
+	"gzip":    decodeGzip,
+	"deflate": decodeDeflate,
+	"br":      decodeBrotli,
+}
+
+func decodeGzip(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func decodeBrotli(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(brotli.NewReader(r)), nil
+}
+
+// decodeDeflate decodes the zlib format, as specified by HTTP, or the raw deflate
+// format sent by some servers.
+func decodeDeflate(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	if (header[0]&0x0f == 8) && ((uint16(header[0])<<8|uint16(header[1]))%31 == 0) {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// decodedBody is a response body decoded with one or more decoders.
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (body *decodedBody) Close() error {
+	var errs error
+	for i := len(body.closers) - 1; i >= 0; i-- {
+		errs = errors.Join(errs, body.closers[i].Close())
+	}
+	return errs
+}
+
+// decoders returns the decoders of the client.
+func (client *Client) decoders() map[string]Decoder {
+	if client.Decoders != nil {
+		return client.Decoders
+	}
+	return DefaultDecoders
+}
+
+// acceptEncoding sets the Accept-Encoding header of the request
+// with the encodings of the decoders if it is not set.
+func (client *Client) acceptEncoding(req *http.Request) {
+	if req.Header.Get("Accept-Encoding") != "" {
+		return
+	}
+
+	decoders := client.decoders()
+	if len(decoders) == 0 {
+		return
+	}
+
+	encodings := make([]string, 0, len(decoders))
+	for encoding := range decoders {
+		encodings = append(encodings, encoding)
+	}
+	sort.Strings(encodings)
+
+	// the header of the request is the header of the rules
+	req.Header = req.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set("Accept-Encoding", strings.Join(encodings, ", "))
+}
+
+// decodeBody decodes the body of the response with the decoders of its Content-Encoding.
+// The Content-Encoding and Content-Length headers are removed.
+// If an encoding is not supported, the body is not modified.
+func (client *Client) decodeBody(resp *http.Response) error {
+	var encodings []string
+	for _, value := range resp.Header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if (encoding != "") && (encoding != "identity") {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+
+	if (len(encodings) == 0) || (resp.Request.Method == http.MethodHead) ||
+		(resp.StatusCode == http.StatusNoContent) || (resp.StatusCode == http.StatusNotModified) {
+		return nil
+	}
+
+	decoders := client.decoders()
+	for _, encoding := range encodings {
+		if _, ok := decoders[encoding]; !ok {
+			return nil
+		}
+	}
+
+	body := &decodedBody{Reader: resp.Body, closers: []io.Closer{resp.Body}}
+
+	// the encodings are listed in the order in which they were applied
+	for i := len(encodings) - 1; i >= 0; i-- {
+		rc, err := decoders[encodings[i]](body.Reader)
+		if errors.Is(err, io.EOF) {
+			// empty body
+			body.Reader = http.NoBody
+			break
+		} else if err != nil {
+			body.Close()
+			return err
+		}
+
+		body.Reader = rc
+		body.closers = append(body.closers, rc)
+	}
+
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
@@ -0,0 +1,32 @@
+package webextractor
+
+import "github.com/eduardogxnzalez/colibri"
+
+// Codes of the errors of webextractor, see colibri.ErrorCode.
+const (
+	CodeBlockedAddress   = "BLOCKED_ADDRESS"
+	CodeTooManyRedirects = "TOO_MANY_REDIRECTS"
+	CodeLoginFailed      = "LOGIN_FAILED"
+	CodeRateLimited      = "RATE_LIMITED"
+)
+
+func init() {
+	for _, ec := range []struct {
+		err            error
+		code, category string
+	}{
+		{ErrorRobotstxtRestriction, colibri.CodeRobotsDenied, colibri.CategoryWarning},
+		{ErrBodyTooLarge, colibri.CodeBodyTruncated, colibri.CategoryWarning},
+		{ErrBlockedAddress, CodeBlockedAddress, colibri.CategoryPolicy},
+		{ErrTooManyRedirects, CodeTooManyRedirects, colibri.CategoryNetwork},
+		{ErrRateLimited, CodeRateLimited, colibri.CategoryNetwork},
+		{ErrProxyUnsupported, colibri.CodeNotConfigured, colibri.CategoryInternal},
+		{ErrFormNotFound, CodeLoginFailed, colibri.CategoryParse},
+		{ErrLoginURLIsNil, colibri.CodeInvalidRules, colibri.CategoryRules},
+		{ErrInvalidTOTPSecret, colibri.CodeInvalidRules, colibri.CategoryRules},
+		{ErrInvalidTOTPPeriod, colibri.CodeInvalidRules, colibri.CategoryRules},
+		{ErrInvalidTOTPDigits, colibri.CodeInvalidRules, colibri.CategoryRules},
+	} {
+		colibri.RegisterErrorCode(ec.err, ec.code, ec.category)
+	}
+}
@@ -0,0 +1,121 @@
+package webextractor
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// DefaultMaxPerHost default maximum number of concurrent requests per host.
+const DefaultMaxPerHost = 2
+
+// HostLimiter limits the number of concurrent requests per host.
+// See the colibri.RateLimiter interface.
+type HostLimiter struct {
+	// MaxPerHost specifies the maximum number of concurrent requests per host.
+	// If zero, DefaultMaxPerHost is used.
+	MaxPerHost int
+
+	mu    sync.Mutex
+	hosts map[string]*hostSem
+}
+
+type hostSem struct {
+	ch   chan struct{}
+	refs int
+}
+
+// NewHostLimiter returns a new HostLimiter with the maximum number of concurrent requests per host.
+func NewHostLimiter(maxPerHost int) *HostLimiter {
+	return &HostLimiter{MaxPerHost: maxPerHost, hosts: make(map[string]*hostSem)}
+}
+
+// Acquire waits until the number of requests in progress to the host
+// of the URL is less than MaxPerHost.
+func (limiter *HostLimiter) Acquire(ctx context.Context, u *url.URL) error {
+	host := colibri.NormalizeHost(u.Host)
+
+	limiter.mu.Lock()
+	if limiter.hosts == nil {
+		limiter.hosts = make(map[string]*hostSem)
+	}
+
+	sem, ok := limiter.hosts[host]
+	if !ok {
+		maxPerHost := limiter.MaxPerHost
+		if maxPerHost <= 0 {
+			maxPerHost = DefaultMaxPerHost
+		}
+
+		sem = &hostSem{ch: make(chan struct{}, maxPerHost)}
+		limiter.hosts[host] = sem
+	}
+	sem.refs++
+	limiter.mu.Unlock()
+
+	select {
+	case sem.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		limiter.unref(host, sem)
+		return ctx.Err()
+	}
+}
+
+// Release warns that the request to the URL has finished.
+func (limiter *HostLimiter) Release(u *url.URL) {
+	host := colibri.NormalizeHost(u.Host)
+
+	limiter.mu.Lock()
+	sem, ok := limiter.hosts[host]
+	limiter.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case <-sem.ch:
+	default:
+	}
+	limiter.unref(host, sem)
+}
+
+// Clear removes the hosts without requests in progress.
+func (limiter *HostLimiter) Clear() {
+	limiter.mu.Lock()
+	for host, sem := range limiter.hosts {
+		if sem.refs == 0 {
+			delete(limiter.hosts, host)
+		}
+	}
+	limiter.mu.Unlock()
+}
+
+// ReportCapabilities adds the "host-limit" feature to the report.
+// See the colibri.CapabilityReporter interface.
+func (limiter *HostLimiter) ReportCapabilities(caps *colibri.Capabilities) {
+	caps.Features = append(caps.Features, "host-limit")
+}
+
+// InProgress returns the number of requests in progress to the host of the URL.
+func (limiter *HostLimiter) InProgress(u *url.URL) int {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if sem, ok := limiter.hosts[colibri.NormalizeHost(u.Host)]; ok {
+		return len(sem.ch)
+	}
+	return 0
+}
+
+// unref removes the host when it has no requests in progress or waiting.
+func (limiter *HostLimiter) unref(host string, sem *hostSem) {
+	limiter.mu.Lock()
+	sem.refs--
+	if (sem.refs == 0) && (limiter.hosts[host] == sem) {
+		delete(limiter.hosts, host)
+	}
+	limiter.mu.Unlock()
+}
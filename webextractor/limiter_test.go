@@ -0,0 +1,82 @@
+package webextractor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostLimiter(t *testing.T) {
+	var (
+		limiter = NewHostLimiter(2)
+		u       = mustNewURL("https://pkg.go.dev")
+		other   = mustNewURL("https://go.dev")
+
+		current, max atomic.Int32
+		wg           sync.WaitGroup
+	)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := limiter.Acquire(context.Background(), u); err != nil {
+				t.Error(err)
+				return
+			}
+			defer limiter.Release(u)
+
+			n := current.Add(1)
+			for {
+				m := max.Load()
+				if (n <= m) || max.CompareAndSwap(m, n) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			current.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if got := max.Load(); (got < 1) || (got > 2) {
+		t.Fatalf(gotWantFormat, got, "<= 2")
+	}
+
+	if got := limiter.InProgress(u); got != 0 {
+		t.Fatalf(gotWantFormat, got, 0)
+	}
+
+	// The limit is per host.
+	limiter.Acquire(context.Background(), u)
+	limiter.Acquire(context.Background(), u)
+	if err := limiter.Acquire(context.Background(), other); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := limiter.Acquire(ctx, u); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf(gotWantFormat, err, context.DeadlineExceeded)
+	}
+
+	limiter.Release(u)
+	if err := limiter.Acquire(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+
+	limiter.Release(u)
+	limiter.Release(u)
+	limiter.Release(other)
+	limiter.Clear()
+
+	if got := len(limiter.hosts); got != 0 {
+		t.Fatalf(gotWantFormat, got, 0)
+	}
+}
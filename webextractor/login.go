@@ -0,0 +1,327 @@
+package webextractor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// DefaultFormSelector is the CSS selector used to find the login form.
+const DefaultFormSelector = "form"
+
+// CSRFHeader is the header used to send the CSRF token found in the meta tags of the login page.
+const CSRFHeader = "X-CSRF-Token"
+
+var (
+	// ErrFormNotFound is returned when the login form is not found.
+	ErrFormNotFound = errors.New("login form not found")
+
+	// ErrLoginURLIsNil is returned when the URL of the login form is nil.
+	ErrLoginURLIsNil = errors.New("login URL is nil")
+)
+
+// csrfMetaNames are the names of the meta tags that contain the CSRF token.
+var csrfMetaNames = []string{"csrf-token", "_csrf", "csrf_token"}
+
+// LoginForm specifies how to fill and submit a login form.
+type LoginForm struct {
+	// URL of the page that contains the login form.
+	URL *url.URL
+
+	// FormSelector CSS selector of the form, if empty DefaultFormSelector is used.
+	FormSelector string
+
+	// Fields values of the form fields by name, e.g. username and password.
+	// They replace the values found in the form.
+	Fields map[string]string
+
+	// Header contains the HTTP header of the requests.
+	Header http.Header
+
+	// Proxy specifies the proxy URI.
+	Proxy *url.URL
+
+	// TLS configures the TLS connections of the requests, see colibri.Rules.TLS.
+	TLS *colibri.TLS
+
+	// Session specifies the session in which the cookies are stored,
+	// if empty the cookies are stored in Jar.
+	Session string
+
+	// TOTP specifies the time-based one-time password of the account (2FA).
+	TOTP *TOTP
+}
+
+// Login gets the login form, fills it with the hidden inputs and CSRF tokens
+// found and with the fields of the LoginForm, submits it and stores the cookies.
+// If TOTP is specified, the generated code is sent in the login form or,
+// if the field is not in the login form, in the form of the response.
+// Returns the response of the last form submission.
+func (client *Client) Login(c *colibri.Colibri, form *LoginForm) (colibri.Response, error) {
+	if (form == nil) || (form.URL == nil) {
+		return nil, ErrLoginURLIsNil
+	}
+
+	jar := client.Jar
+	if form.Session != "" {
+		var err error
+		if jar, err = client.SessionJar(form.Session); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := client.checkProxy(form.URL, form.Proxy); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := client.tlsConfig(form.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := client.getClient(form.Proxy, tlsConfig)
+	defer client.pool.Put(httpClient)
+
+	httpClient.Jar = jar
+	httpClient.Timeout = DefaultTimeout
+
+	// Form
+	req, err := http.NewRequest("GET", form.URL.String(), nil /* Body */)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = loginHeader(form.Header)
+	req = withUnixSocket(req, form.Proxy)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	submit, err := parseLoginForm(resp, form.FormSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range form.Fields {
+		submit.values.Set(name, value)
+	}
+
+	totpPending := form.TOTP != nil
+	if totpPending && submit.values.Has(form.TOTP.Field) {
+		if err := submit.setTOTP(form.TOTP); err != nil {
+			return nil, err
+		}
+		totpPending = false
+	}
+
+	submitResp, err := submit.do(httpClient, form.Header, form.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	if !totpPending {
+		return &Response{HTTP: submitResp, c: c}, nil
+	}
+
+	// TOTP form
+	buf, err := io.ReadAll(submitResp.Body)
+	submitResp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	submitResp.Body = io.NopCloser(bytes.NewReader(buf))
+
+	totpSubmit, err := parseLoginForm(submitResp, form.TOTP.FormSelector)
+	if errors.Is(err, ErrFormNotFound) || ((err == nil) && !totpSubmit.values.Has(form.TOTP.Field)) {
+		submitResp.Body = io.NopCloser(bytes.NewReader(buf))
+		return &Response{HTTP: submitResp, c: c}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := totpSubmit.setTOTP(form.TOTP); err != nil {
+		return nil, err
+	}
+
+	totpResp, err := totpSubmit.do(httpClient, form.Header, form.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{HTTP: totpResp, c: c}, nil
+}
+
+type loginSubmit struct {
+	method    string
+	action    *url.URL
+	values    url.Values
+	csrfToken string
+}
+
+func (submit *loginSubmit) setTOTP(totp *TOTP) error {
+	code, err := totp.Code(time.Now())
+	if err != nil {
+		return err
+	}
+
+	submit.values.Set(totp.Field, code)
+	return nil
+}
+
+func (submit *loginSubmit) do(httpClient *http.Client, header http.Header, proxyURL *url.URL) (*http.Response, error) {
+	var body string
+	if submit.method == "GET" {
+		submit.action.RawQuery = submit.values.Encode()
+	} else {
+		body = submit.values.Encode()
+	}
+
+	req, err := http.NewRequest(submit.method, submit.action.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = loginHeader(header)
+
+	if submit.method != "GET" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	if submit.csrfToken != "" {
+		req.Header.Set(CSRFHeader, submit.csrfToken)
+	}
+	return httpClient.Do(withUnixSocket(req, proxyURL))
+}
+
+func loginHeader(header http.Header) http.Header {
+	h := header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+
+	if strings.TrimSpace(h.Get("User-Agent")) == "" {
+		h.Set("User-Agent", colibri.DefaultUserAgent)
+	}
+	return h
+}
+
+func parseLoginForm(resp *http.Response, formSelector string) (*loginSubmit, error) {
+	r, err := charset.NewReader(resp.Body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if formSelector == "" {
+		formSelector = DefaultFormSelector
+	}
+
+	sel, err := cascadia.Compile(formSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	formNode := cascadia.Query(root, sel)
+	if formNode == nil {
+		return nil, ErrFormNotFound
+	}
+
+	submit := &loginSubmit{
+		method: strings.ToUpper(attr(formNode, "method")),
+		action: resp.Request.URL.ResolveReference(&url.URL{}),
+		values: url.Values{},
+	}
+
+	if submit.method == "" {
+		submit.method = "POST"
+	}
+
+	if action := attr(formNode, "action"); action != "" {
+		actionURL, err := url.Parse(action)
+		if err != nil {
+			return nil, err
+		}
+		submit.action = resp.Request.URL.ResolveReference(actionURL)
+	}
+
+	fields := cascadia.QueryAll(formNode, cascadia.MustCompile("input, select, textarea"))
+	for _, field := range fields {
+		name := attr(field, "name")
+		if name == "" {
+			continue
+		}
+
+		switch field.Data {
+		case "input":
+			switch strings.ToLower(attr(field, "type")) {
+			case "submit", "button", "image", "reset", "file":
+				continue
+			case "checkbox", "radio":
+				if !hasAttr(field, "checked") {
+					continue
+				}
+			}
+			submit.values.Add(name, attr(field, "value"))
+
+		case "select":
+			option := cascadia.Query(field, cascadia.MustCompile("option[selected]"))
+			if option == nil {
+				option = cascadia.Query(field, cascadia.MustCompile("option"))
+			}
+
+			if option != nil {
+				submit.values.Add(name, attr(option, "value"))
+			}
+
+		case "textarea":
+			var text strings.Builder
+			for child := field.FirstChild; child != nil; child = child.NextSibling {
+				if child.Type == html.TextNode {
+					text.WriteString(child.Data)
+				}
+			}
+			submit.values.Add(name, text.String())
+		}
+	}
+
+	for _, name := range csrfMetaNames {
+		meta := cascadia.Query(root, cascadia.MustCompile(`meta[name="`+name+`"]`))
+		if meta != nil {
+			submit.csrfToken = attr(meta, "content")
+			break
+		}
+	}
+	return submit, nil
+}
+
+func attr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(node *html.Node, key string) bool {
+	for _, a := range node.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+package webextractor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const loginBody = `<!doctype html>
+<html>
+	<head>
+		<meta name="csrf-token" content="meta-token">
+	</head>
+	<body>
+		<form id="search" action="/search"><input name="q"></form>
+		<form id="login" method="post" action="/login">
+			<input type="hidden" name="csrf" value="form-token">
+			<input type="text" name="username" value="">
+			<input type="password" name="password">
+			<input type="checkbox" name="remember" value="1" checked>
+			<input type="checkbox" name="newsletter" value="1">
+			<select name="lang"><option value="en">EN</option><option value="es" selected>ES</option></select>
+			<input type="submit" name="send" value="Login">
+		</form>
+	</body>
+</html>`
+
+func testServerLogin() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/form":
+			http.SetCookie(w, &http.Cookie{Name: "pre-session", Value: "1"})
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, loginBody)
+
+		case "/login":
+			r.ParseForm()
+			_, err := r.Cookie("pre-session")
+			switch {
+			case err != nil,
+				r.Method != "POST",
+				r.Header.Get(CSRFHeader) != "meta-token",
+				r.PostForm.Get("csrf") != "form-token",
+				r.PostForm.Get("username") != "gopher",
+				r.PostForm.Get("password") != "secret",
+				r.PostForm.Get("remember") != "1",
+				r.PostForm.Has("newsletter"),
+				r.PostForm.Has("send"),
+				r.PostForm.Get("lang") != "es":
+				http.Error(w, "invalid login", http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "logged"})
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestClientLogin(t *testing.T) {
+	ts := testServerLogin()
+	defer ts.Close()
+
+	tests := []struct {
+		Name    string
+		Form    *LoginForm
+		WantErr error
+		Status  int
+	}{
+		{
+			"OK",
+			&LoginForm{
+				URL:          mustNewURL(ts.URL + "/form"),
+				FormSelector: "#login",
+				Fields:       map[string]string{"username": "gopher", "password": "secret"},
+			},
+			nil,
+			http.StatusOK,
+		},
+		{
+			"Session",
+			&LoginForm{
+				URL:          mustNewURL(ts.URL + "/form"),
+				FormSelector: "#login",
+				Fields:       map[string]string{"username": "gopher", "password": "secret"},
+				Session:      "user",
+			},
+			nil,
+			http.StatusOK,
+		},
+		{
+			"InvalidPassword",
+			&LoginForm{
+				URL:          mustNewURL(ts.URL + "/form"),
+				FormSelector: "#login",
+				Fields:       map[string]string{"username": "gopher", "password": "error"},
+			},
+			nil,
+			http.StatusUnauthorized,
+		},
+		{
+			"FormNotFound",
+			&LoginForm{URL: mustNewURL(ts.URL + "/form"), FormSelector: "#register"},
+			ErrFormNotFound,
+			0,
+		},
+		{"NilURL", &LoginForm{}, ErrLoginURLIsNil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			client, err := NewClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.Login(nil, tt.Form)
+			if (err != nil) || (tt.WantErr != nil) {
+				if !errors.Is(err, tt.WantErr) {
+					t.Fatalf(gotWantFormat, err, tt.WantErr)
+				}
+				return
+			}
+
+			if resp.StatusCode() != tt.Status {
+				t.Fatalf(prefixGotWantFormat, "Status Code", resp.StatusCode(), tt.Status)
+			} else if tt.Status != http.StatusOK {
+				return
+			}
+
+			jar := client.Jar
+			if tt.Form.Session != "" {
+				jar, _ = client.SessionJar(tt.Form.Session)
+			}
+
+			if len(jar.Cookies(tt.Form.URL)) != 2 {
+				t.Fatal("session cookie not stored")
+			}
+		})
+	}
+}
+
+func TestTOTPCode(t *testing.T) {
+	totp := &TOTP{Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Digits: 8}
+
+	tests := []struct {
+		Unix int64
+		Want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{2000000000, "69279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := totp.Code(time.Unix(tt.Unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		} else if code != tt.Want {
+			t.Fatalf(gotWantFormat, code, tt.Want)
+		}
+	}
+
+	errTests := []struct {
+		TOTP *TOTP
+		Err  error
+	}{
+		{&TOTP{Secret: "1nv4l1d"}, ErrInvalidTOTPSecret},
+		{&TOTP{Secret: totp.Secret, Period: time.Millisecond}, ErrInvalidTOTPPeriod},
+		{&TOTP{Secret: totp.Secret, Period: -time.Second}, ErrInvalidTOTPPeriod},
+		{&TOTP{Secret: totp.Secret, Digits: 10}, ErrInvalidTOTPDigits},
+		{&TOTP{Secret: totp.Secret, Digits: -1}, ErrInvalidTOTPDigits},
+	}
+
+	for _, tt := range errTests {
+		if _, err := tt.TOTP.Code(time.Now()); !errors.Is(err, tt.Err) {
+			t.Fatalf(gotWantFormat, err, tt.Err)
+		}
+	}
+}
+
+func TestClientLoginTOTP(t *testing.T) {
+	totp := &TOTP{Secret: "JBSWY3DPEHPK3PXP", Field: "otp"}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/form":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, `<form method="post" action="/login"><input name="username"></form>`)
+
+		case "/login":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, `<form method="post" action="/2fa"><input type="hidden" name="step" value="2"><input name="otp"></form>`)
+
+		case "/2fa":
+			r.ParseForm()
+			code, _ := totp.Code(time.Now())
+			if (r.PostForm.Get("otp") != code) || (r.PostForm.Get("step") != "2") {
+				http.Error(w, "invalid code", http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "logged"})
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := &LoginForm{
+		URL:    mustNewURL(ts.URL + "/form"),
+		Fields: map[string]string{"username": "gopher"},
+		TOTP:   totp,
+	}
+
+	resp, err := client.Login(nil, form)
+	if err != nil {
+		t.Fatal(err)
+	} else if resp.StatusCode() != http.StatusOK {
+		t.Fatalf(prefixGotWantFormat, "Status Code", resp.StatusCode(), http.StatusOK)
+	}
+
+	if len(client.Jar.Cookies(form.URL)) != 1 {
+		t.Fatal("session cookie not stored")
+	}
+}
@@ -0,0 +1,53 @@
+package webextractor
+
+import "container/list"
+
+// DefaultMaxEntries is the default maximum number of hosts stored by ReqDelay and RobotsData.
+const DefaultMaxEntries = 10000
+
+// hostLRU keeps hosts ordered from most recently used to least recently used.
+// It is not safe for concurrent use, the owner structure must synchronize access.
+type hostLRU struct {
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newHostLRU() *hostLRU {
+	return &hostLRU{ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// touch marks the host as the most recently used.
+func (lru *hostLRU) touch(host string) {
+	if e, ok := lru.items[host]; ok {
+		lru.ll.MoveToFront(e)
+		return
+	}
+	lru.items[host] = lru.ll.PushFront(host)
+}
+
+// remove removes the host.
+func (lru *hostLRU) remove(host string) {
+	if e, ok := lru.items[host]; ok {
+		lru.ll.Remove(e)
+		delete(lru.items, host)
+	}
+}
+
+// oldest calls fn from the least recently used host to the most recently used
+// until fn returns true, the host for which fn returned true is returned.
+func (lru *hostLRU) oldest(fn func(host string) bool) (string, bool) {
+	for e := lru.ll.Back(); e != nil; e = e.Prev() {
+		host := e.Value.(string)
+		if fn(host) {
+			return host, true
+		}
+	}
+	return "", false
+}
+
+func (lru *hostLRU) len() int { return lru.ll.Len() }
+
+func (lru *hostLRU) clear() {
+	lru.ll.Init()
+	clear(lru.items)
+}
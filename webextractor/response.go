@@ -1,6 +1,8 @@
 package webextractor
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
@@ -8,17 +10,39 @@ import (
 	"github.com/eduardogxnzalez/colibri"
 )
 
+// ErrBodyTooLarge is returned when the response body exceeds the MaxBodySize of the rules.
+var ErrBodyTooLarge = errors.New("response body exceeds the maximum size")
+
 // Response represents an HTTP response.
 // See the colibri.Response interface.
 type Response struct {
 	HTTP *http.Response
 	c    *colibri.Colibri
+	tr   *tracer
+	ctx  context.Context
+
+	cached bool
 }
 
+// URL returns the URI of the last request, after following the redirects.
 func (resp *Response) URL() *url.URL {
 	return resp.HTTP.Request.URL
 }
 
+// OriginalURL returns the URI of the first request, before following the redirects.
+func (resp *Response) OriginalURL() *url.URL {
+	req := resp.HTTP.Request
+	for (req.Response != nil) && (req.Response.Request != nil) {
+		req = req.Response.Request
+	}
+	return req.URL
+}
+
+// Redirected returns true if the response was obtained after following redirects.
+func (resp *Response) Redirected() bool {
+	return resp.HTTP.Request.Response != nil
+}
+
 func (resp *Response) StatusCode() int {
 	return resp.HTTP.StatusCode
 }
@@ -31,10 +55,104 @@ func (resp *Response) Body() io.ReadCloser {
 	return resp.HTTP.Body
 }
 
+// Timing returns the duration of each phase of the HTTP request.
+func (resp *Response) Timing() Timing {
+	if resp.tr == nil {
+		return Timing{}
+	}
+	return resp.tr.timing()
+}
+
+// Size returns the size in bytes of the request and the response.
+func (resp *Response) Size() Size {
+	if resp.tr == nil {
+		return Size{}
+	}
+	return resp.tr.size()
+}
+
+// Cached returns true if the response was obtained from the cache, see Cache.
+func (resp *Response) Cached() bool {
+	return resp.cached
+}
+
+// NotModified returns true if the status code is 304 (Not Modified), the page did not change
+// since the request whose validators were sent, see Revisit. The response does not have body.
+// It is false if the Cache returned the stored response instead.
+func (resp *Response) NotModified() bool {
+	return resp.HTTP.StatusCode == http.StatusNotModified
+}
+
+// Context returns the context of the request used to obtain the response.
+func (resp *Response) Context() context.Context {
+	if resp.ctx == nil {
+		return context.Background()
+	}
+	return resp.ctx
+}
+
+// Do Colibri DoContext method wrapper, the context of the response is used.
 func (resp *Response) Do(rules *colibri.Rules) (colibri.Response, error) {
-	return resp.c.Do(rules)
+	return resp.c.DoContext(resp.Context(), rules)
 }
 
+// Extract Colibri ExtractContext method wrapper, the context of the response is used.
 func (resp *Response) Extract(rules *colibri.Rules) (colibri.Response, map[string]any, error) {
-	return resp.c.Extract(rules)
+	return resp.c.ExtractContext(resp.Context(), rules)
+}
+
+// limitedBody is a response body that returns ErrBodyTooLarge
+// when it has more bytes than the limit.
+type limitedBody struct {
+	io.ReadCloser
+	u        *url.URL
+	n        int64 // remaining bytes
+	exceeded bool
+}
+
+// limitBody limits the response body to the MaxBodySize of the rules.
+// Returns ErrBodyTooLarge if the Content-Length exceeds it.
+// The errors are colibri.Warnings of kind colibri.WarningTruncated.
+func limitBody(resp *http.Response, rules *colibri.Rules) error {
+	if rules.MaxBodySize <= 0 {
+		return nil
+	}
+
+	if (resp.ContentLength > rules.MaxBodySize) && (resp.Request.Method != http.MethodHead) {
+		resp.Body.Close()
+		return truncatedWarning(resp.Request.URL)
+	}
+
+	resp.Body = &limitedBody{ReadCloser: resp.Body, u: resp.Request.URL, n: rules.MaxBodySize}
+	return nil
+}
+
+// truncatedWarning returns the warning of the body of the URL that exceeds the MaxBodySize.
+func truncatedWarning(u *url.URL) error {
+	return &colibri.Warning{Kind: colibri.WarningTruncated, URL: u, Err: ErrBodyTooLarge}
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+	if body.exceeded {
+		return 0, truncatedWarning(body.u)
+	}
+
+	if body.n <= 0 {
+		// the limit is reached, the body must end
+		var b [1]byte
+		n, err := body.ReadCloser.Read(b[:])
+		if n > 0 {
+			body.exceeded = true
+			return 0, truncatedWarning(body.u)
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > body.n {
+		p = p[:body.n]
+	}
+
+	n, err := body.ReadCloser.Read(p)
+	body.n -= int64(n)
+	return n, err
 }
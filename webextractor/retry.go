@@ -0,0 +1,179 @@
+package webextractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+const (
+	// DefaultRetryBackoff default base delay between retries.
+	DefaultRetryBackoff = 500 * time.Millisecond
+
+	// DefaultMaxRetryBackoff default maximum delay between retries.
+	DefaultMaxRetryBackoff = 30 * time.Second
+)
+
+// maxDiscardBody maximum number of bytes read from the body of a retried response
+// so that the connection can be reused.
+const maxDiscardBody = 64 << 10
+
+// DefaultRetryOn are the status codes of the responses retried
+// when the RetryOn of the rules is empty.
+var DefaultRetryOn = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// shouldRetry returns true if the request must be retried.
+// Requests that fail are retried unless the context is done, the maximum
+// number of redirects is exceeded or the address is blocked.
+func shouldRetry(ctx context.Context, rules *colibri.Rules, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		var redirectErr *redirectError
+		return !errors.Is(err, ErrTooManyRedirects) && !errors.Is(err, ErrBlockedAddress) &&
+			!errors.As(err, &redirectErr)
+	}
+
+	retryOn := rules.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = DefaultRetryOn
+	}
+	return slices.Contains(retryOn, resp.StatusCode)
+}
+
+// ErrRateLimited is the error matched by RateLimitedError with errors.Is.
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitedError is returned when the Retry-After header of a 429 (Too Many Requests)
+// or 503 (Service Unavailable) response exceeds the MaxWait of the rules.
+type RateLimitedError struct {
+	// URL of the response.
+	URL *url.URL
+
+	// StatusCode of the response.
+	StatusCode int
+
+	// RetryAfter is the time to wait before retrying the request.
+	RetryAfter time.Duration
+}
+
+func (err *RateLimitedError) Error() string {
+	return fmt.Sprintf("%v (%d), retry after %v: %v", ErrRateLimited, err.StatusCode, err.RetryAfter, err.URL)
+}
+
+// Is returns true if the target is ErrRateLimited.
+func (err *RateLimitedError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RetryAfter returns the time to wait of the Retry-After header of the 429 (Too Many Requests)
+// and 503 (Service Unavailable) responses, false if the response does not have it.
+func RetryAfter(resp colibri.Response) (time.Duration, bool) {
+	if (resp == nil) || !rateLimitStatus(resp.StatusCode()) {
+		return 0, false
+	}
+	return parseRetryAfter(resp.Header(), time.Now())
+}
+
+// rateLimited returns the time to wait before retrying the response, false if it must not
+// be retried after its Retry-After header because the MaxWait of the rules is zero.
+// The time is at least the RetryBackoff of the rules.
+func rateLimited(rules *colibri.Rules, resp *http.Response, err error) (time.Duration, bool) {
+	if (rules.MaxWait <= 0) || (err != nil) || !rateLimitStatus(resp.StatusCode) {
+		return 0, false
+	}
+
+	retryAfter, ok := parseRetryAfter(resp.Header, time.Now())
+	if !ok {
+		return 0, false
+	}
+
+	minWait := rules.RetryBackoff
+	if minWait <= 0 {
+		minWait = DefaultRetryBackoff
+	}
+	return max(retryAfter, minWait), true
+}
+
+func rateLimitStatus(statusCode int) bool {
+	return (statusCode == http.StatusTooManyRequests) || (statusCode == http.StatusServiceUnavailable)
+}
+
+// waitRetry waits the delay before the retry, returns the error of the context if it is cancelled.
+func (client *Client) waitRetry(ctx context.Context, rules *colibri.Rules, attempt int) error {
+	return sleepContext(ctx, client.retryBackoff(rules.RetryBackoff, attempt))
+}
+
+// sleepContext waits the duration, returns the error of the context if it is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryBackoff returns the delay before the retry, the base delay grows
+// exponentially with each attempt up to MaxRetryBackoff, with a random
+// jitter between the half and the full delay.
+func (client *Client) retryBackoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		base = DefaultRetryBackoff
+	}
+
+	maxBackoff := client.MaxRetryBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxRetryBackoff
+	}
+
+	backoff := base
+	for i := 0; (i < attempt) && (backoff < maxBackoff); i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1))
+}
+
+// redirectError is an error returned by Client.CheckRedirect, it is not retried.
+type redirectError struct {
+	err error
+}
+
+func (err *redirectError) Error() string {
+	return err.err.Error()
+}
+
+func (err *redirectError) Unwrap() error {
+	return err.err
+}
+
+// discardBody reads and closes the body of the response.
+func discardBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDiscardBody))
+	resp.Body.Close()
+}
@@ -0,0 +1,185 @@
+package webextractor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+func TestRetries(t *testing.T) {
+	var n atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		Retries      int
+		RetryOn      []int
+		WantStatus   int
+		WantRequests int32
+	}{
+		{0, nil, http.StatusServiceUnavailable, 1},
+		{1, nil, http.StatusServiceUnavailable, 2},
+		{2, nil, http.StatusOK, 3},
+		{5, nil, http.StatusOK, 3},
+		{2, []int{http.StatusTooManyRequests}, http.StatusServiceUnavailable, 1},
+	}
+
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := colibri.New()
+	for _, tt := range tests {
+		n.Store(0)
+
+		rules := &colibri.Rules{
+			Method:       "GET",
+			URL:          mustNewURL(ts.URL),
+			Retries:      tt.Retries,
+			RetryBackoff: time.Millisecond,
+			RetryOn:      tt.RetryOn,
+		}
+
+		resp, err := client.Do(c, rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode() != tt.WantStatus {
+			t.Fatalf(gotWantFormat, resp.StatusCode(), tt.WantStatus)
+		} else if got := n.Load(); got != tt.WantRequests {
+			t.Fatalf(gotWantFormat, got, tt.WantRequests)
+		}
+	}
+
+	// The context cancels the wait between retries.
+	n.Store(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	rules := &colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL), Retries: 2, RetryBackoff: time.Minute}
+	if _, err := client.DoContext(ctx, c, rules); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf(gotWantFormat, err, context.DeadlineExceeded)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	client := &Client{MaxRetryBackoff: time.Second}
+
+	tests := []struct {
+		Base     time.Duration
+		Attempt  int
+		Min, Max time.Duration
+	}{
+		{100 * time.Millisecond, 0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{100 * time.Millisecond, 2, 200 * time.Millisecond, 400 * time.Millisecond},
+		{100 * time.Millisecond, 10, 500 * time.Millisecond, time.Second},
+		{0, 0, DefaultRetryBackoff / 2, DefaultRetryBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := client.retryBackoff(tt.Base, tt.Attempt); (got < tt.Min) || (got > tt.Max) {
+			t.Fatalf(gotWantFormat, got, tt.Min.String()+"-"+tt.Max.String())
+		}
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	var n atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1) <= 2 {
+			w.Header().Set("Retry-After", r.URL.Query().Get("after"))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		After        string
+		MaxWait      time.Duration
+		WantStatus   int
+		WantRetry    time.Duration
+		WantRequests int32
+	}{
+		{"0", 0, http.StatusTooManyRequests, 0, 1},
+		{"0", time.Second, http.StatusOK, 0, 3},
+		{"120", time.Second, 0, 120 * time.Second, 1},
+		{"", time.Second, http.StatusTooManyRequests, 0, 1},
+	}
+
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := colibri.New()
+	for _, tt := range tests {
+		n.Store(0)
+
+		rules := &colibri.Rules{
+			Method:       "GET",
+			URL:          mustNewURL(ts.URL + "?after=" + tt.After),
+			RetryBackoff: time.Millisecond,
+			MaxWait:      tt.MaxWait,
+		}
+
+		resp, err := client.Do(c, rules)
+		if tt.WantRetry > 0 {
+			var rateLimitedErr *RateLimitedError
+			if !errors.Is(err, ErrRateLimited) || !errors.As(err, &rateLimitedErr) {
+				t.Fatalf(gotWantFormat, err, ErrRateLimited)
+			} else if rateLimitedErr.RetryAfter != tt.WantRetry {
+				t.Fatalf(gotWantFormat, rateLimitedErr.RetryAfter, tt.WantRetry)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		} else if resp.StatusCode() != tt.WantStatus {
+			t.Fatalf(gotWantFormat, resp.StatusCode(), tt.WantStatus)
+		}
+
+		if got := n.Load(); got != tt.WantRequests {
+			t.Fatalf(gotWantFormat, got, tt.WantRequests)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		StatusCode int
+		Value      string
+		Want       time.Duration
+		WantOK     bool
+	}{
+		{http.StatusTooManyRequests, "5", 5 * time.Second, true},
+		{http.StatusServiceUnavailable, "1", time.Second, true},
+		{http.StatusTooManyRequests, "", 0, false},
+		{http.StatusOK, "5", 0, false},
+	}
+
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.Value != "" {
+			header.Set("Retry-After", tt.Value)
+		}
+		resp := &Response{HTTP: &http.Response{StatusCode: tt.StatusCode, Header: header}}
+
+		if got, ok := RetryAfter(resp); (got != tt.Want) || (ok != tt.WantOK) {
+			t.Fatalf(gotWantFormat, got, tt.Want)
+		}
+	}
+}
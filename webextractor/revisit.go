@@ -0,0 +1,232 @@
+package webextractor
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+// Revisit stores the validators of the responses by URL, the Client sends them in the
+// If-None-Match and If-Modified-Since headers of the next requests to the same URL,
+// so the unchanged pages are answered with 304 (Not Modified) without body.
+// Only the GET and HEAD requests are conditional, the requests whose header already
+// has If-None-Match or If-Modified-Since are not modified.
+//
+// The validators are stored by the URL of the request, before following the redirects,
+// which is the URL of the next requests. The validators of a response with body are
+// stored when the body has been read completely, so the truncated or interrupted
+// bodies are requested again. If the parse of a complete body fails, the validators
+// are kept; use Delete to request the URL again without them.
+// See Client.Revisit and Response.NotModified.
+type Revisit struct {
+	// MaxEntries specifies the maximum number of URLs stored.
+	// When the limit is exceeded, the least recently used URL is removed.
+	// Zero means no limit.
+	MaxEntries int
+
+	rw      sync.RWMutex
+	entries map[string]Validators
+	lru     *hostLRU // by URL
+}
+
+// Validators are the ETag and Last-Modified headers of a response.
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// NewRevisit returns a new Revisit structure.
+func NewRevisit() *Revisit {
+	return &Revisit{entries: make(map[string]Validators), lru: newHostLRU()}
+}
+
+// Validators returns the validators stored for the URL.
+func (revisit *Revisit) Validators(u *url.URL) (Validators, bool) {
+	revisit.rw.RLock()
+	defer revisit.rw.RUnlock()
+
+	v, ok := revisit.entries[revisitKey(u)]
+	return v, ok
+}
+
+// Set stores the ETag and Last-Modified of the header for the URL.
+// If the header does not have them, the validators of the URL are removed.
+func (revisit *Revisit) Set(u *url.URL, header http.Header) {
+	v := Validators{ETag: header.Get("ETag"), LastModified: header.Get("Last-Modified")}
+	if v == (Validators{}) {
+		revisit.Delete(u)
+		return
+	}
+
+	revisit.rw.Lock()
+	defer revisit.rw.Unlock()
+	revisit.set(revisitKey(u), v)
+}
+
+// Delete removes the validators of the URL.
+func (revisit *Revisit) Delete(u *url.URL) {
+	key := revisitKey(u)
+
+	revisit.rw.Lock()
+	defer revisit.rw.Unlock()
+
+	delete(revisit.entries, key)
+	if revisit.lru != nil {
+		revisit.lru.remove(key)
+	}
+}
+
+// Len returns the number of URLs stored.
+func (revisit *Revisit) Len() int {
+	revisit.rw.RLock()
+	defer revisit.rw.RUnlock()
+	return len(revisit.entries)
+}
+
+// Clear removes the stored validators.
+func (revisit *Revisit) Clear() {
+	revisit.rw.Lock()
+	clear(revisit.entries)
+	if revisit.lru != nil {
+		revisit.lru.clear()
+	}
+	revisit.rw.Unlock()
+}
+
+// MarshalJSON returns the validators by URL, so they can be stored between crawls.
+func (revisit *Revisit) MarshalJSON() ([]byte, error) {
+	revisit.rw.RLock()
+	defer revisit.rw.RUnlock()
+	return json.Marshal(revisit.entries)
+}
+
+// UnmarshalJSON adds the validators by URL returned by MarshalJSON.
+func (revisit *Revisit) UnmarshalJSON(b []byte) error {
+	var entries map[string]Validators
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return err
+	}
+
+	revisit.rw.Lock()
+	defer revisit.rw.Unlock()
+
+	for key, v := range entries {
+		revisit.set(key, v)
+	}
+	return nil
+}
+
+// conditional adds the validators stored for the URL of the request to its header.
+func (revisit *Revisit) conditional(req *http.Request) {
+	if !revisitMethod(req.Method) || (req.Header.Get("If-None-Match") != "") || (req.Header.Get("If-Modified-Since") != "") {
+		return
+	}
+
+	v, ok := revisit.Validators(req.URL)
+	if !ok {
+		return
+	}
+
+	// the header of the request is the header of the rules
+	req.Header = req.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+// update stores the validators of the response with the URL of the request,
+// which is the URL before following the redirects. The validators of a response
+// with body are stored when the body is read completely.
+// The validators of the pages that no longer exist are removed.
+func (revisit *Revisit) update(req *http.Request, resp *http.Response) {
+	if !revisitMethod(req.Method) {
+		return
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		if (resp.Body == nil) || (resp.Body == http.NoBody) || (req.Method == http.MethodHead) {
+			revisit.Set(req.URL, resp.Header)
+			return
+		}
+		resp.Body = &revisitBody{ReadCloser: resp.Body, store: func() { revisit.Set(req.URL, resp.Header) }}
+
+	case resp.StatusCode == http.StatusNotModified:
+		// the servers may omit the validators in the 304 responses
+		if (resp.Header.Get("ETag") != "") || (resp.Header.Get("Last-Modified") != "") {
+			revisit.Set(req.URL, resp.Header)
+		}
+
+	case (resp.StatusCode == http.StatusNotFound) || (resp.StatusCode == http.StatusGone):
+		revisit.Delete(req.URL)
+	}
+}
+
+// revisitBody stores the validators of the response when the body has been read completely.
+type revisitBody struct {
+	io.ReadCloser
+	store func()
+	once  sync.Once
+}
+
+func (body *revisitBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	if err == io.EOF {
+		body.once.Do(body.store)
+	}
+	return n, err
+}
+
+// set stores the validators and removes the least recently used URLs while MaxEntries is exceeded.
+// The caller must hold the write lock.
+func (revisit *Revisit) set(key string, v Validators) {
+	if revisit.entries == nil {
+		revisit.entries = make(map[string]Validators)
+	}
+
+	if revisit.lru == nil {
+		revisit.lru = newHostLRU()
+	}
+
+	revisit.entries[key] = v
+	revisit.lru.touch(key)
+
+	for (revisit.MaxEntries > 0) && (revisit.lru.len() > revisit.MaxEntries) {
+		oldest, ok := revisit.lru.oldest(func(k string) bool { return k != key })
+		if !ok {
+			return
+		}
+
+		revisit.lru.remove(oldest)
+		delete(revisit.entries, oldest)
+	}
+}
+
+// revisitKey returns the key of the URL, without the fragment and with the host normalized.
+func revisitKey(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	key := *u
+	key.Fragment, key.RawFragment = "", ""
+	key.Host = colibri.NormalizeHost(key.Host)
+	return key.String()
+}
+
+// revisitMethod returns true if the requests with the method can be conditional.
+func revisitMethod(method string) bool {
+	return (method == "") || (method == http.MethodGet) || (method == http.MethodHead)
+}
@@ -0,0 +1,189 @@
+package webextractor
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+func TestRevisit(t *testing.T) {
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+	var gone atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+		case "/redirect":
+			http.Redirect(w, r, "/etag", http.StatusFound)
+			return
+
+		case "/unread":
+			w.Header().Set("ETag", `"u1"`)
+
+		case "/last-modified":
+			if gone.Load() {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+
+			w.Header().Set("Last-Modified", lastModified)
+			if r.Header.Get("If-Modified-Since") == lastModified {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil     // Deactivate Delay
+	we.RobotsTxt = nil // Deactivate RobotsTxt
+
+	revisit := NewRevisit()
+	we.Client.(*Client).Revisit = revisit
+
+	do := func(path string, header http.Header) *Response {
+		resp, err := we.Do(&colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + path), Header: header})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body().Close()
+		io.ReadAll(resp.Body())
+		return resp.(*Response)
+	}
+
+	tests := []struct {
+		Path   string
+		Header http.Header
+		Want   []bool // NotModified of each request
+	}{
+		{"/etag", nil, []bool{false, true, true}},
+		{"/last-modified", nil, []bool{false, true}},
+		{"/none", nil, []bool{false, false}},
+		{"/etag", http.Header{"If-None-Match": {`"v0"`}}, []bool{false}},
+	}
+
+	for _, tt := range tests {
+		for i, want := range tt.Want {
+			if got := do(tt.Path, tt.Header).NotModified(); got != want {
+				t.Fatalf("%s #%d: got %v, want %v", tt.Path, i, got, want)
+			}
+		}
+	}
+
+	if revisit.Len() != 2 {
+		t.Fatalf(gotWantFormat, revisit.Len(), 2)
+	}
+
+	t.Run("SkipNotModified", func(t *testing.T) {
+		we.FetchFilters = []colibri.FetchFilter{colibri.SkipStatus(http.StatusNotModified)}
+		defer func() { we.FetchFilters = nil }()
+
+		rules := &colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + "/etag"), Selectors: []*colibri.Selector{{Name: "body", Expr: "//body"}}}
+		if _, _, err := we.Extract(rules); !errors.Is(err, colibri.ErrSkipped) {
+			t.Fatalf(gotWantFormat, err, colibri.ErrSkipped)
+		}
+	})
+
+	t.Run("Redirect", func(t *testing.T) {
+		for i, want := range []bool{false, true} {
+			if got := do("/redirect", nil).NotModified(); got != want {
+				t.Fatalf("#%d: got %v, want %v", i, got, want)
+			}
+		}
+	})
+
+	t.Run("Unread", func(t *testing.T) {
+		resp, err := we.Do(&colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + "/unread")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body().Close()
+
+		if _, ok := revisit.Validators(mustNewURL(ts.URL + "/unread")); ok {
+			t.Fatal("validators of an unread body are stored")
+		}
+	})
+
+	t.Run("RulesHeader", func(t *testing.T) {
+		header := http.Header{"User-Agent": {"test"}}
+		for _, path := range []string{"/etag", "/last-modified"} {
+			rules := &colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + path), Header: header, DisableCompression: true}
+			resp, err := we.Do(rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body().Close()
+
+			if !resp.(*Response).NotModified() {
+				t.Fatalf("%s: got %v, want %v", path, false, true)
+			}
+		}
+
+		if want := (http.Header{"User-Agent": {"test"}}); !reflect.DeepEqual(header, want) {
+			t.Fatalf(gotWantFormat, header, want)
+		}
+	})
+
+	t.Run("Gone", func(t *testing.T) {
+		gone.Store(true)
+		do("/last-modified", nil)
+
+		if _, ok := revisit.Validators(mustNewURL(ts.URL + "/last-modified")); ok {
+			t.Fatal("validators of a gone page are stored")
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		b, err := json.Marshal(revisit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded := NewRevisit()
+		if err := json.Unmarshal(b, loaded); err != nil {
+			t.Fatal(err)
+		}
+
+		want := Validators{ETag: `"v1"`}
+		if got, _ := loaded.Validators(mustNewURL(ts.URL + "/etag#fragment")); got != want {
+			t.Fatalf(gotWantFormat, got, want)
+		}
+	})
+
+	t.Run("MaxEntries", func(t *testing.T) {
+		revisit := &Revisit{MaxEntries: 1}
+		revisit.Set(mustNewURL("https://example.com/a"), http.Header{"Etag": {`"a"`}})
+		revisit.Set(mustNewURL("https://example.com/b"), http.Header{"Etag": {`"b"`}})
+
+		if revisit.Len() != 1 {
+			t.Fatalf(gotWantFormat, revisit.Len(), 1)
+		} else if _, ok := revisit.Validators(mustNewURL("https://example.com/b")); !ok {
+			t.Fatal("most recently used URL removed")
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		we.Clear()
+		if revisit.Len() != 0 {
+			t.Fatalf(gotWantFormat, revisit.Len(), 0)
+		}
+	})
+}
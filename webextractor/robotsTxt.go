@@ -1,87 +1,468 @@
 package webextractor
 
 import (
+	"context"
 	"errors"
 	"io"
+	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/storage"
 
 	"github.com/temoto/robotstxt"
 )
 
 const robotsTxtPath = "/robots.txt"
 
+const (
+	// DefaultMaxCrawlDelay default maximum crawl delay of the robots.txt applied to the requests.
+	DefaultMaxCrawlDelay = time.Minute
+
+	// DefaultRobotsTTL default time a robots.txt is used before it is requested again.
+	DefaultRobotsTTL = 24 * time.Hour
+
+	// DefaultRobotsErrorTTL default time the server errors of a robots.txt
+	// are cached before it is requested again.
+	DefaultRobotsErrorTTL = 10 * time.Minute
+)
+
 // ErrorRobotstxtRestriction is returned when the page cannot be accessed due to robots.txt restrictions.
 var ErrorRobotstxtRestriction = errors.New("Page not accessible due to robots.txt restriction")
 
 // RobotsData gets, stores and parses robots.txt restrictions.
 type RobotsData struct {
-	rw   sync.RWMutex
-	data map[string]*robotstxt.RobotsData
+	// MaxEntries specifies the maximum number of hosts stored.
+	// When the limit is exceeded, the least recently used host is removed.
+	// Zero means no limit.
+	MaxEntries int
+
+	// MaxCrawlDelay specifies the maximum crawl delay applied to the requests,
+	// the longer Crawl-delay directives are reduced to it. Zero means no limit.
+	MaxCrawlDelay time.Duration
+
+	// TTL specifies how long a robots.txt is used before it is requested again.
+	// The expired robots.txt is still used while it is refreshed in the background.
+	// Zero means the robots.txt does not expire.
+	TTL time.Duration
+
+	// ErrorTTL specifies how long the server errors (5xx) of a robots.txt are cached,
+	// meanwhile the URLs of the host are disallowed. If a refresh fails,
+	// the expired robots.txt is used for ErrorTTL. Zero means they are not cached,
+	// but the failed refreshes are retried after the TTL or, if it is also zero,
+	// after DefaultRobotsErrorTTL, so a failing host is not requested by each request.
+	ErrorTTL time.Duration
+
+	// Storage stores the robots.txt files requested, they are not requested again
+	// by the RobotsData that use the same Storage, e.g. after a restart.
+	// If nil, they are only stored in memory.
+	Storage storage.Storage
+
+	// UserAgent specifies the product token of the robots.txt groups that apply to the requests,
+	// e.g. "MyBot", instead of the User-Agent header of each request, so the requests can send
+	// other User-Agents without changing the rules that apply. If empty, the header is used.
+	UserAgent string
+
+	rw        sync.RWMutex
+	data      map[string]*robotsEntry
+	lruMu     sync.Mutex // the hits update the lru with the read lock
+	lru       *hostLRU
+	evictions atomic.Uint64
+	refreshes sync.WaitGroup
+}
+
+// robotsEntry is the robots.txt stored of a host.
+type robotsEntry struct {
+	data       *robotstxt.RobotsData
+	statusCode int
+	body       []byte
+	expires    time.Time // zero if it does not expire
+	failed     bool      // server error
+	refreshing bool
+}
+
+func (entry *robotsEntry) expired(now time.Time) bool {
+	return !entry.expires.IsZero() && !now.Before(entry.expires)
 }
 
 // NewRobotsData returns a new RobotsData structure.
 func NewRobotsData() *RobotsData {
-	return &RobotsData{data: make(map[string]*robotstxt.RobotsData)}
+	return &RobotsData{
+		MaxEntries:    DefaultMaxEntries,
+		MaxCrawlDelay: DefaultMaxCrawlDelay,
+		TTL:           DefaultRobotsTTL,
+		ErrorTTL:      DefaultRobotsErrorTTL,
+		data:          make(map[string]*robotsEntry),
+		lru:           newHostLRU(),
+	}
 }
 
-// IsAllowed verifies that the User-Agent can access the URL.
+// IsAllowed verifies that the User-Agent can access the URL, see Agent.
 // Gets and stores the robots.txt restrictions of the URL host and for use in URLs with the same host.
 func (robots *RobotsData) IsAllowed(c *colibri.Colibri, rules *colibri.Rules) error {
+	return robots.IsAllowedContext(context.Background(), c, rules)
+}
+
+// IsAllowedContext is like IsAllowed, the context is used to get the robots.txt.
+// See the colibri.RobotsTxtContext interface.
+func (robots *RobotsData) IsAllowedContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) error {
 	if rules.URL.Path == robotsTxtPath {
 		return nil
 	}
 
+	host := colibri.NormalizeHost(rules.URL.Host)
+
+	// the hits only take the read lock, the lru is not updated if it does not evict
 	robots.rw.RLock()
-	robotsData, ok := robots.data[rules.URL.Host]
+	entry, ok := robots.data[host]
+	if ok && (robots.MaxEntries > 0) {
+		robots.lruMu.Lock()
+		robots.lru.touch(host)
+		robots.lruMu.Unlock()
+	}
+	expired := ok && entry.expired(time.Now()) && !entry.refreshing
 	robots.rw.RUnlock()
 
+	if expired {
+		robots.rw.Lock()
+		entry, ok = robots.data[host]
+		if ok && entry.expired(time.Now()) && !entry.refreshing {
+			entry.refreshing = true
+			robots.refreshes.Add(1)
+			go robots.refresh(context.WithoutCancel(ctx), c, rules.Clone(), host)
+		}
+		robots.rw.Unlock()
+	}
+
 	if !ok {
-		robotsRef, err := url.Parse(robotsTxtPath)
-		if err != nil {
+		var err error
+		if entry, err = robots.fetch(ctx, c, rules, host, true); err != nil {
 			return err
 		}
 
-		aux := &colibri.Selector{}
-		robotsRules := aux.Rules(rules)
-		robotsRules.Method = "GET"
-		robotsRules.URL = rules.URL.ResolveReference(robotsRef)
-		robotsRules.IgnoreRobotsTxt = true
+		robots.rw.Lock()
+		robots.data[host] = entry
+		robots.touch(host)
+		robots.rw.Unlock()
+	}
 
-		resp, err := c.Do(robotsRules)
-		if err != nil {
-			return err
+	if entry.data.TestAgent(rules.URL.Path, robots.Agent(rules.Header.Get("User-Agent"))) {
+		return nil
+	}
+	return ErrorRobotstxtRestriction
+}
+
+// fetch gets the robots.txt of the host and returns the entry to store,
+// with the expiration of the TTL or, if it is a server error, of the ErrorTTL.
+// If useStorage is false, the Storage is not read.
+func (robots *RobotsData) fetch(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules, host string, useStorage bool) (*robotsEntry, error) {
+	statusCode, buf, err := robots.get(ctx, c, rules, host, useStorage)
+	if err != nil {
+		return nil, err
+	}
+
+	return robots.newEntry(statusCode, buf)
+}
+
+// newEntry parses the robots.txt and returns the entry to store,
+// with the expiration of the TTL or, if it is a server error, of the ErrorTTL.
+func (robots *RobotsData) newEntry(statusCode int, buf []byte) (*robotsEntry, error) {
+	data, err := robotstxt.FromStatusAndBytes(statusCode, buf)
+	if err != nil {
+		return nil, err
+	}
+	entry := &robotsEntry{data: data, statusCode: statusCode, body: buf, failed: statusCode >= 500}
+
+	ttl := robots.TTL
+	if entry.failed {
+		ttl = robots.ErrorTTL
+	}
+
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	} else if entry.failed {
+		// expired, it is requested again by the next request
+		entry.expires = time.Now()
+	}
+	return entry, nil
+}
+
+// refresh requests again the expired robots.txt of the host and replaces the stored one.
+// If the request fails, the expired robots.txt is used for ErrorTTL, see retryTTL.
+// The rules are a clone owned by the refresh, they are released when it ends.
+func (robots *RobotsData) refresh(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules, host string) {
+	defer robots.refreshes.Done()
+	defer colibri.ReleaseRules(rules)
+
+	entry, err := robots.fetch(ctx, c, rules, host, false)
+
+	robots.rw.Lock()
+	defer robots.rw.Unlock()
+
+	old, ok := robots.data[host]
+	if !ok {
+		// removed by Clear or MaxEntries
+		return
+	}
+
+	if (err != nil) || entry.failed {
+		old.expires = time.Now().Add(robots.retryTTL())
+		old.refreshing = false
+		return
+	}
+	robots.data[host] = entry
+}
+
+// retryTTL returns how long the expired robots.txt is used after a failed refresh.
+func (robots *RobotsData) retryTTL() time.Duration {
+	switch {
+	case robots.ErrorTTL > 0:
+		return robots.ErrorTTL
+	case robots.TTL > 0:
+		return robots.TTL
+	default:
+		return DefaultRobotsErrorTTL
+	}
+}
+
+// Agent returns the user agent with which the robots.txt are checked for a request
+// with the User-Agent, the UserAgent of the RobotsData if it is set.
+func (robots *RobotsData) Agent(userAgent string) string {
+	if robots.UserAgent != "" {
+		return robots.UserAgent
+	}
+	return userAgent
+}
+
+// CrawlDelay returns the Crawl-delay of the robots.txt of the host of the URL for the User-Agent,
+// see Agent, limited by MaxCrawlDelay. Returns zero if the robots.txt of the host is not stored,
+// it is stored by IsAllowed. See the colibri.CrawlDelayer interface.
+func (robots *RobotsData) CrawlDelay(u *url.URL, userAgent string) time.Duration {
+	if u == nil {
+		return 0
+	}
+
+	robots.rw.RLock()
+	entry, ok := robots.data[colibri.NormalizeHost(u.Host)]
+	robots.rw.RUnlock()
+	if !ok {
+		return 0
+	}
+
+	group := entry.data.FindGroup(robots.Agent(userAgent))
+	if (group == nil) || (group.CrawlDelay <= 0) {
+		return 0
+	}
+
+	if (robots.MaxCrawlDelay > 0) && (group.CrawlDelay > robots.MaxCrawlDelay) {
+		return robots.MaxCrawlDelay
+	}
+	return group.CrawlDelay
+}
+
+// Sitemaps returns the URLs of the Sitemap directives of the robots.txt of the host.
+// Returns nil if the robots.txt of the host is not stored, it is stored by IsAllowed.
+// See the colibri.Sitemapper interface.
+func (robots *RobotsData) Sitemaps(host string) []string {
+	robots.rw.RLock()
+	defer robots.rw.RUnlock()
+
+	entry, ok := robots.data[colibri.NormalizeHost(host)]
+	if !ok {
+		return nil
+	}
+	return slices.Clone(entry.data.Sitemaps)
+}
+
+// get returns the status code and the content of the robots.txt of the host of the URL,
+// from the Storage if it is stored, otherwise it is requested and stored in the Storage.
+// If the Storage fails or useStorage is false, the robots.txt is requested.
+func (robots *RobotsData) get(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules, host string, useStorage bool) (int, []byte, error) {
+	if (robots.Storage != nil) && useStorage {
+		if statusCode, buf, ok, err := robots.Storage.RobotsTxt(host); (err == nil) && ok {
+			return statusCode, buf, nil
 		}
+	}
 
-		buf, err := io.ReadAll(resp.Body())
-		if err != nil {
-			return err
+	robotsRef, err := url.Parse(robotsTxtPath)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	aux := &colibri.Selector{}
+	robotsRules := aux.Rules(rules)
+	robotsRules.Method = "GET"
+	robotsRules.URL = rules.URL.ResolveReference(robotsRef)
+	robotsRules.IgnoreRobotsTxt = true
+
+	defer colibri.ReleaseSelector(aux)
+	defer colibri.ReleaseRules(robotsRules)
+
+	resp, err := c.DoContext(ctx, robotsRules)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body().Close()
+
+	buf, err := io.ReadAll(resp.Body())
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// the server errors are temporary, the robots.txt is requested again after a restart
+	if (robots.Storage != nil) && (resp.StatusCode() < 500) {
+		robots.Storage.SetRobotsTxt(host, resp.StatusCode(), buf)
+	}
+	return resp.StatusCode(), buf, nil
+}
+
+// RobotsFile is a robots.txt stored by RobotsData, see Export and Import.
+type RobotsFile struct {
+	// Host normalized host of the robots.txt, see colibri.NormalizeHost.
+	Host string `json:"host"`
+
+	// StatusCode status code of the response of the robots.txt,
+	// the 4xx codes allow all the URLs of the host.
+	StatusCode int `json:"statusCode"`
+
+	// Body content of the robots.txt.
+	Body string `json:"body"`
+
+	// Expires time in which the robots.txt is requested again,
+	// zero if it does not expire.
+	Expires time.Time `json:"expires"`
+}
+
+// Preload stores the content of the robots.txt of the host as if it had been requested,
+// so the URLs of the host are checked without requesting it, e.g. in tests or
+// with the robots.txt of a previous crawl. It expires after the TTL.
+func (robots *RobotsData) Preload(host string, body []byte) error {
+	entry, err := robots.newEntry(http.StatusOK, slices.Clone(body))
+	if err != nil {
+		return err
+	}
+
+	robots.store(colibri.NormalizeHost(host), entry)
+	return nil
+}
+
+// Export returns the robots.txt stored sorted by host, the server errors are not exported.
+// See Import.
+func (robots *RobotsData) Export() []RobotsFile {
+	robots.rw.RLock()
+	defer robots.rw.RUnlock()
+
+	files := make([]RobotsFile, 0, len(robots.data))
+	for host, entry := range robots.data {
+		if entry.failed {
+			continue
 		}
 
-		robotsData, err = robotstxt.FromStatusAndBytes(resp.StatusCode(), buf)
+		files = append(files, RobotsFile{
+			Host:       host,
+			StatusCode: entry.statusCode,
+			Body:       string(entry.body),
+			Expires:    entry.expires,
+		})
+	}
+
+	slices.SortFunc(files, func(a, b RobotsFile) int {
+		return strings.Compare(a.Host, b.Host)
+	})
+	return files
+}
+
+// Import stores the robots.txt exported by Export, e.g. by another RobotsData or a previous crawl.
+// The expired robots.txt are used until they are refreshed, like the stored ones.
+// Returns the errors of the robots.txt that could not be parsed, by host.
+func (robots *RobotsData) Import(files []RobotsFile) error {
+	var errs error
+	for _, file := range files {
+		data, err := robotstxt.FromStatusAndBytes(file.StatusCode, []byte(file.Body))
 		if err != nil {
-			return err
+			errs = colibri.AddError(errs, file.Host, err)
+			continue
 		}
 
-		robots.rw.Lock()
-		robots.data[rules.URL.Host] = robotsData
-		robots.rw.Unlock()
-
-		colibri.ReleaseSelector(aux)
-		colibri.ReleaseRules(robotsRules)
+		robots.store(colibri.NormalizeHost(file.Host), &robotsEntry{
+			data:       data,
+			statusCode: file.StatusCode,
+			body:       []byte(file.Body),
+			expires:    file.Expires,
+			failed:     file.StatusCode >= 500,
+		})
 	}
+	return errs
+}
 
-	if robotsData.TestAgent(rules.URL.Path, rules.Header.Get("User-Agent")) {
-		return nil
+// store stores the entry of the host.
+func (robots *RobotsData) store(host string, entry *robotsEntry) {
+	robots.rw.Lock()
+	defer robots.rw.Unlock()
+
+	if robots.data == nil {
+		robots.data = make(map[string]*robotsEntry)
 	}
-	return ErrorRobotstxtRestriction
+
+	robots.data[host] = entry
+	robots.touch(host)
 }
 
 // Clear removes stored robots.txt restrictions.
 func (robots *RobotsData) Clear() {
 	robots.rw.Lock()
 	clear(robots.data)
+	robots.lruMu.Lock()
+	if robots.lru != nil {
+		robots.lru.clear()
+	}
+	robots.lruMu.Unlock()
 	robots.rw.Unlock()
 }
+
+// ReportCapabilities adds the "robots.txt", "crawl-delay" and "sitemaps" features to the report.
+// See the colibri.CapabilityReporter interface.
+func (robots *RobotsData) ReportCapabilities(caps *colibri.Capabilities) {
+	caps.Features = append(caps.Features, "robots.txt", "crawl-delay", "sitemaps")
+}
+
+// Len returns the number of hosts stored.
+func (robots *RobotsData) Len() int {
+	robots.rw.RLock()
+	defer robots.rw.RUnlock()
+	return len(robots.data)
+}
+
+// Evictions returns the number of hosts removed because MaxEntries was exceeded.
+func (robots *RobotsData) Evictions() uint64 {
+	return robots.evictions.Load()
+}
+
+// touch marks the host as recently used and removes the least
+// recently used hosts while MaxEntries is exceeded.
+// The caller must hold the write lock.
+func (robots *RobotsData) touch(host string) {
+	robots.lruMu.Lock()
+	defer robots.lruMu.Unlock()
+
+	if robots.lru == nil {
+		robots.lru = newHostLRU()
+	}
+	robots.lru.touch(host)
+
+	for (robots.MaxEntries > 0) && (robots.lru.len() > robots.MaxEntries) {
+		oldest, ok := robots.lru.oldest(func(h string) bool { return h != host })
+		if !ok {
+			return
+		}
+
+		robots.lru.remove(oldest)
+		delete(robots.data, oldest)
+		robots.evictions.Add(1)
+	}
+}
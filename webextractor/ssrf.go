@@ -0,0 +1,98 @@
+package webextractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// ErrBlockedAddress is returned when the address of the host is private,
+// loopback, link-local or reserved and Client.BlockPrivateIPs is true.
+var ErrBlockedAddress = errors.New("address not allowed")
+
+// blockedPrefixes are the reserved ranges blocked in addition to the
+// private, loopback, link-local, multicast and unspecified addresses.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"),  // Shared address space (CGNAT)
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // Reserved
+	netip.MustParsePrefix("64:ff9b::/96"),   // IPv4/IPv6 translation
+	netip.MustParsePrefix("64:ff9b:1::/48"), // Local-use IPv4/IPv6 translation
+	netip.MustParsePrefix("2001:db8::/32"),  // Documentation
+	netip.MustParsePrefix("fec0::/10"),      // Site-local (deprecated)
+}
+
+// IsPublicAddr returns false if the address is private (RFC 1918, RFC 4193),
+// loopback, link-local (including the cloud metadata address 169.254.169.254),
+// multicast, unspecified or reserved.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsPrivate() || addr.IsLoopback() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckPublicHost resolves the host and returns ErrBlockedAddress
+// if any of its addresses is not public, see IsPublicAddr.
+func CheckPublicHost(ctx context.Context, host string) error {
+	_, err := publicAddrs(ctx, host)
+	return err
+}
+
+// publicAddrs returns the addresses of the host,
+// ErrBlockedAddress if any of them is not public.
+func publicAddrs(ctx context.Context, host string) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, addr := range addrs {
+		if !IsPublicAddr(addr) {
+			return nil, fmt.Errorf("%w: %s (%s)", ErrBlockedAddress, host, addr)
+		}
+	}
+	return addrs, nil
+}
+
+// dialPublic resolves the host of the address and dials the first public address.
+// The resolved address is dialed so that a second resolution cannot return another address.
+func (client *Client) dialPublic(ctx context.Context, network, addr string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := publicAddrs(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs error
+	for _, a := range addrs {
+		conn, err := dial(ctx, network, net.JoinHostPort(a.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = errors.Join(errs, err)
+	}
+	return nil, errs
+}
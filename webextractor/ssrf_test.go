@@ -0,0 +1,77 @@
+package webextractor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		Addr string
+		Want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fd00:ec2::254", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPublicAddr(netip.MustParseAddr(tt.Addr)); got != tt.Want {
+			t.Fatalf(prefixGotWantFormat, tt.Addr, got, tt.Want)
+		}
+	}
+}
+
+func TestBlockPrivateIPs(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, ts.URL, http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.BlockPrivateIPs = true
+
+	c := colibri.New()
+	c.Client = client
+
+	if _, err := c.Do(&colibri.Rules{URL: mustNewURL(ts.URL)}); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf(gotWantFormat, err, ErrBlockedAddress)
+	}
+
+	// A public address that redirects to a private address.
+	client.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == "93.184.216.34:80" {
+			addr = ts.Listener.Addr().String()
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+
+	_, err = c.Do(&colibri.Rules{URL: mustNewURL("http://93.184.216.34/redirect"), Header: http.Header{}})
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf(gotWantFormat, err, ErrBlockedAddress)
+	}
+}
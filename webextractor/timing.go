@@ -0,0 +1,143 @@
+package webextractor
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Timing contains the duration of each phase of the HTTP request.
+// Phases that did not occur (e.g. TLS in HTTP requests) have zero duration.
+type Timing struct {
+	DNS     time.Duration `json:"dns"`
+	Connect time.Duration `json:"connect"`
+	TLS     time.Duration `json:"tls"`
+
+	// TTFB time from the start of the request to the first byte of the response.
+	TTFB time.Duration `json:"ttfb"`
+
+	// Total time from the start of the request until the body is read
+	// or, if the body has not been read yet, until the header is received.
+	Total time.Duration `json:"total"`
+}
+
+// Size contains the size in bytes of the request and the response.
+type Size struct {
+	RequestHeader  int64 `json:"requestHeader"`
+	ResponseHeader int64 `json:"responseHeader"`
+
+	// ResponseBody number of bytes of the body read so far.
+	ResponseBody int64 `json:"responseBody"`
+}
+
+// tracer records the timestamps of the phases of the HTTP request.
+type tracer struct {
+	mu sync.Mutex
+
+	start, header                         time.Time
+	dnsStart, dnsDone                     time.Time
+	connectStart, connectDone             time.Time
+	tlsStart, tlsDone, firstByte          time.Time
+	bodyDone                              atomic.Int64
+	bodySize                              atomic.Int64
+	requestHeaderSize, responseHeaderSize int64
+}
+
+func newTracer() *tracer {
+	return &tracer{start: time.Now()}
+}
+
+func (tr *tracer) clientTrace() *httptrace.ClientTrace {
+	set := func(t *time.Time) {
+		tr.mu.Lock()
+		*t = time.Now()
+		tr.mu.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { set(&tr.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { set(&tr.dnsDone) },
+		ConnectStart:         func(string, string) { set(&tr.connectStart) },
+		ConnectDone:          func(string, string, error) { set(&tr.connectDone) },
+		TLSHandshakeStart:    func() { set(&tr.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { set(&tr.tlsDone) },
+		GotFirstResponseByte: func() { set(&tr.firstByte) },
+	}
+}
+
+// done records the reception of the response header.
+func (tr *tracer) done(resp *http.Response) {
+	tr.mu.Lock()
+	tr.header = time.Now()
+	tr.requestHeaderSize = headerSize(resp.Request.Header)
+	tr.responseHeaderSize = headerSize(resp.Header)
+	tr.mu.Unlock()
+
+	resp.Body = &tracedBody{ReadCloser: resp.Body, tr: tr}
+}
+
+func (tr *tracer) timing() Timing {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	timing := Timing{
+		DNS:     since(tr.dnsStart, tr.dnsDone),
+		Connect: since(tr.connectStart, tr.connectDone),
+		TLS:     since(tr.tlsStart, tr.tlsDone),
+		TTFB:    since(tr.start, tr.firstByte),
+		Total:   since(tr.start, tr.header),
+	}
+
+	if bodyDone := tr.bodyDone.Load(); bodyDone > 0 {
+		timing.Total = time.Unix(0, bodyDone).Sub(tr.start)
+	}
+	return timing
+}
+
+func (tr *tracer) size() Size {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	return Size{
+		RequestHeader:  tr.requestHeaderSize,
+		ResponseHeader: tr.responseHeaderSize,
+		ResponseBody:   tr.bodySize.Load(),
+	}
+}
+
+func since(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// headerSize returns the size of the header in HTTP/1.1 format.
+func headerSize(header http.Header) int64 {
+	var size int64
+	for key, values := range header {
+		for _, value := range values {
+			size += int64(len(key) + len(": ") + len(value) + len("\r\n"))
+		}
+	}
+	return size
+}
+
+// tracedBody counts the bytes read and records when the body has been read.
+type tracedBody struct {
+	io.ReadCloser
+	tr *tracer
+}
+
+func (body *tracedBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	body.tr.bodySize.Add(int64(n))
+	if err == io.EOF {
+		body.tr.bodyDone.CompareAndSwap(0, time.Now().UnixNano())
+	}
+	return n, err
+}
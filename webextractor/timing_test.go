@@ -0,0 +1,52 @@
+package webextractor
+
+import (
+	"io"
+	"testing"
+
+	"github.com/eduardogxnzalez/colibri"
+)
+
+func TestResponseTiming(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil     // Deactivate Delay
+	we.RobotsTxt = nil // Deactivate RobotsTxt
+
+	resp, err := we.Do(&colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + "/html")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := resp.(*Response)
+	before := r.Timing()
+	if (before.TTFB <= 0) || (before.Connect <= 0) || (before.Total < before.TTFB) {
+		t.Fatalf("unexpected timing %+v", before)
+	}
+
+	body, err := io.ReadAll(resp.Body())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := r.Timing()
+	if after.Total < before.Total {
+		t.Fatalf("unexpected timing %+v", after)
+	}
+
+	size := r.Size()
+	if size.ResponseBody != int64(len(body)) {
+		t.Fatalf(prefixGotWantFormat, "ResponseBody", size.ResponseBody, len(body))
+	} else if (size.RequestHeader <= 0) || (size.ResponseHeader <= 0) {
+		t.Fatalf("unexpected size %+v", size)
+	}
+
+	if (&Response{}).Timing() != (Timing{}) {
+		t.Fatal("must be empty")
+	}
+}
@@ -0,0 +1,93 @@
+package webextractor
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTOTPPeriod is the default period of validity of a TOTP code.
+	DefaultTOTPPeriod = 30 * time.Second
+
+	// DefaultTOTPDigits is the default number of digits of a TOTP code.
+	DefaultTOTPDigits = 6
+)
+
+var (
+	// ErrInvalidTOTPSecret is returned when the TOTP secret is not a valid base32 string.
+	ErrInvalidTOTPSecret = errors.New("invalid TOTP secret")
+
+	// ErrInvalidTOTPPeriod is returned when the TOTP period is negative or less than a second.
+	ErrInvalidTOTPPeriod = errors.New("invalid TOTP period")
+
+	// ErrInvalidTOTPDigits is returned when the TOTP digits are not between 1 and 9.
+	ErrInvalidTOTPDigits = errors.New("invalid TOTP digits")
+)
+
+// TOTP generates time-based one-time passwords (RFC 6238) with HMAC-SHA1,
+// compatible with authenticator apps.
+type TOTP struct {
+	// Secret base32 encoded secret, as shown by the site when 2FA is enabled.
+	Secret string
+
+	// Field name of the form field in which the code is sent.
+	Field string
+
+	// FormSelector CSS selector of the form that contains the field
+	// when it is not in the login form, if empty DefaultFormSelector is used.
+	FormSelector string
+
+	// Period of validity of a code, at least a second. If zero DefaultTOTPPeriod is used.
+	Period time.Duration
+
+	// Digits of the code, between 1 and 9. If zero DefaultTOTPDigits is used.
+	Digits int
+}
+
+// Code returns the code valid at time t.
+// Returns ErrInvalidTOTPPeriod or ErrInvalidTOTPDigits if the Period or the Digits are out of range.
+func (totp *TOTP) Code(t time.Time) (string, error) {
+	secret := strings.ToUpper(strings.ReplaceAll(totp.Secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if (err != nil) || (len(key) == 0) {
+		return "", ErrInvalidTOTPSecret
+	}
+
+	period := totp.Period
+	if period == 0 {
+		period = DefaultTOTPPeriod
+	} else if period < time.Second {
+		return "", ErrInvalidTOTPPeriod
+	}
+
+	digits := totp.Digits
+	if digits == 0 {
+		digits = DefaultTOTPDigits
+	} else if (digits < 0) || (digits > 9) {
+		return "", ErrInvalidTOTPDigits
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(period/time.Second)))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	code := strconv.FormatUint(uint64(value%mod), 10)
+	return strings.Repeat("0", digits-len(code)) + code, nil
+}
@@ -0,0 +1,243 @@
+package webextractor
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+)
+
+var (
+	// ErrHTTP2NotNegotiated is returned when HTTP/2 is forced and the server does not negotiate it.
+	ErrHTTP2NotNegotiated = errors.New("HTTP/2 was not negotiated")
+
+	// ErrProxyUnsupported is returned when the requests with a proxy use a protocol that does not support it,
+	// see ClientOptions.ForceHTTP2 and ClientOptions.EnableHTTP3.
+	ErrProxyUnsupported = errors.New("proxy is not supported by the protocol")
+)
+
+// ClientOptions configures the transport of the Client, see NewClientWithOptions.
+type ClientOptions struct {
+	// ForceHTTP2 specifies whether the requests are only sent with HTTP/2,
+	// the connections to the servers that do not negotiate HTTP/2 fail with ErrHTTP2NotNegotiated.
+	// The http URLs use HTTP/2 without TLS (h2c with prior knowledge).
+	// The requests with a proxy return ErrProxyUnsupported.
+	ForceHTTP2 bool
+
+	// DisableHTTP2 specifies whether the requests are only sent with HTTP/1.1.
+	DisableHTTP2 bool
+
+	// EnableHTTP3 specifies whether the requests of the https URLs are sent with HTTP/3 over QUIC.
+	// The requests with a proxy return ErrProxyUnsupported.
+	EnableHTTP3 bool
+
+	// HTTP3Transport is the HTTP/3 transport used if EnableHTTP3 is true, its TLS configuration
+	// is not modified. If nil, the HTTP/3 transport of quic-go is used with the TLS configuration
+	// of the rules, or of the options, and the dial of the Client, see HostOverride and BlockPrivateIPs.
+	HTTP3Transport http.RoundTripper
+
+	// TLSConfig specifies the TLS configuration of the connections.
+	// If nil, the default configuration is used.
+	TLSConfig *tls.Config
+}
+
+// protocolTransport sends the requests with the protocol selected by the ClientOptions,
+// the requests that use the default protocols are sent with the http.Transport.
+type protocolTransport struct {
+	client    *Client
+	transport *http.Transport
+	tlsConfig *tls.Config // of the rules, nil if the rules do not have TLS
+}
+
+func (t *protocolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	opts := &t.client.Options
+	if opts.EnableHTTP3 && (req.URL.Scheme == "https") {
+		if opts.HTTP3Transport != nil {
+			return opts.HTTP3Transport.RoundTrip(req)
+		}
+		return t.client.http3Transport(t.tlsConfig).RoundTrip(req)
+	}
+
+	if opts.ForceHTTP2 {
+		return t.client.http2Transport(req.URL.Scheme == "http", t.tlsConfig).RoundTrip(req)
+	}
+	return t.transport.RoundTrip(req)
+}
+
+// usesProtocolTransport returns true if the options require protocolTransport.
+func (opts *ClientOptions) usesProtocolTransport() bool {
+	return opts.ForceHTTP2 || opts.EnableHTTP3
+}
+
+// applyTo applies the options to the http.Transport, see transportTLSConfig.
+func (opts *ClientOptions) applyTo(t *http.Transport) {
+	if opts.DisableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+}
+
+// transportTLSConfig returns a copy of the TLS configuration of the rules or, if nil, of the options
+// for an http.Transport. HTTP/2 is offered with ALPN unless DisableHTTP2 is true, the http.Transport
+// only offers it on its first use.
+func (opts *ClientOptions) transportTLSConfig(cfg *tls.Config) *tls.Config {
+	if cfg == nil {
+		cfg = opts.TLSConfig
+	}
+
+	if cfg == nil {
+		cfg = &tls.Config{}
+	} else {
+		cfg = cfg.Clone()
+	}
+
+	if !opts.DisableHTTP2 && (len(cfg.NextProtos) == 0) {
+		cfg.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+	}
+	return cfg
+}
+
+// http2Transport returns the HTTP/2 transport of the Client, over TLS with the TLS configuration
+// of the rules, or of the options if nil, or, if cleartext is true, h2c.
+// The connections are made with the dial function of the Client, see DialContext and BlockPrivateIPs.
+func (client *Client) http2Transport(cleartext bool, tlsConfig *tls.Config) *http2.Transport {
+	client.rw.Lock()
+	defer client.rw.Unlock()
+
+	if cleartext {
+		if client.h2c == nil {
+			client.h2c = &http2.Transport{
+				AllowHTTP:          true,
+				DisableCompression: true, // see Client.Decoders
+				DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+					return client.dialContext(ctx, network, addr)
+				},
+			}
+		}
+		return client.h2c
+	}
+
+	if tlsConfig == nil {
+		tlsConfig = client.Options.TLSConfig
+	}
+
+	if h2, ok := client.h2[tlsConfig]; ok {
+		return h2
+	}
+
+	if client.h2 == nil {
+		client.h2 = make(map[*tls.Config]*http2.Transport)
+	}
+
+	h2 := &http2.Transport{
+		TLSClientConfig:    tlsConfig.Clone(),
+		DisableCompression: true, // see Client.Decoders
+		DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+			conn, err := client.dialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+
+			// http/1.1 is offered so the servers without HTTP/2 complete
+			// the handshake and ErrHTTP2NotNegotiated is returned
+			if !slices.Contains(cfg.NextProtos, "http/1.1") {
+				cfg.NextProtos = append(cfg.NextProtos, "http/1.1")
+			}
+
+			tlsConn := tls.Client(conn, cfg)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+
+			if tlsConn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+				conn.Close()
+				return nil, ErrHTTP2NotNegotiated
+			}
+			return tlsConn, nil
+		},
+	}
+	client.h2[tlsConfig] = h2
+	return h2
+}
+
+// http3Transport returns the HTTP/3 transport of the Client with the TLS configuration
+// of the rules, or of the options if nil.
+// The connections are made with the dial of the Client, see dialQUIC.
+func (client *Client) http3Transport(tlsConfig *tls.Config) *http3.RoundTripper {
+	client.rw.Lock()
+	defer client.rw.Unlock()
+
+	if tlsConfig == nil {
+		tlsConfig = client.Options.TLSConfig
+	}
+
+	if h3, ok := client.h3[tlsConfig]; ok {
+		return h3
+	}
+
+	if client.h3 == nil {
+		client.h3 = make(map[*tls.Config]*http3.RoundTripper)
+	}
+
+	h3 := &http3.RoundTripper{
+		TLSClientConfig:    tlsConfig.Clone(),
+		DisableCompression: true, // see Client.Decoders
+		Dial:               client.dialQUIC,
+	}
+	client.h3[tlsConfig] = h3
+	return h3
+}
+
+// dialQUIC makes the QUIC connections of the HTTP/3 transport to the address,
+// or to its HostOverride, only to the public addresses if BlockPrivateIPs is true.
+func (client *Client) dialQUIC(ctx context.Context, addr string, tlsConfig *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	addr = client.overrideAddr(addr)
+	if !client.BlockPrivateIPs {
+		return quic.DialAddrEarly(ctx, addr, tlsConfig, cfg)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := publicAddrs(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs error
+	for _, a := range addrs {
+		conn, err := quic.DialAddrEarly(ctx, net.JoinHostPort(a.String(), port), tlsConfig, cfg)
+		if err == nil {
+			return conn, nil
+		}
+		errs = errors.Join(errs, err)
+	}
+	return nil, errs
+}
+
+// closeIdleConnections closes the idle connections of the HTTP/2 and HTTP/3 transports.
+func (client *Client) closeIdleConnections() {
+	client.rw.RLock()
+	defer client.rw.RUnlock()
+
+	if client.h2c != nil {
+		client.h2c.CloseIdleConnections()
+	}
+
+	for _, t := range client.h2 {
+		t.CloseIdleConnections()
+	}
+
+	for _, t := range client.h3 {
+		t.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,319 @@
+package webextractor
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eduardogxnzalez/colibri"
+
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestClientOptions(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	})
+
+	h2Server := httptest.NewUnstartedServer(handler)
+	h2Server.EnableHTTP2 = true
+	h2Server.StartTLS()
+	defer h2Server.Close()
+
+	h1Server := httptest.NewTLSServer(handler)
+	defer h1Server.Close()
+
+	h2cServer := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer h2cServer.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(h2Server.Certificate())
+	roots.AddCert(h1Server.Certificate())
+
+	tests := []struct {
+		Options ClientOptions
+		URL     string
+		Proxy   string
+		Want    string
+		Err     error
+	}{
+		{ClientOptions{TLSConfig: &tls.Config{RootCAs: roots}}, h2Server.URL, "", "HTTP/2.0", nil},
+		{ClientOptions{TLSConfig: &tls.Config{RootCAs: roots}, DisableHTTP2: true}, h2Server.URL, "", "HTTP/1.1", nil},
+		{ClientOptions{TLSConfig: &tls.Config{RootCAs: roots}, ForceHTTP2: true}, h2Server.URL, "", "HTTP/2.0", nil},
+		{ClientOptions{TLSConfig: &tls.Config{RootCAs: roots}, ForceHTTP2: true}, h1Server.URL, "", "", ErrHTTP2NotNegotiated},
+		{ClientOptions{ForceHTTP2: true}, h2cServer.URL, "", "HTTP/2.0", nil},
+		{ClientOptions{}, h2cServer.URL, "", "HTTP/1.1", nil},
+		{ClientOptions{ForceHTTP2: true}, h2cServer.URL, "http://proxy.test:8080", "", ErrProxyUnsupported},
+		{ClientOptions{EnableHTTP3: true}, h2Server.URL, "http://proxy.test:8080", "", ErrProxyUnsupported},
+		{ClientOptions{EnableHTTP3: true}, h2cServer.URL, "", "HTTP/1.1", nil}, // http URLs do not use HTTP/3
+	}
+
+	for _, tt := range tests {
+		client, err := NewClientWithOptions(tt.Options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rules := &colibri.Rules{Method: "GET", URL: mustNewURL(tt.URL)}
+		if tt.Proxy != "" {
+			rules.Proxy = mustNewURL(tt.Proxy)
+		}
+
+		resp, err := client.Do(colibri.New(), rules)
+		if !errors.Is(err, tt.Err) {
+			t.Fatalf("got %v, want %v", err, tt.Err)
+		} else if err != nil {
+			continue
+		}
+
+		body, _ := io.ReadAll(resp.Body())
+		resp.Body().Close()
+		if string(body) != tt.Want {
+			t.Fatalf("got %v, want %v", string(body), tt.Want)
+		}
+		client.Clear()
+	}
+}
+
+func TestHTTP3Transport(t *testing.T) {
+	var requested []string
+	client, err := NewClientWithOptions(ClientOptions{
+		EnableHTTP3: true,
+		HTTP3Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			requested = append(requested, req.URL.String())
+			return &http.Response{
+				Status:     "200 OK",
+				StatusCode: http.StatusOK,
+				Proto:      "HTTP/3.0",
+				ProtoMajor: 3,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader("HTTP/3.0")),
+				Request:    req,
+			}, nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(colibri.New(), &colibri.Rules{Method: "GET", URL: mustNewURL("https://h3.test/page")})
+	if err != nil {
+		t.Fatal(err)
+	} else if resp.StatusCode() != http.StatusOK {
+		t.Fatalf("got %v, want %v", resp.StatusCode(), http.StatusOK)
+	}
+
+	if (len(requested) != 1) || (requested[0] != "https://h3.test/page") {
+		t.Fatalf("got %v, want %v", requested, "https://h3.test/page")
+	}
+
+	caps := &colibri.Capabilities{}
+	client.ReportCapabilities(caps)
+	if !strings.Contains(strings.Join(caps.Features, ","), "http3") {
+		t.Fatalf("got %v, want http3", caps.Features)
+	}
+}
+
+func TestHTTP3(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	server := &http3.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.Proto)
+		}),
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: tlsServer.TLS.Certificates}),
+	}
+	defer server.Close()
+	go server.Serve(conn)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(tlsServer.Certificate())
+
+	u := mustNewURL("https://" + conn.LocalAddr().String() + "/")
+	tests := []struct {
+		Options ClientOptions
+		Want    string
+		Err     error
+	}{
+		{ClientOptions{EnableHTTP3: true, TLSConfig: &tls.Config{RootCAs: roots}}, "HTTP/3.0", nil},
+		{ClientOptions{EnableHTTP3: true}, "", nil}, // unknown authority
+	}
+
+	for _, tt := range tests {
+		client, err := NewClientWithOptions(tt.Options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Do(colibri.New(), &colibri.Rules{Method: "GET", URL: u})
+		if tt.Want == "" {
+			if err == nil {
+				t.Fatal("nil error")
+			}
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := io.ReadAll(resp.Body())
+		resp.Body().Close()
+		if string(body) != tt.Want {
+			t.Fatalf("got %v, want %v", string(body), tt.Want)
+		}
+		client.Clear()
+	}
+
+	t.Run("BlockPrivateIPs", func(t *testing.T) {
+		client, err := NewClientWithOptions(ClientOptions{EnableHTTP3: true, TLSConfig: &tls.Config{RootCAs: roots}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.BlockPrivateIPs = true
+
+		if _, err := client.Do(colibri.New(), &colibri.Rules{Method: "GET", URL: u}); !errors.Is(err, ErrBlockedAddress) {
+			t.Fatalf("got %v, want %v", err, ErrBlockedAddress)
+		}
+	})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestRulesTLS(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	})
+
+	errorLog := log.New(io.Discard, "", 0) // handshake errors of the invalid certificates
+
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.Config.ErrorLog = errorLog
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	certFile, keyFile := writeClientCert(t, dir)
+
+	clientCAs := x509.NewCertPool()
+	certPEM, _ := os.ReadFile(certFile)
+	clientCAs.AppendCertsFromPEM(certPEM)
+
+	mTLSServer := httptest.NewUnstartedServer(handler)
+	mTLSServer.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	mTLSServer.Config.ErrorLog = errorLog
+	mTLSServer.StartTLS()
+	defer mTLSServer.Close()
+
+	serverCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	tests := []struct {
+		Options ClientOptions
+		URL     string
+		TLS     *colibri.TLS
+		Want    string
+		AnErr   bool
+	}{
+		{ClientOptions{}, server.URL, nil, "", true},
+		{ClientOptions{}, server.URL, &colibri.TLS{InsecureSkipVerify: true}, "HTTP/2.0", false},
+		{ClientOptions{}, server.URL, &colibri.TLS{RootCAs: []string{serverCA}}, "HTTP/2.0", false},
+		{ClientOptions{}, server.URL, nil, "", true}, // the pooled transport does not keep the TLS of the rules
+		{ClientOptions{DisableHTTP2: true}, server.URL, &colibri.TLS{InsecureSkipVerify: true}, "HTTP/1.1", false},
+		{ClientOptions{ForceHTTP2: true}, server.URL, &colibri.TLS{InsecureSkipVerify: true}, "HTTP/2.0", false},
+		{ClientOptions{}, server.URL, &colibri.TLS{InsecureSkipVerify: true, MinVersion: tls.VersionTLS13}, "HTTP/2.0", false},
+		{ClientOptions{}, mTLSServer.URL, &colibri.TLS{InsecureSkipVerify: true}, "", true},
+		{ClientOptions{}, mTLSServer.URL, &colibri.TLS{InsecureSkipVerify: true, CertFile: certFile, KeyFile: keyFile}, "HTTP/1.1", false},
+		{ClientOptions{}, server.URL, &colibri.TLS{RootCAs: []string{filepath.Join(dir, "missing.pem")}}, "", true},
+	}
+
+	// the requests without options share the client and the transports of its pool
+	shared, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		client := shared
+		if tt.Options != (ClientOptions{}) {
+			if client, err = NewClientWithOptions(tt.Options); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		resp, err := client.Do(colibri.New(), &colibri.Rules{Method: "GET", URL: mustNewURL(tt.URL), TLS: tt.TLS})
+		if (err != nil) != tt.AnErr {
+			t.Fatalf("%v: got %v, want error %v", tt.TLS, err, tt.AnErr)
+		} else if err != nil {
+			continue
+		}
+
+		body, _ := io.ReadAll(resp.Body())
+		resp.Body().Close()
+		if string(body) != tt.Want {
+			t.Fatalf("got %v, want %v", string(body), tt.Want)
+		}
+	}
+}
+
+// writeClientCert writes a self-signed client certificate and its key in the directory.
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "colibri"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
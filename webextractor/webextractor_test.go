@@ -2,19 +2,35 @@ package webextractor
 
 import (
 	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
 	"net/url"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/eduardogxnzalez/colibri"
+	"github.com/eduardogxnzalez/colibri/parsers"
+	"github.com/eduardogxnzalez/colibri/storage"
+
+	"github.com/andybalholm/brotli"
+	"github.com/temoto/robotstxt"
 )
 
 const (
@@ -120,6 +136,30 @@ func TestColibriExtract(t *testing.T) {
 	if !reflect.DeepEqual(output, wantOutput) {
 		t.Fatal(output, wantOutput)
 	}
+
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		resp, err := we.DoContext(ctx, rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+
+		// The regular expressions and the follow requests use the context of the response.
+		_, err = we.Parser.Parse(rules, resp)
+
+		var errs *colibri.Errs
+		if !errors.As(err, &errs) {
+			t.Fatalf(gotWantFormat, err, "*colibri.Errs")
+		} else if err, _ := errs.Get("html"); !errors.Is(err, context.Canceled) {
+			t.Fatalf(gotWantFormat, err, context.Canceled)
+		}
+
+		if _, _, err := we.ExtractContext(ctx, rules); !errors.Is(err, context.Canceled) {
+			t.Fatalf(gotWantFormat, err, context.Canceled)
+		}
+	})
 }
 
 func TestColibriCookies(t *testing.T) {
@@ -189,6 +229,42 @@ func TestColibriCookies(t *testing.T) {
 		}
 	})
 
+	t.Run("Session", func(t *testing.T) {
+		rules := &colibri.Rules{
+			Method:     "GET",
+			URL:        mustNewURL(ts.URL + "/set"),
+			UseCookies: true,
+			Session:    "session",
+		}
+
+		if _, err := we.Do(rules); err != nil {
+			t.Fatal(err)
+		}
+
+		client := we.Client.(*Client)
+		sessionJar, err := client.SessionJar(rules.Session)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(sessionJar.Cookies(rules.URL)) != 1 {
+			t.Fatal("session cookie not stored")
+		}
+
+		otherJar, err := client.SessionJar("other")
+		if err != nil {
+			t.Fatal(err)
+		} else if len(otherJar.Cookies(rules.URL)) != 0 {
+			t.Fatal("sessions share cookies")
+		}
+
+		client.DeleteSession(rules.Session)
+		sessionJar, _ = client.SessionJar(rules.Session)
+		if len(sessionJar.Cookies(rules.URL)) != 0 {
+			t.Fatal("session not deleted")
+		}
+	})
+
 	t.Run("ClientClear", func(t *testing.T) {
 		client := we.Client.(*Client)
 		if client.Jar == nil {
@@ -332,6 +408,674 @@ func TestColibriWithRobotsTxt(t *testing.T) {
 			t.Fatal("")
 		}
 	})
+
+	t.Run("RobotsDataStorage", func(t *testing.T) {
+		var (
+			robots = we.RobotsTxt.(*RobotsData)
+			u      = mustNewURL(ts.URL)
+			mem    = storage.NewMemory()
+		)
+		defer func() { robots.Storage = nil }()
+
+		robots.Storage = mem
+		robots.Clear()
+
+		rules := &colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + "/disallow"), Header: header}
+		if _, err := we.Do(rules); !errors.Is(err, ErrorRobotstxtRestriction) {
+			t.Fatalf(gotWantFormat, err, ErrorRobotstxtRestriction)
+		}
+
+		if _, _, ok, _ := mem.RobotsTxt(u.Host); !ok {
+			t.Fatal("robots.txt not stored")
+		}
+
+		// the stored robots.txt is used instead of requesting it
+		mem.SetRobotsTxt(u.Host, http.StatusOK, []byte("User-agent: *\nDisallow:"))
+		robots.Clear()
+
+		if _, err := we.Do(rules); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestRobotsDataMaxEntries(t *testing.T) {
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	robots := we.RobotsTxt.(*RobotsData)
+	robots.MaxEntries = 1
+
+	var hosts []string
+	for i := 0; i < 2; i++ {
+		ts := testServer()
+		defer ts.Close()
+
+		rules := &colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL)}
+		if _, err := we.Do(rules); err != nil {
+			t.Fatal(err)
+		}
+		hosts = append(hosts, rules.URL.Host)
+	}
+
+	if robots.Len() != 1 {
+		t.Fatalf(gotWantFormat, robots.Len(), 1)
+	} else if robots.Evictions() != 1 {
+		t.Fatalf(gotWantFormat, robots.Evictions(), 1)
+	}
+
+	if _, ok := robots.data[hosts[1]]; !ok {
+		t.Fatal("most recently used host removed")
+	}
+}
+
+func TestRobotsDataHits(t *testing.T) {
+	robots := NewRobotsData()
+	robots.MaxEntries = 2
+
+	store := func(host string) {
+		robotsData, err := robotstxt.FromString("User-agent: *\nDisallow: /private\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		robots.rw.Lock()
+		robots.data[host] = &robotsEntry{data: robotsData}
+		robots.touch(host)
+		robots.rw.Unlock()
+	}
+
+	for _, host := range []string{"a.test", "b.test"} {
+		store(host)
+	}
+
+	// concurrent hits with the read lock
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				robots.IsAllowed(nil, &colibri.Rules{URL: mustNewURL("http://a.test/page")})
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the hits of a.test make b.test the least recently used host
+	store("c.test")
+	for host, want := range map[string]bool{"a.test": true, "b.test": false, "c.test": true} {
+		if _, got := robots.data[host]; got != want {
+			t.Fatalf("%s: got %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestRobotsDataExpiry(t *testing.T) {
+	var (
+		requests   atomic.Int32
+		statusCode atomic.Int32
+		disallow   atomic.Bool
+	)
+	statusCode.Store(http.StatusOK)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			fmt.Fprint(w, r.URL.Path)
+			return
+		}
+
+		requests.Add(1)
+		w.WriteHeader(int(statusCode.Load()))
+		if disallow.Load() {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /page\n")
+		}
+	}))
+	defer ts.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	robots := we.RobotsTxt.(*RobotsData)
+	robots.TTL = 50 * time.Millisecond
+
+	do := func() error {
+		_, err := we.Do(&colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + "/page")})
+		return err
+	}
+
+	tests := []struct {
+		Name       string
+		StatusCode int
+		Disallow   bool
+		Wait       time.Duration
+		WantErr    error
+		Requests   int32
+	}{
+		{"cached", http.StatusOK, true, 0, nil, 1},
+		{"expired is used while refreshed", http.StatusOK, true, 60 * time.Millisecond, nil, 2},
+		{"refreshed", http.StatusOK, true, 0, ErrorRobotstxtRestriction, 2},
+		{"failed refresh keeps the expired", http.StatusServiceUnavailable, false, 60 * time.Millisecond, ErrorRobotstxtRestriction, 3},
+		{"failed refresh is cached", http.StatusOK, false, 60 * time.Millisecond, ErrorRobotstxtRestriction, 3},
+	}
+
+	for i, tt := range tests {
+		if i == 0 {
+			// the robots.txt allows the page until the first refresh
+			if err := do(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		statusCode.Store(int32(tt.StatusCode))
+		disallow.Store(tt.Disallow)
+		time.Sleep(tt.Wait)
+
+		if err := do(); !errors.Is(err, tt.WantErr) {
+			t.Fatalf("%s: got %v, want %v", tt.Name, err, tt.WantErr)
+		}
+		robots.refreshes.Wait()
+
+		if got := requests.Load(); got != tt.Requests {
+			t.Fatalf("%s: got %v, want %v", tt.Name, got, tt.Requests)
+		}
+	}
+
+	t.Run("ServerError", func(t *testing.T) {
+		robots.Clear()
+		robots.ErrorTTL = 50 * time.Millisecond
+		requests.Store(0)
+		statusCode.Store(http.StatusServiceUnavailable)
+
+		// the URLs of the host are disallowed and the robots.txt is not requested again
+		for i := 0; i < 2; i++ {
+			if err := do(); !errors.Is(err, ErrorRobotstxtRestriction) {
+				t.Fatalf(gotWantFormat, err, ErrorRobotstxtRestriction)
+			}
+		}
+
+		if got := requests.Load(); got != 1 {
+			t.Fatalf(gotWantFormat, got, 1)
+		}
+
+		statusCode.Store(http.StatusOK)
+		disallow.Store(false)
+		time.Sleep(60 * time.Millisecond)
+
+		do()
+		robots.refreshes.Wait()
+		if err := do(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ZeroErrorTTL", func(t *testing.T) {
+		robots.Clear()
+		robots.ErrorTTL = 0
+		requests.Store(0)
+		statusCode.Store(http.StatusOK)
+
+		do()
+		time.Sleep(60 * time.Millisecond)
+
+		// the failed refresh is retried after the TTL, not by each request
+		statusCode.Store(http.StatusServiceUnavailable)
+		for i := 0; i < 5; i++ {
+			do()
+			robots.refreshes.Wait()
+		}
+
+		if got := requests.Load(); got != 2 {
+			t.Fatalf(gotWantFormat, got, 2)
+		}
+	})
+}
+
+// recordClient records the rules of the requests and the closes of the response bodies.
+type recordClient struct {
+	*Client
+	rules  []*colibri.Rules
+	closed atomic.Int32
+}
+
+func (client *recordClient) DoContext(ctx context.Context, c *colibri.Colibri, rules *colibri.Rules) (colibri.Response, error) {
+	client.rules = append(client.rules, rules)
+
+	resp, err := client.Client.DoContext(ctx, c, rules)
+	if err != nil {
+		return nil, err
+	}
+	resp.(*Response).HTTP.Body = &closeCounter{ReadCloser: resp.(*Response).HTTP.Body, n: &client.closed}
+	return resp, nil
+}
+
+// closeCounter counts the calls to Close.
+type closeCounter struct {
+	io.ReadCloser
+	n *atomic.Int32
+}
+
+func (body *closeCounter) Close() error {
+	body.n.Add(1)
+	return body.ReadCloser.Close()
+}
+
+func TestRobotsDataRelease(t *testing.T) {
+	colibri.SetPoolDebug(true)
+	defer colibri.SetPoolDebug(false)
+
+	var truncated atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.URL.Path == "/robots.txt") && truncated.Load() {
+			w.Header().Set("Content-Length", "100")
+			fmt.Fprint(w, "User-agent")
+			return
+		}
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	client := &recordClient{Client: we.Client.(*Client)}
+	we.Client = client
+	robots := we.RobotsTxt.(*RobotsData)
+
+	tests := []struct {
+		Name      string
+		Truncated bool
+		WantErr   bool
+	}{
+		{"read", false, false},
+		{"read error", true, true},
+	}
+
+	for _, tt := range tests {
+		robots.Clear()
+		client.rules = nil
+		client.closed.Store(0)
+		truncated.Store(tt.Truncated)
+
+		_, err := we.Do(&colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + "/page")})
+		if (err != nil) != tt.WantErr {
+			t.Fatalf("%s: got %v, want error %v", tt.Name, err, tt.WantErr)
+		}
+
+		if len(client.rules) == 0 {
+			t.Fatalf("%s: robots.txt not requested", tt.Name)
+		}
+
+		// the body of the robots.txt is closed and its rules are released
+		if got := client.closed.Load(); got < 1 {
+			t.Fatalf("%s: got %v, want %v", tt.Name, got, 1)
+		}
+
+		if _, err := we.Do(client.rules[0]); !errors.Is(err, colibri.ErrRulesReleased) {
+			t.Fatalf("%s: got %v, want %v", tt.Name, err, colibri.ErrRulesReleased)
+		}
+	}
+}
+
+func TestRobotsDataCrawlDelay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: slow\nCrawl-delay: 120\n\nUser-agent: *\nCrawl-delay: 0.05\nDisallow: /private\n\nSitemap: https://example.com/sitemap.xml\n")
+			return
+		}
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	robots := we.RobotsTxt.(*RobotsData)
+	u := mustNewURL(ts.URL + "/page")
+	if got := robots.CrawlDelay(u, "colibri"); got != 0 {
+		t.Fatalf(gotWantFormat, got, 0)
+	}
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := we.Do(&colibri.Rules{Method: "GET", URL: u}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("got %v, want at least %v", elapsed, 50*time.Millisecond)
+	}
+
+	tests := []struct {
+		UserAgent string
+		Want      time.Duration
+	}{
+		{"colibri", 50 * time.Millisecond},
+		{"slow", DefaultMaxCrawlDelay},
+	}
+
+	for _, tt := range tests {
+		if got := robots.CrawlDelay(u, tt.UserAgent); got != tt.Want {
+			t.Fatalf(gotWantFormat, got, tt.Want)
+		}
+	}
+
+	want := []string{"https://example.com/sitemap.xml"}
+	if got := robots.Sitemaps(u.Host); !reflect.DeepEqual(got, want) {
+		t.Fatalf(gotWantFormat, got, want)
+	}
+
+	if got := robots.Sitemaps("unknown.example.com"); got != nil {
+		t.Fatalf(gotWantFormat, got, nil)
+	}
+}
+
+func TestRobotsDataPreload(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			requests.Add(1)
+		}
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	u := mustNewURL(ts.URL + "/private")
+	robots := we.RobotsTxt.(*RobotsData)
+	if err := robots.Preload(u.Host, []byte("User-agent: *\nDisallow: /private\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := we.Do(&colibri.Rules{Method: "GET", URL: u}); !errors.Is(err, ErrorRobotstxtRestriction) {
+		t.Fatalf(gotWantFormat, err, ErrorRobotstxtRestriction)
+	} else if requests.Load() != 0 {
+		t.Fatalf(gotWantFormat, requests.Load(), 0)
+	}
+
+	if err := robots.Import([]RobotsFile{{Host: "down.example.com", StatusCode: http.StatusServiceUnavailable}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the server errors are not exported
+	files := robots.Export()
+	if len(files) != 1 {
+		t.Fatalf(gotWantFormat, len(files), 1)
+	} else if (files[0].Host != u.Host) || (files[0].StatusCode != http.StatusOK) || files[0].Expires.IsZero() {
+		t.Fatalf(gotWantFormat, files[0], u.Host)
+	}
+
+	b, err := json.Marshal(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var imported []RobotsFile
+	if err := json.Unmarshal(b, &imported); err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewRobotsData()
+	if err := other.Import(imported); err != nil {
+		t.Fatal(err)
+	}
+
+	we.RobotsTxt = other
+	if _, err := we.Do(&colibri.Rules{Method: "GET", URL: u}); !errors.Is(err, ErrorRobotstxtRestriction) {
+		t.Fatalf(gotWantFormat, err, ErrorRobotstxtRestriction)
+	} else if requests.Load() != 0 {
+		t.Fatalf(gotWantFormat, requests.Load(), 0)
+	}
+
+	if _, err := we.Do(&colibri.Rules{Method: "GET", URL: mustNewURL(ts.URL + "/public")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollowLimits(t *testing.T) {
+	links := map[string][]string{
+		"/1": {"/2", "/3"},
+		"/2": {"/4"},
+		"/3": {"/5"},
+	}
+
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head><body>", r.URL.Path)
+		for _, link := range links[r.URL.Path] {
+			fmt.Fprintf(w, `<a href="%s">%s</a>`, link, link)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+	defer ts.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	tests := []struct {
+		Limit, Value string
+		WantErr      error
+		WantRequests int32
+	}{
+		{colibri.KeyMaxDepth, "1", colibri.ErrDepthExceeded, 3},
+		{colibri.KeyMaxRequests, "2", colibri.ErrBudgetExceeded, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Limit, func(t *testing.T) {
+			requests.Store(0)
+
+			rules, err := colibri.NewRules(map[string]any{
+				"URL":             ts.URL + "/1",
+				"IgnoreRobotsTxt": true,
+				tt.Limit:          tt.Value,
+				"Selectors": map[string]any{
+					"links": map[string]any{
+						"Expr":   "//a/@href",
+						"All":    true,
+						"Follow": true,
+						"Selectors": map[string]any{
+							"title": "//title",
+							"links": map[string]any{"Expr": "//a/@href", "All": true, "Follow": true},
+						},
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _, err = we.Extract(rules)
+			if !errors.Is(err, tt.WantErr) {
+				t.Fatalf(gotWantFormat, err, tt.WantErr)
+			}
+
+			if got := requests.Load(); got != tt.WantRequests {
+				t.Fatalf(gotWantFormat, got, tt.WantRequests)
+			}
+		})
+	}
+}
+
+func TestRobotsDataUserAgent(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil // Deactivate Delay
+
+	u := mustNewURL(ts.URL + "/")
+	robots := we.RobotsTxt.(*RobotsData)
+	if err := robots.Preload(u.Host, []byte("User-agent: MyBot\nDisallow: /\nCrawl-delay: 5\n\nUser-agent: *\nDisallow:\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := &colibri.Rules{Method: "GET", URL: u, Header: http.Header{"User-Agent": {"Mozilla/5.0"}}}
+	if _, err := we.Do(rules); err != nil {
+		t.Fatal(err)
+	} else if got := robots.CrawlDelay(u, "Mozilla/5.0"); got != 0 {
+		t.Fatalf(gotWantFormat, got, 0)
+	}
+
+	// the groups of MyBot apply to any User-Agent
+	robots.UserAgent = "MyBot"
+	if _, err := we.Do(rules); !errors.Is(err, ErrorRobotstxtRestriction) {
+		t.Fatalf(gotWantFormat, err, ErrorRobotstxtRestriction)
+	} else if got := robots.CrawlDelay(u, "Mozilla/5.0"); got != 5*time.Second {
+		t.Fatalf(gotWantFormat, got, 5*time.Second)
+	}
+
+	if got := robots.Agent("Mozilla/5.0"); got != "MyBot" {
+		t.Fatalf(gotWantFormat, got, "MyBot")
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	tsRedirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, ts.URL+"/", http.StatusFound)
+	}))
+	defer tsRedirect.Close()
+
+	we, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	we.Delay = nil     // Deactivate Delay
+	we.RobotsTxt = nil // Deactivate RobotsTxt
+
+	client := we.Client.(*Client)
+
+	tests := []struct {
+		Name         string
+		URL          string
+		StripHeaders []string
+		MaxRedirects int
+
+		WantURL        string
+		WantRedirected bool
+		WantHeaders    map[string]string
+		WantErr        error
+	}{
+		{
+			"CrossHost",
+			tsRedirect.URL,
+			nil,
+			0,
+			ts.URL + "/",
+			true,
+			map[string]string{"Authorization": "", "X-Api-Key": "key"},
+			nil,
+		},
+		{
+			"CustomStripHeaders",
+			tsRedirect.URL,
+			[]string{"X-Api-Key"},
+			0,
+			ts.URL + "/",
+			true,
+			map[string]string{"Authorization": "Bearer token", "X-Api-Key": ""},
+			nil,
+		},
+		{
+			"SameHost",
+			ts.URL + "/redirect?n=1",
+			nil,
+			0,
+			ts.URL + "/redirect?n=0",
+			true,
+			nil,
+			nil,
+		},
+		{
+			"NoRedirect",
+			ts.URL + "/disallow",
+			nil,
+			0,
+			ts.URL + "/disallow",
+			false,
+			nil,
+			nil,
+		},
+		{"TooManyRedirects", ts.URL + "/redirect?n=3", nil, 2, "", false, nil, ErrTooManyRedirects},
+		{"DisableRedirects", ts.URL + "/redirect?n=3", nil, -1, ts.URL + "/redirect?n=3", false, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			client.RedirectStripHeaders = tt.StripHeaders
+			client.MaxRedirects = tt.MaxRedirects
+
+			rules := &colibri.Rules{
+				Method: "GET",
+				URL:    mustNewURL(tt.URL),
+				Header: http.Header{
+					"Authorization": {"Bearer token"},
+					"X-Api-Key":     {"key"},
+				},
+			}
+
+			resp, err := we.Do(rules)
+			if (err != nil) || (tt.WantErr != nil) {
+				if !errors.Is(err, tt.WantErr) {
+					t.Fatalf(gotWantFormat, err, tt.WantErr)
+				}
+				return
+			}
+
+			r := resp.(*Response)
+			if r.URL().String() != tt.WantURL {
+				t.Fatalf(prefixGotWantFormat, "URL", r.URL(), tt.WantURL)
+			} else if r.OriginalURL().String() != tt.URL {
+				t.Fatalf(prefixGotWantFormat, "OriginalURL", r.OriginalURL(), tt.URL)
+			} else if r.Redirected() != tt.WantRedirected {
+				t.Fatalf(prefixGotWantFormat, "Redirected", r.Redirected(), tt.WantRedirected)
+			}
+
+			if tt.WantHeaders == nil {
+				return
+			}
+
+			reqDump, err := http.ReadRequest(bufio.NewReader(resp.Body()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for key, value := range tt.WantHeaders {
+				if reqDump.Header.Get(key) != value {
+					t.Fatalf(prefixGotWantFormat, key, reqDump.Header.Get(key), value)
+				}
+			}
+		})
+	}
 }
 
 /* Benchmark */
@@ -531,3 +1275,248 @@ func testServerCookies() *httptest.Server {
 		}
 	}))
 }
+
+func TestCapabilities(t *testing.T) {
+	c, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caps := c.Capabilities()
+	if caps.Version != colibri.Version() {
+		t.Fatalf(gotWantFormat, caps.Version, colibri.Version())
+	}
+
+	want := map[string][]string{
+		"Parsers":   {parsers.HTMLRegexp, parsers.JSONRegexp, parsers.TextRegexp, parsers.XMLRegexp},
+		"ExprTypes": {parsers.CSSSelector, parsers.JSONPathExpr, parsers.RegularExpr, parsers.XPathExpr},
+		"Features":  {"cookies", "sessions", "delay", "robots.txt"},
+	}
+
+	got := map[string][]string{
+		"Parsers":   caps.Parsers,
+		"ExprTypes": caps.ExprTypes,
+		"Features":  caps.Features,
+	}
+
+	for name, values := range want {
+		for _, v := range values {
+			if !slices.Contains(got[name], v) {
+				t.Fatalf("%s: %q not found in %v", name, v, got[name])
+			}
+		}
+
+		if !slices.IsSorted(got[name]) {
+			t.Fatalf("%s: not sorted %v", name, got[name])
+		}
+	}
+}
+
+func TestTimeoutBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "URL: /")
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := colibri.NewRules(map[string]any{
+		"URL":             ts.URL,
+		"Timeout":         "50ms",
+		"IgnoreRobotsTxt": true,
+		"Selectors":       map[string]any{"url": map[string]any{"Expr": "URL", "Type": "regular"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, _, err := c.Extract(rules); err == nil {
+		t.Fatal("nil error")
+	} else if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("body read not bounded by Timeout")
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/length" {
+			w.Header().Set("Content-Length", "10")
+		}
+
+		fmt.Fprint(w, "URL: ")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, "/body")
+	}))
+	defer ts.Close()
+
+	c, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Delay = nil // Deactivate Delay
+
+	tests := []struct {
+		Path        string
+		MaxBodySize int64
+		WantErr     error
+	}{
+		{"/length", 9, ErrBodyTooLarge},
+		{"/stream", 9, ErrBodyTooLarge},
+		{"/stream", 10, nil},
+		{"/stream", 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.Path, tt.MaxBodySize), func(t *testing.T) {
+			rules := &colibri.Rules{
+				URL:             mustNewURL(ts.URL + tt.Path),
+				IgnoreRobotsTxt: true,
+				MaxBodySize:     tt.MaxBodySize,
+				Selectors:       []*colibri.Selector{{Name: "url", Expr: "/body", Type: "regular"}},
+			}
+
+			_, output, err := c.Extract(rules)
+			if !errors.Is(err, tt.WantErr) {
+				t.Fatalf(gotWantFormat, err, tt.WantErr)
+			} else if (err == nil) && (output["url"] != "/body") {
+				t.Fatalf(gotWantFormat, output["url"], "/body")
+			}
+		})
+	}
+}
+
+func TestCompression(t *testing.T) {
+	const body = "URL: /compressed"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+
+		var buf bytes.Buffer
+		switch r.URL.Path {
+		case "/gzip":
+			gz := gzip.NewWriter(&buf)
+			io.WriteString(gz, body)
+			gz.Close()
+
+		case "/deflate":
+			zw := zlib.NewWriter(&buf)
+			io.WriteString(zw, body)
+			zw.Close()
+
+		case "/raw-deflate":
+			fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+			io.WriteString(fw, body)
+			fw.Close()
+
+		case "/br":
+			bw := brotli.NewWriter(&buf)
+			io.WriteString(bw, body)
+			bw.Close()
+
+		case "/gzip,deflate":
+			zw := zlib.NewWriter(&buf)
+			gz := gzip.NewWriter(zw)
+			io.WriteString(gz, body)
+			gz.Close()
+			zw.Close()
+
+		default:
+			buf.WriteString(body)
+		}
+
+		if encoding := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"), "raw-"); encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.Write(buf.Bytes())
+	}))
+	defer ts.Close()
+
+	c, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Delay = nil // Deactivate Delay
+
+	tests := []struct {
+		Path               string
+		Header             http.Header
+		DisableCompression bool
+
+		WantBody           string
+		WantAcceptEncoding string
+	}{
+		{"/gzip", nil, false, body, "br, deflate, gzip"},
+		{"/gzip", http.Header{"Accept-Encoding": {"gzip"}}, false, body, "gzip"},
+		{"/deflate", nil, false, body, "br, deflate, gzip"},
+		{"/raw-deflate", nil, false, body, "br, deflate, gzip"},
+		{"/gzip,deflate", nil, false, body, "br, deflate, gzip"},
+		{"/br", nil, false, body, "br, deflate, gzip"},
+		{"/compress", nil, false, body, "br, deflate, gzip"}, // not supported
+		{"/", nil, true, body, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.Path, tt.Header), func(t *testing.T) {
+			rules := &colibri.Rules{
+				URL:                mustNewURL(ts.URL + tt.Path),
+				Header:             tt.Header,
+				IgnoreRobotsTxt:    true,
+				DisableCompression: tt.DisableCompression,
+			}
+
+			resp, err := c.Do(rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body().Close()
+
+			b, err := io.ReadAll(resp.Body())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != tt.WantBody {
+				t.Fatalf(gotWantFormat, string(b), tt.WantBody)
+			} else if got := resp.Header().Get("X-Accept-Encoding"); got != tt.WantAcceptEncoding {
+				t.Fatalf(gotWantFormat, got, tt.WantAcceptEncoding)
+			}
+
+			if (tt.Path != "/compress") && (resp.Header().Get("Content-Encoding") != "") {
+				t.Fatalf(gotWantFormat, resp.Header().Get("Content-Encoding"), "")
+			}
+		})
+	}
+
+	t.Run("DisableCompression", func(t *testing.T) {
+		rules := &colibri.Rules{
+			URL:                mustNewURL(ts.URL + "/gzip"),
+			Header:             http.Header{"Accept-Encoding": {"gzip"}},
+			IgnoreRobotsTxt:    true,
+			DisableCompression: true,
+		}
+
+		resp, err := c.Do(rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body().Close()
+
+		if resp.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf(gotWantFormat, resp.Header().Get("Content-Encoding"), "gzip")
+		}
+	})
+}